@@ -0,0 +1,76 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var approveReportCmd = &cobra.Command{
+	Use:   "approve-report <report>",
+	Short: "Approves a preflight report written by check-node --report",
+	Long: `Shows the checks of a preflight report and signs it with the signing key of this
+	installation once approved. prep-node --verify-report only accepts the reports signed
+	with the key of the report_approver_key setting, printed here, so only the holder of
+	that key approves them.`,
+	Example: "pf9ctl approve-report node-1.json",
+	Args:    cobra.ExactArgs(1),
+	Run:     approveReportRun,
+}
+
+func init() {
+	rootCmd.AddCommand(approveReportCmd)
+}
+
+func approveReportRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running approve-report==========")
+
+	report, err := pmk.ReadReport(args[0])
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	fmt.Printf("Host %s (%s), %s\n", report.Host.Hostname, report.Host.MachineID, report.Host.OS)
+	for _, check := range report.Checks {
+		switch {
+		case check.Passed:
+			fmt.Println(color.Green("✓ ") + check.Name)
+		case check.Mandatory:
+			fmt.Println(color.Red("x ") + check.Name + ": " + check.Error)
+		default:
+			fmt.Println(color.Yellow("! ") + check.Name + ": " + check.Error)
+		}
+	}
+
+	if !cmd.Flags().Changed("no-prompt") {
+		approve, err := util.AskBool("Approve the report")
+		if err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+		if !approve {
+			exit(0)
+		}
+	}
+
+	key, err := pmk.LoadReportKey()
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if err := report.Sign(key); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if err := pmk.WriteReport(report, args[0]); err != nil {
+		zap.S().Fatalf("Unable to write the preflight report: %s", err.Error())
+	}
+	public := key.Public().(ed25519.PublicKey)
+	fmt.Printf(color.Green("✓ ")+"Report approved with the key %s\n", pmk.Fingerprint(public))
+	fmt.Printf("prep-node accepts the reports of this key with: pf9ctl config set report_approver_key=%s\n", pmk.EncodeApproverKey(public))
+
+	zap.S().Debug("==========Finished running approve-report==========")
+}
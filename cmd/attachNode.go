@@ -1,15 +1,21 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"os"
+	"text/tabwriter"
 	"time"
 
 	"github.com/platform9/pf9ctl/pkg/client"
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
 	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -20,13 +26,28 @@ var (
 	workerIPs   []string
 	clusterName string
 	Errhostid   error
+
+	allowEvenMasters  bool
+	continueOnError   bool
+	attachInteractive bool
+	attachWait        bool
+	attachMeshTest    bool
+
+	attachNodeFile  string
+	attachOverrides pmk.NodeOverrides
+	attachSSH       objects.NodeConfig
 )
 
+// exitPartialAttach is the exit code of attach-node --continue-on-error when
+// some of the nodes were left out, to tell it from a failed attach
+const exitPartialAttach = 3
+
 var (
 	attachNodeCmd = &cobra.Command{
 		Use:   "attach-node [flags] cluster-name",
 		Short: "Attaches a node to the Kubernetes cluster",
-		Long:  "Attach nodes to existing cluster. At a time, multiple workers but only one master can be attached",
+		Long: `Attach nodes to existing cluster. Workers are attached together, masters are attached one
+	at a time waiting for each of them to become healthy before attaching the next one`,
 		Args: func(attachNodeCmd *cobra.Command, args []string) error {
 			if len(args) > 1 {
 				return errors.New("only cluster name is accepted as a parameter")
@@ -53,14 +74,43 @@ func init() {
 	attachNodeCmd.Flags().StringSliceVarP(&workerIPs, "worker-ip", "w", []string{}, "worker node ip address")
 	attachNodeCmd.Flags().StringVarP(&clusterUuid, "uuid", "u", "", "uuid of the cluster to attach the node to")
 	attachNodeCmd.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
+	attachNodeCmd.Flags().BoolVar(&allowEvenMasters, "allow-even-masters", false, "allow attaching a second master to a single master cluster")
+	attachNodeCmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, fmt.Sprintf("attach the nodes which can be when others can't, exiting with code %d if any was left out", exitPartialAttach))
+	attachNodeCmd.Flags().BoolVar(&attachInteractive, "interactive", false, "pick the masters and workers from a menu of the authorized, unattached hosts of the DU")
+	attachNodeCmd.Flags().BoolVar(&attachWait, "wait", false, "wait for the nodes to converge, showing the task each node is running")
+	attachNodeCmd.Flags().DurationVar(&pmk.ConvergeTimeout, "wait-timeout", pmk.ConvergeTimeout, "how long --wait waits for the nodes to converge")
+	attachNodeCmd.Flags().DurationVar(&pmk.MasterHealthTimeout, "master-timeout", pmk.MasterHealthTimeout, "how long to wait for each master to become healthy")
+	attachNodeCmd.Flags().StringVar(&attachNodeFile, "node-file", "", "YAML file listing the nodes to attach with their ip, role and optional region, nodeIP, maxPods, kubeReserved and systemReserved")
+	attachNodeCmd.Flags().StringVar(&attachOverrides.NodeIP, "node-ip", "", "IP the kubelet registers the node with, for multi-NIC hosts (only when attaching a single node)")
+	attachNodeCmd.Flags().IntVar(&attachOverrides.MaxPods, "max-pods", 0, "maximum number of pods of the kubelet")
+	attachNodeCmd.Flags().StringVar(&attachOverrides.KubeReserved, "kube-reserved", "", "resources reserved for the k8s services, e.g: cpu=500m,memory=1Gi")
+	attachNodeCmd.Flags().StringVar(&attachOverrides.SystemReserved, "system-reserved", "", "resources reserved for the OS, e.g: cpu=500m,memory=1Gi")
+	attachNodeCmd.Flags().StringVar(&attachSSH.User, "ssh-user", "", "ssh username for the nodes, to check they were prepared with the DU of the config")
+	attachNodeCmd.Flags().StringVar(&attachSSH.SshKey, "ssh-key", "", "ssh key file for connecting to the nodes")
+	attachNodeCmd.Flags().StringVar(&attachSSH.Password, "ssh-password", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	attachNodeCmd.Flags().StringVar(&attachSSH.SudoPassword, "sudo-pass", "", "sudo password for user on the nodes")
+	attachNodeCmd.Flags().BoolVar(&attachMeshTest, "mesh-test", false, "check the nodes reach each other on the ports of the cluster before attaching them, with the ssh credentials of the nodes")
+	attachNodeCmd.ValidArgsFunction = completeClusterNames
+	attachNodeCmd.RegisterFlagCompletionFunc("master-ip", completeNodeIPsIn(false))
+	attachNodeCmd.RegisterFlagCompletionFunc("worker-ip", completeNodeIPsIn(false))
 	rootCmd.AddCommand(attachNodeCmd)
 }
 
 func attachNodeRun(cmd *cobra.Command, args []string) {
 	zap.S().Debug("==========Running Attach Node==========")
+	requireWritable("attach-node")
 
 	detachedMode := cmd.Flags().Changed("no-prompt")
 
+	if attachInteractive {
+		if detachedMode {
+			zap.S().Fatalf("--interactive can't be used with --no-prompt")
+		}
+		if len(masterIPs)+len(workerIPs) > 0 || attachNodeFile != "" {
+			zap.S().Fatalf("--interactive can't be used with --master-ip, --worker-ip or --node-file")
+		}
+	}
+
 	if cmdexec.CheckRemote(nc) {
 		if !config.ValidateNodeConfig(&nc, !detachedMode) {
 			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
@@ -91,106 +141,214 @@ func attachNodeRun(cmd *cobra.Command, args []string) {
 
 	defer c.Segment.Close()
 
-	if len(masterIPs) == 0 && len(workerIPs) == 0 {
-		zap.S().Fatalf("No nodes were specified to be attached to the cluster")
+	// The overrides of the node file take precedence over the flags
+	overrides := make(map[string]pmk.NodeOverrides)
+	var nodeFile pmk.NodeFile
+	if attachNodeFile != "" {
+		if nodeFile, err = pmk.ReadNodeFile(attachNodeFile); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+		for _, node := range nodeFile.Nodes {
+			overrides[node.IP] = node.NodeOverrides
+		}
+	}
+
+	if attachOverrides.NodeIP != "" && len(masterIPs)+len(workerIPs)+len(nodeFile.Nodes) > 1 {
+		zap.S().Fatalf("--node-ip can only be used when attaching a single node, use nodeIP in --node-file instead")
+	}
+
+	// The nodes are only reached over SSH to check their DU when credentials are given
+	var sshConfig *objects.NodeConfig
+	if attachSSH.User != "" || attachSSH.SshKey != "" || attachSSH.Password != "" {
+		if !config.ValidateNodeConfig(&attachSSH, !detachedMode) {
+			zap.S().Fatal("Invalid ssh config of the nodes (--ssh-user and --ssh-key or --ssh-password), use 'single quotes' to pass password")
+		}
+		sshConfig = &attachSSH
+	}
+	if attachMeshTest && sshConfig == nil {
+		zap.S().Fatalf("--mesh-test needs the ssh credentials of the nodes, given with --ssh-user and --ssh-key or --ssh-password")
 	}
 
 	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
 	if err != nil {
 		zap.S().Debug("Failed to get keystone %s", err.Error())
 	}
-	projectId := auth.ProjectID
-	token := auth.Token
-	if clusterUuid != "" {
-		if clusterName, err = c.Qbert.CheckClusterExistsWithUuid(clusterUuid, projectId, token); err != nil {
-			zap.S().Fatalf("unable to verify cluster using uuid %s", err.Error())
-		} else if clusterName == "" {
-			zap.S().Fatalf("cluster with given uuid does not exist")
+	requireRole(auth, "attach-node")
+
+	if attachInteractive {
+		if masterIPs, workerIPs, err = pickAttachNodes(c, auth); err != nil {
+			zap.S().Fatalf("%s", err.Error())
 		}
-	} else {
-		_, clusterUuid, _, _ = c.Qbert.CheckClusterExists(clusterName, projectId, token)
 	}
-	_, _, clusterStatus, _ := c.Qbert.CheckClusterExists(clusterName, projectId, token)
 
-	if clusterStatus == "ok" {
+	groups := attachGroups(cfg.Region, masterIPs, workerIPs, nodeFile)
+	if len(groups) > 1 && clusterUuid != "" {
+		zap.S().Fatalf("--uuid can't be used with nodes in several regions, the cluster is found by its name in each region")
+	}
 
-		// master ips
-		var masterHostIDs []string
-		if len(masterIPs) > 0 {
-			masterHostIDs = c.Resmgr.GetHostId(token, masterIPs)
+	// The nodes of each region are attached with the client and the token of
+	// the region, to the cluster of the region
+	regions := client.NewRegions(*cfg, c, auth)
+	left := 0
+	for _, group := range groups {
+		rc, err := regions.Get(group.Region)
+		if err != nil {
+			zap.S().Fatalf("%s", err.Error())
 		}
-
-		// worker ips
-		var workerHostIDs []string
-		if len(workerIPs) > 0 {
-			workerHostIDs = c.Resmgr.GetHostId(token, workerIPs)
+		if len(groups) > 1 {
+			fmt.Printf("\nAttaching the nodes of region %s\n", group.Region)
 		}
+		left += attachRegionNodes(rc, group, overrides, sshConfig, detachedMode)
+	}
+	if left > 0 {
+		fmt.Println(color.Yellow("! ") + fmt.Sprintf("%d node(s) were left out of the attach", left))
+		c.Segment.Close()
+		exit(exitPartialAttach)
+	}
+}
 
-		// Attaching worker node(s) to cluster
-		if err := c.Segment.SendEvent("Starting Attach-node", auth, "", ""); err != nil {
-			zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
+// attachGroups groups the nodes to attach by region, the nodes of
+// --master-ip and --worker-ip and the ones of the node file without a region
+// are in the region of the config
+func attachGroups(region string, masters, workers []string, nodeFile pmk.NodeFile) []pmk.RegionNodes {
+	var all pmk.NodeFile
+	for _, ip := range masters {
+		all.Nodes = append(all.Nodes, pmk.NodeFileEntry{IP: ip, Role: "master"})
+	}
+	for _, ip := range workers {
+		all.Nodes = append(all.Nodes, pmk.NodeFileEntry{IP: ip, Role: "worker"})
+	}
+	all.Nodes = append(all.Nodes, nodeFile.Nodes...)
+	if groups := all.ByRegion(region); len(groups) > 0 {
+		return groups
+	}
+	return []pmk.RegionNodes{{Region: region}}
+}
+
+// attachRegionNodes attaches the nodes of a region to the cluster of the
+// region and returns how many were left out
+func attachRegionNodes(rc client.RegionClient, group pmk.RegionNodes, overrides map[string]pmk.NodeOverrides, sshConfig *objects.NodeConfig, detachedMode bool) int {
+	c, auth := rc.Client, rc.Auth
+	if attachMeshTest {
+		uuid := clusterUuid
+		if uuid == "" {
+			uuid = clusterUUID(c, auth, clusterName)
 		}
-		if len(workerHostIDs) > 0 {
-			fmt.Printf("Attaching node to the cluster %s\n", clusterName)
-			var wokerids []string
-			for _, worker := range workerHostIDs {
-				if cname := c.Qbert.GetNodeInfo(token, projectId, worker); cname.ClusterName != "" {
-					zap.S().Infof("Node with host id %s is connected to %s cluster", worker, cname)
-				} else {
-					wokerids = append(wokerids, worker)
-				}
-			}
-			if len(wokerids) > 0 {
-				err1 := c.Qbert.AttachNode(clusterUuid, projectId, token, wokerids, "worker")
+		spec, err := c.Qbert.GetClusterSpec(uuid, auth.ProjectID, auth.Token)
+		if err != nil {
+			zap.S().Fatalf("Unable to get the network plugin of the cluster: %s", err.Error())
+		}
+		plugin, _ := spec["networkPlugin"].(string)
+		nodes, err := meshNodes(*sshConfig, group.MasterIPs, group.WorkerIPs, detachedMode)
+		if err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+		if blocked := runMeshTest(nodes, plugin); blocked > 0 {
+			zap.S().Fatalf("%d port(s) are blocked between the nodes, open them or attach without --mesh-test", blocked)
+		}
+	}
 
-				if err1 != nil {
-					if err := c.Segment.SendEvent("Attaching-node", auth, "Failed to attach worker node", ""); err != nil {
-						zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
-					}
-					zap.S().Info("Encountered an error while attaching worker node to a Kubernetes cluster : ", err1)
-				} else {
-					if err := c.Segment.SendEvent("Attaching-node", auth, "Worker node attached", ""); err != nil {
-						zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
-					}
-					zap.S().Infof("Worker node(s) %v attached to cluster", wokerids)
-				}
-			} else {
-				zap.S().Infof("No worker node available to attach to the cluster")
-			}
+	job, err := pmk.NewAttachJob(context.Background(), c, auth, rc.Config.Fqdn, pmk.AttachNodesInput{
+		ClusterName:      clusterName,
+		ClusterUuid:      clusterUuid,
+		MasterIPs:        group.MasterIPs,
+		WorkerIPs:        group.WorkerIPs,
+		AllowEvenMasters: allowEvenMasters,
+		Overrides:        overrides,
+		DefaultOverrides: attachOverrides,
+		Region:           group.Region,
+		SSH:              sshConfig,
+		ContinueOnError:  continueOnError,
+	})
+	var unresolved *pmk.UnresolvedNodesError
+	if errors.As(err, &unresolved) && job != nil {
+		for _, node := range unresolved.Nodes {
+			fmt.Println(color.Red("x ") + fmt.Sprintf("Leaving out %s node %s: %s", node.Role, node.IP, node.Reason))
+		}
+	} else if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	fmt.Printf("Started job %s, resume it with 'pf9ctl jobs resume %s' if interrupted\n", job.ID, job.ID)
 
+	if err := pmk.RunJob(c, auth, job); err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	if attachWait {
+		var ips, hostIDs []string
+		for _, host := range job.Hosts {
+			ips = append(ips, host.IP)
+			hostIDs = append(hostIDs, host.HostID)
 		}
-		// Attaching master node(s) to cluster
-		if len(masterHostIDs) > 0 {
-			fmt.Printf("Attaching node to the cluster %s\n", clusterName)
-			var masterids []string
-			for _, master := range masterHostIDs {
-				if cname := c.Qbert.GetNodeInfo(token, projectId, master); cname.ClusterName != "" {
-					zap.S().Infof("Node with host id %s is connected to %s cluster", master, cname)
-				} else {
-					masterids = append(masterids, master)
-				}
-			}
-			if len(masterids) > 0 {
-				err1 := c.Qbert.AttachNode(clusterUuid, projectId, token, masterids, "master")
+		if err := pmk.WaitForConvergence(c, auth, ips, hostIDs, pmk.ConvergeTimeout); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+		fmt.Println(color.Green("✓ ") + "All the nodes converged")
+	}
+	if unresolved != nil {
+		return len(unresolved.Nodes)
+	}
+	return 0
+}
 
-				if err1 != nil {
-					if err := c.Segment.SendEvent("Attaching-node", auth, "Failed to attach master node", ""); err != nil {
-						zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
-					}
-					zap.S().Info("Encountered an error while attaching master node to a Kubernetes cluster : ", err1)
-				} else {
-					if err := c.Segment.SendEvent("Attaching-node", auth, "Master node attached", ""); err != nil {
-						zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
+// pickAttachNodes lists the hosts which can be attached and asks which of
+// them to attach as masters and as workers
+func pickAttachNodes(c client.Client, auth keystone.KeystoneAuth) ([]string, []string, error) {
+	candidates, err := pmk.AttachCandidates(c, auth)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil, errors.New("no authorized, responding host is left to attach, run prep-node on the nodes first")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "#\tHOSTNAME\tIP\tOS")
+	for i, candidate := range candidates {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", i+1, candidate.Hostname, candidate.IP, candidate.OS)
+	}
+	w.Flush()
+
+	r := bufio.NewReader(os.Stdin)
+	ask := func(role string, taken map[int]bool) ([]string, error) {
+		for {
+			fmt.Printf("Select the %s nodes (e.g: 1,3 or 2-4, empty for none): ", role)
+			line, err := r.ReadString('\n')
+			if err != nil && line == "" {
+				return nil, fmt.Errorf("Unable to read the %s nodes: %w", role, err)
+			}
+			indexes, err := util.ParseSelection(line, len(candidates))
+			if err == nil {
+				for _, i := range indexes {
+					if taken[i] {
+						err = fmt.Errorf("%s is already selected as a master", candidates[i].Hostname)
+						break
 					}
-					zap.S().Infof("Master node(s) %v attached to cluster", masterids)
 				}
-			} else {
-				zap.S().Infof("No master node available to attach to the cluster")
 			}
-
+			if err != nil {
+				fmt.Println(color.Red("x ") + err.Error())
+				continue
+			}
+			var ips []string
+			for _, i := range indexes {
+				taken[i] = true
+				ips = append(ips, candidates[i].IP)
+			}
+			return ips, nil
 		}
-	} else {
-		zap.S().Fatalf("Cluster is not ready. cluster status is %v", clusterStatus)
 	}
 
+	taken := make(map[int]bool)
+	masters, err := ask("master", taken)
+	if err != nil {
+		return nil, nil, err
+	}
+	workers, err := ask("worker", taken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(masters)+len(workers) == 0 {
+		return nil, nil, errors.New("no node was selected")
+	}
+	return masters, workers, nil
 }
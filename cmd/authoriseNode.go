@@ -19,7 +19,8 @@ import (
 var authNodeCmd = &cobra.Command{
 	Use:   "authorize-node",
 	Short: "Authorizes this node with PMK control plane",
-	Long:  "Authorizes this node.",
+	Long: `Authorizes this node. A node which was deauthorized, e.g. by mistake, is authorized
+	again without running prep-node as long as its hostagent is running.`,
 	Args: func(deauthNodeCmd *cobra.Command, args []string) error {
 		if len(args) > 0 {
 			return errors.New("No parameters are needed")
@@ -31,15 +32,20 @@ var authNodeCmd = &cobra.Command{
 var ipAdd string
 
 func init() {
+	authNodeCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
 	rootCmd.AddCommand(authNodeCmd)
 	authNodeCmd.Flags().StringVarP(&ipAdd, "ip", "i", "", "IP address of the host to be authorized")
 	authNodeCmd.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
 }
 
 func authNodeRun(cmd *cobra.Command, args []string) {
+	requireWritable("authorize-node")
 
 	detachedMode := cmd.Flags().Changed("no-prompt")
 
+	if err := cmdexec.CheckLocal(nc); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
 	if cmdexec.CheckRemote(nc) {
 		if !config.ValidateNodeConfig(&nc, !detachedMode) {
 			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
@@ -67,31 +73,19 @@ func authNodeRun(cmd *cobra.Command, args []string) {
 	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
 		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
 	}
+	defer c.Segment.Close()
 
 	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
 	if err != nil {
-		zap.S().Debug("Failed to get keystone %s", err.Error())
+		zap.S().Fatalf("Unable to obtain keystone credentials: %s", err.Error())
 	}
+	requireRole(auth, "authorize-node")
 
-	var nodeIPs []string
-	if ipAdd != "" {
-		nodeIPs = append(nodeIPs, ipAdd)
-	} else {
-		nodeIPs = append(nodeIPs, pmk.GetIp().String())
+	ip := ipAdd
+	if ip == "" {
+		ip = localIP()
 	}
-	token := auth.Token
-	nodeUuids := c.Resmgr.GetHostId(token, nodeIPs)
-
-	if len(nodeUuids) == 0 {
-		zap.S().Fatalf("Could not find the node. Check if the node associated with this account")
+	if err := pmk.AuthorizeNode(c, auth, ip); err != nil {
+		zap.S().Fatalf("Unable to authorize node: %s", err.Error())
 	}
-
-	err1 := c.Qbert.AuthoriseNode(nodeUuids[0], token)
-
-	if err1 != nil {
-		zap.S().Fatalf("Error authorising node ", err1.Error())
-	}
-
-	fmt.Println("Node authorization started....This may take a few minutes....Check the latest status in UI")
-	zap.S().Debug("Node authorization started....This may take a few minutes....Check the latest status in UI")
 }
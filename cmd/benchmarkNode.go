@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var benchmarkNodeCmd = &cobra.Command{
+	Use:   "benchmark-node",
+	Short: "Benchmarks the disk and the network of a node against the etcd requirements",
+	Long: `Measures the fdatasync latency of the disk etcd would use, with fio or with dd when fio isn't
+	installed, and with --peer the round trip and the bandwidth to another node, with ping and iperf3.
+	The results are compared to the requirements of etcd, which only runs on the masters, and the
+	node is graded as suitable as a master or as a worker only. --install-tools installs fio and iperf3 for
+	the benchmark and removes them afterwards.`,
+	Example: "pf9ctl benchmark-node --ip 10.0.0.1 --peer 10.0.0.2 -u ubuntu -s ~/.ssh/id_rsa --install-tools",
+	Args:    cobra.NoArgs,
+	Run:     benchmarkNodeRun,
+}
+
+var (
+	benchmarkConfig       objects.NodeConfig
+	benchmarkIP           string
+	benchmarkPeer         string
+	benchmarkDir          string
+	benchmarkDuration     time.Duration
+	benchmarkInstallTools bool
+	benchmarkRole         string
+)
+
+func init() {
+	benchmarkNodeCmd.Flags().StringVarP(&benchmarkConfig.User, "user", "u", "", "ssh username for the nodes")
+	benchmarkNodeCmd.Flags().StringVarP(&benchmarkConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	benchmarkNodeCmd.Flags().StringVarP(&benchmarkConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	benchmarkNodeCmd.Flags().StringVarP(&benchmarkConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	benchmarkNodeCmd.Flags().StringVarP(&benchmarkIP, "ip", "i", "", "IP address of the node to benchmark")
+	benchmarkNodeCmd.Flags().StringVar(&benchmarkPeer, "peer", "", "IP address of another node to benchmark the network with, reached with the same SSH credentials")
+	benchmarkNodeCmd.Flags().StringVar(&benchmarkDir, "dir", "/var/lib", "directory on the disk etcd would use")
+	benchmarkNodeCmd.Flags().DurationVar(&benchmarkDuration, "duration", 10*time.Second, "how long the bandwidth is measured")
+	benchmarkNodeCmd.Flags().BoolVar(&benchmarkInstallTools, "install-tools", false, "install fio and iperf3 for the benchmark, and remove them afterwards")
+	benchmarkNodeCmd.Flags().StringVar(&benchmarkRole, "role", "master", "role the node is benchmarked for, master or worker, the command fails when the node isn't suitable for it")
+	benchmarkNodeCmd.MarkFlagRequired("ip")
+	benchmarkNodeCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
+	benchmarkNodeCmd.RegisterFlagCompletionFunc("peer", completeNodeIPs)
+	rootCmd.AddCommand(benchmarkNodeCmd)
+}
+
+func benchmarkNodeRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running benchmark-node==========")
+	if benchmarkInstallTools {
+		requireWritable("benchmark-node --install-tools")
+	}
+
+	if benchmarkRole != "master" && benchmarkRole != "worker" {
+		zap.S().Fatalf("--role is either master or worker")
+	}
+	if benchmarkDuration < time.Second {
+		zap.S().Fatalf("--duration is at least 1s")
+	}
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	benchmarkConfig.IPs = []string{benchmarkIP}
+	if !config.ValidateNodeConfig(&benchmarkConfig, !detachedMode) {
+		zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+	}
+
+	executor := benchmarkExecutor(benchmarkIP, detachedMode)
+	opts := pmk.BenchmarkOptions{Dir: benchmarkDir, PeerIP: benchmarkPeer, Duration: benchmarkDuration}
+	if benchmarkPeer != "" {
+		opts.Peer = benchmarkExecutor(benchmarkPeer, detachedMode)
+	}
+
+	// The tools installed are removed once benchmarked, before the grade
+	// is reported
+	var installed []benchmarkTools
+	if benchmarkInstallTools {
+		installed = installBenchmarkTools(installed, benchmarkIP, executor)
+		if opts.Peer != nil {
+			installed = installBenchmarkTools(installed, benchmarkPeer, opts.Peer)
+		}
+	}
+
+	fmt.Printf("Benchmarking node %s, this takes about %s\n", benchmarkIP, benchmarkDuration+30*time.Second)
+	results := pmk.BenchmarkNode(executor, opts)
+	removeBenchmarkTools(installed)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "BENCHMARK\tTOOL\tRESULT\tREQUIREMENT\tSTATUS")
+	for _, r := range results {
+		status, value := "PASS", r.Value
+		switch {
+		case r.Err != nil:
+			status, value = "SKIPPED", "-"
+		case !r.Pass:
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.Tool, value, r.Requirement, status)
+	}
+	w.Flush()
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Println(color.Yellow("! ") + fmt.Sprintf("%s: %s", r.Name, r.Err))
+		}
+	}
+	if benchmarkPeer == "" {
+		fmt.Println(color.Yellow("! ") + "The network wasn't benchmarked, pass --peer with the IP of another node")
+	}
+
+	grade := pmk.GradeNode(results)
+	switch {
+	case grade == pmk.GradeMaster, grade == pmk.GradeWorker && benchmarkRole == "worker":
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("Node %s is %s", benchmarkIP, grade))
+	default:
+		zap.S().Fatalf("Node %s is %s", benchmarkIP, grade)
+	}
+
+	zap.S().Debug("==========Finished running benchmark-node==========")
+}
+
+// benchmarkExecutor returns the executor of the node at ip, exiting when it
+// can't be reached
+func benchmarkExecutor(ip string, detachedMode bool) cmdexec.Executor {
+	nodeCfg := benchmarkConfig
+	nodeCfg.IPs = []string{ip}
+	executor, err := cmdexec.GetExecutor("", nodeCfg)
+	if err != nil {
+		zap.S().Fatalf("Unable to connect to node %s: %s", ip, err.Error())
+	}
+	if err := SudoPasswordCheck(executor, detachedMode, nodeCfg.SudoPassword); err != nil {
+		zap.S().Fatalf("Node %s: %s", ip, err.Error())
+	}
+	return executor
+}
+
+// benchmarkTools are the benchmark tools installed on a node
+type benchmarkTools struct {
+	ip       string
+	executor cmdexec.Executor
+	tools    []string
+}
+
+// installBenchmarkTools installs the benchmark tools on the node at ip and
+// adds them to installed. The tools already installed are removed when it
+// fails.
+func installBenchmarkTools(installed []benchmarkTools, ip string, executor cmdexec.Executor) []benchmarkTools {
+	tools, err := pmk.InstallBenchmarkTools(executor)
+	if err != nil {
+		removeBenchmarkTools(installed)
+		zap.S().Fatalf("Node %s: %s", ip, err.Error())
+	}
+	if len(tools) > 0 {
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("Installed %s on node %s for the benchmark", strings.Join(tools, ", "), ip))
+	}
+	return append(installed, benchmarkTools{ip, executor, tools})
+}
+
+// removeBenchmarkTools removes the benchmark tools installed on the nodes
+func removeBenchmarkTools(installed []benchmarkTools) {
+	for _, node := range installed {
+		if err := pmk.RemoveBenchmarkTools(node.executor, node.tools); err != nil {
+			fmt.Println(color.Yellow("! ") + fmt.Sprintf("Node %s: %s", node.ip, err))
+		}
+	}
+}
@@ -8,16 +8,17 @@ import (
 	"strings"
 	"time"
 
-	"github.com/briandowns/spinner"
 	"github.com/platform9/pf9ctl/pkg/client"
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/interrupt"
 	"github.com/platform9/pf9ctl/pkg/log"
 	"github.com/platform9/pf9ctl/pkg/objects"
 	"github.com/platform9/pf9ctl/pkg/pmk"
 	"github.com/platform9/pf9ctl/pkg/qbert"
 	"github.com/platform9/pf9ctl/pkg/supportBundle"
+	"github.com/platform9/pf9ctl/pkg/ui"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -42,6 +43,7 @@ Optional Flags:
 	    --container-runtime string            The container runtime for the cluster (default "containerd")
 	    --containers-cidr string              CIDR for container overlay (default "10.20.0.0/16")
 	    --controller-manager-flags strings    Comma separated list of supported kube-controller-manager flags, e.g: --large-cluster-size-threshold=60,--concurrent-statefulset-syncs=10
+	    --download-limit string               Maximum rate the node downloads the installer at, e.g: 10MB/s (default unlimited or the download-limit of the config)
 	    --enable-kubeVirt                     Enables Kubernetes to run Virtual Machines within Pods. This feature is not recommended for production workloads, use either --enable-kubeVirt or --enable-kubeVirt=true to change
 	    --enable-profile-engine               Simplify cluster governance using the Platform9 Profile Engine, use either --enable-profile-engine or --enable-profile-engine=false to change (default true)
 	    --etcd-backup                         Enable automated etcd backups on this cluster, use either --etcd-backup or --etcd-backup=false to change (default true)
@@ -145,6 +147,13 @@ func init() {
 	bootstrapCmd.Flags().StringVar(&httpProxy, "http-proxy", "", "Specify the HTTP proxy for this cluster. Format-> <scheme>://<username>:<password>@<host>:<port>, username and password are optional.")
 	bootstrapCmd.Flags().IntVar(&intervalInMins, "interval-in-mins", 30, "time interval of etcd-backup in minutes(should be between 30 to 60)")
 	bootstrapCmd.Flags().StringVar(&backupPath, "etcd-backup-path", "/etc/pf9/etcd-backup", "Backup path for etcd")
+	bootstrapCmd.Flags().StringVar(&workDir, "work-dir", "", "Directory of the node the installer is downloaded to (default $HOME/pf9 or the work-dir of the config)")
+	bootstrapCmd.Flags().StringArrayVar(&installerArgs, "installer-arg", nil, "Extra argument of the installer, e.g: --installer-arg=--some-option=value, repeat it for several arguments, they follow the installer_args of the config")
+	bootstrapCmd.Flags().StringVar(&hostagentVersion, "hostagent-version", "", "Version of the hostagent the DU is asked to install, e.g: 5.6.0, to stage a rollout or roll back an agent release (default the latest or the hostagent_version of the config)")
+	bootstrapCmd.Flags().BoolVar(&skipSignatureVerify, "skip-signature-verify", false, "Install the installer without verifying its GPG signature against the signing keys of Platform9, only for DUs which don't sign their installers")
+	bootstrapCmd.Flags().StringVar(&downloadLimit, "download-limit", "", "Maximum rate the node downloads the installer at, e.g: 10MB/s (default unlimited or the download-limit of the config)")
+	bootstrapCmd.Flags().StringVar(&packageRepo, "package-repo", "", "URL of the repo built by 'pf9ctl mirror create' the node installs the OS packages from, e.g: http://10.0.0.5/repo or file:///srv/repo (default the package_repo of the config)")
+	bootstrapCmd.Flags().StringVar(&packageRepoKey, "package-repo-key", "", "File of the public key of the package repo, the "+pmk.MirrorKeyName+" 'pf9ctl mirror create' writes in the repo, copied from the machine which built it (default the package_repo_key of the config)")
 	bootstrapCmd.SetHelpTemplate(boostrapHelpTemplate)
 	rootCmd.AddCommand(bootstrapCmd)
 }
@@ -187,8 +196,14 @@ var (
 
 func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 	zap.S().Debug("Received a call to bootstrap the node")
+	requireWritable("bootstrap")
+	cleanUpOnInterrupt("bootstrap")
 
 	detachedMode := cmd.Flags().Changed("no-prompt")
+	bootConfig.NoPrompt = detachedMode
+	if err := cmdexec.CheckLocal(bootConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
 	isRemote := cmdexec.CheckRemote(bootConfig)
 
 	isEtcdBackupDisabled := cmd.Flags().Changed("etcd-backup")
@@ -210,6 +225,35 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 		qbert.SplitKeyValue = strings.Split(tag, "=")
 	}
 
+	if workDir != "" {
+		if err := pmk.ValidateWorkDir(workDir); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+	}
+	if downloadLimit != "" {
+		if err := pmk.ValidateDownloadLimit(downloadLimit); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+	}
+	if err := pmk.ValidateInstallerArgs(installerArgs); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if hostagentVersion != "" {
+		if err := pmk.ValidateHostagentVersion(hostagentVersion); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+	}
+	if packageRepo != "" {
+		if err := pmk.ValidatePackageRepo(packageRepo); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+	}
+	if packageRepoKey != "" {
+		if err := pmk.ValidatePackageRepoKey(packageRepoKey); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+	}
+
 	if isRemote {
 		if !config.ValidateNodeConfig(&bootConfig, !detachedMode) {
 			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
@@ -226,6 +270,26 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
 	}
+	if workDir != "" {
+		cfg.WorkDir = workDir
+	}
+	if downloadLimit != "" {
+		cfg.DownloadLimit = downloadLimit
+	}
+	if hostagentVersion != "" {
+		cfg.HostagentVersion = hostagentVersion
+	}
+	if packageRepo != "" {
+		cfg.PackageRepo = packageRepo
+	}
+	if packageRepoKey != "" {
+		cfg.PackageRepoKey = packageRepoKey
+	}
+	cfg.InstallerArgs = append(cfg.InstallerArgs, installerArgs...)
+	cfg.SkipSignatureVerify = skipSignatureVerify
+	if skipSignatureVerify {
+		fmt.Println(color.Yellow("! ") + "The signature of the installer isn't verified, it could be tampered with by a mirror or a proxy")
+	}
 
 	fmt.Println(color.Green("✓ ") + "Loaded Config Successfully")
 	zap.S().Debug("Loaded Config Successfully")
@@ -256,9 +320,13 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 		cfg.Tenant,
 		cfg.MfaToken,
 	)
+	requireRole(auth, "bootstrap")
 
 	//Getting all pmk versions
-	pmkRoles := c.Qbert.GetPMKVersions(auth.Token, auth.ProjectID)
+	pmkRoles, err := c.Qbert.GetPMKVersions(auth.Token, auth.ProjectID)
+	if err != nil {
+		zap.S().Fatalf("Unable to get the pmk versions: %s", err.Error())
+	}
 
 	qbert.IsPMKversionDefined = cmd.Flags().Changed("pmk-version")
 	if qbert.IsPMKversionDefined {
@@ -297,18 +365,14 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 		zap.S().Fatalf("%s pmk-version is not supported", pmkVersion)
 	}
 
-	s := spinner.New(spinner.CharSets[9], 100*time.Millisecond)
-	s.Color("red")
-	s.Start()
-	defer s.Stop()
 	zap.S().Debug("Running pre-requisite checks for Bootstrap command")
-	s.Suffix = " Running pre-requisite checks for Bootstrap command"
+	phase := ui.StartPhase("Running pre-requisite checks for Bootstrap command")
 
 	val, val1, err := pmk.PreReqBootstrap(executor)
+	phase.Stop()
 	if err != nil {
-		zap.S().Fatalf("Error running Prerequisite Checks for Bootstrap Command")
+		zap.S().Fatalf("Error running Prerequisite Checks for Bootstrap Command: %s", err.Error())
 	}
-	s.Stop()
 	if !val1 && !val { //Both node and cluster are already present
 		zap.S().Fatalf(color.Red("x ") + " Cannot run this command as this node is already attached to a cluster")
 
@@ -323,6 +387,14 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 	if !util.SkipPrepNode {
 		zap.S().Debug("========== Running check-node as a part of bootstrap ==========")
 
+		// The packages the checks and the installer install come from the
+		// repo, which is removed once the node is prepared
+		removeRepo := addPackageRepo(cfg, executor)
+		fatalf := func(template string, args ...interface{}) {
+			removeRepo()
+			zap.S().Fatalf(template, args...)
+		}
+
 		result, err := pmk.CheckNode(*cfg, c, auth, bootConfig)
 		if err != nil {
 			// Uploads pf9cli log bundle if checknode fails
@@ -330,11 +402,11 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 			if errbundle != nil {
 				zap.S().Debugf("Unable to upload supportbundle to s3 bucket %s", errbundle.Error())
 			}
-			zap.S().Fatalf("Unable to perform pre-requisite checks on this node: %s", err.Error())
+			fatalf("Unable to perform pre-requisite checks on this node: %s", err.Error())
 		}
 
 		if result == pmk.RequiredFail {
-			zap.S().Fatalf(color.Red("x ")+"Required pre-requisite check(s) failed. See %s or use --verbose for logs \n", log.GetLogLocation(util.Pf9Log))
+			fatalf(color.Red("x ")+"Required pre-requisite check(s) failed. See %s or use --verbose for logs \n", log.GetLogLocation(util.Pf9Log))
 			//this is so the exit flag is set to 1
 		} else if result == pmk.OptionalFail {
 			fmt.Printf("\nOptional pre-requisite check(s) failed. See %s or use --verbose for logs \n", log.GetLogLocation(util.Pf9Log))
@@ -349,14 +421,16 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 		if !detachedMode {
 			resp, err := util.AskBool("Prep local node as master node for kubernetes cluster")
 			if err != nil || !resp {
-				zap.S().Fatalf(" Declined to proceed with creating a Kubernetes cluster with the current node as the master node ")
+				fatalf(" Declined to proceed with creating a Kubernetes cluster with the current node as the master node ")
 			}
 		} else {
 			fmt.Println(" Proceeding to create a Kubernetes cluster with current node as master node")
 		}
 
 		zap.S().Debug("========== Running prep-node as a part of bootstrap ==========")
-		if err := pmk.PrepNode(*cfg, c, auth); err != nil {
+		if err := pmk.PrepNodeContext(interrupt.Context(), *cfg, c, auth); err != nil {
+			// An interrupted prep-node exits once cleaned up
+			interrupt.Wait()
 
 			// Uploads pf9cli log bundle if prepnode failed to get prepared
 			errbundle := supportBundle.SupportBundleUpload(*cfg, c, isRemote)
@@ -365,8 +439,9 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 			}
 
 			zap.S().Debugf("Unable to prep node: %s\n", err.Error())
-			zap.S().Fatalf("\nFailed to prepare node. See %s or use --verbose for logs\n", log.GetLogLocation(util.Pf9Log))
+			fatalf("\nFailed to prepare node. See %s or use --verbose for logs\n", log.GetLogLocation(util.Pf9Log))
 		}
+		removeRepo()
 
 		zap.S().Debug("==========Finished running prep-node==========")
 	}
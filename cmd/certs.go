@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var certsCmd = &cobra.Command{
+	Use:   "certs",
+	Short: "Reports and rotates the certificates of clusters",
+}
+
+var certsStatusCmd = &cobra.Command{
+	Use:   "status <cluster>",
+	Short: "Lists when the control plane certificates of a cluster expire",
+	Long: `Lists when the CA of the cluster and the serving certificate of its API server expire.
+	Exits with an error when a certificate expires within --warn-days, has expired or can't be read,
+	so it can be run from cron to monitor the clusters.`,
+	Example: "pf9ctl certs status my-cluster --warn-days 14 --no-prompt",
+	Args:    cobra.ExactArgs(1),
+	Run:     certsStatusRun,
+}
+
+var certsRotateCmd = &cobra.Command{
+	Use:   "rotate <cluster>",
+	Short: "Triggers the rotation of the control plane certificates of a cluster",
+	Long: `Asks qbert to rotate the certificates of the control plane of the cluster. The nodes
+	converge to the new certificates afterwards, which restarts the components of the cluster.`,
+	Example: "pf9ctl certs rotate my-cluster",
+	Args:    cobra.ExactArgs(1),
+	Run:     certsRotateRun,
+}
+
+var (
+	certsMFA      string
+	certsWarnDays int
+)
+
+func init() {
+	certsStatusCmd.Flags().IntVar(&certsWarnDays, "warn-days", 30, "Days before their expiry certificates are reported as expiring")
+	certsStatusCmd.Flags().StringVar(&certsMFA, "mfa", "", "MFA token")
+	certsStatusCmd.ValidArgsFunction = completeClusterNames
+	certsRotateCmd.Flags().StringVar(&certsMFA, "mfa", "", "MFA token")
+	certsRotateCmd.ValidArgsFunction = completeClusterNames
+	certsCmd.AddCommand(certsStatusCmd)
+	certsCmd.AddCommand(certsRotateCmd)
+	rootCmd.AddCommand(certsCmd)
+}
+
+func certsStatusRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running certs status==========")
+
+	if certsWarnDays < 0 {
+		zap.S().Fatalf("--warn-days can't be negative")
+	}
+	_, c, auth := loadClient(cmd, certsMFA)
+	defer c.Segment.Close()
+
+	clusterName := args[0]
+	clusterUuid := clusterUUID(c, auth, clusterName)
+	spec, err := c.Qbert.GetClusterSpec(clusterUuid, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to get cluster %s: %s", clusterName, err.Error())
+	}
+	// The CA is reported as unreadable when the kubeconfig can't be fetched
+	kubeconfig, err := c.Qbert.GetKubeconfig(clusterUuid, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Debugf("Unable to get the kubeconfig of cluster %s: %s", clusterName, err.Error())
+	}
+
+	now := time.Now()
+	warn := time.Duration(certsWarnDays) * 24 * time.Hour
+	failing := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CERTIFICATE\tEXPIRES\tDAYS LEFT\tSTATUS")
+	for _, cert := range pmk.ClusterCerts(kubeconfig, spec, 5*time.Second) {
+		status := cert.Status(now, warn)
+		if status != "OK" {
+			failing++
+		}
+		if cert.Err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\t%s\n", cert.Name, status)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", cert.Name, cert.NotAfter.Format(time.RFC3339), int(cert.NotAfter.Sub(now).Hours()/24), status)
+	}
+	w.Flush()
+
+	if failing > 0 {
+		zap.S().Fatalf("%d certificate(s) of cluster %s need attention, rotate them with 'pf9ctl certs rotate %s'", failing, clusterName, clusterName)
+	}
+
+	zap.S().Debug("==========Finished running certs status==========")
+}
+
+func certsRotateRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running certs rotate==========")
+
+	_, c, auth := loadClient(cmd, certsMFA)
+	defer c.Segment.Close()
+	requireRole(auth, "certs rotate")
+
+	clusterName := args[0]
+	clusterUuid := clusterUUID(c, auth, clusterName)
+
+	if !cmd.Flags().Changed("no-prompt") {
+		fmt.Printf("The certificates of cluster %s will be rotated, which restarts its control plane.\n", clusterName)
+		answer, err := util.AskBool("Do you want to continue?")
+		if err != nil || !answer {
+			fmt.Println("Stopping certs rotate")
+			return
+		}
+	}
+
+	if err := c.Qbert.RotateClusterCerts(clusterUuid, auth.ProjectID, auth.Token); err != nil {
+		if sendErr := c.Segment.Track(client.Event{Name: "Certs rotate", Phase: "rotate", Status: util.CheckFail, Err: err}, auth); sendErr != nil {
+			zap.S().Debugf("Unable to send Segment event for certs rotate. Error: %s", sendErr.Error())
+		}
+		zap.S().Fatalf("Unable to rotate the certificates of cluster %s: %s", clusterName, err.Error())
+	}
+	if err := c.Segment.Track(client.Event{Name: "Certs rotate", Phase: "rotate", Status: util.CheckPass}, auth); err != nil {
+		zap.S().Debugf("Unable to send Segment event for certs rotate. Error: %s", err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + fmt.Sprintf("Rotation of the certificates of cluster %s triggered, check it with 'pf9ctl certs status %s'", clusterName, clusterName))
+
+	zap.S().Debug("==========Finished running certs rotate==========")
+}
+
+// clusterUUID returns the UUID of the cluster, exiting when it doesn't exist
+func clusterUUID(c client.Client, auth keystone.KeystoneAuth, name string) string {
+	exists, uuid, _, err := c.Qbert.CheckClusterExists(name, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to check the cluster: %s", err.Error())
+	}
+	if !exists {
+		zap.S().Fatalf("Cluster %s not found", name)
+	}
+	return uuid
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var checkDUCmd = &cobra.Command{
+	Use:   "check-du",
+	Short: "Checks the health of the Platform9 controller endpoints",
+	Long: `Checks the API endpoints of the Platform9 controller (DU) and reports their latency,
+	along with the expiry dates of the TLS certificates of the DU and of its regions.
+	Expired or expiring certificates break the communication of the hostagent with the DU.
+	Exits with an error when an endpoint is down or a certificate is expired or invalid.`,
+	Args: cobra.NoArgs,
+	Run:  checkDURun,
+}
+
+var checkDUMFA string
+
+func init() {
+	checkDUCmd.Flags().StringVar(&checkDUMFA, "mfa", "", "MFA token")
+	checkDUCmd.Flags().DurationVar(&pmk.CertExpiryWarning, "warn-before", pmk.CertExpiryWarning, "report certificates expiring within this duration")
+	rootCmd.AddCommand(checkDUCmd)
+}
+
+func checkDURun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running check-du==========")
+
+	// The config is still used when it can't be validated, as an expired
+	// certificate of the DU makes the validation fail
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: checkDUMFA}
+	err := config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	if err == config.NO_CONFIG {
+		zap.S().Fatalf("Unable to load the context: %s, create it with 'pf9ctl config set'", err.Error())
+	}
+	if err != nil {
+		fmt.Println(color.Yellow("! ") + fmt.Sprintf("Unable to validate the config: %s", err))
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+
+	healthy := true
+	hosts := []string{cfg.Fqdn}
+
+	auth, authErr := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if authErr != nil {
+		healthy = false
+		fmt.Println(color.Red("x ") + fmt.Sprintf("Unable to obtain keystone credentials, skipping the authenticated endpoints: %s", authErr))
+	} else if regions, err := keystone.FetchRegions(cfg.Fqdn, auth); err != nil {
+		fmt.Println(color.Yellow("! ") + fmt.Sprintf("Unable to fetch the regions: %s", err))
+	} else {
+		for _, name := range keystone.RegionNames(regions) {
+			hosts = append(hosts, regions[name])
+		}
+	}
+
+	endpoints := pmk.DUEndpoints(cfg.Fqdn, auth.ProjectID)
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tURL\tSTATUS\tLATENCY")
+	for _, name := range names {
+		health := pmk.CheckEndpoint(name, endpoints[name], auth.Token)
+		status := fmt.Sprintf("%d", health.Status)
+		if health.Err != nil {
+			status = "UNREACHABLE"
+		}
+		if !health.Healthy() {
+			healthy = false
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, health.URL, status, health.Latency.Round(time.Millisecond))
+	}
+	w.Flush()
+
+	fmt.Println()
+	now := time.Now()
+	seen := make(map[string]bool)
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "HOST\tEXPIRES\tDAYS LEFT\tISSUER\tSTATUS")
+	// The failures make the DU unhealthy, the warnings don't
+	var failures, warnings []string
+	for _, host := range hosts {
+		cert := pmk.CheckCertificate(host)
+		if seen[cert.Host] {
+			continue
+		}
+		seen[cert.Host] = true
+
+		status := cert.Status(now)
+		switch status {
+		case "UNREACHABLE":
+			failures = append(failures, fmt.Sprintf("%s: %s", cert.Host, cert.Err))
+			fmt.Fprintf(w, "%s\t-\t-\t-\t%s\n", cert.Host, status)
+			healthy = false
+			continue
+		case "INVALID":
+			failures = append(failures, fmt.Sprintf("%s: %s", cert.Host, cert.VerifyErr))
+			healthy = false
+		case "EXPIRED":
+			failures = append(failures, fmt.Sprintf("%s: certificate expired on %s", cert.Host, cert.NotAfter.Format("2006-01-02")))
+			healthy = false
+		case "EXPIRING":
+			warnings = append(warnings, fmt.Sprintf("%s: certificate expires on %s", cert.Host, cert.NotAfter.Format("2006-01-02")))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", cert.Host, cert.NotAfter.Format("2006-01-02"),
+			int(cert.NotAfter.Sub(now).Hours()/24), cert.Issuer, status)
+	}
+	w.Flush()
+
+	if len(failures)+len(warnings) > 0 {
+		fmt.Println()
+		for _, failure := range failures {
+			fmt.Println(color.Red("x ") + failure)
+		}
+		for _, warning := range warnings {
+			fmt.Println(color.Yellow("! ") + warning)
+		}
+	}
+	if !healthy {
+		fmt.Println()
+		zap.S().Fatalf("The Platform9 controller is not healthy")
+	}
+	fmt.Println("\n" + color.Green("✓ ") + "The Platform9 controller is healthy")
+
+	zap.S().Debug("==========Finished running check-du==========")
+}
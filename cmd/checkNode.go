@@ -4,15 +4,20 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/platform9/pf9ctl/pkg/client"
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
 	"github.com/platform9/pf9ctl/pkg/log"
 	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/output"
+	"github.com/platform9/pf9ctl/pkg/platform"
 	"github.com/platform9/pf9ctl/pkg/pmk"
 	"github.com/platform9/pf9ctl/pkg/supportBundle"
 	"github.com/platform9/pf9ctl/pkg/util"
@@ -21,13 +26,25 @@ import (
 )
 
 var (
-	nc objects.NodeConfig
+	nc         objects.NodeConfig
+	reportFile string
+	// listChecks lists the checks instead of running them, onlyCheckIDs and
+	// excludeCheckIDs select the checks which run
+	listChecks      bool
+	onlyCheckIDs    []string
+	excludeCheckIDs []string
+	// checkPolicyFile changes the severities of the checks
+	checkPolicyFile string
 
 	checkNodeCmd = &cobra.Command{
 		Use:   "check-node",
 		Short: "Checks prerequisites on a node to use with PMK",
 		Long: `Check if a node satisfies prerequisites to be ready to be added to a Kubernetes cluster. Read more
 	at https://platform9.com/blog/support/managed-container-cloud-requirements-checklist/`,
+		Example: `pf9ctl check-node --list-checks -o json
+pf9ctl check-node -i 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --exclude-checks time-sync,firewalld
+pf9ctl check-node -i 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --checks swap,cgroup
+pf9ctl check-node -i 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --check-policy lab-policy.yaml`,
 		Run: checkNodeRun,
 	}
 )
@@ -41,6 +58,21 @@ func init() {
 	checkNodeCmd.Flags().StringVar(&nc.MFA, "mfa", "", "MFA token")
 	checkNodeCmd.Flags().StringVarP(&nc.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
 	checkNodeCmd.Flags().BoolVarP(&nc.RemoveExistingPkgs, "remove-existing-pkgs", "r", false, "Will remove previous installation if found (default false)")
+	checkNodeCmd.Flags().StringVar(&reportFile, "report", "", "Write a JSON report of the checks to this file, to be approved with approve-report before running prep-node --verify-report")
+	checkNodeCmd.Flags().BoolVar(&util.FixHostname, "fix-hostname", false, "Add the hostname of the node to /etc/hosts when it doesn't resolve")
+	checkNodeCmd.Flags().StringVar(&util.NodeRole, "role", "", "Role the node is checked for, master or worker (default checks for any role)")
+	checkNodeCmd.Flags().DurationVar(&pmk.MaxClockSkew, "max-clock-skew", pmk.MaxClockSkew, "Largest difference allowed between the clock of the node and the one of the DU")
+	checkNodeCmd.Flags().StringVar(&pmk.NTPServer, "ntp-server", "", "NTP server to compare the clock of the node against instead of the DU, e.g: pool.ntp.org")
+	checkNodeCmd.Flags().BoolVar(&pmk.ReuseRuntime, "reuse-runtime", false, "Check the docker or containerd installed on the node can be kept for PMK, as prep-node --reuse-runtime does")
+	checkNodeCmd.Flags().StringVar(&pmk.KubernetesVersion, "kubernetes-version", "", "Kubernetes version the cgroup version of the node is checked for, e.g: 1.21 (default the newest version of the DU)")
+
+	checkNodeCmd.Flags().BoolVar(&listChecks, "list-checks", false, "List the checks with their IDs, severities and remediations instead of running them")
+	checkNodeCmd.Flags().StringSliceVar(&onlyCheckIDs, "checks", nil, "IDs of the only checks to run, see --list-checks (default all the checks)")
+	checkNodeCmd.Flags().StringSliceVar(&excludeCheckIDs, "exclude-checks", nil, "IDs of the checks not to run, see --list-checks")
+	addCheckPolicyFlag(checkNodeCmd)
+	checkNodeCmd.RegisterFlagCompletionFunc("checks", completeCheckIDs)
+	checkNodeCmd.RegisterFlagCompletionFunc("exclude-checks", completeCheckIDs)
+	addOutputFlag(checkNodeCmd)
 
 	//checkNodeCmd.Flags().BoolVarP(&floatingIP, "floating-ip", "f", false, "") //Unsupported in first version.
 
@@ -49,10 +81,33 @@ func init() {
 
 func checkNodeRun(cmd *cobra.Command, args []string) {
 	zap.S().Debug("==========Running check-node==========")
+	format := parseOutput()
+	if listChecks {
+		listChecksRun(format)
+		return
+	}
+	if !format.Default() {
+		zap.S().Fatalf("--output only applies to --list-checks")
+	}
+	if err := platform.SelectChecks(onlyCheckIDs, excludeCheckIDs); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	loadCheckPolicy()
+	if nc.RemoveExistingPkgs || util.FixHostname {
+		requireWritable("check-node --remove-existing-pkgs or --fix-hostname")
+	}
 
 	detachedMode := cmd.Flags().Changed("no-prompt")
+	nc.NoPrompt = detachedMode
+	if err := cmdexec.CheckLocal(nc); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
 	isRemote := cmdexec.CheckRemote(nc)
 
+	if err := util.ValidateNodeRole(util.NodeRole); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+
 	if isRemote {
 		if !config.ValidateNodeConfig(&nc, !detachedMode) {
 			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
@@ -70,6 +125,10 @@ func checkNodeRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
 	}
+	// A read-only config only checks the node
+	if client.ReadOnly {
+		nc.RemoveExistingPkgs = false
+	}
 
 	fmt.Println(color.Green("✓ ") + "Loaded Config Successfully")
 	zap.S().Debug("Loaded Config Successfully")
@@ -110,8 +169,20 @@ func checkNodeRun(cmd *cobra.Command, args []string) {
 			zap.S().Fatal("Failed executing commands on remote machine with sudo: ", err.Error())
 		}
 	}
+	resolveKubernetesVersion(c, auth)
 
-	result, err := pmk.CheckNode(*cfg, c, auth, nc)
+	var result pmk.CheckNodeResult
+	if reportFile != "" {
+		var report *pmk.PreflightReport
+		result, report, err = pmk.CheckNodeWithReport(*cfg, c, auth, nc)
+		if report != nil {
+			if err := writeReport(report, reportFile); err != nil {
+				zap.S().Fatalf("Unable to write the preflight report: %s", err.Error())
+			}
+		}
+	} else {
+		result, err = pmk.CheckNode(*cfg, c, auth, nc)
+	}
 	if err != nil {
 		// Uploads pf9cli log bundle if checknode fails
 		errbundle := supportBundle.SupportBundleUpload(*cfg, c, isRemote)
@@ -131,3 +202,84 @@ func checkNodeRun(cmd *cobra.Command, args []string) {
 	}
 	zap.S().Debug("==========Finished running check-node==========")
 }
+
+func addCheckPolicyFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&checkPolicyFile, "check-policy", "", "YAML file of a CheckPolicy changing the severities of the checks by ID, e.g: swap optional in a lab, echoed into the --report")
+}
+
+// loadCheckPolicy reads the policy of --check-policy the checks are run with
+func loadCheckPolicy() {
+	if checkPolicyFile == "" {
+		return
+	}
+	policy, err := pmk.ReadCheckPolicy(checkPolicyFile)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	pmk.ActiveCheckPolicy = policy
+}
+
+// listChecksRun prints the checks check-node runs, for the tools picking the
+// checks to run by their ID
+func listChecksRun(format output.Format) {
+	checks := platform.Catalog()
+	if printOutput(format, map[string][]platform.CheckInfo{"items": checks}) {
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tSEVERITY\tREMEDIABLE\tDESCRIPTION")
+	for _, check := range checks {
+		remediable := "no"
+		if check.Remediable {
+			remediable = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", check.ID, check.Severity, remediable, check.Description)
+	}
+	w.Flush()
+}
+
+// completeCheckIDs completes the IDs of the checks
+func completeCheckIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var ids []string
+	for _, check := range platform.Catalog() {
+		ids = append(ids, check.ID)
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
+
+// writeReport writes the report to file, to be approved with approve-report
+func writeReport(report *pmk.PreflightReport, file string) error {
+	if err := pmk.WriteReport(report, file); err != nil {
+		return err
+	}
+	fmt.Printf(color.Green("✓ ")+"Preflight report written to %s, approve it with 'pf9ctl approve-report %s'\n", file, file)
+	return nil
+}
+
+// resolveKubernetesVersion sets the Kubernetes version the cgroup version of
+// the nodes is checked for to the newest the DU supports, unless it is given
+// with --kubernetes-version
+func resolveKubernetesVersion(c client.Client, auth keystone.KeystoneAuth) {
+	if pmk.KubernetesVersion != "" {
+		version, err := pmk.NormalizeKubernetesVersion(pmk.KubernetesVersion)
+		if err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+		pmk.KubernetesVersion = version
+		return
+	}
+	// The checks which depend on the version only run the checks of every
+	// version without it
+	versions, err := c.Qbert.GetPMKVersions(auth.Token, auth.ProjectID)
+	if err != nil {
+		fmt.Println(color.Yellow("! ") + "Unable to get the Kubernetes versions of the DU, the cgroup check doesn't know the version of the node")
+		zap.S().Debugf("Unable to get the pmk versions: %s", err.Error())
+		return
+	}
+	var roles []string
+	for _, role := range versions.Roles {
+		roles = append(roles, role.RoleVersion)
+	}
+	pmk.KubernetesVersion = pmk.NewestKubernetesVersion(roles)
+	zap.S().Debugf("Checking the nodes for Kubernetes %q, the newest version of the DU", pmk.KubernetesVersion)
+}
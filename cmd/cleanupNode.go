@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var cleanupNodeCmd = &cobra.Command{
+	Use:   "cleanup-node",
+	Short: "Removes the leftovers of failed preps from nodes",
+	Long: `Removes what previous failed or interrupted prep-node runs left on the nodes: the installer
+	and its extracted pf9-install-* directories and the agent_install log in the work directory
+	($HOME/pf9 unless set with --work-dir or the work_dir setting), the pf9-install-* directories and
+	agent_install logs in /tmp, and the files pf9ctl uploaded to the home of the user more than an
+	hour ago. The installed packages and the pf9ctl logs are left alone. Cleans the local node unless
+	--ip is given, and refuses to clean a node while the installer runs on it.`,
+	Example: `pf9ctl cleanup-node
+	pf9ctl cleanup-node --ip 10.0.0.1 --ip 10.0.0.2 -u ubuntu -s ~/.ssh/id_rsa --dry-run`,
+	Args: cobra.NoArgs,
+	Run:  cleanupNodeRun,
+}
+
+var (
+	cleanupNodeConfig  objects.NodeConfig
+	cleanupNodeWorkDir string
+	cleanupNodeDryRun  bool
+)
+
+func init() {
+	cleanupNodeCmd.Flags().StringVarP(&cleanupNodeConfig.User, "user", "u", "", "ssh username for the nodes")
+	cleanupNodeCmd.Flags().StringVarP(&cleanupNodeConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	cleanupNodeCmd.Flags().StringVarP(&cleanupNodeConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	cleanupNodeCmd.Flags().StringSliceVarP(&cleanupNodeConfig.IPs, "ip", "i", []string{}, "IP address of the nodes")
+	cleanupNodeCmd.Flags().StringVarP(&cleanupNodeConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	cleanupNodeCmd.Flags().StringVar(&cleanupNodeWorkDir, "work-dir", "", "directory of the nodes the installer was downloaded to (default the work_dir setting or $HOME/pf9)")
+	cleanupNodeCmd.Flags().BoolVar(&cleanupNodeDryRun, "dry-run", false, "only list the leftovers, without removing them")
+	cleanupNodeCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
+	rootCmd.AddCommand(cleanupNodeCmd)
+}
+
+func cleanupNodeRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running cleanup-node==========")
+	if !cleanupNodeDryRun {
+		requireWritable("cleanup-node")
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if err := cmdexec.CheckLocal(cleanupNodeConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	isRemote := cmdexec.CheckRemote(cleanupNodeConfig)
+	if isRemote {
+		if !config.ValidateNodeConfig(&cleanupNodeConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	workDir := cleanupNodeWorkDir
+	if workDir == "" {
+		// The nodes were prepared in the work directory of the config, if any
+		if stored, err := config.ReadStoredConfig(util.Pf9DBLoc); err == nil {
+			workDir = stored.WorkDir
+		}
+	}
+	if workDir != "" {
+		if err := pmk.ValidateWorkDir(workDir); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+	}
+
+	ips := cleanupNodeConfig.IPs
+	if len(ips) == 0 {
+		ips = []string{"localhost"}
+	}
+
+	failed := 0
+	for _, ip := range ips {
+		if err := cleanupNode(ip, workDir, detachedMode, isRemote); err != nil {
+			failed++
+			fmt.Println(color.Red("x ") + fmt.Sprintf("Unable to clean node %s: %s", ip, err))
+		}
+	}
+	if failed > 0 {
+		zap.S().Fatalf("Unable to clean %d node(s)", failed)
+	}
+
+	zap.S().Debug("==========Finished running cleanup-node==========")
+}
+
+// cleanupNode lists and, unless --dry-run is set, removes the prep artifacts
+// of the node at ip
+func cleanupNode(ip, workDir string, detachedMode, isRemote bool) error {
+	nodeCfg := cleanupNodeConfig
+	nodeCfg.IPs = []string{ip}
+	executor, err := cmdexec.GetExecutor("", nodeCfg)
+	if err != nil {
+		return err
+	}
+	if isRemote {
+		if err := SudoPasswordCheck(executor, detachedMode, nodeCfg.SudoPassword); err != nil {
+			return err
+		}
+	}
+
+	artifacts, err := pmk.FindPrepArtifacts(executor, workDir)
+	if err != nil {
+		return err
+	}
+	if len(artifacts) == 0 {
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("Node %s: no leftover of previous preps", ip))
+		return nil
+	}
+
+	fmt.Printf("Node %s:\n", ip)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "PATH\tKIND\tSIZE")
+	var total int64
+	for _, artifact := range artifacts {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", artifact.Path, artifact.Kind, formatBytes(artifact.SizeKB*1024))
+		total += artifact.SizeKB
+	}
+	w.Flush()
+
+	if cleanupNodeDryRun {
+		fmt.Println(color.Yellow("! ") + fmt.Sprintf("Node %s: %d leftover(s) using %s, not removed with --dry-run", ip, len(artifacts), formatBytes(total*1024)))
+		return nil
+	}
+	if pmk.PrepRunning(executor) {
+		return fmt.Errorf("the installer is running, retry once prep-node is done")
+	}
+	if err := pmk.RemovePrepArtifacts(executor, artifacts); err != nil {
+		return err
+	}
+	fmt.Println(color.Green("✓ ") + fmt.Sprintf("Node %s: removed %d leftover(s), freed %s", ip, len(artifacts), formatBytes(total*1024)))
+	return nil
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// loadClient loads the config, prompting for it unless --no-prompt is set, and
+// returns a client of the DU authenticated with it. The caller closes the
+// Segment client.
+func loadClient(cmd *cobra.Command, mfa string) (*objects.Config, client.Client, keystone.KeystoneAuth) {
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: mfa}
+	var err error
+	if cmd.Flags().Changed("no-prompt") {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		c.Segment.Close()
+		zap.S().Fatalf("Unable to obtain keystone credentials: %s", err.Error())
+	}
+	return cfg, c, auth
+}
+
+// requireWritable exits when the config is read-only, before operation
+// changes the nodes
+func requireWritable(operation string) {
+	if err := config.CheckWritable(util.Pf9DBLoc, operation); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+}
+
+// requireRole exits when the user lacks the role needed by operation, before
+// the operation changes anything
+func requireRole(auth keystone.KeystoneAuth, operation string) {
+	if err := keystone.CheckOperationRole(auth, operation); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+}
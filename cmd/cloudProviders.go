@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/platform9/pf9ctl/pkg/color"
@@ -66,7 +65,7 @@ func checkGoogleProviderRun(cmd *cobra.Command, args []string) {
 		flagsNotSet := checkFlags(cmd)
 		if len(flagsNotSet) > 0 {
 			fmt.Printf(color.Red("x ")+"Missing required flags: %v\n", strings.Join(flagsNotSet, ", "))
-			os.Exit(1)
+			exit(1)
 		}
 	} else {
 		err = config.GetConfigRecursive("google.json", &cfg, objects.NodeConfig{})
@@ -76,7 +75,7 @@ func checkGoogleProviderRun(cmd *cobra.Command, args []string) {
 	}
 
 	if !pmk.CheckGoogleProvider(cfg.GooglePath, cfg.GoogleProjectName, cfg.GoogleServiceEmail) {
-		os.Exit(1)
+		exit(1)
 	}
 
 }
@@ -87,7 +86,7 @@ func checkAmazonProviderRun(cmd *cobra.Command, args []string) {
 		flagsNotSet := checkFlags(cmd)
 		if len(flagsNotSet) > 0 {
 			fmt.Printf(color.Red("x ")+"Missing required flags: %v\n", strings.Join(flagsNotSet, ", "))
-			os.Exit(1)
+			exit(1)
 		}
 	} else {
 		err = config.GetConfigRecursive("amazon.json", &cfg, objects.NodeConfig{})
@@ -96,7 +95,7 @@ func checkAmazonProviderRun(cmd *cobra.Command, args []string) {
 		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
 	}
 	if !pmk.CheckAmazonPovider(cfg.AwsIamUsername, cfg.AwsAccessKey, cfg.AwsSecretKey, cfg.AwsRegion) {
-		os.Exit(1)
+		exit(1)
 	}
 }
 
@@ -107,7 +106,7 @@ func checkAzureProviderRun(cmd *cobra.Command, args []string) {
 		flagsNotSet := checkFlags(cmd)
 		if len(flagsNotSet) > 0 {
 			fmt.Printf(color.Red("x ")+"Missing required flags: %v\n", strings.Join(flagsNotSet, ", "))
-			os.Exit(1)
+			exit(1)
 		}
 	} else {
 		err = config.GetConfigRecursive("azure.json", &cfg, objects.NodeConfig{})
@@ -117,7 +116,7 @@ func checkAzureProviderRun(cmd *cobra.Command, args []string) {
 	}
 
 	if !pmk.CheckAzureProvider(cfg.AzureTenant, cfg.AzureClient, cfg.AzureSubscription, cfg.AzureSecret) {
-		os.Exit(1)
+		exit(1)
 	}
 
 }
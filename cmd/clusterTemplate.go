@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var clusterTemplateCmd = &cobra.Command{
+	Use:   "cluster-template",
+	Short: "Manages cluster templates",
+	Long: `Cluster templates hold the configuration of a cluster, so the same cluster can be
+	created in other regions or tenants with 'pf9ctl create-cluster --from-template'.`,
+}
+
+var clusterTemplateExportCmd = &cobra.Command{
+	Use:     "export <cluster>",
+	Short:   "Exports the configuration of a cluster as a template",
+	Long:    "Exports the qbert configuration of an existing cluster as a YAML template. Review the site specific settings, like the virtual IP, before using it elsewhere.",
+	Example: "pf9ctl cluster-template export my-cluster -o tmpl.yaml",
+	Args:    cobra.ExactArgs(1),
+	Run:     clusterTemplateExportRun,
+}
+
+var createClusterCmd = &cobra.Command{
+	Use:     "create-cluster <name>",
+	Short:   "Creates a cluster from a cluster template",
+	Long:    "Creates a cluster without nodes from a template exported with 'pf9ctl cluster-template export', attach nodes to it with attach-node.",
+	Example: "pf9ctl create-cluster my-cluster --from-template tmpl.yaml",
+	Args:    cobra.ExactArgs(1),
+	Run:     createClusterRun,
+}
+
+var (
+	clusterTemplateOutput string
+	clusterTemplateFile   string
+	clusterTemplateMFA    string
+)
+
+func init() {
+	clusterTemplateExportCmd.Flags().StringVarP(&clusterTemplateOutput, "output", "o", "", "File to write the template to (default stdout)")
+	clusterTemplateExportCmd.Flags().StringVar(&clusterTemplateMFA, "mfa", "", "MFA token")
+	clusterTemplateExportCmd.ValidArgsFunction = completeClusterNames
+	clusterTemplateCmd.AddCommand(clusterTemplateExportCmd)
+	rootCmd.AddCommand(clusterTemplateCmd)
+
+	createClusterCmd.Flags().StringVar(&clusterTemplateFile, "from-template", "", "Cluster template to create the cluster from")
+	createClusterCmd.Flags().StringVar(&clusterTemplateMFA, "mfa", "", "MFA token")
+	createClusterCmd.MarkFlagRequired("from-template")
+	rootCmd.AddCommand(createClusterCmd)
+}
+
+func clusterTemplateExportRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running cluster-template export==========")
+
+	_, c, auth := loadClient(cmd, clusterTemplateMFA)
+	defer c.Segment.Close()
+
+	clusterName := args[0]
+	exists, clusterUuid, _, err := c.Qbert.CheckClusterExists(clusterName, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to check the cluster: %s", err.Error())
+	}
+	if !exists {
+		zap.S().Fatalf("Cluster %s not found", clusterName)
+	}
+
+	spec, err := c.Qbert.GetClusterSpec(clusterUuid, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to get cluster %s: %s", clusterName, err.Error())
+	}
+	data, err := pmk.MarshalClusterTemplate(pmk.ClusterTemplateFromSpec(spec))
+	if err != nil {
+		zap.S().Fatalf("Unable to create the cluster template: %s", err.Error())
+	}
+
+	if clusterTemplateOutput == "" {
+		os.Stdout.Write(data)
+	} else {
+		if err := ioutil.WriteFile(clusterTemplateOutput, data, 0644); err != nil {
+			zap.S().Fatalf("Unable to write the cluster template: %s", err.Error())
+		}
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("Template of cluster %s written to %s", clusterName, clusterTemplateOutput))
+	}
+
+	zap.S().Debug("==========Finished running cluster-template export==========")
+}
+
+func createClusterRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running create-cluster==========")
+
+	template, err := pmk.ReadClusterTemplate(clusterTemplateFile)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+
+	_, c, auth := loadClient(cmd, clusterTemplateMFA)
+	defer c.Segment.Close()
+	requireRole(auth, "create-cluster")
+
+	// The pmk version of the template may not be available in this region
+	pmkVersions, err := c.Qbert.GetPMKVersions(auth.Token, auth.ProjectID)
+	if err != nil {
+		zap.S().Fatalf("Unable to get the pmk versions: %s", err.Error())
+	}
+	supported := false
+	var versions []string
+	for _, role := range pmkVersions.Roles {
+		versions = append(versions, role.RoleVersion)
+		supported = supported || role.RoleVersion == template.PmkVersion
+	}
+	if !supported {
+		zap.S().Fatalf("pmk version %s of the template is not supported, supported versions are: %s",
+			template.PmkVersion, strings.Join(versions, ", "))
+	}
+
+	// The monitoring and pmk version settings are read by qbert when it
+	// builds the payload
+	qbert.IsPMKversionDefined = true
+	qbert.SplitPMKversion = strings.Split(template.PmkVersion, "-")
+	qbert.IsMonitoringDisabled = !template.Monitoring
+
+	clusterUuid, err := c.Qbert.CreateCluster(template.CreateRequest(args[0]), auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to create cluster %s: %s", args[0], err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + fmt.Sprintf("Cluster %s created from template %s (%s)", args[0], clusterTemplateFile, clusterUuid))
+	fmt.Printf("Attach nodes with: pf9ctl attach-node %s -m <master-ip> -w <worker-ip>\n", args[0])
+
+	zap.S().Debug("==========Finished running create-cluster==========")
+}
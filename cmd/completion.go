@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish]",
+	Short: "Generates the shell completion script",
+	Long: `Generates the completion script of the shell. Besides the commands and flags, the names of
+	the clusters and the IPs of the nodes are completed from the DU of the config, they are cached
+	for a few minutes so that completing them again doesn't query the DU.
+
+	bash: source <(pf9ctl completion bash)
+	zsh:  source <(pf9ctl completion zsh)
+	fish: pf9ctl completion fish | source`,
+	Example:   "pf9ctl completion bash > /etc/bash_completion.d/pf9ctl",
+	Args:      cobra.ExactValidArgs(1),
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	Run:       completionRun,
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
+
+func completionRun(cmd *cobra.Command, args []string) {
+	var err error
+	switch args[0] {
+	case "bash":
+		err = rootCmd.GenBashCompletion(os.Stdout)
+	case "zsh":
+		_, err = fmt.Fprint(os.Stdout, zshCompletion)
+	case "fish":
+		err = rootCmd.GenFishCompletion(os.Stdout, true)
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to generate the %s completion: %s", args[0], err.Error())
+	}
+}
+
+// zshCompletion completes through the hidden __complete command of cobra,
+// like the bash and fish scripts it generates do
+const zshCompletion = `#compdef pf9ctl
+
+_pf9ctl() {
+    local out directive
+    local -a lines completions
+
+    out=$(pf9ctl __completeNoDesc "${(@)words[2,CURRENT-1]}" "${words[CURRENT]}" 2>/dev/null) || return
+    lines=("${(@f)out}")
+    directive=${lines[-1]#:}
+    completions=("${(@)lines[1,-2]}")
+
+    # 1: error, 2: no space after the completion, 4: no file completion
+    (( directive & 1 )) && return
+    if (( ${#completions} == 0 )); then
+        (( directive & 4 )) || _files
+        return
+    fi
+    if (( directive & 2 )); then
+        compadd -S '' -a completions
+    else
+        compadd -a completions
+    fi
+}
+
+compdef _pf9ctl pf9ctl
+`
+
+// isCompletion tells whether pf9ctl runs to generate the completion script
+// or to complete the command line of the shell
+func isCompletion() bool {
+	if len(os.Args) < 2 {
+		return false
+	}
+	switch os.Args[1] {
+	case completionCmd.Name(), cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return true
+	}
+	return false
+}
+
+// completeClusterNames completes the first argument with the names of the clusters
+func completeClusterNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeClusterFlag(cmd, args, toComplete)
+}
+
+// completeClusterFlag completes a flag with the names of the clusters
+func completeClusterFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	names, ok := completionNames()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return names.ClusterNames(toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeNodeIPs completes a flag with the IPs of the hosts of the DU
+func completeNodeIPs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	names, ok := completionNames()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return names.AllHostIPs(toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeNodeIPsIn completes a flag with the IPs of the hosts which are part
+// of a cluster when attached is set, and of the others otherwise
+func completeNodeIPsIn(attached bool) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		names, ok := completionNames()
+		if !ok {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return names.HostIPs(toComplete, attached), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completionNames returns the names of the clusters and hosts of the DU of
+// the config. The completion runs in the background of the shell, so it
+// neither prompts nor exits, it just completes nothing on errors.
+func completionNames() (pmk.CompletionNames, bool) {
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false}
+	if err := config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{}); err != nil {
+		zap.S().Debugf("Unable to load the config for completion: %s", err.Error())
+		return pmk.CompletionNames{}, false
+	}
+	if names, ok := pmk.CachedCompletionNames(cfg.Fqdn, cfg.Tenant); ok {
+		return names, true
+	}
+
+	executor, err := cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{})
+	if err != nil {
+		zap.S().Debugf("Unable to create executor for completion: %s", err.Error())
+		return pmk.CompletionNames{}, false
+	}
+	c, err := client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false)
+	if err != nil {
+		zap.S().Debugf("Unable to create client for completion: %s", err.Error())
+		return pmk.CompletionNames{}, false
+	}
+	defer c.Segment.Close()
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Debugf("Unable to obtain keystone credentials for completion: %s", err.Error())
+		return pmk.CompletionNames{}, false
+	}
+	names, err := pmk.FetchCompletionNames(c, auth, cfg.Fqdn, cfg.Tenant)
+	if err != nil {
+		zap.S().Debugf("%s", err.Error())
+		return pmk.CompletionNames{}, false
+	}
+	return names, true
+}
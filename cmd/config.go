@@ -5,14 +5,22 @@ package cmd
 import (
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"text/tabwriter"
 
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/config"
 	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/socks"
+	"github.com/platform9/pf9ctl/pkg/ssh"
+	"github.com/platform9/pf9ctl/pkg/statefile"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 // configCmdCreate represents the config command
@@ -24,18 +32,30 @@ var (
 	}
 
 	configCmdSet = &cobra.Command{
-		Use:   "set",
-		Short: "Create a new config",
-		Long:  `Create a new config that can be used to query Platform9 controller`,
-		Run:   configCmdCreateRun,
+		Use:   "set [key=value...]",
+		Short: "Create a new config, or change some of its settings",
+		Long: `Create a new config that can be used to query Platform9 controller.
+	Given settings as key=value, only changes those settings of the stored config, without prompts
+	and without validating the config against the controller. 'pf9ctl config view' lists the settings.`,
+		Example: `pf9ctl config set fqdn=https://example.platform9.io region=RegionOne
+  pf9ctl config set --account-url https://example.platform9.io --username admin@example.com --no-prompt`,
+		Run:               configCmdCreateRun,
+		ValidArgsFunction: completeSettingAssignments,
 	}
 
 	configCmdGet = &cobra.Command{
-		Use:   "get",
+		Use:   "get [key...]",
 		Short: "Print stored config",
-		Long:  `Print details of the stored config`,
+		Long:  `Print details of the stored config, or the values of the given settings one per line`,
+		Example: `pf9ctl config get
+  pf9ctl config get fqdn region`,
+		ValidArgsFunction: completeSettingKeys,
 		Run: func(cmd *cobra.Command, args []string) {
 			zap.S().Debug("==========Running get config==========")
+			if len(args) > 0 {
+				configGetSettings(args)
+				return
+			}
 			_, err := os.Stat(util.Pf9DBLoc)
 			if err != nil || os.IsNotExist(err) {
 				zap.S().Fatal("Could not load config: ", err)
@@ -61,13 +81,51 @@ var (
 		},
 	}
 
-	cfg objects.Config
+	configCmdUnset = &cobra.Command{
+		Use:               "unset <key>...",
+		Short:             "Reset settings of the stored config to their default",
+		Example:           "pf9ctl config unset proxy_url download_limit",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeSettingKeys,
+		Run:               configCmdUnsetRun,
+	}
+
+	configCmdView = &cobra.Command{
+		Use:   "view",
+		Short: "List the settings of the stored config",
+		Long: `List the settings of the stored config which are set. With --redact the secrets
+	and the credentials of the proxy are replaced, so the output can be shared.`,
+		Args: cobra.NoArgs,
+		Run:  configCmdViewRun,
+	}
+
+	configCmdRotatePassword = &cobra.Command{
+		Use:   "rotate-password",
+		Short: "Stores the new password of the account once it has been changed",
+		Long: `Prompts for the new password of the account of the stored config, or reads it from stdin
+	with --password-stdin, and stores it once Keystone accepted it. The stored config is left unchanged
+	when Keystone rejects the new password.`,
+		Example: `pf9ctl config rotate-password
+  vault read -field=password secret/pf9 | pf9ctl config rotate-password --password-stdin`,
+		Args: cobra.NoArgs,
+		Run:  configCmdRotatePasswordRun,
+	}
+
+	cfg          objects.Config
+	configRedact bool
+	configMFA    string
 )
 
 func init() {
 	rootCmd.AddCommand(configCmdCreate)
 	configCmdCreate.AddCommand(configCmdGet)
 	configCmdCreate.AddCommand(configCmdSet)
+	configCmdCreate.AddCommand(configCmdUnset)
+	configCmdCreate.AddCommand(configCmdView)
+	configCmdCreate.AddCommand(configCmdRotatePassword)
+
+	configCmdView.Flags().BoolVar(&configRedact, "redact", false, "replace the secrets and the credentials of the proxy")
+	configCmdRotatePassword.Flags().StringVar(&configMFA, "mfa", "", "MFA token")
 
 	configCmdSet.Flags().StringVarP(&cfg.Fqdn, "account-url", "u", "", "sets account-url")
 	configCmdSet.Flags().StringVarP(&cfg.Username, "username", "e", "", "sets username")
@@ -76,15 +134,53 @@ func init() {
 	configCmdSet.Flags().StringVarP(&cfg.Region, "region", "r", "", "sets region")
 	configCmdSet.Flags().StringVarP(&cfg.Tenant, "tenant", "t", "", "sets tenant")
 	configCmdSet.Flags().StringVar(&cfg.MfaToken, "mfa", "", "set MFA token")
+	configCmdSet.Flags().StringVar(&cfg.DownloadLimit, "download-limit", "", "sets the maximum rate the nodes download the installer at, e.g: 10MB/s (default unlimited)")
+	configCmdSet.Flags().StringVar(&cfg.WorkDir, "work-dir", "", "sets the directory of the nodes the installer is downloaded to (default $HOME/pf9)")
+	configCmdSet.Flags().StringVar(&cfg.PhaseBudget, "phase-budget", "", "sets how many times their typical duration the phases of prep-node run before a warning, 0 disables the warnings (default 3)")
+	configCmdSet.Flags().StringVar(&cfg.ProtectedHosts, "protected-hosts", "", "sets the IPs, CIDRs and hostnames, comma separated, prep-node and decommission-node refuse to run on, e.g: 10.0.0.0/24,infra-*")
+	configCmdSet.Flags().StringVar(&cfg.ProtectedMarkers, "protected-markers", "", "sets the files, comma separated, whose presence on a host makes prep-node and decommission-node refuse to run on it (default "+pmk.DefaultProtectedMarker+" only)")
+	configCmdSet.Flags().BoolVar(&cfg.ReadOnly, "read-only", false, "only allows the commands which change neither the DU nor the nodes, e.g: for operators who should only inspect them. Once stored, it is only turned off by removing the config")
 }
 
 func configCmdCreateRun(cmd *cobra.Command, args []string) {
 	zap.S().Debug("==========Running set config==========")
 
+	if len(args) > 0 {
+		configSetSettings(cmd, args)
+		return
+	}
+
 	var err error
 	if err = config.SetProxy(cfg.ProxyURL); err != nil {
 		zap.S().Fatal(color.Red("x "), err)
 	}
+	if cfg.WorkDir != "" {
+		if err = pmk.ValidateWorkDir(cfg.WorkDir); err != nil {
+			zap.S().Fatal(color.Red("x "), err)
+		}
+	}
+	if cfg.DownloadLimit != "" {
+		if err = pmk.ValidateDownloadLimit(cfg.DownloadLimit); err != nil {
+			zap.S().Fatal(color.Red("x "), err)
+		}
+	}
+	if cfg.PhaseBudget != "" {
+		if _, err = pmk.ParsePhaseBudget(cfg.PhaseBudget); err != nil {
+			zap.S().Fatal(color.Red("x "), err)
+		}
+	}
+	if err = pmk.ValidateProtectedHosts(cfg.ProtectedHosts); err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+	if err = pmk.ValidateProtectedMarkers(cfg.ProtectedMarkers); err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+	if config.PasswordStdin && cfg.Password != "" {
+		zap.S().Fatal(color.Red("x "), "--password and --password-stdin are mutually exclusive")
+	}
+	if err = config.ApplySecrets(&cfg); err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
 
 	if cmd.Flags().Changed("no-prompt") {
 		if err = config.ValidateUserCredentials(&cfg, objects.NodeConfig{}); err != nil {
@@ -103,3 +199,217 @@ func configCmdCreateRun(cmd *cobra.Command, args []string) {
 
 	zap.S().Debug("==========Finished running set config==========")
 }
+
+// configSetSettings changes the settings of the stored config given as
+// key=value, creating it when there is none
+func configSetSettings(cmd *cobra.Command, assignments []string) {
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if cmd.LocalNonPersistentFlags().Lookup(flag.Name) != nil {
+			zap.S().Fatal(color.Red("x "), "settings are either given as key=value or as flags")
+		}
+	})
+	lock := lockConfig()
+	defer lock.Release()
+	stored := storedConfig(true)
+	for _, assignment := range assignments {
+		key, value, err := config.ParseAssignment(assignment)
+		if err == nil {
+			err = validateSetting(key, value)
+		}
+		if err == nil {
+			err = config.SetSetting(&stored, key, value)
+		}
+		if err != nil {
+			zap.S().Fatal(color.Red("x "), err)
+		}
+	}
+	if err := config.StoreConfig(&stored, util.Pf9DBLoc); err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+	zap.S().Debug("==========Finished running set config==========")
+}
+
+// validateSetting validates the settings which are checked by the flags of
+// 'config set'
+func validateSetting(key, value string) error {
+	switch key {
+	case "fqdn", "username", "region", "tenant":
+		if value == "" {
+			return fmt.Errorf("%s can't be empty", key)
+		}
+	case "proxy_url":
+		if _, err := url.Parse(value); err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+	case "work_dir":
+		return pmk.ValidateWorkDir(value)
+	case "download_limit":
+		return pmk.ValidateDownloadLimit(value)
+	case "phase_budget":
+		_, err := pmk.ParsePhaseBudget(value)
+		return err
+	case "protected_hosts":
+		return pmk.ValidateProtectedHosts(value)
+	case "protected_markers":
+		return pmk.ValidateProtectedMarkers(value)
+	case "hostagent_version":
+		return pmk.ValidateHostagentVersion(value)
+	case "installer_args":
+		args, err := config.ParseList(value)
+		if err != nil {
+			return err
+		}
+		return pmk.ValidateInstallerArgs(args)
+	case "package_repo":
+		return pmk.ValidatePackageRepo(value)
+	case "package_repo_key":
+		return pmk.ValidatePackageRepoKey(value)
+	case "report_approver_key":
+		_, err := pmk.ParseApproverKey(value)
+		return err
+	case "socks_proxy":
+		_, err := socks.Parse(value)
+		return err
+	case "host_key_policy":
+		return ssh.ValidateHostKeyPolicy(value)
+	}
+	return nil
+}
+
+// configGetSettings prints the values of the settings of the stored config
+func configGetSettings(keys []string) {
+	stored := storedConfig(false)
+	for _, key := range keys {
+		value, err := config.GetSetting(&stored, key)
+		if err != nil {
+			zap.S().Fatal(color.Red("x "), err)
+		}
+		fmt.Println(value)
+	}
+	zap.S().Debug("==========Finished running get config==========")
+}
+
+func configCmdUnsetRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running unset config==========")
+
+	lock := lockConfig()
+	defer lock.Release()
+	stored := storedConfig(false)
+	for _, key := range args {
+		if err := config.UnsetSetting(&stored, key); err != nil {
+			zap.S().Fatal(color.Red("x "), err)
+		}
+	}
+	if err := config.StoreConfig(&stored, util.Pf9DBLoc); err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+
+	zap.S().Debug("==========Finished running unset config==========")
+}
+
+func configCmdViewRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running view config==========")
+
+	stored := storedConfig(false)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE")
+	for _, setting := range config.Settings(&stored, configRedact) {
+		fmt.Fprintf(w, "%s\t%s\n", setting[0], setting[1])
+	}
+	w.Flush()
+
+	zap.S().Debug("==========Finished running view config==========")
+}
+
+// storedConfig returns the stored config, an empty one when there is none
+// and orEmpty is set
+func storedConfig(orEmpty bool) objects.Config {
+	stored, err := config.ReadStoredConfig(util.Pf9DBLoc)
+	if err == config.NO_CONFIG && orEmpty {
+		return objects.Config{}
+	}
+	if err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+	return stored
+}
+
+// lockConfig locks the stored config until the command changing it is done,
+// so a pf9ctl run at the same time doesn't overwrite the change
+func lockConfig() *statefile.Lock {
+	lock, err := config.Lock(util.Pf9DBLoc)
+	if err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+	return lock
+}
+
+// completeSettingKeys completes the keys of the settings of the config
+func completeSettingKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return config.SettingKeys(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeSettingAssignments completes the keys of the settings of the config
+// followed by =
+func completeSettingAssignments(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var assignments []string
+	for _, key := range config.SettingKeys() {
+		assignments = append(assignments, key+"=")
+	}
+	return assignments, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+func configCmdRotatePasswordRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running config rotate-password==========")
+
+	lock := lockConfig()
+	defer lock.Release()
+	stored := storedConfig(false)
+	rotated := stored
+	rotated.Password = ""
+	rotated.MfaToken = configMFA
+	if err := config.ApplySecrets(&rotated); err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+	if rotated.Password == "" {
+		if cmd.Flags().Changed("no-prompt") {
+			zap.S().Fatal(color.Red("x "), "--password-stdin is required with --no-prompt")
+		}
+		rotated.Password = readNewPassword(stored.Username)
+	}
+	if rotated.Password == stored.Password {
+		zap.S().Fatal(color.Red("x "), "The new password is the stored one")
+	}
+
+	if err := config.ValidateUserCredentials(&rotated, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("%sThe new password couldn't be verified, the stored config is unchanged: %s", color.Red("x "), err)
+	}
+	if err := config.StoreConfig(&rotated, util.Pf9DBLoc); err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+
+	zap.S().Debug("==========Finished running config rotate-password==========")
+}
+
+// readNewPassword prompts twice for the new password of user
+func readNewPassword(user string) string {
+	fmt.Printf("New password of %s: ", user)
+	password, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		zap.S().Fatalf("Unable to read the password: %s", err.Error())
+	}
+	fmt.Printf("Confirm the new password: ")
+	confirmation, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		zap.S().Fatalf("Unable to read the password: %s", err.Error())
+	}
+	if len(password) == 0 {
+		zap.S().Fatal(color.Red("x "), "The password can't be empty")
+	}
+	if string(password) != string(confirmation) {
+		zap.S().Fatal(color.Red("x "), "The passwords don't match")
+	}
+	return string(password)
+}
@@ -0,0 +1,192 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var (
+	configCmdExport = &cobra.Command{
+		Use:   "export",
+		Short: "Export the stored config as a portable profile bundle",
+		Long: `Export the settings of the stored config as a profile bundle, which 'pf9ctl config import'
+	sets on other machines, e.g. to hand ready-made DU profiles to operators. With --redact-secrets
+	the secrets and the credentials of the proxy are left out, the operators keep their own. With
+	--encrypt the settings are encrypted with a passphrase, prompted for or read from stdin.`,
+		Example: `pf9ctl config export --redact-secrets --exclude username -o du-profile.json
+  pf9ctl config export --encrypt -o du-profile.json`,
+		Args: cobra.NoArgs,
+		Run:  configCmdExportRun,
+	}
+
+	configCmdImport = &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import a profile bundle into the stored config",
+		Long: `Set the settings of a profile bundle exported with 'pf9ctl config export' on the stored config,
+	creating it when there is none. The other settings are kept, unless --replace is set. The secrets
+	left out of the bundle keep their stored value. The passphrase of an encrypted bundle is prompted
+	for or read from stdin.`,
+		Example: `pf9ctl config import du-profile.json
+  vault read -field=passphrase secret/pf9 | pf9ctl config import --passphrase-stdin du-profile.json`,
+		Args: cobra.ExactArgs(1),
+		Run:  configCmdImportRun,
+	}
+
+	bundleOutput          string
+	bundleRedactSecrets   bool
+	bundleExclude         []string
+	bundleEncrypt         bool
+	bundlePassphraseStdin bool
+	bundleReplace         bool
+)
+
+func init() {
+	configCmdCreate.AddCommand(configCmdExport)
+	configCmdCreate.AddCommand(configCmdImport)
+
+	configCmdExport.Flags().StringVarP(&bundleOutput, "output", "o", "", "file the bundle is written to (default stdout)")
+	configCmdExport.Flags().BoolVar(&bundleRedactSecrets, "redact-secrets", false, "leave the secrets and the credentials of the proxy out of the bundle")
+	configCmdExport.Flags().StringSliceVar(&bundleExclude, "exclude", nil, "settings left out of the bundle, e.g: username")
+	configCmdExport.Flags().BoolVar(&bundleEncrypt, "encrypt", false, "encrypt the settings of the bundle with a passphrase")
+	configCmdExport.Flags().BoolVar(&bundlePassphraseStdin, "passphrase-stdin", false, "read the passphrase of --encrypt from stdin")
+	configCmdExport.RegisterFlagCompletionFunc("exclude", completeSettingKeys)
+
+	configCmdImport.Flags().BoolVar(&bundlePassphraseStdin, "passphrase-stdin", false, "read the passphrase of an encrypted bundle from stdin")
+	configCmdImport.Flags().BoolVar(&bundleReplace, "replace", false, "reset the settings the bundle doesn't have, but the redacted secrets")
+}
+
+func configCmdExportRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running config export==========")
+
+	if bundlePassphraseStdin && !bundleEncrypt {
+		zap.S().Fatal(color.Red("x "), "--passphrase-stdin is only used with --encrypt")
+	}
+	stored := storedConfig(false)
+	bundle, err := config.NewProfileBundle(&stored, bundleRedactSecrets, bundleExclude)
+	if err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+	if bundleEncrypt {
+		if err := bundle.Encrypt(readBundlePassphrase(true)); err != nil {
+			zap.S().Fatal(color.Red("x "), err)
+		}
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+	data = append(data, '\n')
+
+	if bundleOutput == "" {
+		os.Stdout.Write(data)
+	} else {
+		if err := ioutil.WriteFile(bundleOutput, data, 0600); err != nil {
+			zap.S().Fatal(color.Red("x "), err)
+		}
+		fmt.Println(color.Green("✓ ") + "Profile exported to " + bundleOutput)
+	}
+	if !bundleRedactSecrets && !bundleEncrypt && len(config.Settings(&stored, false)) != len(config.Settings(&stored, true)) {
+		fmt.Fprintln(os.Stderr, color.Yellow("! ")+"The bundle has the secrets of the config in clear, "+
+			"use --redact-secrets or --encrypt before sharing it")
+	}
+
+	zap.S().Debug("==========Finished running config export==========")
+}
+
+func configCmdImportRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running config import==========")
+
+	bundle := loadProfileBundle(args[0])
+	lock := lockConfig()
+	defer lock.Release()
+	stored := storedConfig(true)
+	if err := bundle.Apply(&stored, bundleReplace); err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+	if err := config.StoreConfig(&stored, util.Pf9DBLoc); err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+
+	fmt.Printf("%sImported %s: %s\n", color.Green("✓ "), args[0], strings.Join(bundle.Keys(), ", "))
+	for _, key := range bundle.Redacted {
+		if value, _ := config.GetSetting(&stored, key); value == "" {
+			fmt.Printf("%s%s was left out of the bundle, set it with 'pf9ctl config set %s=<value>'\n", color.Yellow("! "), key, key)
+		}
+	}
+
+	zap.S().Debug("==========Finished running config import==========")
+}
+
+// loadProfileBundle reads the profile bundle of file, decrypting it with the
+// passphrase when it is encrypted, and validates its settings
+func loadProfileBundle(file string) *config.ProfileBundle {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+	passphrase := ""
+	if bundlePassphraseStdin {
+		passphrase = readBundlePassphrase(false)
+	}
+	bundle, err := config.ReadProfileBundle(data, passphrase)
+	if err == config.ErrPassphraseRequired {
+		bundle, err = config.ReadProfileBundle(data, readBundlePassphrase(false))
+	}
+	if err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+
+	for _, key := range bundle.Keys() {
+		if err := validateSetting(key, bundle.Settings[key]); err != nil {
+			zap.S().Fatalf("%sInvalid setting %s of the profile bundle: %s", color.Red("x "), key, err)
+		}
+	}
+	return bundle
+}
+
+// readBundlePassphrase reads the passphrase of a bundle from stdin with
+// --passphrase-stdin, or prompts for it, twice when confirm is set
+func readBundlePassphrase(confirm bool) string {
+	if bundlePassphraseStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			zap.S().Fatalf("Unable to read the passphrase from stdin: %s", err.Error())
+		}
+		return strings.TrimRight(line, "\r\n")
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		zap.S().Fatal(color.Red("x "), "No terminal to prompt for the passphrase of the profile bundle, give it with --passphrase-stdin")
+	}
+	fmt.Fprint(os.Stderr, "Passphrase of the profile bundle: ")
+	passphrase, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		zap.S().Fatalf("Unable to read the passphrase: %s", err.Error())
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm the passphrase: ")
+		confirmation, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			zap.S().Fatalf("Unable to read the passphrase: %s", err.Error())
+		}
+		if string(passphrase) != string(confirmation) {
+			zap.S().Fatal(color.Red("x "), "The passphrases don't match")
+		}
+	}
+	return string(passphrase)
+}
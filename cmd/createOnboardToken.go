@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var createOnboardTokenCmd = &cobra.Command{
+	Use:   "create-onboard-token",
+	Short: "Creates a short-lived token to onboard nodes with prep-node",
+	Long: `Creates a short-lived, restricted onboarding token which can be handed out to
+	prepare nodes with 'pf9ctl prep-node --onboard-token <token>' without sharing the
+	credentials of the account. The token is a keystone application credential which
+	expires after --ttl and can't be used to create other credentials.`,
+	Args: cobra.NoArgs,
+	Run:  createOnboardTokenRun,
+}
+
+var (
+	onboardTokenTTL   time.Duration
+	onboardTokenName  string
+	onboardTokenRoles []string
+	onboardTokenMFA   string
+)
+
+func init() {
+	createOnboardTokenCmd.Flags().DurationVar(&onboardTokenTTL, "ttl", 2*time.Hour, "Lifetime of the token")
+	createOnboardTokenCmd.Flags().StringVar(&onboardTokenName, "name", "", "Name of the token (default pf9ctl-onboard-<timestamp>)")
+	createOnboardTokenCmd.Flags().StringSliceVar(&onboardTokenRoles, "role", []string{}, "Limit the token to these roles of the account (default all the roles of the account)")
+	createOnboardTokenCmd.Flags().StringVar(&onboardTokenMFA, "mfa", "", "MFA token")
+	rootCmd.AddCommand(createOnboardTokenCmd)
+}
+
+func createOnboardTokenRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running create-onboard-token==========")
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: onboardTokenMFA}
+	var err error
+	if cmd.Flags().Changed("no-prompt") {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Unable to obtain keystone credentials: %s", err.Error())
+	}
+
+	name := onboardTokenName
+	if name == "" {
+		name = fmt.Sprintf("pf9ctl-onboard-%s", time.Now().UTC().Format("20060102-150405"))
+	}
+	token, err := keystone.CreateOnboardToken(cfg.Fqdn, cfg.Region, name, onboardTokenTTL, onboardTokenRoles, auth)
+	if err != nil {
+		zap.S().Fatalf("Unable to create onboarding token: %s", err.Error())
+	}
+	encoded, err := token.Encode()
+	if err != nil {
+		zap.S().Fatalf("Unable to encode onboarding token: %s", err.Error())
+	}
+
+	fmt.Printf("Onboarding token %s created for region %s, it expires at %s\n\n", name, cfg.Region, token.ExpiresAt.Local().Format(time.RFC3339))
+	fmt.Println(encoded)
+	fmt.Printf("\nPrepare a node with:\n  pf9ctl prep-node --onboard-token <token>\n")
+
+	zap.S().Debug("==========Finished running create-onboard-token==========")
+}
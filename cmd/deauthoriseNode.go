@@ -10,7 +10,6 @@ import (
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/config"
 	"github.com/platform9/pf9ctl/pkg/objects"
-	"github.com/platform9/pf9ctl/pkg/pmk"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -32,13 +31,18 @@ var deauthNodeCmd = &cobra.Command{
 func init() {
 	deauthNodeCmd.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
 	deauthNodeCmd.Flags().StringVarP(&ipAdd, "ip", "i", "", "IP address of the host to be deauthorized")
+	deauthNodeCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
 	rootCmd.AddCommand(deauthNodeCmd)
 }
 
 func deauthNodeRun(cmd *cobra.Command, args []string) {
+	requireWritable("deauthorize-node")
 
 	detachedMode := cmd.Flags().Changed("no-prompt")
 
+	if err := cmdexec.CheckLocal(nc); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
 	if cmdexec.CheckRemote(nc) {
 		if !config.ValidateNodeConfig(&nc, !detachedMode) {
 			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
@@ -71,12 +75,13 @@ func deauthNodeRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		zap.S().Debug("Failed to get keystone %s", err.Error())
 	}
+	requireRole(auth, "deauthorize-node")
 
 	var nodeIPs []string
 	if ipAdd != "" {
 		nodeIPs = append(nodeIPs, ipAdd)
 	} else {
-		nodeIPs = append(nodeIPs, pmk.GetIp().String())
+		nodeIPs = append(nodeIPs, localIP())
 	}
 	projectId := auth.ProjectID
 	token := auth.Token
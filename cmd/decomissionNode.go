@@ -3,8 +3,10 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/platform9/pf9ctl/pkg/client"
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/config"
@@ -18,7 +20,10 @@ import (
 var decommissionNodeCmd = &cobra.Command{
 	Use:   "decommission-node",
 	Short: "Decommissions this node from the PMK control plane",
-	Long:  "Removes the host agent package and decommissions this node from the Platform9 control plane.",
+	Long: `Removes the host agent package and decommissions this node from the Platform9 control plane.
+	The nodes which would leave a cluster below the etcd quorum of its masters or without a
+	schedulable node for the evicted pods are listed and --force is required, as it is when
+	the clusters of the nodes can't be checked.`,
 	Args: func(deauthNodeCmd *cobra.Command, args []string) error {
 		if len(args) > 0 {
 			return errors.New("No parameters are needed")
@@ -28,19 +33,31 @@ var decommissionNodeCmd = &cobra.Command{
 	Run: decommissionNodeRun,
 }
 
+var decommissionForce bool
+
 func init() {
 	decommissionNodeCmd.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
 	decommissionNodeCmd.Flags().StringVarP(&nc.User, "user", "u", "", "ssh username for the nodes")
 	decommissionNodeCmd.Flags().StringVarP(&nc.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
 	decommissionNodeCmd.Flags().StringVarP(&nc.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
 	decommissionNodeCmd.Flags().StringSliceVarP(&nc.IPs, "ip", "i", []string{}, "IP address of host to be decommissioned")
+	decommissionNodeCmd.Flags().DurationVar(&pmk.DecommissionTimeout, "timeout", pmk.DecommissionTimeout, "how long to wait for the node to be removed from the management plane")
+	decommissionNodeCmd.Flags().BoolVar(&pmk.DecommissionDeepClean, "deep-clean", false, "also remove the network interfaces, iptables rules and configuration of the CNI plugins")
+	decommissionNodeCmd.Flags().BoolVar(&pmk.DecommissionCleanRuntime, "clean-runtime", false, "also remove the containers and images of the pods from docker and the k8s.io namespace of containerd")
+	decommissionNodeCmd.Flags().BoolVar(&decommissionForce, "force", false, "decommission the nodes even when it puts the clusters they are part of at risk or they can't be checked")
+	decommissionNodeCmd.Flags().BoolVar(&overrideProtected, overrideProtectedFlag, false, "decommission the node even when it is protected: it runs the DU, matches protected_hosts or has a protected marker file")
+	decommissionNodeCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
 	rootCmd.AddCommand(decommissionNodeCmd)
 }
 
 func decommissionNodeRun(cmd *cobra.Command, args []string) {
+	requireWritable("decommission-node")
 
 	detachedMode := cmd.Flags().Changed("no-prompt")
 
+	if err := cmdexec.CheckLocal(nc); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
 	if cmdexec.CheckRemote(nc) {
 		if !config.ValidateNodeConfig(&nc, !detachedMode) {
 			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
@@ -59,6 +76,77 @@ func decommissionNodeRun(cmd *cobra.Command, args []string) {
 	}
 	fmt.Println(color.Green("✓ ") + "Loaded Config Successfully")
 	zap.S().Debug("Loaded Config Successfully")
-	pmk.DecommissionNode(cfg, nc, true)
+	refuseProtectedHosts(cfg, nc, "decommission-node")
+	if len(nc.IPs) == 0 {
+		checkDecommission(cfg, []string{localIP()})
+	} else {
+		checkDecommission(cfg, nc.IPs)
+	}
+	if len(nc.IPs) <= 1 {
+		if err := pmk.DecommissionNode(cfg, nc, true); err != nil {
+			zap.S().Fatalf("Unable to decommission node: %s", err.Error())
+		}
+		return
+	}
+
+	var failed []string
+	for _, ip := range nc.IPs {
+		nodeConfig := nc
+		nodeConfig.IPs = []string{ip}
+		fmt.Printf("Decommissioning node %s\n", ip)
+		if err := pmk.DecommissionNode(cfg, nodeConfig, true); err != nil {
+			fmt.Println(color.Red("x ") + fmt.Sprintf("Unable to decommission node %s: %s", ip, err))
+			failed = append(failed, ip)
+		}
+	}
+	if len(failed) > 0 {
+		zap.S().Fatalf("Unable to decommission node(s) %v", failed)
+	}
+}
+
+// checkDecommission lists the clusters the nodes are part of which
+// decommissioning them puts at risk, exiting unless --force is set. It exits
+// as well when the clusters of the nodes can't be checked.
+func checkDecommission(cfg *objects.Config, ips []string) {
+	executor, err := cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{})
+	if err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	c, err := client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false)
+	if err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Unable to obtain keystone credentials: %s", err.Error())
+	}
 
+	impacts, err := pmk.CheckDecommission(c, auth, cfg.Fqdn, ips)
+	if err != nil && !decommissionForce {
+		c.Segment.Close()
+		zap.S().Fatalf("Unable to check the clusters of the nodes: %s. Use --force to decommission the nodes anyway", err.Error())
+	}
+	if err != nil {
+		fmt.Println(color.Yellow("! ") + fmt.Sprintf("Unable to check the clusters of the nodes: %s", err))
+		return
+	}
+	if len(impacts) == 0 {
+		return
+	}
+	mark := color.Red("x ")
+	if decommissionForce {
+		mark = color.Yellow("! ")
+	}
+	fmt.Println(mark + "Decommissioning the nodes puts these clusters at risk:")
+	for _, impact := range impacts {
+		fmt.Printf("  %s (nodes %s):\n", impact.Cluster, strings.Join(impact.Nodes, ", "))
+		for _, risk := range impact.Risks {
+			fmt.Printf("    - %s\n", risk)
+		}
+	}
+	if !decommissionForce {
+		c.Segment.Close()
+		zap.S().Fatalf("Decommission stopped, use --force to decommission the nodes anyway")
+	}
 }
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/ssh"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var decommissionClusterCmd = &cobra.Command{
+	Use:   "decommission-cluster [flags] cluster-name",
+	Short: "Decommissions all the nodes of a cluster and deletes it",
+	Long: `Drains and detaches every node of the cluster, removes the host agent from each of
+	them over SSH then deauthorizes it, and finally deletes the cluster. The nodes which can't
+	be reached over SSH are left as they were and the cluster isn't deleted.`,
+	Args: func(decommissionClusterCmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("cluster name is required for decommission-cluster")
+		}
+		clusterName = args[0]
+		return nil
+	},
+	Example: "pf9ctl decommission-cluster <clusterName> -u ubuntu -s ~/.ssh/id_rsa",
+	Run:     decommissionClusterRun,
+}
+
+var decommissionClusterConfig objects.NodeConfig
+
+func init() {
+	decommissionClusterCmd.Flags().StringVarP(&decommissionClusterConfig.User, "user", "u", "", "ssh username for the nodes")
+	decommissionClusterCmd.Flags().StringVarP(&decommissionClusterConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	decommissionClusterCmd.Flags().StringVarP(&decommissionClusterConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	decommissionClusterCmd.Flags().StringVar(&decommissionClusterConfig.MFA, "mfa", "", "MFA token")
+	decommissionClusterCmd.Flags().StringVarP(&decommissionClusterConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	decommissionClusterCmd.Flags().BoolVar(&overrideProtected, overrideProtectedFlag, false, "decommission the cluster even when one of its nodes is protected: it runs the DU, matches protected_hosts or has a protected marker file")
+	decommissionClusterCmd.ValidArgsFunction = completeClusterNames
+	rootCmd.AddCommand(decommissionClusterCmd)
+}
+
+func decommissionClusterRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running decommission-cluster==========")
+	requireWritable("decommission-cluster")
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+
+	// Nodes are always reached over SSH, so the credentials are mandatory.
+	if !config.ValidateNodeConfig(&decommissionClusterConfig, !detachedMode) {
+		zap.S().Fatal("Invalid remote node config (Username/Password), use 'single quotes' to pass password")
+	}
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: decommissionClusterConfig.MFA}
+	var err error
+	if detachedMode {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + "Loaded Config Successfully")
+	zap.S().Debug("Loaded Config Successfully")
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Unable to obtain keystone credentials: %s", err.Error())
+	}
+	requireRole(auth, "decommission-cluster")
+
+	exists, clusterUuid, _, err := c.Qbert.CheckClusterExists(clusterName, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to check existing cluster: %s", err.Error())
+	}
+	if !exists {
+		zap.S().Fatalf("Cluster %s does not exist", clusterName)
+	}
+
+	// None of the nodes is touched when one of them is protected
+	allNodes, err := c.Qbert.ListNodes(auth.Token, auth.ProjectID)
+	if err != nil {
+		zap.S().Fatalf("Unable to list the nodes of the cluster: %s", err.Error())
+	}
+	// The nodes checked here are the ones decommissioned
+	nodes := pmk.ClusterNodes(allNodes, clusterUuid)
+	protectedConfig := decommissionClusterConfig
+	protectedConfig.IPs = nil
+	for _, node := range nodes {
+		protectedConfig.IPs = append(protectedConfig.IPs, node.PrimaryIp)
+	}
+	if len(protectedConfig.IPs) > 0 {
+		refuseProtectedHosts(cfg, protectedConfig, "decommission-cluster")
+	}
+
+	if !detachedMode {
+		fmt.Printf("All the nodes of cluster %s will be decommissioned and the cluster will be deleted.\n", clusterName)
+		answer, err := util.AskBool("Do you want to continue?")
+		if err != nil || !answer {
+			fmt.Println("Stopping decommission-cluster")
+			return
+		}
+	}
+
+	ssh.SudoPassword = decommissionClusterConfig.SudoPassword
+	if err := c.Segment.Track(client.Event{Name: "Decommission-cluster", Phase: "start", Status: util.CheckPass}, auth); err != nil {
+		zap.S().Debugf("Unable to send Segment event for decommission cluster. Error: %s", err.Error())
+	}
+
+	if err := pmk.DecommissionCluster(cfg, decommissionClusterConfig, c, auth, clusterUuid, nodes); err != nil {
+		if sendErr := c.Segment.Track(client.Event{Name: "Decommission-cluster", Phase: "decommission", Status: util.CheckFail, Err: err}, auth); sendErr != nil {
+			zap.S().Debugf("Unable to send Segment event for decommission cluster. Error: %s", sendErr.Error())
+		}
+		zap.S().Fatalf("Failed to decommission cluster %s: %s", clusterName, err.Error())
+	}
+
+	if err := c.Segment.Track(client.Event{Name: "Decommission-cluster", Phase: "complete", Status: util.CheckPass}, auth); err != nil {
+		zap.S().Debugf("Unable to send Segment event for decommission cluster. Error: %s", err.Error())
+	}
+	zap.S().Debug("==========Finished running decommission-cluster==========")
+}
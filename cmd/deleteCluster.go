@@ -10,7 +10,6 @@ import (
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/config"
 	"github.com/platform9/pf9ctl/pkg/objects"
-	"github.com/platform9/pf9ctl/pkg/pmk"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -38,10 +37,12 @@ func init() {
 	deleteClusterCmd.Flags().StringVarP(&clusterName, "name", "n", "", "clusters name")
 	deleteClusterCmd.Flags().StringVarP(&clusterUuid, "uuid", "i", "", "clusters uuid")
 	deleteClusterCmd.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
+	deleteClusterCmd.RegisterFlagCompletionFunc("name", completeClusterFlag)
 	rootCmd.AddCommand(deleteClusterCmd)
 }
 
 func deleteClusterRun(cmd *cobra.Command, args []string) {
+	requireWritable("delete-cluster")
 
 	if !cmd.Flags().Changed("name") && !cmd.Flags().Changed("uuid") {
 		zap.S().Fatalf("You must pass a cluster name or the cluster uuid")
@@ -81,6 +82,7 @@ func deleteClusterRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		zap.S().Debug("Failed to get keystone %s", err.Error())
 	}
+	requireRole(auth, "delete-cluster")
 
 	projectId := auth.ProjectID
 	token := auth.Token
@@ -94,7 +96,7 @@ func deleteClusterRun(cmd *cobra.Command, args []string) {
 
 	}
 
-	nodeIPs = append(nodeIPs, pmk.GetIp().String())
+	nodeIPs = append(nodeIPs, localIP())
 
 	projectNodes := c.Qbert.GetAllNodes(token, projectId)
 	nodeUuids := c.Resmgr.GetHostId(token, nodeIPs)
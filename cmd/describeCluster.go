@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var describeClusterCmd = &cobra.Command{
+	Use:   "describe-cluster <name>",
+	Short: "Describes the posture of a cluster",
+	Long: `Describes a cluster: its pmk version and the upgrades available to it, its addons,
+	the expiry of the certificate of its API server and the status of its etcd backups.`,
+	Example: `pf9ctl describe-cluster my-cluster
+	pf9ctl describe-cluster my-cluster -o jsonpath='{.uuid}'`,
+	Args: cobra.ExactArgs(1),
+	Run:  describeClusterRun,
+}
+
+var describeClusterMFA string
+
+// certExpiryWarning is how long before its expiry the certificate of the API
+// server is reported
+const certExpiryWarning = 30 * 24 * time.Hour
+
+func init() {
+	describeClusterCmd.Flags().StringVar(&describeClusterMFA, "mfa", "", "MFA token")
+	addOutputFlag(describeClusterCmd)
+	describeClusterCmd.ValidArgsFunction = completeClusterNames
+	rootCmd.AddCommand(describeClusterCmd)
+}
+
+func describeClusterRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running describe-cluster==========")
+	format := parseOutput()
+
+	_, c, auth := loadClient(cmd, describeClusterMFA)
+	defer c.Segment.Close()
+
+	clusterName := args[0]
+	exists, clusterUuid, _, err := c.Qbert.CheckClusterExists(clusterName, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to check the cluster: %s", err.Error())
+	}
+	if !exists {
+		zap.S().Fatalf("Cluster %s not found", clusterName)
+	}
+
+	spec, err := c.Qbert.GetClusterSpec(clusterUuid, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to get cluster %s: %s", clusterName, err.Error())
+	}
+	// The addons are also derived from the spec, so failing to list them
+	// isn't fatal
+	addons, err := c.Qbert.GetClusterAddons(clusterUuid, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Debugf("Unable to get the addons of cluster %s: %s", clusterName, err.Error())
+	}
+	versions, err := c.Qbert.GetPMKVersions(auth.Token, auth.ProjectID)
+	if err != nil {
+		zap.S().Debugf("Unable to get the pmk versions: %s", err.Error())
+	}
+	d := pmk.DescribeCluster(spec, addons, versions)
+	if d.APIEndpoint != "" {
+		if d.CertExpiry, err = pmk.APIServerCertExpiry(d.APIEndpoint, 5*time.Second); err != nil {
+			d.CertError = err.Error()
+		}
+	}
+
+	if !printOutput(format, d) {
+		printClusterDescription(d)
+	}
+
+	zap.S().Debug("==========Finished running describe-cluster==========")
+}
+
+func printClusterDescription(d pmk.ClusterDescription) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", d.Name)
+	fmt.Fprintf(w, "UUID:\t%s\n", d.Uuid)
+	fmt.Fprintf(w, "Status:\t%s (task %s)\n", d.Status, d.TaskStatus)
+	fmt.Fprintf(w, "PMK version:\t%s\n", d.PmkVersion)
+	fmt.Fprintf(w, "Container runtime:\t%s\n", d.ContainerRuntime)
+	fmt.Fprintf(w, "API endpoint:\t%s\n", d.APIEndpoint)
+	w.Flush()
+
+	fmt.Println("\nUpgrade:")
+	switch {
+	case d.Upgrade.UpgradingTo != "":
+		fmt.Println(color.Yellow("! ") + fmt.Sprintf("Upgrading to %s", d.Upgrade.UpgradingTo))
+	case d.Upgrade.Available:
+		if d.Upgrade.PatchVersion != "" {
+			fmt.Println(color.Yellow("! ") + fmt.Sprintf("Patch upgrade available to %s", d.Upgrade.PatchVersion))
+		}
+		if d.Upgrade.MinorVersion != "" {
+			fmt.Println(color.Yellow("! ") + fmt.Sprintf("Minor upgrade available to %s", d.Upgrade.MinorVersion))
+		}
+	default:
+		fmt.Println(color.Green("✓ ") + "No upgrade available")
+	}
+	if len(d.Upgrade.Versions) > 0 {
+		fmt.Printf("  Newer supported versions: %s\n", strings.Join(d.Upgrade.Versions, ", "))
+	}
+
+	fmt.Println("\nAddons:")
+	if len(d.Addons) == 0 {
+		fmt.Println("  none")
+	} else {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "  ADDON\tVERSION\tPHASE\tMESSAGE")
+		for _, addon := range d.Addons {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", addon.Type, addon.Version, addon.Phase, addon.Message)
+		}
+		w.Flush()
+	}
+
+	fmt.Println("\nAPI server certificate:")
+	switch {
+	case d.CertError != "":
+		fmt.Println(color.Yellow("! ") + d.CertError)
+	case d.CertExpiry.IsZero():
+		fmt.Println(color.Yellow("! ") + "Cluster has no API endpoint")
+	case time.Until(d.CertExpiry) < 0:
+		fmt.Println(color.Red("x ") + fmt.Sprintf("Expired on %s", d.CertExpiry.Format(time.RFC3339)))
+	case time.Until(d.CertExpiry) < certExpiryWarning:
+		fmt.Println(color.Yellow("! ") + fmt.Sprintf("Expires on %s", d.CertExpiry.Format(time.RFC3339)))
+	default:
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("Expires on %s", d.CertExpiry.Format(time.RFC3339)))
+	}
+
+	fmt.Println("\nEtcd backup:")
+	if !d.EtcdBackup.Enabled {
+		fmt.Println(color.Yellow("! ") + "Disabled")
+		return
+	}
+	fmt.Printf("  Storage: %s %s\n", d.EtcdBackup.StorageType, d.EtcdBackup.LocalPath)
+	fmt.Printf("  Schedule: %s\n", d.EtcdBackup.Schedule)
+	switch d.EtcdBackup.TaskStatus {
+	case "", "success":
+		fmt.Println(color.Green("✓ ") + "Last backup task succeeded")
+	default:
+		fmt.Println(color.Red("x ") + fmt.Sprintf("Last backup task %s: %s", d.EtcdBackup.TaskStatus, d.EtcdBackup.TaskError))
+	}
+}
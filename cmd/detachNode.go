@@ -8,6 +8,7 @@ import (
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/jobs"
 	"github.com/platform9/pf9ctl/pkg/objects"
 	"github.com/platform9/pf9ctl/pkg/pmk"
 	"github.com/platform9/pf9ctl/pkg/qbert"
@@ -33,13 +34,15 @@ var detachNodeCmd = &cobra.Command{
 func init() {
 	detachNodeCmd.Flags().StringSliceVarP(&nodeIPs, "node-ip", "n", []string{}, "node ip address")
 	detachNodeCmd.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
+	detachNodeCmd.RegisterFlagCompletionFunc("node-ip", completeNodeIPsIn(true))
 	rootCmd.AddCommand(detachNodeCmd)
 }
 
 func detachNodeRun(cmd *cobra.Command, args []string) {
+	requireWritable("detach-node")
 
 	if len(nodeIPs) == 0 {
-		nodeIPs = append(nodeIPs, pmk.GetIp().String())
+		nodeIPs = append(nodeIPs, localIP())
 	}
 
 	detachedMode := cmd.Flags().Changed("no-prompt")
@@ -78,6 +81,7 @@ func detachNodeRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		zap.S().Debug("Failed to get keystone %s", err.Error())
 	}
+	requireRole(auth, "detach-node")
 	projectId := auth.ProjectID
 	token := auth.Token
 
@@ -96,34 +100,23 @@ func detachNodeRun(cmd *cobra.Command, args []string) {
 
 	fmt.Println("Starting detaching process")
 
-	if err := c.Segment.SendEvent("Starting detach-node", auth, "", ""); err != nil {
+	if err := c.Segment.Track(client.Event{Name: "Detach-node", Phase: "start", Status: util.CheckPass}, auth); err != nil {
 		zap.S().Debugf("Unable to send Segment event for detach node. Error: %s", err.Error())
 	}
 
-	for i := range detachNodes {
-
-		isMaster := c.Qbert.GetNodeInfo(token, projectId, nodeUuids[0])
-		clusterNodes := getAllClusterNodes(projectNodes, []string{isMaster.ClusterUuid})
-
-		if len(clusterNodes) == 1 || isMaster.IsMaster == 1 {
-			fmt.Printf("Node %v is either the master node or the last node in the cluster\n", isMaster.Uuid)
-		}
-
-		err1 := c.Qbert.DetachNode(detachNodes[i].ClusterUuid, projectId, token, detachNodes[i].Uuid)
-
-		if err1 != nil {
-			if err := c.Segment.SendEvent("Detaching-node", auth, "Failed to detach node", ""); err != nil {
-				zap.S().Debugf("Unable to send Segment event for detach node. Error: %s", err.Error())
-			}
-			zap.S().Info("Encountered an error while detaching the", detachNodes[i].PrimaryIp, " node from a Kubernetes cluster : ", err1)
-		} else {
-			if err := c.Segment.SendEvent("Detaching-node", detachNodes[i].PrimaryIp, "Node detached", ""); err != nil {
-				zap.S().Debugf("Unable to send Segment event for detach node. Error: %s", err.Error())
-			}
-			zap.S().Infof("Node [%v] detached from cluster", detachNodes[i].Uuid)
-		}
+	var hosts []jobs.Host
+	for _, node := range detachNodes {
+		hosts = append(hosts, jobs.Host{IP: node.PrimaryIp, HostID: node.Uuid, ClusterUuid: node.ClusterUuid})
+	}
+	job, err := jobs.New(jobs.DetachNode, cfg.Fqdn, auth.ProjectID, "", "", hosts)
+	if err != nil {
+		zap.S().Fatalf("Unable to create detach-node job: %s", err.Error())
 	}
+	fmt.Printf("Started job %s, resume it with 'pf9ctl jobs resume %s' if interrupted\n", job.ID, job.ID)
 
+	if err := pmk.RunJob(c, auth, job); err != nil {
+		zap.S().Fatalf(err.Error())
+	}
 }
 
 //returns the nodes whos ip's were passed in the flag (or the node installed on the machine if no ip was passed)
@@ -0,0 +1,121 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compares the spec of a cluster with the cluster",
+	Long: `Compares a declarative spec of a cluster with the cluster in qbert: the settings given in
+	the spec, named like the ones of 'pf9ctl cluster-template export', and the nodes attached to
+	it. The lines starting with - are the live state and the ones starting with + are the spec.
+	Nothing is changed. The command exits with 2 when the cluster differs from the spec.`,
+	Example: `pf9ctl diff -f cluster.yaml
+
+	# cluster.yaml
+	kind: Cluster
+	version: 1
+	name: edge
+	settings:
+	  pmkVersion: 1.21.3-pmk.72
+	  etcdBackup:
+	    enabled: true
+	nodes:
+	- ip: 10.0.0.1
+	  role: master
+	- ip: 10.0.0.2
+	  role: worker`,
+	Args: cobra.NoArgs,
+	Run:  diffRun,
+}
+
+var (
+	diffFile string
+	diffMFA  string
+)
+
+// exitDiffFound is the exit code of diff when the cluster differs from its
+// spec, to tell it from a failed diff
+const exitDiffFound = 2
+
+func init() {
+	diffCmd.Flags().StringVarP(&diffFile, "file", "f", "", "YAML spec of the cluster")
+	diffCmd.Flags().StringVar(&diffMFA, "mfa", "", "MFA token")
+	diffCmd.MarkFlagRequired("file")
+	rootCmd.AddCommand(diffCmd)
+}
+
+func diffRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running diff==========")
+
+	spec, err := pmk.ReadClusterSpec(diffFile)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+
+	_, c, auth := loadClient(cmd, diffMFA)
+	defer c.Segment.Close()
+
+	exists, clusterUuid, _, err := c.Qbert.CheckClusterExists(spec.Name, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to check the cluster: %s", err.Error())
+	}
+	var live map[string]interface{}
+	if exists {
+		if live, err = c.Qbert.GetClusterSpec(clusterUuid, auth.ProjectID, auth.Token); err != nil {
+			zap.S().Fatalf("Unable to get cluster %s: %s", spec.Name, err.Error())
+		}
+	}
+	d, err := pmk.DiffClusterSpec(spec, live, c.Qbert.GetAllNodes(auth.Token, auth.ProjectID))
+	if err != nil {
+		zap.S().Fatalf("Unable to compare cluster %s with its spec: %s", spec.Name, err.Error())
+	}
+
+	if d.Empty() {
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("Cluster %s matches %s", d.Name, diffFile))
+		zap.S().Debug("==========Finished running diff==========")
+		return
+	}
+	printClusterDiff(d)
+	c.Segment.Close()
+	exit(exitDiffFound)
+}
+
+// printClusterDiff prints the differences like a diff of the spec, the live
+// state in red and the spec in green
+func printClusterDiff(d pmk.ClusterDiff) {
+	if d.Exists {
+		fmt.Printf("Cluster %s (%s):\n", d.Name, d.Uuid)
+	} else {
+		fmt.Println(color.Green(fmt.Sprintf("+ cluster %s doesn't exist", d.Name)))
+	}
+	if len(d.Settings) > 0 {
+		fmt.Println("  settings:")
+		for _, s := range d.Settings {
+			if d.Exists {
+				fmt.Println(color.Red(fmt.Sprintf("-   %s: %s", s.Name, pmk.FormatSetting(s.Live))))
+			}
+			fmt.Println(color.Green(fmt.Sprintf("+   %s: %s", s.Name, pmk.FormatSetting(s.Want))))
+		}
+	}
+	if len(d.Nodes) > 0 {
+		fmt.Println("  nodes:")
+		for _, n := range d.Nodes {
+			if n.LiveRole != "" {
+				fmt.Println(color.Red(fmt.Sprintf("-   %s: %s", n.IP, n.LiveRole)))
+			}
+			if n.WantRole != "" {
+				fmt.Println(color.Green(fmt.Sprintf("+   %s: %s", n.IP, n.WantRole)))
+			}
+		}
+	}
+	fmt.Println(color.Yellow("! ") + fmt.Sprintf("%d setting(s) and %d node(s) differ from the spec", len(d.Settings), len(d.Nodes)))
+}
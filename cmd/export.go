@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports the resources of the DU for other tools",
+}
+
+var exportInventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "Exports the inventory of the hosts onboarded to the DU",
+	Long: `Exports the hosts registered with the DU and tenant of the config, with their IP, role,
+	cluster and OS from resmgr and qbert, and the last job of pf9ctl which processed them. The
+	ansible format writes an INI inventory with the pf9_masters, pf9_workers and pf9_unattached
+	groups and a pf9_cluster_<name> group per cluster.`,
+	Example: `pf9ctl export inventory --format ansible -o hosts.ini
+	pf9ctl export inventory --format csv`,
+	Args: cobra.NoArgs,
+	Run:  exportInventoryRun,
+}
+
+var (
+	inventoryFormat string
+	inventoryOutput string
+	inventoryMFA    string
+)
+
+func init() {
+	exportInventoryCmd.Flags().StringVar(&inventoryFormat, "format", pmk.InventoryAnsible, "format of the inventory: ansible, csv or json")
+	exportInventoryCmd.Flags().StringVarP(&inventoryOutput, "output", "o", "", "File to write the inventory to (default stdout)")
+	exportInventoryCmd.Flags().StringVar(&inventoryMFA, "mfa", "", "MFA token")
+	exportInventoryCmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{pmk.InventoryAnsible, pmk.InventoryCSV, pmk.InventoryJSON}, cobra.ShellCompDirectiveNoFileComp
+	})
+	exportCmd.AddCommand(exportInventoryCmd)
+	rootCmd.AddCommand(exportCmd)
+}
+
+func exportInventoryRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running export inventory==========")
+
+	// Fail on an invalid format before connecting to the DU
+	if err := pmk.WriteInventory(ioutil.Discard, nil, inventoryFormat); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+
+	cfg, c, auth := loadClient(cmd, inventoryMFA)
+	defer c.Segment.Close()
+
+	hosts, err := pmk.Inventory(c, auth, cfg.Fqdn)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	var out bytes.Buffer
+	if err := pmk.WriteInventory(&out, hosts, inventoryFormat); err != nil {
+		zap.S().Fatalf("Unable to write the inventory: %s", err.Error())
+	}
+
+	if inventoryOutput == "" {
+		os.Stdout.Write(out.Bytes())
+	} else {
+		if err := ioutil.WriteFile(inventoryOutput, out.Bytes(), 0644); err != nil {
+			zap.S().Fatalf("Unable to write the inventory: %s", err.Error())
+		}
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("Inventory of %d host(s) written to %s", len(hosts), inventoryOutput))
+	}
+
+	zap.S().Debug("==========Finished running export inventory==========")
+}
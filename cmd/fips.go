@@ -0,0 +1,26 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"net/http"
+
+	"github.com/platform9/pf9ctl/pkg/fips"
+	"github.com/platform9/pf9ctl/pkg/objects"
+)
+
+// fipsMode is the --fips flag, it overrides the fips setting
+var fipsMode bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&fipsMode, "fips", false, "restrict the TLS and SSH connections to the algorithms approved by FIPS 140 and require the openssl of the nodes to be in FIPS mode (default the fips setting, always on in the FIPS builds)")
+}
+
+// configureFIPS enables the FIPS mode with --fips or the fips setting of the
+// stored config, restricting the default transport of the HTTP clients
+func configureFIPS(stored objects.Config) {
+	fips.Enable(fipsMode || stored.FIPS)
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		t.TLSClientConfig = fips.TLSConfig(t.TLSClientConfig)
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var configureFirewallCmd = &cobra.Command{
+	Use:   "configure-firewall",
+	Short: "Allows the traffic PMK needs in the firewall of the nodes",
+	Long: `Adds the rules PMK needs to the firewall of the nodes, with ufw, firewalld or iptables
+	depending on the one running on the node. The rules depend on the role of the node and on the
+	CIDRs of the cluster, existing rules are kept. Use --print-rules to only print the rules, for
+	firewalls managed outside of the nodes.`,
+	Example: `pf9ctl configure-firewall --print-rules --role master --node-cidr 10.0.0.0/24
+	pf9ctl configure-firewall --print-rules --firewall firewalld --role worker
+	pf9ctl configure-firewall --ip 10.0.0.1 --ip 10.0.0.2 -u ubuntu -s ~/.ssh/id_rsa --role worker --node-cidr 10.0.0.0/24`,
+	Args: cobra.NoArgs,
+	Run:  configureFirewallRun,
+}
+
+var (
+	firewallConfig     objects.NodeConfig
+	firewallRole       string
+	firewallName       string
+	firewallCIDRs      pmk.FirewallCIDRs
+	firewallPrintRules bool
+)
+
+func init() {
+	configureFirewallCmd.Flags().StringVarP(&firewallConfig.User, "user", "u", "", "ssh username for the nodes")
+	configureFirewallCmd.Flags().StringVarP(&firewallConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	configureFirewallCmd.Flags().StringVarP(&firewallConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	configureFirewallCmd.Flags().StringSliceVarP(&firewallConfig.IPs, "ip", "i", []string{}, "IP address of the nodes")
+	configureFirewallCmd.Flags().StringVarP(&firewallConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	configureFirewallCmd.Flags().StringVar(&firewallRole, "role", "", "Role of the nodes, master or worker (default allows the traffic of both roles)")
+	configureFirewallCmd.Flags().StringVar(&firewallName, "firewall", "", "Firewall to configure, ufw, firewalld or iptables (default detected on each node)")
+	configureFirewallCmd.Flags().StringVar(&firewallCIDRs.Nodes, "node-cidr", "", "CIDR of the nodes the traffic between nodes is allowed from (default anywhere)")
+	configureFirewallCmd.Flags().StringVar(&firewallCIDRs.Containers, "containers-cidr", "10.20.0.0/16", "CIDR for container overlay")
+	configureFirewallCmd.Flags().StringVar(&firewallCIDRs.Services, "services-cidr", "10.21.0.0/16", "CIDR for services overlay")
+	configureFirewallCmd.Flags().BoolVar(&firewallPrintRules, "print-rules", false, "Print the rules instead of applying them, as commands of the firewall when --firewall is set")
+	rootCmd.AddCommand(configureFirewallCmd)
+}
+
+func configureFirewallRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running configure-firewall==========")
+	if !firewallPrintRules {
+		requireWritable("configure-firewall")
+	}
+
+	if err := pmk.ValidateFirewall(firewallName); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	rules, err := pmk.FirewallRules(firewallRole, firewallCIDRs)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+
+	if firewallPrintRules {
+		printFirewallRules(rules)
+		return
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if err := cmdexec.CheckLocal(firewallConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	isRemote := cmdexec.CheckRemote(firewallConfig)
+	if isRemote {
+		if !config.ValidateNodeConfig(&firewallConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	ips := firewallConfig.IPs
+	if len(ips) == 0 {
+		ips = []string{"localhost"}
+	}
+
+	failed := 0
+	for _, ip := range ips {
+		nodeCfg := firewallConfig
+		nodeCfg.IPs = []string{ip}
+		executor, err := cmdexec.GetExecutor("", nodeCfg)
+		if err == nil && isRemote {
+			err = SudoPasswordCheck(executor, detachedMode, nodeCfg.SudoPassword)
+		}
+		firewall := firewallName
+		if err == nil {
+			firewall, err = pmk.ApplyFirewallRules(executor, firewallName, rules)
+		}
+		if err != nil {
+			failed++
+			fmt.Println(color.Red("x ") + fmt.Sprintf("Unable to configure the firewall of node %s: %s", ip, err))
+			continue
+		}
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("%d rules added to %s on node %s", len(rules), firewall, ip))
+	}
+	if failed > 0 {
+		zap.S().Fatalf("Unable to configure the firewall of %d node(s)", failed)
+	}
+
+	zap.S().Debug("==========Finished running configure-firewall==========")
+}
+
+// printFirewallRules prints the rules, as the script of --firewall when set
+func printFirewallRules(rules []pmk.FirewallRule) {
+	if firewallName != "" {
+		script, err := pmk.FirewallScript(firewallName, rules)
+		if err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+		fmt.Print(script)
+		return
+	}
+	fmt.Println(pmk.FirewallRule{Protocol: "PROTO", Ports: "PORTS", Source: "SOURCE", Description: "DESCRIPTION"}.String())
+	for _, rule := range rules {
+		fmt.Println(rule.String())
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/ssh"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// The flags verifying the SSH host keys of the nodes, they override the
+// settings of the config
+var (
+	hostKeyPolicy        string
+	knownHostsFile       string
+	hostFingerprintsFile string
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&hostKeyPolicy, "host-key-policy", "", "how the SSH host keys of the nodes are verified: strict only accepts the keys of the known hosts or pinned, tofu asks to trust the key of a node seen for the first time, insecure accepts any key (default the host_key_policy setting or tofu)")
+	rootCmd.PersistentFlags().StringVar(&knownHostsFile, "known-hosts", "", "known_hosts file the SSH host keys of the nodes are verified against and added to (default the known_hosts_file setting or $HOME/.ssh/known_hosts)")
+	rootCmd.PersistentFlags().StringVar(&hostFingerprintsFile, "host-fingerprints", "", "JSON inventory pinning the SSH host keys of the nodes to the fingerprints of their sshFingerprints, see 'export inventory' (default the host_fingerprints_file setting)")
+}
+
+// configureHostKeys sets how the SSH host keys of the nodes are verified from
+// the flags, or the settings of the stored config
+func configureHostKeys(cmd *cobra.Command, stored objects.Config) error {
+	policy := firstNonEmpty(hostKeyPolicy, stored.HostKeyPolicy, ssh.HostKeyTOFU)
+	if err := ssh.ValidateHostKeyPolicy(policy); err != nil {
+		return err
+	}
+	ssh.HostKeyPolicy = policy
+	ssh.KnownHostsFile = firstNonEmpty(knownHostsFile, stored.KnownHostsFile, filepath.Join(util.HomeDir, ".ssh", "known_hosts"))
+
+	if file := firstNonEmpty(hostFingerprintsFile, stored.HostFingerprintsFile); file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("unable to read the host fingerprints: %w", err)
+		}
+		defer f.Close()
+		if ssh.HostFingerprints, err = pmk.ReadHostFingerprints(f); err != nil {
+			return fmt.Errorf("unable to read the host fingerprints %s: %w", file, err)
+		}
+	}
+
+	ssh.ConfirmHostKey = nil
+	if flag := cmd.Flags().Lookup("no-prompt"); flag == nil || !flag.Changed {
+		ssh.ConfirmHostKey = confirmHostKey
+	}
+	return nil
+}
+
+func confirmHostKey(host, fingerprint string) bool {
+	answer, err := util.AskBool("\nThe authenticity of host %s can't be established, its key fingerprint is %s. Do you want to trust it?", host, fingerprint)
+	return err == nil && answer
+}
+
+// firstNonEmpty returns the first of values which isn't empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
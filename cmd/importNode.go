@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var importNodeCmd = &cobra.Command{
+	Use:   "import-node",
+	Short: "Imports a node of an existing Kubernetes installation, like kubeadm, into PMK",
+	Long: `Detects the kubelet, kubeadm and container runtime already installed on the node and
+	checks they can be imported. The existing installation is then adopted: the kubelet is stopped,
+	its pods removed, its configuration moved to /etc/pf9/import-backup and the Kubernetes packages
+	removed while the container runtime and its images are kept. With --teardown, the installation
+	is removed along with its container runtime and data instead. The node is then prepared like
+	with prep-node. Drain the node and delete it from its current cluster first.`,
+	Example: `pf9ctl import-node --ip 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --check-only
+	pf9ctl import-node --ip 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa
+	pf9ctl import-node --ip 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --teardown`,
+	Args: cobra.NoArgs,
+	Run:  importNodeRun,
+}
+
+var (
+	importConfig    objects.NodeConfig
+	importTeardown  bool
+	importCheckOnly bool
+)
+
+func init() {
+	importNodeCmd.Flags().StringVarP(&importConfig.User, "user", "u", "", "ssh username for the node")
+	importNodeCmd.Flags().StringVarP(&importConfig.Password, "password", "p", "", "ssh password for the node (use 'single quotes' to pass password)")
+	importNodeCmd.Flags().StringVarP(&importConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the node")
+	importNodeCmd.Flags().StringSliceVarP(&importConfig.IPs, "ip", "i", []string{}, "IP address of the node")
+	importNodeCmd.Flags().StringVarP(&importConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	importNodeCmd.Flags().StringVar(&importConfig.MFA, "mfa", "", "MFA token")
+	importNodeCmd.Flags().BoolVar(&importTeardown, "teardown", false, "Remove the existing installation, its container runtime and data instead of adopting it")
+	importNodeCmd.Flags().BoolVar(&importCheckOnly, "check-only", false, "Only report the existing installation and whether it can be imported")
+	importNodeCmd.Flags().BoolVarP(&skipChecks, "skip-checks", "c", false, "Will skip optional checks if true")
+	importNodeCmd.Flags().BoolVar(&overrideProtected, overrideProtectedFlag, false, "Import the node even when it is protected: it runs the DU, matches protected_hosts or has a protected marker file")
+	importNodeCmd.Flags().StringVar(&util.NodeRole, "role", "", "Role the node is prepared for, master or worker, to check and tune the kernel for that role (default checks for any role)")
+	rootCmd.AddCommand(importNodeCmd)
+}
+
+func importNodeRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running import-node==========")
+	if !importCheckOnly {
+		requireWritable("import-node")
+	}
+
+	if len(importConfig.IPs) > 1 {
+		zap.S().Fatal("Only one node can be imported at a time")
+	}
+	if err := util.ValidateNodeRole(util.NodeRole); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if skipChecks {
+		pmk.WarningOptionalChecks = true
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if err := cmdexec.CheckLocal(importConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	isRemote := cmdexec.CheckRemote(importConfig)
+	if isRemote {
+		if !config.ValidateNodeConfig(&importConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	cfg, c, auth := loadClient(cmd, importConfig.MFA)
+	defer c.Segment.Close()
+	if !importCheckOnly {
+		requireRole(auth, "import-node")
+	}
+
+	executor, err := cmdexec.GetExecutor(cfg.ProxyURL, importConfig)
+	if err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	if isRemote {
+		if err := SudoPasswordCheck(executor, detachedMode, importConfig.SudoPassword); err != nil {
+			zap.S().Fatal("Failed executing commands on remote machine with sudo: ", err.Error())
+		}
+	}
+	c.Executor = executor
+	if !importCheckOnly {
+		refuseProtectedHosts(cfg, importConfig, "import-node")
+	}
+
+	inst, err := pmk.DetectExistingInstall(executor)
+	if err != nil {
+		zap.S().Fatalf("Unable to detect the existing installation: %s", err.Error())
+	}
+	printExistingInstall(inst)
+
+	if inst.Found() {
+		versions, err := c.Qbert.GetPMKVersions(auth.Token, auth.ProjectID)
+		if err != nil {
+			zap.S().Fatalf("Unable to get the pmk versions: %s", err.Error())
+		}
+		var supported []string
+		for _, role := range versions.Roles {
+			supported = append(supported, role.RoleVersion)
+		}
+		problems, warnings := inst.Validate(supported, importTeardown)
+		for _, warning := range warnings {
+			fmt.Println(color.Yellow("! ") + warning)
+		}
+		for _, problem := range problems {
+			fmt.Println(color.Red("x ") + problem)
+		}
+		if len(problems) > 0 {
+			zap.S().Fatal("The node can't be imported")
+		}
+		if importCheckOnly {
+			fmt.Println(color.Green("✓ ") + "The node can be imported")
+			return
+		}
+
+		action := "adopt the existing installation, its configuration is moved to /etc/pf9/import-backup"
+		if importTeardown {
+			action = "remove the existing installation, its container runtime, container images and etcd data"
+		}
+		if !detachedMode && !confirmImport(action) {
+			exit(0)
+		}
+
+		if importTeardown {
+			err = pmk.TeardownExistingInstall(executor, inst)
+		} else {
+			err = pmk.AdoptExistingInstall(executor, inst)
+		}
+		if err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+		if importTeardown {
+			fmt.Println(color.Green("✓ ") + "Existing installation removed")
+		} else {
+			fmt.Println(color.Green("✓ ") + "Existing installation adopted")
+		}
+	} else if importCheckOnly {
+		fmt.Println(color.Green("✓ ") + "No existing Kubernetes installation, the node can be prepared with prep-node")
+		return
+	}
+
+	if detachedMode {
+		importConfig.RemoveExistingPkgs = true
+	}
+	// The adopted installation keeps its container runtime
+	pmk.ReuseRuntime = !importTeardown
+	runPrepNode(cfg, c, auth, importConfig, isRemote, detachedMode)
+
+	zap.S().Debug("==========Finished running import-node==========")
+}
+
+func printExistingInstall(inst pmk.ExistingInstall) {
+	if !inst.Found() {
+		fmt.Println(color.Green("✓ ") + "No existing Kubernetes installation found")
+		return
+	}
+	var found []string
+	if inst.Kubelet {
+		kubelet := "kubelet " + inst.KubeletVersion
+		if inst.KubeletActive {
+			kubelet += " (running)"
+		}
+		found = append(found, strings.TrimSpace(kubelet))
+	}
+	if inst.Kubeadm {
+		found = append(found, "kubeadm")
+	}
+	if inst.ControlPlane {
+		found = append(found, "control plane static pods")
+	}
+	if inst.Runtime != "" {
+		found = append(found, strings.TrimSpace(inst.Runtime+" "+inst.RuntimeVersion))
+	}
+	fmt.Println(color.Yellow("! ") + "Existing Kubernetes installation found: " + strings.Join(found, ", "))
+}
+
+func confirmImport(action string) bool {
+	fmt.Printf("\nImporting the node will %s. Do you want to continue? (y/n) ", action)
+	reader := bufio.NewReader(os.Stdin)
+	char, _, _ := reader.ReadRune()
+	return char == 'y'
+}
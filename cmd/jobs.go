@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/jobs"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Manages the batch operations run on several nodes",
+	Long: `Batch operations like attach-node and detach-node are recorded as jobs with a
+	local job ID, their per node progress can be inspected and interrupted jobs resumed.`,
+}
+
+var jobsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the jobs",
+	Args:  cobra.NoArgs,
+	Run:   jobsListRun,
+}
+
+var jobsStatusCmd = &cobra.Command{
+	Use:   "status <id>",
+	Short: "Shows the progress of a job on each node",
+	Args:  cobra.ExactArgs(1),
+	Run:   jobsStatusRun,
+}
+
+var jobsResumeCmd = &cobra.Command{
+	Use:   "resume <id>",
+	Short: "Resumes a job on the nodes it has not completed on",
+	Args:  cobra.ExactArgs(1),
+	Run:   jobsResumeRun,
+}
+
+var jobsResumeMFA string
+
+func init() {
+	jobsResumeCmd.Flags().StringVar(&jobsResumeMFA, "mfa", "", "MFA token")
+	jobsCmd.AddCommand(jobsListCmd)
+	jobsCmd.AddCommand(jobsStatusCmd)
+	jobsCmd.AddCommand(jobsResumeCmd)
+	rootCmd.AddCommand(jobsCmd)
+}
+
+func jobsListRun(cmd *cobra.Command, args []string) {
+	list, err := jobs.List()
+	if err != nil {
+		zap.S().Fatalf("Unable to list jobs: %s", err.Error())
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tOPERATION\tCLUSTER\tSTATUS\tNODES DONE\tCREATED")
+	for _, job := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", job.ID, job.Operation, job.ClusterName, job.Status(),
+			job.Progress(), job.CreatedAt.Format(time.RFC3339))
+	}
+	w.Flush()
+}
+
+func jobsStatusRun(cmd *cobra.Command, args []string) {
+	job, err := jobs.Load(args[0])
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+
+	fmt.Printf("Job:       %s\n", job.ID)
+	fmt.Printf("Operation: %s\n", job.Operation)
+	if job.ClusterName != "" {
+		fmt.Printf("Cluster:   %s\n", job.ClusterName)
+	}
+	fmt.Printf("Status:    %s (%s nodes done)\n", job.Status(), job.Progress())
+	fmt.Printf("Created:   %s\n", job.CreatedAt.Format(time.RFC3339))
+	fmt.Printf("Updated:   %s\n\n", job.UpdatedAt.Format(time.RFC3339))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NODE\tHOST ID\tROLE\tSTATUS\tUPDATED\tERROR")
+	for _, host := range job.Hosts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", host.IP, host.HostID, host.Role, host.Status,
+			host.UpdatedAt.Format(time.RFC3339), host.Error)
+	}
+	w.Flush()
+}
+
+func jobsResumeRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running jobs resume==========")
+	requireWritable("jobs resume")
+
+	job, err := jobs.Load(args[0])
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	// The job is read again once locked, the pf9ctl which held it may have
+	// changed it
+	if _, err := jobs.Lock(job.ID); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if job, err = jobs.Load(job.ID); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if job.Status() == jobs.Done {
+		fmt.Printf("Job %s is already done\n", job.ID)
+		return
+	}
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: jobsResumeMFA}
+	if cmd.Flags().Changed("no-prompt") {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Unable to obtain keystone credentials: %s", err.Error())
+	}
+	requireRole(auth, job.Operation)
+
+	// The jobs of the nodes of another region are resumed against that region
+	if job.Fqdn != "" && job.Fqdn != cfg.Fqdn {
+		rc, err := client.NewRegions(*cfg, c, auth).GetByFqdn(job.Fqdn)
+		if err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+		c, auth = rc.Client, rc.Auth
+	}
+
+	fmt.Printf("Resuming %s job %s on %d node(s)\n", job.Operation, job.ID, len(job.Remaining()))
+	if err := pmk.RunJob(c, auth, job); err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	fmt.Printf("Job %s done\n", job.ID)
+
+	zap.S().Debug("==========Finished running jobs resume==========")
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/ssh"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Fetches logs of nodes and clusters for debugging",
+	Long:  "Fetches the logs of the Platform9 services running on a node or the convergence status of a cluster",
+}
+
+var logsNodeCmd = &cobra.Command{
+	Use:     "node",
+	Short:   "Prints the logs of a Platform9 service on a node",
+	Long:    "Prints the journal of the hostagent, nodelet or kubelet service running on the node. Runs on this machine unless --ip is given",
+	Example: "pf9ctl logs node --ip 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --component nodelet --since 30m",
+	Run:     logsNodeRun,
+}
+
+var logsClusterCmd = &cobra.Command{
+	Use:     "cluster <name>",
+	Short:   "Prints the convergence status of a cluster and its nodes",
+	Args:    cobra.ExactArgs(1),
+	Example: "pf9ctl logs cluster my-cluster",
+	Run:     logsClusterRun,
+}
+
+var (
+	logsConfig    objects.NodeConfig
+	logsComponent string
+	logsSince     time.Duration
+	logsFollow    bool
+	logsMFA       string
+)
+
+func init() {
+	logsNodeCmd.Flags().StringVarP(&logsConfig.User, "user", "u", "", "ssh username for the node")
+	logsNodeCmd.Flags().StringVarP(&logsConfig.Password, "password", "p", "", "ssh password for the node (use 'single quotes' to pass password)")
+	logsNodeCmd.Flags().StringVarP(&logsConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the node")
+	logsNodeCmd.Flags().StringSliceVarP(&logsConfig.IPs, "ip", "i", []string{}, "IP address of the host")
+	logsNodeCmd.Flags().StringVar(&logsConfig.MFA, "mfa", "", "MFA token")
+	logsNodeCmd.Flags().StringVarP(&logsConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	logsNodeCmd.Flags().StringVar(&logsComponent, "component", "hostagent", fmt.Sprintf("service to print the logs of (%s)", strings.Join(pmk.LogComponents(), "|")))
+	logsNodeCmd.Flags().DurationVar(&logsSince, "since", time.Hour, "print the logs written within this duration")
+	logsNodeCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "keep printing new log entries")
+	logsClusterCmd.Flags().StringVar(&logsMFA, "mfa", "", "MFA token")
+
+	logsClusterCmd.ValidArgsFunction = completeClusterNames
+	logsNodeCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
+	logsCmd.AddCommand(logsNodeCmd)
+	logsCmd.AddCommand(logsClusterCmd)
+	rootCmd.AddCommand(logsCmd)
+}
+
+func logsNodeRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running logs node==========")
+
+	command, err := pmk.NodeLogCommand(logsComponent, logsSince, logsFollow)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if len(logsConfig.IPs) > 1 {
+		zap.S().Fatalf("Logs can be fetched from a single node, use 'pf9ctl run' for several nodes")
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+
+	if err := cmdexec.CheckLocal(logsConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if cmdexec.CheckRemote(logsConfig) {
+		if !config.ValidateNodeConfig(&logsConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: logsConfig.MFA}
+	if detachedMode {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, logsConfig)
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, logsConfig)
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	ssh.SudoPassword = logsConfig.SudoPassword
+	executor, err := cmdexec.GetExecutor(cfg.ProxyURL, logsConfig)
+	if err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+
+	if err := cmdexec.RunWithStream(executor, os.Stdout, command); err != nil {
+		zap.S().Fatalf("Unable to fetch the %s logs: %s", logsComponent, cmdexec.ConfidentialInfoRemover(err.Error()))
+	}
+
+	zap.S().Debug("==========Finished running logs node==========")
+}
+
+func logsClusterRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running logs cluster==========")
+	clusterName := args[0]
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: logsMFA}
+	var err error
+	if cmd.Flags().Changed("no-prompt") {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Unable to obtain keystone credentials: %s", err.Error())
+	}
+
+	exists, clusterUuid, _, err := c.Qbert.CheckClusterExists(clusterName, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to check if the cluster exists: %s", err.Error())
+	}
+	if !exists {
+		zap.S().Fatalf("Cluster %s does not exist", clusterName)
+	}
+
+	cluster, err := c.Qbert.GetCluster(clusterUuid, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to get the cluster: %s", err.Error())
+	}
+
+	fmt.Printf("Cluster:      %s (%s)\n", cluster.Name, cluster.Uuid)
+	fmt.Printf("Status:       %s\n", cluster.Status)
+	fmt.Printf("Task status:  %s\n", cluster.TaskStatus)
+	fmt.Printf("Last op:      %s\n", cluster.LastOp)
+	fmt.Printf("Last ok:      %s\n", cluster.LastOk)
+	fmt.Printf("Kube version: %s\n", cluster.KubeRoleVersion)
+	if cluster.TaskError != "" {
+		fmt.Printf("Task error:   %s\n", cluster.TaskError)
+	}
+	fmt.Println()
+
+	nodes := pmk.ClusterNodes(c.Qbert.GetAllNodes(auth.Token, auth.ProjectID), clusterUuid)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NODE\tIP\tROLE\tSTATUS\tAPI RESPONDING")
+	for _, node := range nodes {
+		role := "worker"
+		if node.IsMaster == 1 {
+			role = "master"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\n", node.Name, node.PrimaryIp, role, node.Status, node.ApiResponding == 1)
+	}
+	w.Flush()
+
+	zap.S().Debug("==========Finished running logs cluster==========")
+}
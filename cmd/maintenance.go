@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var nodeCmd = &cobra.Command{
+	Use:   "node",
+	Short: "Manages onboarded nodes",
+}
+
+var nodeMaintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Puts a node in maintenance for an OS patch window and restores it",
+	Long: `With --start, cordons and drains the node when it is attached to a cluster, then stops
+	and disables the Platform9 services so the node can be patched and rebooted. With --end,
+	starts the services again, waits for the node to converge and uncordons it.
+	Runs on this machine unless --ip is given.`,
+	Example: `pf9ctl node maintenance --ip 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --start
+	pf9ctl node maintenance --ip 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --end`,
+	Args: cobra.NoArgs,
+	Run:  nodeMaintenanceRun,
+}
+
+var (
+	maintenanceConfig objects.NodeConfig
+	maintenanceStart  bool
+	maintenanceEnd    bool
+)
+
+func init() {
+	nodeMaintenanceCmd.Flags().StringVarP(&maintenanceConfig.User, "user", "u", "", "ssh username for the node")
+	nodeMaintenanceCmd.Flags().StringVarP(&maintenanceConfig.Password, "password", "p", "", "ssh password for the node (use 'single quotes' to pass password)")
+	nodeMaintenanceCmd.Flags().StringVarP(&maintenanceConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the node")
+	nodeMaintenanceCmd.Flags().StringSliceVarP(&maintenanceConfig.IPs, "ip", "i", []string{}, "IP address of the node")
+	nodeMaintenanceCmd.Flags().StringVarP(&maintenanceConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	nodeMaintenanceCmd.Flags().StringVar(&maintenanceConfig.MFA, "mfa", "", "MFA token")
+	nodeMaintenanceCmd.Flags().BoolVar(&maintenanceStart, "start", false, "drain the node and stop the Platform9 services")
+	nodeMaintenanceCmd.Flags().BoolVar(&maintenanceEnd, "end", false, "start the Platform9 services and uncordon the node")
+	nodeMaintenanceCmd.Flags().DurationVar(&pmk.MaintenanceTimeout, "timeout", pmk.MaintenanceTimeout, "how long to wait for the node to drain or to converge")
+	nodeMaintenanceCmd.RegisterFlagCompletionFunc("ip", completeNodeIPsIn(true))
+	nodeCmd.AddCommand(nodeMaintenanceCmd)
+	rootCmd.AddCommand(nodeCmd)
+}
+
+func nodeMaintenanceRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running node maintenance==========")
+	requireWritable("node maintenance")
+
+	if maintenanceStart == maintenanceEnd {
+		zap.S().Fatal("Either --start or --end is required")
+	}
+	if len(maintenanceConfig.IPs) > 1 {
+		zap.S().Fatal("Only one node can be put in maintenance at a time")
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if err := cmdexec.CheckLocal(maintenanceConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	isRemote := cmdexec.CheckRemote(maintenanceConfig)
+	if isRemote {
+		if !config.ValidateNodeConfig(&maintenanceConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	cfg, c, auth := loadClient(cmd, maintenanceConfig.MFA)
+	defer c.Segment.Close()
+	requireRole(auth, "node maintenance")
+
+	executor, err := cmdexec.GetExecutor(cfg.ProxyURL, maintenanceConfig)
+	if err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	if isRemote {
+		if err := SudoPasswordCheck(executor, detachedMode, maintenanceConfig.SudoPassword); err != nil {
+			zap.S().Fatal("Failed executing commands on remote machine with sudo: ", err.Error())
+		}
+	}
+	c.Executor = executor
+
+	var ip string
+	if isRemote {
+		ip = maintenanceConfig.IPs[0]
+	}
+	if maintenanceStart {
+		err = pmk.StartMaintenance(c, auth, cfg.Fqdn, ip)
+	} else {
+		err = pmk.EndMaintenance(c, auth, cfg.Fqdn, ip)
+	}
+	if err != nil {
+		zap.S().Fatalf("%s", fmt.Sprintf("Node maintenance failed: %s", err.Error()))
+	}
+
+	zap.S().Debug("==========Finished running node maintenance==========")
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var migrateNodeCmd = &cobra.Command{
+	Use:   "migrate-node",
+	Short: "Moves a node from the DU of the config to the DU of a profile",
+	Long: `Moves a node to another DU in a single supervised flow: the node is drained from its cluster,
+	decommissioned from the DU of the config, prepared with the DU of the profile and attached to
+	--cluster on it. The profile is a bundle of 'pf9ctl config export', given by its path or by its
+	name in ~/pf9/db/profiles. Every step done is recorded, running migrate-node again resumes an
+	interrupted migration. Use --dry-run to only show the plan.`,
+	Example: `pf9ctl config export -o ~/pf9/db/profiles/newdu.json
+	pf9ctl migrate-node --ip 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --to-profile newdu --dry-run
+	pf9ctl migrate-node --ip 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --to-profile newdu --cluster prod`,
+	Args: cobra.NoArgs,
+	Run:  migrateNodeRun,
+}
+
+var (
+	migrateConfig  objects.NodeConfig
+	migrateIP      string
+	migrateProfile string
+	migrateCluster string
+	migrateRole    string
+	migrateDryRun  bool
+	migrateRestart bool
+)
+
+func init() {
+	migrateNodeCmd.Flags().StringVarP(&migrateConfig.User, "user", "u", "", "ssh username for the node")
+	migrateNodeCmd.Flags().StringVarP(&migrateConfig.Password, "password", "p", "", "ssh password for the node (use 'single quotes' to pass password)")
+	migrateNodeCmd.Flags().StringVarP(&migrateConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the node")
+	migrateNodeCmd.Flags().StringVarP(&migrateIP, "ip", "i", "", "IP address of the node")
+	migrateNodeCmd.Flags().StringVarP(&migrateConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	migrateNodeCmd.Flags().StringVar(&migrateConfig.MFA, "mfa", "", "MFA token")
+	migrateNodeCmd.Flags().StringVar(&migrateProfile, "to-profile", "", "Profile bundle of the DU the node moves to, its path or its name in ~/pf9/db/profiles")
+	migrateNodeCmd.Flags().BoolVar(&bundlePassphraseStdin, "passphrase-stdin", false, "Read the passphrase of an encrypted profile from stdin")
+	migrateNodeCmd.Flags().StringVar(&migrateCluster, "cluster", "", "Cluster of the new DU the node is attached to (default the node is only prepared)")
+	migrateNodeCmd.Flags().StringVar(&migrateRole, "role", "", "Role the node is attached to --cluster with, master or worker (default worker)")
+	migrateNodeCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Show the plan of the migration without running it")
+	migrateNodeCmd.Flags().BoolVar(&migrateRestart, "restart", false, "Discard the recorded progress of an interrupted migration of the node and plan it again")
+	migrateNodeCmd.Flags().BoolVarP(&skipChecks, "skip-checks", "c", false, "Will skip optional checks if true")
+	migrateNodeCmd.Flags().BoolVar(&overrideProtected, overrideProtectedFlag, false, "Migrate the node even when it is protected: it runs a DU, matches protected_hosts or has a protected marker file")
+	migrateNodeCmd.MarkFlagRequired("ip")
+	migrateNodeCmd.MarkFlagRequired("to-profile")
+	migrateNodeCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
+	rootCmd.AddCommand(migrateNodeCmd)
+}
+
+func migrateNodeRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running migrate-node==========")
+	if !migrateDryRun {
+		requireWritable("migrate-node")
+	}
+	if err := util.ValidateNodeRole(migrateRole); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if migrateRole != "" && migrateCluster == "" {
+		zap.S().Fatalf("--role is the role of the node in --cluster, which is missing")
+	}
+	if skipChecks {
+		pmk.WarningOptionalChecks = true
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	nodeCfg := migrateConfig
+	nodeCfg.IPs = []string{migrateIP}
+	isRemote := cmdexec.CheckRemote(nodeCfg)
+	if isRemote {
+		if !config.ValidateNodeConfig(&nodeCfg, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: migrateConfig.MFA}
+	var err error
+	if detachedMode {
+		nodeCfg.RemoveExistingPkgs = true
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, nodeCfg)
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, nodeCfg)
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+	target := loadMigrationProfile(migrateProfile)
+	refuseProtectedHosts(cfg, nodeCfg, "migrate-node")
+	refuseProtectedHosts(target, nodeCfg, "migrate-node")
+
+	c, auth := migrationClient(cfg, nodeCfg)
+	defer c.Segment.Close()
+	defer cmdexec.Close(c.Executor)
+	tc, tauth := migrationClient(target, nodeCfg)
+	defer tc.Segment.Close()
+	defer cmdexec.Close(tc.Executor)
+	requireRole(auth, "migrate-node")
+	requireRole(tauth, "migrate-node")
+
+	m, err := pmk.LoadMigration(migrateIP)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if m != nil && migrateRestart {
+		m = nil
+	}
+	if m != nil && !m.Matches(target.Fqdn, migrateCluster) {
+		zap.S().Fatalf("Node %s has an interrupted migration to %s, resume it with the same profile and cluster or discard it with --restart",
+			migrateIP, pmk.DUHost(m.To))
+	}
+	if m == nil {
+		if migrateCluster != "" {
+			if _, uuid, _, err := tc.Qbert.CheckClusterExists(migrateCluster, tauth.ProjectID, tauth.Token); err != nil || uuid == "" {
+				zap.S().Fatalf("Cluster %s doesn't exist on %s: %v", migrateCluster, pmk.DUHost(target.Fqdn), err)
+			}
+		}
+		if m, err = pmk.PlanMigration(c, auth, migrateIP, cfg.Fqdn, target.Fqdn, migrateProfile, migrateCluster, migrateRole); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+	} else {
+		fmt.Printf("Resuming the migration of node %s started %s\n", migrateIP, m.CreatedAt.Format(time.RFC1123))
+	}
+
+	fmt.Printf("Migration of node %s from %s to %s:\n", migrateIP, pmk.DUHost(m.From), pmk.DUHost(m.To))
+	for i, step := range m.Steps {
+		line := fmt.Sprintf("  %d. %s", i+1, m.Describe(step))
+		if m.IsDone(step) {
+			line += " (done)"
+		}
+		fmt.Println(line)
+	}
+	if migrateDryRun {
+		return
+	}
+	if !detachedMode && !confirmMigration() {
+		exit(0)
+	}
+	if err := m.Save(); err != nil {
+		zap.S().Fatalf("Unable to record the migration: %s", err.Error())
+	}
+
+	if isRemote {
+		if err := SudoPasswordCheck(tc.Executor, detachedMode, nodeCfg.SudoPassword); err != nil {
+			zap.S().Fatal("Failed executing commands on remote machine with sudo: ", err.Error())
+		}
+	}
+	for _, step := range m.Steps {
+		if m.IsDone(step) {
+			continue
+		}
+		switch step {
+		case pmk.MigrateDrain:
+			err = pmk.DrainNode(auth, cfg.Fqdn, m.FromCluster, m.FromClusterUuid, migrateIP)
+		case pmk.MigrateDecommission:
+			err = pmk.DecommissionNode(cfg, nodeCfg, true)
+		case pmk.MigratePrep:
+			runPrepNode(target, tc, tauth, nodeCfg, isRemote, detachedMode)
+		case pmk.MigrateAttach:
+			err = attachMigrated(tc, tauth, target, m)
+		}
+		if err != nil {
+			zap.S().Fatalf("%s failed: %s. Run migrate-node again to resume the migration", m.Describe(step), err.Error())
+		}
+		if err := m.Complete(step); err != nil {
+			zap.S().Fatalf("Unable to record the migration: %s", err.Error())
+		}
+		fmt.Println(color.Green("✓ ") + m.Describe(step))
+	}
+	if err := m.Delete(); err != nil {
+		zap.S().Debugf("%s", err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + fmt.Sprintf("Node %s migrated to %s", migrateIP, pmk.DUHost(m.To)))
+
+	zap.S().Debug("==========Finished running migrate-node==========")
+}
+
+// loadMigrationProfile reads the config of the DU of the profile bundle, which
+// needs the credentials of the DU
+func loadMigrationProfile(profile string) *objects.Config {
+	target := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false}
+	if err := loadProfileBundle(pmk.ProfileFile(profile)).Apply(target, true); err != nil {
+		zap.S().Fatal(color.Red("x "), err)
+	}
+	if target.Fqdn == "" {
+		zap.S().Fatalf("The profile %s has no fqdn", profile)
+	}
+	if target.Password == "" && target.ApplicationCredentialID == "" {
+		zap.S().Fatalf("The profile %s has no credentials, export it without --redact-secrets", profile)
+	}
+	return target
+}
+
+// migrationClient returns the client of the DU of cfg, reaching the node of
+// nodeCfg, and authenticates with the DU
+func migrationClient(cfg *objects.Config, nodeCfg objects.NodeConfig) (client.Client, keystone.KeystoneAuth) {
+	executor, err := cmdexec.GetExecutor(cfg.ProxyURL, nodeCfg)
+	if err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	c, err := client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false)
+	if err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	auth, err := keystone.Authenticate(c.Keystone, *cfg)
+	if err != nil {
+		c.Segment.Close()
+		zap.S().Fatalf("Unable to obtain keystone credentials of %s: %s", pmk.DUHost(cfg.Fqdn), err.Error())
+	}
+	return c, auth
+}
+
+// attachMigrated attaches the migrated node to its cluster on the new DU and
+// waits for it to converge
+func attachMigrated(c client.Client, auth keystone.KeystoneAuth, cfg *objects.Config, m *pmk.Migration) error {
+	in := pmk.AttachNodesInput{ClusterName: m.ToCluster, Region: cfg.Region}
+	if m.Role == "master" {
+		in.MasterIPs = []string{m.IP}
+	} else {
+		in.WorkerIPs = []string{m.IP}
+	}
+	job, err := pmk.NewAttachJob(context.Background(), c, auth, cfg.Fqdn, in)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started job %s, resume it with 'pf9ctl jobs resume %s' if interrupted\n", job.ID, job.ID)
+	if err := pmk.RunJob(c, auth, job); err != nil {
+		return err
+	}
+	return pmk.WaitForConvergence(c, auth, []string{m.IP}, []string{job.Hosts[0].HostID}, pmk.ConvergeTimeout)
+}
+
+func confirmMigration() bool {
+	fmt.Println()
+	answer, err := util.AskBool("The node is removed from its current DU. Do you want to continue?")
+	return err == nil && answer
+}
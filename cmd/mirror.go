@@ -0,0 +1,112 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/interrupt"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/ui"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror",
+	Short: "Builds the package repos of disconnected installs",
+}
+
+var mirrorCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Builds a local apt or yum repo of the packages prep-node installs",
+	Long: `Downloads the packages the checks of prep-node and the installer fetch on the
+	nodes of an OS, with their dependencies, and builds an apt or yum repo of them in the
+	output directory. The packages are downloaded in a container of the OS, so docker or
+	podman is needed. The repo is signed with a key pf9ctl generates on the first run, and
+	its public key is written in the repo as pf9ctl-mirror.asc. Serve the directory over
+	HTTP, or copy it to the nodes, and prepare them with --package-repo and --package-repo-key,
+	the key being a copy of pf9ctl-mirror.asc taken from this machine, not the repo.`,
+	Example: `pf9ctl mirror create --os ubuntu22 --output ./repo
+	pf9ctl prep-node -i 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --package-repo http://10.0.0.5/repo --package-repo-key ./repo/pf9ctl-mirror.asc`,
+	Args: cobra.NoArgs,
+	Run:  mirrorCreateRun,
+}
+
+var (
+	mirrorOS     string
+	mirrorOutput string
+)
+
+func init() {
+	mirrorCreateCmd.Flags().StringVar(&mirrorOS, "os", "", "OS of the nodes the repo is for: "+strings.Join(pmk.MirrorOSNames(), ", "))
+	mirrorCreateCmd.Flags().StringVarP(&mirrorOutput, "output", "o", "repo", "Directory the repo is built in")
+	mirrorCreateCmd.MarkFlagRequired("os")
+	mirrorCreateCmd.RegisterFlagCompletionFunc("os", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return pmk.MirrorOSNames(), cobra.ShellCompDirectiveNoFileComp
+	})
+	mirrorCmd.AddCommand(mirrorCreateCmd)
+	rootCmd.AddCommand(mirrorCmd)
+}
+
+func mirrorCreateRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running mirror create==========")
+
+	dir, err := filepath.Abs(mirrorOutput)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		zap.S().Fatalf("Unable to create the repo directory: %s", err.Error())
+	}
+	// The container writes the packages as root, they are given back to the
+	// user running pf9ctl
+	owner := ""
+	if uid := os.Getuid(); uid > 0 {
+		owner = fmt.Sprintf("%d:%d", uid, os.Getgid())
+	}
+
+	phase := ui.StartPhase(fmt.Sprintf("Downloading the packages of %s", mirrorOS))
+	missing, err := pmk.CreateMirror(cmdexec.LocalExecutor{}, mirrorOS, dir, owner, util.Pf9MirrorKeyLoc)
+	if err != nil {
+		phase.Fail(fmt.Sprintf("Unable to build the package repo of %s", mirrorOS))
+		zap.S().Fatalf("%s", err.Error())
+	}
+	phase.Succeed(fmt.Sprintf("Built the package repo of %s in %s", mirrorOS, dir))
+	for _, p := range missing {
+		fmt.Println(color.Yellow("! ") + fmt.Sprintf("Package %s isn't available for %s, the nodes need it from another repo if they use it", p, mirrorOS))
+	}
+	fmt.Println("Serve the directory over HTTP or copy it to the nodes, and prepare them with --package-repo and --package-repo-key " + filepath.Join(dir, pmk.MirrorKeyName))
+
+	zap.S().Debug("==========Finished running mirror create==========")
+}
+
+// addPackageRepo adds the package repo of the config to the node, if any, and
+// returns the function removing it
+func addPackageRepo(cfg *objects.Config, exec cmdexec.Executor) func() {
+	if cfg.PackageRepo == "" {
+		return func() {}
+	}
+	// The key is never fetched from the repo, which it would then not protect
+	if cfg.PackageRepoKey == "" {
+		zap.S().Fatalf("The package repo %s needs its public key, set it with --package-repo-key or the package_repo_key of the config", cfg.PackageRepo)
+	}
+	if err := pmk.ConfigurePackageRepo(exec, cfg.PackageRepo, cfg.PackageRepoKey); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + fmt.Sprintf("Added the package repo %s to the node", cfg.PackageRepo))
+	return interrupt.Defer(func() {
+		if err := pmk.RemovePackageRepo(exec); err != nil {
+			fmt.Println(color.Yellow("! ") + err.Error())
+			return
+		}
+		zap.S().Debugf("Removed the package repo %s from the node", cfg.PackageRepo)
+	})
+}
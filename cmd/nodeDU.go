@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var nodeUpdateDUCmd = &cobra.Command{
+	Use:   "update-du",
+	Short: "Points the pf9 services of onboarded nodes to the DU of the config",
+	Long: `Updates the DU pf9-comms and the hostagent of onboarded nodes connect to, e.g: after the
+	FQDN of the DU changed, and optionally the AMQP credentials of the hostagent, without
+	reinstalling them. The files of /etc/pf9 mentioning the former DU are updated and the services
+	restarted, the workloads of the nodes keep running. The nodes are then expected to reconnect
+	to the DU of the config, their previous configuration is restored when they don't.`,
+	Example: `pf9ctl node update-du --ip 10.0.0.1 --ip 10.0.0.2 -u ubuntu -s ~/.ssh/id_rsa
+	pf9ctl node update-du --ip 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --from old-du.example.com --amqp-password-stdin < amqp.pass`,
+	Args: cobra.NoArgs,
+	Run:  nodeUpdateDURun,
+}
+
+var (
+	updateDUConfig        objects.NodeConfig
+	updateDUFrom          string
+	updateDUAMQPUser      string
+	updateDUPasswordStdin bool
+)
+
+func init() {
+	nodeUpdateDUCmd.Flags().StringVarP(&updateDUConfig.User, "user", "u", "", "ssh username for the nodes")
+	nodeUpdateDUCmd.Flags().StringVarP(&updateDUConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	nodeUpdateDUCmd.Flags().StringVarP(&updateDUConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	nodeUpdateDUCmd.Flags().StringSliceVarP(&updateDUConfig.IPs, "ip", "i", []string{}, "IP address of the nodes")
+	nodeUpdateDUCmd.Flags().StringVarP(&updateDUConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	nodeUpdateDUCmd.Flags().StringVar(&updateDUConfig.MFA, "mfa", "", "MFA token")
+	nodeUpdateDUCmd.Flags().StringVar(&updateDUFrom, "from", "", "DU the nodes connect to now (default read from the hostagent configuration of the nodes)")
+	nodeUpdateDUCmd.Flags().StringVar(&updateDUAMQPUser, "amqp-user", "", "AMQP username of the hostagent (default kept)")
+	nodeUpdateDUCmd.Flags().BoolVar(&updateDUPasswordStdin, "amqp-password-stdin", false, "Read the AMQP password of the hostagent from stdin (default kept)")
+	nodeUpdateDUCmd.Flags().DurationVar(&pmk.NodeDUTimeout, "timeout", pmk.NodeDUTimeout, "How long the nodes have to reconnect before their previous configuration is restored")
+	nodeUpdateDUCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
+	nodeCmd.AddCommand(nodeUpdateDUCmd)
+}
+
+func nodeUpdateDURun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running node update-du==========")
+	requireWritable("node update-du")
+
+	var amqpPassword string
+	if updateDUPasswordStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			zap.S().Fatalf("Unable to read the AMQP password from stdin: %s", err.Error())
+		}
+		if amqpPassword = strings.TrimRight(line, "\r\n"); amqpPassword == "" {
+			zap.S().Fatal("The AMQP password read from stdin is empty")
+		}
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if err := cmdexec.CheckLocal(updateDUConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	isRemote := cmdexec.CheckRemote(updateDUConfig)
+	if isRemote {
+		if !config.ValidateNodeConfig(&updateDUConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	cfg, c, auth := loadClient(cmd, updateDUConfig.MFA)
+	defer c.Segment.Close()
+
+	regionURL, err := keystone.FetchRegionFQDN(cfg.Fqdn, cfg.Region, auth)
+	if err != nil {
+		zap.S().Fatalf("Unable to fetch the URL of the region: %s", err.Error())
+	}
+	via := pmk.WatchdogHost(regionURL, cfg.ProxyURL)
+
+	ips := updateDUConfig.IPs
+	if len(ips) == 0 {
+		ips = []string{"localhost"}
+	}
+
+	failed := 0
+	for _, ip := range ips {
+		nodeCfg := updateDUConfig
+		nodeCfg.IPs = []string{ip}
+		update := pmk.NodeDUUpdate{From: updateDUFrom, To: regionURL, Via: via, AMQPUser: updateDUAMQPUser, AMQPPassword: amqpPassword}
+		executor, err := cmdexec.GetExecutor(cfg.ProxyURL, nodeCfg)
+		if err == nil && isRemote {
+			err = SudoPasswordCheck(executor, detachedMode, nodeCfg.SudoPassword)
+		}
+		if err == nil && update.From == "" {
+			update.From, err = pmk.NodeDU(executor)
+		}
+		if err == nil && update.From != "" && !update.Changes() {
+			fmt.Println(color.Green("✓ ") + fmt.Sprintf("Node %s already connects to %s", ip, pmk.DUHost(regionURL)))
+			continue
+		}
+		if err == nil {
+			err = pmk.UpdateNodeDU(c, auth, executor, update)
+		}
+		if err != nil {
+			failed++
+			fmt.Println(color.Red("x ") + fmt.Sprintf("Unable to update the DU of node %s: %s", ip, err))
+			continue
+		}
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("Node %s reconnected to %s", ip, pmk.DUHost(regionURL)))
+	}
+	if failed > 0 {
+		zap.S().Fatalf("Unable to update the DU of %d node(s)", failed)
+	}
+
+	zap.S().Debug("==========Finished running node update-du==========")
+}
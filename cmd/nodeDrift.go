@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var nodeDriftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Reports how onboarded nodes drifted from the state prep-node left them in",
+	Long: `Compares the kernel settings of the role, swap, the Platform9 services, the firewall rules
+	added by configure-firewall, the proxy of the pf9 services and the pf9 packages of onboarded
+	nodes with the state expected after prep-node. The proxy is expected to be the one of the config
+	unless --proxy is given, the pf9-kube version the one of the cluster of the node. Use --fix to
+	repair the kernel settings, swap, services and proxy. Exits with an error when drift is left.`,
+	Example: `pf9ctl node drift --ip 10.0.0.1 --ip 10.0.0.2 -u ubuntu -s ~/.ssh/id_rsa
+	pf9ctl node drift --ip 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --role worker --fix`,
+	Args: cobra.NoArgs,
+	Run:  nodeDriftRun,
+}
+
+var (
+	driftConfig   objects.NodeConfig
+	driftRole     string
+	driftProxyURL string
+	driftNoProxy  string
+	driftFix      bool
+)
+
+func init() {
+	nodeDriftCmd.Flags().StringVarP(&driftConfig.User, "user", "u", "", "ssh username for the nodes")
+	nodeDriftCmd.Flags().StringVarP(&driftConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	nodeDriftCmd.Flags().StringVarP(&driftConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	nodeDriftCmd.Flags().StringSliceVarP(&driftConfig.IPs, "ip", "i", []string{}, "IP address of the nodes")
+	nodeDriftCmd.Flags().StringVarP(&driftConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	nodeDriftCmd.Flags().StringVar(&driftConfig.MFA, "mfa", "", "MFA token")
+	nodeDriftCmd.Flags().StringVar(&driftRole, "role", "", "Role the nodes were prepared for, master or worker (default read from the kernel settings prep-node applied)")
+	nodeDriftCmd.Flags().StringVar(&driftProxyURL, "proxy", "", "proxy URL the pf9 services are expected to go through (default the proxy of the config)")
+	nodeDriftCmd.Flags().StringVar(&driftNoProxy, "no-proxy", "", "comma separated list of hosts the services reach directly, set when --fix updates the proxy")
+	nodeDriftCmd.Flags().BoolVar(&driftFix, "fix", false, "repair the drift which can be")
+	nodeDriftCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
+	nodeCmd.AddCommand(nodeDriftCmd)
+}
+
+func nodeDriftRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running node drift==========")
+	if driftFix {
+		requireWritable("node drift --fix")
+	}
+
+	if err := util.ValidateNodeRole(driftRole); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if err := cmdexec.CheckLocal(driftConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	isRemote := cmdexec.CheckRemote(driftConfig)
+	if isRemote {
+		if !config.ValidateNodeConfig(&driftConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	cfg, c, auth := loadClient(cmd, driftConfig.MFA)
+	defer c.Segment.Close()
+
+	proxyURL := driftProxyURL
+	if proxyURL == "" {
+		proxyURL = cfg.ProxyURL
+	}
+	var proxy *pmk.NodeProxy
+	if proxyURL != "" {
+		parsed, err := pmk.ParseNodeProxy(proxyURL, driftNoProxy)
+		if err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+		proxy = &parsed
+	}
+
+	ips := driftConfig.IPs
+	if len(ips) == 0 {
+		ips = []string{"localhost"}
+	}
+
+	drifted := 0
+	for _, ip := range ips {
+		nodeCfg := driftConfig
+		nodeCfg.IPs = []string{ip}
+		executor, err := cmdexec.GetExecutor(cfg.ProxyURL, nodeCfg)
+		if err == nil && isRemote {
+			err = SudoPasswordCheck(executor, detachedMode, nodeCfg.SudoPassword)
+		}
+		var drifts []pmk.Drift
+		if err == nil {
+			baseline := pmk.NewDriftBaseline(c, auth, executor, driftRole, proxy)
+			drifts, err = pmk.DetectDrift(executor, baseline)
+			if err == nil && driftFix && len(drifts) > 0 {
+				for _, fixErr := range pmk.FixDrift(executor, drifts) {
+					fmt.Println(color.Red("x ") + fmt.Sprintf("Node %s: %s", ip, fixErr))
+				}
+				drifts, err = pmk.DetectDrift(executor, baseline)
+			}
+		}
+		if err != nil {
+			drifted++
+			fmt.Println(color.Red("x ") + fmt.Sprintf("Unable to check node %s: %s", ip, err))
+			continue
+		}
+		if len(drifts) == 0 {
+			fmt.Println(color.Green("✓ ") + fmt.Sprintf("Node %s matches its baseline", ip))
+			continue
+		}
+		drifted++
+		fmt.Println(color.Red("x ") + fmt.Sprintf("Node %s drifted from its baseline:", ip))
+		printDrifts(drifts)
+	}
+	if drifted > 0 {
+		zap.S().Fatalf("%d node(s) drifted from their baseline", drifted)
+	}
+
+	zap.S().Debug("==========Finished running node drift==========")
+}
+
+func printDrifts(drifts []pmk.Drift) {
+	for _, d := range drifts {
+		line := fmt.Sprintf("  [%s] %s", d.Area, d.Message)
+		if d.Fixable() && !driftFix {
+			line += " (fixable with --fix)"
+		}
+		fmt.Println(line)
+	}
+}
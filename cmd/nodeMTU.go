@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var nodeMTUCmd = &cobra.Command{
+	Use:   "mtu",
+	Short: "Checks the MTU between nodes and to the DU fits the overlay of the CNI",
+	Long: `Measures the effective MTU between each pair of the candidate nodes and from each node to the DU,
+	with ping packets which must not be fragmented. The MTU between nodes must carry the MTU of the pods
+	with the overlay of the CNI, IP-in-IP for calico and VXLAN for flannel, and the MTU to the DU the MTU
+	of the pods alone. A warning suggests the --mtu-size to pass to 'pf9ctl bootstrap' when a path is
+	too small.`,
+	Example: "pf9ctl node mtu --ip 10.0.0.1,10.0.0.2 -u ubuntu -s ~/.ssh/id_rsa --network-plugin calico --mtu-size 1440",
+	Args:    cobra.NoArgs,
+	Run:     nodeMTURun,
+}
+
+var (
+	mtuConfig          objects.NodeConfig
+	mtuNetworkPlugin   string
+	mtuIPEncapsulation string
+	mtuPodMTU          int
+)
+
+func init() {
+	nodeMTUCmd.Flags().StringVarP(&mtuConfig.User, "user", "u", "", "ssh username for the nodes")
+	nodeMTUCmd.Flags().StringVarP(&mtuConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	nodeMTUCmd.Flags().StringVarP(&mtuConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	nodeMTUCmd.Flags().StringSliceVarP(&mtuConfig.IPs, "ip", "i", []string{}, "IP address of the candidate nodes")
+	nodeMTUCmd.Flags().StringVarP(&mtuConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	nodeMTUCmd.Flags().StringVar(&mtuNetworkPlugin, "network-plugin", util.Calico, "network plugin of the cluster ( Possible values: flannel or calico )")
+	nodeMTUCmd.Flags().StringVar(&mtuIPEncapsulation, "ip-encapsulation", "Always", "IP-in-IP mode of calico ( Possible values: Always, CrossSubnet or Never )")
+	nodeMTUCmd.Flags().IntVar(&mtuPodMTU, "mtu-size", 1440, "MTU of the pods, as passed to 'pf9ctl bootstrap'")
+	nodeMTUCmd.MarkFlagRequired("ip")
+	nodeMTUCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
+	nodeCmd.AddCommand(nodeMTUCmd)
+}
+
+func nodeMTURun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running node mtu==========")
+
+	overhead, overlay, err := pmk.OverlayOverhead(mtuNetworkPlugin, mtuIPEncapsulation)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if !cmdexec.CheckRemote(mtuConfig) {
+		zap.S().Fatalf("The nodes to check are given with --ip")
+	}
+	if !config.ValidateNodeConfig(&mtuConfig, !detachedMode) {
+		zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+	}
+
+	// The DU is only pinged, so the config isn't validated against it
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false}
+	var duHost string
+	if err := config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{}); err == config.NO_CONFIG {
+		fmt.Println(color.Yellow("! ") + "The MTU to the DU isn't checked, there is no config to read it from")
+	} else {
+		if err != nil {
+			fmt.Println(color.Yellow("! ") + fmt.Sprintf("Unable to validate the config: %s", err))
+		}
+		duHost = pmk.DUHost(cfg.Fqdn)
+	}
+
+	report := pmk.MTUReport{PodMTU: mtuPodMTU, Overhead: overhead}
+	for i, ip := range mtuConfig.IPs {
+		nodeCfg := mtuConfig
+		nodeCfg.IPs = []string{ip}
+		executor, err := cmdexec.GetExecutor("", nodeCfg)
+		if err == nil {
+			err = SudoPasswordCheck(executor, detachedMode, nodeCfg.SudoPassword)
+		}
+		if err != nil {
+			zap.S().Fatalf("Unable to connect to node %s: %s", ip, err.Error())
+		}
+		// The path can't carry more than the interface of the node
+		ifaceMTU, err := pmk.InterfaceMTU(executor)
+		if err != nil {
+			zap.S().Debugf("Node %s: %s", ip, err)
+		}
+
+		fmt.Printf("Measuring the MTU from node %s\n", ip)
+		for _, peer := range mtuConfig.IPs[i+1:] {
+			mtu, err := pmk.ProbePathMTU(executor, peer, ifaceMTU)
+			report.Paths = append(report.Paths, pmk.PathMTU{From: ip, To: peer, MTU: mtu, Err: err})
+		}
+		if duHost != "" {
+			mtu, err := pmk.ProbePathMTU(executor, duHost, ifaceMTU)
+			report.Paths = append(report.Paths, pmk.PathMTU{From: ip, To: duHost, ToDU: true, MTU: mtu, Err: err})
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "FROM\tTO\tMTU\tREQUIRED\tSTATUS")
+	for _, path := range report.Paths {
+		to, mtu, status := path.To, "-", "UNKNOWN"
+		if path.ToDU {
+			to += " (DU)"
+		}
+		if path.Err == nil {
+			mtu, status = fmt.Sprint(path.MTU), "OK"
+			if path.MTU < report.Required(path) {
+				status = "TOO SMALL"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", path.From, to, mtu, report.Required(path), status)
+	}
+	w.Flush()
+	fmt.Printf("The pods have an MTU of %d, %s adds %d bytes between nodes\n", mtuPodMTU, overlay, overhead)
+
+	for _, path := range report.Paths {
+		if path.Err != nil {
+			fmt.Println(color.Yellow("! ") + fmt.Sprintf("Unable to measure the MTU from %s to %s: %s", path.From, path.To, path.Err))
+		}
+	}
+	if len(report.Paths) == 0 {
+		fmt.Println(color.Yellow("! ") + "No path was measured, pass the IPs of several nodes")
+	} else if tooSmall := report.TooSmall(); len(tooSmall) > 0 {
+		for _, path := range tooSmall {
+			fmt.Println(color.Yellow("! ") + fmt.Sprintf("The MTU from %s to %s is %d, below the %d it needs", path.From, path.To, path.MTU, report.Required(path)))
+		}
+		fmt.Println(color.Yellow("! ") + fmt.Sprintf("Pass --mtu-size %d to 'pf9ctl bootstrap' to fit the smallest path", report.SuggestedMTU()))
+	} else {
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("The measured paths carry the MTU of %d of the pods", mtuPodMTU))
+	}
+
+	zap.S().Debug("==========Finished running node mtu==========")
+}
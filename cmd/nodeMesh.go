@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var nodeMeshCmd = &cobra.Command{
+	Use:   "mesh",
+	Short: "Checks the candidate nodes of a cluster reach each other on the ports it needs",
+	Long: `Probes from each candidate node the ports it must reach on the others: etcd between masters, the
+	API server of the masters, the kubelet, the VXLAN port, and the BGP port and IP-in-IP of calico. A TCP
+	port refusing the connection is connected to again while the node listens on it with python3, it is
+	rejected when the connection is still refused. The VXLAN port and IP-in-IP are listened on with python3
+	on the node for the datagrams and packets sent by the others, which needs root for IP-in-IP. The
+	reachability is shown as a matrix of the nodes the traffic comes from by the nodes it goes to.`,
+	Example: "pf9ctl node mesh --master-ip 10.0.0.1 --worker-ip 10.0.0.2,10.0.0.3 -u ubuntu -s ~/.ssh/id_rsa",
+	Args:    cobra.NoArgs,
+	Run:     nodeMeshRun,
+}
+
+var (
+	meshConfig        objects.NodeConfig
+	meshMasterIPs     []string
+	meshWorkerIPs     []string
+	meshNetworkPlugin string
+)
+
+func init() {
+	nodeMeshCmd.Flags().StringVarP(&meshConfig.User, "user", "u", "", "ssh username for the nodes")
+	nodeMeshCmd.Flags().StringVarP(&meshConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	nodeMeshCmd.Flags().StringVarP(&meshConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	nodeMeshCmd.Flags().StringVarP(&meshConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	nodeMeshCmd.Flags().StringSliceVarP(&meshMasterIPs, "master-ip", "m", []string{}, "IP address of the candidate masters")
+	nodeMeshCmd.Flags().StringSliceVarP(&meshWorkerIPs, "worker-ip", "w", []string{}, "IP address of the candidate workers")
+	nodeMeshCmd.Flags().StringVar(&meshNetworkPlugin, "network-plugin", util.Calico, "network plugin of the cluster ( Possible values: flannel or calico )")
+	nodeMeshCmd.RegisterFlagCompletionFunc("master-ip", completeNodeIPs)
+	nodeMeshCmd.RegisterFlagCompletionFunc("worker-ip", completeNodeIPs)
+	nodeCmd.AddCommand(nodeMeshCmd)
+}
+
+func nodeMeshRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running node mesh==========")
+
+	if meshNetworkPlugin != string(pmk.Calico) && meshNetworkPlugin != string(pmk.Flannel) {
+		zap.S().Fatalf("--network-plugin is either calico or flannel")
+	}
+	if len(meshMasterIPs)+len(meshWorkerIPs) < 2 {
+		zap.S().Fatalf("The mesh needs at least 2 nodes, given with --master-ip and --worker-ip")
+	}
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	meshConfig.IPs = append(append([]string{}, meshMasterIPs...), meshWorkerIPs...)
+	if !config.ValidateNodeConfig(&meshConfig, !detachedMode) {
+		zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+	}
+
+	nodes, err := meshNodes(meshConfig, meshMasterIPs, meshWorkerIPs, detachedMode)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if blocked := runMeshTest(nodes, meshNetworkPlugin); blocked > 0 {
+		zap.S().Fatalf("%d port(s) are blocked between the nodes", blocked)
+	}
+
+	zap.S().Debug("==========Finished running node mesh==========")
+}
+
+// meshNodes connects to the masters and workers of the mesh test with the
+// credentials of nodeCfg
+func meshNodes(nodeCfg objects.NodeConfig, masterIPs, workerIPs []string, detachedMode bool) ([]pmk.MeshNode, error) {
+	var nodes []pmk.MeshNode
+	add := func(ips []string, role string) error {
+		for _, ip := range ips {
+			nodeCfg.IPs = []string{ip}
+			// The probes run on the node without a proxy
+			executor, err := cmdexec.GetExecutor("", nodeCfg)
+			if err == nil {
+				err = SudoPasswordCheck(executor, detachedMode, nodeCfg.SudoPassword)
+			}
+			if err != nil {
+				return fmt.Errorf("Unable to connect to node %s: %w", ip, err)
+			}
+			nodes = append(nodes, pmk.MeshNode{IP: ip, Role: role, Exec: executor})
+		}
+		return nil
+	}
+	if err := add(masterIPs, util.RoleMaster); err != nil {
+		return nil, err
+	}
+	if err := add(workerIPs, util.RoleWorker); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// runMeshTest probes the ports between the nodes and prints the reachability
+// matrix, returning how many ports are blocked
+func runMeshTest(nodes []pmk.MeshNode, plugin string) int {
+	fmt.Printf("Probing the ports between %d nodes\n", len(nodes))
+	probes := pmk.RunMesh(nodes, plugin)
+
+	var ips []string
+	for _, node := range nodes {
+		ips = append(ips, node.IP)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprint(w, "FROM \\ TO")
+	for _, node := range nodes {
+		fmt.Fprintf(w, "\t%s (%s)", node.IP, node.Role)
+	}
+	fmt.Fprintln(w)
+	for i, row := range pmk.MeshMatrix(ips, probes) {
+		fmt.Fprint(w, ips[i])
+		for _, cell := range row {
+			fmt.Fprint(w, "\t"+cell)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	for _, p := range probes {
+		if p.Err != nil {
+			fmt.Println(color.Yellow("! ") + fmt.Sprintf("%s of %s wasn't probed from %s: %s", p.Port, p.To, p.From, p.Err))
+		}
+	}
+	blocked := pmk.BlockedProbes(probes)
+	for _, p := range blocked {
+		fmt.Println(color.Red("x ") + fmt.Sprintf("%s can't reach %s (%s) of %s, the traffic is %s", p.From, p.Port, p.Port.Description, p.To, p.Status))
+	}
+	if len(blocked) == 0 {
+		fmt.Println(color.Green("✓ ") + "The nodes reach each other on the ports the cluster needs")
+	}
+	return len(blocked)
+}
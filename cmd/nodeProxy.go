@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var configCmdNodeProxy = &cobra.Command{
+	Use:   "node-proxy",
+	Short: "Updates the proxy of the pf9 services on onboarded nodes",
+	Long: `Updates the proxy pf9-comms and the hostagent use to reach the Platform9 controller
+	on nodes which are already onboarded, and restarts them. The settings persist across
+	reboots and hostagent upgrades. Use --remove to connect to the controller directly.`,
+	Args: cobra.NoArgs,
+	Run:  configNodeProxyRun,
+}
+
+var (
+	nodeProxyConfig objects.NodeConfig
+	nodeProxyURL    string
+	nodeNoProxy     string
+	nodeProxyRemove bool
+)
+
+func init() {
+	configCmdNodeProxy.Flags().StringVarP(&nodeProxyConfig.User, "user", "u", "", "ssh username for the nodes")
+	configCmdNodeProxy.Flags().StringVarP(&nodeProxyConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	configCmdNodeProxy.Flags().StringVarP(&nodeProxyConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	configCmdNodeProxy.Flags().StringSliceVarP(&nodeProxyConfig.IPs, "ip", "i", []string{}, "IP address of the nodes")
+	configCmdNodeProxy.Flags().StringVarP(&nodeProxyConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	configCmdNodeProxy.Flags().StringVar(&nodeProxyURL, "proxy", "", "proxy URL, can be specified as [<protocol>][<username>:<password>@]<host>:<port>")
+	configCmdNodeProxy.Flags().StringVar(&nodeNoProxy, "no-proxy", "", "comma separated list of hosts the services reach directly")
+	configCmdNodeProxy.Flags().BoolVar(&nodeProxyRemove, "remove", false, "remove the proxy settings")
+	configCmdCreate.AddCommand(configCmdNodeProxy)
+}
+
+func configNodeProxyRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running config node-proxy==========")
+	requireWritable("config node-proxy")
+
+	if (nodeProxyURL == "") == !nodeProxyRemove {
+		zap.S().Fatal("Either --proxy or --remove is required")
+	}
+
+	var proxy *pmk.NodeProxy
+	if !nodeProxyRemove {
+		parsed, err := pmk.ParseNodeProxy(nodeProxyURL, nodeNoProxy)
+		if err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+		proxy = &parsed
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if err := cmdexec.CheckLocal(nodeProxyConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	isRemote := cmdexec.CheckRemote(nodeProxyConfig)
+	if isRemote {
+		if !config.ValidateNodeConfig(&nodeProxyConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	ips := nodeProxyConfig.IPs
+	if len(ips) == 0 {
+		ips = []string{"localhost"}
+	}
+
+	failed := 0
+	for _, ip := range ips {
+		nodeCfg := nodeProxyConfig
+		nodeCfg.IPs = []string{ip}
+		executor, err := cmdexec.GetExecutor("", nodeCfg)
+		if err == nil && isRemote {
+			err = SudoPasswordCheck(executor, detachedMode, nodeCfg.SudoPassword)
+		}
+		if err == nil {
+			err = pmk.ConfigureNodeProxy(executor, proxy)
+		}
+		if err != nil {
+			failed++
+			fmt.Println(color.Red("x ") + fmt.Sprintf("Unable to update the proxy of node %s: %s", ip, err))
+			continue
+		}
+		if proxy == nil {
+			fmt.Println(color.Green("✓ ") + fmt.Sprintf("Proxy removed from node %s", ip))
+		} else {
+			fmt.Println(color.Green("✓ ") + fmt.Sprintf("Proxy of node %s set to %s:%d", ip, proxy.Host, proxy.Port))
+		}
+	}
+	if failed > 0 {
+		zap.S().Fatalf("Unable to update the proxy of %d node(s)", failed)
+	}
+
+	zap.S().Debug("==========Finished running config node-proxy==========")
+}
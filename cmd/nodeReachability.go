@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var nodeReachabilityCmd = &cobra.Command{
+	Use:   "reachability",
+	Short: "Diagnoses how nodes can reach the DU",
+	Long: `Checks this machine reaches the DU and the nodes over SSH, then which way each node reaches
+	the DU: directly, through the proxy of the config or --proxy, and with --tunnel through a tunnel to
+	this machine opened for the check. Tells a node which can't reach the DU from one this machine can't
+	reach, and which of 'prep-node', 'prep-node' with a proxy or 'prep-node --tunnel --relay' works.`,
+	Example: `pf9ctl node reachability --ip 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa --tunnel`,
+	Args:    cobra.NoArgs,
+	Run:     nodeReachabilityRun,
+}
+
+var (
+	reachConfig     objects.NodeConfig
+	reachProxyURL   string
+	reachTunnel     bool
+	reachTunnelPort int
+)
+
+func init() {
+	nodeReachabilityCmd.Flags().StringVarP(&reachConfig.User, "user", "u", "", "ssh username for the nodes")
+	nodeReachabilityCmd.Flags().StringVarP(&reachConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	nodeReachabilityCmd.Flags().StringVarP(&reachConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	nodeReachabilityCmd.Flags().StringSliceVarP(&reachConfig.IPs, "ip", "i", []string{}, "IP address of the nodes")
+	nodeReachabilityCmd.Flags().StringVarP(&reachConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	nodeReachabilityCmd.Flags().StringVar(&reachProxyURL, "proxy", "", "proxy URL the nodes could reach the DU through (default the proxy of the config)")
+	nodeReachabilityCmd.Flags().BoolVar(&reachTunnel, "tunnel", false, "also check the nodes reach the DU through a tunnel to this machine")
+	nodeReachabilityCmd.Flags().IntVar(&reachTunnelPort, "tunnel-port", pmk.DefaultTunnelPort, "Port of the loopback of the nodes the tunnel is checked on")
+	nodeReachabilityCmd.MarkFlagRequired("ip")
+	nodeReachabilityCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
+	nodeCmd.AddCommand(nodeReachabilityCmd)
+}
+
+func nodeReachabilityRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running node reachability==========")
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if !cmdexec.CheckRemote(reachConfig) {
+		zap.S().Fatalf("The nodes to check are given with --ip")
+	}
+	if !config.ValidateNodeConfig(&reachConfig, !detachedMode) {
+		zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+	}
+
+	// The DU may be unreachable, so the config isn't validated against it
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false}
+	err := config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	if err == config.NO_CONFIG {
+		zap.S().Fatalf("Unable to load the context: %s, create it with 'pf9ctl config set'", err.Error())
+	}
+	if err != nil {
+		fmt.Println(color.Yellow("! ") + fmt.Sprintf("Unable to validate the config: %s", err))
+	}
+	c, err := client.NewClient(cfg.Fqdn, cmdexec.LocalExecutor{ProxyUrl: cfg.ProxyURL}, cfg.AllowInsecure, false)
+	if err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+
+	proxyURL := reachProxyURL
+	if proxyURL == "" {
+		proxyURL = cfg.ProxyURL
+	}
+	tunnelPort := 0
+	if reachTunnel {
+		tunnelPort = reachTunnelPort
+	}
+
+	fmt.Println("This machine:")
+	du := pmk.CheckEndpoint("keystone", pmk.DUEndpoints(cfg.Fqdn, "")["keystone"], "")
+	duReachable := du.Healthy()
+	if duReachable {
+		fmt.Println(color.Green("  ✓ ") + fmt.Sprintf("Reaches the DU %s (%d in %s)", cfg.Fqdn, du.Status, du.Latency.Round(time.Millisecond)))
+	} else if du.Err != nil {
+		fmt.Println(color.Red("  x ") + fmt.Sprintf("Can't reach the DU %s: %s", cfg.Fqdn, du.Err))
+	} else {
+		fmt.Println(color.Red("  x ") + fmt.Sprintf("The DU %s answered with status %d", cfg.Fqdn, du.Status))
+	}
+
+	unreachable := 0
+	for _, ip := range reachConfig.IPs {
+		fmt.Printf("\nNode %s:\n", ip)
+		nodeCfg := reachConfig
+		nodeCfg.IPs = []string{ip}
+		// The proxies are given to curl, the commands run on the node
+		// without one
+		executor, err := cmdexec.GetExecutor("", nodeCfg)
+		if err == nil {
+			err = SudoPasswordCheck(executor, detachedMode, nodeCfg.SudoPassword)
+		}
+		if err != nil {
+			unreachable++
+			fmt.Println(color.Red("  x ") + sshFailure(err))
+			continue
+		}
+		fmt.Println(color.Green("  ✓ ") + "Reachable from this machine over SSH")
+
+		results := pmk.ProbeNodeReachability(executor, cfg.Fqdn, proxyURL, tunnelPort)
+		for _, r := range results {
+			how := "directly"
+			if r.Via != "" {
+				how = fmt.Sprintf("through the %s %s", r.Model, r.Via)
+			}
+			if r.OK() {
+				fmt.Println(color.Green("  ✓ ") + fmt.Sprintf("Reaches the DU %s (%s)", how, r.Status))
+			} else {
+				fmt.Println(color.Red("  x ") + fmt.Sprintf("Can't reach the DU %s: %s", how, r.Err))
+			}
+		}
+
+		models := pmk.WorkingModels(results)
+		if len(models) == 0 {
+			unreachable++
+			advice := "the node has no way to the DU"
+			if !reachTunnel && duReachable {
+				advice += ", check with --tunnel whether it can go through this machine"
+			}
+			fmt.Println(color.Red("  x ") + advice)
+			continue
+		}
+		fmt.Println("  Works with: " + strings.Join(reachCommands(models), " or "))
+	}
+
+	if unreachable > 0 {
+		zap.S().Fatalf("%d node(s) can't be prepared from here", unreachable)
+	}
+
+	zap.S().Debug("==========Finished running node reachability==========")
+}
+
+// reachCommands returns how the nodes are prepared for the models they reach
+// the DU with
+func reachCommands(models []string) []string {
+	var commands []string
+	for _, model := range models {
+		switch model {
+		case pmk.ReachDirect:
+			commands = append(commands, "'pf9ctl prep-node'")
+		case pmk.ReachProxy:
+			commands = append(commands, "'pf9ctl prep-node' with the proxy in 'pf9ctl config set --proxy-url'")
+		case pmk.ReachTunnel:
+			commands = append(commands, "'pf9ctl prep-node --tunnel --relay' then 'pf9ctl node tunnel'")
+		}
+	}
+	return commands
+}
+
+// sshFailure explains why the node can't be reached over SSH
+func sshFailure(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "unable to dial"):
+		return fmt.Sprintf("This machine can't reach the node over SSH, check the route and port 22 are open: %s", msg)
+	case strings.Contains(msg, "unable to authenticate"), strings.Contains(msg, "handshake failed"):
+		return fmt.Sprintf("The node refused the SSH login, check the user and the key or password: %s", msg)
+	}
+	return fmt.Sprintf("Unable to run commands on the node: %s", msg)
+}
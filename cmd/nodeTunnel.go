@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var nodeTunnelCmd = &cobra.Command{
+	Use:   "tunnel",
+	Short: "Tunnels the DU traffic of nodes without internet access through this machine",
+	Long: `Connects to the nodes over SSH and serves them a proxy on port --tunnel-port of their loopback,
+	which only reaches the DU, until interrupted. Nodes prepared with 'pf9ctl prep-node --tunnel' send
+	the traffic of the pf9 services to that proxy, so they stay connected to the DU while the tunnel runs.`,
+	Example: `pf9ctl node tunnel --ip 10.0.0.1 --ip 10.0.0.2 -u ubuntu -s ~/.ssh/id_rsa`,
+	Args:    cobra.NoArgs,
+	Run:     nodeTunnelRun,
+}
+
+var (
+	tunnelConfig   objects.NodeConfig
+	nodeTunnelPort int
+)
+
+func init() {
+	nodeTunnelCmd.Flags().StringVarP(&tunnelConfig.User, "user", "u", "", "ssh username for the nodes")
+	nodeTunnelCmd.Flags().StringVarP(&tunnelConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	nodeTunnelCmd.Flags().StringVarP(&tunnelConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	nodeTunnelCmd.Flags().StringSliceVarP(&tunnelConfig.IPs, "ip", "i", []string{}, "IP address of the nodes")
+	nodeTunnelCmd.Flags().StringVar(&tunnelConfig.MFA, "mfa", "", "MFA token")
+	nodeTunnelCmd.Flags().IntVar(&nodeTunnelPort, "tunnel-port", pmk.DefaultTunnelPort, "Port of the loopback of the nodes the DU traffic is tunneled from")
+	nodeTunnelCmd.MarkFlagRequired("ip")
+	nodeTunnelCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
+	nodeCmd.AddCommand(nodeTunnelCmd)
+}
+
+func nodeTunnelRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running node tunnel==========")
+	requireWritable("node tunnel")
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if !cmdexec.CheckRemote(tunnelConfig) {
+		zap.S().Fatalf("The nodes to tunnel are given with --ip")
+	}
+	if !config.ValidateNodeConfig(&tunnelConfig, !detachedMode) {
+		zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+	}
+
+	cfg, c, auth := loadClient(cmd, tunnelConfig.MFA)
+	defer c.Segment.Close()
+
+	var tunnels []io.Closer
+	for _, ip := range tunnelConfig.IPs {
+		nodeCfg := tunnelConfig
+		nodeCfg.IPs = []string{ip}
+		executor, err := cmdexec.GetExecutor("", nodeCfg)
+		if err != nil {
+			zap.S().Fatalf("Unable to connect to node %s: %s", ip, err)
+		}
+		closer, err := openDUTunnel(cfg, auth, executor, nodeTunnelPort)
+		if err != nil {
+			zap.S().Fatalf("Node %s: %s", ip, err)
+		}
+		tunnels = append(tunnels, closer)
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("Tunneling the DU traffic of node %s through this machine", ip))
+	}
+
+	fmt.Println("Press Ctrl-C to close the tunnels")
+	waitForSignal(os.Interrupt, syscall.SIGTERM)
+	for _, closer := range tunnels {
+		closer.Close()
+	}
+
+	zap.S().Debug("==========Finished running node tunnel==========")
+}
+
+// openDUTunnel tunnels the traffic of the node to the DU and to the region of
+// the config through this machine
+func openDUTunnel(cfg *objects.Config, auth keystone.KeystoneAuth, executor cmdexec.Executor, port int) (io.Closer, error) {
+	regionURL, err := keystone.FetchRegionFQDN(cfg.Fqdn, cfg.Region, auth)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to fetch the URL of region %s: %w", cfg.Region, err)
+	}
+	return pmk.OpenDUTunnel(executor, port, cfg.Fqdn, regionURL)
+}
+
+// waitForSignal blocks until one of signals is received
+func waitForSignal(signals ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	defer signal.Stop(ch)
+	<-ch
+}
@@ -0,0 +1,43 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/platform9/pf9ctl/pkg/output"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// outputFormat is the --output of the get and describe commands
+var outputFormat string
+
+// addOutputFlag adds --output to a get or describe command
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", "", "format of the output: json, jsonpath='{.path}' or go-template='{{.field}}' (default a table)")
+	cmd.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return output.Formats, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	})
+}
+
+// parseOutput exits on an invalid --output, before connecting to the DU
+func parseOutput() output.Format {
+	format, err := output.Parse(outputFormat)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	return format
+}
+
+// printOutput prints v in the format of --output, unless it is the default
+// format which the command prints itself. It returns whether v was printed.
+func printOutput(format output.Format, v interface{}) bool {
+	if format.Default() {
+		return false
+	}
+	if err := format.Print(os.Stdout, v); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	return true
+}
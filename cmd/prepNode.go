@@ -14,11 +14,14 @@ import (
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/interrupt"
+	"github.com/platform9/pf9ctl/pkg/keystone"
 	"github.com/platform9/pf9ctl/pkg/log"
 	"github.com/platform9/pf9ctl/pkg/objects"
 	"github.com/platform9/pf9ctl/pkg/pmk"
 	"github.com/platform9/pf9ctl/pkg/ssh"
 	"github.com/platform9/pf9ctl/pkg/supportBundle"
+	"github.com/platform9/pf9ctl/pkg/ui"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -41,12 +44,28 @@ var prepNodeCmd = &cobra.Command{
 }
 
 var (
-	user           string
-	password       string
-	sshKey         string
-	ips            []string
-	skipChecks     bool
-	disableSwapOff bool
+	user             string
+	password         string
+	sshKey           string
+	ips              []string
+	skipChecks       bool
+	disableSwapOff   bool
+	onboardToken     string
+	verifyReport     string
+	workDir          string
+	downloadLimit    string
+	relay            bool
+	tunnel           bool
+	tunnelPort       int
+	installWatchdog  bool
+	installerArgs    []string
+	hostagentVersion string
+	packageRepo      string
+	packageRepoKey   string
+	prepNodeFile     string
+	// skipSignatureVerify is the only way to skip the verification of the
+	// signature of the installers, it is never stored in the config
+	skipSignatureVerify bool
 )
 
 var nodeConfig objects.NodeConfig
@@ -55,38 +74,142 @@ func init() {
 	prepNodeCmd.Flags().StringVarP(&nodeConfig.User, "user", "u", "", "ssh username for the nodes")
 	prepNodeCmd.Flags().StringVarP(&nodeConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
 	prepNodeCmd.Flags().StringVarP(&nodeConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
-	prepNodeCmd.Flags().StringSliceVarP(&nodeConfig.IPs, "ip", "i", []string{}, "IP address of host to be prepared")
+	prepNodeCmd.Flags().StringSliceVarP(&nodeConfig.IPs, "ip", "i", []string{}, "IP address of host to be prepared, repeat it to prepare a batch of nodes compared on their preflight checks first")
+	prepNodeCmd.Flags().StringVar(&prepNodeFile, "node-file", "", "YAML file listing the nodes to prepare with their ip, role and optional region, like the one of attach-node --node-file, prepared along with the ones of --ip")
 	prepNodeCmd.Flags().BoolVarP(&skipChecks, "skip-checks", "c", false, "Will skip optional checks if true")
 	prepNodeCmd.Flags().BoolVarP(&disableSwapOff, "disable-swapoff", "d", false, "Will skip swapoff")
+	addCheckPolicyFlag(prepNodeCmd)
 	prepNodeCmd.Flags().MarkHidden("disable-swapoff")
 	prepNodeCmd.Flags().StringVar(&nodeConfig.MFA, "mfa", "", "MFA token")
 	prepNodeCmd.Flags().StringVarP(&nodeConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
 	prepNodeCmd.Flags().BoolVarP(&nodeConfig.RemoveExistingPkgs, "remove-existing-pkgs", "r", false, "Will remove previous installation if found (default false)")
 	prepNodeCmd.Flags().BoolVar(&util.SkipKube, "skip-kube", false, "Skip installing pf9-kube/nodelet on this host")
+	prepNodeCmd.Flags().StringVar(&verifyReport, "verify-report", "", "Refuse to prepare the node if its state doesn't match this check-node --report, approved with the key of the report_approver_key setting")
+	prepNodeCmd.Flags().StringVar(&onboardToken, "onboard-token", "", "Onboarding token created with 'pf9ctl create-onboard-token', used instead of the config")
+	prepNodeCmd.Flags().BoolVar(&util.RegenerateHostID, "regenerate-host-id", false, "Reset the host identity (host ID and machine-id), use for nodes cloned from an onboarded VM")
+	prepNodeCmd.Flags().StringVar(&workDir, "work-dir", "", "Directory of the node the installer is downloaded to (default $HOME/pf9 or the work-dir of the config)")
+	prepNodeCmd.Flags().StringVar(&downloadLimit, "download-limit", "", "Maximum rate the node downloads the installer at, e.g: 10MB/s (default unlimited or the download-limit of the config)")
+	prepNodeCmd.Flags().StringArrayVar(&installerArgs, "installer-arg", nil, "Extra argument of the installer, e.g: --installer-arg=--some-option=value, repeat it for several arguments, they follow the installer_args of the config")
+	prepNodeCmd.Flags().StringVar(&hostagentVersion, "hostagent-version", "", "Version of the hostagent the DU is asked to install, e.g: 5.6.0, to stage a rollout or roll back an agent release (default the latest or the hostagent_version of the config)")
+	prepNodeCmd.Flags().BoolVar(&skipSignatureVerify, "skip-signature-verify", false, "Install the installer without verifying its GPG signature against the signing keys of Platform9, only for DUs which don't sign their installers")
+	prepNodeCmd.Flags().StringVar(&packageRepo, "package-repo", "", "URL of the repo built by 'pf9ctl mirror create' the node installs the OS packages from, e.g: http://10.0.0.5/repo or file:///srv/repo (default the package_repo of the config)")
+	prepNodeCmd.Flags().StringVar(&packageRepoKey, "package-repo-key", "", "File of the public key of the package repo, the "+pmk.MirrorKeyName+" 'pf9ctl mirror create' writes in the repo, copied from the machine which built it (default the package_repo_key of the config)")
+	prepNodeCmd.Flags().BoolVar(&relay, "relay", false, "Download the installer on this machine and copy it to the node, for nodes without internet access")
+	prepNodeCmd.Flags().BoolVar(&tunnel, "tunnel", false, "Send the DU traffic of the node through this machine over SSH, keep it going after prep-node with 'pf9ctl node tunnel'")
+	prepNodeCmd.Flags().IntVar(&tunnelPort, "tunnel-port", pmk.DefaultTunnelPort, "Port of the loopback of the node the DU traffic is tunneled from")
+	prepNodeCmd.Flags().BoolVar(&util.FixHostname, "fix-hostname", false, "Add the hostname of the node to /etc/hosts when it doesn't resolve")
+	prepNodeCmd.Flags().StringVar(&util.NodeRole, "role", "", "Role the node is prepared for, master or worker, to check and tune the kernel for that role (default checks for any role)")
+	prepNodeCmd.Flags().DurationVar(&pmk.MaxClockSkew, "max-clock-skew", pmk.MaxClockSkew, "Largest difference allowed between the clock of the node and the one of the DU")
+	prepNodeCmd.Flags().StringVar(&pmk.NTPServer, "ntp-server", "", "NTP server to compare the clock of the node against instead of the DU, e.g: pool.ntp.org")
+	prepNodeCmd.Flags().BoolVar(&installWatchdog, "install-watchdog", false, "Install a systemd timer restarting pf9-hostagent and pf9-comms with a growing delay when they stop or lose the DU, shown by 'pf9ctl status'")
+	prepNodeCmd.Flags().StringVar(&pmk.KubernetesVersion, "kubernetes-version", "", "Kubernetes version the cgroup version of the node is checked for, e.g: 1.21 (default the newest version of the DU)")
+	prepNodeCmd.Flags().BoolVar(&pmk.FixCgroup, "fix-cgroup", false, "Switch the node to the cgroup version the Kubernetes version needs, which applies once the node is rebooted, see --allow-reboot")
+	prepNodeCmd.Flags().BoolVar(&pmk.RemoveExistingRuntime, "remove-existing-runtime", false, "Remove the docker, containerd or CRI-O installed on the node, which conflict with the containerd of PMK")
+	prepNodeCmd.Flags().BoolVar(&pmk.ReuseRuntime, "reuse-runtime", false, "Keep the docker or containerd running on the node for PMK instead of the containerd of pf9-kube, when it is recent enough")
+	prepNodeCmd.Flags().BoolVar(&util.AllowReboot, "allow-reboot", false, "Reboot the node when a remediation needs it, e.g: swap still in use, wait for it and resume prep-node")
+	prepNodeCmd.Flags().DurationVar(&pmk.RebootTimeout, "reboot-timeout", pmk.RebootTimeout, "How long the node has to come back after a reboot of --allow-reboot")
+	prepNodeCmd.Flags().BoolVar(&overrideProtected, overrideProtectedFlag, false, "Prepare the node even when it is protected: it runs the DU, matches protected_hosts or has a protected marker file")
 	prepNodeCmd.Flags().MarkHidden("skip-kube")
 
 	rootCmd.AddCommand(prepNodeCmd)
 }
 
+// cleanUpOnInterrupt undoes what command changed on the nodes when it is
+// interrupted with Ctrl-C or SIGTERM, then exits with the code of the signal
+func cleanUpOnInterrupt(command string) {
+	interrupt.Notify(func(sig os.Signal) {
+		ui.StopAll()
+		fmt.Println(color.Red("x ") + fmt.Sprintf("%s was interrupted, run it again to resume", command))
+		exit(interrupt.ExitCode(sig))
+	})
+}
+
 func prepNodeRun(cmd *cobra.Command, args []string) {
 	zap.S().Debug("==========Running prep-node==========")
+	requireWritable("prep-node")
+	cleanUpOnInterrupt("prep-node")
 
 	if skipChecks {
 		pmk.WarningOptionalChecks = true
 	}
+	loadCheckPolicy()
 
 	detachedMode := cmd.Flags().Changed("no-prompt")
+	// The nodes of the node file are prepared in their region with the role
+	// of the file
+	fileNodes := make(map[string]pmk.NodeFileEntry)
+	if prepNodeFile != "" {
+		nodeFile, err := pmk.ReadNodeFile(prepNodeFile)
+		if err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+		for _, node := range nodeFile.Nodes {
+			nodeConfig.IPs = append(nodeConfig.IPs, node.IP)
+			fileNodes[node.IP] = node
+		}
+	}
+	if err := cmdexec.CheckLocal(nodeConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
 	isRemote := cmdexec.CheckRemote(nodeConfig)
 
+	if workDir != "" {
+		if err := pmk.ValidateWorkDir(workDir); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+	}
+	if downloadLimit != "" {
+		if err := pmk.ValidateDownloadLimit(downloadLimit); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+	}
+	if err := pmk.ValidateInstallerArgs(installerArgs); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if hostagentVersion != "" {
+		if err := pmk.ValidateHostagentVersion(hostagentVersion); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+	}
+	if packageRepo != "" {
+		if err := pmk.ValidatePackageRepo(packageRepo); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+	}
+	if packageRepoKey != "" {
+		if err := pmk.ValidatePackageRepoKey(packageRepoKey); err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+	}
+	if err := util.ValidateNodeRole(util.NodeRole); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if pmk.RemoveExistingRuntime && pmk.ReuseRuntime {
+		zap.S().Fatalf("--remove-existing-runtime and --reuse-runtime can't be used together")
+	}
+
 	if isRemote {
 		if !config.ValidateNodeConfig(&nodeConfig, !detachedMode) {
 			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
 		}
 	}
+	if tunnel && !isRemote {
+		zap.S().Fatalf("--tunnel needs the node given with --ip")
+	}
+	if tunnel && len(nodeConfig.IPs) > 1 {
+		zap.S().Fatalf("--tunnel prepares a single node")
+	}
 
 	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: nodeConfig.MFA}
 	var err error
-	if detachedMode {
+	if onboardToken != "" {
+		// The onboarding token carries the DU and the credential, so the
+		// config of the admin isn't needed
+		if detachedMode {
+			nodeConfig.RemoveExistingPkgs = true
+		}
+		err = loadOnboardToken(cfg, onboardToken)
+	} else if detachedMode {
 		nodeConfig.RemoveExistingPkgs = true
 		err = config.LoadConfig(util.Pf9DBLoc, cfg, nodeConfig)
 	} else {
@@ -96,6 +219,33 @@ func prepNodeRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
 	}
+	refuseProtectedHosts(cfg, nodeConfig, "prep-node")
+	if workDir != "" {
+		cfg.WorkDir = workDir
+	}
+	if downloadLimit != "" {
+		cfg.DownloadLimit = downloadLimit
+	}
+	if hostagentVersion != "" {
+		cfg.HostagentVersion = hostagentVersion
+	}
+	if packageRepo != "" {
+		cfg.PackageRepo = packageRepo
+	}
+	if packageRepoKey != "" {
+		cfg.PackageRepoKey = packageRepoKey
+	}
+	cfg.InstallerArgs = append(cfg.InstallerArgs, installerArgs...)
+	cfg.Relay = relay
+	cfg.SkipSignatureVerify = skipSignatureVerify
+	if skipSignatureVerify {
+		fmt.Println(color.Yellow("! ") + "The signature of the installer isn't verified, it could be tampered with by a mirror or a proxy")
+	}
+	if tunnel {
+		// The commands run on the node and the pf9 services reach the DU
+		// through the proxy the tunnel serves on the node
+		cfg.ProxyURL = pmk.TunnelProxyURL(tunnelPort)
+	}
 
 	fmt.Println(color.Green("✓ ") + "Loaded Config Successfully")
 	zap.S().Debug("Loaded Config Successfully")
@@ -110,12 +260,7 @@ func prepNodeRun(cmd *cobra.Command, args []string) {
 	}
 	defer c.Segment.Close()
 	// Fetch the keystone token.
-	auth, err := c.Keystone.GetAuth(
-		cfg.Username,
-		cfg.Password,
-		cfg.Tenant,
-		cfg.MfaToken,
-	)
+	auth, err := keystone.Authenticate(c.Keystone, *cfg)
 
 	if err != nil {
 		// Certificate expiration is detected by the http library and
@@ -133,20 +278,76 @@ func prepNodeRun(cmd *cobra.Command, args []string) {
 			zap.S().Fatal("Failed executing commands on remote machine with sudo: ", err.Error())
 		}
 	}
+	if tunnel {
+		closer, err := openDUTunnel(cfg, auth, executor, tunnelPort)
+		if err != nil {
+			zap.S().Fatalf("%s", err.Error())
+		}
+		defer closer.Close()
+		fmt.Println(color.Yellow("! ") + "The node reaches the DU through this machine, run 'pf9ctl node tunnel' to keep it connected after prep-node")
+	}
 
+	if isRemote && (len(nodeConfig.IPs) > 1 || prepNodeFile != "") {
+		prepNodeBatch(cfg, c, auth, fileNodes, detachedMode)
+	} else {
+		runPrepNode(cfg, c, auth, nodeConfig, isRemote, detachedMode)
+	}
+
+	zap.S().Debug("==========Finished running prep-node==========")
+}
+
+// runPrepNode checks the node and prepares it once the config is loaded and the
+// executor of the node created
+func runPrepNode(cfg *objects.Config, c client.Client, auth keystone.KeystoneAuth, nodeCfg objects.NodeConfig, isRemote, detachedMode bool) {
+	nodeCfg.NoPrompt = detachedMode
+	// The node is only authorized, which needs the admin role, without --skip-kube
+	if !util.SkipKube {
+		requireRole(auth, "prep-node")
+	}
+	resolveKubernetesVersion(c, auth)
+	var err error
 	// If all pre-requisite checks passed in Check-Node then prep-node
-	result, err := pmk.CheckNode(*cfg, c, auth, nodeConfig)
+	var approved *pmk.PreflightReport
+	if verifyReport != "" {
+		if approved, err = readApprovedReport(verifyReport, cfg.ReportApproverKey); err != nil {
+			zap.S().Fatalf("Unable to verify the preflight report: %s", err.Error())
+		}
+	}
+	// The packages the checks and the installer install come from the repo,
+	// which is removed before prep-node exits
+	removeRepo := addPackageRepo(cfg, c.Executor)
+	fatalf := func(template string, args ...interface{}) {
+		removeRepo()
+		zap.S().Fatalf(template, args...)
+	}
+
+	var result pmk.CheckNodeResult
+	if approved != nil {
+		var current *pmk.PreflightReport
+		result, current, err = pmk.CheckNodeWithReport(*cfg, c, auth, nodeCfg)
+		if err == nil {
+			if diffs := approved.Compare(current); len(diffs) > 0 {
+				for _, diff := range diffs {
+					fmt.Println(color.Red("x ") + diff)
+				}
+				fatalf("The node no longer matches the approved preflight report %s", verifyReport)
+			}
+			fmt.Println(color.Green("✓ ") + "Node matches the approved preflight report")
+		}
+	} else {
+		result, err = pmk.CheckNode(*cfg, c, auth, nodeCfg)
+	}
 	if err != nil {
 		// Uploads pf9cli log bundle if pre-requisite checks fails
 		errbundle := supportBundle.SupportBundleUpload(*cfg, c, isRemote)
 		if errbundle != nil {
 			zap.S().Debugf("Unable to upload supportbundle to s3 bucket %s", errbundle.Error())
 		}
-		zap.S().Fatalf("\nPre-requisite check(s) failed %s\n", err.Error())
+		fatalf("\nPre-requisite check(s) failed %s\n", err.Error())
 	}
 
 	if result == pmk.RequiredFail {
-		zap.S().Fatalf(color.Red("x ")+"Required pre-requisite check(s) failed. See %s or use --verbose for logs \n", log.GetLogLocation(util.Pf9Log))
+		fatalf(color.Red("x ")+"Required pre-requisite check(s) failed. See %s or use --verbose for logs \n", log.GetLogLocation(util.Pf9Log))
 	} else if result == pmk.CleanInstallFail {
 		fmt.Println("\nPrevious Installation Removed")
 	}
@@ -155,13 +356,15 @@ func prepNodeRun(cmd *cobra.Command, args []string) {
 		if !skipChecks {
 			if detachedMode {
 				fmt.Print(color.Red("x ") + "Optional pre-requisite check(s) failed. Use --skip-checks to skip these checks.\n")
-				os.Exit(1)
+				removeRepo()
+				exit(1)
 			} else {
 				fmt.Print("\nOptional pre-requisite check(s) failed. Do you want to continue? (y/n) ")
 				reader := bufio.NewReader(os.Stdin)
 				char, _, _ := reader.ReadRune()
 				if char != 'y' {
-					os.Exit(0)
+					removeRepo()
+					exit(0)
 				}
 			}
 		} else {
@@ -169,7 +372,12 @@ func prepNodeRun(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	if err := pmk.PrepNode(*cfg, c, auth); err != nil {
+	if err := pmk.PrepNodeContext(interrupt.Context(), *cfg, c, auth); err != nil {
+		// An interrupted prep-node exits once cleaned up
+		interrupt.Wait()
+		if errors.Is(err, pmk.ErrRebootRequired) {
+			fatalf("%s", err.Error())
+		}
 
 		// Uploads pf9cli log bundle if prepnode failed to get prepared
 		errbundle := supportBundle.SupportBundleUpload(*cfg, c, isRemote)
@@ -178,10 +386,51 @@ func prepNodeRun(cmd *cobra.Command, args []string) {
 		}
 
 		zap.S().Debugf("Unable to prep node: %s\n", err.Error())
-		zap.S().Fatalf("\nFailed to prepare node. See %s or use --verbose for logs\n", log.GetLogLocation(util.Pf9Log))
+		fatalf("\nFailed to prepare node. See %s or use --verbose for logs\n", log.GetLogLocation(util.Pf9Log))
 	}
+	removeRepo()
 
-	zap.S().Debug("==========Finished running prep-node==========")
+	if installWatchdog {
+		if err := pmk.InstallWatchdog(c.Executor, pmk.WatchdogHost(cfg.Fqdn, cfg.ProxyURL)); err != nil {
+			fmt.Println(color.Yellow("! ") + err.Error())
+		} else {
+			fmt.Println(color.Green("✓ ") + "Installed the watchdog of pf9-hostagent and pf9-comms, check it with 'pf9ctl status'")
+		}
+	}
+}
+
+// readApprovedReport reads the report and checks it was approved with the key
+// of the report_approver_key setting and not modified since
+func readApprovedReport(file, approverKey string) (*pmk.PreflightReport, error) {
+	if approverKey == "" {
+		return nil, fmt.Errorf("no report_approver_key is set, set it to the key printed by 'pf9ctl approve-report'")
+	}
+	key, err := pmk.ParseApproverKey(approverKey)
+	if err != nil {
+		return nil, err
+	}
+	report, err := pmk.ReadReport(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := report.Verify(key); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// loadOnboardToken sets the DU, the region and the credential of cfg from an
+// onboarding token
+func loadOnboardToken(cfg *objects.Config, encoded string) error {
+	token, err := keystone.DecodeOnboardToken(encoded)
+	if err != nil {
+		return err
+	}
+	cfg.Fqdn = token.Fqdn
+	cfg.Region = token.Region
+	cfg.ApplicationCredentialID = token.ID
+	cfg.ApplicationCredentialSecret = token.Secret
+	return nil
 }
 
 // To check if Remote Host needs Password to access Sudo and prompt for Sudo Password if exists.
@@ -224,7 +473,7 @@ func validateSudoPassword(exec cmdexec.Executor) string {
 
 	_ = pmk.CheckSudo(exec)
 	// Validate Sudo Password entered for Remote Host from stderr.
-	if strings.Contains(cmdexec.StdErrSudoPassword, util.InvalidPassword) {
+	if strings.Contains(cmdexec.LastStderr(exec), util.InvalidPassword) {
 		return util.Invalid
 	}
 	return util.Valid
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/ui"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// prepNodeBatch prepares the nodes of --ip and --node-file one after the
+// other. The preflight checks run on all of them first and are compared, as
+// nodes which differ in kernel, MTU or size are a common cause of flaky
+// clusters. The nodes the checks can't run on aren't prepared. The nodes of
+// fileNodes are prepared with their role, in their region.
+func prepNodeBatch(cfg *objects.Config, c client.Client, auth keystone.KeystoneAuth, fileNodes map[string]pmk.NodeFileEntry, detachedMode bool) {
+	resolveKubernetesVersion(c, auth)
+	var nodes []pmk.NodePreflight
+	regions := client.NewRegions(*cfg, c, auth)
+	clients := make(map[string]client.RegionClient)
+	role := util.NodeRole
+	for _, ip := range nodeConfig.IPs {
+		nodeCfg := nodeConfig
+		nodeCfg.IPs = []string{ip}
+		executor, err := cmdexec.GetExecutor(cfg.ProxyURL, nodeCfg)
+		if err == nil {
+			err = SudoPasswordCheck(executor, detachedMode, nodeCfg.SudoPassword)
+		}
+		if err != nil {
+			fmt.Println(color.Red("x ") + fmt.Sprintf("Unable to run commands on node %s", ip))
+			nodes = append(nodes, pmk.NodePreflight{Host: ip, Err: fmt.Errorf("unable to run commands on the node: %w", err)})
+			continue
+		}
+		rc, err := regions.Get(fileNodes[ip].Region)
+		if err != nil {
+			fmt.Println(color.Red("x ") + fmt.Sprintf("Unable to reach the region of node %s", ip))
+			nodes = append(nodes, pmk.NodePreflight{Host: ip, Err: err})
+			continue
+		}
+		rc.Executor = executor
+		clients[ip] = rc
+
+		util.NodeRole = firstNonEmpty(fileNodes[ip].Role, role)
+		restore := scopeHost(ip)
+		phase := ui.StartPhase(fmt.Sprintf("Running the preflight checks of node %s", ip))
+		node := pmk.PreflightNode(rc.Config, executor, ip)
+		if node.Err != nil {
+			phase.Fail(fmt.Sprintf("Unable to run the preflight checks of node %s: %s", ip, node.Err))
+		} else {
+			phase.Succeed(fmt.Sprintf("Ran the preflight checks of node %s", ip))
+		}
+		restore()
+		nodes = append(nodes, node)
+	}
+
+	matrix := pmk.NewPreflightMatrix(nodes)
+	fmt.Println()
+	printPreflightMatrix(matrix)
+
+	if len(matrix.Errors) == len(nodes) {
+		zap.S().Fatalf("The preflight checks couldn't run on any node")
+	}
+	if len(matrix.Errors) > 0 {
+		switch {
+		case skipChecks:
+			fmt.Println("\nProceeding for prep-node with the other nodes")
+		case detachedMode:
+			zap.S().Fatalf("The preflight checks couldn't run on %d node(s). Use --skip-checks to prepare the other nodes anyway", len(matrix.Errors))
+		default:
+			answer, err := util.AskBool(fmt.Sprintf("The preflight checks couldn't run on %d node(s). Do you want to prepare the other nodes?", len(matrix.Errors)))
+			if err != nil || !answer {
+				fmt.Println("Stopping prep-node")
+				return
+			}
+		}
+	}
+
+	if outliers := matrix.Outliers(); len(outliers) > 0 {
+		fmt.Println()
+		for _, outlier := range outliers {
+			fmt.Println(color.Yellow("! ") + outlier)
+		}
+		switch {
+		case skipChecks:
+			fmt.Println("\nProceeding for prep-node with nodes which differ")
+		case detachedMode:
+			zap.S().Fatalf("The nodes differ, which makes clusters flaky. Use --skip-checks to prepare them anyway")
+		default:
+			answer, err := util.AskBool("The nodes differ, which makes clusters flaky. Do you want to prepare them anyway?")
+			if err != nil || !answer {
+				fmt.Println("Stopping prep-node")
+				return
+			}
+		}
+	}
+
+	for _, ip := range nodeConfig.IPs {
+		if _, failed := matrix.Errors[ip]; failed {
+			continue
+		}
+		rc := clients[ip]
+		if rc.Config.Region != cfg.Region {
+			fmt.Printf("\nPreparing node %s in region %s\n", ip, rc.Config.Region)
+		} else {
+			fmt.Printf("\nPreparing node %s\n", ip)
+		}
+		nodeCfg := nodeConfig
+		nodeCfg.IPs = []string{ip}
+		util.NodeRole = firstNonEmpty(fileNodes[ip].Role, role)
+		restore := scopeHost(ip)
+		runPrepNode(&rc.Config, rc.Client, rc.Auth, nodeCfg, true, detachedMode)
+		restore()
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("Node %s prepared", ip))
+	}
+
+	if len(matrix.Errors) > 0 {
+		fmt.Println()
+		for _, host := range matrix.Hosts {
+			if _, failed := matrix.Errors[host]; failed {
+				fmt.Println(color.Red("x ") + fmt.Sprintf("Node %s wasn't prepared", host))
+			}
+		}
+		exit(1)
+	}
+}
+
+// scopeHost prefixes the log and the phase lines with the node ip until the
+// returned function is called
+func scopeHost(ip string) (restore func()) {
+	restoreLog, restoreUI := log.ScopeHost(ip), ui.ScopeHost(ip)
+	return func() {
+		restoreUI()
+		restoreLog()
+	}
+}
+
+// printPreflightMatrix prints the facts and the checks of the nodes, a row by
+// fact or check and a column by node. The values which differ from most nodes
+// are marked with a *.
+func printPreflightMatrix(m pmk.PreflightMatrix) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(w, "CHECK\t%s\n", strings.Join(m.Hosts, "\t"))
+	for _, row := range m.Rows {
+		values := make([]string, len(row.Values))
+		for i, value := range row.Values {
+			values[i] = value
+			if row.Outliers[i] {
+				values[i] += " *"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\n", row.Name, strings.Join(values, "\t"))
+	}
+	w.Flush()
+	fmt.Println("* differs from most nodes")
+	for _, host := range m.Hosts {
+		if err, ok := m.Errors[host]; ok {
+			fmt.Println(color.Red("x ") + fmt.Sprintf("Node %s: %s", host, err))
+		}
+	}
+}
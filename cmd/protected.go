@@ -0,0 +1,65 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"go.uber.org/zap"
+)
+
+// overrideProtected lets prep-node, decommission-node and decommission-cluster
+// run on the protected hosts
+var overrideProtected bool
+
+// overrideProtectedFlag is the flag setting overrideProtected
+const overrideProtectedFlag = "i-know-what-i-am-doing"
+
+// refuseProtectedHosts exits when one of the nodes of nodeCfg, or this machine
+// when there is none, is protected from op, unless the override flag is set
+func refuseProtectedHosts(cfg *objects.Config, nodeCfg objects.NodeConfig, op string) {
+	nodes := [][]string{nil}
+	if cmdexec.CheckRemote(nodeCfg) {
+		nodes = nil
+		for _, ip := range nodeCfg.IPs {
+			nodes = append(nodes, []string{ip})
+		}
+	}
+
+	var protected []string
+	for _, ips := range nodes {
+		node := nodeCfg
+		node.IPs = ips
+		executor, err := cmdexec.GetExecutor(cfg.ProxyURL, node)
+		if err != nil {
+			zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+		}
+		name := "this machine"
+		if len(ips) > 0 {
+			name = ips[0]
+		}
+		if reason := pmk.ProtectedReason(executor, cfg); reason != "" {
+			protected = append(protected, fmt.Sprintf("%s is protected: %s", name, reason))
+		}
+	}
+	if len(protected) == 0 {
+		return
+	}
+
+	if overrideProtected {
+		for _, p := range protected {
+			fmt.Println(color.Yellow("! ") + p + ", running " + op + " anyway")
+		}
+		zap.S().Infof("Running %s on protected hosts with --%s: %s", op, overrideProtectedFlag, strings.Join(protected, "; "))
+		return
+	}
+	for _, p := range protected {
+		fmt.Println(color.Red("x ") + p)
+	}
+	zap.S().Fatalf("%s refuses to run on protected hosts, use --%s to run it anyway", op, overrideProtectedFlag)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var getRegionsCmd = &cobra.Command{
+	Use:   "regions",
+	Short: "Lists the regions of the Platform9 account",
+	Long:  "Lists the regions of the Platform9 account along with their FQDN. The configured region is marked with '*'",
+	Example: `pf9ctl get regions
+	pf9ctl get regions -o go-template='{{range .items}}{{.name}} {{.fqdn}}{{"\n"}}{{end}}'`,
+	Run: getRegionsRun,
+}
+
+var getRegionsMFA string
+
+// regionList is the output of get regions for --output
+type regionList struct {
+	Items []regionItem `json:"items"`
+}
+
+type regionItem struct {
+	Name    string `json:"name"`
+	Fqdn    string `json:"fqdn"`
+	Current bool   `json:"current"`
+}
+
+func init() {
+	getRegionsCmd.Flags().StringVar(&getRegionsMFA, "mfa", "", "MFA token")
+	addOutputFlag(getRegionsCmd)
+	getCmd.AddCommand(getRegionsCmd)
+}
+
+func getRegionsRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running get regions==========")
+	format := parseOutput()
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: getRegionsMFA}
+	var err error
+	if cmd.Flags().Changed("no-prompt") {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Unable to obtain keystone credentials: %s", err.Error())
+	}
+
+	regions, err := keystone.FetchRegions(cfg.Fqdn, auth)
+	if err != nil {
+		zap.S().Fatalf("Unable to fetch regions: %s", err.Error())
+	}
+
+	var list regionList
+	for _, name := range keystone.RegionNames(regions) {
+		list.Items = append(list.Items, regionItem{Name: name, Fqdn: regions[name], Current: name == cfg.Region})
+	}
+	if printOutput(format, list) {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tREGION\tFQDN")
+	for _, region := range list.Items {
+		current := ""
+		if region.Current {
+			current = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", current, region.Name, region.Fqdn)
+	}
+	w.Flush()
+
+	zap.S().Debug("==========Finished running get regions==========")
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var replaceNodeCmd = &cobra.Command{
+	Use:   "replace-node",
+	Short: "Replaces a node of a cluster by a new node",
+	Long: `Replaces a node of a cluster, e.g. for a hardware refresh. The new node is prepared, attached
+	to the cluster with the role and the labels of the old node and waited for to converge. The old
+	node is then drained, detached and decommissioned. Both nodes are reached with the same ssh
+	credentials.`,
+	Example: "pf9ctl replace-node --old-ip 10.0.0.5 --new-ip 10.0.0.9 --cluster prod -u ubuntu -s ~/.ssh/id_rsa",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return errors.New("No parameters are needed")
+		}
+		return nil
+	},
+	Run: replaceNodeRun,
+}
+
+var (
+	replaceOldIP   string
+	replaceNewIP   string
+	replaceCluster string
+	replaceConfig  objects.NodeConfig
+)
+
+func init() {
+	replaceNodeCmd.Flags().StringVar(&replaceOldIP, "old-ip", "", "IP address of the node to replace")
+	replaceNodeCmd.Flags().StringVar(&replaceNewIP, "new-ip", "", "IP address of the node replacing it")
+	replaceNodeCmd.Flags().StringVar(&replaceCluster, "cluster", "", "name of the cluster of the node")
+	replaceNodeCmd.Flags().StringVarP(&replaceConfig.User, "user", "u", "", "ssh username for the nodes")
+	replaceNodeCmd.Flags().StringVarP(&replaceConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	replaceNodeCmd.Flags().StringVarP(&replaceConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	replaceNodeCmd.Flags().StringVarP(&replaceConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on the nodes")
+	replaceNodeCmd.Flags().StringVar(&replaceConfig.MFA, "mfa", "", "MFA token")
+	replaceNodeCmd.Flags().BoolVarP(&skipChecks, "skip-checks", "c", false, "Will skip optional checks of the new node if true")
+	replaceNodeCmd.Flags().DurationVar(&pmk.ConvergeTimeout, "wait-timeout", pmk.ConvergeTimeout, "how long to wait for the new node to converge")
+	replaceNodeCmd.Flags().DurationVar(&pmk.MaintenanceTimeout, "drain-timeout", pmk.MaintenanceTimeout, "how long to wait for the old node to drain")
+	replaceNodeCmd.Flags().BoolVar(&overrideProtected, overrideProtectedFlag, false, "replace the node even when one of the nodes is protected: it runs the DU, matches protected_hosts or has a protected marker file")
+	replaceNodeCmd.MarkFlagRequired("old-ip")
+	replaceNodeCmd.MarkFlagRequired("new-ip")
+	replaceNodeCmd.MarkFlagRequired("cluster")
+	replaceNodeCmd.RegisterFlagCompletionFunc("old-ip", completeNodeIPsIn(true))
+	replaceNodeCmd.RegisterFlagCompletionFunc("new-ip", completeNodeIPsIn(false))
+	replaceNodeCmd.RegisterFlagCompletionFunc("cluster", completeClusterFlag)
+	rootCmd.AddCommand(replaceNodeCmd)
+}
+
+func replaceNodeRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running replace-node==========")
+	requireWritable("replace-node")
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if skipChecks {
+		pmk.WarningOptionalChecks = true
+	}
+
+	// The new node is prepared first, so the executor of the client reaches it
+	newConfig := replaceConfig
+	newConfig.IPs = []string{replaceNewIP}
+	isRemote := cmdexec.CheckRemote(newConfig)
+	if isRemote {
+		if !config.ValidateNodeConfig(&newConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: replaceConfig.MFA}
+	var err error
+	if detachedMode {
+		newConfig.RemoveExistingPkgs = true
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, newConfig)
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, newConfig)
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + "Loaded Config Successfully")
+	zap.S().Debug("Loaded Config Successfully")
+	// The new node is prepared and the old one decommissioned
+	bothConfig := replaceConfig
+	bothConfig.IPs = []string{replaceNewIP, replaceOldIP}
+	refuseProtectedHosts(cfg, bothConfig, "replace-node")
+
+	executor, err := cmdexec.GetExecutor(cfg.ProxyURL, newConfig)
+	if err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	c, err := client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false)
+	if err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+	auth, err := keystone.Authenticate(c.Keystone, *cfg)
+	if err != nil {
+		zap.S().Fatalf("Unable to obtain keystone credentials: %s", err.Error())
+	}
+	requireRole(auth, "replace-node")
+
+	replacement, err := pmk.PlanReplacement(c, auth, cfg.Fqdn, replaceCluster, replaceOldIP, replaceNewIP)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	fmt.Printf("Replacing %s node %s of cluster %s by node %s\n", replacement.Role, replacement.OldIP, replacement.ClusterName, replacement.NewIP)
+
+	if isRemote {
+		if err := SudoPasswordCheck(executor, detachedMode, newConfig.SudoPassword); err != nil {
+			zap.S().Fatal("Failed executing commands on remote machine with sudo: ", err.Error())
+		}
+	}
+	runPrepNode(cfg, c, auth, newConfig, isRemote, detachedMode)
+
+	if err := attachReplacement(c, auth, cfg, replacement); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+
+	if err := pmk.DrainReplacedNode(auth, cfg.Fqdn, replacement); err != nil {
+		zap.S().Fatalf("Node %s replaces node %s, which is left in the cluster: %s", replacement.NewIP, replacement.OldIP, err.Error())
+	}
+
+	oldConfig := replaceConfig
+	oldConfig.IPs = []string{replacement.OldIP}
+	if err := pmk.DecommissionNode(cfg, oldConfig, true); err != nil {
+		zap.S().Fatalf("Node %s replaces node %s, which is drained but couldn't be decommissioned: %s", replacement.NewIP, replacement.OldIP, err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + fmt.Sprintf("Node %s replaced by node %s in cluster %s", replacement.OldIP, replacement.NewIP, replacement.ClusterName))
+
+	zap.S().Debug("==========Finished running replace-node==========")
+}
+
+// attachReplacement attaches the new node with the role of the old one,
+// waits for it to converge and copies the labels of the old node on it
+func attachReplacement(c client.Client, auth keystone.KeystoneAuth, cfg *objects.Config, r pmk.NodeReplacement) error {
+	in := pmk.AttachNodesInput{
+		ClusterName: r.ClusterName,
+		ClusterUuid: r.ClusterUuid,
+		Region:      cfg.Region,
+		// The cluster has an even number of masters only until the old
+		// master is decommissioned
+		AllowEvenMasters: true,
+	}
+	if r.Role == "master" {
+		in.MasterIPs = []string{r.NewIP}
+	} else {
+		in.WorkerIPs = []string{r.NewIP}
+	}
+	job, err := pmk.NewAttachJob(context.Background(), c, auth, cfg.Fqdn, in)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started job %s, resume it with 'pf9ctl jobs resume %s' if interrupted\n", job.ID, job.ID)
+	if err := pmk.RunJob(c, auth, job); err != nil {
+		return err
+	}
+	if err := pmk.WaitForConvergence(c, auth, []string{r.NewIP}, []string{job.Hosts[0].HostID}, pmk.ConvergeTimeout); err != nil {
+		return fmt.Errorf("Node %s didn't converge, node %s is left in the cluster: %w", r.NewIP, r.OldIP, err)
+	}
+	fmt.Println(color.Green("✓ ") + fmt.Sprintf("Node %s converged", r.NewIP))
+
+	if err := pmk.CopyNodeLabels(auth, cfg.Fqdn, r); err != nil {
+		return fmt.Errorf("Unable to copy the labels %s of node %s: %w", r.LabelList(), r.OldIP, err)
+	}
+	if len(r.Labels) > 0 {
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("Labels %s copied to node %s", r.LabelList(), r.NewIP))
+	}
+	return nil
+}
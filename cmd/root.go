@@ -6,13 +6,20 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	//homedir "github.com/mitchellh/go-homedir"
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/config"
 	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/tracing"
+	"github.com/platform9/pf9ctl/pkg/ui"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var cfgFile string
@@ -27,11 +34,26 @@ var rootCmd = &cobra.Command{
 	Platform9 Managed Kubernetes cluster operations. Read more at
 	http://pf9.io/cli_clhelp.`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := beginQuiet(cmd); err != nil {
+			return err
+		}
+		beginTracing()
 		// Initializing zap log with console and file logging support
 		if err := log.ConfigureGlobalLog(verbosity, util.Pf9Log); err != nil {
 			return fmt.Errorf("log initialization failed: %s", err)
 		}
-		return nil
+		zap.S().Debugf("Correlation ID of the operation: %s", log.CorrelationID)
+		// The stored config may not exist yet, e.g: for 'config set'
+		stored, _ := config.ReadStoredConfig(util.Pf9DBLoc)
+		configureFIPS(stored)
+		if err := configureSOCKS(stored); err != nil {
+			return err
+		}
+		return configureHostKeys(cmd, stored)
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		flushTraces("")
+		ui.EndQuiet(0, "")
 	},
 }
 
@@ -48,6 +70,82 @@ func Execute() {
 	}
 }
 
+// beginQuiet silences the output of the command with --quiet, or with
+// --summary-format json which implies it. The prompts are disabled as they couldn't be
+// answered.
+func beginQuiet(cmd *cobra.Command) error {
+	switch ui.SummaryFormat {
+	case ui.SummaryText:
+	case ui.SummaryJSON:
+		ui.Quiet = true
+	default:
+		return fmt.Errorf("invalid --summary-format %q, it is either %s or %s", ui.SummaryFormat, ui.SummaryText, ui.SummaryJSON)
+	}
+	if !ui.Quiet {
+		return nil
+	}
+	if flag := cmd.Flags().Lookup("no-prompt"); flag != nil && !flag.Changed {
+		cmd.Flags().Set("no-prompt", "true")
+	}
+	log.Quiet = true
+	log.EntryHook = func(entry zapcore.Entry) error {
+		switch entry.Level {
+		case zapcore.WarnLevel, zapcore.ErrorLevel:
+			ui.CountWarning()
+		case zapcore.FatalLevel:
+			ui.EndQuiet(1, entry.Message)
+		}
+		return nil
+	}
+	return ui.BeginQuiet(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" "))
+}
+
+// beginTracing exports the spans of the operations when the command exits on
+// a fatal error too, ending the running ones with its message
+func beginTracing() {
+	if !tracing.Enabled() {
+		return
+	}
+	next := log.EntryHook
+	log.EntryHook = func(entry zapcore.Entry) error {
+		if entry.Level == zapcore.FatalLevel {
+			flushTraces(entry.Message)
+		}
+		if next != nil {
+			return next(entry)
+		}
+		return nil
+	}
+}
+
+// flushTraces exports the spans of the command, the command goes on when
+// they can't be
+func flushTraces(reason string) {
+	if err := tracing.Flush(reason); err != nil {
+		zap.S().Debugf("%s", err.Error())
+	}
+}
+
+// exit exits with code once the summary of --quiet is printed
+func exit(code int) {
+	reason := ""
+	if code != 0 {
+		reason = fmt.Sprintf("exit status %d", code)
+	}
+	flushTraces(reason)
+	ui.EndQuiet(code, "")
+	os.Exit(code)
+}
+
+// localIP returns the IP of this machine, for the commands run on it
+func localIP() string {
+	ip, err := pmk.GetIp()
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	return ip.String()
+}
+
 func initializeBaseDirs() (err error) {
 	err = os.MkdirAll(util.Pf9Dir, 0700)
 	if err != nil {
@@ -67,6 +165,18 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&verbosity, "verbose", false, "print verbose logs")
 	rootCmd.PersistentFlags().BoolVar(&detach, "no-prompt", false, "disable all user prompts")
 	rootCmd.PersistentFlags().StringVar(&logDirPath, "log-dir", "", "path to save logs")
+	rootCmd.PersistentFlags().BoolVar(&log.PerHostLogs, "per-host-logs", false, "also write the logs of every node to its own file, in a directory named after the node under the log directory")
+	rootCmd.PersistentFlags().BoolVar(&log.TraceAPI, "trace-api", false, "log the method, URL, status, latency and request IDs of the Platform9 API calls to the debug log")
+	rootCmd.PersistentFlags().StringVar(&tracing.Endpoint, "otlp-endpoint", "", "export OpenTelemetry traces of the phases of the node operations to this OTLP/HTTP collector, e.g: http://localhost:4318 (default $"+tracing.EndpointEnv+")")
+	rootCmd.PersistentFlags().StringVar(&tracing.File, "trace-file", "", "append OpenTelemetry traces of the phases of the node operations to this file, as OTLP JSON")
+	rootCmd.PersistentFlags().BoolVar(&ui.Plain, "plain", false, "disable spinners and print progress as plain text")
+	rootCmd.PersistentFlags().BoolVarP(&ui.Quiet, "quiet", "q", false, "only print a summary line when the command finishes, for cron jobs and CI; implies --no-prompt")
+	rootCmd.PersistentFlags().StringVar(&ui.SummaryFormat, "summary-format", ui.SummaryText, "format of the --quiet summary line: text or json, json implies --quiet")
+	rootCmd.PersistentFlags().Float64Var(&client.APIRateLimit, "api-rps", client.DefaultAPIRateLimit, "maximum number of requests per second sent to the Platform9 APIs, 0 disables the limit")
+	rootCmd.PersistentFlags().StringVar(&config.Tenant, "tenant", "", "tenant to run the command in, overriding the one of the config")
+	rootCmd.PersistentFlags().BoolVar(&config.PasswordStdin, "password-stdin", false, "read the password of the account from stdin")
+	rootCmd.PersistentFlags().BoolVar(&config.MFAStdin, "mfa-stdin", false, "read the MFA token from stdin, on the line after the password with --password-stdin")
+	rootCmd.PersistentFlags().IntVar(&config.SSHKeyFD, "ssh-key-fd", -1, "read the SSH key of the nodes from this file descriptor, e.g: --ssh-key-fd 3 3<~/.ssh/id_rsa")
 	//rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.pf9ctl.yaml)")
 	//rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/ssh"
+	"github.com/platform9/pf9ctl/pkg/ui"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run [flags] -- command",
+	Short: "Runs a command on a set of nodes",
+	Long: `Runs an arbitrary command on each of the given nodes over SSH and prints the
+	aggregated output. Useful for troubleshooting a fleet of nodes. The words after -- are
+	passed to the command as they are, a single quoted word is run by bash for pipelines.`,
+	Example: `pf9ctl run --ip 10.0.0.1,10.0.0.2 -u ubuntu -s ~/.ssh/id_rsa -- systemctl status pf9-hostagent
+pf9ctl run --ip 10.0.0.1 -u ubuntu -s ~/.ssh/id_rsa -- 'journalctl -u pf9-hostagent | tail -n 20'`,
+	Args: func(runCmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errors.New("command to run is required, pass it after --")
+		}
+		return nil
+	},
+	Run: runCmdRun,
+}
+
+var (
+	runConfig   objects.NodeConfig
+	runParallel int
+)
+
+func init() {
+	runCmd.Flags().StringVarP(&runConfig.User, "user", "u", "", "ssh username for the nodes")
+	runCmd.Flags().StringVarP(&runConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	runCmd.Flags().StringVarP(&runConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	runCmd.Flags().StringSliceVarP(&runConfig.IPs, "ip", "i", []string{}, "IP address of the hosts to run the command on")
+	runCmd.Flags().StringVar(&runConfig.MFA, "mfa", "", "MFA token")
+	runCmd.Flags().StringVarP(&runConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	runCmd.Flags().IntVar(&runParallel, "parallel", cmdexec.DefaultParallelism, "maximum number of nodes to run the command on concurrently")
+	rootCmd.AddCommand(runCmd)
+}
+
+func runCmdRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running run==========")
+	requireWritable("run")
+
+	if len(runConfig.IPs) == 0 {
+		zap.S().Fatalf("No nodes were specified, use --ip to pass the nodes")
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+
+	if err := cmdexec.CheckLocal(runConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	if cmdexec.CheckRemote(runConfig) {
+		if !config.ValidateNodeConfig(&runConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: runConfig.MFA}
+	var err error
+	if detachedMode {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, runConfig)
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, runConfig)
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+	zap.S().Debug("Loaded Config Successfully")
+
+	ssh.SudoPassword = runConfig.SudoPassword
+	newExecutor := func(host string) (cmdexec.Executor, error) {
+		hostConfig := runConfig
+		hostConfig.IPs = []string{host}
+		return cmdexec.GetExecutor(cfg.ProxyURL, hostConfig)
+	}
+
+	command := runCommand(args)
+	progress := ui.StartProgress(fmt.Sprintf("Running command on %d node(s)", len(runConfig.IPs)), runConfig.IPs)
+	results := cmdexec.RunOnHostsWithProgress(runConfig.IPs, runParallel, newExecutor, command, progress)
+	progress.Stop()
+	fmt.Println()
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Printf(color.Red("x ")+"%s\n", result.Host)
+		} else {
+			fmt.Printf(color.Green("✓ ")+"%s\n", result.Host)
+		}
+		if result.Stdout != "" {
+			fmt.Println(strings.TrimRight(result.Stdout, "\n"))
+		}
+		if result.Err != nil {
+			fmt.Printf("Error: %s\n", cmdexec.ConfidentialInfoRemover(result.Err.Error()))
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Command completed on %d/%d node(s)\n", len(results)-failed, len(results))
+	zap.S().Debug("==========Finished running run==========")
+	if failed > 0 {
+		exit(1)
+	}
+}
+
+// runCommand returns the bash command running args, a single arg is a command
+// line of its own while several are the words of the command
+func runCommand(args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+	words := make([]string, len(args))
+	for i, arg := range args {
+		words[i] = cmdexec.ShellQuote(arg)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,93 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/signature"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var signingKeysCmd = &cobra.Command{
+	Use:   "signing-keys",
+	Short: "Manages the keys the signatures of the installers are verified with",
+	Long: `prep-node and bootstrap verify the GPG signature of the installer downloaded from the
+	DU against the signing keys of Platform9, bundled with pf9ctl and refreshable from where
+	Platform9 publishes them.`,
+}
+
+var signingKeysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the signing keys the installers are verified with",
+	Args:  cobra.NoArgs,
+	Run:   signingKeysListRun,
+}
+
+var signingKeysRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Downloads the signing keys published by Platform9",
+	Long: `Downloads the signing keys published by Platform9 and uses them instead of the bundled
+	ones. The new keys must be signed by the current ones, with a detached signature
+	published next to them with the .asc extension, so a key rotation can't be forged.
+	A build without bundled keys only trusts the downloaded ones with --trust-on-first-use.`,
+	Args: cobra.NoArgs,
+	Run:  signingKeysRefreshRun,
+}
+
+var (
+	signingKeysURL  string
+	trustOnFirstUse bool
+)
+
+func init() {
+	signingKeysRefreshCmd.Flags().StringVar(&signingKeysURL, "url", "", "https URL of the armored signing keys (default where Platform9 publishes them)")
+	signingKeysRefreshCmd.Flags().BoolVar(&trustOnFirstUse, "trust-on-first-use", false, "Trust the downloaded keys when no keys are bundled or refreshed to verify them with, check their fingerprints with 'pf9ctl signing-keys list' afterwards")
+	signingKeysCmd.AddCommand(signingKeysListCmd)
+	signingKeysCmd.AddCommand(signingKeysRefreshCmd)
+	rootCmd.AddCommand(signingKeysCmd)
+}
+
+func signingKeysListRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running signing-keys list==========")
+
+	keys, err := signature.Keyring()
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	source := "bundled"
+	if _, err := os.Stat(signature.KeyringFile); err == nil {
+		source = "refreshed, " + signature.KeyringFile
+	}
+	fmt.Printf("Signing keys (%s):\n", source)
+	for _, key := range keys {
+		fmt.Printf("  %s %s\n", signature.Fingerprint(key), signature.Identity(key))
+	}
+
+	zap.S().Debug("==========Finished running signing-keys list==========")
+}
+
+func signingKeysRefreshRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running signing-keys refresh==========")
+
+	keysURL := firstNonEmpty(signingKeysURL, signature.KeysURL)
+	if keysURL == "" {
+		zap.S().Fatalf("This build doesn't know where the signing keys are published, pass their URL with --url")
+	}
+	keys, signer, err := signature.Refresh(http.DefaultClient, keysURL, trustOnFirstUse)
+	if err != nil {
+		zap.S().Fatalf("Unable to refresh the signing keys: %s", err.Error())
+	}
+	if signer == nil {
+		fmt.Println(color.Yellow("! ") + fmt.Sprintf("Trusted the signing keys of %s on first use, check their fingerprints with 'pf9ctl signing-keys list'", keysURL))
+	} else {
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("The signing keys of %s are signed by the current key %s", keysURL, signature.Fingerprint(signer)))
+	}
+	fmt.Println(color.Green("✓ ") + fmt.Sprintf("Refreshed %d signing key(s)", len(keys)))
+
+	zap.S().Debug("==========Finished running signing-keys refresh==========")
+}
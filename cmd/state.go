@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Manages the local state store of pf9ctl",
+	Long: `The state store keeps the jobs of the batch operations and the names cached for shell
+	completion, under the db dir of pf9ctl.`,
+}
+
+var statePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Removes the hosts which no longer exist in the DU from the state store",
+	Long: `Cross-checks the hosts tracked by the jobs against the hosts of resmgr and removes those
+	which no longer exist, deleting the jobs left without hosts, then refreshes the hosts cached for
+	shell completion. Only the jobs of the DU and tenant of the config are pruned. The jobs created
+	before pf9ctl recorded their DU may belong to another DU, they are only pruned with
+	--include-legacy. Use --dry-run to only list what would be removed.`,
+	Example: `pf9ctl state prune --dry-run
+	pf9ctl state prune`,
+	Args: cobra.NoArgs,
+	Run:  statePruneRun,
+}
+
+var (
+	statePruneDryRun        bool
+	statePruneIncludeLegacy bool
+	statePruneMFA           string
+)
+
+func init() {
+	statePruneCmd.Flags().BoolVar(&statePruneDryRun, "dry-run", false, "list the entries which would be removed without removing them")
+	statePruneCmd.Flags().BoolVar(&statePruneIncludeLegacy, "include-legacy", false, "also prune the jobs created before pf9ctl recorded their DU, which may belong to another DU")
+	statePruneCmd.Flags().StringVar(&statePruneMFA, "mfa", "", "MFA token")
+	stateCmd.AddCommand(statePruneCmd)
+	rootCmd.AddCommand(stateCmd)
+}
+
+func statePruneRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running state prune==========")
+
+	cfg, c, auth := loadClient(cmd, statePruneMFA)
+	defer c.Segment.Close()
+
+	pruned, err := pmk.PruneState(c, auth, cfg.Fqdn, cfg.Tenant, statePruneIncludeLegacy, statePruneDryRun)
+	if err != nil {
+		zap.S().Fatalf("Unable to prune the state store: %s", err.Error())
+	}
+	if len(pruned) == 0 {
+		fmt.Println("No stale host in the state store")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "JOB\tOPERATION\tSTALE HOSTS\tACTION")
+	for _, job := range pruned {
+		var hosts []string
+		for _, host := range job.Hosts {
+			hosts = append(hosts, fmt.Sprintf("%s (%s)", host.IP, host.HostID))
+		}
+		action := "removed hosts"
+		if job.Deleted {
+			action = "deleted job"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", job.ID, job.Operation, strings.Join(hosts, ", "), action)
+	}
+	w.Flush()
+	if statePruneDryRun {
+		fmt.Println("Dry run, nothing was removed")
+	}
+
+	zap.S().Debug("==========Finished running state prune==========")
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Reports the health of the pf9 services of nodes",
+	Long: `Reports whether pf9-hostagent and pf9-comms run on the nodes and the health the watchdog
+	installed by prep-node --install-watchdog last recorded: the failures, the last restart and when it
+	restarts the services next. Checks the local node unless --ip is given. Exits with an error when
+	the services of a node are unhealthy.`,
+	Example: `pf9ctl status
+	pf9ctl status --ip 10.0.0.1 --ip 10.0.0.2 -u ubuntu -s ~/.ssh/id_rsa`,
+	Args: cobra.NoArgs,
+	Run:  statusRun,
+}
+
+var statusConfig objects.NodeConfig
+
+func init() {
+	statusCmd.Flags().StringVarP(&statusConfig.User, "user", "u", "", "ssh username for the nodes")
+	statusCmd.Flags().StringVarP(&statusConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	statusCmd.Flags().StringVarP(&statusConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	statusCmd.Flags().StringSliceVarP(&statusConfig.IPs, "ip", "i", []string{}, "IP address of the nodes")
+	statusCmd.Flags().StringVarP(&statusConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	statusCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
+	rootCmd.AddCommand(statusCmd)
+}
+
+func statusRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running status==========")
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if err := cmdexec.CheckLocal(statusConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	isRemote := cmdexec.CheckRemote(statusConfig)
+	if isRemote {
+		if !config.ValidateNodeConfig(&statusConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	ips := statusConfig.IPs
+	if len(ips) == 0 {
+		ips = []string{"localhost"}
+	}
+
+	unhealthy := 0
+	for _, ip := range ips {
+		nodeCfg := statusConfig
+		nodeCfg.IPs = []string{ip}
+		executor, err := cmdexec.GetExecutor("", nodeCfg)
+		if err == nil && isRemote {
+			err = SudoPasswordCheck(executor, detachedMode, nodeCfg.SudoPassword)
+		}
+		if err != nil {
+			unhealthy++
+			fmt.Println(color.Red("x ") + fmt.Sprintf("Unable to check node %s: %s", ip, err))
+			continue
+		}
+		if !printNodeStatus(ip, pmk.InactiveServices(executor), pmk.ReadWatchdogHealth(executor)) {
+			unhealthy++
+		}
+	}
+	if unhealthy > 0 {
+		zap.S().Fatalf("The pf9 services of %d node(s) are unhealthy", unhealthy)
+	}
+
+	zap.S().Debug("==========Finished running status==========")
+}
+
+// printNodeStatus prints the services and watchdog of the node at ip,
+// returning false when its services are unhealthy
+func printNodeStatus(ip string, inactive []string, health pmk.WatchdogHealth) bool {
+	healthy := len(inactive) == 0
+	if healthy {
+		fmt.Println(color.Green("✓ ") + fmt.Sprintf("Node %s: pf9-hostagent and pf9-comms are running", ip))
+	} else {
+		fmt.Println(color.Red("x ") + fmt.Sprintf("Node %s: %s not running", ip, strings.Join(inactive, " and ")))
+	}
+
+	when := func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Local().Format(time.RFC3339)
+	}
+	switch {
+	case !health.Installed:
+		fmt.Println("  Watchdog: not installed, add it with prep-node --install-watchdog")
+		return healthy
+	case !health.Active:
+		fmt.Println(color.Yellow("! ") + "Watchdog: installed but its timer isn't running, start it with 'systemctl enable --now pf9ctl-watchdog.timer'")
+	case health.Status == "":
+		fmt.Println("  Watchdog: no check recorded yet")
+		return healthy
+	case health.Status == "unhealthy":
+		healthy = false
+		fmt.Println(color.Red("x ") + fmt.Sprintf("Watchdog: unhealthy at %s, %s", when(health.LastCheck), health.Reason))
+	default:
+		fmt.Printf("  Watchdog: %s at %s\n", health.Status, when(health.LastCheck))
+	}
+	if health.Failures > 0 || !health.LastRestart.IsZero() {
+		fmt.Printf("  Restarts: %d failed in a row, last restart %s\n", health.Failures, when(health.LastRestart))
+	}
+	if !health.NextRestart.IsZero() {
+		fmt.Printf("  Next restart: %s at the earliest\n", when(health.NextRestart))
+	}
+	return healthy
+}
@@ -45,6 +45,9 @@ func supportBundleUpload(cmd *cobra.Command, args []string) {
 	zap.S().Debug("==========Running supportBundleUpload==========")
 
 	detachedMode := cmd.Flags().Changed("no-prompt")
+	if err := cmdexec.CheckLocal(bundleConfig); err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
 	isRemote := cmdexec.CheckRemote(bundleConfig)
 
 	if isRemote {
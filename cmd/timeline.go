@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var timelineCmd = &cobra.Command{
+	Use:   "timeline",
+	Short: "Shows the events of a host in chronological order",
+	Long: `Merges the jobs and log entries of pf9ctl about a host with what resmgr and qbert report
+	about it, its hostagent reports, role and convergence, into a single chronological view.
+	The current state reported by the DU is shown at the time of the query.`,
+	Example: "pf9ctl timeline --ip 10.0.0.1 --since 24h",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return errors.New("please pass the IP of the host using --ip")
+		}
+		return nil
+	},
+	Run: timelineRun,
+}
+
+var (
+	timelineIP    string
+	timelineSince time.Duration
+	timelineMFA   string
+)
+
+func init() {
+	timelineCmd.Flags().StringVarP(&timelineIP, "ip", "i", "", "IP address of the host")
+	timelineCmd.Flags().DurationVar(&timelineSince, "since", 7*24*time.Hour, "show the events within this duration, 0 for all of them")
+	timelineCmd.Flags().StringVar(&timelineMFA, "mfa", "", "MFA token")
+	timelineCmd.MarkFlagRequired("ip")
+	timelineCmd.RegisterFlagCompletionFunc("ip", completeNodeIPs)
+	rootCmd.AddCommand(timelineCmd)
+}
+
+func timelineRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running timeline==========")
+
+	_, c, auth := loadClient(cmd, timelineMFA)
+	defer c.Segment.Close()
+
+	var since time.Time
+	if timelineSince > 0 {
+		since = time.Now().Add(-timelineSince)
+	}
+	events, err := pmk.HostTimeline(c, auth, timelineIP, since)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSOURCE\tEVENT")
+	for _, event := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", event.Time.Local().Format(time.RFC3339), event.Source, event.Message)
+	}
+	w.Flush()
+}
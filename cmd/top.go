@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Displays the resource usage of clusters",
+}
+
+var topClusterCmd = &cobra.Command{
+	Use:   "cluster <name>",
+	Short: "Displays the CPU and memory usage and the pods of the nodes of a cluster",
+	Long: `Prints the CPU and memory used on each node of the cluster, out of what is allocatable to
+	the pods, and the number of pods scheduled on it, read through the k8s API proxy of qbert. The usage
+	comes from the metrics API, which needs metrics-server to run on the cluster; without it only the
+	pods and the allocatable resources are printed.`,
+	Example:           "pf9ctl top cluster my-cluster",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeClusterNames,
+	Run:               topClusterRun,
+}
+
+var topMFA string
+
+func init() {
+	topClusterCmd.Flags().StringVar(&topMFA, "mfa", "", "MFA token")
+	topCmd.AddCommand(topClusterCmd)
+	rootCmd.AddCommand(topCmd)
+}
+
+func topClusterRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running top cluster==========")
+
+	cfg, c, auth := loadClient(cmd, topMFA)
+	defer c.Segment.Close()
+
+	uuid := clusterUUID(c, auth, args[0])
+	nodes, err := pmk.ClusterTop(cfg.Fqdn, uuid, auth.Token)
+	if err != nil && err != pmk.ErrNoMetrics {
+		zap.S().Fatalf("Unable to get the usage of cluster %s: %s", args[0], err.Error())
+	}
+	if err == pmk.ErrNoMetrics {
+		fmt.Println(color.Yellow("! ") + err.Error() + ", only the pods and the allocatable resources are shown")
+	}
+
+	var total pmk.NodeTop
+	total.HasMetrics = err == nil
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NODE\tROLE\tSTATUS\tCPU(cores)\tCPU%\tMEMORY\tMEMORY%\tPODS")
+	for _, node := range nodes {
+		status := "Ready"
+		if !node.Ready {
+			status = "NotReady"
+		}
+		printNodeTop(w, node.Name, node.Role, status, node)
+		total.CPUUsage += node.CPUUsage
+		total.CPUAllocatable += node.CPUAllocatable
+		total.MemoryUsage += node.MemoryUsage
+		total.MemoryAllocatable += node.MemoryAllocatable
+		total.Pods += node.Pods
+		total.PodCapacity += node.PodCapacity
+	}
+	if len(nodes) > 1 {
+		printNodeTop(w, "TOTAL", "", "", total)
+	}
+	w.Flush()
+
+	zap.S().Debug("==========Finished running top cluster==========")
+}
+
+func printNodeTop(w *tabwriter.Writer, name, role, status string, node pmk.NodeTop) {
+	cpu := fmt.Sprintf("-/%s", formatCores(node.CPUAllocatable))
+	cpuPercent, memory, memoryPercent := "-", fmt.Sprintf("-/%s", formatBytes(node.MemoryAllocatable)), "-"
+	if node.HasMetrics {
+		cpu = fmt.Sprintf("%s/%s", formatCores(node.CPUUsage), formatCores(node.CPUAllocatable))
+		cpuPercent = fmt.Sprintf("%d%%", node.CPUPercent())
+		memory = fmt.Sprintf("%s/%s", formatBytes(node.MemoryUsage), formatBytes(node.MemoryAllocatable))
+		memoryPercent = fmt.Sprintf("%d%%", node.MemoryPercent())
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d/%d\n", name, role, status, cpu, cpuPercent, memory, memoryPercent, node.Pods, node.PodCapacity)
+}
+
+// formatCores formats millicores as cores
+func formatCores(millicores int64) string {
+	return fmt.Sprintf("%.2f", float64(millicores)/1000)
+}
+
+// formatBytes formats bytes in the largest binary unit
+func formatBytes(bytes int64) string {
+	units := []string{"B", "Ki", "Mi", "Gi", "Ti"}
+	value := float64(bytes)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d%s", bytes, units[0])
+	}
+	return fmt.Sprintf("%.1f%s", value, units[unit])
+}
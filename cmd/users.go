@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+var getUsersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Lists the users of the Platform9 account",
+	Example: `pf9ctl get users
+	pf9ctl get users -o jsonpath='{.items[?(@.enabled==true)].name}'`,
+	Args: cobra.NoArgs,
+	Run:  getUsersRun,
+}
+
+var createUserCmd = &cobra.Command{
+	Use:   "create-user <name>",
+	Short: "Creates a user with a role in a tenant",
+	Long: `Creates a user and grants it a role in a tenant, by default the self-service role in
+	the tenant of the config. The password is prompted for when --user-password isn't set.`,
+	Example: "pf9ctl create-user ci-bot --email ci@example.com --tenant service",
+	Args:    cobra.ExactArgs(1),
+	Run:     createUserRun,
+}
+
+var assignRoleCmd = &cobra.Command{
+	Use:     "assign-role <user>",
+	Short:   "Grants a role in a tenant to a user",
+	Example: "pf9ctl assign-role ci-bot --role _member_ --tenant service",
+	Args:    cobra.ExactArgs(1),
+	Run:     assignRoleRun,
+}
+
+var (
+	usersMFA     string
+	userPassword string
+	userEmail    string
+	userTenant   string
+	userRole     string
+)
+
+func init() {
+	getUsersCmd.Flags().StringVar(&usersMFA, "mfa", "", "MFA token")
+	addOutputFlag(getUsersCmd)
+	getCmd.AddCommand(getUsersCmd)
+
+	createUserCmd.Flags().StringVar(&userPassword, "user-password", "", "password of the new user (use 'single quotes' to pass password)")
+	createUserCmd.Flags().StringVar(&userEmail, "email", "", "email of the new user")
+	createUserCmd.Flags().StringVar(&userTenant, "tenant", "", "tenant to grant the role in (default the tenant of the config)")
+	createUserCmd.Flags().StringVar(&userRole, "role", keystone.SelfServiceRole, "role to grant to the user")
+	createUserCmd.Flags().StringVar(&usersMFA, "mfa", "", "MFA token")
+	rootCmd.AddCommand(createUserCmd)
+
+	assignRoleCmd.Flags().StringVar(&userTenant, "tenant", "", "tenant to grant the role in (default the tenant of the config)")
+	assignRoleCmd.Flags().StringVar(&userRole, "role", keystone.SelfServiceRole, "role to grant to the user")
+	assignRoleCmd.Flags().StringVar(&usersMFA, "mfa", "", "MFA token")
+	rootCmd.AddCommand(assignRoleCmd)
+}
+
+func getUsersRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running get users==========")
+	format := parseOutput()
+
+	cfg, c, auth := loadClient(cmd, usersMFA)
+	defer c.Segment.Close()
+
+	users, err := keystone.ListUsers(cfg.Fqdn, auth)
+	if err != nil {
+		zap.S().Fatalf("Unable to list users: %s", err.Error())
+	}
+
+	if printOutput(format, map[string][]keystone.User{"items": users}) {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tEMAIL\tENABLED")
+	for _, user := range users {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", user.Name, user.ID, user.Email, user.Enabled)
+	}
+	w.Flush()
+
+	zap.S().Debug("==========Finished running get users==========")
+}
+
+func createUserRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running create-user==========")
+
+	if userPassword == "" {
+		if cmd.Flags().Changed("no-prompt") {
+			zap.S().Fatal("--user-password is required with --no-prompt")
+		}
+		fmt.Printf("Password of user %s: ", args[0])
+		password, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			zap.S().Fatalf("Unable to read the password: %s", err.Error())
+		}
+		userPassword = string(password)
+	}
+	if userPassword == "" {
+		zap.S().Fatal("The password of the user can't be empty")
+	}
+
+	cfg, c, auth := loadClient(cmd, usersMFA)
+	defer c.Segment.Close()
+	requireRole(auth, "create-user")
+
+	project, role := findProjectAndRole(cfg.Fqdn, cfg.Tenant, auth)
+	user, err := keystone.CreateUser(cfg.Fqdn, auth, args[0], userPassword, userEmail, project.ID)
+	if err != nil {
+		zap.S().Fatalf("Unable to create user %s: %s", args[0], err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + fmt.Sprintf("User %s created (%s)", user.Name, user.ID))
+
+	if err := keystone.AssignRole(cfg.Fqdn, auth, project.ID, user.ID, role.ID); err != nil {
+		zap.S().Fatalf("Unable to grant role %s to user %s, grant it with 'pf9ctl assign-role': %s", role.Name, user.Name, err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + fmt.Sprintf("Role %s granted to user %s in tenant %s", role.Name, user.Name, project.Name))
+
+	zap.S().Debug("==========Finished running create-user==========")
+}
+
+func assignRoleRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running assign-role==========")
+
+	cfg, c, auth := loadClient(cmd, usersMFA)
+	defer c.Segment.Close()
+	requireRole(auth, "assign-role")
+
+	user, err := keystone.FindUser(cfg.Fqdn, auth, args[0])
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	project, role := findProjectAndRole(cfg.Fqdn, cfg.Tenant, auth)
+	if err := keystone.AssignRole(cfg.Fqdn, auth, project.ID, user.ID, role.ID); err != nil {
+		zap.S().Fatalf("Unable to grant role %s to user %s: %s", role.Name, user.Name, err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + fmt.Sprintf("Role %s granted to user %s in tenant %s", role.Name, user.Name, project.Name))
+
+	zap.S().Debug("==========Finished running assign-role==========")
+}
+
+// findProjectAndRole looks up the tenant and the role of the flags, the tenant
+// defaults to the one of the config
+func findProjectAndRole(fqdn, configTenant string, auth keystone.KeystoneAuth) (keystone.Project, keystone.Role) {
+	tenant := userTenant
+	if tenant == "" {
+		tenant = configTenant
+	}
+	project, err := keystone.FindProject(fqdn, auth, tenant)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	role, err := keystone.FindRole(fqdn, auth, userRole)
+	if err != nil {
+		zap.S().Fatalf("%s", err.Error())
+	}
+	return project, role
+}
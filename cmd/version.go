@@ -15,7 +15,10 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/fips"
+	"github.com/platform9/pf9ctl/pkg/ui"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -32,6 +35,9 @@ var versionCmd = &cobra.Command{
 		zap.S().Debug("Version called")
 		//Prints the current version of pf9ctl being used.
 		fmt.Println(util.Version)
+		if fips.BuiltIn() {
+			fmt.Println("FIPS build, the connections are restricted to the algorithms approved by FIPS 140")
+		}
 	},
 }
 
@@ -116,6 +122,10 @@ func getEtag() string {
 }
 
 func upgradeVersion() error {
+	// The installer is a bash script
+	if !cmdexec.LocalSupported {
+		return fmt.Errorf("the CLI can only be upgraded in place on Linux")
+	}
 
 	fmt.Println("\nDownloading the CLI")
 	curlCmd, err := exec.Command("curl", "-sL", util.BucketPath).Output()
@@ -134,6 +144,11 @@ func upgradeVersion() error {
 }
 
 func checkVersionInit() {
+	// The output of the completion is read by the shell, and quiet runs
+	// only print their summary
+	if isCompletion() || ui.Quiet || ui.SummaryFormat == ui.SummaryJSON {
+		return
+	}
 	newVersion, err := getLatestVersion()
 	if err != nil {
 		fmt.Println("Error checking versions ", err)
@@ -26,7 +26,8 @@ require (
 	go.uber.org/zap v1.10.0
 	golang.org/x/crypto v0.0.0-20210513164829-c07d793c2f9a
 	golang.org/x/net v0.0.0-20210614182718-04defd469f4e
+	golang.org/x/sys v0.0.0-20210823070655-63515b42dcdf
 	google.golang.org/api v0.56.0
 	gopkg.in/segmentio/analytics-go.v3 v3.1.0
-
+	gopkg.in/yaml.v2 v2.2.8
 )
@@ -4,18 +4,67 @@ package client
 import (
 	"crypto/tls"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/fips"
 	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/log"
 	"github.com/platform9/pf9ctl/pkg/qbert"
 	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/platform9/pf9ctl/pkg/socks"
 )
 
 const HTTPMaxRetry = 15
 const HTTPRetryMinWait = 10 * time.Second
 const HTTPRetryMaxWait = 30 * time.Second
 
+// baseTransport is the transport the ones the requests are finally sent with
+// are cloned from, it is left as it is
+var baseTransport = http.DefaultTransport.(*http.Transport).Clone()
+
+// otherTransport sends the requests to the hosts which aren't the DU of a
+// client, e.g. the analytics
+var otherTransport *http.Transport
+
+var installTransport sync.Once
+
+// duTransports are the transports of the DUs the clients were created for, by
+// host, each with the TLS settings of its client
+var duTransports = struct {
+	sync.RWMutex
+	hosts map[string]*http.Transport
+}{hosts: make(map[string]*http.Transport)}
+
+// newTransport returns a transport of its own, verifying the certificates of
+// the servers unless allowInsecure is set
+func newTransport(allowInsecure bool) *http.Transport {
+	t := baseTransport.Clone()
+	if allowInsecure {
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	t.TLSClientConfig = fips.TLSConfig(t.TLSClientConfig)
+	t.Proxy = socks.Proxy
+	return t
+}
+
+// duTransport sends the requests through the transport of the DU they are
+// sent to, so that all of them share the rate limit
+type duTransport struct{}
+
+// RoundTrip implements http.RoundTripper
+func (duTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	duTransports.RLock()
+	t, found := duTransports.hosts[req.URL.Host]
+	duTransports.RUnlock()
+	if !found {
+		t = otherTransport
+	}
+	return t.RoundTrip(req)
+}
+
 // Clients struct encapsulate the collection of
 // external services
 type Client struct {
@@ -29,12 +78,26 @@ type Client struct {
 // New creates the clients needed by the CLI
 // to interact with the external services.
 func NewClient(fqdn string, executor cmdexec.Executor, allowInsecure bool, noTracking bool) (Client, error) {
-	// Bring the hammer down to make default http allow insecure
-	if allowInsecure {
-		http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	// The service clients send their requests through the default transport,
+	// which sends those to fqdn through a transport of this client
+	installTransport.Do(func() {
+		otherTransport = newTransport(false)
+		http.DefaultTransport = NewReadOnlyTransport(NewRateLimitedTransport(log.NewCorrelatingTransport(log.NewTracingTransport(duTransport{})), APIRateLimit))
+	})
+	host := fqdn
+	if u, err := url.Parse(fqdn); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	duTransports.Lock()
+	duTransports.hosts[host] = newTransport(allowInsecure)
+	duTransports.Unlock()
+	guardReadOnly(fqdn)
+	rm := resmgr.NewResmgr(fqdn, HTTPMaxRetry, HTTPRetryMinWait, HTTPRetryMaxWait, allowInsecure)
+	if ReadOnly {
+		rm = readOnlyResmgr{rm}
 	}
 	return Client{
-		Resmgr:   resmgr.NewResmgr(fqdn, HTTPMaxRetry, HTTPRetryMinWait, HTTPRetryMaxWait, allowInsecure),
+		Resmgr:   rm,
 		Keystone: keystone.NewKeystone(fqdn),
 		Qbert:    qbert.NewQbert(fqdn),
 		Executor: executor,
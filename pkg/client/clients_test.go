@@ -0,0 +1,27 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClientTransports(t *testing.T) {
+	defer func() {
+		duTransports.hosts = make(map[string]*http.Transport)
+	}()
+	_, err := NewClient("https://insecure.example.com", nil, true, true)
+	assert.Nil(t, err)
+	_, err = NewClient("https://du.example.com:8443", nil, false, true)
+	assert.Nil(t, err)
+
+	// Each DU has a transport of its own, the base one is left as it is
+	insecure, secure := duTransports.hosts["insecure.example.com"], duTransports.hosts["du.example.com:8443"]
+	assert.True(t, insecure.TLSClientConfig.InsecureSkipVerify)
+	assert.True(t, secure.TLSClientConfig == nil || !secure.TLSClientConfig.InsecureSkipVerify)
+	assert.True(t, baseTransport.TLSClientConfig == nil || !baseTransport.TLSClientConfig.InsecureSkipVerify)
+	assert.True(t, otherTransport.TLSClientConfig == nil || !otherTransport.TLSClientConfig.InsecureSkipVerify)
+	_, wrapped := http.DefaultTransport.(*ReadOnlyTransport)
+	assert.True(t, wrapped)
+}
@@ -0,0 +1,175 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultAPIRateLimit is the default number of requests per second sent to the DU
+const DefaultAPIRateLimit = 10
+
+// APIRateLimit is the maximum number of requests per second sent to the DU
+// APIs, batch commands querying resmgr and qbert for hundreds of hosts would
+// otherwise trip the DU throttling. A value of 0 disables the limit.
+var APIRateLimit float64 = DefaultAPIRateLimit
+
+// tokenBucket allows rate requests per second on average with bursts of up to
+// burst requests.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, now: time.Now}
+}
+
+// reserve takes a token from the bucket and returns how long the caller has
+// to wait before the token can be used.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// inflightRequest is a GET request sent to the DU whose response is shared by
+// every identical request made while it is running.
+type inflightRequest struct {
+	done chan struct{}
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// RateLimitedTransport limits the rate of the requests sent through next and
+// coalesces concurrent identical GET requests to the DU APIs into a single
+// request.
+type RateLimitedTransport struct {
+	next     http.RoundTripper
+	bucket   *tokenBucket
+	mu       sync.Mutex
+	inflight map[string]*inflightRequest
+}
+
+// NewRateLimitedTransport returns a transport sending at most rps requests per
+// second through next, rps of 0 only coalesces the requests.
+func NewRateLimitedTransport(next http.RoundTripper, rps float64) *RateLimitedTransport {
+	t := &RateLimitedTransport{next: next, inflight: make(map[string]*inflightRequest)}
+	if rps > 0 {
+		t.bucket = newTokenBucket(rps)
+	}
+	return t
+}
+
+// coalescedAPIs are the paths of the DU APIs whose GET requests are coalesced,
+// they answer with small JSON documents. The other responses, like the
+// installers and the packages, are streamed rather than read in memory.
+var coalescedAPIs = []string{"/resmgr/", "/qbert/", "/keystone/"}
+
+// coalesced is true for the requests whose response can be shared
+func coalesced(req *http.Request) bool {
+	if req.Method != http.MethodGet || req.Body != nil {
+		return false
+	}
+	for _, prefix := range coalescedAPIs {
+		if strings.HasPrefix(req.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !coalesced(req) {
+		return t.send(req)
+	}
+
+	// Requests made with a different token may see different results
+	key := req.URL.String() + " " + req.Header.Get("X-Auth-Token")
+	t.mu.Lock()
+	if call, found := t.inflight[key]; found {
+		t.mu.Unlock()
+		zap.S().Debugf("Coalescing request to %s", req.URL.Path)
+		select {
+		case <-call.done:
+			return call.response(req)
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	call := &inflightRequest{done: make(chan struct{})}
+	t.inflight[key] = call
+	t.mu.Unlock()
+
+	call.resp, call.err = t.send(req)
+	if call.err == nil {
+		call.body, call.err = ioutil.ReadAll(call.resp.Body)
+		call.resp.Body.Close()
+	}
+
+	t.mu.Lock()
+	delete(t.inflight, key)
+	t.mu.Unlock()
+	close(call.done)
+
+	return call.response(req)
+}
+
+// send waits for the rate limit before sending req through the next transport
+func (t *RateLimitedTransport) send(req *http.Request) (*http.Response, error) {
+	if t.bucket != nil {
+		if delay := t.bucket.reserve(); delay > 0 {
+			zap.S().Debugf("Rate limiting request to %s for %s", req.URL.Path, delay)
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			}
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// response returns a copy of the shared response with its own body
+func (call *inflightRequest) response(req *http.Request) (*http.Response, error) {
+	if call.err != nil {
+		return nil, call.err
+	}
+	resp := *call.resp
+	resp.Header = call.resp.Header.Clone()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(call.body))
+	resp.Request = req
+	return &resp, nil
+}
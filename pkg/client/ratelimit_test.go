@@ -0,0 +1,112 @@
+package client
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(2)
+	b.now = func() time.Time { return now }
+
+	// The burst is used up first, then requests are spaced out by 1/rate
+	assert.Equal(t, time.Duration(0), b.reserve())
+	assert.Equal(t, time.Duration(0), b.reserve())
+	assert.Equal(t, 500*time.Millisecond, b.reserve())
+	assert.Equal(t, time.Second, b.reserve())
+
+	// Idle time refills the bucket up to the burst
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, time.Duration(0), b.reserve())
+	assert.Equal(t, time.Duration(0), b.reserve())
+	assert.Equal(t, 500*time.Millisecond, b.reserve())
+}
+
+func TestCoalescing(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewRateLimitedTransport(http.DefaultTransport, 0)}
+
+	const requests = 5
+	var wg sync.WaitGroup
+	bodies := make([]string, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := client.Get(server.URL + "/resmgr/v1/hosts")
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			bodies[i] = string(body)
+		}(i)
+	}
+
+	// Let the requests pile up behind the first one before answering it
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+	for _, body := range bodies {
+		assert.Equal(t, "/resmgr/v1/hosts", body)
+	}
+
+	// Requests made once the first one finished are sent again
+	resp, err := client.Get(server.URL + "/resmgr/v1/hosts")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+func TestNotCoalesced(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte("#!/bin/bash\n"))
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := &http.Client{Transport: NewRateLimitedTransport(http.DefaultTransport, 0)}
+
+	// The installers are streamed, their response is returned before the
+	// whole of it is downloaded
+	var bodies []io.ReadCloser
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL + "/clarity/platform9-install-debian.sh")
+		if !assert.Nil(t, err) {
+			return
+		}
+		bodies = append(bodies, resp.Body)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	for _, body := range bodies {
+		head := make([]byte, 12)
+		_, err := io.ReadFull(body, head)
+		assert.Nil(t, err)
+		assert.Equal(t, "#!/bin/bash\n", string(head))
+		body.Close()
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+)
+
+// ReadOnly refuses the requests changing the DU, set from the read_only
+// setting of the config before the clients are created
+var ReadOnly bool
+
+// readOnlyHosts are the hosts of the DUs the clients were created for while
+// ReadOnly was set
+var readOnlyHosts = struct {
+	sync.RWMutex
+	hosts map[string]bool
+}{hosts: make(map[string]bool)}
+
+// ReadOnlyError is returned for an operation changing the DU or the nodes
+// while the config is read-only
+type ReadOnlyError struct {
+	Operation string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("%s is refused as the config is read-only, use the config of an operator allowed to make changes", e.Operation)
+}
+
+// guardReadOnly makes the requests to the DU at fqdn refused when ReadOnly is set
+func guardReadOnly(fqdn string) {
+	if !ReadOnly {
+		return
+	}
+	host := fqdn
+	if u, err := url.Parse(fqdn); err == nil && u.Host != "" {
+		host = u.Hostname()
+	}
+	readOnlyHosts.Lock()
+	readOnlyHosts.hosts[host] = true
+	readOnlyHosts.Unlock()
+}
+
+// mutatingRequest is true for the requests which change the DU. Getting a
+// token from Keystone only reads the credentials.
+func mutatingRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return !(req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/keystone/v3/auth/tokens"))
+}
+
+// ReadOnlyTransport refuses the requests changing a DU guarded by
+// guardReadOnly
+type ReadOnlyTransport struct {
+	next http.RoundTripper
+}
+
+// NewReadOnlyTransport returns a transport sending the requests through next
+// unless they change a read-only DU
+func NewReadOnlyTransport(next http.RoundTripper) *ReadOnlyTransport {
+	return &ReadOnlyTransport{next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *ReadOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	readOnlyHosts.RLock()
+	guarded := readOnlyHosts.hosts[req.URL.Hostname()]
+	readOnlyHosts.RUnlock()
+	if guarded && mutatingRequest(req) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, &ReadOnlyError{Operation: fmt.Sprintf("%s %s", req.Method, req.URL.Path)}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// readOnlyResmgr refuses the calls of resmgr changing the DU before any of
+// their requests is sent
+type readOnlyResmgr struct {
+	resmgr.Resmgr
+}
+
+func (r readOnlyResmgr) AuthorizeHost(hostID, token string) error {
+	return &ReadOnlyError{Operation: "authorizing host " + hostID}
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnlyTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client := &http.Client{Transport: NewReadOnlyTransport(http.DefaultTransport)}
+
+	// Requests are sent while the DU isn't guarded
+	resp, err := client.Post(server.URL+"/qbert/v4/clusters", "application/json", strings.NewReader("{}"))
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	ReadOnly = true
+	defer func() {
+		ReadOnly = false
+		readOnlyHosts.hosts = make(map[string]bool)
+	}()
+	guardReadOnly(server.URL)
+
+	resp, err = client.Get(server.URL + "/qbert/v4/clusters")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	resp, err = client.Post(server.URL+"/keystone/v3/auth/tokens", "application/json", strings.NewReader("{}"))
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	_, err = client.Post(server.URL+"/qbert/v4/clusters", "application/json", strings.NewReader("{}"))
+	var readOnlyErr *ReadOnlyError
+	assert.True(t, errors.As(err, &readOnlyErr))
+	assert.Equal(t, "POST /qbert/v4/clusters", readOnlyErr.Operation)
+
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/resmgr/v1/hosts/host-1", nil)
+	_, err = client.Do(req)
+	assert.True(t, errors.As(err, &readOnlyErr))
+
+	c, err := NewClient("https://du.example.com", nil, false, true)
+	assert.Nil(t, err)
+	assert.True(t, errors.As(c.Resmgr.AuthorizeHost("host-1", "token"), &readOnlyErr))
+}
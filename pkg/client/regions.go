@@ -0,0 +1,91 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+)
+
+// RegionClient is the client of a region of the DU with the token issued by
+// the region. The Fqdn and the Region of its Config are the ones of the region.
+type RegionClient struct {
+	Client
+	Config objects.Config
+	Auth   keystone.KeystoneAuth
+}
+
+// Regions are the clients of the regions of a DU, for the batches of nodes
+// spanning regions. The client of a region is created the first time it is
+// used and kept for the next nodes of the region.
+type Regions struct {
+	defaultRegion string
+	clients       map[string]RegionClient
+}
+
+// NewRegions returns the clients of the regions of the DU of cfg, c and auth
+// being the ones of the region of cfg
+func NewRegions(cfg objects.Config, c Client, auth keystone.KeystoneAuth) *Regions {
+	return &Regions{
+		defaultRegion: cfg.Region,
+		clients:       map[string]RegionClient{cfg.Region: {Client: c, Config: cfg, Auth: auth}},
+	}
+}
+
+// Get returns the client of region, the one of the config when region is empty
+func (r *Regions) Get(region string) (RegionClient, error) {
+	if region == "" {
+		region = r.defaultRegion
+	}
+	if rc, ok := r.clients[region]; ok {
+		return rc, nil
+	}
+	def := r.clients[r.defaultRegion]
+	host, err := keystone.FetchRegionFQDN(def.Config.Fqdn, region, def.Auth)
+	if err != nil {
+		return RegionClient{}, fmt.Errorf("Unable to fetch the URL of region %s: %w", region, err)
+	}
+	return r.connect(region, "https://"+host)
+}
+
+// GetByFqdn returns the client of the region at fqdn, for the jobs which
+// recorded the region they were created in
+func (r *Regions) GetByFqdn(fqdn string) (RegionClient, error) {
+	for _, rc := range r.clients {
+		if strings.TrimSuffix(rc.Config.Fqdn, "/") == strings.TrimSuffix(fqdn, "/") {
+			return rc, nil
+		}
+	}
+	return r.connect("", fqdn)
+}
+
+// connect creates the client of the region at fqdn and gets a token from the
+// keystone of the region. The events are still sent with the segment of the
+// default region.
+func (r *Regions) connect(region, fqdn string) (RegionClient, error) {
+	def := r.clients[r.defaultRegion]
+	cfg := def.Config
+	cfg.Fqdn = fqdn
+	cfg.Region = region
+	c, err := NewClient(fqdn, def.Executor, cfg.AllowInsecure, true)
+	if err != nil {
+		return RegionClient{}, err
+	}
+	c.Segment = def.Segment
+
+	// The regions share the keystone of the DU, a one-time MFA code can't
+	// be used twice so the token of the default region is kept
+	auth := def.Auth
+	if cfg.MfaToken == "" {
+		if auth, err = keystone.Authenticate(c.Keystone, cfg); err != nil {
+			return RegionClient{}, fmt.Errorf("Unable to obtain keystone credentials of %s: %w", fqdn, err)
+		}
+	}
+	rc := RegionClient{Client: c, Config: cfg, Auth: auth}
+	if region != "" {
+		r.clients[region] = rc
+	}
+	return rc, nil
+}
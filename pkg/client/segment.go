@@ -18,7 +18,8 @@ import (
 var SegmentWriteKey string
 
 type Segment interface {
-	SendEvent(string, interface{}, string, string) error
+	// Track sends e for the user of the keystone.KeystoneAuth data
+	Track(Event, interface{}) error
 	SendGroupTraits(string, interface{}) error
 	Close()
 }
@@ -57,7 +58,7 @@ func NewSegment(fqdn string, noTracking bool) Segment {
 	}
 }
 
-func (c SegmentImpl) SendEvent(name string, data interface{}, status string, err string) error {
+func (c SegmentImpl) Track(e Event, data interface{}) error {
 	//To differentiate between OVA and non-OVA node
 	var infra string
 	ovfservicepresent := InfraCheck()
@@ -67,19 +68,13 @@ func (c SegmentImpl) SendEvent(name string, data interface{}, status string, err
 		infra = "CLI"
 	}
 
-	zap.S().Debug("Sending Segment Event: ", name)
+	zap.S().Debug("Sending Segment Event: ", e.Name, " ", e.Phase)
 	data_struct, ok := data.(keystone.KeystoneAuth)
 	if ok {
 		return c.client.Enqueue(analytics.Track{
-			UserId: data_struct.UserID,
-			Event:  name,
-			Properties: analytics.NewProperties().
-				Set("keystoneData", data).
-				Set("dufqdn", data_struct.DUFqdn).
-				Set("email", data_struct.Email).
-				Set("status", status).
-				Set("infra", infra).
-				Set("errorMsg", err),
+			UserId:     data_struct.UserID,
+			Event:      e.Name,
+			Properties: eventProperties(e, data_struct, infra),
 			Integrations: analytics.NewIntegrations().Set("Amplitude", map[string]interface{}{
 				"session_id": time.Now().Unix(),
 			}),
@@ -133,7 +128,7 @@ func (c *SegmentNoopLogger) Errorf(err string, args ...interface{}) {
 }
 
 // The Noop Implementation of Segment
-func (c NoopSegment) SendEvent(name string, data interface{}, status string, err string) error {
+func (c NoopSegment) Track(e Event, data interface{}) error {
 	return nil
 }
 
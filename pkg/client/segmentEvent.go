@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"gopkg.in/segmentio/analytics-go.v3"
+)
+
+// SegmentSchemaVersion is the version of the properties of the events, 2 adds
+// the phase, its duration, the OS of the node, the version of pf9ctl and the
+// category of the error
+const SegmentSchemaVersion = 2
+
+// Categories of the errors of the events, aggregated by the analytics
+const (
+	ErrCategoryNetwork    = "network"
+	ErrCategoryTimeout    = "timeout"
+	ErrCategoryAuth       = "auth"
+	ErrCategoryPermission = "permission"
+	ErrCategoryReadOnly   = "read-only"
+	ErrCategoryPlatform   = "platform"
+	ErrCategoryPackages   = "packages"
+	ErrCategoryConflict   = "conflict"
+	ErrCategoryOther      = "other"
+)
+
+// Event is an event of a command, with the step it reached as its phase
+// instead of a suffix of its name
+type Event struct {
+	// Name is the command, as "Prep-node"
+	Name  string
+	Phase string
+	// Status is util.CheckPass or util.CheckFail
+	Status   string
+	Duration time.Duration
+	// OS and OSVersion are of the node the command runs on, when known
+	OS        string
+	OSVersion string
+	Err       error
+	// ErrCategory overrides the category found by ErrorCategory
+	ErrCategory string
+}
+
+// categoryPatterns are the category of the errors whose message has one of
+// the patterns, tried in order
+var categoryPatterns = []struct {
+	category string
+	patterns []string
+}{
+	{ErrCategoryTimeout, []string{"timeout", "timed out", "deadline exceeded"}},
+	{ErrCategoryAuth, []string{"401", "unauthorized", "invalid credentials", "token", "mfa"}},
+	{ErrCategoryPermission, []string{"permission denied", "403", "forbidden", "sudo", "requires the admin role"}},
+	{ErrCategoryNetwork, []string{"connection refused", "no such host", "dial tcp", "network is unreachable", "no route to host", "connection reset", "eof", "tls", "certificate"}},
+	{ErrCategoryConflict, []string{"already present", "already exists", "conflict", "under lock"}},
+	{ErrCategoryPackages, []string{"package", "dpkg", "yum", "apt", "install"}},
+	{ErrCategoryPlatform, []string{"host os", "platform", "os-release", "kernel"}},
+}
+
+// ErrorCategory returns the category of err, empty when err is nil
+func ErrorCategory(err error) string {
+	if err == nil {
+		return ""
+	}
+	var readOnly *ReadOnlyError
+	if errors.As(err, &readOnly) {
+		return ErrCategoryReadOnly
+	}
+	var missingRole *keystone.MissingRoleError
+	if errors.As(err, &missingRole) {
+		return ErrCategoryPermission
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrCategoryTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrCategoryTimeout
+		}
+		return ErrCategoryNetwork
+	}
+	msg := strings.ToLower(err.Error())
+	for _, c := range categoryPatterns {
+		for _, pattern := range c.patterns {
+			if strings.Contains(msg, pattern) {
+				return c.category
+			}
+		}
+	}
+	return ErrCategoryOther
+}
+
+// eventProperties returns the properties of e sent for the user of auth
+func eventProperties(e Event, auth keystone.KeystoneAuth, infra string) analytics.Properties {
+	var errMsg string
+	category := e.ErrCategory
+	if e.Err != nil {
+		errMsg = e.Err.Error()
+		if category == "" {
+			category = ErrorCategory(e.Err)
+		}
+	}
+	props := analytics.NewProperties().
+		Set("schemaVersion", SegmentSchemaVersion).
+		Set("keystoneData", auth).
+		Set("dufqdn", auth.DUFqdn).
+		Set("email", auth.Email).
+		Set("status", e.Status).
+		Set("infra", infra).
+		Set("environment", util.NodeEnvironment).
+		Set("errorMsg", errMsg).
+		Set("errorCategory", category).
+		Set("correlationId", log.CorrelationID).
+		Set("cliVersion", util.CLIVersion).
+		Set("cliPlatform", runtime.GOOS+"/"+runtime.GOARCH)
+	if e.Phase != "" {
+		props.Set("phase", e.Phase)
+	}
+	if e.Duration > 0 {
+		props.Set("durationMs", e.Duration.Milliseconds())
+	}
+	if e.OS != "" {
+		props.Set("os", e.OS).Set("osVersion", e.OSVersion)
+	}
+	return props
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCategory(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want string
+	}{
+		"Nil":         {nil, ""},
+		"ReadOnly":    {fmt.Errorf("unable to prep: %w", &ReadOnlyError{Operation: "prep-node"}), ErrCategoryReadOnly},
+		"MissingRole": {&keystone.MissingRoleError{Operation: "attach-node"}, ErrCategoryPermission},
+		"Deadline":    {fmt.Errorf("waiting: %w", context.DeadlineExceeded), ErrCategoryTimeout},
+		"Dial":        {&net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrCategoryNetwork},
+		"Refused":     {errors.New("Get https://du: dial tcp 10.0.0.1:443: connection refused"), ErrCategoryNetwork},
+		"Sudo":        {errors.New("sudo: a password is required"), ErrCategoryPermission},
+		"Packages":    {errors.New("Error: Platform9 packages already present."), ErrCategoryConflict},
+		"Install":     {errors.New("Error: Unable to install hostagent. exit status 1"), ErrCategoryPackages},
+		"HostOS":      {errors.New("Error: Invalid host OS. failed reading data from file"), ErrCategoryPlatform},
+		"Other":       {errors.New("something happened"), ErrCategoryOther},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ErrorCategory(tc.err))
+		})
+	}
+}
+
+func TestEventProperties(t *testing.T) {
+	auth := keystone.KeystoneAuth{DUFqdn: "https://du.example.com", Email: "admin@example.com"}
+	props := eventProperties(Event{
+		Name:      "Prep-node",
+		Phase:     "install-hostagent",
+		Status:    util.CheckFail,
+		Duration:  1500 * time.Millisecond,
+		OS:        "ubuntu",
+		OSVersion: "20.04",
+		Err:       errors.New("Error: Unable to install hostagent. exit status 1"),
+	}, auth, "CLI")
+
+	assert.Equal(t, SegmentSchemaVersion, props["schemaVersion"])
+	assert.Equal(t, "install-hostagent", props["phase"])
+	assert.Equal(t, int64(1500), props["durationMs"])
+	assert.Equal(t, "ubuntu", props["os"])
+	assert.Equal(t, "20.04", props["osVersion"])
+	assert.Equal(t, ErrCategoryPackages, props["errorCategory"])
+	assert.Equal(t, "Error: Unable to install hostagent. exit status 1", props["errorMsg"])
+	assert.Equal(t, util.CLIVersion, props["cliVersion"])
+
+	props = eventProperties(Event{Name: "Attach-node", Status: util.CheckPass}, auth, "CLI")
+	assert.NotContains(t, props, "phase")
+	assert.NotContains(t, props, "durationMs")
+	assert.NotContains(t, props, "os")
+	assert.Equal(t, "", props["errorCategory"])
+}
@@ -2,21 +2,21 @@
 package cmdexec
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 
+	"github.com/platform9/pf9ctl/pkg/log"
 	"github.com/platform9/pf9ctl/pkg/objects"
 	"github.com/platform9/pf9ctl/pkg/ssh"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"go.uber.org/zap"
 )
 
-// To fetch the stderr after executing command
-var StdErrSudoPassword string
-
 const (
 	httpsProxy = "https_proxy"
 	env_path   = "PATH"
@@ -26,15 +26,27 @@ const (
 type Executor interface {
 	Run(name string, args ...string) error
 	RunWithStdout(name string, args ...string) (string, error)
+	// RunArgs runs name with args without going through a shell, so the args
+	// are passed as they are even when they hold spaces, quotes or $. Commands
+	// needing pipelines or redirections are run with RunWithStdout and bash -c.
+	RunArgs(name string, args ...string) (string, error)
 	RunCommandWait(command string) string
 }
 
+// ErrLocalUnsupported is returned when commands are run on this machine while
+// it can't run them, see LocalSupported
+var ErrLocalUnsupported = errors.New("commands can only be run on this machine on Linux, pass the nodes to run them on with --ip")
+
 // LocalExecutor as the name implies executes commands locally
 type LocalExecutor struct {
 	ProxyUrl string
 }
 
 func (c LocalExecutor) RunCommandWait(command string) string {
+	if !LocalSupported {
+		fmt.Println(ErrLocalUnsupported.Error())
+		return ""
+	}
 	command = "sudo " + command
 	output := exec.Command("/bin/sh", "-c", command)
 	output.Stdout = os.Stdout
@@ -57,6 +69,9 @@ func (r *RemoteExecutor) RunCommandWait(command string) string {
 
 // Run runs a command locally returning just success or failure
 func (c LocalExecutor) Run(name string, args ...string) error {
+	if !LocalSupported {
+		return ErrLocalUnsupported
+	}
 	if c.ProxyUrl != "" {
 		args = append([]string{httpsProxy + "=" + c.ProxyUrl, name}, args...)
 	} else {
@@ -70,6 +85,9 @@ func (c LocalExecutor) Run(name string, args ...string) error {
 
 // RunWithStdout runs a command locally returning stdout and err
 func (c LocalExecutor) RunWithStdout(name string, args ...string) (string, error) {
+	if !LocalSupported {
+		return "", ErrLocalUnsupported
+	}
 	if c.ProxyUrl != "" {
 		args = append([]string{httpsProxy + "=" + c.ProxyUrl, name}, args...)
 	} else {
@@ -98,10 +116,60 @@ func (c LocalExecutor) RunWithStdout(name string, args ...string) (string, error
 	return string(byt), err
 }
 
+// RunArgs runs a command locally without a shell returning stdout and err,
+// sudo runs the command with its args as they are
+func (c LocalExecutor) RunArgs(name string, args ...string) (string, error) {
+	return c.RunWithStdout(name, args...)
+}
+
 // RemoteExecutor as the name implies runs commands usign SSH on remote host
 type RemoteExecutor struct {
 	Client   ssh.Client
+	host     string
 	proxyURL string
+	// log prefixes the lines with the host, see log.ForHost
+	log *zap.SugaredLogger
+	// dial opens a new connection to the host, see Reconnect
+	dial func() (ssh.Client, error)
+
+	// stderr is the stderr of the last command, see LastStderr
+	mu     sync.Mutex
+	stderr string
+}
+
+func (r *RemoteExecutor) setStderr(stderr []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stderr = string(stderr)
+}
+
+// LastStderr returns the stderr of the last command exec ran on a remote
+// node, e.g. to tell a wrong sudo password. It's empty for this machine.
+func LastStderr(exec Executor) string {
+	r, ok := exec.(*RemoteExecutor)
+	if !ok {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stderr
+}
+
+// Close closes the SSH connection of exec to its node, the executors of this
+// machine have none
+func Close(exec Executor) {
+	if r, ok := exec.(*RemoteExecutor); ok && r.Client != nil {
+		if err := r.Client.Close(); err != nil {
+			r.logger().Debugf("Unable to close the connection: %s", err)
+		}
+	}
+}
+
+func (r *RemoteExecutor) logger() *zap.SugaredLogger {
+	if r.log == nil {
+		return zap.S()
+	}
+	return r.log
 }
 
 // Run runs a command locally returning just success or failure
@@ -120,26 +188,71 @@ func (r *RemoteExecutor) RunWithStdout(name string, args ...string) (string, err
 		cmd = fmt.Sprintf("%s=%s %s", httpsProxy, r.proxyURL, cmd)
 	}
 	stdout, stderr, err := r.Client.RunCommand(cmd)
-	// To fetch the stderr after executing command.
-	StdErrSudoPassword = string(stderr)
+	r.setStderr(stderr)
+
+	// Avoid confidential info in the command from getting logged
+	command := ConfidentialInfoRemover(cmd)
+
+	r.logger().Debug("Running command ", command, "stdout:", string(stdout), "stderr:", string(stderr))
+	return string(stdout), err
+}
+
+// RunArgs runs a command remotely returning stdout and err. The name and each
+// arg are single quoted so the remote shell passes them to sudo as they are.
+func (r *RemoteExecutor) RunArgs(name string, args ...string) (string, error) {
+	cmd := argvCommand(name, args...)
+	if r.proxyURL != "" {
+		cmd = fmt.Sprintf("%s=%s %s", httpsProxy, ShellQuote(r.proxyURL), cmd)
+	}
+	stdout, stderr, err := r.Client.RunCommand(cmd)
+	r.setStderr(stderr)
 
 	// Avoid confidential info in the command from getting logged
 	command := ConfidentialInfoRemover(cmd)
 
-	zap.S().Debug("Running command ", command, "stdout:", string(stdout), "stderr:", string(stderr))
+	r.logger().Debug("Running command ", command, "stdout:", string(stdout), "stderr:", string(stderr))
 	return string(stdout), err
 }
 
+// argvCommand returns the shell command running name with args as they are
+func argvCommand(name string, args ...string) string {
+	words := []string{ShellQuote(name)}
+	for _, arg := range args {
+		words = append(words, ShellQuote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
 // NewRemoteExecutor create an Executor interface to execute commands remotely
 func NewRemoteExecutor(host string, port int, username string, privateKey []byte, password, proxyURL string) (Executor, error) {
-	client, err := ssh.NewClient(host, port, username, privateKey, password, proxyURL)
+	dial := func() (ssh.Client, error) {
+		return ssh.NewClient(host, port, username, privateKey, password, proxyURL)
+	}
+	client, err := dial()
 	if err != nil {
 		return nil, err
 	}
-	re := &RemoteExecutor{Client: client, proxyURL: proxyURL}
+	re := &RemoteExecutor{Client: client, host: host, proxyURL: proxyURL, log: log.ForHost(host), dial: dial}
 	return re, nil
 }
 
+// Reconnect opens a new SSH connection of exec to its node, e.g. once the node
+// rebooted, closing the previous one. Only the executors of remote nodes
+// reconnect.
+func Reconnect(exec Executor) error {
+	r, ok := exec.(*RemoteExecutor)
+	if !ok || r.dial == nil {
+		return errors.New("only the commands run on remote nodes can reconnect")
+	}
+	client, err := r.dial()
+	if err != nil {
+		return err
+	}
+	Close(r)
+	r.Client = client
+	return nil
+}
+
 // Avoid confidential information from getting logged
 func ConfidentialInfoRemover(cmd string) string {
 	// To find the command that contains confidential info
@@ -163,14 +276,26 @@ func ConfidentialInfoRemover(cmd string) string {
 	return cmd
 }
 
+// Host returns the node exec runs the commands on, localhost for this machine
+// and empty when it isn't known
+func Host(exec Executor) string {
+	switch e := exec.(type) {
+	case *RemoteExecutor:
+		return e.host
+	case LocalExecutor:
+		return "localhost"
+	}
+	return ""
+}
+
 func GetExecutor(proxyURL string, nc objects.NodeConfig) (Executor, error) {
 	if CheckRemote(nc) {
 		var pKey []byte
 		var err error
 		if nc.SshKey != "" {
-			pKey, err = ioutil.ReadFile(nc.SshKey)
+			pKey, err = readSSHKey(nc.SshKey)
 			if err != nil {
-				zap.S().Fatalf("Unable to read the sshKey %s, %s", nc.SshKey, err.Error())
+				return nil, fmt.Errorf("Unable to read the sshKey %s, %s", nc.SshKey, err.Error())
 			}
 		}
 		return NewRemoteExecutor(nc.IPs[0], 22, nc.User, pKey, nc.Password, proxyURL)
@@ -179,6 +304,37 @@ func GetExecutor(proxyURL string, nc objects.NodeConfig) (Executor, error) {
 	return LocalExecutor{ProxyUrl: proxyURL}, nil
 }
 
+// sshKeys are the SSH keys already read by path. Keys given as a file
+// descriptor like /dev/fd/3 can only be read once, while the executors of all
+// the nodes need them.
+var sshKeys = struct {
+	sync.Mutex
+	keys map[string][]byte
+}{keys: make(map[string][]byte)}
+
+func readSSHKey(path string) ([]byte, error) {
+	sshKeys.Lock()
+	defer sshKeys.Unlock()
+	if key, ok := sshKeys.keys[path]; ok {
+		return key, nil
+	}
+	key, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sshKeys.keys[path] = key
+	return key, nil
+}
+
+// CheckLocal returns ErrLocalUnsupported when nc is this machine while
+// commands can't be run on it
+func CheckLocal(nc objects.NodeConfig) error {
+	if !LocalSupported && !CheckRemote(nc) {
+		return ErrLocalUnsupported
+	}
+	return nil
+}
+
 func CheckRemote(nc objects.NodeConfig) bool {
 	for _, ip := range nc.IPs {
 		if ip != "localhost" && ip != "127.0.0.1" && ip != "::1" {
@@ -5,6 +5,7 @@ var _ Executor = (*MockExecutor)(nil)
 type MockExecutor struct {
 	MockRun            func(name string, args ...string) error
 	MockRunWithStdout  func(name string, args ...string) (string, error)
+	MockRunArgs        func(name string, args ...string) (string, error)
 	MockRunCommandWait func(name string) string
 }
 
@@ -16,6 +17,14 @@ func (m *MockExecutor) RunWithStdout(name string, args ...string) (string, error
 	return m.MockRunWithStdout(name, args...)
 }
 
+// RunArgs calls MockRunArgs, or MockRunWithStdout when it isn't set
+func (m *MockExecutor) RunArgs(name string, args ...string) (string, error) {
+	if m.MockRunArgs == nil {
+		return m.MockRunWithStdout(name, args...)
+	}
+	return m.MockRunArgs(name, args...)
+}
+
 func (m *MockExecutor) RunCommandWait(name string) string {
 	return m.RunCommandWait(name)
 }
@@ -0,0 +1,8 @@
+//go:build linux
+// +build linux
+
+package cmdexec
+
+// LocalSupported tells whether commands can be run on this machine. The local
+// executor runs them with sudo and bash.
+const LocalSupported = true
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package cmdexec
+
+// LocalSupported tells whether commands can be run on this machine. Only
+// Linux machines can be onboarded, on macOS and Windows workstations only the
+// nodes given with --ip can be operated.
+const LocalSupported = false
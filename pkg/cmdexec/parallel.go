@@ -0,0 +1,80 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+package cmdexec
+
+import (
+	"sync"
+
+	"github.com/platform9/pf9ctl/pkg/log"
+)
+
+// DefaultParallelism is the number of hosts operated upon concurrently
+// when the caller does not specify a limit.
+const DefaultParallelism = 10
+
+// HostResult holds the outcome of running a command on a single host
+type HostResult struct {
+	Host   string
+	Stdout string
+	Err    error
+}
+
+// ExecutorFactory returns an Executor that runs commands on the given host
+type ExecutorFactory func(host string) (Executor, error)
+
+// HostProgress is notified as the command progresses on every host
+type HostProgress interface {
+	Set(host, phase string)
+	Finish(host string, err error)
+}
+
+// RunOnHosts runs command on every host using at most parallel concurrent
+// executors. Results are returned in the same order as hosts.
+func RunOnHosts(hosts []string, parallel int, newExecutor ExecutorFactory, command string) []HostResult {
+	return RunOnHostsWithProgress(hosts, parallel, newExecutor, command, nil)
+}
+
+// RunOnHostsWithProgress is RunOnHosts reporting the phase of every host to
+// progress, which can be nil.
+func RunOnHostsWithProgress(hosts []string, parallel int, newExecutor ExecutorFactory, command string, progress HostProgress) []HostResult {
+	if parallel <= 0 {
+		parallel = DefaultParallelism
+	}
+
+	results := make([]HostResult, len(hosts))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = runOnHost(host, newExecutor, command, progress)
+			if progress != nil {
+				progress.Finish(host, results[i].Err)
+			}
+		}(i, host)
+	}
+	wg.Wait()
+	return results
+}
+
+func runOnHost(host string, newExecutor ExecutorFactory, command string, progress HostProgress) HostResult {
+	log.ForHost(host).Debug("Running command")
+	if progress != nil {
+		progress.Set(host, "connecting")
+	}
+	exec, err := newExecutor(host)
+	if err != nil {
+		return HostResult{Host: host, Err: err}
+	}
+	defer Close(exec)
+	if progress != nil {
+		progress.Set(host, "running")
+	}
+	// The command is passed to bash as it is, quotes and $ included
+	stdout, err := exec.RunArgs("bash", "-c", command)
+	return HostResult{Host: host, Stdout: stdout, Err: err}
+}
@@ -0,0 +1,97 @@
+package cmdexec
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunOnHosts(t *testing.T) {
+	hosts := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+
+	newExecutor := func(host string) (Executor, error) {
+		if host == "10.0.0.3" {
+			return nil, fmt.Errorf("unable to dial %s", host)
+		}
+		return &MockExecutor{
+			MockRunWithStdout: func(name string, args ...string) (string, error) {
+				if host == "10.0.0.2" {
+					return "", fmt.Errorf("command failed")
+				}
+				return host + ": " + args[1], nil
+			},
+		}, nil
+	}
+
+	results := RunOnHosts(hosts, 2, newExecutor, "uptime")
+
+	assert.Equal(t, len(hosts), len(results))
+	// Results must keep the order of the hosts passed in
+	for i, host := range hosts {
+		assert.Equal(t, host, results[i].Host)
+	}
+	assert.Equal(t, "10.0.0.1: uptime", results[0].Stdout)
+	assert.Nil(t, results[0].Err)
+	assert.Equal(t, fmt.Errorf("command failed"), results[1].Err)
+	assert.Equal(t, fmt.Errorf("unable to dial 10.0.0.3"), results[2].Err)
+}
+
+func TestRunOnHostsRemote(t *testing.T) {
+	clients := map[string]*recordingClient{}
+	var mu sync.Mutex
+	newExecutor := func(host string) (Executor, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		clients[host] = &recordingClient{}
+		return &RemoteExecutor{Client: clients[host], host: host}, nil
+	}
+
+	results := RunOnHosts([]string{"10.0.0.1", "10.0.0.2"}, 2, newExecutor, `echo "$HOME"`)
+	for _, result := range results {
+		assert.Nil(t, result.Err)
+		client := clients[result.Host]
+		// The command reaches bash as it is and the connection is closed
+		assert.Equal(t, []string{`'bash' '-c' 'echo "$HOME"'`}, client.commands)
+		assert.True(t, client.closed)
+	}
+}
+
+type recordedProgress struct {
+	mu     sync.Mutex
+	phases map[string][]string
+}
+
+func (r *recordedProgress) Set(host, phase string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.phases[host] = append(r.phases[host], phase)
+}
+
+func (r *recordedProgress) Finish(host string, err error) {
+	if err != nil {
+		r.Set(host, "failed")
+	} else {
+		r.Set(host, "done")
+	}
+}
+
+func TestRunOnHostsWithProgress(t *testing.T) {
+	newExecutor := func(host string) (Executor, error) {
+		if host == "10.0.0.2" {
+			return nil, fmt.Errorf("unable to dial %s", host)
+		}
+		return &MockExecutor{
+			MockRunWithStdout: func(name string, args ...string) (string, error) {
+				return "", nil
+			},
+		}, nil
+	}
+
+	progress := &recordedProgress{phases: make(map[string][]string)}
+	RunOnHostsWithProgress([]string{"10.0.0.1", "10.0.0.2"}, 2, newExecutor, "uptime", progress)
+
+	assert.Equal(t, []string{"connecting", "running", "done"}, progress.phases["10.0.0.1"])
+	assert.Equal(t, []string{"connecting", "failed"}, progress.phases["10.0.0.2"])
+}
@@ -0,0 +1,54 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+package cmdexec
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+)
+
+// CopyFile copies the file local of this machine to remote on the node of the
+// executor. The copy is owned by root with mode.
+func CopyFile(e Executor, local, remote string, mode os.FileMode) error {
+	src := local
+	if executor, ok := e.(*RemoteExecutor); ok {
+		// SFTP writes the file as the SSH user, sudo then installs it in
+		// place since remote may only be writable by root
+		tmp, err := remoteTempName()
+		if err != nil {
+			return err
+		}
+		if err := executor.Client.UploadFile(local, tmp, 0600, nil); err != nil {
+			return fmt.Errorf("unable to upload %s: %w", local, err)
+		}
+		defer e.RunArgs("rm", "-f", tmp)
+		src = tmp
+	}
+	if _, err := e.RunArgs("install", "-m", fmt.Sprintf("%o", mode), src, remote); err != nil {
+		return fmt.Errorf("unable to copy %s to %s: %w", local, remote, err)
+	}
+	return nil
+}
+
+// ListenRemote listens on addr of the node of the executor, the connections
+// made to it on the node are forwarded to this machine over SSH
+func ListenRemote(e Executor, addr string) (net.Listener, error) {
+	executor, ok := e.(*RemoteExecutor)
+	if !ok {
+		return nil, fmt.Errorf("only the nodes given with --ip can be reached through SSH")
+	}
+	return executor.Client.ListenRemote(addr)
+}
+
+// remoteTempName returns the name of a temporary file in the home dir of the
+// SSH user. Relative paths are resolved from the home dir by SFTP and by the
+// remote shell alike.
+func remoteTempName() (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(".pf9-%s", hex.EncodeToString(suffix)), nil
+}
@@ -0,0 +1,57 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+package cmdexec
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// RunSecretScript runs a bash script holding secrets like passwords or tokens.
+// The script is written to a file only readable by its owner and run from there,
+// so the secrets don't show up in the command lines run through sudo or SSH,
+// which are visible to `ps` on the node and logged. The script removes itself
+// before running anything else.
+func RunSecretScript(e Executor, script string) (string, error) {
+	switch e.(type) {
+	case LocalExecutor, *RemoteExecutor:
+	default:
+		// Executors which don't run commands on a host, like the mock used by tests
+		return e.RunWithStdout("bash", "-c", script)
+	}
+
+	local, err := ioutil.TempFile("", "pf9-")
+	if err != nil {
+		return "", fmt.Errorf("unable to create script file: %w", err)
+	}
+	defer os.Remove(local.Name())
+	_, err = local.WriteString("rm -f \"$0\"\n" + script)
+	if closeErr := local.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to write script file: %w", err)
+	}
+
+	executor, ok := e.(*RemoteExecutor)
+	if !ok {
+		// sudo runs the script as root, which can read the file of the user
+		return e.RunWithStdout("bash", local.Name())
+	}
+
+	remote, err := remoteTempName()
+	if err != nil {
+		return "", err
+	}
+	remote += ".sh"
+	if err := executor.Client.UploadFile(local.Name(), remote, 0600, nil); err != nil {
+		return "", fmt.Errorf("unable to upload script file: %w", err)
+	}
+	return e.RunWithStdout("bash", remote)
+}
+
+// ShellQuote quotes s to be used as a single word in a shell command
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,93 @@
+package cmdexec
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/ssh"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingClient records the files uploaded and the commands run over SSH
+type recordingClient struct {
+	commands []string
+	uploaded map[string]string
+	modes    map[string]os.FileMode
+	closed   bool
+}
+
+func (c *recordingClient) RunCommand(cmd string) ([]byte, []byte, error) {
+	c.commands = append(c.commands, cmd)
+	return []byte("ok"), nil, nil
+}
+
+func (c *recordingClient) RunCommandStream(cmd string, w io.Writer) error {
+	c.commands = append(c.commands, cmd)
+	return nil
+}
+
+func (c *recordingClient) UploadFile(src, dst string, mode os.FileMode, cb func(read int64, total int64)) error {
+	content, err := ioutil.ReadFile(src)
+	c.uploaded[dst] = string(content)
+	c.modes[dst] = mode
+	return err
+}
+
+func (c *recordingClient) ListenRemote(addr string) (net.Listener, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *recordingClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *recordingClient) DownloadFile(remoteFile, localPath string, mode os.FileMode, cb func(read int64, total int64)) error {
+	return nil
+}
+
+func TestReconnect(t *testing.T) {
+	old, dialed := &recordingClient{}, &recordingClient{}
+	dials := 0
+	executor := &RemoteExecutor{Client: old, dial: func() (ssh.Client, error) {
+		dials++
+		if dials == 1 {
+			return nil, errors.New("connection refused")
+		}
+		return dialed, nil
+	}}
+
+	// The connection is kept until the node can be reached again
+	assert.EqualError(t, Reconnect(executor), "connection refused")
+	assert.False(t, old.closed)
+	assert.Equal(t, old, executor.Client)
+
+	assert.NoError(t, Reconnect(executor))
+	assert.True(t, old.closed)
+	assert.Equal(t, dialed, executor.Client)
+
+	assert.Error(t, Reconnect(LocalExecutor{}))
+}
+
+func TestRunSecretScript(t *testing.T) {
+	client := &recordingClient{uploaded: map[string]string{}, modes: map[string]os.FileMode{}}
+	executor := &RemoteExecutor{Client: client}
+
+	out, err := RunSecretScript(executor, "installer.sh --password="+ShellQuote("s3cr3t'")+"\n")
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", out)
+
+	assert.Len(t, client.uploaded, 1)
+	for path, content := range client.uploaded {
+		assert.Equal(t, "rm -f \"$0\"\ninstaller.sh --password='s3cr3t'\\'''\n", content)
+		assert.Equal(t, os.FileMode(0600), client.modes[path])
+		assert.Equal(t, []string{"bash \"" + path + "\""}, client.commands)
+	}
+	for _, cmd := range client.commands {
+		assert.NotContains(t, cmd, "s3cr3t")
+	}
+}
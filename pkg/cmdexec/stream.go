@@ -0,0 +1,31 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+package cmdexec
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// RunWithStream runs command through bash copying its stdout to w as it is
+// produced. It is meant for commands that keep running like `journalctl -f`.
+func RunWithStream(e Executor, w io.Writer, command string) error {
+	switch executor := e.(type) {
+	case *RemoteExecutor:
+		return executor.Client.RunCommandStream(fmt.Sprintf("bash -c %q", command), w)
+	case LocalExecutor:
+		if !LocalSupported {
+			return ErrLocalUnsupported
+		}
+		cmd := exec.Command("sudo", "bash", "-c", command)
+		cmd.Stdout = w
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	default:
+		// Executors that can't stream return the output once the command exits
+		output, err := e.RunWithStdout("bash", "-c", command)
+		fmt.Fprint(w, output)
+		return err
+	}
+}
@@ -0,0 +1,293 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"golang.org/x/crypto/scrypt"
+)
+
+// A profile bundle carries the settings of a config to other machines, e.g.
+// for a team lead to hand ready-made DU profiles to the operators. Its
+// settings are encrypted with a passphrase when it is exported with one.
+const (
+	bundleKind    = "pf9ctl-profile"
+	bundleVersion = 1
+	// bundleCipher is the only encryption of the bundles: the key is derived
+	// from the passphrase with scrypt and seals the settings with AES-256-GCM
+	bundleCipher = "scrypt-aes256gcm"
+)
+
+// Cost of the scrypt derivation of the key of the bundles, stored in the
+// bundle so it can be raised without breaking the older bundles
+var (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// Bounds of the scrypt cost read from a bundle, so that a crafted one can't
+// make the import use GiBs of memory or run for hours
+const (
+	maxScryptN      = 1 << 20
+	maxScryptMemory = 1 << 30
+	maxScryptP      = 16
+)
+
+// ErrPassphraseRequired is returned when an encrypted bundle is read without
+// its passphrase
+var ErrPassphraseRequired = errors.New("the profile bundle is encrypted, its passphrase is required")
+
+// ProfileBundle is the portable profile of a config
+type ProfileBundle struct {
+	Kind       string `json:"kind"`
+	Version    int    `json:"version"`
+	CLIVersion string `json:"cliVersion,omitempty"`
+	// Redacted are the settings whose secrets were left out of the bundle,
+	// they keep their value on the machines the bundle is imported on
+	Redacted []string          `json:"redacted,omitempty"`
+	Settings map[string]string `json:"settings,omitempty"`
+
+	// Encryption and Ciphertext are set when the bundle is encrypted, the
+	// ciphertext sealing Redacted and Settings
+	Encryption *bundleEncryption `json:"encryption,omitempty"`
+	Ciphertext string            `json:"ciphertext,omitempty"`
+}
+
+type bundleEncryption struct {
+	Cipher string `json:"cipher"`
+	Salt   string `json:"salt"`
+	Nonce  string `json:"nonce"`
+	N      int    `json:"n"`
+	R      int    `json:"r"`
+	P      int    `json:"p"`
+}
+
+// bundleContent is what the ciphertext of an encrypted bundle seals
+type bundleContent struct {
+	Redacted []string          `json:"redacted,omitempty"`
+	Settings map[string]string `json:"settings"`
+}
+
+// NewProfileBundle returns the bundle of the settings of cfg which are set,
+// but those of exclude. The secrets and the credentials of the proxy are left
+// out when redact is set.
+func NewProfileBundle(cfg *objects.Config, redact bool, exclude []string) (*ProfileBundle, error) {
+	excluded := make(map[string]bool)
+	for _, key := range exclude {
+		if _, err := settingField(cfg, key); err != nil {
+			return nil, err
+		}
+		excluded[key] = true
+	}
+
+	b := &ProfileBundle{Kind: bundleKind, Version: bundleVersion, CLIVersion: util.CLIVersion, Settings: make(map[string]string)}
+	for _, setting := range Settings(cfg, false) {
+		key, value := setting[0], setting[1]
+		if excluded[key] {
+			continue
+		}
+		if redact && secretSettings[key] {
+			b.Redacted = append(b.Redacted, key)
+			continue
+		}
+		if redact && key == "proxy_url" {
+			if u, err := url.Parse(value); err == nil && u.User != nil {
+				u.User = nil
+				value = u.String()
+				b.Redacted = append(b.Redacted, key)
+			}
+		}
+		b.Settings[key] = value
+	}
+	return b, nil
+}
+
+// Encrypted is true when the settings of b are sealed with a passphrase
+func (b *ProfileBundle) Encrypted() bool {
+	return b.Encryption != nil
+}
+
+// deriveBundleKey derives the AES-256 key of a bundle from passphrase
+func deriveBundleKey(passphrase string, enc *bundleEncryption) (cipher.AEAD, error) {
+	if enc.N <= 1 || enc.N > maxScryptN || enc.R <= 0 || enc.P <= 0 || enc.P > maxScryptP ||
+		int64(enc.N)*int64(enc.R) > maxScryptMemory/128 {
+		return nil, fmt.Errorf("unsupported cost of the key of the profile bundle (n=%d, r=%d, p=%d)", enc.N, enc.R, enc.P)
+	}
+	salt, err := base64.StdEncoding.DecodeString(enc.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt in the profile bundle: %w", err)
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, enc.N, enc.R, enc.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unable to derive the key of the profile bundle: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt seals the settings of b with passphrase
+func (b *ProfileBundle) Encrypt(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("the passphrase of the profile bundle can't be empty")
+	}
+	if b.Encrypted() {
+		return errors.New("the profile bundle is already encrypted")
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	enc := &bundleEncryption{Cipher: bundleCipher, Salt: base64.StdEncoding.EncodeToString(salt), N: scryptN, R: scryptR, P: scryptP}
+	aead, err := deriveBundleKey(passphrase, enc)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	enc.Nonce = base64.StdEncoding.EncodeToString(nonce)
+
+	plaintext, err := json.Marshal(bundleContent{Redacted: b.Redacted, Settings: b.Settings})
+	if err != nil {
+		return err
+	}
+	// The header of the bundle is authenticated with the settings, so it
+	// can't be swapped with another one
+	b.Encryption = enc
+	b.Redacted, b.Settings = nil, nil
+	b.Ciphertext = base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, b.additionalData()))
+	return nil
+}
+
+// additionalData is the header of an encrypted bundle, authenticated along
+// with its ciphertext
+func (b *ProfileBundle) additionalData() []byte {
+	enc := b.Encryption
+	return []byte(fmt.Sprintf("%s/%d/%s/%s/%d/%d/%d", b.Kind, b.Version, enc.Cipher, enc.Salt, enc.N, enc.R, enc.P))
+}
+
+// decrypt opens the settings of the encrypted bundle b with passphrase
+func (b *ProfileBundle) decrypt(passphrase string) error {
+	if b.Encryption.Cipher != bundleCipher {
+		return fmt.Errorf("unsupported encryption %q of the profile bundle", b.Encryption.Cipher)
+	}
+	aead, err := deriveBundleKey(passphrase, b.Encryption)
+	if err != nil {
+		return err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(b.Encryption.Nonce)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return errors.New("invalid nonce in the profile bundle")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(b.Ciphertext)
+	if err != nil {
+		return fmt.Errorf("invalid ciphertext in the profile bundle: %w", err)
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, b.additionalData())
+	if err != nil {
+		return errors.New("unable to decrypt the profile bundle, the passphrase is wrong or the bundle was altered")
+	}
+	var content bundleContent
+	if err := json.Unmarshal(plaintext, &content); err != nil {
+		return fmt.Errorf("unable to read the settings of the profile bundle: %w", err)
+	}
+	b.Redacted, b.Settings = content.Redacted, content.Settings
+	b.Encryption, b.Ciphertext = nil, ""
+	return nil
+}
+
+// ReadProfileBundle reads a bundle, decrypting it with passphrase when it is
+// encrypted. It returns ErrPassphraseRequired for an encrypted bundle without
+// a passphrase.
+func ReadProfileBundle(data []byte, passphrase string) (*ProfileBundle, error) {
+	var b ProfileBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("unable to read the profile bundle: %w", err)
+	}
+	if b.Kind != bundleKind {
+		return nil, errors.New("not a pf9ctl profile bundle, it is exported with 'pf9ctl config export'")
+	}
+	if b.Version > bundleVersion {
+		return nil, fmt.Errorf("the profile bundle is of version %d, this pf9ctl only reads up to version %d", b.Version, bundleVersion)
+	}
+	if b.Encrypted() {
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		if err := b.decrypt(passphrase); err != nil {
+			return nil, err
+		}
+	}
+	return &b, nil
+}
+
+// Keys returns the keys of the settings of b, sorted
+func (b *ProfileBundle) Keys() []string {
+	var keys []string
+	for key := range b.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Apply sets the settings of b on cfg. The settings b doesn't have are reset
+// when replace is set, but for the redacted ones which keep their value. The
+// credentials of the proxy are kept when they were redacted.
+func (b *ProfileBundle) Apply(cfg *objects.Config, replace bool) error {
+	if b.Encrypted() {
+		return ErrPassphraseRequired
+	}
+	redacted := make(map[string]bool)
+	for _, key := range b.Redacted {
+		redacted[key] = true
+	}
+	previous := *cfg
+
+	if replace {
+		for _, key := range SettingKeys() {
+			if _, ok := b.Settings[key]; !ok && !redacted[key] {
+				UnsetSetting(cfg, key)
+			}
+		}
+	}
+	for _, key := range b.Keys() {
+		value := b.Settings[key]
+		if key == "proxy_url" && redacted[key] {
+			value = withProxyCredentials(value, previous.ProxyURL)
+		}
+		if err := SetSetting(cfg, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withProxyCredentials adds the credentials of the proxy local to proxyURL
+// when it is the same proxy
+func withProxyCredentials(proxyURL, local string) string {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return proxyURL
+	}
+	l, err := url.Parse(local)
+	if err != nil || l.User == nil || l.Host != u.Host {
+		return proxyURL
+	}
+	u.User = l.User
+	return u.String()
+}
@@ -9,12 +9,14 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/platform9/pf9ctl/pkg/client"
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/keystone"
 	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/statefile"
 
 	"github.com/jinzhu/copier"
 	"github.com/platform9/pf9ctl/pkg/util"
@@ -44,16 +46,39 @@ func StoreConfig(cfg *objects.Config, loc string) error {
 	// Clear the MFA token as it will be required afresh every time
 	cfgCopy.MfaToken = ""
 
-	f, err := os.Create(loc)
+	lock, err := Lock(loc)
 	if err != nil {
 		return err
 	}
+	defer lock.Release()
 
-	defer f.Close()
+	// The read-only config is only unlocked by removing it, not by the
+	// operator it restricts
+	if stored, err := ReadStoredConfig(loc); err == nil && stored.ReadOnly && !cfg.ReadOnly {
+		return &client.ReadOnlyError{Operation: "turning read_only off"}
+	}
 
-	encoder := json.NewEncoder(f)
+	data, err := json.Marshal(cfgCopy)
+	if err != nil {
+		return err
+	}
+	// The config is written aside and moved in place, so that it is never
+	// left half written
+	if err := statefile.WriteFile(loc, append(data, '\n'), 0600); err != nil {
+		return err
+	}
 	fmt.Println(color.Green("✓ ") + "Stored configuration details successfully")
-	return encoder.Encode(cfgCopy)
+	return nil
+}
+
+// LockWait is how long the commands changing the config wait for another
+// pf9ctl changing it
+var LockWait = 5 * time.Second
+
+// Lock locks the config stored at loc until the lock is released, for the
+// commands reading the config and storing it back
+func Lock(loc string) (*statefile.Lock, error) {
+	return statefile.LockFile(loc, LockWait)
 }
 
 // LoadConfig returns the information for communication with PF9 controller.
@@ -86,6 +111,11 @@ func LoadConfig(loc string, cfg *objects.Config, nc objects.NodeConfig) error {
 	//s.Stop()
 
 	copier.CopyWithOption(cfg, &fileConfig, copier.Option{IgnoreEmpty: true})
+	applyTenant(cfg)
+	client.ReadOnly = cfg.ReadOnly
+	if err = ApplySecrets(cfg); err != nil {
+		return err
+	}
 
 	if err = SetProxy(cfg.ProxyURL); err != nil {
 		return err
@@ -94,6 +124,16 @@ func LoadConfig(loc string, cfg *objects.Config, nc objects.NodeConfig) error {
 	return ValidateUserCredentials(cfg, nc)
 }
 
+// CheckWritable fails when the config stored at loc is read-only, before
+// operation changes the nodes
+func CheckWritable(loc, operation string) error {
+	stored, err := ReadStoredConfig(loc)
+	if client.ReadOnly || (err == nil && stored.ReadOnly) {
+		return &client.ReadOnlyError{Operation: operation}
+	}
+	return nil
+}
+
 func LoadConfigInteractive(loc string, cfg *objects.Config, nc objects.NodeConfig) error {
 
 	err := LoadConfig(loc, cfg, nc)
@@ -101,16 +141,28 @@ func LoadConfigInteractive(loc string, cfg *objects.Config, nc objects.NodeConfi
 		return nil
 	}
 
+	// The config isn't at fault when the tenant of --tenant is, so it isn't
+	// prompted for again
+	if Tenant != "" && err == INVALID_CREDS {
+		return fmt.Errorf("unable to authenticate with tenant %s: %w", Tenant, err)
+	}
+
 	if err == NO_CONFIG {
 		fmt.Println(color.Red("x ") + "Existing config not found, prompting for new config")
 		zap.S().Debug("Existing config not found, prompting for new config.")
-	} else if err == INVALID_CREDS || err == REGION_INVALID {
+	} else if err == INVALID_CREDS || errors.Is(err, REGION_INVALID) {
 		fmt.Println(color.Red("x ") + "Existing config is invalid, prompting for new config")
 		zap.S().Debug("Existing config is invalid, prompting for new config.")
 	}
 
 	clearContext(cfg)
-	return GetConfigRecursive(loc, cfg, nc)
+	if err := GetConfigRecursive(loc, cfg, nc); err != nil || Tenant == "" {
+		return err
+	}
+	// The new config is stored with the tenant prompted for, this run uses
+	// the one of --tenant
+	applyTenant(cfg)
+	return ValidateUserCredentials(cfg, nc)
 
 }
 
@@ -146,6 +198,9 @@ func GetConfigRecursive(loc string, cfg *objects.Config, nc objects.NodeConfig)
 		}
 
 		if err = ValidateUserCredentials(cfg, nc); err != nil {
+			if errors.Is(err, REGION_INVALID) {
+				fmt.Println(color.Red("x ") + err.Error())
+			}
 			clearContext(cfg)
 			InvalidExistingConfig = true
 			count++
@@ -168,10 +223,10 @@ func ValidateUserCredentials(cfg *objects.Config, nc objects.NodeConfig) error {
 	}
 
 	c, err := createClient(cfg, nc)
-	defer c.Segment.Close()
 	if err != nil {
 		return fmt.Errorf("Error validating credentials %w", err)
 	}
+	defer c.Segment.Close()
 
 	auth, err := c.Keystone.GetAuth(
 		cfg.Username,
@@ -188,6 +243,10 @@ func ValidateUserCredentials(cfg *objects.Config, nc objects.NodeConfig) error {
 	endpointURL, err1 := keystone.FetchRegionFQDN(cfg.Fqdn, cfg.Region, auth)
 	if endpointURL == "" || err1 != nil {
 		zap.S().Debug("Invalid Region")
+		var regionNotFound *keystone.RegionNotFoundError
+		if errors.As(err1, &regionNotFound) && regionNotFound.Suggestion != "" {
+			return fmt.Errorf("%w, did you mean %s?", REGION_INVALID, regionNotFound.Suggestion)
+		}
 		return REGION_INVALID
 	}
 	return nil
@@ -361,9 +420,8 @@ func ConfigCmdCreateRun(cfg *objects.Config) error {
 func createClient(cfg *objects.Config, nc objects.NodeConfig) (client.Client, error) {
 	executor, err := cmdexec.GetExecutor(cfg.ProxyURL, nc)
 	if err != nil {
-		//debug first since Fatalf calls os.Exit
-		zap.S().Debug("Error connecting to host %s", err.Error())
-		zap.S().Fatalf(" Invalid (Username/Password/IP), use 'single quotes' to pass password")
+		zap.S().Debugf("Error connecting to host %s", err.Error())
+		return client.Client{}, fmt.Errorf("Invalid (Username/Password/IP), use 'single quotes' to pass password: %w", err)
 	}
 
 	return client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false)
@@ -376,6 +434,9 @@ func clearContext(v interface{}) {
 }
 
 func ValidateNodeConfig(nc *objects.NodeConfig, interactive bool) bool {
+	applyNodeSecrets(nc)
+	// IPv6 addresses may be given in brackets
+	nc.IPs = util.NormalizeIPs(nc.IPs)
 
 	if nc.User == "" || (nc.SshKey == "" && nc.Password == "") {
 		if !interactive {
@@ -405,7 +466,8 @@ func ValidateNodeConfig(nc *objects.NodeConfig, interactive bool) bool {
 				nc.SshKey, _ = reader.ReadString('\n')
 				nc.SshKey = strings.TrimSpace(nc.SshKey)
 			default:
-				zap.S().Fatalf("Wrong choice please try again")
+				fmt.Println("Wrong choice please try again")
+				return false
 			}
 			fmt.Printf("\n")
 		}
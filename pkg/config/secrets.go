@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/platform9/pf9ctl/pkg/objects"
+)
+
+// Secrets can be read from stdin and file descriptors instead of argv or the
+// environment, like docker login --password-stdin. When both the password and
+// the MFA token are read from stdin, the password is its first line and the
+// MFA token its second.
+var (
+	// PasswordStdin reads the password of the account from stdin
+	PasswordStdin bool
+	// MFAStdin reads the MFA token from stdin
+	MFAStdin bool
+	// SSHKeyFD is the file descriptor the SSH key of the nodes is read from,
+	// -1 when not set
+	SSHKeyFD = -1
+)
+
+// secretInput is where the secrets are read from, stdin outside of tests
+var secretInput io.Reader = os.Stdin
+
+var stdinSecrets struct {
+	once     sync.Once
+	password string
+	mfa      string
+	err      error
+}
+
+// readStdinSecrets reads the secrets of stdin once, as stdin can only be read once
+func readStdinSecrets() (string, string, error) {
+	stdinSecrets.once.Do(func() {
+		reader := bufio.NewReader(secretInput)
+		if PasswordStdin {
+			stdinSecrets.password, stdinSecrets.err = readSecretLine(reader, "password")
+			if stdinSecrets.err != nil {
+				return
+			}
+		}
+		if MFAStdin {
+			stdinSecrets.mfa, stdinSecrets.err = readSecretLine(reader, "MFA token")
+		}
+	})
+	return stdinSecrets.password, stdinSecrets.mfa, stdinSecrets.err
+}
+
+func readSecretLine(reader *bufio.Reader, name string) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("unable to read the %s from stdin: %w", name, err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("no %s given on stdin", name)
+	}
+	return line, nil
+}
+
+// ApplySecrets sets the password and the MFA token of cfg from stdin when
+// requested, overriding the ones of the config file
+func ApplySecrets(cfg *objects.Config) error {
+	if !PasswordStdin && !MFAStdin {
+		return nil
+	}
+	if MFAStdin && cfg.MfaToken != "" {
+		return fmt.Errorf("--mfa and --mfa-stdin are mutually exclusive")
+	}
+	password, mfa, err := readStdinSecrets()
+	if err != nil {
+		return err
+	}
+	if PasswordStdin {
+		cfg.Password = password
+	}
+	if MFAStdin {
+		cfg.MfaToken = mfa
+	}
+	return nil
+}
+
+// applyNodeSecrets makes nc use the SSH key of SSHKeyFD when set
+func applyNodeSecrets(nc *objects.NodeConfig) {
+	if SSHKeyFD >= 0 && nc.SshKey == "" {
+		nc.SshKey = fmt.Sprintf("/dev/fd/%d", SSHKeyFD)
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplySecrets(t *testing.T) {
+	cases := map[string]struct {
+		passwordStdin bool
+		mfaStdin      bool
+		stdin         string
+		mfaFlag       string
+		want          objects.Config
+		wantErr       string
+	}{
+		"Nothing": {
+			stdin: "ignored\n",
+			want:  objects.Config{Password: "file-password"},
+		},
+		"Password": {
+			passwordStdin: true,
+			stdin:         "s3cr3t pass\n",
+			want:          objects.Config{Password: "s3cr3t pass"},
+		},
+		"PasswordWithoutNewline": {
+			passwordStdin: true,
+			stdin:         "s3cr3t",
+			want:          objects.Config{Password: "s3cr3t"},
+		},
+		"PasswordAndMFA": {
+			passwordStdin: true,
+			mfaStdin:      true,
+			stdin:         "s3cr3t\r\n123456\n",
+			want:          objects.Config{Password: "s3cr3t", MfaToken: "123456"},
+		},
+		"MFA": {
+			mfaStdin: true,
+			stdin:    "123456\n",
+			want:     objects.Config{Password: "file-password", MfaToken: "123456"},
+		},
+		"EmptyStdin": {
+			passwordStdin: true,
+			wantErr:       "no password given on stdin",
+		},
+		"MissingMFA": {
+			passwordStdin: true,
+			mfaStdin:      true,
+			stdin:         "s3cr3t\n",
+			wantErr:       "no MFA token given on stdin",
+		},
+		"MFAFlagAndStdin": {
+			mfaStdin: true,
+			mfaFlag:  "654321",
+			stdin:    "123456\n",
+			wantErr:  "mutually exclusive",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				PasswordStdin, MFAStdin, secretInput = false, false, os.Stdin
+			}()
+			PasswordStdin, MFAStdin = tc.passwordStdin, tc.mfaStdin
+			secretInput = strings.NewReader(tc.stdin)
+			stdinSecrets.once = sync.Once{}
+
+			cfg := objects.Config{Password: "file-password", MfaToken: tc.mfaFlag}
+			err := ApplySecrets(&cfg)
+			if tc.wantErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.wantErr)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, cfg)
+		})
+	}
+}
+
+func TestApplyNodeSecrets(t *testing.T) {
+	defer func() { SSHKeyFD = -1 }()
+
+	nc := objects.NodeConfig{User: "ubuntu"}
+	applyNodeSecrets(&nc)
+	assert.Equal(t, "", nc.SshKey)
+
+	SSHKeyFD = 3
+	applyNodeSecrets(&nc)
+	assert.Equal(t, "/dev/fd/3", nc.SshKey)
+
+	// An explicit key file takes precedence
+	nc.SshKey = "/home/ubuntu/.ssh/id_rsa"
+	applyNodeSecrets(&nc)
+	assert.Equal(t, "/home/ubuntu/.ssh/id_rsa", nc.SshKey)
+}
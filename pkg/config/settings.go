@@ -0,0 +1,200 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/objects"
+)
+
+// Redacted replaces the secrets of the config when it is shown
+const Redacted = "REDACTED"
+
+// secretSettings are the settings which are redacted
+var secretSettings = map[string]bool{
+	"password":       true,
+	"aws_access_key": true,
+	"aws_secret_key": true,
+	"azure_secret":   true,
+}
+
+// unsettable are the fields of the config which aren't settings, the MFA
+// token is never stored
+var unsettable = map[string]bool{
+	"mfa_token":   true,
+	"wait_period": true,
+}
+
+// settingFields returns the fields of the config by setting key, the key of a
+// setting being its name in the stored config
+func settingFields(cfg *objects.Config) map[string]reflect.Value {
+	fields := make(map[string]reflect.Value)
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" || unsettable[key] {
+			continue
+		}
+		fields[key] = v.Field(i)
+	}
+	return fields
+}
+
+// SettingKeys returns the keys of the settings of the config, sorted
+func SettingKeys() []string {
+	var keys []string
+	for key := range settingFields(&objects.Config{}) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func settingField(cfg *objects.Config, key string) (reflect.Value, error) {
+	field, ok := settingFields(cfg)[key]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("unknown setting %q, the settings are: %s", key, strings.Join(SettingKeys(), ", "))
+	}
+	return field, nil
+}
+
+// GetSetting returns the value of the setting key of cfg
+func GetSetting(cfg *objects.Config, key string) (string, error) {
+	field, err := settingField(cfg, key)
+	if err != nil {
+		return "", err
+	}
+	switch field.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), nil
+	case reflect.Slice:
+		if field.Len() == 0 {
+			return "", nil
+		}
+		data, err := json.Marshal(field.Interface())
+		return string(data), err
+	}
+	return field.String(), nil
+}
+
+// SetSetting sets the setting key of cfg to value
+func SetSetting(cfg *objects.Config, key, value string) error {
+	field, err := settingField(cfg, key)
+	if err != nil {
+		return err
+	}
+	if field.Kind() == reflect.Bool {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s is either true or false", key)
+		}
+		field.SetBool(b)
+		return nil
+	}
+	if field.Kind() == reflect.Slice {
+		list, err := ParseList(value)
+		if err != nil {
+			return fmt.Errorf("%s is a JSON array of strings or a comma separated list: %w", key, err)
+		}
+		field.Set(reflect.ValueOf(list))
+		return nil
+	}
+	field.SetString(value)
+	return nil
+}
+
+// ParseList parses the value of a list setting, a JSON array for the items
+// with commas
+func ParseList(value string) ([]string, error) {
+	var list []string
+	if strings.HasPrefix(strings.TrimSpace(value), "[") {
+		err := json.Unmarshal([]byte(value), &list)
+		return list, err
+	}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
+
+// UnsetSetting resets the setting key of cfg to its default
+func UnsetSetting(cfg *objects.Config, key string) error {
+	field, err := settingField(cfg, key)
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.Zero(field.Type()))
+	return nil
+}
+
+// ParseAssignment splits an assignment of a setting like fqdn=https://du
+func ParseAssignment(assignment string) (string, string, error) {
+	i := strings.Index(assignment, "=")
+	if i <= 0 {
+		return "", "", fmt.Errorf("invalid setting %q, settings are given as key=value", assignment)
+	}
+	return assignment[:i], assignment[i+1:], nil
+}
+
+// Settings returns the settings of cfg which are set, sorted by key. The
+// secrets and the credentials of the proxy are replaced when redact is set.
+func Settings(cfg *objects.Config, redact bool) [][2]string {
+	var settings [][2]string
+	for _, key := range SettingKeys() {
+		value, _ := GetSetting(cfg, key)
+		if value == "" || value == "false" {
+			continue
+		}
+		if redact {
+			value = redactSetting(key, value)
+		}
+		settings = append(settings, [2]string{key, value})
+	}
+	return settings
+}
+
+func redactSetting(key, value string) string {
+	if secretSettings[key] {
+		return Redacted
+	}
+	if key == "proxy_url" {
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			u.User = url.User(Redacted)
+			return u.String()
+		}
+	}
+	return value
+}
+
+// ReadStoredConfig reads the config stored at loc as it is, without applying
+// --tenant or validating it against the DU
+func ReadStoredConfig(loc string) (objects.Config, error) {
+	var cfg objects.Config
+	f, err := os.Open(loc)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, NO_CONFIG
+		}
+		return cfg, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("unable to read the config %s: %w", loc, err)
+	}
+	password, err := base64.StdEncoding.DecodeString(cfg.Password)
+	if err != nil {
+		return cfg, fmt.Errorf("unable to decode the password of the config: %w", err)
+	}
+	cfg.Password = string(password)
+	return cfg, nil
+}
@@ -0,0 +1,17 @@
+package config
+
+import (
+	"github.com/platform9/pf9ctl/pkg/objects"
+)
+
+// Tenant overrides the tenant of the config for the current run, so the
+// tenants of a DU can be scripted against without editing the config between
+// runs. The config file keeps its own tenant.
+var Tenant string
+
+// applyTenant makes cfg use the tenant set with --tenant
+func applyTenant(cfg *objects.Config) {
+	if Tenant != "" {
+		cfg.Tenant = Tenant
+	}
+}
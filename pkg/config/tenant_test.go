@@ -0,0 +1,65 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfigTenant(t *testing.T) {
+	cases := map[string]struct {
+		override string
+		want     string
+	}{
+		"ConfigTenant": {want: "service"},
+		"Override":     {override: "customer-a", want: "customer-a"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Keystone rejects the credentials, only the tenant they were
+			// sent with matters
+			var scoped string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Auth struct {
+						Scope struct {
+							Project struct {
+								Name string `json:"name"`
+							} `json:"project"`
+						} `json:"scope"`
+					} `json:"auth"`
+				}
+				json.NewDecoder(r.Body).Decode(&body)
+				scoped = body.Auth.Scope.Project.Name
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			defer server.Close()
+
+			dir, err := ioutil.TempDir("", "config")
+			assert.NoError(t, err)
+			defer os.RemoveAll(dir)
+			loc := filepath.Join(dir, "config.json")
+			data, _ := json.Marshal(objects.Config{
+				Fqdn: server.URL, Username: "jdoe@example.com", Region: "RegionOne", Tenant: "service",
+				Password: base64.StdEncoding.EncodeToString([]byte("secret")),
+			})
+			assert.Nil(t, ioutil.WriteFile(loc, data, 0600))
+
+			Tenant = tc.override
+			defer func() { Tenant = "" }()
+			cfg := &objects.Config{}
+			assert.Equal(t, INVALID_CREDS, LoadConfig(loc, cfg, objects.NodeConfig{}))
+			assert.Equal(t, tc.want, cfg.Tenant)
+			assert.Equal(t, tc.want, scoped)
+		})
+	}
+}
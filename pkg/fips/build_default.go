@@ -0,0 +1,7 @@
+//go:build !fips
+// +build !fips
+
+package fips
+
+// builtIn enables the FIPS mode in the builds with the fips tag
+const builtIn = false
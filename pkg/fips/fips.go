@@ -0,0 +1,115 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+
+// Package fips restricts the TLS and SSH connections of pf9ctl to the
+// algorithms approved by FIPS 140. The mode is enabled with --fips, the fips
+// setting, or by building pf9ctl with the fips tag.
+package fips
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Enabled restricts the connections to the FIPS approved algorithms, it can't
+// be disabled in the builds with the fips tag
+var Enabled = builtIn
+
+// BuiltIn reports whether pf9ctl is built with the fips tag
+func BuiltIn() bool {
+	return builtIn
+}
+
+// Enable enables the FIPS mode when on is set, the builds with the fips tag
+// always run in it
+func Enable(on bool) {
+	Enabled = builtIn || on
+}
+
+// TLS cipher suites, curves and versions approved by FIPS 140
+var (
+	CipherSuites = []uint16{
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	}
+	CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+)
+
+// SSH algorithms approved by FIPS 140
+var (
+	SSHCiphers      = []string{"aes128-gcm@openssh.com", "aes128-ctr", "aes192-ctr", "aes256-ctr"}
+	SSHKeyExchanges = []string{"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521"}
+	SSHMACs         = []string{"hmac-sha2-256-etm@openssh.com", "hmac-sha2-256"}
+	// SSHKeyAlgorithms are the algorithms of the host keys and of the keys
+	// authenticating to the nodes. The RSA keys sign with SHA-1, which FIPS
+	// no longer approves for signatures.
+	SSHKeyAlgorithms = []string{ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521}
+)
+
+// TLSConfig returns cfg restricted to TLS 1.2 and the FIPS approved cipher
+// suites and curves in the FIPS mode, cfg as it is otherwise. cfg may be nil.
+func TLSConfig(cfg *tls.Config) *tls.Config {
+	if !Enabled {
+		return cfg
+	}
+	if cfg == nil {
+		cfg = &tls.Config{}
+	} else {
+		cfg = cfg.Clone()
+	}
+	// The cipher suites of TLS 1.3 can't be restricted
+	cfg.MinVersion = tls.VersionTLS12
+	cfg.MaxVersion = tls.VersionTLS12
+	cfg.CipherSuites = CipherSuites
+	cfg.CurvePreferences = CurvePreferences
+	return cfg
+}
+
+// SSHConfig restricts cfg to the FIPS approved algorithms in the FIPS mode.
+// The host key algorithms of cfg, e.g: the ones of the known hosts, are kept
+// when they are approved, SSHConfig fails when none is.
+func SSHConfig(cfg *ssh.ClientConfig) error {
+	if !Enabled {
+		return nil
+	}
+	cfg.Ciphers = SSHCiphers
+	cfg.KeyExchanges = SSHKeyExchanges
+	cfg.MACs = SSHMACs
+	if len(cfg.HostKeyAlgorithms) == 0 {
+		cfg.HostKeyAlgorithms = SSHKeyAlgorithms
+		return nil
+	}
+	var approved []string
+	for _, algorithm := range cfg.HostKeyAlgorithms {
+		if isApproved(algorithm) {
+			approved = append(approved, algorithm)
+		}
+	}
+	if len(approved) == 0 {
+		return fmt.Errorf("the known host keys are %s keys, which FIPS doesn't approve, add an ECDSA host key of the node to the known hosts", strings.Join(cfg.HostKeyAlgorithms, ", "))
+	}
+	cfg.HostKeyAlgorithms = approved
+	return nil
+}
+
+// CheckSSHKey fails in the FIPS mode when the key authenticating to the nodes
+// isn't a FIPS approved key
+func CheckSSHKey(signer ssh.Signer) error {
+	if !Enabled || isApproved(signer.PublicKey().Type()) {
+		return nil
+	}
+	return fmt.Errorf("the SSH key is a %s key, which FIPS doesn't approve, use an ECDSA key", signer.PublicKey().Type())
+}
+
+func isApproved(algorithm string) bool {
+	for _, approved := range SSHKeyAlgorithms {
+		if algorithm == approved {
+			return true
+		}
+	}
+	return false
+}
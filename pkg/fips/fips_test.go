@@ -0,0 +1,48 @@
+package fips
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestTLSConfig(t *testing.T) {
+	// Enable can't disable the mode in the builds with the fips tag
+	defer func() { Enabled = builtIn }()
+
+	Enabled = false
+	assert.Nil(t, TLSConfig(nil))
+	insecure := &tls.Config{InsecureSkipVerify: true}
+	assert.Equal(t, insecure, TLSConfig(insecure))
+
+	Enabled = true
+	cfg := TLSConfig(insecure)
+	assert.True(t, cfg.InsecureSkipVerify)
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MaxVersion)
+	assert.Equal(t, CipherSuites, cfg.CipherSuites)
+	assert.Nil(t, insecure.CipherSuites)
+}
+
+func TestSSHConfig(t *testing.T) {
+	// Enable can't disable the mode in the builds with the fips tag
+	defer func() { Enabled = builtIn }()
+
+	Enabled = false
+	cfg := &ssh.ClientConfig{}
+	assert.NoError(t, SSHConfig(cfg))
+	assert.Nil(t, cfg.Ciphers)
+
+	Enabled = true
+	assert.NoError(t, SSHConfig(cfg))
+	assert.Equal(t, SSHKeyExchanges, cfg.KeyExchanges)
+	assert.Equal(t, SSHKeyAlgorithms, cfg.HostKeyAlgorithms)
+
+	cfg = &ssh.ClientConfig{HostKeyAlgorithms: []string{ssh.KeyAlgoED25519, ssh.KeyAlgoECDSA256}}
+	assert.NoError(t, SSHConfig(cfg))
+	assert.Equal(t, []string{ssh.KeyAlgoECDSA256}, cfg.HostKeyAlgorithms)
+
+	cfg = &ssh.ClientConfig{HostKeyAlgorithms: []string{ssh.KeyAlgoED25519}}
+	assert.EqualError(t, SSHConfig(cfg), "the known host keys are ssh-ed25519 keys, which FIPS doesn't approve, add an ECDSA host key of the node to the known hosts")
+}
@@ -0,0 +1,145 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+
+// Package interrupt runs the cleanups of a command when it is interrupted
+// with Ctrl-C or SIGTERM, so the node and the terminal aren't left in the
+// state the command was in. The cleanups are registered while the command
+// changes something it undoes once done, and unregistered once undone.
+//
+// The operations which can't be cleaned up from another goroutine, e.g.
+// because a command is running on the node, stop on Context instead and
+// clean up themselves, holding the exit with Hold meanwhile.
+package interrupt
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Timeout is how long the cleanups have to run once interrupted, the
+// command exits anyway after it
+var Timeout = 30 * time.Second
+
+var (
+	mu       sync.Mutex
+	cleanups = map[int]func(){}
+	nextID   int
+
+	interrupted, cancel = context.WithCancel(context.Background())
+)
+
+// Context is done once the command is interrupted
+func Context() context.Context {
+	return interrupted
+}
+
+// Hold makes the interrupted command wait for the operation stopping on
+// Context to clean up, until release is called or after Timeout
+func Hold() (release func()) {
+	done := make(chan struct{})
+	var once sync.Once
+	unregister := Register(func() { <-done })
+	return func() {
+		once.Do(func() { close(done) })
+		unregister()
+	}
+}
+
+// Register adds f to the cleanups run when the command is interrupted,
+// until the returned function is called
+func Register(f func()) (unregister func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	id := nextID
+	nextID++
+	cleanups[id] = f
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(cleanups, id)
+	}
+}
+
+// Defer registers f and returns the function running it once, either when
+// the command is interrupted or when it is called. It replaces a defer:
+//
+//	defer interrupt.Defer(func() { ... })()
+func Defer(f func()) func() {
+	var once sync.Once
+	run := func() { once.Do(f) }
+	unregister := Register(run)
+	return func() {
+		unregister()
+		run()
+	}
+}
+
+// Run runs the registered cleanups, the last registered first, and returns
+// once they are done or after Timeout
+func Run() {
+	mu.Lock()
+	pending := make([]func(), 0, len(cleanups))
+	for id := nextID - 1; id >= 0; id-- {
+		if f, ok := cleanups[id]; ok {
+			pending = append(pending, f)
+			delete(cleanups, id)
+		}
+	}
+	mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, f := range pending {
+			f()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(Timeout):
+		zap.S().Debugf("The cleanups didn't finish within %s", Timeout)
+	}
+}
+
+// Wait blocks the goroutine of an interrupted command while the handler of
+// Notify runs the cleanups, until it exits. It returns at once when the
+// command isn't interrupted.
+func Wait() {
+	if interrupted.Err() != nil {
+		select {}
+	}
+}
+
+// Notify cancels Context and runs the cleanups then handler on the first
+// SIGINT or SIGTERM, handler is expected to exit. A second signal exits at
+// once with the code of the signal.
+func Notify(handler func(os.Signal)) {
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-ch
+		zap.S().Debugf("Received %s, cleaning up", sig)
+		cancel()
+		go func() {
+			sig := <-ch
+			zap.S().Debugf("Received %s while cleaning up, exiting", sig)
+			os.Exit(ExitCode(sig))
+		}()
+		Run()
+		handler(sig)
+	}()
+}
+
+// ExitCode is the exit code of a command killed by sig, 128 plus the
+// number of the signal like the shells
+func ExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
@@ -0,0 +1,70 @@
+package interrupt
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	var ran []string
+	Register(func() { ran = append(ran, "first") })
+	unregister := Register(func() { ran = append(ran, "unregistered") })
+	Register(func() { ran = append(ran, "last") })
+	unregister()
+
+	Run()
+	assert.Equal(t, []string{"last", "first"}, ran)
+
+	// The cleanups only run once
+	Run()
+	assert.Equal(t, []string{"last", "first"}, ran)
+}
+
+func TestDefer(t *testing.T) {
+	count := 0
+	cleanup := Defer(func() { count++ })
+	cleanup()
+	Run()
+	assert.Equal(t, 1, count)
+
+	count = 0
+	cleanup = Defer(func() { count++ })
+	Run()
+	cleanup()
+	assert.Equal(t, 1, count)
+}
+
+func TestRunTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { Timeout = timeout }(Timeout)
+	Timeout = 10 * time.Millisecond
+	block := make(chan struct{})
+	defer close(block)
+	Register(func() { <-block })
+
+	start := time.Now()
+	Run()
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestHold(t *testing.T) {
+	release := Hold()
+	cleanedUp := false
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cleanedUp = true
+		release()
+	}()
+	// Run waits for the holder to clean up
+	Run()
+	assert.True(t, cleanedUp)
+	release()
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 130, ExitCode(os.Interrupt))
+	assert.Equal(t, 143, ExitCode(syscall.SIGTERM))
+}
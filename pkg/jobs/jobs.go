@@ -0,0 +1,246 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+
+// Package jobs keeps track of batch operations run on several hosts. A job is
+// persisted in the local state store after every host is processed, so an
+// interrupted batch can be inspected and resumed later.
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/statefile"
+	"github.com/platform9/pf9ctl/pkg/util"
+)
+
+// Operations run as jobs
+const (
+	AttachNode = "attach-node"
+	DetachNode = "detach-node"
+)
+
+// Status of a job or of a single host of a job
+const (
+	Pending = "pending"
+	Running = "running"
+	Done    = "done"
+	Failed  = "failed"
+)
+
+// Host is a single host processed by a job
+type Host struct {
+	IP          string    `json:"ip"`
+	HostID      string    `json:"hostId"`
+	Role        string    `json:"role,omitempty"`
+	ClusterUuid string    `json:"clusterUuid,omitempty"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Job is a batch operation run on several hosts
+type Job struct {
+	ID        string `json:"id"`
+	Operation string `json:"operation"`
+	// Fqdn and ProjectID are the DU and tenant the hosts are registered
+	// with, unset for the jobs created before they were recorded
+	Fqdn        string    `json:"fqdn,omitempty"`
+	ProjectID   string    `json:"projectId,omitempty"`
+	ClusterName string    `json:"clusterName,omitempty"`
+	ClusterUuid string    `json:"clusterUuid,omitempty"`
+	Hosts       []Host    `json:"hosts"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// New creates a job for operation on the hosts of the tenant projectID of
+// the DU at fqdn with all the hosts pending and saves it.
+func New(operation, fqdn, projectID, clusterName, clusterUuid string, hosts []Host) (*Job, error) {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("unable to generate job ID: %w", err)
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:          hex.EncodeToString(id),
+		Operation:   operation,
+		Fqdn:        fqdn,
+		ProjectID:   projectID,
+		ClusterName: clusterName,
+		ClusterUuid: clusterUuid,
+		CreatedAt:   now,
+	}
+	for _, host := range hosts {
+		host.Status = Pending
+		host.UpdatedAt = now
+		job.Hosts = append(job.Hosts, host)
+	}
+	if _, err := Lock(job.ID); err != nil {
+		return nil, err
+	}
+	return job, job.Save()
+}
+
+// Lock locks the job with id until the process exits, so it isn't resumed
+// by two pf9ctl at once. The job is locked by the pf9ctl creating it.
+func Lock(id string) (*statefile.Lock, error) {
+	if err := os.MkdirAll(util.Pf9JobsDir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create jobs dir: %w", err)
+	}
+	lock, err := statefile.LockFile(jobFile(id), 0)
+	if err != nil {
+		return nil, fmt.Errorf("job %s is in use: %w", id, err)
+	}
+	return lock, nil
+}
+
+// Load reads the job with id from the state store
+func Load(id string) (*Job, error) {
+	if id == "" || filepath.Base(id) != id {
+		return nil, fmt.Errorf("invalid job ID %q", id)
+	}
+	data, err := ioutil.ReadFile(jobFile(id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("job %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read job %s: %w", id, err)
+	}
+
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, fmt.Errorf("unable to parse job %s: %w", id, err)
+	}
+	return job, nil
+}
+
+// List returns all the jobs in the state store, the most recent first.
+func List() ([]*Job, error) {
+	files, err := filepath.Glob(filepath.Join(util.Pf9JobsDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []*Job
+	for _, file := range files {
+		job, err := Load(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	return jobs, nil
+}
+
+// Save writes the job to the state store
+func (j *Job) Save() error {
+	if err := os.MkdirAll(util.Pf9JobsDir, 0700); err != nil {
+		return fmt.Errorf("unable to create jobs dir: %w", err)
+	}
+
+	j.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statefile.WriteFile(jobFile(j.ID), data, 0600)
+}
+
+// SetHostStatus records the status of the host with hostID and saves the job.
+// err is recorded when the host failed.
+func (j *Job) SetHostStatus(hostID, status string, err error) error {
+	for i := range j.Hosts {
+		if j.Hosts[i].HostID != hostID {
+			continue
+		}
+		j.Hosts[i].Status = status
+		j.Hosts[i].Error = ""
+		if err != nil {
+			j.Hosts[i].Error = err.Error()
+		}
+		j.Hosts[i].UpdatedAt = time.Now()
+	}
+	return j.Save()
+}
+
+// Delete removes the job and its lock file from the state store
+func (j *Job) Delete() error {
+	for _, file := range []string{jobFile(j.ID), jobFile(j.ID) + ".lock"} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to delete job %s: %w", j.ID, err)
+		}
+	}
+	return nil
+}
+
+// BelongsTo is true when the hosts of the job are registered with the tenant
+// projectID of the DU at fqdn. The jobs which didn't record them only belong
+// to it with includeLegacy.
+func (j *Job) BelongsTo(fqdn, projectID string, includeLegacy bool) bool {
+	if j.Fqdn == "" {
+		return includeLegacy
+	}
+	return j.Fqdn == fqdn && j.ProjectID == projectID
+}
+
+// PruneHosts removes the hosts for which exists is false and returns them.
+// The job isn't saved.
+func (j *Job) PruneHosts(exists func(hostID string) bool) []Host {
+	var kept, pruned []Host
+	for _, host := range j.Hosts {
+		if exists(host.HostID) {
+			kept = append(kept, host)
+		} else {
+			pruned = append(pruned, host)
+		}
+	}
+	j.Hosts = kept
+	return pruned
+}
+
+// Remaining returns the hosts which are not done yet
+func (j *Job) Remaining() []Host {
+	var hosts []Host
+	for _, host := range j.Hosts {
+		if host.Status != Done {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// Status summarizes the status of the hosts. A job is running until every host
+// is processed, hosts left running by an interrupted run keep it running too.
+func (j *Job) Status() string {
+	status := Done
+	for _, host := range j.Hosts {
+		switch host.Status {
+		case Pending, Running:
+			return Running
+		case Failed:
+			status = Failed
+		}
+	}
+	return status
+}
+
+// Progress returns the number of hosts done out of all the hosts
+func (j *Job) Progress() string {
+	return fmt.Sprintf("%d/%d", len(j.Hosts)-len(j.Remaining()), len(j.Hosts))
+}
+
+func jobFile(id string) string {
+	return filepath.Join(util.Pf9JobsDir, id+".json")
+}
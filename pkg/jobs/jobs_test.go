@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	util.Pf9JobsDir = dir
+
+	job, err := New(AttachNode, "du.example.com", "project-a", "cluster-a", "uuid-a", []Host{
+		{IP: "10.0.0.1", HostID: "host-1", Role: "master"},
+		{IP: "10.0.0.2", HostID: "host-2", Role: "worker"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, Running, job.Status())
+	assert.Equal(t, "0/2", job.Progress())
+
+	assert.Nil(t, job.SetHostStatus("host-1", Done, nil))
+	assert.Nil(t, job.SetHostStatus("host-2", Failed, errors.New("timed out")))
+
+	loaded, err := Load(job.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, Failed, loaded.Status())
+	assert.Equal(t, "1/2", loaded.Progress())
+	assert.Equal(t, "host-2", loaded.Remaining()[0].HostID)
+	assert.Equal(t, "timed out", loaded.Remaining()[0].Error)
+
+	list, err := List()
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+
+	_, err = Load("../config")
+	assert.NotNil(t, err)
+	_, err = Load("missing")
+	assert.EqualError(t, err, "job missing not found")
+
+	assert.Nil(t, job.Delete())
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+}
+
+func TestJobStatus(t *testing.T) {
+	cases := map[string]struct {
+		statuses []string
+		want     string
+	}{
+		"AllDone":     {statuses: []string{Done, Done}, want: Done},
+		"SomeFailed":  {statuses: []string{Done, Failed}, want: Failed},
+		"Interrupted": {statuses: []string{Done, Running, Pending}, want: Running},
+		"NotStarted":  {statuses: []string{Pending, Failed}, want: Running},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			job := &Job{}
+			for _, status := range tc.statuses {
+				job.Hosts = append(job.Hosts, Host{Status: status})
+			}
+			assert.Equal(t, tc.want, job.Status())
+		})
+	}
+}
@@ -74,7 +74,39 @@ func (e_api *EndpointManagerAPI) GetEndpointForRegion_API(
 	serviceID string,
 ) (string, error) {
 	zap.S().Debug("Fetching endpoints for region ", regionName)
+	endpoints, err := e_api.GetRegionEndpoints_API(serviceID)
+	if err != nil {
+		return "", err
+	}
+	endpointURL := endpoints[regionName]
+	zap.S().Debug("FQDN: ", endpointURL)
+	return endpointURL, nil
+}
+
+// Fetches the internal endpoint FQDN of every region the service is registered in.
+func GetRegionEndpoints(
+	fqdn string, //DU fqdn
+	auth KeystoneAuth, // Auth info
+	serviceID string, // ID for regionInfo service
+) (map[string]string, error) {
+
+	zap.S().Debug("Fetching endpoints of all regions")
+
+	url := fmt.Sprintf("%s/keystone/v3/endpoints", fqdn)
+	client := &http.Client{}
+	e_api := EndpointManagerAPI{client, url, auth.Token}
+
+	return e_api.GetRegionEndpoints_API(serviceID)
+}
+
+// Endpoint manager function returning a map of region name to endpoint FQDN.
+func (e_api *EndpointManagerAPI) GetRegionEndpoints_API(
+	serviceID string,
+) (map[string]string, error) {
 	req, err := http.NewRequest("GET", e_api.BaseURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create request to fetch endpoints: %w", err)
+	}
 
 	// Add keystone token in the header.
 	req.Header.Add("X-Auth-Token", e_api.Token)
@@ -86,8 +118,8 @@ func (e_api *EndpointManagerAPI) GetEndpointForRegion_API(
 
 	resp, err := e_api.Client.Do(req)
 	if err != nil {
-		zap.S().Errorf("Failed to fetch endpoint information for region %s, Error: %s", regionName, err)
-		return "", fmt.Errorf("Failed to fetch endpoint information for region %s, Error: %s", regionName, err)
+		zap.S().Errorf("Failed to fetch endpoint information, Error: %s", err)
+		return nil, fmt.Errorf("Failed to fetch endpoint information, Error: %s", err)
 	}
 	defer resp.Body.Close()
 
@@ -96,26 +128,23 @@ func (e_api *EndpointManagerAPI) GetEndpointForRegion_API(
 	err = json.NewDecoder(resp.Body).Decode(&endpointsInfo)
 	if err != nil {
 		zap.S().Errorf("Failed to decode endpoint information, Error: %s", err)
-		return "", fmt.Errorf("Failed to decode endpoint information, Error: %s", err)
+		return nil, fmt.Errorf("Failed to decode endpoint information, Error: %s", err)
 	}
 
-	var endpointURL string
+	endpoints := make(map[string]string)
 	for _, endpoint := range endpointsInfo.Endpoints {
-		// There will be multiple regions. Filter based on region name and
-		// interface which is going to give exact endpoint for a region.
-		if (endpoint.Region == regionName) && (endpoint.Interface == "internal") {
-			zap.S().Debug("endpoint: ", endpoint.URL)
-			u, err := url.Parse(endpoint.URL)
-			if err != nil {
-				zap.S().Errorf("Failed to parse endpoint information, Error: %s", err)
-				return "", fmt.Errorf("Failed to parse endpoint information, Error: %s", err)
-
-			}
-			endpointURL = u.Host
-			zap.S().Debug("FQDN: ", endpointURL)
-			break
+		// There will be multiple endpoints per region. The internal
+		// interface gives the exact endpoint for a region.
+		if endpoint.Interface != "internal" {
+			continue
 		}
+		u, err := url.Parse(endpoint.URL)
+		if err != nil {
+			zap.S().Errorf("Failed to parse endpoint information, Error: %s", err)
+			return nil, fmt.Errorf("Failed to parse endpoint information, Error: %s", err)
+		}
+		endpoints[endpoint.Region] = u.Host
 	}
 
-	return endpointURL, nil
+	return endpoints, nil
 }
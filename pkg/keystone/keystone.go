@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/platform9/pf9ctl/pkg/log"
 	"go.uber.org/zap"
 )
 
@@ -17,11 +18,16 @@ type KeystoneAuth struct {
 	Token     string
 	UserID    string
 	ProjectID string
-	Email     string
+	// ProjectName is the name of the tenant of the token
+	ProjectName string
+	Email       string
+	// Roles are the names of the roles of the user on the project
+	Roles []string
 }
 
 type Keystone interface {
 	GetAuth(username, password, tenant string, mfa string) (KeystoneAuth, error)
+	GetAuthWithApplicationCredential(id, secret string) (KeystoneAuth, error)
 }
 
 type KeystoneImpl struct {
@@ -38,7 +44,9 @@ func (k KeystoneImpl) GetAuth(
 	tenant string,
 	mfa string) (auth KeystoneAuth, err error) {
 
-	zap.S().Debugf("Received a call to fetch keystone authentication for fqdn: %s and user: %s and tenant: %s, mfa_token: %s\n", k.fqdn, username, tenant, mfa)
+	zap.S().Debugf("Received a call to fetch keystone authentication for fqdn: %s and user: %s and tenant: %s, mfa: %t\n", k.fqdn, username, tenant, mfa != "")
+	log.RegisterSecret(password)
+	log.RegisterSecret(mfa)
 
 	url := fmt.Sprintf("%s/keystone/v3/auth/tokens?nocatalog", k.fqdn)
 
@@ -155,6 +163,34 @@ func (k KeystoneImpl) GetAuth(
 			}`, username, password, tenant)
 		}
 	}
+	return k.issueToken(url, body)
+}
+
+// GetAuthWithApplicationCredential fetches a token with an application
+// credential, the token is scoped to the project the credential was created in.
+func (k KeystoneImpl) GetAuthWithApplicationCredential(id, secret string) (KeystoneAuth, error) {
+	zap.S().Debugf("Received a call to fetch keystone authentication for fqdn: %s and application credential: %s\n", k.fqdn, id)
+	log.RegisterSecret(secret)
+
+	url := fmt.Sprintf("%s/keystone/v3/auth/tokens?nocatalog", k.fqdn)
+	body, err := json.Marshal(map[string]interface{}{
+		"auth": map[string]interface{}{
+			"identity": map[string]interface{}{
+				"methods": []string{"application_credential"},
+				"application_credential": map[string]string{
+					"id":     id,
+					"secret": secret,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return KeystoneAuth{}, err
+	}
+	return k.issueToken(url, string(body))
+}
+
+func (k KeystoneImpl) issueToken(url, body string) (auth KeystoneAuth, err error) {
 	resp, err := http.Post(url, "application/json", strings.NewReader(body))
 	if err != nil {
 		zap.S().Debugf("Error calling keystone API:%s\n", err.Error())
@@ -177,14 +213,30 @@ func (k KeystoneImpl) GetAuth(
 	project := t["project"].(map[string]interface{})
 	user := t["user"].(map[string]interface{})
 	token := resp.Header["X-Subject-Token"][0]
+	log.RegisterSecret(token)
+
+	var roles []string
+	if tokenRoles, ok := t["roles"].([]interface{}); ok {
+		for _, r := range tokenRoles {
+			if role, ok := r.(map[string]interface{}); ok {
+				if name, ok := role["name"].(string); ok {
+					roles = append(roles, name)
+				}
+			}
+		}
+	}
+
+	projectName, _ := project["name"].(string)
 
 	zap.S().Debugf("returning successfully\n")
 
 	return KeystoneAuth{
-		DUFqdn:    k.fqdn,
-		Token:     token,
-		UserID:    user["id"].(string),
-		ProjectID: project["id"].(string),
-		Email:     user["name"].(string),
+		DUFqdn:      k.fqdn,
+		Token:       token,
+		UserID:      user["id"].(string),
+		ProjectID:   project["id"].(string),
+		ProjectName: projectName,
+		Email:       user["name"].(string),
+		Roles:       roles,
 	}, nil
 }
@@ -160,3 +160,22 @@ func TestGetEndpointForRegion(t *testing.T) {
 	Ok(t, err)
 	Equals(t, region2_endpoint_expected, endpoint_actual)
 }
+
+// Tests the API to fetch the FQDN of all the regions.
+func TestGetRegionEndpoints(t *testing.T) {
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		Equals(t, req.URL.String(), "http://example.com?service_id=6d30c85c033247548d6d93b0056b266b")
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(endpointInfo)),
+			Header:     make(http.Header),
+		}
+	})
+
+	e_api := EndpointManagerAPI{client, "http://example.com", "token"}
+
+	regions, err := e_api.GetRegionEndpoints_API("6d30c85c033247548d6d93b0056b266b")
+	Ok(t, err)
+	Equals(t, map[string]string{"region1": region1_endpoint_expected, "region2": region2_endpoint_expected}, regions)
+	Equals(t, []string{"region1", "region2"}, RegionNames(regions))
+}
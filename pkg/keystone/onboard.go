@@ -0,0 +1,122 @@
+package keystone
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"go.uber.org/zap"
+)
+
+// MaxOnboardTokenTTL is the longest lifetime of an onboarding token, they are
+// meant to be handed out for a single visit to the site.
+const MaxOnboardTokenTTL = 7 * 24 * time.Hour
+
+// onboardTokenPrefix marks the onboarding tokens so they can be told apart
+// from keystone tokens.
+const onboardTokenPrefix = "pf9onb_"
+
+// OnboardToken holds everything prep-node needs to onboard a node: the DU and
+// region to onboard it to and a restricted application credential.
+type OnboardToken struct {
+	Fqdn      string    `json:"fqdn"`
+	Region    string    `json:"region"`
+	ID        string    `json:"id"`
+	Secret    string    `json:"secret"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Encode returns the token as a single string which can be passed on the
+// command line.
+func (t OnboardToken) Encode() (string, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return onboardTokenPrefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeOnboardToken parses a token returned by OnboardToken.Encode and checks
+// that it is complete and hasn't expired.
+func DecodeOnboardToken(encoded string) (OnboardToken, error) {
+	var t OnboardToken
+	encoded = strings.TrimSpace(encoded)
+	if !strings.HasPrefix(encoded, onboardTokenPrefix) {
+		return t, fmt.Errorf("invalid onboarding token, it should start with %s", onboardTokenPrefix)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(encoded, onboardTokenPrefix))
+	if err != nil {
+		return t, fmt.Errorf("invalid onboarding token: %w", err)
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, fmt.Errorf("invalid onboarding token: %w", err)
+	}
+	if t.Fqdn == "" || t.Region == "" || t.ID == "" || t.Secret == "" {
+		return t, fmt.Errorf("invalid onboarding token, it is incomplete")
+	}
+	log.RegisterSecret(t.Secret)
+	log.RegisterSecret(encoded)
+	if !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt) {
+		return t, fmt.Errorf("onboarding token expired at %s", t.ExpiresAt.Format(time.RFC3339))
+	}
+	return t, nil
+}
+
+// CreateOnboardToken creates an application credential of the user of auth
+// which expires after ttl. The credential is restricted, so it can't be used to
+// create other credentials or trusts, and limited to roles when given.
+func CreateOnboardToken(fqdn, region, name string, ttl time.Duration, roles []string, auth KeystoneAuth) (OnboardToken, error) {
+	zap.S().Debugf("Creating onboarding token %s for user %s, ttl: %s, roles: %v", name, auth.UserID, ttl, roles)
+
+	if ttl <= 0 || ttl > MaxOnboardTokenTTL {
+		return OnboardToken{}, fmt.Errorf("invalid ttl %s, it should be positive and at most %s", ttl, MaxOnboardTokenTTL)
+	}
+
+	expiresAt := time.Now().Add(ttl).UTC().Truncate(time.Second)
+	credential := map[string]interface{}{
+		"name":         name,
+		"description":  fmt.Sprintf("pf9ctl onboarding token for region %s", region),
+		"expires_at":   expiresAt.Format("2006-01-02T15:04:05"),
+		"unrestricted": false,
+	}
+	if len(roles) > 0 {
+		var roleRefs []map[string]string
+		for _, role := range roles {
+			roleRefs = append(roleRefs, map[string]string{"name": role})
+		}
+		credential["roles"] = roleRefs
+	}
+	var payload struct {
+		ApplicationCredential struct {
+			ID     string `json:"id"`
+			Secret string `json:"secret"`
+		} `json:"application_credential"`
+	}
+	url := fmt.Sprintf("%s/keystone/v3/users/%s/application_credentials", fqdn, auth.UserID)
+	if err := keystoneRequest("POST", url, auth, map[string]interface{}{"application_credential": credential}, &payload, http.StatusCreated); err != nil {
+		return OnboardToken{}, fmt.Errorf("unable to create application credential: %w", err)
+	}
+	log.RegisterSecret(payload.ApplicationCredential.Secret)
+
+	return OnboardToken{
+		Fqdn:      fqdn,
+		Region:    region,
+		ID:        payload.ApplicationCredential.ID,
+		Secret:    payload.ApplicationCredential.Secret,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Authenticate fetches a token with the onboarding credential of cfg when it
+// has one, and with the username and password otherwise.
+func Authenticate(k Keystone, cfg objects.Config) (KeystoneAuth, error) {
+	if cfg.ApplicationCredentialID != "" {
+		return k.GetAuthWithApplicationCredential(cfg.ApplicationCredentialID, cfg.ApplicationCredentialSecret)
+	}
+	return k.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+}
@@ -0,0 +1,79 @@
+package keystone
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnboardTokenEncoding(t *testing.T) {
+	token := OnboardToken{
+		Fqdn:      "https://example.platform9.io",
+		Region:    "RegionOne",
+		ID:        "cred-id",
+		Secret:    "cred-secret",
+		ExpiresAt: time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+	}
+	encoded, err := token.Encode()
+	assert.Nil(t, err)
+
+	decoded, err := DecodeOnboardToken(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, token, decoded)
+
+	cases := map[string]struct {
+		encoded string
+		err     string
+	}{
+		"NoPrefix":   {encoded: "abc", err: "invalid onboarding token, it should start with pf9onb_"},
+		"Incomplete": {encoded: mustEncode(t, OnboardToken{Fqdn: "https://example.platform9.io"}), err: "invalid onboarding token, it is incomplete"},
+		"Expired": {
+			encoded: mustEncode(t, OnboardToken{Fqdn: "f", Region: "r", ID: "i", Secret: "secret", ExpiresAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}),
+			err:     "onboarding token expired at 2020-01-01T00:00:00Z",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeOnboardToken(tc.encoded)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestCreateOnboardToken(t *testing.T) {
+	var request map[string]map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/keystone/v3/users/user-1/application_credentials", r.URL.Path)
+		assert.Equal(t, "token", r.Header.Get("X-Auth-Token"))
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&request))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"application_credential": {"id": "cred-id", "secret": "cred-secret"}}`))
+	}))
+	defer server.Close()
+
+	auth := KeystoneAuth{Token: "token", UserID: "user-1"}
+	token, err := CreateOnboardToken(server.URL, "RegionOne", "onboard", 2*time.Hour, []string{"_member_"}, auth)
+	assert.Nil(t, err)
+	assert.Equal(t, "cred-id", token.ID)
+	assert.Equal(t, "cred-secret", token.Secret)
+	assert.Equal(t, server.URL, token.Fqdn)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), token.ExpiresAt, time.Minute)
+
+	credential := request["application_credential"]
+	assert.Equal(t, "onboard", credential["name"])
+	assert.Equal(t, false, credential["unrestricted"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "_member_"}}, credential["roles"])
+
+	_, err = CreateOnboardToken(server.URL, "RegionOne", "onboard", 30*24*time.Hour, nil, auth)
+	assert.EqualError(t, err, "invalid ttl 720h0m0s, it should be positive and at most 168h0m0s")
+}
+
+func mustEncode(t *testing.T, token OnboardToken) string {
+	encoded, err := token.Encode()
+	assert.Nil(t, err)
+	return encoded
+}
@@ -1,25 +1,131 @@
 package keystone
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
 
+	"github.com/platform9/pf9ctl/pkg/statefile"
+	"github.com/platform9/pf9ctl/pkg/util"
 	"go.uber.org/zap"
 )
 
+// RegionCacheTTL is how long the region endpoints fetched from keystone are reused
+var RegionCacheTTL = 24 * time.Hour
+
+// RegionNotFoundError is returned when the region is not known to the DU
+type RegionNotFoundError struct {
+	Region     string
+	Suggestion string
+}
+
+func (e *RegionNotFoundError) Error() string {
+	if e.Suggestion != "" {
+		return fmt.Sprintf("region %s not found, did you mean %s?", e.Region, e.Suggestion)
+	}
+	return fmt.Sprintf("region %s not found", e.Region)
+}
+
+// regionCacheEntry holds the region endpoints of a single DU
+type regionCacheEntry struct {
+	Regions   map[string]string `json:"regions"`
+	FetchedAt time.Time         `json:"fetchedAt"`
+}
+
+// FetchRegionFQDN returns the FQDN of the region, using the cached service
+// catalog when it is fresh enough.
 func FetchRegionFQDN(fqdn string, region string, auth KeystoneAuth) (string, error) {
 
+	if regions, ok := loadRegionCache(fqdn); ok {
+		if endpointURL, found := regions[region]; found {
+			zap.S().Debug("endpointURL found in cache : ", endpointURL)
+			return endpointURL, nil
+		}
+	}
+
+	// The region may have been added since the cache was filled, so refetch
+	// before giving up on it.
+	regions, err := FetchRegions(fqdn, auth)
+	if err != nil {
+		return "", fmt.Errorf("Failed to fetch installer URL, Error: %s", err)
+	}
+
+	endpointURL, found := regions[region]
+	if !found {
+		return "", &RegionNotFoundError{Region: region, Suggestion: util.ClosestMatch(region, RegionNames(regions))}
+	}
+	zap.S().Debug("endpointURL fetched : ", endpointURL)
+	return endpointURL, nil
+}
+
+// FetchRegions returns the FQDN of every region of the DU and refreshes the cache.
+func FetchRegions(fqdn string, auth KeystoneAuth) (map[string]string, error) {
+
 	// "regionInfo" service will have endpoint information. So fetch it's service ID.
 	regionInfoServiceID, err := GetServiceID(fqdn, auth, "regionInfo")
 	if err != nil {
-		return "", fmt.Errorf("Failed to fetch installer URL, Error: %s", err)
+		return nil, err
 	}
 	zap.S().Debug("Service ID fetched : ", regionInfoServiceID)
 
-	// Fetch the endpoint based on region name.
-	endpointURL, err := GetEndpointForRegion(fqdn, auth, region, regionInfoServiceID)
+	regions, err := GetRegionEndpoints(fqdn, auth, regionInfoServiceID)
 	if err != nil {
-		return "", fmt.Errorf("Failed to fetch installer URL, Error: %s", err)
+		return nil, err
 	}
-	zap.S().Debug("endpointURL fetched : ", endpointURL)
-	return endpointURL, nil
+
+	if err := storeRegionCache(fqdn, regions); err != nil {
+		zap.S().Debugf("Unable to store region cache: %s", err)
+	}
+	return regions, nil
+}
+
+// RegionNames returns the sorted names of the regions
+func RegionNames(regions map[string]string) []string {
+	names := make([]string, 0, len(regions))
+	for name := range regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readRegionCache() map[string]regionCacheEntry {
+	cache := make(map[string]regionCacheEntry)
+	data, err := ioutil.ReadFile(util.Pf9RegionCacheLoc)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		zap.S().Debugf("Ignoring invalid region cache: %s", err)
+		return make(map[string]regionCacheEntry)
+	}
+	return cache
+}
+
+func loadRegionCache(fqdn string) (map[string]string, bool) {
+	entry, found := readRegionCache()[fqdn]
+	if !found || time.Since(entry.FetchedAt) > RegionCacheTTL {
+		return nil, false
+	}
+	return entry.Regions, true
+}
+
+func storeRegionCache(fqdn string, regions map[string]string) error {
+	// The regions fetched by another pf9ctl at the same time are kept
+	lock, err := statefile.LockFile(util.Pf9RegionCacheLoc, time.Second)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	cache := readRegionCache()
+	cache[fqdn] = regionCacheEntry{Regions: regions, FetchedAt: time.Now()}
+
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return statefile.WriteFile(util.Pf9RegionCacheLoc, data, os.FileMode(0600))
 }
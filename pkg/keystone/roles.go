@@ -0,0 +1,75 @@
+package keystone
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RoleAdmin is the role needed to authorize hosts and to manage users
+const RoleAdmin = "admin"
+
+// operationRoles are the roles the operations of pf9ctl need on the tenant,
+// the other operations only need a role on it. Changing the clusters and
+// their nodes is only allowed to the administrators, the self-service users
+// with the _member_ role get 403s from qbert and resmgr.
+var operationRoles = map[string]string{
+	"authorize-node":       RoleAdmin,
+	"deauthorize-node":     RoleAdmin,
+	"decommission-node":    RoleAdmin,
+	"prep-node":            RoleAdmin,
+	"import-node":          RoleAdmin,
+	"replace-node":         RoleAdmin,
+	"migrate-node":         RoleAdmin,
+	"attach-node":          RoleAdmin,
+	"detach-node":          RoleAdmin,
+	"node maintenance":     RoleAdmin,
+	"bootstrap":            RoleAdmin,
+	"create-cluster":       RoleAdmin,
+	"delete-cluster":       RoleAdmin,
+	"decommission-cluster": RoleAdmin,
+	"create-user":          RoleAdmin,
+	"assign-role":          RoleAdmin,
+	"certs rotate":         RoleAdmin,
+}
+
+// MissingRoleError is returned for an operation the user doesn't have the
+// role of on the tenant
+type MissingRoleError struct {
+	Operation string
+	Role      string
+	User      string
+	Tenant    string
+	// Roles are the roles the user has on the tenant
+	Roles []string
+}
+
+func (e *MissingRoleError) Error() string {
+	tenant := "the tenant"
+	if e.Tenant != "" {
+		tenant = "tenant " + e.Tenant
+	}
+	return fmt.Sprintf("%s requires the %s role on %s; user %s has %s. "+
+		"Ask an administrator of the DU to grant it or use the config of a user having it",
+		e.Operation, e.Role, tenant, e.User, strings.Join(e.Roles, ", "))
+}
+
+// HasRole reports whether the token of auth has role
+func (a KeystoneAuth) HasRole(role string) bool {
+	for _, r := range a.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckOperationRole fails when the user lacks the role needed by operation,
+// so it can be checked before any change is made instead of failing midway
+// with a 403. Tokens without roles, like the ones of older DUs, are trusted.
+func CheckOperationRole(auth KeystoneAuth, operation string) error {
+	role, ok := operationRoles[operation]
+	if !ok || len(auth.Roles) == 0 || auth.HasRole(role) {
+		return nil
+	}
+	return &MissingRoleError{Operation: operation, Role: role, User: auth.Email, Tenant: auth.ProjectName, Roles: auth.Roles}
+}
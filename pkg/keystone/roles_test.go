@@ -0,0 +1,50 @@
+package keystone
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckOperationRole(t *testing.T) {
+	cases := map[string]struct {
+		roles     []string
+		operation string
+		err       string
+	}{
+		"Admin":        {roles: []string{"_member_", "admin"}, operation: "authorize-node"},
+		"MissingAdmin": {roles: []string{"_member_"}, operation: "attach-node", err: "attach-node requires the admin role on tenant service; user jdoe@example.com has _member_. Ask an administrator of the DU to grant it or use the config of a user having it"},
+		"AnyRole":      {roles: []string{"_member_"}, operation: "describe-cluster"},
+		"UnknownRoles": {operation: "prep-node"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			auth := KeystoneAuth{Email: "jdoe@example.com", ProjectName: "service", Roles: tc.roles}
+			err := CheckOperationRole(auth, tc.operation)
+			if tc.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestTokenRoles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Subject-Token", "token")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"token": {"project": {"id": "p1", "name": "service"}, "user": {"id": "u1", "name": "jdoe@example.com"},
+			"roles": [{"id": "r1", "name": "_member_"}, {"id": "r2", "name": "admin"}]}}`))
+	}))
+	defer server.Close()
+
+	auth, err := NewKeystone(server.URL).GetAuth("jdoe@example.com", "secret", "service", "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"_member_", "admin"}, auth.Roles)
+	assert.True(t, auth.HasRole(RoleAdmin))
+	assert.Equal(t, "service", auth.ProjectName)
+}
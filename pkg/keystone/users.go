@@ -0,0 +1,165 @@
+package keystone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"go.uber.org/zap"
+)
+
+// SelfServiceRole is the role of the users which can only manage the
+// resources of their tenants
+const SelfServiceRole = "_member_"
+
+// User is a keystone user
+type User struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	Email            string `json:"email,omitempty"`
+	Enabled          bool   `json:"enabled"`
+	DefaultProjectID string `json:"default_project_id,omitempty"`
+}
+
+// Role is a keystone role
+type Role struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Project is a keystone project, which is shown as a tenant by Platform9
+type Project struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// keystoneRequest sends a request to the keystone API and decodes the response
+// into out, when it isn't nil.
+func keystoneRequest(method, url string, auth KeystoneAuth, in, out interface{}, status int) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Auth-Token", auth.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to call keystone: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		return fmt.Errorf("keystone returned status %d for %s %s", resp.StatusCode, method, req.URL.Path)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("unable to decode keystone response: %w", err)
+	}
+	return nil
+}
+
+// ListUsers returns the users of the default domain
+func ListUsers(fqdn string, auth KeystoneAuth) ([]User, error) {
+	var payload struct {
+		Users []User `json:"users"`
+	}
+	err := keystoneRequest("GET", fmt.Sprintf("%s/keystone/v3/users?domain_id=default", fqdn), auth, nil, &payload, http.StatusOK)
+	return payload.Users, err
+}
+
+// FindUser returns the user with the name or ID nameOrID
+func FindUser(fqdn string, auth KeystoneAuth, nameOrID string) (User, error) {
+	users, err := ListUsers(fqdn, auth)
+	if err != nil {
+		return User{}, err
+	}
+	for _, user := range users {
+		if user.ID == nameOrID || user.Name == nameOrID {
+			return user, nil
+		}
+	}
+	return User{}, fmt.Errorf("user %s not found", nameOrID)
+}
+
+// CreateUser creates a user in the default domain with projectID as its
+// default project.
+func CreateUser(fqdn string, auth KeystoneAuth, name, password, email, projectID string) (User, error) {
+	zap.S().Debugf("Creating user %s in project %s", name, projectID)
+	log.RegisterSecret(password)
+
+	user := map[string]interface{}{
+		"name":               name,
+		"password":           password,
+		"domain_id":          "default",
+		"enabled":            true,
+		"default_project_id": projectID,
+	}
+	if email != "" {
+		user["email"] = email
+	}
+	var payload struct {
+		User User `json:"user"`
+	}
+	err := keystoneRequest("POST", fmt.Sprintf("%s/keystone/v3/users", fqdn), auth,
+		map[string]interface{}{"user": user}, &payload, http.StatusCreated)
+	return payload.User, err
+}
+
+// FindRole returns the role named name
+func FindRole(fqdn string, auth KeystoneAuth, name string) (Role, error) {
+	var payload struct {
+		Roles []Role `json:"roles"`
+	}
+	err := keystoneRequest("GET", fmt.Sprintf("%s/keystone/v3/roles?name=%s", fqdn, url.QueryEscape(name)), auth, nil, &payload, http.StatusOK)
+	if err != nil {
+		return Role{}, err
+	}
+	if len(payload.Roles) == 0 {
+		return Role{}, fmt.Errorf("role %s not found", name)
+	}
+	return payload.Roles[0], nil
+}
+
+// FindProject returns the project with the name or ID nameOrID, like the
+// tenant of the config
+func FindProject(fqdn string, auth KeystoneAuth, nameOrID string) (Project, error) {
+	var payload struct {
+		Project  Project   `json:"project"`
+		Projects []Project `json:"projects"`
+	}
+	if _, err := uuid.Parse(nameOrID); err == nil {
+		err := keystoneRequest("GET", fmt.Sprintf("%s/keystone/v3/projects/%s", fqdn, nameOrID), auth, nil, &payload, http.StatusOK)
+		return payload.Project, err
+	}
+
+	err := keystoneRequest("GET", fmt.Sprintf("%s/keystone/v3/projects?domain_id=default&name=%s", fqdn, url.QueryEscape(nameOrID)), auth, nil, &payload, http.StatusOK)
+	if err != nil {
+		return Project{}, err
+	}
+	if len(payload.Projects) == 0 {
+		return Project{}, fmt.Errorf("tenant %s not found", nameOrID)
+	}
+	return payload.Projects[0], nil
+}
+
+// AssignRole grants the role to the user on the project
+func AssignRole(fqdn string, auth KeystoneAuth, projectID, userID, roleID string) error {
+	zap.S().Debugf("Assigning role %s to user %s on project %s", roleID, userID, projectID)
+	return keystoneRequest("PUT", fmt.Sprintf("%s/keystone/v3/projects/%s/users/%s/roles/%s", fqdn, projectID, userID, roleID),
+		auth, nil, nil, http.StatusNoContent)
+}
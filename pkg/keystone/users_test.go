@@ -0,0 +1,72 @@
+package keystone
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsersAndRoles(t *testing.T) {
+	var created map[string]map[string]interface{}
+	var assigned string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("X-Auth-Token") != "token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Method == "GET" && r.URL.Path == "/keystone/v3/users":
+			w.Write([]byte(`{"users": [{"id": "u1", "name": "admin@example.com", "enabled": true}, {"id": "u2", "name": "ci-bot", "enabled": false}]}`))
+		case r.Method == "POST" && r.URL.Path == "/keystone/v3/users":
+			assert.Nil(t, json.NewDecoder(r.Body).Decode(&created))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"user": {"id": "u3", "name": "new-user", "enabled": true}}`))
+		case r.URL.Path == "/keystone/v3/roles":
+			if r.URL.Query().Get("name") == "_member_" {
+				w.Write([]byte(`{"roles": [{"id": "r1", "name": "_member_"}]}`))
+			} else {
+				w.Write([]byte(`{"roles": []}`))
+			}
+		case r.URL.Path == "/keystone/v3/projects":
+			assert.Equal(t, "service", r.URL.Query().Get("name"))
+			w.Write([]byte(`{"projects": [{"id": "p1", "name": "service"}]}`))
+		case r.Method == "PUT":
+			assigned = r.URL.Path
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	auth := KeystoneAuth{Token: "token"}
+
+	user, err := FindUser(server.URL, auth, "ci-bot")
+	assert.Nil(t, err)
+	assert.Equal(t, User{ID: "u2", Name: "ci-bot"}, user)
+	_, err = FindUser(server.URL, auth, "nobody")
+	assert.EqualError(t, err, "user nobody not found")
+
+	user, err = CreateUser(server.URL, auth, "new-user", "s3cr3t-pass", "", "p1")
+	assert.Nil(t, err)
+	assert.Equal(t, "u3", user.ID)
+	assert.Equal(t, "p1", created["user"]["default_project_id"])
+	assert.Equal(t, "default", created["user"]["domain_id"])
+	assert.NotContains(t, created["user"], "email")
+
+	role, err := FindRole(server.URL, auth, SelfServiceRole)
+	assert.Nil(t, err)
+	assert.Equal(t, "r1", role.ID)
+	_, err = FindRole(server.URL, auth, "reader")
+	assert.EqualError(t, err, "role reader not found")
+
+	project, err := FindProject(server.URL, auth, "service")
+	assert.Nil(t, err)
+	assert.Equal(t, "p1", project.ID)
+
+	assert.Nil(t, AssignRole(server.URL, auth, "p1", "u3", "r1"))
+	assert.Equal(t, "/keystone/v3/projects/p1/users/u3/roles/r1", assigned)
+
+	err = AssignRole(server.URL, KeystoneAuth{Token: "expired"}, "p1", "u3", "r1")
+	assert.EqualError(t, err, "keystone returned status 401 for PUT /keystone/v3/projects/p1/users/u3/roles/r1")
+}
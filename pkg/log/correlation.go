@@ -0,0 +1,56 @@
+package log
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// CorrelationIDHeader is the header the correlation ID is sent to the DU with
+const CorrelationIDHeader = "X-Correlation-Id"
+
+// CorrelationIDEnv is the environment variable the correlation ID is passed to
+// the installer with. pf9ctl reuses it when it is set, so that the commands run
+// by a script can share an ID.
+const CorrelationIDEnv = "PF9CTL_CORRELATION_ID"
+
+// CorrelationID identifies the operation run by this invocation of pf9ctl, in
+// its own logs, in the requests sent to the DU and on the nodes
+var CorrelationID = newCorrelationID()
+
+func newCorrelationID() string {
+	if id := os.Getenv(CorrelationIDEnv); id != "" {
+		return id
+	}
+	return uuid.New().String()
+}
+
+// correlationField adds the correlation ID to the entries of the log files
+func correlationField() []zapcore.Field {
+	return []zapcore.Field{zap.String("correlation_id", CorrelationID)}
+}
+
+// CorrelatingTransport sends the correlation ID with the requests sent
+// through next
+type CorrelatingTransport struct {
+	next http.RoundTripper
+}
+
+// NewCorrelatingTransport returns a transport adding the correlation ID header
+// to the requests sent through next
+func NewCorrelatingTransport(next http.RoundTripper) *CorrelatingTransport {
+	return &CorrelatingTransport{next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *CorrelatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get(CorrelationIDHeader) == "" {
+		// The request of the caller must not be modified
+		req = req.Clone(req.Context())
+		req.Header.Set(CorrelationIDHeader, CorrelationID)
+	}
+	return t.next.RoundTrip(req)
+}
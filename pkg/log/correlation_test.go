@@ -0,0 +1,33 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorrelatingTransport(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(CorrelationIDHeader))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: NewCorrelatingTransport(http.DefaultTransport)}
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	assert.NoError(t, err)
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, req.Header.Get(CorrelationIDHeader))
+
+	req.Header.Set(CorrelationIDHeader, "set-by-caller")
+	resp, err = client.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, []string{CorrelationID, "set-by-caller"}, received)
+	assert.NotEmpty(t, CorrelationID)
+}
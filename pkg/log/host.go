@@ -0,0 +1,127 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// PerHostLogs writes the lines logged for a host to a log of its own as
+// well, in a directory named after the host under the log directory
+var PerHostLogs bool
+
+// hostCores are the cores writing the logs of the hosts, by host
+var hostCores = struct {
+	sync.Mutex
+	cores map[string]zapcore.Core
+}{cores: make(map[string]zapcore.Core)}
+
+// scoped is the host of the global logger, see ScopeHost
+var scoped struct {
+	sync.Mutex
+	host string
+}
+
+// ForHost returns the logger of the operations run on host. Its lines are
+// prefixed with the host so that the output of hosts handled concurrently
+// can be told apart.
+func ForHost(host string) *zap.SugaredLogger {
+	scoped.Lock()
+	global := scoped.host == host
+	scoped.Unlock()
+	if global {
+		return zap.S()
+	}
+	return zap.L().WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if PerHostLogs {
+			if hostCore, err := hostFileCore(host); err != nil {
+				zap.S().Debugf("Unable to open the log of host %s: %s", host, err)
+			} else {
+				core = zapcore.NewTee(core, hostCore)
+			}
+		}
+		return hostPrefixCore{Core: core, prefix: fmt.Sprintf("[%s] ", host)}
+	})).Sugar()
+}
+
+// ScopeHost makes the global logger the one of host until restore is called.
+// The operations on a node log through the global logger, so the lines of the
+// nodes of a batch, handled one after the other, are told apart. The nodes
+// handled concurrently log through ForHost.
+func ScopeHost(host string) (restore func()) {
+	logger := ForHost(host).Desugar()
+	scoped.Lock()
+	previous := scoped.host
+	scoped.host = host
+	scoped.Unlock()
+	undo := zap.ReplaceGlobals(logger)
+	return func() {
+		undo()
+		scoped.Lock()
+		scoped.host = previous
+		scoped.Unlock()
+	}
+}
+
+// HostLogDir returns the directory of the logs of host
+func HostLogDir(host string) string {
+	return filepath.Join(filepath.Dir(util.LogFileNamePath), hostDirName(host))
+}
+
+var unsafeHostChars = regexp.MustCompile(`[^A-Za-z0-9._:-]`)
+
+func hostDirName(host string) string {
+	name := unsafeHostChars.ReplaceAllString(host, "_")
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// hostFileCore returns the core writing the JSON log of host, rotated daily
+// like the main log
+func hostFileCore(host string) (zapcore.Core, error) {
+	if util.LogFileNamePath == "" {
+		return nil, fmt.Errorf("the log is not configured")
+	}
+	hostCores.Lock()
+	defer hostCores.Unlock()
+	if core, ok := hostCores.cores[host]; ok {
+		return core, nil
+	}
+
+	dir := HostLogDir(host)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	logFile := GetLogLocation(filepath.Join(dir, filepath.Base(util.LogFileNamePath)))
+	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	core := NewRedactingCore(zapcore.NewCore(zapcore.NewJSONEncoder(fileConfig()), zapcore.Lock(f), zap.DebugLevel).With(correlationField()))
+	hostCores.cores[host] = core
+	return core, nil
+}
+
+// hostPrefixCore prefixes the message of the entries with the host before
+// the wrapped core checks and writes them
+type hostPrefixCore struct {
+	zapcore.Core
+	prefix string
+}
+
+func (c hostPrefixCore) With(fields []zapcore.Field) zapcore.Core {
+	return hostPrefixCore{Core: c.Core.With(fields), prefix: c.prefix}
+}
+
+func (c hostPrefixCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	ent.Message = c.prefix + ent.Message
+	return c.Core.Check(ent, ce)
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestForHost(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	dir, err := ioutil.TempDir("", "pf9ctl-log")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(path string) { util.LogFileNamePath = path }(util.LogFileNamePath)
+	util.LogFileNamePath = filepath.Join(dir, "pf9ctl.log")
+
+	ForHost("10.0.0.1").Infof("Running command %s", "uptime")
+	ForHost("10.0.0.1").Debug("Not enabled")
+
+	PerHostLogs = true
+	defer func() { PerHostLogs = false }()
+	ForHost("10.0.0.2").Info("Running command hostname")
+
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"[10.0.0.1] Running command uptime", "[10.0.0.2] Running command hostname"}, messages)
+
+	assert.NoFileExists(t, GetLogLocation(filepath.Join(dir, "10.0.0.1", "pf9ctl.log")))
+	data, err := ioutil.ReadFile(GetLogLocation(filepath.Join(dir, "10.0.0.2", "pf9ctl.log")))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"[10.0.0.2] Running command hostname"`)
+}
+
+func TestScopeHost(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	restore := ScopeHost("10.0.0.1")
+	zap.S().Info("Installing the hostagent")
+	ForHost("10.0.0.1").Info("Running command uptime")
+	restore()
+	zap.S().Info("Preparing the next node")
+
+	var messages []string
+	for _, entry := range logs.All() {
+		messages = append(messages, entry.Message)
+	}
+	assert.Equal(t, []string{"[10.0.0.1] Installing the hostagent", "[10.0.0.1] Running command uptime", "Preparing the next node"}, messages)
+}
+
+func TestHostDirName(t *testing.T) {
+	assert.Equal(t, "fe80::1", hostDirName("fe80::1"))
+	assert.Equal(t, "node-1.example.com", hostDirName("node-1.example.com"))
+	assert.Equal(t, ".._etc", hostDirName("../etc"))
+	assert.Equal(t, "_", hostDirName(".."))
+}
@@ -11,6 +11,14 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+// Quiet leaves the logs out of the console, they are only written to the log
+// file
+var Quiet bool
+
+// EntryHook is called with every entry logged, before the CLI exits on the
+// fatal ones
+var EntryHook func(zapcore.Entry) error
+
 // Returns the current log file location.
 func GetLogLocation(logFile string) string {
 	runLogLocation := fmt.Sprintf("%s-%s.%s", logFile[:strings.LastIndex(logFile, ".")], time.Now().Format("20060102"), logFile[strings.LastIndex(logFile, ".")+1:])
@@ -42,13 +50,19 @@ func ConfigureGlobalLog(debug bool, logFile string) error {
 	consoleLogs := zapcore.Lock(os.Stderr)
 	fileLogs := zapcore.Lock(f)
 
-	// Create custom zap config
-	core := zapcore.NewTee(
-		zapcore.NewCore(zapcore.NewConsoleEncoder(consoleConfig()), consoleLogs, lvl),
-		zapcore.NewCore(zapcore.NewJSONEncoder(fileConfig()), fileLogs, zap.DebugLevel),
-	)
+	// Create custom zap config, secrets are redacted from both the console and the file
+	cores := []zapcore.Core{
+		NewRedactingCore(zapcore.NewCore(zapcore.NewJSONEncoder(fileConfig()), fileLogs, zap.DebugLevel).With(correlationField())),
+	}
+	if !Quiet {
+		cores = append(cores, NewRedactingCore(zapcore.NewCore(zapcore.NewConsoleEncoder(consoleConfig()), consoleLogs, lvl)))
+	}
 
-	logger := zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
+	options := []zap.Option{zap.AddCaller(), zap.AddCallerSkip(1)}
+	if EntryHook != nil {
+		options = append(options, zap.Hooks(EntryHook))
+	}
+	logger := zap.New(zapcore.NewTee(cores...), options...)
 	zap.ReplaceGlobals(logger)
 
 	defer logger.Sync()
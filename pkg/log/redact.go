@@ -0,0 +1,106 @@
+package log
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Redacted replaces the secrets in the logs
+const Redacted = "*****"
+
+// secretPatterns match the secrets by the way they are passed around, the first
+// group is kept and what follows it is redacted.
+var secretPatterns = []*regexp.Regexp{
+	// Headers, either as written on the wire or as printed by http.Header
+	regexp.MustCompile(`(?i)((?:x-auth-token|x-subject-token)["']?\s*[:=]\s*["'\[]?)[^\s"',}\]]+`),
+	regexp.MustCompile(`(?i)(authorization["']?\s*[:=]\s*["'\[]?(?:bearer|basic)?\s*)[^\s"',}\]]+`),
+	// JSON bodies like the keystone auth request
+	regexp.MustCompile(`(?i)("(?:password|passcode|token|user_token|secret)"\s*:\s*")[^"]*`),
+	// Command line flags of pf9ctl and of the hostagent installer
+	regexp.MustCompile(`(?i)(--(?:password|user-token|sudo-pass|mfa|onboard-token)(?:=|\s+)["']?)[^\s"']+`),
+}
+
+var (
+	secretsLock sync.RWMutex
+	secrets     []string
+)
+
+// RegisterSecret makes every occurrence of secret in the logs redacted. It is
+// meant for values which can't be recognised by a pattern, like a password
+// interpolated into a command.
+func RegisterSecret(secret string) {
+	// Short values would redact unrelated parts of the logs
+	if len(secret) < 4 {
+		return
+	}
+	secretsLock.Lock()
+	defer secretsLock.Unlock()
+	for _, s := range secrets {
+		if s == secret {
+			return
+		}
+	}
+	secrets = append(secrets, secret)
+}
+
+// Redact removes the secrets from s
+func Redact(s string) string {
+	secretsLock.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	secretsLock.RUnlock()
+
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+Redacted)
+	}
+	return s
+}
+
+// redactingCore removes the secrets from the message and the string fields of
+// the entries before they are written by the wrapped core.
+type redactingCore struct {
+	zapcore.Core
+}
+
+// NewRedactingCore wraps core so that no secrets are written to the logs
+func NewRedactingCore(core zapcore.Core) zapcore.Core {
+	return redactingCore{core}
+}
+
+func (c redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactingCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c redactingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = Redact(ent.Message)
+	return c.Core.Write(ent, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = Redact(field.String)
+		case zapcore.ByteStringType:
+			field = zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: Redact(string(field.Interface.([]byte)))}
+		case zapcore.ErrorType:
+			if err, ok := field.Interface.(error); ok && err != nil {
+				field = zapcore.Field{Key: field.Key, Type: zapcore.StringType, String: Redact(err.Error())}
+			}
+		}
+		redacted[i] = field
+	}
+	return redacted
+}
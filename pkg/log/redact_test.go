@@ -0,0 +1,52 @@
+package log
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRedact(t *testing.T) {
+	RegisterSecret("hunter22")
+	RegisterSecret("abc")
+
+	cases := map[string]string{
+		"map[Content-Type:[application/json] X-Auth-Token:[gAAAAABh]]":     "map[Content-Type:[application/json] X-Auth-Token:[*****]]",
+		"X-Subject-Token: gAAAAABh":                                        "X-Subject-Token: *****",
+		"Authorization: Bearer eyJhbGci":                                   "Authorization: Bearer *****",
+		`{"password": "p@ss", "passcode": "123456", "name": "admin"}`:      `{"password": "*****", "passcode": "*****", "name": "admin"}`,
+		`installer.sh --username=admin --password='p@ss' --user-token=gAA`: `installer.sh --username=admin --password='*****' --user-token=*****`,
+		"pf9ctl config set --mfa 123456":                                   "pf9ctl config set --mfa *****",
+		"echo hunter22 | sudo -S true":                                     "echo ***** | sudo -S true",
+		// Too short to be registered
+		"abc def": "abc def",
+	}
+
+	for in, want := range cases {
+		assert.Equal(t, want, Redact(in))
+	}
+}
+
+func TestRedactingCore(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(NewRedactingCore(core)).With(zap.String("header", "X-Auth-Token: gAAAAABh"))
+
+	logger.Debug("X-Auth-Token: gAAAAABh")
+	logger.Sugar().Infof("Sending request with X-Auth-Token: %s", "gAAAAABh")
+	logger.Info("command failed", zap.Error(errors.New("--password=p@ss")), zap.ByteString("stdout", []byte(`"token": "gAAAAABh"`)))
+
+	entries := logs.AllUntimed()
+	// The level of the wrapped core is still honored
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "Sending request with X-Auth-Token: *****", entries[0].Message)
+	assert.Equal(t, map[string]interface{}{
+		"header": "X-Auth-Token: *****",
+		"error":  "--password=*****",
+		"stdout": `"token": "*****"`,
+	}, entries[1].ContextMap())
+	assert.Equal(t, zapcore.InfoLevel, entries[1].Level)
+}
@@ -0,0 +1,81 @@
+package log
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TraceAPI logs the requests sent to the Platform9 APIs and their responses to
+// the debug log
+var TraceAPI bool
+
+// requestIDHeaders are the headers the DU services identify the requests with,
+// the same IDs are found in the logs of the DU
+var requestIDHeaders = []string{
+	CorrelationIDHeader,
+	"X-Openstack-Request-Id",
+	"X-Compute-Request-Id",
+	"X-Request-Id",
+	"X-Trans-Id",
+}
+
+// TracingTransport logs the method, URL, status, latency and request IDs of
+// the requests sent through next when TraceAPI is set. Neither the headers nor
+// the bodies are logged, so the tokens and passwords they hold are not either.
+type TracingTransport struct {
+	next http.RoundTripper
+}
+
+// NewTracingTransport returns a transport tracing the requests sent through next
+func NewTracingTransport(next http.RoundTripper) *TracingTransport {
+	return &TracingTransport{next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !TraceAPI {
+		return t.next.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	latency := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		zap.S().Debugf("API %s %s failed after %s: %s", req.Method, traceURL(req), latency, err)
+		return resp, err
+	}
+	zap.S().Debugf("API %s %s %d in %s%s", req.Method, traceURL(req), resp.StatusCode, latency, requestIDs(req.Header, resp.Header))
+	return resp, err
+}
+
+// traceURL returns the URL of req without credentials, the secrets of the
+// query are redacted by the log
+func traceURL(req *http.Request) string {
+	u := *req.URL
+	u.User = nil
+	return u.String()
+}
+
+// requestIDs formats the request ID headers sent or received
+func requestIDs(headers ...http.Header) string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, header := range headers {
+		for _, name := range requestIDHeaders {
+			for _, id := range header.Values(name) {
+				if id == "" || seen[name+id] {
+					continue
+				}
+				seen[name+id] = true
+				ids = append(ids, fmt.Sprintf("%s=%s", name, id))
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(ids, ", ") + ")"
+}
@@ -0,0 +1,52 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTracingTransport(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Openstack-Request-Id", "req-1234")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: NewTracingTransport(http.DefaultTransport)}
+
+	send := func() {
+		req, err := http.NewRequest("GET", server.URL+"/qbert/v4/clusters?x=1", nil)
+		assert.NoError(t, err)
+		req.Header.Set("X-Auth-Token", "secret-token")
+		req.Header.Set("X-Request-Id", "pf9ctl-1")
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	send()
+	assert.Empty(t, logs.All())
+
+	TraceAPI = true
+	defer func() { TraceAPI = false }()
+	send()
+	entries := logs.All()
+	assert.Len(t, entries, 1)
+	assert.Regexp(t, "^API GET "+regexp.QuoteMeta(server.URL)+`/qbert/v4/clusters\?x=1 404 in \d+m?s \(X-Request-Id=pf9ctl-1, X-Openstack-Request-Id=req-1234\)$`, entries[0].Message)
+	assert.NotContains(t, entries[0].Message, "secret-token")
+}
+
+func TestRequestIDs(t *testing.T) {
+	assert.Equal(t, "", requestIDs(http.Header{}))
+	sent := http.Header{"X-Request-Id": {"a"}}
+	received := http.Header{"X-Request-Id": {"a"}, "X-Trans-Id": {"tx1"}}
+	assert.Equal(t, " (X-Request-Id=a, X-Trans-Id=tx1)", requestIDs(sent, received))
+}
@@ -24,6 +24,65 @@ type Config struct {
 	GooglePath         string        `json:"google_path"`
 	GoogleProjectName  string        `json:"google_project_name"`
 	GoogleServiceEmail string        `json:"google_service_email"`
+	// WorkDir is where the installer is downloaded on the nodes, $HOME/pf9
+	// when empty
+	WorkDir string `json:"work_dir"`
+	// DownloadLimit is the maximum rate the nodes download the installer at,
+	// like 10MB/s, unlimited when empty
+	DownloadLimit string `json:"download_limit,omitempty"`
+	// ReadOnly restricts pf9ctl to the commands which neither change the DU
+	// nor the nodes, for operators who should only inspect them
+	ReadOnly bool `json:"read_only,omitempty"`
+	// PhaseBudget is how many times their typical duration the phases of
+	// prep-node run before a warning, 3 when empty and never when 0
+	PhaseBudget string `json:"phase_budget,omitempty"`
+	// ProtectedHosts are the IPs, CIDRs and hostnames, comma separated, which
+	// prep-node and decommission-node refuse to run on
+	ProtectedHosts string `json:"protected_hosts,omitempty"`
+	// ProtectedMarkers are the files, comma separated, whose presence on a
+	// host makes prep-node and decommission-node refuse to run on it
+	ProtectedMarkers string `json:"protected_markers,omitempty"`
+	// HostKeyPolicy is how the SSH host keys of the nodes are verified,
+	// strict, tofu or insecure, tofu when empty
+	HostKeyPolicy string `json:"host_key_policy,omitempty"`
+	// KnownHostsFile is the known_hosts file the SSH host keys of the nodes
+	// are verified against, $HOME/.ssh/known_hosts when empty
+	KnownHostsFile string `json:"known_hosts_file,omitempty"`
+	// HostFingerprintsFile is a JSON inventory pinning the SSH host keys of
+	// the nodes to the fingerprints of its sshFingerprints
+	HostFingerprintsFile string `json:"host_fingerprints_file,omitempty"`
+	// FIPS restricts the connections to the algorithms approved by FIPS 140
+	// and requires the openssl of the nodes to be in FIPS mode, for the DUs
+	// requiring it
+	FIPS bool `json:"fips,omitempty"`
+	// HostagentVersion is the version of the hostagent the DU is asked to
+	// install on the nodes, the latest when empty
+	HostagentVersion string `json:"hostagent_version,omitempty"`
+	// InstallerArgs are appended to the command line of the installer, for
+	// the options pf9ctl has no flag for
+	InstallerArgs []string `json:"installer_args,omitempty"`
+	// PackageRepo is the URL of the repo built by 'pf9ctl mirror create' the
+	// nodes install the OS packages from, for disconnected installs
+	PackageRepo string `json:"package_repo,omitempty"`
+	// PackageRepoKey is the file of the public key of the package repo, on
+	// the machine running pf9ctl
+	PackageRepoKey string `json:"package_repo_key,omitempty"`
+	// SOCKSProxy is the SOCKS5 proxy the requests to the DU and the SSH
+	// connections to the nodes go through, like socks5://10.0.0.5:1080
+	SOCKSProxy string `json:"socks_proxy,omitempty"`
+	// ReportApproverKey is the public key, base64 encoded, of the approver of
+	// the preflight reports prep-node --verify-report accepts
+	ReportApproverKey string `json:"report_approver_key,omitempty"`
+	// Relay downloads the installer on the machine running pf9ctl and copies
+	// it to the nodes, for nodes without internet access
+	Relay bool `json:"-"`
+	// SkipSignatureVerify installs the installers without verifying their
+	// signature, it is only given on the command line
+	SkipSignatureVerify bool `json:"-"`
+	// The onboarding credential is only used for the current command and is
+	// never stored in the config
+	ApplicationCredentialID     string `json:"-"`
+	ApplicationCredentialSecret string `json:"-"`
 }
 
 type NodeConfig struct {
@@ -34,4 +93,7 @@ type NodeConfig struct {
 	MFA                string
 	SudoPassword       string
 	RemoveExistingPkgs bool
+	// NoPrompt fails instead of asking, e.g. whether to remove the existing
+	// installation without RemoveExistingPkgs
+	NoPrompt bool
 }
@@ -0,0 +1,422 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a template of the JSONPath syntax of kubectl: text with
+// {expressions} like {.items[*].name}, {"\n"} and
+// {range .items[*]}{.name}{"\t"}{.uuid}{"\n"}{end}
+type jsonPath struct {
+	nodes []jsonPathNode
+}
+
+// jsonPathNode is literal text, a path or a range over a path
+type jsonPathNode struct {
+	text    string
+	path    *path
+	isRange bool
+	body    []jsonPathNode
+}
+
+// path selects values from the root of the document or from the current
+// value of a range
+type path struct {
+	root  bool
+	steps []step
+}
+
+// step is a field, an index, a wildcard or a filter of a path
+type step struct {
+	field    string
+	index    *int
+	wildcard bool
+	filter   *filter
+}
+
+// filter keeps the elements of a list whose value at path, relative to the
+// element, is equal or not to value, or exists when op is empty
+type filter struct {
+	path  path
+	op    string
+	value interface{}
+}
+
+// parseJSONPath parses a JSONPath template
+func parseJSONPath(template string) (*jsonPath, error) {
+	var stack [][]jsonPathNode
+	var ranges []*path
+	var nodes []jsonPathNode
+	for rest := template; rest != ""; {
+		open := strings.Index(rest, "{")
+		if open < 0 {
+			nodes = append(nodes, jsonPathNode{text: rest})
+			break
+		}
+		if open > 0 {
+			nodes = append(nodes, jsonPathNode{text: rest[:open]})
+		}
+		end := closingBrace(rest[open:])
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed { in %q", template)
+		}
+		expr := strings.TrimSpace(rest[open+1 : open+end])
+		rest = rest[open+end+1:]
+
+		switch {
+		case expr == "end":
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("{end} without {range} in %q", template)
+			}
+			body := nodes
+			nodes = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			nodes = append(nodes, jsonPathNode{isRange: true, path: ranges[len(ranges)-1], body: body})
+			ranges = ranges[:len(ranges)-1]
+		case strings.HasPrefix(expr, "range "):
+			p, err := parsePath(strings.TrimSpace(strings.TrimPrefix(expr, "range ")))
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, nodes)
+			ranges = append(ranges, p)
+			nodes = nil
+		case strings.HasPrefix(expr, `"`):
+			text, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s: %w", expr, err)
+			}
+			nodes = append(nodes, jsonPathNode{text: text})
+		default:
+			p, err := parsePath(expr)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, jsonPathNode{path: p})
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("{range} without {end} in %q", template)
+	}
+	return &jsonPath{nodes: nodes}, nil
+}
+
+// closingBrace returns the index of the } closing the { s starts with,
+// ignoring the braces in quotes, or -1
+func closingBrace(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0 && s[i] == '\\':
+			i++
+		case quote != 0 && s[i] == quote:
+			quote = 0
+		case quote != 0:
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '}':
+			return i
+		}
+	}
+	return -1
+}
+
+// parsePath parses a path like .items[0].name, $.items[*] or
+// .items[?(@.name=="edge")].uuid
+func parsePath(expr string) (*path, error) {
+	p := &path{}
+	rest := expr
+	switch {
+	case strings.HasPrefix(rest, "$"):
+		p.root = true
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "@"):
+		rest = rest[1:]
+	}
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			if strings.HasPrefix(rest, "*") {
+				p.steps = append(p.steps, step{wildcard: true})
+				rest = rest[1:]
+				continue
+			}
+			n := 0
+			for n < len(rest) && rest[n] != '.' && rest[n] != '[' {
+				n++
+			}
+			if n == 0 {
+				// A lone . is the current value
+				if rest == "" {
+					continue
+				}
+				return nil, fmt.Errorf("invalid path %q: missing field name", expr)
+			}
+			p.steps = append(p.steps, step{field: rest[:n]})
+			rest = rest[n:]
+		case '[':
+			end := closingBracket(rest)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed [", expr)
+			}
+			s, err := parseSubscript(strings.TrimSpace(rest[1:end]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %w", expr, err)
+			}
+			p.steps = append(p.steps, s)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid path %q: it should start with . or [", expr)
+		}
+	}
+	return p, nil
+}
+
+// closingBracket returns the index of the ] closing the [ s starts with,
+// ignoring the brackets in quotes, or -1
+func closingBracket(s string) int {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0 && s[i] == '\\':
+			i++
+		case quote != 0 && s[i] == quote:
+			quote = 0
+		case quote != 0:
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '[':
+			depth++
+		case s[i] == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseSubscript parses what is between the brackets of a path
+func parseSubscript(sub string) (step, error) {
+	switch {
+	case sub == "*":
+		return step{wildcard: true}, nil
+	case strings.HasPrefix(sub, "?(") && strings.HasSuffix(sub, ")"):
+		f, err := parseFilter(strings.TrimSpace(sub[2 : len(sub)-1]))
+		if err != nil {
+			return step{}, err
+		}
+		return step{filter: f}, nil
+	case strings.HasPrefix(sub, "'") || strings.HasPrefix(sub, `"`):
+		name, err := unquote(sub)
+		if err != nil {
+			return step{}, err
+		}
+		return step{field: name}, nil
+	}
+	i, err := strconv.Atoi(sub)
+	if err != nil {
+		return step{}, fmt.Errorf("invalid subscript [%s]", sub)
+	}
+	return step{index: &i}, nil
+}
+
+// parseFilter parses a filter like @.name=="edge", @.enabled==true or
+// @.email
+func parseFilter(expr string) (*filter, error) {
+	if !strings.HasPrefix(expr, "@") {
+		return nil, fmt.Errorf("invalid filter %q, it should start with @", expr)
+	}
+	f := &filter{}
+	left := expr
+	for _, op := range []string{"==", "!="} {
+		if i := strings.Index(expr, op); i >= 0 {
+			f.op = op
+			left = strings.TrimSpace(expr[:i])
+			right := strings.TrimSpace(expr[i+len(op):])
+			if strings.HasPrefix(right, "'") {
+				s, err := unquote(right)
+				if err != nil {
+					return nil, err
+				}
+				f.value = s
+			} else if err := json.Unmarshal([]byte(right), &f.value); err != nil {
+				return nil, fmt.Errorf("invalid value %s in filter %q", right, expr)
+			}
+			break
+		}
+	}
+	p, err := parsePath(left)
+	if err != nil {
+		return nil, err
+	}
+	f.path = *p
+	return f, nil
+}
+
+// unquote removes the single or double quotes of s
+func unquote(s string) (string, error) {
+	if strings.HasPrefix(s, "'") {
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+		return strings.Replace(s[1:len(s)-1], `\'`, `'`, -1), nil
+	}
+	return strconv.Unquote(s)
+}
+
+// execute writes the template applied to data, a document decoded from JSON
+func (j *jsonPath) execute(w io.Writer, data interface{}) error {
+	return executeNodes(w, j.nodes, data, data)
+}
+
+func executeNodes(w io.Writer, nodes []jsonPathNode, root, current interface{}) error {
+	for _, node := range nodes {
+		switch {
+		case node.isRange:
+			values, err := node.path.eval(root, current)
+			if err != nil {
+				return err
+			}
+			for _, value := range values {
+				if err := executeNodes(w, node.body, root, value); err != nil {
+					return err
+				}
+			}
+		case node.path != nil:
+			values, err := node.path.eval(root, current)
+			if err != nil {
+				return err
+			}
+			texts := make([]string, len(values))
+			for i, value := range values {
+				if texts[i], err = format(value); err != nil {
+					return err
+				}
+			}
+			if _, err := io.WriteString(w, strings.Join(texts, " ")); err != nil {
+				return err
+			}
+		default:
+			if _, err := io.WriteString(w, node.text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// eval returns the values the path selects. The fields missing from the
+// document select nothing, so a script gets an empty string.
+func (p path) eval(root, current interface{}) ([]interface{}, error) {
+	values := []interface{}{current}
+	if p.root {
+		values = []interface{}{root}
+	}
+	for _, s := range p.steps {
+		var next []interface{}
+		for _, value := range values {
+			selected, err := s.eval(root, value)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, selected...)
+		}
+		values = next
+	}
+	return values, nil
+}
+
+func (s step) eval(root, value interface{}) ([]interface{}, error) {
+	switch {
+	case s.wildcard:
+		switch v := value.(type) {
+		case []interface{}:
+			return v, nil
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			values := make([]interface{}, len(keys))
+			for i, key := range keys {
+				values[i] = v[key]
+			}
+			return values, nil
+		}
+		return nil, nil
+	case s.index != nil:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, nil
+		}
+		i := *s.index
+		if i < 0 {
+			i += len(list)
+		}
+		if i < 0 || i >= len(list) {
+			return nil, fmt.Errorf("index %d is out of the %d element(s) of the list", *s.index, len(list))
+		}
+		return []interface{}{list[i]}, nil
+	case s.filter != nil:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, nil
+		}
+		var kept []interface{}
+		for _, element := range list {
+			match, err := s.filter.match(root, element)
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				kept = append(kept, element)
+			}
+		}
+		return kept, nil
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	if field, ok := object[s.field]; ok {
+		return []interface{}{field}, nil
+	}
+	return nil, nil
+}
+
+func (f filter) match(root, element interface{}) (bool, error) {
+	values, err := f.path.eval(root, element)
+	if err != nil {
+		return false, err
+	}
+	switch f.op {
+	case "==", "!=":
+		equal := len(values) == 1 && reflect.DeepEqual(values[0], f.value)
+		return equal == (f.op == "=="), nil
+	}
+	return len(values) > 0, nil
+}
+
+// format returns a value as printed by a path: the strings as they are and
+// the rest as JSON
+func format(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case nil:
+		return "", nil
+	}
+	b, err := json.Marshal(value)
+	return string(b), err
+}
@@ -0,0 +1,114 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+
+// Package output prints the results of the get and describe commands in the
+// format of --output: as JSON, or only the fields picked with a JSONPath or a
+// Go template like kubectl does, so scripts don't need jq.
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// Formats of --output
+const (
+	JSON       = "json"
+	JSONPath   = "jsonpath"
+	GoTemplate = "go-template"
+)
+
+// Formats are the values --output is completed with
+var Formats = []string{JSON, JSONPath + "=", GoTemplate + "="}
+
+// Format is how a command prints its result
+type Format struct {
+	kind     string
+	jsonPath *jsonPath
+	template *template.Template
+}
+
+// Parse parses --output, like json, jsonpath={.items[*].name} or
+// go-template={{range .items}}{{.name}}{{end}}. An empty value is the
+// default format of the command, which the command prints itself.
+func Parse(raw string) (Format, error) {
+	kind, arg := raw, ""
+	if i := strings.Index(raw, "="); i >= 0 {
+		kind, arg = raw[:i], raw[i+1:]
+	}
+	switch kind {
+	case "":
+		return Format{}, nil
+	case JSON:
+		if arg != "" {
+			break
+		}
+		return Format{kind: JSON}, nil
+	case JSONPath:
+		if arg == "" {
+			return Format{}, fmt.Errorf("--output jsonpath needs a template, e.g: jsonpath='{.items[*].name}'")
+		}
+		j, err := parseJSONPath(arg)
+		if err != nil {
+			return Format{}, fmt.Errorf("invalid jsonpath template: %w", err)
+		}
+		return Format{kind: JSONPath, jsonPath: j}, nil
+	case GoTemplate:
+		if arg == "" {
+			return Format{}, fmt.Errorf("--output go-template needs a template, e.g: go-template='{{range .items}}{{.name}}{{end}}'")
+		}
+		t, err := template.New("output").Parse(arg)
+		if err != nil {
+			return Format{}, fmt.Errorf("invalid go-template: %w", err)
+		}
+		return Format{kind: GoTemplate, template: t}, nil
+	}
+	return Format{}, fmt.Errorf("invalid --output %q, it is json, jsonpath=<template> or go-template=<template>", raw)
+}
+
+// Default is true for the default format of the command
+func (f Format) Default() bool {
+	return f.kind == ""
+}
+
+// Print writes v in the format. The paths and the templates see v as it is
+// encoded to JSON, with the field names of the JSON.
+func (f Format) Print(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if f.kind == JSON {
+		var out bytes.Buffer
+		if err := json.Indent(&out, b, "", "  "); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		_, err = out.WriteTo(w)
+		return err
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+	// The output is only written once it is complete, so a failed template
+	// doesn't leave half of it
+	var out bytes.Buffer
+	switch f.kind {
+	case JSONPath:
+		err = f.jsonPath.execute(&out, data)
+	case GoTemplate:
+		err = f.template.Execute(&out, data)
+	default:
+		return fmt.Errorf("no output format")
+	}
+	if err != nil {
+		return fmt.Errorf("unable to apply the %s template: %w", f.kind, err)
+	}
+	_, err = out.WriteTo(w)
+	return err
+}
@@ -0,0 +1,87 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cluster struct {
+	Name    string   `json:"name"`
+	Uuid    string   `json:"uuid"`
+	Masters int      `json:"masters"`
+	Addons  []string `json:"addons,omitempty"`
+}
+
+var clusters = map[string]interface{}{
+	"items": []cluster{
+		{Name: "edge", Uuid: "1b2c", Masters: 3, Addons: []string{"metallb", "kubevirt"}},
+		{Name: "lab", Uuid: "9f8e", Masters: 1},
+	},
+}
+
+func TestPrint(t *testing.T) {
+	tcs := map[string]struct {
+		output string
+		want   string
+		err    bool
+	}{
+		"field":             {output: "jsonpath={.items[0].uuid}", want: "1b2c"},
+		"wildcard":          {output: "jsonpath={.items[*].name}", want: "edge lab"},
+		"last":              {output: "jsonpath={.items[-1].name}", want: "lab"},
+		"number":            {output: "jsonpath={.items[0].masters}", want: "3"},
+		"list":              {output: "jsonpath={.items[0].addons}", want: `["metallb","kubevirt"]`},
+		"quoted field":      {output: "jsonpath={.items[0]['name']}", want: "edge"},
+		"filter":            {output: `jsonpath={.items[?(@.name=="lab")].uuid}`, want: "9f8e"},
+		"filter number":     {output: `jsonpath={.items[?(@.masters!=1)].name}`, want: "edge"},
+		"filter exists":     {output: `jsonpath={.items[?(@.addons)].name}`, want: "edge"},
+		"filter brace":      {output: `jsonpath={.items[?(@.name=='a}b')].name}`, want: ""},
+		"missing":           {output: "jsonpath={.items[0].region}", want: ""},
+		"root":              {output: "jsonpath={$.items[1].name}", want: "lab"},
+		"text":              {output: `jsonpath=uuid: {.items[0].uuid}{"\n"}`, want: "uuid: 1b2c\n"},
+		"range":             {output: `jsonpath={range .items[*]}{.name}{"\t"}{.uuid}{"\n"}{end}`, want: "edge\t1b2c\nlab\t9f8e\n"},
+		"nested range":      {output: `jsonpath={range .items[*]}{.name}:{range .addons[*]} {.}{end};{end}`, want: "edge: metallb kubevirt;lab:;"},
+		"out of range":      {output: "jsonpath={.items[5].name}", err: true},
+		"go template":       {output: `go-template={{range .items}}{{.name}}={{.uuid}} {{end}}`, want: "edge=1b2c lab=9f8e "},
+		"go template error": {output: `go-template={{.items.name.first}}`, err: true},
+		"json":              {output: "json", want: "{\n  \"items\": [\n    {\n      \"name\": \"edge\",\n      \"uuid\": \"1b2c\",\n      \"masters\": 3,\n      \"addons\": [\n        \"metallb\",\n        \"kubevirt\"\n      ]\n    },\n    {\n      \"name\": \"lab\",\n      \"uuid\": \"9f8e\",\n      \"masters\": 1\n    }\n  ]\n}\n"},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			f, err := Parse(tc.output)
+			assert.NoError(t, err)
+			var b bytes.Buffer
+			err = f.Print(&b, clusters)
+			if tc.err {
+				assert.Error(t, err)
+				assert.Empty(t, b.String())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, b.String())
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	f, err := Parse("")
+	assert.NoError(t, err)
+	assert.True(t, f.Default())
+
+	for _, raw := range []string{
+		"yaml",
+		"json=x",
+		"jsonpath=",
+		"jsonpath={.items",
+		"jsonpath={range .items[*]}{.name}",
+		"jsonpath={end}",
+		"jsonpath={items}",
+		"jsonpath={.items[x]}",
+		"jsonpath={.items[?(.name)]}",
+		"go-template={{.name",
+	} {
+		_, err := Parse(raw)
+		assert.Error(t, err, raw)
+	}
+}
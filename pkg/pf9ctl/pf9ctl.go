@@ -0,0 +1,315 @@
+// Package pf9ctl embeds the node operations of the pf9ctl CLI in Go programs,
+// so nodes can be prepared, attached and decommissioned without running the
+// binary:
+//
+//	c, err := pf9ctl.New(ctx, pf9ctl.Config{
+//		Fqdn:     "https://example.platform9.io",
+//		Username: "admin@example.com",
+//		Password: password,
+//		Region:   "RegionOne",
+//		Tenant:   "service",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//
+//	node := pf9ctl.Node{IPs: []string{"10.0.0.1"}, User: "ubuntu", SshKey: "/home/ubuntu/.ssh/id_rsa"}
+//	if err := c.PrepNode(ctx, node, pf9ctl.PrepNodeOptions{Role: "worker"}); err != nil {
+//		return err
+//	}
+//	job, err := c.AttachNodes(ctx, pf9ctl.AttachNodesInput{ClusterName: "prod", WorkerIPs: node.IPs})
+//
+// A Node without IPs, or with a localhost IP, is the machine the program runs
+// on. The operations report their progress like the CLI, on ui.Output, and
+// never prompt. They set the global settings of the CLI they need and restore
+// them once done, so a Client must not be used concurrently. The context is
+// checked between the steps of the operations and the phases of prep-node.
+package pf9ctl
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/jobs"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/socks"
+	"github.com/platform9/pf9ctl/pkg/ssh"
+	"github.com/platform9/pf9ctl/pkg/util"
+)
+
+// Config is the DU and the credentials to use, like the config of the CLI
+type Config = objects.Config
+
+// Node is how to reach a node over SSH
+type Node = objects.NodeConfig
+
+// AttachNodesInput are the nodes to attach to a cluster
+type AttachNodesInput = pmk.AttachNodesInput
+
+// UnresolvedNodesError lists the nodes AttachNodes left out with
+// ContinueOnError
+type UnresolvedNodesError = pmk.UnresolvedNodesError
+
+// DecommissionRiskError lists the clusters DecommissionNode puts at risk
+// without Force
+type DecommissionRiskError = pmk.DecommissionRiskError
+
+// CheckNodeResult is the outcome of the preflight checks of a node
+type CheckNodeResult = pmk.CheckNodeResult
+
+// Results of the preflight checks
+const (
+	CheckPass         = pmk.PASS
+	CheckRequiredFail = pmk.RequiredFail
+	CheckOptionalFail = pmk.OptionalFail
+)
+
+// defaultWaitPeriod is the wait period of the CLI before authorizing a host
+const defaultWaitPeriod = time.Duration(60)
+
+// Client runs the node operations against a DU
+type Client struct {
+	cfg    Config
+	client client.Client
+	auth   keystone.KeystoneAuth
+}
+
+// DecommissionNodeOptions change the checks of DecommissionNode
+type DecommissionNodeOptions struct {
+	// Force decommissions the node even when it puts its cluster at risk,
+	// or when the cluster of the node can't be checked
+	Force bool
+	// OverrideProtected decommissions the node even when it is protected,
+	// see the protected_hosts and protected_markers settings
+	OverrideProtected bool
+}
+
+// PrepNodeOptions change how a node is prepared
+type PrepNodeOptions struct {
+	// SkipOptionalChecks prepares the node even when optional checks fail
+	SkipOptionalChecks bool
+	// Role is master or worker, to check and tune the node for that role.
+	// Empty checks the node for any role.
+	Role string
+	// WorkDir is where the installer is downloaded, $HOME/pf9 when empty
+	WorkDir string
+}
+
+// New authenticates with the DU of cfg and returns a client of it. Usage
+// tracking is disabled for embedding programs.
+func New(ctx context.Context, cfg Config) (*Client, error) {
+	if cfg.Fqdn == "" {
+		return nil, fmt.Errorf("the DU FQDN is required")
+	}
+	if cfg.ApplicationCredentialID == "" && (cfg.Username == "" || cfg.Password == "") {
+		return nil, fmt.Errorf("a username and a password or an application credential are required")
+	}
+	if cfg.WaitPeriod == 0 {
+		cfg.WaitPeriod = defaultWaitPeriod
+	}
+	if cfg.WorkDir != "" {
+		if err := pmk.ValidateWorkDir(cfg.WorkDir); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ProxyURL != "" {
+		if err := config.SetProxy(cfg.ProxyURL); err != nil {
+			return nil, fmt.Errorf("unable to set the proxy: %w", err)
+		}
+	}
+	if err := socks.Configure(cfg.SOCKSProxy); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c, err := client.NewClient(cfg.Fqdn, cmdexec.LocalExecutor{ProxyUrl: cfg.ProxyURL}, cfg.AllowInsecure, true)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create client: %w", err)
+	}
+	auth, err := keystone.Authenticate(c.Keystone, cfg)
+	if err != nil {
+		c.Segment.Close()
+		return nil, fmt.Errorf("unable to obtain keystone credentials: %w", err)
+	}
+	return &Client{cfg: cfg, client: c, auth: auth}, nil
+}
+
+// Close releases the resources of the client
+func (c *Client) Close() {
+	c.client.Segment.Close()
+}
+
+// CheckNode runs the preflight checks of prep-node on the node. An existing
+// installation is only removed with RemoveExistingPkgs, it fails the checks
+// otherwise.
+func (c *Client) CheckNode(ctx context.Context, node Node) (CheckNodeResult, error) {
+	defer useSettings(node, PrepNodeOptions{})()
+	node.NoPrompt = true
+	nodeClient, err := c.nodeClient(ctx, node)
+	if err != nil {
+		return CheckRequiredFail, err
+	}
+	defer cmdexec.Close(nodeClient.Executor)
+	return pmk.CheckNode(c.cfg, nodeClient, c.auth, node)
+}
+
+// PrepNode checks the node and, when the checks pass, installs the Platform9
+// packages and authorizes the node so it can be attached to a cluster
+func (c *Client) PrepNode(ctx context.Context, node Node, opts PrepNodeOptions) error {
+	if err := util.ValidateNodeRole(opts.Role); err != nil {
+		return err
+	}
+	cfg := c.cfg
+	if opts.WorkDir != "" {
+		if err := pmk.ValidateWorkDir(opts.WorkDir); err != nil {
+			return err
+		}
+		cfg.WorkDir = opts.WorkDir
+	}
+	if !util.SkipKube {
+		if err := keystone.CheckOperationRole(c.auth, "prep-node"); err != nil {
+			return err
+		}
+	}
+	defer useSettings(node, opts)()
+	node.NoPrompt = true
+
+	nodeClient, err := c.nodeClient(ctx, node)
+	if err != nil {
+		return err
+	}
+	defer cmdexec.Close(nodeClient.Executor)
+	result, err := pmk.CheckNode(cfg, nodeClient, c.auth, node)
+	// The existing installation was removed with RemoveExistingPkgs, the
+	// node is checked again without it
+	if err == nil && result == pmk.CleanInstallFail {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result, err = pmk.CheckNode(cfg, nodeClient, c.auth, node)
+	}
+	if err != nil {
+		return fmt.Errorf("pre-requisite check(s) failed: %w", err)
+	}
+	switch {
+	case result == pmk.CleanInstallFail:
+		return fmt.Errorf("the existing installation is still on the node once removed")
+	case result == pmk.RequiredFail:
+		return fmt.Errorf("required pre-requisite check(s) failed")
+	case result == pmk.OptionalFail && !opts.SkipOptionalChecks:
+		return fmt.Errorf("optional pre-requisite check(s) failed")
+	}
+	return pmk.PrepNodeContext(ctx, cfg, nodeClient, c.auth)
+}
+
+// AttachNodes attaches prepared nodes to a cluster. The returned job records
+// the status of each node, a failed job can be resumed with 'pf9ctl jobs
+// resume' or ResumeJob. With ContinueOnError the nodes which can't be attached
+// are returned as an *UnresolvedNodesError once the others are attached.
+func (c *Client) AttachNodes(ctx context.Context, in AttachNodesInput) (*jobs.Job, error) {
+	if err := keystone.CheckOperationRole(c.auth, "attach-node"); err != nil {
+		return nil, err
+	}
+	if in.Region == "" {
+		in.Region = c.cfg.Region
+	}
+	job, err := pmk.NewAttachJob(ctx, c.client, c.auth, c.cfg.Fqdn, in)
+	if job == nil {
+		return nil, err
+	}
+	if runErr := c.ResumeJob(ctx, job); runErr != nil {
+		return job, runErr
+	}
+	return job, err
+}
+
+// ResumeJob runs the operation of the job on its remaining nodes
+func (c *Client) ResumeJob(ctx context.Context, job *jobs.Job) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := keystone.CheckOperationRole(c.auth, job.Operation); err != nil {
+		return err
+	}
+	return pmk.RunJob(c.client, c.auth, job)
+}
+
+// DecommissionNode detaches the node from its cluster, removes it from the
+// DU and uninstalls the Platform9 packages. Like the CLI, it refuses to
+// decommission a protected node unless OverrideProtected is set, and returns
+// a *DecommissionRiskError when it puts the cluster of the node at risk unless
+// Force is set.
+func (c *Client) DecommissionNode(ctx context.Context, node Node, opts DecommissionNodeOptions) error {
+	defer useSettings(node, PrepNodeOptions{})()
+	cfg := c.cfg
+	nodeClient, err := c.nodeClient(ctx, node)
+	if err != nil {
+		return err
+	}
+	reason := pmk.ProtectedReason(nodeClient.Executor, &cfg)
+	cmdexec.Close(nodeClient.Executor)
+	if reason != "" && !opts.OverrideProtected {
+		return fmt.Errorf("the node is protected: %s, set OverrideProtected to decommission it anyway", reason)
+	}
+
+	ip := ""
+	if cmdexec.CheckRemote(node) {
+		ip = node.IPs[0]
+	} else {
+		localIP, err := pmk.GetIp()
+		if err != nil {
+			return err
+		}
+		ip = localIP.String()
+	}
+	impacts, err := pmk.CheckDecommission(c.client, c.auth, cfg.Fqdn, []string{ip})
+	if err != nil && !opts.Force {
+		return fmt.Errorf("unable to check the cluster of the node: %w, set Force to decommission it anyway", err)
+	}
+	if len(impacts) > 0 && !opts.Force {
+		return &DecommissionRiskError{Impacts: impacts}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return pmk.DecommissionNode(&cfg, node, true)
+}
+
+// useSettings sets the global settings of the CLI the operations on node
+// read, and returns the function restoring them, so they don't leak from one
+// operation to the next
+func useSettings(node Node, opts PrepNodeOptions) (restore func()) {
+	role, warnOptional, sudoPassword := util.NodeRole, pmk.WarningOptionalChecks, ssh.SudoPassword
+	util.NodeRole = opts.Role
+	pmk.WarningOptionalChecks = opts.SkipOptionalChecks
+	ssh.SudoPassword = node.SudoPassword
+	return func() {
+		util.NodeRole, pmk.WarningOptionalChecks, ssh.SudoPassword = role, warnOptional, sudoPassword
+	}
+}
+
+// nodeClient returns the client running its commands on the node
+func (c *Client) nodeClient(ctx context.Context, node Node) (client.Client, error) {
+	if err := ctx.Err(); err != nil {
+		return client.Client{}, err
+	}
+	if cmdexec.CheckRemote(node) && len(node.IPs) > 1 {
+		return client.Client{}, fmt.Errorf("only one node can be given, got %d IPs", len(node.IPs))
+	}
+	executor, err := cmdexec.GetExecutor(c.cfg.ProxyURL, node)
+	if err != nil {
+		return client.Client{}, fmt.Errorf("unable to create executor: %w", err)
+	}
+	nodeClient := c.client
+	nodeClient.Executor = executor
+	return nodeClient, nil
+}
@@ -0,0 +1,81 @@
+package pf9ctl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/ssh"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewValidation(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := map[string]struct {
+		ctx     context.Context
+		cfg     Config
+		wantErr string
+	}{
+		"NoFqdn": {
+			ctx:     context.Background(),
+			cfg:     Config{Username: "admin", Password: "secret"},
+			wantErr: "the DU FQDN is required",
+		},
+		"NoCredentials": {
+			ctx:     context.Background(),
+			cfg:     Config{Fqdn: "https://du.platform9.net", Username: "admin"},
+			wantErr: "a username and a password or an application credential are required",
+		},
+		"InvalidWorkDir": {
+			ctx:     context.Background(),
+			cfg:     Config{Fqdn: "https://du.platform9.net", ApplicationCredentialID: "id", WorkDir: "pf9"},
+			wantErr: "invalid work directory",
+		},
+		"Canceled": {
+			ctx:     canceled,
+			cfg:     Config{Fqdn: "https://du.platform9.net", Username: "admin", Password: "secret"},
+			wantErr: context.Canceled.Error(),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(tc.ctx, tc.cfg)
+			assert.Nil(t, c)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrepNodeInvalidRole(t *testing.T) {
+	c := &Client{}
+	err := c.PrepNode(context.Background(), Node{}, PrepNodeOptions{Role: "etcd"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid role etcd")
+	}
+}
+
+func TestDecommissionNodeSingleNode(t *testing.T) {
+	c := &Client{}
+	err := c.DecommissionNode(context.Background(), Node{IPs: []string{"10.0.0.1", "10.0.0.2"}}, DecommissionNodeOptions{Force: true})
+	assert.EqualError(t, err, "only one node can be given, got 2 IPs")
+}
+
+func TestUseSettings(t *testing.T) {
+	util.NodeRole, pmk.WarningOptionalChecks, ssh.SudoPassword = "", false, ""
+
+	restore := useSettings(Node{SudoPassword: "s3cr3t"}, PrepNodeOptions{Role: "master", SkipOptionalChecks: true})
+	assert.Equal(t, "master", util.NodeRole)
+	assert.True(t, pmk.WarningOptionalChecks)
+	assert.Equal(t, "s3cr3t", ssh.SudoPassword)
+
+	// The settings don't leak into the next operations
+	restore()
+	assert.Equal(t, "", util.NodeRole)
+	assert.False(t, pmk.WarningOptionalChecks)
+	assert.Equal(t, "", ssh.SudoPassword)
+}
@@ -0,0 +1,216 @@
+package platform
+
+import (
+	"fmt"
+)
+
+// Severities of the checks, a failed required check stops prep-node while a
+// failed optional check only warns with --skip-checks
+const (
+	SeverityRequired = "required"
+	SeverityOptional = "optional"
+)
+
+// IDs of the checks, given to check-node --checks and --exclude-checks
+const (
+	CheckIDLegacyCLI          = "legacy-cli"
+	CheckIDExistingPackages   = "existing-packages"
+	CheckIDOSPackages         = "os-packages"
+	CheckIDSudo               = "sudo"
+	CheckIDCPU                = "cpu"
+	CheckIDDisk               = "disk"
+	CheckIDMemory             = "memory"
+	CheckIDPorts              = "ports"
+	CheckIDExistingKubernetes = "existing-kubernetes"
+	CheckIDDpkgLock           = "dpkg-lock"
+	CheckIDAptLock            = "apt-lock"
+	CheckIDSystemd            = "systemd"
+	CheckIDTimeSync           = "time-sync"
+	CheckIDFirewalld          = "firewalld"
+	CheckIDSwap               = "swap"
+	CheckIDMountSpace         = "mount-space"
+	CheckIDHostname           = "hostname"
+	CheckIDDNSResolver        = "dns-resolver"
+	CheckIDDNSOptions         = "dns-options"
+	CheckIDCgroup             = "cgroup"
+	CheckIDContainerRuntime   = "container-runtime"
+	CheckIDFIPS               = "fips"
+	CheckIDNoexecTmp          = "noexec-tmp"
+	CheckIDSudoers            = "sudoers"
+	CheckIDIPForwarding       = "ip-forwarding"
+	CheckIDAuditd             = "auditd"
+	CheckIDClockSkew          = "clock-skew"
+)
+
+// CheckInfo describes a check run by check-node and prep-node
+type CheckInfo struct {
+	ID string `json:"id"`
+	// Name is the name the check is reported with
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	// Remediable is set when pf9ctl fixes the node when the check fails,
+	// as told by Remediation
+	Remediable  bool   `json:"remediable"`
+	Remediation string `json:"remediation,omitempty"`
+	// OS are the OS families the check runs on, debian or redhat, all when
+	// empty
+	OS []string `json:"os,omitempty"`
+
+	// prefix is set when the checks are named Name followed by what they
+	// check, like the mount they check the space of
+	prefix bool
+}
+
+// catalog is every check, in the order they run
+var catalog = []CheckInfo{
+	{ID: CheckIDLegacyCLI, Name: "Removal of existing CLI", Severity: SeverityOptional,
+		Description: "Removes the deprecated Python CLI of Platform9", Remediation: "the Python CLI is removed"},
+	{ID: CheckIDExistingPackages, Name: "Existing Platform9 Packages Check", Severity: SeverityRequired,
+		Description: "Checks the Platform9 packages aren't installed already", Remediation: "the previous installation is removed with --remove-existing-pkgs"},
+	{ID: CheckIDOSPackages, Name: "Required OS Packages Check", Severity: SeverityRequired,
+		Description: "Checks the OS packages PMK needs are installed", Remediation: "the missing packages are installed"},
+	{ID: CheckIDSudo, Name: "SudoCheck", Severity: SeverityRequired,
+		Description: "Checks the user can run commands with sudo"},
+	{ID: CheckIDCPU, Name: "CPUCheck", Severity: SeverityOptional,
+		Description: "Checks the node has the CPUs PMK needs"},
+	{ID: CheckIDDisk, Name: "DiskCheck", Severity: SeverityOptional,
+		Description: "Checks the node has the disk space PMK needs"},
+	{ID: CheckIDMemory, Name: "MemoryCheck", Severity: SeverityOptional,
+		Description: "Checks the node has the memory PMK needs"},
+	{ID: CheckIDPorts, Name: "PortCheck", Severity: SeverityRequired,
+		Description: "Checks the ports of the Kubernetes services are free"},
+	{ID: CheckIDExistingKubernetes, Name: "Existing Kubernetes Cluster Check", Severity: SeverityRequired,
+		Description: "Checks no Kubernetes process runs on the node"},
+	{ID: CheckIDDpkgLock, Name: "Check lock on dpkg", Severity: SeverityRequired, OS: []string{"debian"},
+		Description: "Checks no other process holds the lock of dpkg"},
+	{ID: CheckIDAptLock, Name: "Check lock on apt", Severity: SeverityRequired, OS: []string{"debian"},
+		Description: "Checks no other process holds the lock of apt"},
+	{ID: CheckIDSystemd, Name: "Check if system is booted with systemd", Severity: SeverityRequired,
+		Description: "Checks the node booted with systemd"},
+	{ID: CheckIDTimeSync, Name: "Check time synchronization", Severity: SeverityOptional, OS: []string{"debian"},
+		Description: "Checks a time synchronization service runs", Remediation: "a time synchronization service is installed and started"},
+	{ID: CheckIDFirewalld, Name: "Check if firewalld service is not running", Severity: SeverityOptional,
+		Description: "Checks firewalld doesn't run"},
+	{ID: CheckIDSwap, Name: "Disabling swap and removing swap in fstab", Severity: SeverityRequired,
+		Description: "Checks swap is off and out of /etc/fstab", Remediation: "swap is disabled and removed from /etc/fstab"},
+	{ID: CheckIDMountSpace, Name: "Disk space and inodes check of", Severity: SeverityRequired, prefix: true,
+		Description: "Checks the filesystems of the packages and the work directory have room for them"},
+	{ID: CheckIDHostname, Name: "Hostname and /etc/hosts check", Severity: SeverityRequired,
+		Description: "Checks the hostname is valid and resolves with /etc/hosts or the DNS", Remediation: "the hostname is added to /etc/hosts with --fix-hostname"},
+	{ID: CheckIDDNSResolver, Name: "DNS resolver check", Severity: SeverityRequired,
+		Description: "Checks /etc/resolv.conf has upstream nameservers resolving the DU"},
+	{ID: CheckIDDNSOptions, Name: "DNS options check", Severity: SeverityOptional,
+		Description: "Checks the search domains and ndots of /etc/resolv.conf suit the pods"},
+	{ID: CheckIDCgroup, Name: "Cgroup version check", Severity: SeverityRequired,
+		Description: "Checks the cgroup version suits the Kubernetes version", Remediation: "the node is switched to the cgroup version with prep-node --fix-cgroup"},
+	{ID: CheckIDContainerRuntime, Name: "Container runtime check", Severity: SeverityOptional,
+		Description: "Warns about the container runtimes running on the node, which conflict with the containerd of PMK",
+		Remediation: "the runtimes are removed with prep-node --remove-existing-runtime, or kept for PMK with --reuse-runtime"},
+	{ID: CheckIDFIPS, Name: "FIPS check", Severity: SeverityRequired,
+		Description: "Checks the node runs in FIPS mode, with the FIPS build of pf9ctl"},
+	{ID: CheckIDNoexecTmp, Name: "Noexec /tmp check", Severity: SeverityRequired,
+		Description: "Checks /tmp isn't mounted noexec by a hardening benchmark"},
+	{ID: CheckIDSudoers, Name: "Sudoers check", Severity: SeverityRequired,
+		Description: "Checks the hardened sudoers let the pf9 user run sudo"},
+	{ID: CheckIDIPForwarding, Name: "IP forwarding check", Severity: SeverityRequired,
+		Description: "Checks a hardening benchmark doesn't disable IP forwarding"},
+	{ID: CheckIDAuditd, Name: "Auditd check", Severity: SeverityOptional,
+		Description: "Checks the audit settings suit the containers"},
+	{ID: CheckIDClockSkew, Name: "Clock skew check", Severity: SeverityRequired,
+		Description: "Checks the clock of the node is close to the one of the DU or of --ntp-server"},
+}
+
+// Catalog returns every check, in the order they run
+func Catalog() []CheckInfo {
+	checks := make([]CheckInfo, len(catalog))
+	for i, c := range catalog {
+		c.Remediable = c.Remediation != ""
+		if c.prefix {
+			c.Name += " <mount>"
+		}
+		checks[i] = c
+	}
+	return checks
+}
+
+// LookupCheck returns the check of the catalog check was run for. The checks
+// outside of the catalog have no ID.
+func LookupCheck(check Check) (CheckInfo, bool) {
+	if check.ID == "" {
+		return CheckInfo{}, false
+	}
+	return LookupCheckID(check.ID)
+}
+
+// LookupCheckID returns the check with id
+func LookupCheckID(id string) (CheckInfo, bool) {
+	for _, c := range catalog {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return CheckInfo{}, false
+}
+
+// UnknownCheckError is the error of a check ID which isn't in the catalog
+func UnknownCheckError(id string) error {
+	return fmt.Errorf("unknown check %q, see check-node --list-checks for the IDs of the checks", id)
+}
+
+// selected are the checks which run, all when only is empty but the ones
+// of skip
+var selected struct {
+	only map[string]bool
+	skip map[string]bool
+}
+
+// SelectChecks runs only the checks with the IDs of only, all when empty,
+// but the ones of skip
+func SelectChecks(only, skip []string) error {
+	onlyIDs, err := checkIDs(only)
+	if err != nil {
+		return err
+	}
+	skipIDs, err := checkIDs(skip)
+	if err != nil {
+		return err
+	}
+	selected.only, selected.skip = onlyIDs, skipIDs
+	return nil
+}
+
+func checkIDs(ids []string) (map[string]bool, error) {
+	set := make(map[string]bool)
+	for _, id := range ids {
+		if _, found := LookupCheckID(id); !found {
+			return nil, UnknownCheckError(id)
+		}
+		set[id] = true
+	}
+	return set, nil
+}
+
+// SkipRemediableChecks skips the checks which fix the node when they fail,
+// so the checks only read the node, until restore is called
+func SkipRemediableChecks() (restore func()) {
+	skip := selected.skip
+	selected.skip = make(map[string]bool)
+	for id := range skip {
+		selected.skip[id] = true
+	}
+	for _, c := range catalog {
+		if c.Remediation != "" {
+			selected.skip[c.ID] = true
+		}
+	}
+	return func() { selected.skip = skip }
+}
+
+// Enabled is true when the check with id runs
+func Enabled(id string) bool {
+	if len(selected.only) > 0 && !selected.only[id] {
+		return false
+	}
+	return !selected.skip[id]
+}
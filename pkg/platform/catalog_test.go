@@ -0,0 +1,72 @@
+package platform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog(t *testing.T) {
+	ids := make(map[string]bool)
+	for _, check := range Catalog() {
+		assert.False(t, ids[check.ID], "duplicate check %s", check.ID)
+		ids[check.ID] = true
+		assert.Contains(t, []string{SeverityRequired, SeverityOptional}, check.Severity, check.ID)
+		assert.Equal(t, check.Remediation != "", check.Remediable, check.ID)
+		assert.NotEmpty(t, check.Description, check.ID)
+	}
+}
+
+func TestLookupCheck(t *testing.T) {
+	cases := map[string]struct {
+		check Check
+		id    string
+	}{
+		"ID":        {check: Check{ID: CheckIDSwap, Name: "Disabling swap and removing swap in fstab"}, id: CheckIDSwap},
+		"OtherName": {check: Check{ID: CheckIDMountSpace, Name: "Disk space check of /var/opt/pf9"}, id: CheckIDMountSpace},
+		"NoID":      {check: Check{Name: "Hardening check"}},
+		"UnknownID": {check: Check{ID: "swapoff", Name: "Swap check"}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			info, found := LookupCheck(tc.check)
+			assert.Equal(t, tc.id != "", found)
+			assert.Equal(t, tc.id, info.ID)
+		})
+	}
+}
+
+func TestSelectChecks(t *testing.T) {
+	defer SelectChecks(nil, nil)
+
+	assert.NoError(t, SelectChecks(nil, []string{CheckIDTimeSync}))
+	assert.True(t, Enabled(CheckIDSwap))
+	assert.False(t, Enabled(CheckIDTimeSync))
+
+	assert.NoError(t, SelectChecks([]string{CheckIDSwap, CheckIDCgroup}, []string{CheckIDCgroup}))
+	assert.True(t, Enabled(CheckIDSwap))
+	assert.False(t, Enabled(CheckIDCgroup))
+	assert.False(t, Enabled(CheckIDTimeSync))
+
+	assert.EqualError(t, SelectChecks([]string{"swapoff"}, nil), `unknown check "swapoff", see check-node --list-checks for the IDs of the checks`)
+	// The selection is unchanged by an invalid one
+	assert.True(t, Enabled(CheckIDSwap))
+	assert.False(t, Enabled(CheckIDTimeSync))
+}
+
+func TestSkipRemediableChecks(t *testing.T) {
+	defer SelectChecks(nil, nil)
+
+	assert.NoError(t, SelectChecks(nil, []string{CheckIDCPU}))
+	restore := SkipRemediableChecks()
+	assert.False(t, Enabled(CheckIDOSPackages))
+	assert.False(t, Enabled(CheckIDSwap))
+	assert.False(t, Enabled(CheckIDTimeSync))
+	assert.False(t, Enabled(CheckIDCPU))
+	assert.True(t, Enabled(CheckIDMemory))
+
+	restore()
+	assert.True(t, Enabled(CheckIDOSPackages))
+	assert.True(t, Enabled(CheckIDSwap))
+	assert.False(t, Enabled(CheckIDCPU))
+}
@@ -19,7 +19,7 @@ var (
 	packages                   = []string{"ntp", "curl", "policycoreutils", "policycoreutils-python", "selinux-policy", "selinux-policy-targeted", "libselinux-utils", "net-tools"}
 	packageInstallError        = "Packages not found and could not be installed"
 	MissingPkgsInstalledCentos bool
-	k8sPresentError            = errors.New("A Kubernetes cluster is already running on node")
+	k8sPresentError            = errors.New("A Kubernetes cluster is already running on node, use pf9ctl import-node to adopt or tear it down")
 )
 
 // CentOS reprents centos based host machine
@@ -36,42 +36,64 @@ func NewCentOS(exec cmdexec.Executor) *CentOS {
 func (c *CentOS) Check() []platform.Check {
 	var checks []platform.Check
 
-	result, err := c.removePyCli()
-	checks = append(checks, platform.Check{"Removal of existing CLI", false, result, err, util.PyCliErr})
+	if platform.Enabled(platform.CheckIDLegacyCLI) {
+		result, err := c.removePyCli()
+		checks = append(checks, platform.Check{platform.CheckIDLegacyCLI, "Removal of existing CLI", false, result, err, util.PyCliErr})
+	}
 
-	result, err = c.CheckExistingInstallation()
-	checks = append(checks, platform.Check{"Existing Platform9 Packages Check", true, result, err, util.ExisitngInstallationErr})
+	if platform.Enabled(platform.CheckIDExistingPackages) {
+		result, err := c.CheckExistingInstallation()
+		checks = append(checks, platform.Check{platform.CheckIDExistingPackages, "Existing Platform9 Packages Check", true, result, err, util.ExisitngInstallationErr})
+	}
 
-	result, err = c.checkOSPackages()
-	checks = append(checks, platform.Check{"Required OS Packages Check", true, result, err, fmt.Sprintf("%s. %s", util.OSPackagesErr, err)})
+	if platform.Enabled(platform.CheckIDOSPackages) {
+		result, err := c.checkOSPackages()
+		checks = append(checks, platform.Check{platform.CheckIDOSPackages, "Required OS Packages Check", true, result, err, fmt.Sprintf("%s. %s", util.OSPackagesErr, err)})
+	}
 
-	result, err = c.checkSudo()
-	checks = append(checks, platform.Check{"SudoCheck", true, result, err, util.SudoErr})
+	if platform.Enabled(platform.CheckIDSudo) {
+		result, err := c.checkSudo()
+		checks = append(checks, platform.Check{platform.CheckIDSudo, "SudoCheck", true, result, err, util.SudoErr})
+	}
 
-	result, err = c.checkCPU()
-	checks = append(checks, platform.Check{"CPUCheck", false, result, err, fmt.Sprintf("%s %s", util.CPUErr, err)})
+	if platform.Enabled(platform.CheckIDCPU) {
+		result, err := c.checkCPU()
+		checks = append(checks, platform.Check{platform.CheckIDCPU, "CPUCheck", false, result, err, fmt.Sprintf("%s %s", util.Requirements().CPUErr(), err)})
+	}
 
-	result, err = c.checkDisk()
-	checks = append(checks, platform.Check{"DiskCheck", false, result, err, fmt.Sprintf("%s %s", util.DiskErr, err)})
+	if platform.Enabled(platform.CheckIDDisk) {
+		result, err := c.checkDisk()
+		checks = append(checks, platform.Check{platform.CheckIDDisk, "DiskCheck", false, result, err, fmt.Sprintf("%s %s", util.Requirements().DiskErr(), err)})
+	}
 
-	result, err = c.checkMem()
-	checks = append(checks, platform.Check{"MemoryCheck", false, result, err, fmt.Sprintf("%s %s", util.MemErr, err)})
+	if platform.Enabled(platform.CheckIDMemory) {
+		result, err := c.checkMem()
+		checks = append(checks, platform.Check{platform.CheckIDMemory, "MemoryCheck", false, result, err, fmt.Sprintf("%s %s", util.Requirements().MemErr(), err)})
+	}
 
-	result, err = c.checkPort()
-	checks = append(checks, platform.Check{"PortCheck", true, result, err, fmt.Sprintf("%s", err)})
+	if platform.Enabled(platform.CheckIDPorts) {
+		result, err := c.checkPort()
+		checks = append(checks, platform.Check{platform.CheckIDPorts, "PortCheck", true, result, err, fmt.Sprintf("%s", err)})
+	}
 
-	result, err = c.CheckKubernetesCluster()
-	checks = append(checks, platform.Check{"Existing Kubernetes Cluster Check", true, result, err, fmt.Sprintf("%s", err)})
+	if platform.Enabled(platform.CheckIDExistingKubernetes) {
+		result, err := c.CheckKubernetesCluster()
+		checks = append(checks, platform.Check{platform.CheckIDExistingKubernetes, "Existing Kubernetes Cluster Check", true, result, err, fmt.Sprintf("%s", err)})
+	}
 
-	result, err = c.checkPIDofSystemd()
-	checks = append(checks, platform.Check{"Check if system is booted with systemd", true, result, err, fmt.Sprintf("%s", err)})
+	if platform.Enabled(platform.CheckIDSystemd) {
+		result, err := c.checkPIDofSystemd()
+		checks = append(checks, platform.Check{platform.CheckIDSystemd, "Check if system is booted with systemd", true, result, err, fmt.Sprintf("%s", err)})
+	}
 
-	result, err = c.checkFirewalldIsRunning()
-	checks = append(checks, platform.Check{"Check if firewalld service is not running", false, result, err, fmt.Sprintf("%s", err)})
+	if platform.Enabled(platform.CheckIDFirewalld) {
+		result, err := c.checkFirewalldIsRunning()
+		checks = append(checks, platform.Check{platform.CheckIDFirewalld, "Check if firewalld service is not running", false, result, err, fmt.Sprintf("%s", err)})
+	}
 
-	if !util.SwapOffDisabled {
-		result, err = c.disableSwap()
-		checks = append(checks, platform.Check{"Disabling swap and removing swap in fstab", true, result, err, fmt.Sprintf("%s", err)})
+	if !util.SwapOffDisabled && platform.Enabled(platform.CheckIDSwap) {
+		result, err := c.disableSwap()
+		checks = append(checks, platform.Check{platform.CheckIDSwap, "Disabling swap and removing swap in fstab", true, result, err, fmt.Sprintf("%s", err)})
 	}
 
 	return checks
@@ -84,8 +106,6 @@ func (c *CentOS) CheckKubernetesCluster() (bool, error) {
 
 		if err != nil {
 			return true, nil
-		} else if c.checkDocker(); err != nil {
-			return true, nil
 		} else {
 			return false, k8sPresentError
 		}
@@ -93,18 +113,6 @@ func (c *CentOS) CheckKubernetesCluster() (bool, error) {
 	return true, nil
 }
 
-func (c *CentOS) checkDocker() error {
-	//Checking kube-proxy. Every node in kubernetes cluster runs kube-proxy.
-	var err error
-	for _, proc := range util.ProcessesList {
-		_, err = c.exec.RunWithStdout("bash", "-c", fmt.Sprintf("docker ps | grep -i %s", proc))
-		if err == nil {
-			return k8sPresentError
-		}
-	}
-	return err
-}
-
 func (c *CentOS) CheckExistingInstallation() (bool, error) {
 
 	var (
@@ -177,7 +185,7 @@ func (c *CentOS) checkCPU() (bool, error) {
 
 	zap.S().Debug("Number of CPUs found: ", cpu)
 
-	if cpu >= util.MinCPUs {
+	if cpu >= util.Requirements().CPUs {
 		return true, nil
 	}
 	return false, fmt.Errorf("Number of CPUs found: %d", cpu)
@@ -196,7 +204,7 @@ func (c *CentOS) checkMem() (bool, error) {
 
 	zap.S().Debug("Total memory allocated in GiBs", mem)
 
-	if math.Ceil(mem/1024) >= util.MinMem {
+	if math.Ceil(mem/1024) >= float64(util.Requirements().MemGB) {
 		return true, nil
 	}
 	return false, fmt.Errorf("Total memory found: %.0f GB", math.Ceil(mem/1024))
@@ -213,7 +221,7 @@ func (c *CentOS) checkDisk() (bool, error) {
 		return false, err
 	}
 
-	if math.Ceil(disk/util.GB) < util.MinDisk {
+	if math.Ceil(disk/util.GB) < float64(util.Requirements().DiskGB) {
 		return false, fmt.Errorf("Disk Space found: %.0f GB", math.Ceil(disk/util.GB))
 	}
 
@@ -231,7 +239,7 @@ func (c *CentOS) checkDisk() (bool, error) {
 
 	zap.S().Debug("Available disk space: ", avail)
 
-	if math.Ceil(avail/util.GB) >= util.MinAvailDisk {
+	if math.Ceil(avail/util.GB) >= float64(util.Requirements().AvailDiskGB) {
 		return true, nil
 	}
 	return false, fmt.Errorf("Available disk space: %.0f GB", math.Trunc(avail/util.GB))
@@ -515,55 +515,6 @@ func TestCheckKubernetesCluster(t *testing.T) {
 	}
 }
 
-func TestCheckDocker(t *testing.T) {
-	type want struct {
-		err error
-	}
-
-	cases := map[string]struct {
-		args
-		want
-	}{
-		//Success case. Node should not have any container-runtime (Docker) running
-		//Returning 0 exit code. Therefore test case should pass.
-		"CheckPass": {
-			args: args{
-				exec: &cmdexec.MockExecutor{
-					MockRunWithStdout: func(name string, args ...string) (string, error) {
-						return "0", nil
-					},
-				},
-			},
-			want: want{
-				err: k8sPresentError,
-			},
-		},
-		//Failure case. If node running any container-runtime (Docker).
-		//Returning 1 exit status. Therefore test case should pass.
-		"CheckFail": {
-			args: args{
-				exec: &cmdexec.MockExecutor{
-					MockRunWithStdout: func(name string, args ...string) (string, error) {
-						return "1", k8sPresentError
-					},
-				},
-			},
-			want: want{
-				err: k8sPresentError,
-			},
-		},
-	}
-
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			c := &CentOS{exec: tc.exec}
-			err := c.checkDocker()
-
-			assert.Equal(t, tc.err, err)
-		})
-	}
-}
-
 func TestDisableSwap(t *testing.T) {
 	type want struct {
 		result bool
@@ -1,6 +1,8 @@
 package platform
 
 type Check struct {
+	// ID is the ID of the check in the catalog
+	ID        string
 	Name      string
 	Mandatory bool
 	Result    bool
@@ -18,7 +18,7 @@ var (
 	packages                   = []string{"curl", "uuid-runtime", "net-tools"}
 	packageInstallError        = "Packages not found and could not be installed"
 	MissingPkgsInstalledDebian bool
-	k8sPresentError            = errors.New("A Kubernetes cluster is already running on node")
+	k8sPresentError            = errors.New("A Kubernetes cluster is already running on node, use pf9ctl import-node to adopt or tear it down")
 )
 
 // Debian represents debian based host machine
@@ -35,51 +35,79 @@ func NewDebian(exec cmdexec.Executor) *Debian {
 func (d *Debian) Check() []platform.Check {
 	var checks []platform.Check
 
-	result, err := d.removePyCli()
-	checks = append(checks, platform.Check{"Removal of existing CLI", false, result, err, util.PyCliErr})
+	if platform.Enabled(platform.CheckIDLegacyCLI) {
+		result, err := d.removePyCli()
+		checks = append(checks, platform.Check{platform.CheckIDLegacyCLI, "Removal of existing CLI", false, result, err, util.PyCliErr})
+	}
 
-	result, err = d.CheckExistingInstallation()
-	checks = append(checks, platform.Check{"Existing Platform9 Packages Check", true, result, err, util.ExisitngInstallationErr})
+	if platform.Enabled(platform.CheckIDExistingPackages) {
+		result, err := d.CheckExistingInstallation()
+		checks = append(checks, platform.Check{platform.CheckIDExistingPackages, "Existing Platform9 Packages Check", true, result, err, util.ExisitngInstallationErr})
+	}
 
-	result, err = d.checkOSPackages()
-	checks = append(checks, platform.Check{"Required OS Packages Check", true, result, err, fmt.Sprintf("%s. %s", util.OSPackagesErr, err)})
+	if platform.Enabled(platform.CheckIDOSPackages) {
+		result, err := d.checkOSPackages()
+		checks = append(checks, platform.Check{platform.CheckIDOSPackages, "Required OS Packages Check", true, result, err, fmt.Sprintf("%s. %s", util.OSPackagesErr, err)})
+	}
 
-	result, err = d.checkSudo()
-	checks = append(checks, platform.Check{"SudoCheck", true, result, err, util.SudoErr})
+	if platform.Enabled(platform.CheckIDSudo) {
+		result, err := d.checkSudo()
+		checks = append(checks, platform.Check{platform.CheckIDSudo, "SudoCheck", true, result, err, util.SudoErr})
+	}
 
-	result, err = d.checkCPU()
-	checks = append(checks, platform.Check{"CPUCheck", false, result, err, fmt.Sprintf("%s %s", util.CPUErr, err)})
+	if platform.Enabled(platform.CheckIDCPU) {
+		result, err := d.checkCPU()
+		checks = append(checks, platform.Check{platform.CheckIDCPU, "CPUCheck", false, result, err, fmt.Sprintf("%s %s", util.Requirements().CPUErr(), err)})
+	}
 
-	result, err = d.checkDisk()
-	checks = append(checks, platform.Check{"DiskCheck", false, result, err, fmt.Sprintf("%s %s", util.DiskErr, err)})
+	if platform.Enabled(platform.CheckIDDisk) {
+		result, err := d.checkDisk()
+		checks = append(checks, platform.Check{platform.CheckIDDisk, "DiskCheck", false, result, err, fmt.Sprintf("%s %s", util.Requirements().DiskErr(), err)})
+	}
 
-	result, err = d.checkMem()
-	checks = append(checks, platform.Check{"MemoryCheck", false, result, err, fmt.Sprintf("%s %s", util.MemErr, err)})
+	if platform.Enabled(platform.CheckIDMemory) {
+		result, err := d.checkMem()
+		checks = append(checks, platform.Check{platform.CheckIDMemory, "MemoryCheck", false, result, err, fmt.Sprintf("%s %s", util.Requirements().MemErr(), err)})
+	}
 
-	result, err = d.checkPort()
-	checks = append(checks, platform.Check{"PortCheck", true, result, err, fmt.Sprintf("%s", err)})
+	if platform.Enabled(platform.CheckIDPorts) {
+		result, err := d.checkPort()
+		checks = append(checks, platform.Check{platform.CheckIDPorts, "PortCheck", true, result, err, fmt.Sprintf("%s", err)})
+	}
 
-	result, err = d.CheckKubernetesCluster()
-	checks = append(checks, platform.Check{"Existing Kubernetes Cluster Check", true, result, err, fmt.Sprintf("%s", err)})
+	if platform.Enabled(platform.CheckIDExistingKubernetes) {
+		result, err := d.CheckKubernetesCluster()
+		checks = append(checks, platform.Check{platform.CheckIDExistingKubernetes, "Existing Kubernetes Cluster Check", true, result, err, fmt.Sprintf("%s", err)})
+	}
 
-	result, err = d.CheckIfdpkgISLock()
-	checks = append(checks, platform.Check{"Check lock on dpkg", true, result, err, fmt.Sprintf("%s", err)})
+	if platform.Enabled(platform.CheckIDDpkgLock) {
+		result, err := d.CheckIfdpkgISLock()
+		checks = append(checks, platform.Check{platform.CheckIDDpkgLock, "Check lock on dpkg", true, result, err, fmt.Sprintf("%s", err)})
+	}
 
-	result, err = d.checkIfaptISLock()
-	checks = append(checks, platform.Check{"Check lock on apt", true, result, err, fmt.Sprintf("%s", err)})
+	if platform.Enabled(platform.CheckIDAptLock) {
+		result, err := d.checkIfaptISLock()
+		checks = append(checks, platform.Check{platform.CheckIDAptLock, "Check lock on apt", true, result, err, fmt.Sprintf("%s", err)})
+	}
 
-	result, err = d.checkPIDofSystemd()
-	checks = append(checks, platform.Check{"Check if system is booted with systemd", true, result, err, fmt.Sprintf("%s", err)})
+	if platform.Enabled(platform.CheckIDSystemd) {
+		result, err := d.checkPIDofSystemd()
+		checks = append(checks, platform.Check{platform.CheckIDSystemd, "Check if system is booted with systemd", true, result, err, fmt.Sprintf("%s", err)})
+	}
 
-	result, err = d.checkIfTimesyncServiceRunning()
-	checks = append(checks, platform.Check{"Check time synchronization", false, result, err, fmt.Sprintf("%s", err)})
+	if platform.Enabled(platform.CheckIDTimeSync) {
+		result, err := d.checkIfTimesyncServiceRunning()
+		checks = append(checks, platform.Check{platform.CheckIDTimeSync, "Check time synchronization", false, result, err, fmt.Sprintf("%s", err)})
+	}
 
-	result, err = d.checkFirewalldIsRunning()
-	checks = append(checks, platform.Check{"Check if firewalld service is not running", false, result, err, fmt.Sprintf("%s", err)})
+	if platform.Enabled(platform.CheckIDFirewalld) {
+		result, err := d.checkFirewalldIsRunning()
+		checks = append(checks, platform.Check{platform.CheckIDFirewalld, "Check if firewalld service is not running", false, result, err, fmt.Sprintf("%s", err)})
+	}
 
-	if !util.SwapOffDisabled {
-		result, err = d.disableSwap()
-		checks = append(checks, platform.Check{"Disabling swap and removing swap in fstab", true, result, err, fmt.Sprintf("%s", err)})
+	if !util.SwapOffDisabled && platform.Enabled(platform.CheckIDSwap) {
+		result, err := d.disableSwap()
+		checks = append(checks, platform.Check{platform.CheckIDSwap, "Disabling swap and removing swap in fstab", true, result, err, fmt.Sprintf("%s", err)})
 	}
 	return checks
 }
@@ -91,8 +119,6 @@ func (d *Debian) CheckKubernetesCluster() (bool, error) {
 
 		if err != nil {
 			return true, nil
-		} else if d.checkDocker(); err != nil {
-			return true, nil
 		} else {
 			return false, k8sPresentError
 		}
@@ -100,18 +126,6 @@ func (d *Debian) CheckKubernetesCluster() (bool, error) {
 	return true, nil
 }
 
-func (d *Debian) checkDocker() error {
-	//Checking kube-proxy. Every node in kubernetes cluster runs kube-proxy.
-	var err error
-	for _, proc := range util.ProcessesList {
-		_, err = d.exec.RunWithStdout("bash", "-c", fmt.Sprintf("docker ps | grep -i %s", proc))
-		if err == nil {
-			return k8sPresentError
-		}
-	}
-	return err
-}
-
 func (d *Debian) CheckExistingInstallation() (bool, error) {
 
 	var (
@@ -186,7 +200,7 @@ func (d *Debian) checkCPU() (bool, error) {
 
 	zap.S().Debug("Number of CPUs found: ", cpu)
 
-	if cpu >= util.MinCPUs {
+	if cpu >= util.Requirements().CPUs {
 		return true, nil
 	}
 	return false, fmt.Errorf("Number of CPUs found: %d", cpu)
@@ -205,7 +219,7 @@ func (d *Debian) checkMem() (bool, error) {
 
 	zap.S().Debug("Total memory allocated in GiBs", mem)
 
-	if math.Ceil(mem/1024) >= util.MinMem {
+	if math.Ceil(mem/1024) >= float64(util.Requirements().MemGB) {
 		return true, nil
 	}
 	return false, fmt.Errorf("Total memory found: %.0f GB", math.Ceil(mem/1024))
@@ -222,7 +236,7 @@ func (d *Debian) checkDisk() (bool, error) {
 		return false, err
 	}
 
-	if math.Ceil(disk/util.GB) < util.MinDisk {
+	if math.Ceil(disk/util.GB) < float64(util.Requirements().DiskGB) {
 		return false, fmt.Errorf("Disk Space found: %.0f GB", math.Ceil(disk/util.GB))
 	}
 
@@ -240,7 +254,7 @@ func (d *Debian) checkDisk() (bool, error) {
 
 	zap.S().Debug("Available disk space: ", avail)
 
-	if math.Ceil(avail/util.GB) >= util.MinAvailDisk {
+	if math.Ceil(avail/util.GB) >= float64(util.Requirements().AvailDiskGB) {
 		return true, nil
 	}
 	return false, fmt.Errorf("Available disk space: %.0f GB", math.Trunc(avail/util.GB))
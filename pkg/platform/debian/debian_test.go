@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/util"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -81,6 +82,7 @@ func TestRAM(t *testing.T) {
 	cases := map[string]struct {
 		args
 		want
+		role string
 	}{
 		//Success case. Minimum required RAM 12GB
 		//Returning 12288 MB = 12 GB. Therefore test case should pass
@@ -111,10 +113,42 @@ func TestRAM(t *testing.T) {
 				err:    fmt.Errorf("Total memory found: 8 GB"),
 			},
 		},
+		//Success case. Minimum required RAM of a worker is 8GB
+		//Returning 8 GB. Therefore test case should pass
+		"WorkerCheckPass": {
+			args: args{
+				exec: &cmdexec.MockExecutor{
+					MockRunWithStdout: func(name string, args ...string) (string, error) {
+						return "8192", nil
+					},
+				},
+			},
+			want: want{
+				result: true,
+			},
+			role: util.RoleWorker,
+		},
+		//Failure case. A master needs 12 GB like a node of any role
+		"MasterCheckFail": {
+			args: args{
+				exec: &cmdexec.MockExecutor{
+					MockRunWithStdout: func(name string, args ...string) (string, error) {
+						return "8192", nil
+					},
+				},
+			},
+			want: want{
+				result: false,
+				err:    fmt.Errorf("Total memory found: 8 GB"),
+			},
+			role: util.RoleMaster,
+		},
 	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
+			util.NodeRole = tc.role
+			defer func() { util.NodeRole = "" }()
 			c := &Debian{exec: tc.exec}
 			o, err := c.checkMem()
 
@@ -512,55 +546,6 @@ func TestCheckKubernetesCluster(t *testing.T) {
 	}
 }
 
-func TestCheckDocker(t *testing.T) {
-	type want struct {
-		err error
-	}
-
-	cases := map[string]struct {
-		args
-		want
-	}{
-		//Success case. Node should not have any container-runtime (Docker) running
-		//Returning 0 exit code. Therefore test case should pass.
-		"CheckPass": {
-			args: args{
-				exec: &cmdexec.MockExecutor{
-					MockRunWithStdout: func(name string, args ...string) (string, error) {
-						return "0", nil
-					},
-				},
-			},
-			want: want{
-				err: k8sPresentError,
-			},
-		},
-		//Failure case. If node running any container-runtime (Docker).
-		//Returning 1 exit status. Therefore test case should pass.
-		"CheckFail": {
-			args: args{
-				exec: &cmdexec.MockExecutor{
-					MockRunWithStdout: func(name string, args ...string) (string, error) {
-						return "1", k8sPresentError
-					},
-				},
-			},
-			want: want{
-				err: k8sPresentError,
-			},
-		},
-	}
-
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			c := &Debian{exec: tc.exec}
-			err := c.checkDocker()
-
-			assert.Equal(t, tc.err, err)
-		})
-	}
-}
-
 func TestDisableSwap(t *testing.T) {
 	type want struct {
 		result bool
@@ -0,0 +1,129 @@
+package platform
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// MountRequirement is the free space and inodes needed under Path
+type MountRequirement struct {
+	Path          string
+	MinFreeMB     int64
+	MinFreeInodes int64
+}
+
+// DefaultMountRequirements are the free space and inodes needed by the
+// installer and the Platform9 packages. /var holds the container images and
+// the logs, /opt the Platform9 packages.
+var DefaultMountRequirements = []MountRequirement{
+	{Path: "/", MinFreeMB: 2048, MinFreeInodes: 50000},
+	{Path: "/var", MinFreeMB: 5120, MinFreeInodes: 100000},
+	{Path: "/opt", MinFreeMB: 2048, MinFreeInodes: 50000},
+}
+
+// mountUsage is the free space and inodes of a mounted filesystem
+type mountUsage struct {
+	mount      string
+	freeMB     int64
+	freeInodes int64
+	// noInodes is set for filesystems like btrfs which don't report inodes
+	noInodes bool
+}
+
+// CheckMounts checks the free space and inodes of the filesystems holding the
+// paths of reqs. Paths sharing a filesystem add up their requirements, so a
+// full root filesystem is reported once with everything it has to hold.
+func CheckMounts(exec cmdexec.Executor, reqs []MountRequirement) []Check {
+	var mounts []string
+	usages := make(map[string]mountUsage)
+	needed := make(map[string]MountRequirement)
+	paths := make(map[string][]string)
+
+	var checks []Check
+	for _, req := range reqs {
+		usage, err := diskUsage(exec, req.Path)
+		if err != nil {
+			checks = append(checks, Check{CheckIDMountSpace, fmt.Sprintf("Disk space check of %s", req.Path), true, false, err,
+				fmt.Sprintf("Unable to get the free space of %s", req.Path)})
+			continue
+		}
+		if _, ok := usages[usage.mount]; !ok {
+			mounts = append(mounts, usage.mount)
+			usages[usage.mount] = usage
+		}
+		total := needed[usage.mount]
+		total.MinFreeMB += req.MinFreeMB
+		total.MinFreeInodes += req.MinFreeInodes
+		needed[usage.mount] = total
+		paths[usage.mount] = append(paths[usage.mount], req.Path)
+	}
+
+	for _, mount := range mounts {
+		usage, req := usages[mount], needed[mount]
+		name := fmt.Sprintf("Disk space and inodes check of %s", mount)
+		var problems []string
+		if usage.freeMB < req.MinFreeMB {
+			problems = append(problems, fmt.Sprintf("%d MB free, %d MB needed", usage.freeMB, req.MinFreeMB))
+		}
+		if !usage.noInodes && usage.freeInodes < req.MinFreeInodes {
+			problems = append(problems, fmt.Sprintf("%d inodes free, %d needed", usage.freeInodes, req.MinFreeInodes))
+		}
+		if len(problems) > 0 {
+			err := fmt.Errorf("filesystem %s holding %s: %s", mount, strings.Join(paths[mount], ", "), strings.Join(problems, ", "))
+			checks = append(checks, Check{CheckIDMountSpace, name, true, false, err, fmt.Sprintf("Not enough room on %s. %s", mount, err)})
+			continue
+		}
+		checks = append(checks, Check{CheckIDMountSpace, name, true, true, nil, ""})
+	}
+	return checks
+}
+
+// diskUsage returns the usage of the filesystem of p. Paths which don't exist
+// yet, like the work directory, are checked on their closest existing parent.
+func diskUsage(exec cmdexec.Executor, p string) (mountUsage, error) {
+	var space string
+	var err error
+	for {
+		space, err = exec.RunWithStdout("bash", "-c", fmt.Sprintf("df -Pm %s | tail -1", p))
+		if err == nil || p == "/" {
+			break
+		}
+		zap.S().Debugf("Unable to get the free space of %s, checking its parent: %s", p, err)
+		p = path.Dir(p)
+	}
+	if err != nil {
+		return mountUsage{}, err
+	}
+	// Filesystem 1048576-blocks Used Available Capacity Mounted-on
+	fields := strings.Fields(space)
+	if len(fields) < 6 {
+		return mountUsage{}, fmt.Errorf("unexpected df output %q", space)
+	}
+	usage := mountUsage{mount: fields[5]}
+	if usage.freeMB, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+		return mountUsage{}, fmt.Errorf("unexpected df output %q", space)
+	}
+
+	inodes, err := exec.RunWithStdout("bash", "-c", fmt.Sprintf("df -Pi %s | tail -1", p))
+	if err != nil {
+		return mountUsage{}, err
+	}
+	// Filesystem Inodes IUsed IFree IUse% Mounted-on
+	fields = strings.Fields(inodes)
+	if len(fields) < 6 {
+		return mountUsage{}, fmt.Errorf("unexpected df output %q", inodes)
+	}
+	if fields[1] == "0" || fields[1] == "-" {
+		usage.noInodes = true
+		return usage, nil
+	}
+	if usage.freeInodes, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+		return mountUsage{}, fmt.Errorf("unexpected df output %q", inodes)
+	}
+	return usage, nil
+}
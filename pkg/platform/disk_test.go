@@ -0,0 +1,100 @@
+package platform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+// dfExecutor answers df with the usage of the mounts, paths are on the
+// filesystem of their longest mounted prefix
+func dfExecutor(space, inodes map[string]string, missing ...string) cmdexec.Executor {
+	return &cmdexec.MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			fields := strings.Fields(args[1])
+			p := fields[2]
+			for _, m := range missing {
+				if p == m {
+					return "", fmt.Errorf("df: %s: No such file or directory", p)
+				}
+			}
+			mount := "/"
+			for m := range space {
+				if (p == m || strings.HasPrefix(p, m+"/")) && len(m) > len(mount) {
+					mount = m
+				}
+			}
+			if fields[1] == "-Pm" {
+				return space[mount] + " " + mount + "\n", nil
+			}
+			return inodes[mount] + " " + mount + "\n", nil
+		},
+	}
+}
+
+func TestCheckMounts(t *testing.T) {
+	reqs := []MountRequirement{
+		{Path: "/", MinFreeMB: 2048, MinFreeInodes: 50000},
+		{Path: "/var", MinFreeMB: 5120, MinFreeInodes: 100000},
+		{Path: "/opt", MinFreeMB: 2048, MinFreeInodes: 50000},
+		{Path: "/home/ubuntu/pf9", MinFreeMB: 500, MinFreeInodes: 10000},
+	}
+
+	cases := map[string]struct {
+		space, inodes map[string]string
+		want          map[string]string
+	}{
+		// Everything on / adds up to 9716 MB and 210000 inodes
+		"SingleFilesystem": {
+			space:  map[string]string{"/": "/dev/sda1 40000 20000 20000 50%"},
+			inodes: map[string]string{"/": "/dev/sda1 2000000 1000000 1000000 50%"},
+			want:   map[string]string{"/": ""},
+		},
+		"SingleFilesystemTooSmall": {
+			space:  map[string]string{"/": "/dev/sda1 40000 31000 9000 78%"},
+			inodes: map[string]string{"/": "/dev/sda1 2000000 1000000 1000000 50%"},
+			want:   map[string]string{"/": "filesystem / holding /, /var, /opt, /home/ubuntu/pf9: 9000 MB free, 9716 MB needed"},
+		},
+		"FullVar": {
+			space: map[string]string{
+				"/":    "/dev/sda1 40000 20000 20000 50%",
+				"/var": "/dev/sdb1 10000 9000 1000 90%",
+			},
+			inodes: map[string]string{
+				"/":    "/dev/sda1 2000000 1000000 1000000 50%",
+				"/var": "/dev/sdb1 600000 590000 10000 99%",
+			},
+			want: map[string]string{
+				"/":    "",
+				"/var": "filesystem /var holding /var: 1000 MB free, 5120 MB needed, 10000 inodes free, 100000 needed",
+			},
+		},
+		// btrfs doesn't report inodes
+		"NoInodes": {
+			space:  map[string]string{"/": "/dev/sda1 40000 20000 20000 50%"},
+			inodes: map[string]string{"/": "/dev/sda1 0 0 0 -"},
+			want:   map[string]string{"/": ""},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// The work directory doesn't exist yet, its parent is checked
+			checks := CheckMounts(dfExecutor(tc.space, tc.inodes, "/home/ubuntu/pf9"), reqs)
+			got := make(map[string]string)
+			for _, check := range checks {
+				mount := strings.TrimPrefix(check.Name, "Disk space and inodes check of ")
+				assert.Equal(t, check.Err == nil, check.Result)
+				assert.True(t, check.Mandatory)
+				got[mount] = ""
+				if check.Err != nil {
+					got[mount] = check.Err.Error()
+				}
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package platform
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// Limits of the DNS options of a node, CoreDNS and the pods inherit them
+const (
+	// MaxNdots is the largest ndots before every lookup of an external name
+	// goes through the search domains first
+	MaxNdots = 5
+	// MaxSearchDomains is how many search domains of the node fit in the
+	// 6 of the kubelet with the 3 domains of the cluster
+	MaxSearchDomains = 3
+)
+
+// resolvedUpstream is the resolv.conf of systemd-resolved listing the
+// upstream servers, which the stub resolver 127.0.0.53 forwards to
+const resolvedUpstream = "/run/systemd/resolve/resolv.conf"
+
+// resolvConf is the content of a resolv.conf
+type resolvConf struct {
+	Nameservers []string
+	Search      []string
+	Ndots       int
+}
+
+// parseResolvConf parses a resolv.conf, ndots is 1 unless it is set
+func parseResolvConf(content string) resolvConf {
+	conf := resolvConf{Ndots: 1}
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			conf.Nameservers = append(conf.Nameservers, fields[1])
+		case "search", "domain":
+			// The last of search and domain wins
+			conf.Search = fields[1:]
+		case "options":
+			for _, option := range fields[1:] {
+				if strings.HasPrefix(option, "ndots:") {
+					if ndots, err := strconv.Atoi(strings.TrimPrefix(option, "ndots:")); err == nil {
+						conf.Ndots = ndots
+					}
+				}
+			}
+		}
+	}
+	return conf
+}
+
+// upstreamNameservers returns the nameservers which aren't on the loopback
+func (c resolvConf) upstreamNameservers() []string {
+	var upstream []string
+	for _, ns := range c.Nameservers {
+		if ip := net.ParseIP(ns); ip == nil || !ip.IsLoopback() {
+			upstream = append(upstream, ns)
+		}
+	}
+	return upstream
+}
+
+// CheckDNS checks the resolver of the node has upstream nameservers and
+// resolves duHost when it is a name. Nameservers on the loopback only are
+// accepted for the stub of systemd-resolved when it has upstream servers, as
+// CoreDNS forwards to the upstream ones. Any other local resolver makes
+// CoreDNS forward the queries to itself.
+func CheckDNS(exec cmdexec.Executor, duHost string) Check {
+	name := "DNS resolver check"
+	fail := func(err error) Check {
+		return Check{CheckIDDNSResolver, name, true, false, err, err.Error()}
+	}
+
+	out, err := exec.RunWithStdout("cat", "/etc/resolv.conf")
+	if err != nil {
+		return fail(fmt.Errorf("unable to read /etc/resolv.conf: %w", err))
+	}
+	conf := parseResolvConf(out)
+	if len(conf.Nameservers) == 0 {
+		return fail(fmt.Errorf("/etc/resolv.conf has no nameserver, the node can't resolve the DU or pull images"))
+	}
+	if len(conf.upstreamNameservers()) == 0 {
+		if len(conf.Nameservers) != 1 || conf.Nameservers[0] != "127.0.0.53" {
+			return fail(fmt.Errorf("/etc/resolv.conf only has the local nameservers %s, CoreDNS would forward the queries to itself. "+
+				"List the upstream nameservers instead", strings.Join(conf.Nameservers, ", ")))
+		}
+		upstream, err := exec.RunWithStdout("cat", resolvedUpstream)
+		if err != nil {
+			return fail(fmt.Errorf("/etc/resolv.conf points to systemd-resolved but %s can't be read: %w", resolvedUpstream, err))
+		}
+		if len(parseResolvConf(upstream).upstreamNameservers()) == 0 {
+			return fail(fmt.Errorf("systemd-resolved has no upstream nameserver in %s, set DNS= in /etc/systemd/resolved.conf or on the interface", resolvedUpstream))
+		}
+	}
+
+	if duHost != "" && net.ParseIP(duHost) == nil {
+		if _, err := exec.RunArgs("getent", "hosts", duHost); err != nil {
+			return fail(fmt.Errorf("the node can't resolve the DU %s, check the nameservers of /etc/resolv.conf", duHost))
+		}
+	}
+	zap.S().Debugf("Nameservers of the node: %s", strings.Join(conf.Nameservers, ", "))
+	return Check{CheckIDDNSResolver, name, true, true, nil, ""}
+}
+
+// CheckDNSOptions checks the search domains and ndots of /etc/resolv.conf,
+// which the pods inherit
+func CheckDNSOptions(exec cmdexec.Executor) Check {
+	name := "DNS options check"
+	fail := func(err error) Check {
+		return Check{CheckIDDNSOptions, name, false, false, err, err.Error()}
+	}
+
+	out, err := exec.RunWithStdout("cat", "/etc/resolv.conf")
+	if err != nil {
+		return fail(fmt.Errorf("unable to read /etc/resolv.conf: %w", err))
+	}
+	conf := parseResolvConf(out)
+	if conf.Ndots > MaxNdots {
+		return fail(fmt.Errorf("/etc/resolv.conf sets ndots:%d, above %d every lookup of an external name tries the search domains first", conf.Ndots, MaxNdots))
+	}
+	if len(conf.Search) > MaxSearchDomains {
+		return fail(fmt.Errorf("/etc/resolv.conf has %d search domains, the kubelet drops those after the first %d from the pods", len(conf.Search), MaxSearchDomains))
+	}
+	return Check{CheckIDDNSOptions, name, false, true, nil, ""}
+}
@@ -0,0 +1,109 @@
+package platform
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	conf := parseResolvConf(`# Generated by NetworkManager
+nameserver 10.0.0.2
+nameserver 127.0.0.1 ; local cache
+domain example.com
+search corp.example.com example.com
+options edns0 ndots:2
+`)
+	assert.Equal(t, []string{"10.0.0.2", "127.0.0.1"}, conf.Nameservers)
+	assert.Equal(t, []string{"corp.example.com", "example.com"}, conf.Search)
+	assert.Equal(t, 2, conf.Ndots)
+	assert.Equal(t, []string{"10.0.0.2"}, conf.upstreamNameservers())
+	assert.Equal(t, 1, parseResolvConf("").Ndots)
+}
+
+func TestCheckDNS(t *testing.T) {
+	cases := map[string]struct {
+		resolvConf string
+		upstream   string
+		resolves   bool
+		err        string
+	}{
+		"Upstream": {resolvConf: "nameserver 10.0.0.2\n", resolves: true},
+		"SystemdResolved": {
+			resolvConf: "nameserver 127.0.0.53\noptions edns0\n", upstream: "nameserver 10.0.0.2\n", resolves: true,
+		},
+		"NoNameserver": {
+			resolvConf: "search example.com\n",
+			err:        "/etc/resolv.conf has no nameserver, the node can't resolve the DU or pull images",
+		},
+		"ResolvedWithoutUpstream": {
+			resolvConf: "nameserver 127.0.0.53\n", upstream: "# No DNS servers known.\n",
+			err: "systemd-resolved has no upstream nameserver in /run/systemd/resolve/resolv.conf, set DNS= in /etc/systemd/resolved.conf or on the interface",
+		},
+		"LocalResolver": {
+			resolvConf: "nameserver 127.0.0.1\n",
+			err:        "/etc/resolv.conf only has the local nameservers 127.0.0.1, CoreDNS would forward the queries to itself. List the upstream nameservers instead",
+		},
+		"DUNotResolving": {
+			resolvConf: "nameserver 10.0.0.2\n",
+			err:        "the node can't resolve the DU du.example.com, check the nameservers of /etc/resolv.conf",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					switch {
+					case name == "cat" && args[0] == "/etc/resolv.conf":
+						return tc.resolvConf, nil
+					case name == "cat":
+						return tc.upstream, nil
+					case name == "getent" && !tc.resolves:
+						return "", errors.New("exit status 2")
+					}
+					return "", nil
+				},
+			}
+			check := CheckDNS(exec, "du.example.com")
+			assert.True(t, check.Mandatory)
+			assert.Equal(t, tc.err == "", check.Result)
+			if tc.err != "" {
+				assert.EqualError(t, check.Err, tc.err)
+			}
+		})
+	}
+}
+
+func TestCheckDNSOptions(t *testing.T) {
+	cases := map[string]struct {
+		resolvConf string
+		err        string
+	}{
+		"Default": {resolvConf: "nameserver 10.0.0.2\nsearch example.com\n"},
+		"Ndots": {
+			resolvConf: "options ndots:15\n",
+			err:        "/etc/resolv.conf sets ndots:15, above 5 every lookup of an external name tries the search domains first",
+		},
+		"SearchDomains": {
+			resolvConf: "search a.example.com b.example.com c.example.com example.com\n",
+			err:        "/etc/resolv.conf has 4 search domains, the kubelet drops those after the first 3 from the pods",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					return tc.resolvConf, nil
+				},
+			}
+			check := CheckDNSOptions(exec)
+			assert.False(t, check.Mandatory)
+			assert.Equal(t, tc.err == "", check.Result)
+			if tc.err != "" {
+				assert.EqualError(t, check.Err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package platform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// hostnameLabel is a label of an RFC 1123 host name as accepted by k8s for node names
+var hostnameLabel = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// ValidHostname checks name is a lowercase RFC 1123 host name, which nodelet
+// and kubelet need to register the node
+func ValidHostname(name string) error {
+	if name == "" {
+		return fmt.Errorf("hostname is empty")
+	}
+	if len(name) > 253 {
+		return fmt.Errorf("hostname %s is longer than 253 characters", name)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if len(label) > 63 {
+			return fmt.Errorf("hostname %s has a label longer than 63 characters", name)
+		}
+		if !hostnameLabel.MatchString(label) {
+			return fmt.Errorf("hostname %s is not a valid RFC 1123 name, it should only have lowercase letters, digits, '-' and '.'", name)
+		}
+	}
+	return nil
+}
+
+// hostsHasEntry reports whether the /etc/hosts content maps hostname to an
+// address, the names being case-insensitive
+func hostsHasEntry(hosts, hostname string) bool {
+	for _, line := range strings.Split(hosts, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, name := range fields[1:] {
+			if strings.EqualFold(name, hostname) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckHostname checks the hostname of the node is valid and resolves, either
+// with an entry in /etc/hosts or with the DNS through hostname -f. With fix, an
+// entry is added to /etc/hosts for the primary IP of the node when it doesn't
+// resolve. An invalid hostname is never changed as it is part of the identity
+// of the node.
+func CheckHostname(exec cmdexec.Executor, fix bool) Check {
+	name := "Hostname and /etc/hosts check"
+	fail := func(err error) Check {
+		return Check{CheckIDHostname, name, true, false, err, err.Error()}
+	}
+
+	out, err := exec.RunWithStdout("hostname")
+	if err != nil {
+		return fail(fmt.Errorf("unable to get the hostname: %w", err))
+	}
+	hostname := strings.TrimSpace(out)
+	if err := ValidHostname(hostname); err != nil {
+		return fail(err)
+	}
+
+	hosts, err := exec.RunWithStdout("cat", "/etc/hosts")
+	if err != nil {
+		return fail(fmt.Errorf("unable to read /etc/hosts: %w", err))
+	}
+	if hostsHasEntry(hosts, hostname) {
+		zap.S().Debugf("Hostname %s has an entry in /etc/hosts", hostname)
+		return Check{CheckIDHostname, name, true, true, nil, ""}
+	}
+	fqdn, err := exec.RunWithStdout("hostname", "-f")
+	if err == nil && strings.TrimSpace(fqdn) != "" {
+		zap.S().Debugf("Hostname %s resolves to %s", hostname, strings.TrimSpace(fqdn))
+		return Check{CheckIDHostname, name, true, true, nil, ""}
+	}
+
+	if !fix {
+		return fail(fmt.Errorf("hostname %s resolves neither with /etc/hosts nor with the DNS, add it to /etc/hosts or use --fix-hostname", hostname))
+	}
+	if err := addHostsEntry(exec, hostname); err != nil {
+		return fail(fmt.Errorf("unable to add hostname %s to /etc/hosts: %w", hostname, err))
+	}
+	return Check{CheckIDHostname, name, true, true, nil, ""}
+}
+
+func addHostsEntry(exec cmdexec.Executor, hostname string) error {
+	out, err := exec.RunWithStdout("bash", "-c", "hostname -I")
+	if err != nil {
+		return err
+	}
+	ips := strings.Fields(out)
+	if len(ips) == 0 {
+		return fmt.Errorf("no IP address found on the node")
+	}
+	entry := fmt.Sprintf("%s %s", ips[0], hostname)
+	zap.S().Debugf("Adding %s to /etc/hosts", entry)
+	_, err = exec.RunWithStdout("bash", "-c", fmt.Sprintf("echo %s | tee -a /etc/hosts > /dev/null", cmdexec.ShellQuote(entry)))
+	return err
+}
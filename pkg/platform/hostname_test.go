@@ -0,0 +1,94 @@
+package platform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidHostname(t *testing.T) {
+	cases := map[string]bool{
+		"worker-1":                      true,
+		"worker-1.example.com":          true,
+		"Worker-1":                      false,
+		"worker_1":                      false,
+		"-worker":                       false,
+		"worker.":                       false,
+		"":                              false,
+		strings.Repeat("a", 64):         false,
+		strings.Repeat("a.", 127) + "a": false,
+	}
+	for name, valid := range cases {
+		assert.Equal(t, valid, ValidHostname(name) == nil, name)
+	}
+}
+
+func TestCheckHostname(t *testing.T) {
+	cases := map[string]struct {
+		hostname   string
+		hosts      string
+		resolves   bool
+		fix        bool
+		wantResult bool
+		wantAdded  string
+		err        string
+	}{
+		"Valid": {
+			hostname: "worker-1", hosts: "127.0.0.1 localhost\n10.0.0.1 worker-1.example.com worker-1\n", resolves: true, wantResult: true,
+		},
+		"InvalidName": {
+			hostname: "Worker_1", hosts: "10.0.0.1 Worker_1\n", resolves: true,
+			err: "hostname Worker_1 is not a valid RFC 1123 name, it should only have lowercase letters, digits, '-' and '.'",
+		},
+		"EntryOtherCase": {
+			hostname: "worker-1", hosts: "10.0.0.1 WORKER-1\n", wantResult: true,
+		},
+		"ResolvedByDNS": {
+			hostname: "worker-1", hosts: "127.0.0.1 localhost\n", resolves: true, wantResult: true,
+		},
+		// An entry commented out doesn't count
+		"NotResolving": {
+			hostname: "worker-1", hosts: "127.0.0.1 localhost\n# 10.0.0.1 worker-1\n",
+			err: "hostname worker-1 resolves neither with /etc/hosts nor with the DNS, add it to /etc/hosts or use --fix-hostname",
+		},
+		"FixedEntry": {
+			hostname: "worker-1", hosts: "127.0.0.1 localhost\n", fix: true, wantResult: true,
+			wantAdded: "echo '10.0.0.1 worker-1' | tee -a /etc/hosts > /dev/null",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var added string
+			exec := &cmdexec.MockExecutor{
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					switch {
+					case name == "hostname" && len(args) == 0:
+						return tc.hostname + "\n", nil
+					case name == "hostname":
+						if !tc.resolves {
+							return "", fmt.Errorf("hostname: Name or service not known")
+						}
+						return tc.hostname + ".example.com\n", nil
+					case name == "cat":
+						return tc.hosts, nil
+					case args[1] == "hostname -I":
+						return "10.0.0.1 172.17.0.1 \n", nil
+					}
+					added = args[1]
+					return "", nil
+				},
+			}
+			check := CheckHostname(exec, tc.fix)
+			assert.Equal(t, tc.wantResult, check.Result)
+			assert.True(t, check.Mandatory)
+			assert.Equal(t, tc.wantAdded, added)
+			if tc.err != "" {
+				assert.EqualError(t, check.Err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package pmk
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+)
+
+// AttachCandidate is a host of resmgr which can be attached to a cluster
+type AttachCandidate struct {
+	HostID   string
+	Hostname string
+	IP       string
+	OS       string
+}
+
+// AttachCandidates lists the hosts which are authorized, responding and
+// not part of any cluster, for picking the nodes to attach from.
+func AttachCandidates(c client.Client, auth keystone.KeystoneAuth) ([]AttachCandidate, error) {
+	hosts, err := c.Resmgr.GetHosts(auth.Token)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the hosts of the DU: %w", err)
+	}
+	return attachCandidates(hosts, c.Qbert.GetAllNodes(auth.Token, auth.ProjectID)), nil
+}
+
+func attachCandidates(hosts []resmgr.HostInfo, nodes []qbert.Node) []AttachCandidate {
+	qbertNodes := make(map[string]qbert.Node)
+	for _, node := range nodes {
+		qbertNodes[node.Uuid] = node
+	}
+
+	var candidates []AttachCandidate
+	for _, host := range hosts {
+		if !host.Info.Responding || !hasString(host.Roles, "pf9-kube") {
+			continue
+		}
+		node, found := qbertNodes[host.ID]
+		if found && node.ClusterUuid != "" {
+			continue
+		}
+		ip := node.PrimaryIp
+		if ip == "" && len(host.Extensions.IPAddress.Data) > 0 {
+			ip = host.Extensions.IPAddress.Data[0]
+		}
+		if ip == "" {
+			continue
+		}
+		candidates = append(candidates, AttachCandidate{
+			HostID:   host.ID,
+			Hostname: host.Info.Hostname,
+			IP:       ip,
+			OS:       host.Info.OSInfo,
+		})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Hostname < candidates[j].Hostname
+	})
+	return candidates
+}
+
+func hasString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
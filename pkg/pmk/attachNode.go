@@ -0,0 +1,514 @@
+package pmk
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/jobs"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/platform9/pf9ctl/pkg/ssh"
+	"github.com/platform9/pf9ctl/pkg/ui"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// AttachNodeRequest describes the nodes to be attached to a cluster
+type AttachNodeRequest struct {
+	ClusterUuid      string
+	MasterIDs        []string
+	WorkerIDs        []string
+	AllowEvenMasters bool
+}
+
+// AttachNodesInput are the nodes to attach to a cluster, given by its name or
+// its UUID, along with their kubelet overrides
+type AttachNodesInput struct {
+	ClusterName      string
+	ClusterUuid      string
+	MasterIPs        []string
+	WorkerIPs        []string
+	AllowEvenMasters bool
+	// Overrides are the overrides of the nodes by IP, the settings they don't
+	// set are taken from DefaultOverrides
+	Overrides        map[string]NodeOverrides
+	DefaultOverrides NodeOverrides
+	// Region is the region of the DU the nodes are attached with
+	Region string
+	// SSH is how to reach the nodes to check they were prepared with the DU
+	// of Region, the check is skipped when nil
+	SSH *objects.NodeConfig
+	// ContinueOnError attaches the nodes which can be, instead of none, when
+	// some of them can't be
+	ContinueOnError bool
+}
+
+// UnresolvedNode is a node which can't be attached and why
+type UnresolvedNode struct {
+	IP     string
+	Role   string
+	Reason string
+}
+
+// UnresolvedNodesError is returned for the nodes which can't be attached.
+// With ContinueOnError NewAttachJob returns it along with the job attaching
+// the other nodes.
+type UnresolvedNodesError struct {
+	Nodes []UnresolvedNode
+}
+
+func (e *UnresolvedNodesError) Error() string {
+	var nodes []string
+	for _, node := range e.Nodes {
+		nodes = append(nodes, fmt.Sprintf("%s (%s): %s", node.IP, node.Role, node.Reason))
+	}
+	return fmt.Sprintf("Unable to attach %d node(s): %s", len(e.Nodes), strings.Join(nodes, "; "))
+}
+
+// NewAttachJob resolves the cluster and the nodes of in, validates the attach
+// and applies the kubelet overrides of the nodes. It returns the job attaching
+// the nodes, to be run with RunJob. With ContinueOnError the nodes which can't
+// be attached are left out of the job and returned as an *UnresolvedNodesError
+// along with it.
+func NewAttachJob(ctx context.Context, c client.Client, auth keystone.KeystoneAuth, fqdn string, in AttachNodesInput) (*jobs.Job, error) {
+	if len(in.MasterIPs) == 0 && len(in.WorkerIPs) == 0 {
+		return nil, fmt.Errorf("no nodes were specified to be attached to the cluster")
+	}
+	if in.DefaultOverrides.NodeIP != "" && len(in.MasterIPs)+len(in.WorkerIPs) > 1 {
+		return nil, fmt.Errorf("the node IP can only be overridden for all the nodes when attaching a single node")
+	}
+	in.MasterIPs, in.WorkerIPs = util.NormalizeIPs(in.MasterIPs), util.NormalizeIPs(in.WorkerIPs)
+
+	clusterName, clusterUuid := in.ClusterName, in.ClusterUuid
+	var err error
+	if clusterUuid != "" {
+		if clusterName, err = c.Qbert.CheckClusterExistsWithUuid(clusterUuid, auth.ProjectID, auth.Token); err != nil {
+			return nil, fmt.Errorf("unable to verify cluster using uuid %s", err.Error())
+		} else if clusterName == "" {
+			return nil, fmt.Errorf("cluster with given uuid does not exist")
+		}
+	} else {
+		_, clusterUuid, _, _ = c.Qbert.CheckClusterExists(clusterName, auth.ProjectID, auth.Token)
+	}
+	if _, _, clusterStatus, _ := c.Qbert.CheckClusterExists(clusterName, auth.ProjectID, auth.Token); clusterStatus != "ok" {
+		return nil, fmt.Errorf("Cluster is not ready. cluster status is %v", clusterStatus)
+	}
+
+	masters, unresolved := resolveAttachHosts(c.Resmgr, auth.Token, in.MasterIPs, "master")
+	workers, unresolvedWorkers := resolveAttachHosts(c.Resmgr, auth.Token, in.WorkerIPs, "worker")
+	unresolved = append(unresolved, unresolvedWorkers...)
+	if len(unresolved) > 0 && !in.ContinueOnError {
+		return nil, &UnresolvedNodesError{Nodes: unresolved}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if in.SSH != nil {
+		failed, err := checkNodesDU(auth, fqdn, in.Region, *in.SSH, append(append([]jobs.Host{}, masters...), workers...))
+		if err != nil {
+			return nil, err
+		}
+		if len(failed) > 0 && !in.ContinueOnError {
+			return nil, &UnresolvedNodesError{Nodes: failed}
+		}
+		masters, workers = withoutUnresolved(masters, failed), withoutUnresolved(workers, failed)
+		unresolved = append(unresolved, failed...)
+	}
+	if failed := checkNodesIPStack(c, auth, clusterUuid, in.SSH, append(append([]jobs.Host{}, masters...), workers...)); len(failed) > 0 {
+		if !in.ContinueOnError {
+			return nil, &UnresolvedNodesError{Nodes: failed}
+		}
+		masters, workers = withoutUnresolved(masters, failed), withoutUnresolved(workers, failed)
+		unresolved = append(unresolved, failed...)
+	}
+	if len(masters)+len(workers) == 0 {
+		return nil, &UnresolvedNodesError{Nodes: unresolved}
+	}
+	var masterHostIDs, workerHostIDs []string
+	for _, host := range masters {
+		masterHostIDs = append(masterHostIDs, host.HostID)
+	}
+	for _, host := range workers {
+		workerHostIDs = append(workerHostIDs, host.HostID)
+	}
+
+	validation := ui.StartPhase("Validating node(s)")
+	warnings, err := ValidateAttachNode(c, auth, AttachNodeRequest{
+		ClusterUuid:      clusterUuid,
+		MasterIDs:        masterHostIDs,
+		WorkerIDs:        workerHostIDs,
+		AllowEvenMasters: in.AllowEvenMasters,
+	})
+	if err != nil {
+		validation.Stop()
+		return nil, fmt.Errorf("Unable to attach node(s) to cluster %s: %s", clusterName, err.Error())
+	}
+	for _, warning := range warnings {
+		validation.Warn(warning)
+	}
+	validation.Succeed("Node(s) validated")
+
+	trackEvent(c, auth, client.Event{Name: segmentEventAttach, Phase: phaseStart, Status: checkPass})
+	hosts := append(append([]jobs.Host{}, workers...), masters...)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := applyNodeOverrides(c, auth, fqdn, hosts, in.Overrides, in.DefaultOverrides); err != nil {
+		return nil, err
+	}
+
+	job, err := jobs.New(jobs.AttachNode, fqdn, auth.ProjectID, clusterName, clusterUuid, hosts)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create attach-node job: %s", err.Error())
+	}
+	if len(unresolved) > 0 {
+		return job, &UnresolvedNodesError{Nodes: unresolved}
+	}
+	return job, nil
+}
+
+// resolveAttachHosts looks up the host IDs of the nodes of role by IP. The
+// nodes which can't be resolved are returned with the reason.
+func resolveAttachHosts(r resmgr.Resmgr, token string, ips []string, role string) ([]jobs.Host, []UnresolvedNode) {
+	var hosts []jobs.Host
+	var unresolved []UnresolvedNode
+	for _, ip := range ips {
+		switch hostIDs := r.GetHostId(token, []string{ip}); len(hostIDs) {
+		case 0:
+			unresolved = append(unresolved, UnresolvedNode{IP: ip, Role: role, Reason: "not registered with the DU, run prep-node on it first"})
+		case 1:
+			hosts = append(hosts, jobs.Host{IP: ip, HostID: hostIDs[0], Role: role})
+		default:
+			unresolved = append(unresolved, UnresolvedNode{IP: ip, Role: role,
+				Reason: fmt.Sprintf("IP shared by hosts %s of the DU, decommission the stale ones", strings.Join(hostIDs, ", "))})
+		}
+	}
+	return hosts, unresolved
+}
+
+// withoutUnresolved returns the hosts which aren't unresolved
+func withoutUnresolved(hosts []jobs.Host, unresolved []UnresolvedNode) []jobs.Host {
+	var kept []jobs.Host
+	for _, host := range hosts {
+		found := false
+		for _, node := range unresolved {
+			found = found || node.IP == host.IP
+		}
+		if !found {
+			kept = append(kept, host)
+		}
+	}
+	return kept
+}
+
+// checkNodesDU checks the hosts were prepared with the DU of region, nodeCfg
+// is how to reach them over SSH. The hosts which weren't are returned with
+// the reason.
+func checkNodesDU(auth keystone.KeystoneAuth, fqdn, region string, nodeCfg objects.NodeConfig, hosts []jobs.Host) ([]UnresolvedNode, error) {
+	phase := ui.StartPhase("Checking the DU of the node(s)")
+	defer phase.Stop()
+	du, err := keystone.FetchRegionFQDN(fqdn, region, auth)
+	if err != nil {
+		phase.Fail("Unable to get the FQDN of the region")
+		return nil, fmt.Errorf("Unable to get the FQDN of region %s: %w", region, err)
+	}
+	ssh.SudoPassword = nodeCfg.SudoPassword
+	var failed []UnresolvedNode
+	for _, host := range hosts {
+		nodeCfg.IPs = []string{host.IP}
+		executor, err := cmdexec.GetExecutor("", nodeCfg)
+		if err == nil {
+			err = CheckNodeDU(executor, host.IP, du)
+			cmdexec.Close(executor)
+		}
+		if err != nil {
+			phase.Warn(err.Error())
+			failed = append(failed, UnresolvedNode{IP: host.IP, Role: host.Role, Reason: err.Error()})
+			continue
+		}
+		phase.Step(fmt.Sprintf("Node %s prepared with %s", host.IP, du))
+	}
+	if len(failed) > 0 {
+		phase.Fail("Node(s) not prepared with the DU of the config")
+		return failed, nil
+	}
+	phase.Succeed("Node(s) prepared with the DU of the config")
+	return nil, nil
+}
+
+// checkNodesIPStack checks the hosts have the addresses and, when nodeCfg is
+// set to reach them over SSH, the settings an IPv6 or dual-stack cluster
+// needs. The hosts which don't are returned with the reason.
+func checkNodesIPStack(c client.Client, auth keystone.KeystoneAuth, clusterUuid string, nodeCfg *objects.NodeConfig, hosts []jobs.Host) []UnresolvedNode {
+	spec, err := c.Qbert.GetClusterSpec(clusterUuid, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Debugf("Unable to get the spec of cluster %s, the IP stack isn't checked: %s", clusterUuid, err)
+		return nil
+	}
+	stack := ClusterIPStack(spec)
+	if !stack.IPv6 {
+		return nil
+	}
+
+	phase := ui.StartPhase(fmt.Sprintf("Checking the node(s) for the %s cluster", stack))
+	defer phase.Stop()
+	var failed []UnresolvedNode
+	for _, host := range hosts {
+		info, err := c.Resmgr.GetHostInfo(auth.Token, host.HostID)
+		if err == nil {
+			err = CheckHostIPStack(info.Extensions.IPAddress.Data, stack)
+		}
+		if err == nil && nodeCfg != nil {
+			cfg := *nodeCfg
+			cfg.IPs = []string{host.IP}
+			var executor cmdexec.Executor
+			if executor, err = cmdexec.GetExecutor("", cfg); err == nil {
+				if missing := CheckNodeIPStack(executor, stack); len(missing) > 0 {
+					err = fmt.Errorf("the node isn't ready for the %s cluster: %s", stack, strings.Join(missing, ", "))
+				}
+				cmdexec.Close(executor)
+			}
+		}
+		if err != nil {
+			phase.Warn(fmt.Sprintf("Node %s: %s", host.IP, err))
+			failed = append(failed, UnresolvedNode{IP: host.IP, Role: host.Role, Reason: err.Error()})
+			continue
+		}
+		phase.Step(fmt.Sprintf("Node %s is ready for the %s cluster", host.IP, stack))
+	}
+	if len(failed) > 0 {
+		phase.Fail(fmt.Sprintf("Node(s) not ready for the %s cluster", stack))
+		return failed
+	}
+	phase.Succeed(fmt.Sprintf("Node(s) ready for the %s cluster", stack))
+	return nil
+}
+
+// applyNodeOverrides writes the kubelet overrides of the hosts before they are
+// attached, overrides holds the ones of each host by IP
+func applyNodeOverrides(c client.Client, auth keystone.KeystoneAuth, fqdn string, hosts []jobs.Host, overrides map[string]NodeOverrides, defaults NodeOverrides) error {
+	var phase *ui.Phase
+	for _, host := range hosts {
+		nodeOverrides := overrides[host.IP].Merge(defaults)
+		if nodeOverrides.Empty() {
+			continue
+		}
+		if phase == nil {
+			phase = ui.StartPhase("Applying node overrides")
+			defer phase.Stop()
+		}
+		info, err := c.Resmgr.GetHostInfo(auth.Token, host.HostID)
+		if err == nil {
+			err = nodeOverrides.Validate(info.Extensions.IPAddress.Data)
+		}
+		if err == nil {
+			err = ApplyNodeOverrides(fqdn, auth, host.HostID, nodeOverrides)
+		}
+		if err != nil {
+			phase.Fail(fmt.Sprintf("Unable to apply the overrides of node %s", host.IP))
+			return fmt.Errorf("Unable to apply the overrides of node %s: %s", host.IP, err.Error())
+		}
+		phase.Step(fmt.Sprintf("Overrides of node %s applied", host.IP))
+	}
+	if phase != nil {
+		phase.Succeed("Node overrides applied")
+	}
+	return nil
+}
+
+// ValidateAttachNode checks the request against the current state of the cluster
+// before it is sent to qbert. Warnings do not block the attach, an error explains
+// what has to be fixed first.
+func ValidateAttachNode(c client.Client, auth keystone.KeystoneAuth, req AttachNodeRequest) ([]string, error) {
+	// Without the nodes, hosts of other clusters and the masters already
+	// there would go unnoticed
+	allNodes, err := c.Qbert.ListNodes(auth.Token, auth.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the nodes of qbert: %w", err)
+	}
+	clusterNodes := ClusterNodes(allNodes, req.ClusterUuid)
+
+	hostIDs := append(append([]string{}, req.MasterIDs...), req.WorkerIDs...)
+	if err := checkNotAttached(allNodes, req.ClusterUuid, hostIDs); err != nil {
+		return nil, err
+	}
+
+	existingMasters := 0
+	for _, node := range clusterNodes {
+		if node.IsMaster == 1 {
+			existingMasters++
+		}
+	}
+	var warnings []string
+	warning, err := checkMasterCount(existingMasters, len(req.MasterIDs), req.AllowEvenMasters)
+	if err != nil {
+		return nil, err
+	}
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+
+	if err := checkOSConsistency(c, auth, clusterNodes, hostIDs); err != nil {
+		return nil, err
+	}
+	return warnings, nil
+}
+
+// checkNotAttached fails if any of the hosts is already part of a cluster.
+func checkNotAttached(allNodes []qbert.Node, clusterUuid string, hostIDs []string) error {
+	for _, hostID := range hostIDs {
+		for _, node := range allNodes {
+			if node.Uuid != hostID || node.ClusterUuid == "" {
+				continue
+			}
+			if node.ClusterUuid == clusterUuid {
+				return fmt.Errorf("node %s is already attached to this cluster", node.PrimaryIp)
+			}
+			return fmt.Errorf("node %s is attached to cluster %s, detach it first using 'pf9ctl detach-node'", node.PrimaryIp, node.ClusterName)
+		}
+	}
+	return nil
+}
+
+// checkMasterCount validates the number of masters the cluster ends up with.
+// Going from one to two masters makes etcd lose quorum as soon as either of them
+// goes down, so it has to be explicitly allowed.
+func checkMasterCount(existing, adding int, allowEven bool) (string, error) {
+	if adding == 0 {
+		return "", nil
+	}
+	total := existing + adding
+	if existing == 1 && total == 2 && !allowEven {
+		return "", fmt.Errorf("attaching a second master leaves the cluster without etcd fault tolerance until a third master is attached, use --allow-even-masters to continue")
+	}
+	if total%2 == 0 {
+		return fmt.Sprintf("cluster will have %d masters, an even number of masters does not improve etcd fault tolerance", total), nil
+	}
+	return "", nil
+}
+
+// checkOSConsistency fails if the hosts run a different OS release than the
+// nodes already in the cluster.
+func checkOSConsistency(c client.Client, auth keystone.KeystoneAuth, clusterNodes []qbert.Node, hostIDs []string) error {
+	clusterOS := ""
+	for _, node := range clusterNodes {
+		host, err := c.Resmgr.GetHostInfo(auth.Token, node.Uuid)
+		if err != nil {
+			zap.S().Debugf("Unable to get host info of cluster node %s: %s", node.PrimaryIp, err)
+			continue
+		}
+		if clusterOS = osRelease(host.Info.OSInfo); clusterOS != "" {
+			break
+		}
+	}
+	if clusterOS == "" {
+		zap.S().Debug("Unable to determine the OS of the cluster nodes, skipping OS consistency check")
+		return nil
+	}
+
+	for _, hostID := range hostIDs {
+		host, err := c.Resmgr.GetHostInfo(auth.Token, hostID)
+		if err != nil {
+			return fmt.Errorf("unable to get host info of node %s: %w", hostID, err)
+		}
+		if hostOS := osRelease(host.Info.OSInfo); hostOS != clusterOS {
+			return fmt.Errorf("node %s runs %q while the cluster nodes run %q, nodes of a cluster must run the same OS release",
+				host.Info.Hostname, host.Info.OSInfo, clusterOS)
+		}
+	}
+	return nil
+}
+
+// osRelease reduces the OS info reported by the hostagent, like "Ubuntu 20.04 focal"
+// or "CentOS Linux 7.9.2009 Core", to the distribution and its major version.
+func osRelease(osInfo string) string {
+	var name []string
+	for _, field := range strings.Fields(strings.ToLower(osInfo)) {
+		if field[0] >= '0' && field[0] <= '9' {
+			return strings.Join(append(name, strings.Split(field, ".")[0]), " ")
+		}
+		name = append(name, field)
+	}
+	return strings.Join(name, " ")
+}
+
+// MasterHealthTimeout is how long attach-node waits for a master to converge
+// before attaching the next one
+var MasterHealthTimeout = 20 * time.Minute
+
+var masterHealthPollInterval = 30 * time.Second
+
+// waitForMasterHealth polls qbert until the new master and all the other
+// masters of the cluster are healthy.
+func waitForMasterHealth(q qbert.Qbert, auth keystone.KeystoneAuth, clusterUuid, hostID string, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		clusterNodes := ClusterNodes(q.GetAllNodes(auth.Token, auth.ProjectID), clusterUuid)
+		healthy, err := mastersHealthy(clusterNodes, hostID)
+		if err != nil {
+			return err
+		}
+		if healthy {
+			return nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("masters not healthy after %s", timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// mastersHealthy reports whether the master with hostID, if any, has joined the
+// cluster and every master is converged with its API server responding. A failed
+// master is an error as attaching more masters would put the etcd quorum at risk.
+func mastersHealthy(clusterNodes []qbert.Node, hostID string) (bool, error) {
+	joined := hostID == ""
+	healthy := true
+	for _, node := range clusterNodes {
+		if node.IsMaster != 1 {
+			continue
+		}
+		if node.Status == "failed" {
+			return false, fmt.Errorf("master %s is in failed state, etcd quorum may be at risk", node.PrimaryIp)
+		}
+		if node.Uuid == hostID {
+			joined = true
+		}
+		if node.Status != "ok" || node.ApiResponding != 1 {
+			healthy = false
+		}
+	}
+	return joined && healthy, nil
+}
+
+// abortMasterAttach reports the state of the masters when attaching the
+// remaining masters is stopped.
+func abortMasterAttach(c client.Client, auth keystone.KeystoneAuth, job *jobs.Job) {
+	var pending []string
+	for _, host := range job.Remaining() {
+		if host.Role == "master" && host.Status == jobs.Pending {
+			pending = append(pending, host.IP)
+		}
+	}
+	if len(pending) > 0 {
+		fmt.Printf("Not attaching master node(s) %v to keep the etcd quorum safe\n", pending)
+	}
+
+	fmt.Printf("Masters of cluster %s:\n", job.ClusterName)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  NODE\tIP\tSTATUS\tAPI RESPONDING")
+	for _, node := range ClusterNodes(c.Qbert.GetAllNodes(auth.Token, auth.ProjectID), job.ClusterUuid) {
+		if node.IsMaster == 1 {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%t\n", node.Name, node.PrimaryIp, node.Status, node.ApiResponding == 1)
+		}
+	}
+	w.Flush()
+}
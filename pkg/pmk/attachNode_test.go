@@ -0,0 +1,198 @@
+package pmk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/jobs"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMasterCount(t *testing.T) {
+	type want struct {
+		warning bool
+		err     bool
+	}
+
+	cases := map[string]struct {
+		existing  int
+		adding    int
+		allowEven bool
+		want
+	}{
+		"NoMasters":         {existing: 1, adding: 0, want: want{}},
+		"FirstMaster":       {existing: 0, adding: 1, want: want{}},
+		"OneToTwo":          {existing: 1, adding: 1, want: want{err: true}},
+		"OneToTwoAllowed":   {existing: 1, adding: 1, allowEven: true, want: want{warning: true}},
+		"TwoToThree":        {existing: 2, adding: 1, want: want{}},
+		"ThreeToFour":       {existing: 3, adding: 1, want: want{warning: true}},
+		"ZeroToTwoAtOnce":   {existing: 0, adding: 2, want: want{warning: true}},
+		"ThreeToFiveAtOnce": {existing: 3, adding: 2, want: want{}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			warning, err := checkMasterCount(tc.existing, tc.adding, tc.allowEven)
+			assert.Equal(t, tc.want.warning, warning != "")
+			assert.Equal(t, tc.want.err, err != nil)
+		})
+	}
+}
+
+func TestCheckNotAttached(t *testing.T) {
+	allNodes := []qbert.Node{
+		{Uuid: "node-1", PrimaryIp: "10.0.0.1", ClusterUuid: "cluster-a", ClusterName: "a"},
+		{Uuid: "node-2", PrimaryIp: "10.0.0.2", ClusterUuid: "cluster-b", ClusterName: "b"},
+		{Uuid: "node-3", PrimaryIp: "10.0.0.3"},
+	}
+
+	cases := map[string]struct {
+		hostIDs []string
+		err     string
+	}{
+		"Unattached":     {hostIDs: []string{"node-3"}},
+		"SameCluster":    {hostIDs: []string{"node-3", "node-1"}, err: "node 10.0.0.1 is already attached to this cluster"},
+		"AnotherCluster": {hostIDs: []string{"node-2"}, err: "node 10.0.0.2 is attached to cluster b, detach it first using 'pf9ctl detach-node'"},
+		"UnknownToQbert": {hostIDs: []string{"node-4"}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := checkNotAttached(allNodes, "cluster-a", tc.hostIDs)
+			if tc.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestOSRelease(t *testing.T) {
+	cases := map[string]string{
+		"Ubuntu 20.04 focal":         "ubuntu 20",
+		"Ubuntu 18.04.5 LTS":         "ubuntu 18",
+		"CentOS Linux 7.9.2009 Core": "centos linux 7",
+		"Red Hat Enterprise Linux":   "red hat enterprise linux",
+		"":                           "",
+	}
+
+	for osInfo, want := range cases {
+		assert.Equal(t, want, osRelease(osInfo))
+	}
+}
+
+func TestMastersHealthy(t *testing.T) {
+	master := func(uuid, status string, apiResponding int) qbert.Node {
+		return qbert.Node{Uuid: uuid, PrimaryIp: uuid, ClusterUuid: "cluster-a", IsMaster: 1, Status: status, ApiResponding: apiResponding}
+	}
+	worker := qbert.Node{Uuid: "worker-1", ClusterUuid: "cluster-a", Status: "converging"}
+
+	cases := map[string]struct {
+		nodes   []qbert.Node
+		hostID  string
+		healthy bool
+		err     bool
+	}{
+		"Healthy":            {nodes: []qbert.Node{master("m1", "ok", 1), master("m2", "ok", 1), worker}, hostID: "m2", healthy: true},
+		"NotJoinedYet":       {nodes: []qbert.Node{master("m1", "ok", 1)}, hostID: "m2"},
+		"Converging":         {nodes: []qbert.Node{master("m1", "ok", 1), master("m2", "converging", 0)}, hostID: "m2"},
+		"APINotResponding":   {nodes: []qbert.Node{master("m1", "ok", 0), master("m2", "ok", 1)}, hostID: "m2"},
+		"FailedMaster":       {nodes: []qbert.Node{master("m1", "failed", 0), master("m2", "converging", 0)}, hostID: "m2", err: true},
+		"ExistingHealthy":    {nodes: []qbert.Node{master("m1", "ok", 1)}, healthy: true},
+		"ExistingConverging": {nodes: []qbert.Node{master("m1", "converging", 1)}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			healthy, err := mastersHealthy(tc.nodes, tc.hostID)
+			assert.Equal(t, tc.healthy, healthy)
+			assert.Equal(t, tc.err, err != nil)
+		})
+	}
+}
+
+// hostIDResmgr answers GetHostId with the host IDs of the IPs
+type hostIDResmgr struct {
+	resmgr.Resmgr
+	hostIDs map[string][]string
+}
+
+func (r hostIDResmgr) GetHostId(token string, ips []string) []string {
+	var ids []string
+	for _, ip := range ips {
+		ids = append(ids, r.hostIDs[ip]...)
+	}
+	return ids
+}
+
+func TestResolveAttachHosts(t *testing.T) {
+	r := hostIDResmgr{hostIDs: map[string][]string{
+		"10.0.0.1": {"host-1"},
+		"10.0.0.2": {"host-2"},
+		"10.0.0.4": {"host-4", "host-4-stale"},
+	}}
+
+	hosts, unresolved := resolveAttachHosts(r, "token", []string{"10.0.0.1", "10.0.0.3", "10.0.0.2", "10.0.0.4"}, "worker")
+	assert.Equal(t, []jobs.Host{
+		{IP: "10.0.0.1", HostID: "host-1", Role: "worker"},
+		{IP: "10.0.0.2", HostID: "host-2", Role: "worker"},
+	}, hosts)
+	assert.Equal(t, []UnresolvedNode{
+		{IP: "10.0.0.3", Role: "worker", Reason: "not registered with the DU, run prep-node on it first"},
+		{IP: "10.0.0.4", Role: "worker", Reason: "IP shared by hosts host-4, host-4-stale of the DU, decommission the stale ones"},
+	}, unresolved)
+
+	assert.Equal(t, []jobs.Host{{IP: "10.0.0.2", HostID: "host-2", Role: "worker"}},
+		withoutUnresolved(hosts, []UnresolvedNode{{IP: "10.0.0.1"}}))
+
+	err := &UnresolvedNodesError{Nodes: unresolved}
+	assert.EqualError(t, err, "Unable to attach 2 node(s): 10.0.0.3 (worker): not registered with the DU, run prep-node on it first; "+
+		"10.0.0.4 (worker): IP shared by hosts host-4, host-4-stale of the DU, decommission the stale ones")
+}
+
+func TestAttachCandidates(t *testing.T) {
+	newHost := func(id, hostname, ip string, responding bool, roles ...string) resmgr.HostInfo {
+		host := resmgr.HostInfo{ID: id, Roles: roles}
+		host.Info.Hostname = hostname
+		host.Info.Responding = responding
+		host.Extensions.IPAddress.Data = []string{ip}
+		return host
+	}
+	hosts := []resmgr.HostInfo{
+		newHost("host-4", "node-d", "10.0.0.4", true, "pf9-kube"),
+		newHost("host-1", "node-a", "10.0.0.1", true, "pf9-kube"),
+		newHost("host-2", "node-b", "10.0.0.2", false, "pf9-kube"),
+		newHost("host-3", "node-c", "10.0.0.3", true),
+		newHost("host-5", "node-e", "10.0.0.5", true, "pf9-kube"),
+	}
+	nodes := []qbert.Node{
+		{Uuid: "host-4", PrimaryIp: "192.168.0.4"},
+		{Uuid: "host-5", PrimaryIp: "10.0.0.5", ClusterUuid: "cluster-1"},
+	}
+
+	assert.Equal(t, []AttachCandidate{
+		{HostID: "host-1", Hostname: "node-a", IP: "10.0.0.1"},
+		{HostID: "host-4", Hostname: "node-d", IP: "192.168.0.4"},
+	}, attachCandidates(hosts, nodes))
+}
+
+func TestValidateAttachNode(t *testing.T) {
+	c := client.Client{Qbert: nodesQbert{nodes: map[string]qbert.Node{
+		"host-1": {Uuid: "host-1", PrimaryIp: "10.0.0.1", ClusterName: "prod", ClusterUuid: "uuid-prod"},
+	}}}
+	auth := keystone.KeystoneAuth{}
+	req := AttachNodeRequest{ClusterUuid: "uuid-dev", WorkerIDs: []string{"host-1"}}
+
+	_, err := ValidateAttachNode(c, auth, req)
+	assert.EqualError(t, err, "node 10.0.0.1 is attached to cluster prod, detach it first using 'pf9ctl detach-node'")
+
+	// The request isn't taken for valid when the nodes can't be listed
+	c.Qbert = nodesQbert{err: errors.New("could not query the qbert endpoint: 503")}
+	_, err = ValidateAttachNode(c, auth, req)
+	assert.EqualError(t, err, "unable to list the nodes of qbert: could not query the qbert endpoint: 503")
+}
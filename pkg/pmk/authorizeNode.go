@@ -0,0 +1,52 @@
+package pmk
+
+import (
+	"fmt"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/platform9/pf9ctl/pkg/ui"
+	"go.uber.org/zap"
+)
+
+// AuthorizeNode authorizes again a node which was deauthorized while its
+// hostagent kept running, without going through prep-node again.
+func AuthorizeNode(c client.Client, auth keystone.KeystoneAuth, ip string) error {
+	hostIDs := c.Resmgr.GetHostId(auth.Token, []string{ip})
+	if len(hostIDs) == 0 {
+		return fmt.Errorf("node %s is not registered with the management plane, run prep-node on it", ip)
+	}
+
+	host, err := c.Resmgr.GetHostInfo(auth.Token, hostIDs[0])
+	if err != nil {
+		return fmt.Errorf("unable to get host info of node %s: %w", ip, err)
+	}
+	if err := checkReauthorizable(host, ip); err != nil {
+		return err
+	}
+
+	phase := ui.StartPhase(fmt.Sprintf("Authorizing node %s", ip))
+	if err := c.Resmgr.AuthorizeHost(host.ID, auth.Token); err != nil {
+		phase.Fail("Unable to authorize node")
+		trackEvent(c, auth, client.Event{Name: segmentEventAuthorize, Phase: phaseAuthorise, Status: checkFail, Err: err})
+		return err
+	}
+	phase.Succeed(fmt.Sprintf("Node %s authorized, applying the role may take a few minutes", ip))
+	zap.S().Debugf("Host %s authorized", host.ID)
+
+	trackEvent(c, auth, client.Event{Name: segmentEventAuthorize, Phase: phaseAuthorise, Status: checkPass})
+	return nil
+}
+
+// checkReauthorizable fails if the host already has a role or can't take one
+// because its hostagent is not talking to the management plane.
+func checkReauthorizable(host resmgr.HostInfo, ip string) error {
+	if len(host.Roles) > 0 {
+		return fmt.Errorf("node %s is already authorized", ip)
+	}
+	if !host.Info.Responding {
+		return fmt.Errorf("node %s is not responding, make sure pf9-hostagent is running on it or run prep-node", ip)
+	}
+	return nil
+}
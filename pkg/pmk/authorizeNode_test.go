@@ -0,0 +1,36 @@
+package pmk
+
+import (
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckReauthorizable(t *testing.T) {
+	host := func(responding bool, roles ...string) resmgr.HostInfo {
+		h := resmgr.HostInfo{ID: "host-1", Roles: roles}
+		h.Info.Responding = responding
+		return h
+	}
+
+	cases := map[string]struct {
+		host resmgr.HostInfo
+		err  string
+	}{
+		"Deauthorized":  {host: host(true)},
+		"Authorized":    {host: host(true, "pf9-kube"), err: "node 10.0.0.1 is already authorized"},
+		"NotResponding": {host: host(false), err: "node 10.0.0.1 is not responding, make sure pf9-hostagent is running on it or run prep-node"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := checkReauthorizable(tc.host, "10.0.0.1")
+			if tc.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
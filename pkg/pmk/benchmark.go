@@ -0,0 +1,343 @@
+package pmk
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// Requirements the benchmarks of a node are compared to
+var (
+	// MaxFsyncLatency is the 99th percentile of the fdatasync latency etcd
+	// needs to keep its leader elected
+	MaxFsyncLatency = 10 * time.Millisecond
+	// MaxPeerLatency is the round trip between masters etcd needs with its
+	// default heartbeat interval of 100ms
+	MaxPeerLatency = 50 * time.Millisecond
+	// MinPeerBandwidth is the bandwidth between masters etcd needs, in
+	// Mbit/s. iperf3 measures about 940 Mbit/s on a gigabit link.
+	MinPeerBandwidth = 900.0
+)
+
+// Grades of a benchmarked node
+const (
+	GradeMaster     = "suitable as a master"
+	GradeWorker     = "suitable as a worker only"
+	GradeUnsuitable = "unsuitable"
+)
+
+// benchmarkTools are the packages of the benchmarks, installed with
+// --install-tools
+var benchmarkTools = []string{"fio", "iperf3"}
+
+// BenchmarkResult is a benchmark of a node compared to its requirement
+type BenchmarkResult struct {
+	Name        string
+	Tool        string
+	Value       string
+	Requirement string
+	// MasterOnly is set for the requirements of etcd, which only runs on
+	// the masters
+	MasterOnly bool
+	Pass       bool
+	// Err is why the benchmark couldn't run, the requirement isn't graded
+	Err error
+}
+
+// BenchmarkOptions are the benchmarks run on a node
+type BenchmarkOptions struct {
+	// Dir is the directory on the disk etcd would use
+	Dir string
+	// Peer is the node the network is benchmarked with, the network isn't
+	// benchmarked when nil
+	Peer     cmdexec.Executor
+	PeerIP   string
+	Duration time.Duration
+}
+
+// BenchmarkNode benchmarks the disk of the node of exec, and its network to
+// the peer of opts when set
+func BenchmarkNode(exec cmdexec.Executor, opts BenchmarkOptions) []BenchmarkResult {
+	results := []BenchmarkResult{benchmarkFsync(exec, opts.Dir)}
+	if opts.Peer != nil {
+		results = append(results, benchmarkPeerLatency(exec, opts.PeerIP), benchmarkBandwidth(exec, opts.Peer, opts.PeerIP, opts.Duration))
+	}
+	return results
+}
+
+// GradeNode grades the node from its benchmarks. Benchmarks which couldn't
+// run aren't graded.
+func GradeNode(results []BenchmarkResult) string {
+	grade := GradeMaster
+	for _, r := range results {
+		if r.Err != nil || r.Pass {
+			continue
+		}
+		if !r.MasterOnly {
+			return GradeUnsuitable
+		}
+		grade = GradeWorker
+	}
+	return grade
+}
+
+// benchmarkFsync measures the latency of the small writes etcd syncs to its
+// WAL in dir, with fio or with dd when fio isn't installed
+func benchmarkFsync(exec cmdexec.Executor, dir string) BenchmarkResult {
+	result := BenchmarkResult{
+		Name:        "disk fdatasync latency (p99)",
+		Requirement: fmt.Sprintf("<= %s (etcd)", MaxFsyncLatency),
+		MasterOnly:  true,
+	}
+	file := path.Join(dir, "pf9ctl-benchmark")
+	defer exec.RunArgs("rm", "-rf", file)
+
+	var latency time.Duration
+	var err error
+	if toolInstalled(exec, "fio") {
+		result.Tool = "fio"
+		latency, err = fioFsyncLatency(exec, file)
+	} else {
+		// dd only reports the mean latency
+		result.Tool = "dd"
+		result.Name = "disk fdatasync latency (mean)"
+		latency, err = ddFsyncLatency(exec, file)
+	}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Value = latency.Round(10 * time.Microsecond).String()
+	result.Pass = latency <= MaxFsyncLatency
+	return result
+}
+
+// fioFsyncLatency runs the fio job recommended for the disks of etcd
+func fioFsyncLatency(exec cmdexec.Executor, file string) (time.Duration, error) {
+	out, err := exec.RunArgs("fio", "--rw=write", "--ioengine=sync", "--fdatasync=1", "--filename="+file,
+		"--size=22m", "--bs=2300", "--name=pf9ctl-benchmark", "--output-format=json")
+	if err != nil {
+		return 0, fmt.Errorf("fio failed: %w", err)
+	}
+	return parseFioFsyncP99(out)
+}
+
+// parseFioFsyncP99 returns the 99th percentile of the sync latency of the
+// JSON output of fio
+func parseFioFsyncP99(out string) (time.Duration, error) {
+	var report struct {
+		Jobs []struct {
+			Sync struct {
+				LatNs struct {
+					Percentile map[string]float64 `json:"percentile"`
+				} `json:"lat_ns"`
+			} `json:"sync"`
+		} `json:"jobs"`
+	}
+	// fio may print warnings before the report
+	if i := strings.Index(out, "{"); i > 0 {
+		out = out[i:]
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		return 0, fmt.Errorf("unable to parse the output of fio: %w", err)
+	}
+	if len(report.Jobs) == 0 {
+		return 0, fmt.Errorf("fio reported no job")
+	}
+	p99, ok := report.Jobs[0].Sync.LatNs.Percentile["99.000000"]
+	if !ok {
+		return 0, fmt.Errorf("fio reported no sync latency, fio 3.5 or later is needed")
+	}
+	return time.Duration(p99), nil
+}
+
+var ddCopiedPattern = regexp.MustCompile(`copied, ([0-9.]+) s`)
+
+// ddWrites is the number of writes of the dd benchmark
+const ddWrites = 1000
+
+// ddFsyncLatency measures the mean latency of synced writes with dd
+func ddFsyncLatency(exec cmdexec.Executor, file string) (time.Duration, error) {
+	// dd reports on stderr and its format depends on the locale
+	out, err := exec.RunWithStdout("bash", "-c", fmt.Sprintf("LC_ALL=C dd if=/dev/zero of=%s bs=2300 count=%d oflag=dsync 2>&1", cmdexec.ShellQuote(file), ddWrites))
+	if err != nil {
+		return 0, fmt.Errorf("dd failed: %w", err)
+	}
+	return parseDDLatency(out, ddWrites)
+}
+
+// parseDDLatency returns the mean latency of the writes from the output of dd
+func parseDDLatency(out string, writes int) (time.Duration, error) {
+	m := ddCopiedPattern.FindStringSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("unable to parse the output of dd: %s", strings.TrimSpace(out))
+	}
+	seconds, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse the output of dd: %w", err)
+	}
+	return time.Duration(seconds / float64(writes) * float64(time.Second)), nil
+}
+
+var pingRTTPattern = regexp.MustCompile(`= [0-9.]+/([0-9.]+)/`)
+
+// benchmarkPeerLatency measures the round trip to the peer with ping
+func benchmarkPeerLatency(exec cmdexec.Executor, peerIP string) BenchmarkResult {
+	result := BenchmarkResult{
+		Name:        "round trip to " + peerIP,
+		Tool:        "ping",
+		Requirement: fmt.Sprintf("<= %s (etcd)", MaxPeerLatency),
+		MasterOnly:  true,
+	}
+	out, err := exec.RunArgs("ping", "-c", "10", "-i", "0.2", "-q", peerIP)
+	if err != nil {
+		result.Err = fmt.Errorf("unable to ping %s: %w", peerIP, err)
+		return result
+	}
+	rtt, err := parsePingRTT(out)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Value = rtt.Round(10 * time.Microsecond).String()
+	result.Pass = rtt <= MaxPeerLatency
+	return result
+}
+
+// parsePingRTT returns the mean round trip of the summary of ping
+func parsePingRTT(out string) (time.Duration, error) {
+	m := pingRTTPattern.FindStringSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("unable to parse the output of ping: %s", strings.TrimSpace(out))
+	}
+	ms, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse the output of ping: %w", err)
+	}
+	return time.Duration(ms * float64(time.Millisecond)), nil
+}
+
+// benchmarkBandwidth measures the bandwidth to the peer with iperf3, served
+// by the peer for a single test
+func benchmarkBandwidth(exec, peer cmdexec.Executor, peerIP string, duration time.Duration) BenchmarkResult {
+	result := BenchmarkResult{
+		Name:        "bandwidth to " + peerIP,
+		Tool:        "iperf3",
+		Requirement: fmt.Sprintf(">= %.0f Mbit/s (etcd)", MinPeerBandwidth),
+		MasterOnly:  true,
+	}
+	if !toolInstalled(exec, "iperf3") || !toolInstalled(peer, "iperf3") {
+		result.Err = fmt.Errorf("iperf3 isn't installed on both nodes, install it or pass --install-tools")
+		return result
+	}
+	if _, err := peer.RunArgs("iperf3", "--server", "--one-off", "--daemon"); err != nil {
+		result.Err = fmt.Errorf("unable to start iperf3 on %s: %w", peerIP, err)
+		return result
+	}
+	seconds := strconv.Itoa(int(duration.Seconds()))
+	out, err := exec.RunArgs("iperf3", "--client", peerIP, "--time", seconds, "--json")
+	if err != nil {
+		result.Err = fmt.Errorf("iperf3 failed, check port 5201 of %s is open: %w", peerIP, err)
+		return result
+	}
+	mbps, err := parseIperfBandwidth(out)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Value = fmt.Sprintf("%.0f Mbit/s", mbps)
+	result.Pass = mbps >= MinPeerBandwidth
+	return result
+}
+
+// parseIperfBandwidth returns the bandwidth received by the server of the
+// JSON output of iperf3, in Mbit/s
+func parseIperfBandwidth(out string) (float64, error) {
+	var report struct {
+		End struct {
+			SumReceived struct {
+				BitsPerSecond float64 `json:"bits_per_second"`
+			} `json:"sum_received"`
+		} `json:"end"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		return 0, fmt.Errorf("unable to parse the output of iperf3: %w", err)
+	}
+	if report.Error != "" {
+		return 0, fmt.Errorf("iperf3 failed: %s", report.Error)
+	}
+	return report.End.SumReceived.BitsPerSecond / 1e6, nil
+}
+
+// toolInstalled is true when the command is found on the node
+func toolInstalled(exec cmdexec.Executor, tool string) bool {
+	_, err := exec.RunWithStdout("bash", "-c", "command -v "+tool)
+	return err == nil
+}
+
+// InstallBenchmarkTools installs the benchmark tools missing from the node
+// with its package manager. It returns the tools it installed, to be removed
+// by RemoveBenchmarkTools.
+func InstallBenchmarkTools(exec cmdexec.Executor) ([]string, error) {
+	var missing []string
+	for _, tool := range benchmarkTools {
+		if !toolInstalled(exec, tool) {
+			missing = append(missing, tool)
+		}
+	}
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	manager, err := packageManager(exec)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"install", "-y", "-q"}
+	if manager == "apt-get" {
+		// apt-get doesn't refresh the package lists itself
+		if _, err := exec.RunArgs("apt-get", "update", "-q"); err != nil {
+			zap.S().Debugf("Unable to update the package lists: %s", err)
+		}
+	}
+	if _, err := exec.RunArgs(manager, append(args, missing...)...); err != nil {
+		return nil, fmt.Errorf("unable to install %s: %w", strings.Join(missing, ", "), err)
+	}
+	return missing, nil
+}
+
+// RemoveBenchmarkTools removes the tools installed by InstallBenchmarkTools
+func RemoveBenchmarkTools(exec cmdexec.Executor, tools []string) error {
+	if len(tools) == 0 {
+		return nil
+	}
+	manager, err := packageManager(exec)
+	if err != nil {
+		return err
+	}
+	verb := "remove"
+	if manager == "apt-get" {
+		verb = "purge"
+	}
+	if _, err := exec.RunArgs(manager, append([]string{verb, "-y", "-q"}, tools...)...); err != nil {
+		return fmt.Errorf("unable to remove %s: %w", strings.Join(tools, ", "), err)
+	}
+	return nil
+}
+
+// packageManager returns the package manager of the node
+func packageManager(exec cmdexec.Executor) (string, error) {
+	for _, manager := range []string{"apt-get", "yum"} {
+		if toolInstalled(exec, manager) {
+			return manager, nil
+		}
+	}
+	return "", fmt.Errorf("neither apt-get nor yum is installed")
+}
@@ -0,0 +1,80 @@
+package pmk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBenchmarkOutputs(t *testing.T) {
+	fio := `fio: this platform does not support process shared mutexes
+{"fio version": "fio-3.16", "jobs": [{"jobname": "pf9ctl-benchmark", "sync": {"lat_ns": {"min": 1000, "percentile": {"90.000000": 2048000, "99.000000": 4227072}}}}]}`
+	latency, err := parseFioFsyncP99(fio)
+	assert.NoError(t, err)
+	assert.Equal(t, 4227072*time.Nanosecond, latency)
+
+	_, err = parseFioFsyncP99(`{"jobs": [{"sync": {"lat": {}}}]}`)
+	assert.EqualError(t, err, "fio reported no sync latency, fio 3.5 or later is needed")
+
+	dd := "1000+0 records in\n1000+0 records out\n2300000 bytes (2.3 MB, 2.2 MiB) copied, 3.5 s, 657 kB/s\n"
+	latency, err = parseDDLatency(dd, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, 3500*time.Microsecond, latency)
+
+	ping := "--- 10.0.0.2 ping statistics ---\n10 packets transmitted, 10 received, 0% packet loss, time 1805ms\nrtt min/avg/max/mdev = 0.312/0.455/0.701/0.112 ms\n"
+	rtt, err := parsePingRTT(ping)
+	assert.NoError(t, err)
+	assert.Equal(t, 455*time.Microsecond, rtt)
+
+	mbps, err := parseIperfBandwidth(`{"end": {"sum_received": {"bits_per_second": 9410000000}}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, 9410.0, mbps)
+
+	_, err = parseIperfBandwidth(`{"error": "unable to connect to server: Connection refused"}`)
+	assert.EqualError(t, err, "iperf3 failed: unable to connect to server: Connection refused")
+}
+
+func TestGradeNode(t *testing.T) {
+	cases := map[string]struct {
+		results []BenchmarkResult
+		want    string
+	}{
+		"AllPass":     {[]BenchmarkResult{{MasterOnly: true, Pass: true}, {Pass: true}}, GradeMaster},
+		"SlowDisk":    {[]BenchmarkResult{{MasterOnly: true}, {Pass: true}}, GradeWorker},
+		"SlowNetwork": {[]BenchmarkResult{{MasterOnly: true, Pass: true}, {}}, GradeUnsuitable},
+		// Benchmarks which couldn't run aren't graded
+		"Skipped": {[]BenchmarkResult{{MasterOnly: true, Pass: true}, {Err: errors.New("no iperf3")}}, GradeMaster},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, GradeNode(tc.results))
+		})
+	}
+}
+
+func TestBenchmarkFsyncFallback(t *testing.T) {
+	var ran []string
+	exec := &cmdexec.MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			cmd := strings.Join(args, " ")
+			ran = append(ran, cmd)
+			switch {
+			case strings.HasPrefix(cmd, "-c command -v"):
+				return "", errors.New("exit status 1")
+			case strings.Contains(cmd, "dd if=/dev/zero of='/var/lib/pf9ctl-benchmark'"):
+				return "2300000 bytes (2.3 MB, 2.2 MiB) copied, 25 s, 92 kB/s\n", nil
+			}
+			return "", nil
+		},
+	}
+	result := benchmarkFsync(exec, "/var/lib")
+	assert.NoError(t, result.Err)
+	assert.Equal(t, "dd", result.Tool)
+	assert.Equal(t, "25ms", result.Value)
+	assert.False(t, result.Pass)
+	assert.Equal(t, "-rf /var/lib/pf9ctl-benchmark", ran[len(ran)-1])
+}
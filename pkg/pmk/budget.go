@@ -0,0 +1,186 @@
+package pmk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/statefile"
+	"github.com/platform9/pf9ctl/pkg/ui"
+	"go.uber.org/zap"
+)
+
+// DefaultPhaseBudget is how many times its typical duration a phase runs
+// before a warning, unless the phase_budget setting changes it
+const DefaultPhaseBudget = 3.0
+
+// minPhaseBudget is the shortest a phase runs before a warning, the phases
+// taking a few seconds vary too much for their typical duration to matter
+const minPhaseBudget = 30 * time.Second
+
+// typicalWeight is the weight of the last duration of a phase in its typical
+// duration, the others decaying
+const typicalWeight = 0.3
+
+// defaultPhaseDurations are the typical durations of the phases of prep-node
+// until they are recorded on this machine
+var defaultPhaseDurations = map[string]time.Duration{
+	phaseValidateOS:    5 * time.Second,
+	phaseHostID:        5 * time.Second,
+	phaseExistingPkgs:  10 * time.Second,
+	phaseRemoveRuntime: 30 * time.Second,
+	phaseKernelTuning:  5 * time.Second,
+	phaseReboot:        2 * time.Minute,
+	phaseInstallAgent:  3 * time.Minute,
+	phaseInitialise:    5 * time.Second,
+	phaseAuthorise:     90 * time.Second,
+}
+
+// phaseHints are what to check when the phase is slow
+var phaseHints = map[string]string{
+	phaseInstallAgent: "the download of the installer may be slow: check the bandwidth between the node and the DU, " +
+		"the proxy_url and download_limit settings, or prepare the node with --relay",
+	phaseAuthorise:     "the DU may be slow to answer: check the node reaches it with 'pf9ctl check-node' and the proxy of the node",
+	phaseExistingPkgs:  "the package manager of the node may be slow or another apt or yum may hold its lock",
+	phaseKernelTuning:  "sysctl may be slow to apply the settings on the node",
+	phaseRemoveRuntime: "the package manager of the node may be slow or another apt or yum may hold its lock",
+	phaseReboot:        "the node may be slow to boot or its SSH server slow to start: check its console",
+}
+
+// defaultPhaseHint is the hint of the phases without their own
+const defaultPhaseHint = "the node may be slow to answer: check its load and the SSH connection to it"
+
+// ParsePhaseBudget parses the phase_budget setting, DefaultPhaseBudget when it
+// is empty. 0 disables the warnings.
+func ParsePhaseBudget(value string) (float64, error) {
+	if value == "" {
+		return DefaultPhaseBudget, nil
+	}
+	multiple, err := strconv.ParseFloat(value, 64)
+	if err != nil || multiple < 0 || (multiple > 0 && multiple < 1) {
+		return 0, fmt.Errorf("invalid phase budget %q, it is a multiple of the typical duration of the phases of at least 1, or 0 to disable the warnings", value)
+	}
+	return multiple, nil
+}
+
+// typicalDuration is the typical duration of a phase recorded on this machine
+type typicalDuration struct {
+	Seconds float64 `json:"seconds"`
+	Samples int     `json:"samples"`
+}
+
+// phaseBudgets warns when a phase of an operation runs longer than multiple
+// times its typical duration, and records the durations of the phases
+type phaseBudgets struct {
+	path      string
+	operation string
+	multiple  float64
+
+	mu      sync.Mutex
+	typical map[string]typicalDuration
+	timer   *time.Timer
+}
+
+// newPhaseBudgets loads the typical durations of the phases of operation
+// recorded at path
+func newPhaseBudgets(path, operation string, multiple float64) *phaseBudgets {
+	b := &phaseBudgets{path: path, operation: operation, multiple: multiple, typical: make(map[string]typicalDuration)}
+	if data, err := ioutil.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &b.typical); err != nil {
+			zap.S().Debugf("Ignoring the typical durations of the phases in %s: %s", path, err)
+		}
+	}
+	return b
+}
+
+func (b *phaseBudgets) key(phase string) string {
+	return b.operation + "/" + phase
+}
+
+// expected returns the typical duration of phase, zero when it isn't known
+func (b *phaseBudgets) expected(phase string) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if typical, ok := b.typical[b.key(phase)]; ok && typical.Samples > 0 {
+		return time.Duration(typical.Seconds * float64(time.Second))
+	}
+	return defaultPhaseDurations[phase]
+}
+
+// budget returns how long phase runs before a warning, zero when it never
+// warns
+func (b *phaseBudgets) budget(phase string) time.Duration {
+	expected := b.expected(phase)
+	if b.multiple == 0 || expected == 0 {
+		return 0
+	}
+	budget := time.Duration(float64(expected) * b.multiple)
+	if budget < minPhaseBudget {
+		budget = minPhaseBudget
+	}
+	return budget
+}
+
+// watch warns on p once phase runs over its budget, until stop is called
+func (b *phaseBudgets) watch(phase string, p *ui.Phase) {
+	b.stop()
+	budget := b.budget(phase)
+	if budget == 0 || p == nil {
+		return
+	}
+	expected := b.expected(phase)
+	timer := time.AfterFunc(budget, func() {
+		p.Warn(slowPhaseMessage(phase, budget, expected))
+		zap.S().Debugf("Phase %s of %s is over its budget of %s", phase, b.operation, budget)
+	})
+	b.mu.Lock()
+	b.timer = timer
+	b.mu.Unlock()
+}
+
+// stop stops watching the running phase
+func (b *phaseBudgets) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
+
+// record adds the duration of a phase which succeeded to its typical duration
+func (b *phaseBudgets) record(phase string, duration time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	key := b.key(phase)
+	typical := b.typical[key]
+	if typical.Samples == 0 {
+		typical.Seconds = duration.Seconds()
+	} else {
+		typical.Seconds = typicalWeight*duration.Seconds() + (1-typicalWeight)*typical.Seconds
+	}
+	typical.Samples++
+	b.typical[key] = typical
+
+	data, err := json.Marshal(b.typical)
+	if err == nil {
+		err = statefile.WriteFile(b.path, data, os.FileMode(0600))
+	}
+	if err != nil {
+		zap.S().Debugf("Unable to record the duration of the phase %s: %s", key, err)
+	}
+}
+
+// slowPhaseMessage is the warning of a phase running over its budget
+func slowPhaseMessage(phase string, budget, expected time.Duration) string {
+	hint, ok := phaseHints[phase]
+	if !ok {
+		hint = defaultPhaseHint
+	}
+	return fmt.Sprintf("%s is taking longer than usual, over %s while it typically takes %s: %s",
+		phase, budget.Round(time.Second), expected.Round(time.Second), hint)
+}
@@ -0,0 +1,65 @@
+package pmk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePhaseBudget(t *testing.T) {
+	cases := map[string]struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		"Default":  {"", DefaultPhaseBudget, false},
+		"Multiple": {"2.5", 2.5, false},
+		"Disabled": {"0", 0, false},
+		"BelowOne": {"0.5", 0, true},
+		"Negative": {"-1", 0, true},
+		"NotFloat": {"3x", 0, true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParsePhaseBudget(tc.value)
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestPhaseBudgets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "budgets")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "phase_durations.json")
+
+	b := newPhaseBudgets(path, "prep-node", 3)
+	// The defaults are used until a duration is recorded, the short phases
+	// get the minimum budget
+	assert.Equal(t, 9*time.Minute, b.budget(phaseInstallAgent))
+	assert.Equal(t, minPhaseBudget, b.budget(phaseValidateOS))
+	assert.Equal(t, time.Duration(0), b.budget("unknown"))
+
+	b.record(phaseInstallAgent, 60*time.Second)
+	assert.Equal(t, 60*time.Second, b.expected(phaseInstallAgent))
+	b.record(phaseInstallAgent, 160*time.Second)
+	assert.Equal(t, 90*time.Second, b.expected(phaseInstallAgent))
+	assert.Equal(t, 270*time.Second, b.budget(phaseInstallAgent))
+
+	// The durations are kept for the next runs, by operation
+	b = newPhaseBudgets(path, "prep-node", 0)
+	assert.Equal(t, 90*time.Second, b.expected(phaseInstallAgent))
+	assert.Equal(t, time.Duration(0), b.budget(phaseInstallAgent), "0 never warns")
+	assert.Equal(t, 3*time.Minute, newPhaseBudgets(path, "other", 3).expected(phaseInstallAgent))
+}
+
+func TestSlowPhaseMessage(t *testing.T) {
+	assert.Equal(t, "install-hostagent is taking longer than usual, over 9m0s while it typically takes 3m0s: "+phaseHints[phaseInstallAgent],
+		slowPhaseMessage(phaseInstallAgent, 9*time.Minute, 3*time.Minute))
+	assert.Contains(t, slowPhaseMessage(phaseHostID, time.Minute, time.Second), defaultPhaseHint)
+}
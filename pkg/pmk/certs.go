@@ -0,0 +1,89 @@
+package pmk
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Certificates of the control plane of a cluster
+const (
+	ClusterCACert = "cluster CA"
+	APIServerCert = "API server"
+)
+
+// ClusterCert is the expiry of a certificate of the control plane of a cluster
+type ClusterCert struct {
+	Name     string
+	NotAfter time.Time
+	// Err is why the certificate couldn't be read
+	Err error
+}
+
+// Status summarizes the certificate as OK, EXPIRING when it expires within
+// warn, EXPIRED or UNREACHABLE
+func (c ClusterCert) Status(now time.Time, warn time.Duration) string {
+	switch {
+	case c.Err != nil:
+		return "UNREACHABLE"
+	case now.After(c.NotAfter):
+		return "EXPIRED"
+	case c.NotAfter.Sub(now) < warn:
+		return "EXPIRING"
+	}
+	return "OK"
+}
+
+// ClusterCerts returns the expiry of the CA of the cluster, read from the
+// kubeconfig qbert generates for it, and of the serving certificate of its API
+// server, read from the API endpoint of spec
+func ClusterCerts(kubeconfig []byte, spec map[string]interface{}, timeout time.Duration) []ClusterCert {
+	ca := ClusterCert{Name: ClusterCACert}
+	ca.NotAfter, ca.Err = kubeconfigCAExpiry(kubeconfig)
+
+	apiServer := ClusterCert{Name: APIServerCert}
+	if endpoint := clusterAPIEndpoint(spec); endpoint != "" {
+		apiServer.NotAfter, apiServer.Err = APIServerCertExpiry(endpoint, timeout)
+	} else {
+		apiServer.Err = fmt.Errorf("cluster has no API endpoint")
+	}
+	return []ClusterCert{ca, apiServer}
+}
+
+// kubeconfigCAExpiry returns when the CA of the first cluster of the
+// kubeconfig expires
+func kubeconfigCAExpiry(kubeconfig []byte) (time.Time, error) {
+	if len(kubeconfig) == 0 {
+		return time.Time{}, fmt.Errorf("no kubeconfig for the cluster")
+	}
+	var config struct {
+		Clusters []struct {
+			Cluster struct {
+				CAData string `yaml:"certificate-authority-data"`
+			} `yaml:"cluster"`
+		} `yaml:"clusters"`
+	}
+	if err := yaml.Unmarshal(kubeconfig, &config); err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse the kubeconfig: %w", err)
+	}
+	if len(config.Clusters) == 0 || config.Clusters[0].Cluster.CAData == "" {
+		return time.Time{}, fmt.Errorf("kubeconfig has no CA for the cluster")
+	}
+	data, err := base64.StdEncoding.DecodeString(config.Clusters[0].Cluster.CAData)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to decode the CA of the kubeconfig: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return time.Time{}, fmt.Errorf("CA of the kubeconfig isn't PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse the CA of the kubeconfig: %w", err)
+	}
+	return cert.NotAfter, nil
+}
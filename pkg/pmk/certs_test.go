@@ -0,0 +1,57 @@
+package pmk
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterCerts(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	cert := server.Certificate()
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	kubeconfig := fmt.Sprintf("apiVersion: v1\nclusters:\n- cluster:\n    certificate-authority-data: %s\n    server: https://10.0.0.1\n  name: my-cluster\n",
+		base64.StdEncoding.EncodeToString(ca))
+	spec := map[string]interface{}{"masterIp": strings.TrimPrefix(server.URL, "https://")}
+
+	certs := ClusterCerts([]byte(kubeconfig), spec, time.Second)
+	assert.Len(t, certs, 2)
+	assert.Equal(t, ClusterCACert, certs[0].Name)
+	assert.NoError(t, certs[0].Err)
+	assert.True(t, cert.NotAfter.Equal(certs[0].NotAfter))
+	assert.Equal(t, APIServerCert, certs[1].Name)
+	assert.NoError(t, certs[1].Err)
+	assert.True(t, cert.NotAfter.Equal(certs[1].NotAfter))
+
+	certs = ClusterCerts([]byte("clusters: []\n"), map[string]interface{}{}, time.Second)
+	assert.EqualError(t, certs[0].Err, "kubeconfig has no CA for the cluster")
+	assert.EqualError(t, certs[1].Err, "cluster has no API endpoint")
+}
+
+func TestClusterCertStatus(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	warn := 30 * 24 * time.Hour
+	tests := map[string]struct {
+		cert ClusterCert
+		want string
+	}{
+		"ok":          {ClusterCert{NotAfter: now.Add(60 * 24 * time.Hour)}, "OK"},
+		"expiring":    {ClusterCert{NotAfter: now.Add(10 * 24 * time.Hour)}, "EXPIRING"},
+		"expired":     {ClusterCert{NotAfter: now.Add(-time.Hour)}, "EXPIRED"},
+		"unreachable": {ClusterCert{Err: fmt.Errorf("timeout")}, "UNREACHABLE"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.cert.Status(now, warn))
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package pmk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Cgroup check of the nodes
+var (
+	// KubernetesVersion is the version of Kubernetes the node is prepared
+	// for, the cgroup version of the node is checked against it. The newest
+	// version the DU supports is used when it is empty.
+	KubernetesVersion string
+	// FixCgroup switches the node to the cgroup version the Kubernetes
+	// version needs, which applies once the node rebooted
+	FixCgroup bool
+)
+
+// Cgroup versions of the nodes
+const (
+	CgroupV1 = "v1"
+	CgroupV2 = "v2"
+)
+
+// Versions bounding the support of the cgroup versions, as major and minor
+var (
+	// minCgroupV2Kube is the first Kubernetes version whose kubelet runs on
+	// cgroup v2
+	minCgroupV2Kube = [2]int{1, 22}
+	// minCgroupV1RefusedKube is the first Kubernetes version whose kubelet
+	// refuses to start on cgroup v1
+	minCgroupV1RefusedKube = [2]int{1, 35}
+	// minCgroupV2Containerd is the first containerd supporting cgroup v2
+	minCgroupV2Containerd = [2]int{1, 4}
+	// minCgroupV2Kernel is the first kernel with the cgroup v2 controllers
+	// the kubelet needs
+	minCgroupV2Kernel = [2]int{4, 15}
+)
+
+func atLeast(version string, min [2]int) bool {
+	return versionAtLeast(version, min[0], min[1])
+}
+
+// NormalizeKubernetesVersion checks version is a Kubernetes version like 1.21
+// or 1.21.3, returning it as major.minor.patch
+func NormalizeKubernetesVersion(version string) (string, error) {
+	v := strings.TrimPrefix(version, "v")
+	if strings.Count(v, ".") == 1 {
+		v += ".0"
+	}
+	if parseVersion(v) != v {
+		return "", fmt.Errorf("invalid Kubernetes version %q, it is given as 1.21 or 1.21.3", version)
+	}
+	return v, nil
+}
+
+// NewestKubernetesVersion returns the newest of the role versions of the DU,
+// as 1.21.3-pmk.72, empty when there is none
+func NewestKubernetesVersion(roleVersions []string) string {
+	var newest string
+	for _, role := range roleVersions {
+		if parseVersion(role) != "" && (newest == "" || compareMinor(role, newest) > 0) {
+			newest = role
+		}
+	}
+	return newest
+}
+
+// cgroupState is the cgroup setup of a node
+type cgroupState struct {
+	// Mode is the cgroup version of the node, v1 in hybrid mode
+	Mode string
+	// Kernel is the version of the running kernel
+	Kernel string
+	// Containerd is the version of containerd when it is installed
+	Containerd string
+}
+
+// cgroupScript prints the filesystem of /sys/fs/cgroup, the kernel and the
+// version of containerd, a line each
+const cgroupScript = `stat -fc %T /sys/fs/cgroup
+uname -r
+(containerd --version || /opt/pf9/pf9-kube/bin/containerd --version) 2> /dev/null | head -1
+true`
+
+// parseCgroupState parses the output of cgroupScript
+func parseCgroupState(out string) (cgroupState, error) {
+	lines := strings.Split(out, "\n")
+	if len(lines) < 2 {
+		return cgroupState{}, fmt.Errorf("unexpected output %q", out)
+	}
+	state := cgroupState{Mode: CgroupV1, Kernel: strings.TrimSpace(lines[1])}
+	switch strings.TrimSpace(lines[0]) {
+	case "cgroup2fs":
+		state.Mode = CgroupV2
+	case "tmpfs":
+	default:
+		return state, fmt.Errorf("unexpected filesystem %q of /sys/fs/cgroup", lines[0])
+	}
+	if len(lines) > 2 {
+		state.Containerd = parseVersion(lines[2])
+	}
+	return state, nil
+}
+
+// readCgroupState reads the cgroup setup of the node of exec
+func readCgroupState(exec cmdexec.Executor) (cgroupState, error) {
+	out, err := exec.RunArgs("bash", "-c", cgroupScript)
+	if err != nil {
+		return cgroupState{}, err
+	}
+	return parseCgroupState(out)
+}
+
+// cgroupProblem returns why the cgroup setup of state can't run the kubelet
+// of Kubernetes kube, and the cgroup version the node is switched to, which is
+// empty when switching doesn't fix it. kube is empty when it isn't known.
+func cgroupProblem(state cgroupState, kube string) (string, string) {
+	v2Kernel := parseVersion(state.Kernel) == "" || atLeast(state.Kernel, minCgroupV2Kernel)
+	kube = parseVersion(kube)
+
+	if state.Mode == CgroupV2 {
+		if !v2Kernel {
+			return fmt.Sprintf("the node runs cgroup v2 on kernel %s, the kubelet needs kernel %d.%d or later for it",
+				state.Kernel, minCgroupV2Kernel[0], minCgroupV2Kernel[1]), CgroupV1
+		}
+		if kube != "" && !atLeast(kube, minCgroupV2Kube) {
+			return fmt.Sprintf("the node runs cgroup v2, which the kubelet of Kubernetes %s doesn't support before %d.%d",
+				kube, minCgroupV2Kube[0], minCgroupV2Kube[1]), CgroupV1
+		}
+		if state.Containerd != "" && !atLeast(state.Containerd, minCgroupV2Containerd) {
+			return fmt.Sprintf("the node runs cgroup v2, which containerd %s doesn't support before %d.%d",
+				state.Containerd, minCgroupV2Containerd[0], minCgroupV2Containerd[1]), CgroupV1
+		}
+		return "", ""
+	}
+
+	if kube != "" && atLeast(kube, minCgroupV1RefusedKube) {
+		problem := fmt.Sprintf("the node runs cgroup v1, on which the kubelet of Kubernetes %s refuses to start", kube)
+		if !v2Kernel {
+			return fmt.Sprintf("%s. Upgrade the kernel %s to %d.%d or later to run cgroup v2",
+				problem, state.Kernel, minCgroupV2Kernel[0], minCgroupV2Kernel[1]), ""
+		}
+		return problem, CgroupV2
+	}
+	return "", ""
+}
+
+// switchCgroupScript sets the cgroup version the node boots on, with grubby
+// or in the GRUB config. The %d is 1 for cgroup v2 and 0 for cgroup v1.
+const switchCgroupScript = `set -e
+ARG=systemd.unified_cgroup_hierarchy=%d
+if command -v grubby > /dev/null; then
+    grubby --update-kernel=ALL --remove-args=systemd.unified_cgroup_hierarchy --args=$ARG
+else
+    sed -i -E 's/ ?systemd\.unified_cgroup_hierarchy=[^ "]*//g; s/^(GRUB_CMDLINE_LINUX=")/\1'$ARG' /' /etc/default/grub
+    if command -v update-grub > /dev/null; then
+        update-grub
+    else
+        grub2-mkconfig -o /boot/grub2/grub.cfg
+    fi
+fi
+`
+
+// switchCgroup makes the node of exec boot on the cgroup version mode and
+// records the reboot applying it
+func switchCgroup(exec cmdexec.Executor, mode string) error {
+	unified, check := 0, `[ "$(stat -fc %T /sys/fs/cgroup)" = tmpfs ]`
+	if mode == CgroupV2 {
+		unified, check = 1, `[ "$(stat -fc %T /sys/fs/cgroup)" = cgroup2fs ]`
+	}
+	if _, err := exec.RunArgs("bash", "-c", fmt.Sprintf(switchCgroupScript, unified)); err != nil {
+		return fmt.Errorf("unable to switch the node to cgroup %s: %w", mode, err)
+	}
+	return RequireReboot(exec, fmt.Sprintf("the node boots on cgroup %s", mode), check)
+}
+
+// checkCgroups checks the cgroup version of the node of exec runs the kubelet
+// of KubernetesVersion, switching the node to the cgroup version it needs with
+// FixCgroup. The switch applies once the node rebooted, which prep-node does
+// with --allow-reboot.
+func checkCgroups(exec cmdexec.Executor) platform.Check {
+	name := "Cgroup version check"
+	state, err := readCgroupState(exec)
+	if err != nil {
+		return platform.Check{ID: platform.CheckIDCgroup, Name: name, Mandatory: true, Result: false, Err: err, UserErr: "unable to read the cgroup version of the node"}
+	}
+	zap.S().Debugf("Node runs cgroup %s on kernel %s, containerd %q, for Kubernetes %q", state.Mode, state.Kernel, state.Containerd, KubernetesVersion)
+
+	problem, want := cgroupProblem(state, KubernetesVersion)
+	if problem == "" {
+		return platform.Check{ID: platform.CheckIDCgroup, Name: name, Mandatory: true, Result: true}
+	}
+	fail := func(userErr string) platform.Check {
+		return platform.Check{ID: platform.CheckIDCgroup, Name: name, Mandatory: true, Result: false, Err: fmt.Errorf("%s", problem), UserErr: userErr}
+	}
+	if want == "" {
+		return fail(problem)
+	}
+	if !FixCgroup {
+		return fail(fmt.Sprintf("%s. Switch the node to cgroup %s with --fix-cgroup, it reboots the node with --allow-reboot", problem, want))
+	}
+	if err := switchCgroup(exec, want); err != nil {
+		return platform.Check{ID: platform.CheckIDCgroup, Name: name, Mandatory: true, Result: false, Err: err, UserErr: err.Error()}
+	}
+	if !util.AllowReboot {
+		return fail(fmt.Sprintf("%s. The node boots on cgroup %s once rebooted, reboot it or run prep-node with --allow-reboot", problem, want))
+	}
+	zap.S().Debugf("Node switched to cgroup %s, prep-node reboots it", want)
+	return platform.Check{ID: platform.CheckIDCgroup, Name: name, Mandatory: true, Result: true}
+}
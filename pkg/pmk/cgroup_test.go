@@ -0,0 +1,104 @@
+package pmk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCgroupState(t *testing.T) {
+	state, err := parseCgroupState("cgroup2fs\n5.14.0-70.el9.x86_64\ncontainerd github.com/containerd/containerd v1.6.8 9cd3357b\n")
+	assert.NoError(t, err)
+	assert.Equal(t, cgroupState{Mode: CgroupV2, Kernel: "5.14.0-70.el9.x86_64", Containerd: "1.6.8"}, state)
+
+	state, err = parseCgroupState("tmpfs\n3.10.0-1160.el7.x86_64\n")
+	assert.NoError(t, err)
+	assert.Equal(t, cgroupState{Mode: CgroupV1, Kernel: "3.10.0-1160.el7.x86_64"}, state)
+
+	_, err = parseCgroupState("ext4\n5.4.0\n")
+	assert.Error(t, err)
+}
+
+func TestCgroupProblem(t *testing.T) {
+	cases := map[string]struct {
+		state   cgroupState
+		kube    string
+		problem string
+		want    string
+	}{
+		"V2": {cgroupState{Mode: CgroupV2, Kernel: "5.15.0-56-generic", Containerd: "1.6.8"}, "1.26.3", "", ""},
+		"V2OldKube": {cgroupState{Mode: CgroupV2, Kernel: "5.15.0"}, "1.21.3-pmk.72",
+			"the node runs cgroup v2, which the kubelet of Kubernetes 1.21.3 doesn't support before 1.22", CgroupV1},
+		"V2OldContainerd": {cgroupState{Mode: CgroupV2, Kernel: "5.15.0", Containerd: "1.3.9"}, "1.26.3",
+			"the node runs cgroup v2, which containerd 1.3.9 doesn't support before 1.4", CgroupV1},
+		"V2OldKernel": {cgroupState{Mode: CgroupV2, Kernel: "4.14.0"}, "1.26.3",
+			"the node runs cgroup v2 on kernel 4.14.0, the kubelet needs kernel 4.15 or later for it", CgroupV1},
+		"V2UnknownKube": {cgroupState{Mode: CgroupV2, Kernel: "5.15.0"}, "", "", ""},
+		"V1":            {cgroupState{Mode: CgroupV1, Kernel: "5.15.0"}, "1.26.3", "", ""},
+		"V1Refused": {cgroupState{Mode: CgroupV1, Kernel: "5.15.0"}, "1.35.0",
+			"the node runs cgroup v1, on which the kubelet of Kubernetes 1.35.0 refuses to start", CgroupV2},
+		"V1RefusedOldKernel": {cgroupState{Mode: CgroupV1, Kernel: "3.10.0-1160.el7.x86_64"}, "1.35.0",
+			"the node runs cgroup v1, on which the kubelet of Kubernetes 1.35.0 refuses to start. Upgrade the kernel 3.10.0-1160.el7.x86_64 to 4.15 or later to run cgroup v2", ""},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			problem, want := cgroupProblem(tc.state, tc.kube)
+			assert.Equal(t, tc.problem, problem)
+			assert.Equal(t, tc.want, want)
+		})
+	}
+}
+
+func TestKubernetesVersion(t *testing.T) {
+	assert.Equal(t, "1.26.3-pmk.12", NewestKubernetesVersion([]string{"1.21.3-pmk.72", "1.26.3-pmk.12", "1.22.9-pmk.40", "latest"}))
+	assert.Equal(t, "", NewestKubernetesVersion(nil))
+
+	for version, want := range map[string]string{"1.21": "1.21.0", "v1.21.3": "1.21.3"} {
+		got, err := NormalizeKubernetesVersion(version)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := NormalizeKubernetesVersion("1")
+	assert.EqualError(t, err, `invalid Kubernetes version "1", it is given as 1.21 or 1.21.3`)
+	_, err = NormalizeKubernetesVersion("1.21-pmk")
+	assert.Error(t, err)
+}
+
+func TestCheckCgroupsFix(t *testing.T) {
+	var ran []string
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			if name != "bash" {
+				return "", errors.New("unexpected command")
+			}
+			ran = append(ran, args[1])
+			if args[1] == cgroupScript {
+				return "tmpfs\n5.15.0\n", nil
+			}
+			return "", nil
+		},
+	}
+	KubernetesVersion, FixCgroup = "1.35.0", false
+	defer func() { KubernetesVersion, FixCgroup, util.AllowReboot = "", false, false }()
+
+	check := checkCgroups(exec)
+	assert.False(t, check.Result)
+	assert.Contains(t, check.UserErr, "with --fix-cgroup")
+	assert.Len(t, ran, 1)
+
+	FixCgroup = true
+	check = checkCgroups(exec)
+	assert.False(t, check.Result)
+	assert.Contains(t, check.UserErr, "boots on cgroup v2 once rebooted")
+	if assert.Len(t, ran, 4) {
+		assert.Contains(t, ran[2], "systemd.unified_cgroup_hierarchy=1")
+		assert.True(t, strings.Contains(ran[3], rebootMarker))
+	}
+
+	util.AllowReboot = true
+	assert.True(t, checkCgroups(exec).Result)
+}
@@ -0,0 +1,148 @@
+package pmk
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/fips"
+	"github.com/platform9/pf9ctl/pkg/socks"
+	"go.uber.org/zap"
+)
+
+// CertExpiryWarning is how long before expiry a DU certificate is reported as expiring
+var CertExpiryWarning = 30 * 24 * time.Hour
+
+// duCheckTimeout bounds every connection made to check the DU
+var duCheckTimeout = 15 * time.Second
+
+// EndpointHealth is the result of a request to an API endpoint of the DU
+type EndpointHealth struct {
+	Name    string
+	URL     string
+	Status  int
+	Latency time.Duration
+	Err     error
+}
+
+// Healthy is true when the endpoint answered without a server error
+func (e EndpointHealth) Healthy() bool {
+	return e.Err == nil && e.Status > 0 && e.Status < 500
+}
+
+// CertificateInfo describes the TLS certificate served for a host of the DU
+type CertificateInfo struct {
+	Host     string
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+	// VerifyErr is set when the certificate isn't trusted, e.g. when it has
+	// expired or doesn't match the host
+	VerifyErr error
+	Err       error
+}
+
+// Status summarizes the certificate as OK, EXPIRING, EXPIRED or INVALID
+func (c CertificateInfo) Status(now time.Time) string {
+	switch {
+	case c.Err != nil:
+		return "UNREACHABLE"
+	case now.After(c.NotAfter):
+		return "EXPIRED"
+	case c.VerifyErr != nil:
+		return "INVALID"
+	case c.NotAfter.Sub(now) < CertExpiryWarning:
+		return "EXPIRING"
+	}
+	return "OK"
+}
+
+// CheckEndpoint sends a GET request to url and measures how long the endpoint
+// takes to answer. token is sent when set.
+func CheckEndpoint(name, url, token string) EndpointHealth {
+	health := EndpointHealth{Name: name, URL: url}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		health.Err = err
+		return health
+	}
+	if token != "" {
+		req.Header.Set("X-Auth-Token", token)
+	}
+
+	client := http.Client{Timeout: duCheckTimeout}
+	start := time.Now()
+	resp, err := client.Do(req)
+	health.Latency = time.Since(start)
+	if err != nil {
+		zap.S().Debugf("Endpoint %s is unreachable: %s", url, err)
+		health.Err = err
+		return health
+	}
+	resp.Body.Close()
+	health.Status = resp.StatusCode
+	return health
+}
+
+// CheckCertificate reads the certificate served for host, which is either a
+// host name or a URL, and verifies it against the system roots.
+func CheckCertificate(host string) CertificateInfo {
+	hostname, addr := certAddress(host)
+	info := CertificateInfo{Host: hostname}
+
+	// The certificate is read even when it isn't trusted, it is verified below
+	conn, err := socks.DialTLS(addr, duCheckTimeout, fips.TLSConfig(&tls.Config{ServerName: hostname, InsecureSkipVerify: true}))
+	if err != nil {
+		info.Err = err
+		return info
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		info.Err = fmt.Errorf("no certificate served")
+		return info
+	}
+	leaf := certs[0]
+	info.Subject = leaf.Subject.CommonName
+	info.Issuer = leaf.Issuer.CommonName
+	info.NotAfter = leaf.NotAfter
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, info.VerifyErr = leaf.Verify(x509.VerifyOptions{DNSName: hostname, Intermediates: intermediates})
+	return info
+}
+
+// certAddress returns the host name and the address to dial for host
+func certAddress(host string) (string, string) {
+	if strings.Contains(host, "://") {
+		if u, err := url.Parse(host); err == nil {
+			host = u.Host
+		}
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		return hostname, host
+	}
+	return host, net.JoinHostPort(host, "443")
+}
+
+// DUEndpoints returns the API endpoints of the DU checked by check-du, the
+// ones requiring a token are skipped when projectID is empty.
+func DUEndpoints(fqdn, projectID string) map[string]string {
+	endpoints := map[string]string{
+		"keystone": fmt.Sprintf("%s/keystone/v3", fqdn),
+	}
+	if projectID != "" {
+		endpoints["qbert"] = fmt.Sprintf("%s/qbert/v3/%s/clusters", fqdn, projectID)
+		endpoints["resmgr"] = fmt.Sprintf("%s/resmgr/v1/hosts", fqdn)
+	}
+	return endpoints
+}
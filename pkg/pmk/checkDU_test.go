@@ -0,0 +1,78 @@
+package pmk
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "token" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	health := CheckEndpoint("qbert", server.URL, "token")
+	assert.Nil(t, health.Err)
+	assert.Equal(t, http.StatusOK, health.Status)
+	assert.True(t, health.Healthy())
+
+	health = CheckEndpoint("qbert", server.URL, "")
+	assert.Equal(t, http.StatusServiceUnavailable, health.Status)
+	assert.False(t, health.Healthy())
+
+	server.Close()
+	health = CheckEndpoint("qbert", server.URL, "token")
+	assert.NotNil(t, health.Err)
+	assert.False(t, health.Healthy())
+}
+
+func TestCheckCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The test certificate is served but not trusted
+	cert := CheckCertificate(server.URL)
+	assert.Nil(t, cert.Err)
+	assert.Equal(t, "127.0.0.1", cert.Host)
+	assert.Equal(t, server.Certificate().NotAfter, cert.NotAfter)
+	assert.NotNil(t, cert.VerifyErr)
+	assert.Equal(t, "INVALID", cert.Status(time.Now()))
+}
+
+func TestCertificateStatus(t *testing.T) {
+	now := time.Now()
+	cases := map[string]struct {
+		cert CertificateInfo
+		want string
+	}{
+		"OK":          {cert: CertificateInfo{NotAfter: now.Add(90 * 24 * time.Hour)}, want: "OK"},
+		"Expiring":    {cert: CertificateInfo{NotAfter: now.Add(10 * 24 * time.Hour)}, want: "EXPIRING"},
+		"Expired":     {cert: CertificateInfo{NotAfter: now.Add(-time.Hour), VerifyErr: errors.New("expired")}, want: "EXPIRED"},
+		"Untrusted":   {cert: CertificateInfo{NotAfter: now.Add(90 * 24 * time.Hour), VerifyErr: errors.New("unknown authority")}, want: "INVALID"},
+		"Unreachable": {cert: CertificateInfo{Err: errors.New("connection refused")}, want: "UNREACHABLE"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.cert.Status(now))
+		})
+	}
+}
+
+func TestCertAddress(t *testing.T) {
+	cases := map[string][2]string{
+		"https://example.platform9.io":      {"example.platform9.io", "example.platform9.io:443"},
+		"example-region1.platform9.io":      {"example-region1.platform9.io", "example-region1.platform9.io:443"},
+		"https://example.platform9.io:8443": {"example.platform9.io", "example.platform9.io:8443"},
+	}
+	for host, want := range cases {
+		hostname, addr := certAddress(host)
+		assert.Equal(t, want, [2]string{hostname, addr})
+	}
+}
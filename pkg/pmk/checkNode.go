@@ -5,16 +5,17 @@ package pmk
 import (
 	"fmt"
 	"strings"
-	"time"
 
-	"github.com/briandowns/spinner"
 	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/keystone"
 	"github.com/platform9/pf9ctl/pkg/objects"
 	"github.com/platform9/pf9ctl/pkg/platform"
 	"github.com/platform9/pf9ctl/pkg/platform/centos"
 	"github.com/platform9/pf9ctl/pkg/platform/debian"
+	"github.com/platform9/pf9ctl/pkg/ui"
+	"github.com/platform9/pf9ctl/pkg/util"
 	"go.uber.org/zap"
 )
 
@@ -39,41 +40,47 @@ var WarningOptionalChecks bool
 
 // CheckNode checks the prerequisites for k8s stack
 func CheckNode(ctx objects.Config, allClients client.Client, auth keystone.KeystoneAuth, nc objects.NodeConfig) (CheckNodeResult, error) {
-	// Building our new spinner
-	s := spinner.New(spinner.CharSets[9], 100*time.Millisecond)
-	s.Color("red")
+	result, _, err := checkNode(ctx, allClients, auth, nc)
+	return result, err
+}
+
+// CheckNodeWithReport checks the prerequisites like CheckNode and returns the
+// preflight report of the checks, which is nil when they could not be run.
+func CheckNodeWithReport(ctx objects.Config, allClients client.Client, auth keystone.KeystoneAuth, nc objects.NodeConfig) (CheckNodeResult, *PreflightReport, error) {
+	result, checks, err := checkNode(ctx, allClients, auth, nc)
+	if checks == nil {
+		return result, nil, err
+	}
+	report, reportErr := NewPreflightReport(allClients.Executor, result, checks)
+	if reportErr != nil {
+		zap.S().Debugf("Unable to create the preflight report: %s", reportErr)
+		if err == nil {
+			err = fmt.Errorf("unable to create the preflight report: %w", reportErr)
+		}
+	}
+	return result, report, err
+}
 
+func checkNode(ctx objects.Config, allClients client.Client, auth keystone.KeystoneAuth, nc objects.NodeConfig) (CheckNodeResult, []platform.Check, error) {
 	zap.S().Debug("Received a call to check node.")
 
-	isSudo := CheckSudo(allClients.Executor)
-	if !isSudo {
-		return RequiredFail, fmt.Errorf("User executing this CLI is not allowed to switch to privileged (sudo) mode")
-	}
-	os, err := ValidatePlatform(allClients.Executor)
+	platform, err := nodePlatform(allClients.Executor)
 	if err != nil {
-		return RequiredFail, err
+		return RequiredFail, nil, err
 	}
 
-	var platform platform.Platform
-	switch os {
-	case "debian":
-		platform = debian.NewDebian(allClients.Executor)
-	case "redhat":
-		platform = centos.NewCentOS(allClients.Executor)
-	default:
-		return RequiredFail, fmt.Errorf("This OS is not supported. Supported operating systems are: Ubuntu (18.04, 20.04), CentOS 7.[3-9], RHEL 7.[3-9] & RHEL 8.[5-6]")
-	}
+	events := newPhaseTracker(allClients, auth, segmentEventCheck, "check-node")
+	events.begin(phaseStart)
+	events.pass()
 
-	if err = allClients.Segment.SendEvent("Starting CheckNode", auth, checkPass, ""); err != nil {
-		zap.S().Debugf("Unable to send Segment event for check node. Error: %s", err.Error())
+	if ActiveCheckPolicy != nil {
+		fmt.Println(color.Yellow("! ") + ActiveCheckPolicy.String())
 	}
-
-	s.Start() // Start the spinner
-	defer s.Stop()
 	zap.S().Debug("Running pre-requisite checks and installing any missing OS packages")
-	s.Suffix = " Running pre-requisite checks and installing any missing OS packages"
-	checks := platform.Check()
-	s.Stop()
+	phase := ui.StartPhase("Running pre-requisite checks and installing any missing OS packages")
+	checks := runChecks(ctx, allClients.Executor, platform)
+	phase.Stop()
+	ActiveCheckPolicy.apply(checks)
 
 	//We will print console if any missing os packages installed
 	if debian.MissingPkgsInstalledDebian || centos.MissingPkgsInstalledCentos {
@@ -85,18 +92,11 @@ func CheckNode(ctx objects.Config, allClients client.Client, auth keystone.Keyst
 	cleanInstallCheck := true
 
 	for _, check := range checks {
+		events.check(check.Name, check.Result, check.UserErr)
 		if check.Result {
-			segment_str := "CheckNode: " + check.Name
-			if err := allClients.Segment.SendEvent(segment_str, auth, checkPass, ""); err != nil {
-				zap.S().Debugf("Unable to send Segment event for check node. Error: %s", err.Error())
-			}
 			fmt.Printf(color.Green("✓ ")+"%s\n", check.Name)
 
 		} else {
-			segment_str := "CheckNode: " + check.Name
-			if err := allClients.Segment.SendEvent(segment_str, auth, checkFail, check.UserErr); err != nil {
-				zap.S().Debugf("Unable to send Segment event for check node. Error: %s", err.Error())
-			}
 			// To print warning "!", if --skipchecks flag passed and optional checks failed.
 			if WarningOptionalChecks && !check.Mandatory {
 				fmt.Printf(color.Yellow("! ")+"%s - %s\n", check.Name, check.UserErr)
@@ -120,9 +120,7 @@ func CheckNode(ctx objects.Config, allClients client.Client, auth keystone.Keyst
 		}
 	}
 
-	if err = allClients.Segment.SendEvent("CheckNode complete", auth, checkPass, ""); err != nil {
-		zap.S().Debugf("Unable to send Segment event for check node. Error: %s", err.Error())
-	}
+	events.complete()
 	fmt.Printf("\n")
 	if mandatoryCheck {
 		fmt.Println(color.Green("✓ ") + "Completed Pre-Requisite Checks successfully\n")
@@ -132,26 +130,106 @@ func CheckNode(ctx objects.Config, allClients client.Client, auth keystone.Keyst
 	removeCurrentInstallation := ""
 	if !cleanInstallCheck {
 		fmt.Println(color.Yellow("\nPrevious installation found"))
-		if !nc.RemoveExistingPkgs {
+		if !nc.RemoveExistingPkgs && !nc.NoPrompt {
 			fmt.Println(color.Yellow("Reinstall Required..."))
 			fmt.Print("Remove Current Installation Type ('yes'/'no'):")
 			fmt.Scanf("%s", &removeCurrentInstallation)
 		}
 		if nc.RemoveExistingPkgs || strings.ToLower(removeCurrentInstallation) == "yes" {
-			DecommissionNode(&ctx, nc, false)
-			return CleanInstallFail, nil
+			if err := DecommissionNode(&ctx, nc, false); err != nil {
+				return CleanInstallFail, checks, err
+			}
+			return CleanInstallFail, checks, nil
 		}
 
-		return RequiredFail, nil
+		return RequiredFail, checks, nil
 
 	}
 
 	if !mandatoryCheck {
-		return RequiredFail, nil
+		return RequiredFail, checks, nil
 	} else if !optionalCheck {
-		return OptionalFail, nil
+		return OptionalFail, checks, nil
 	} else {
-		return PASS, nil
+		return PASS, checks, nil
 	}
 
 }
+
+// nodePlatform returns the platform of the node of exec once it is supported
+// and commands can be run on it with sudo. It detects the environment of the
+// node as well.
+func nodePlatform(exec cmdexec.Executor) (platform.Platform, error) {
+	isSudo := CheckSudo(exec)
+	if !isSudo {
+		return nil, fmt.Errorf("User executing this CLI is not allowed to switch to privileged (sudo) mode")
+	}
+	os, err := ValidatePlatform(exec)
+	if err != nil {
+		return nil, err
+	}
+
+	var p platform.Platform
+	switch os {
+	case "debian":
+		p = debian.NewDebian(exec)
+	case "redhat":
+		p = centos.NewCentOS(exec)
+	default:
+		return nil, fmt.Errorf("This OS is not supported. Supported operating systems are: Ubuntu (18.04, 20.04), CentOS 7.[3-9], RHEL 7.[3-9] & RHEL 8.[5-6]")
+	}
+
+	util.NodeEnvironment = DetectEnvironment(exec)
+	zap.S().Debugf("Node environment: %s", util.NodeEnvironment)
+	return p, nil
+}
+
+// runChecks runs the pre-requisite checks of the node selected with
+// platform.SelectChecks, installing the missing OS packages
+func runChecks(ctx objects.Config, exec cmdexec.Executor, p platform.Platform) []platform.Check {
+	checks := p.Check()
+	if platform.Enabled(platform.CheckIDMountSpace) {
+		checks = append(checks, checkMounts(exec, ctx.WorkDir)...)
+	}
+	if platform.Enabled(platform.CheckIDHostname) {
+		checks = append(checks, checkHostname(exec))
+	}
+	if platform.Enabled(platform.CheckIDDNSResolver) {
+		checks = append(checks, platform.CheckDNS(exec, DUHost(ctx.Fqdn)))
+	}
+	if platform.Enabled(platform.CheckIDDNSOptions) {
+		checks = append(checks, platform.CheckDNSOptions(exec))
+	}
+	if platform.Enabled(platform.CheckIDCgroup) {
+		checks = append(checks, checkCgroups(exec))
+	}
+	if platform.Enabled(platform.CheckIDContainerRuntime) {
+		checks = append(checks, checkRuntimes(exec))
+	}
+	if platform.Enabled(platform.CheckIDFIPS) {
+		checks = append(checks, fipsChecks(exec)...)
+	}
+	checks = append(checks, checkHardening(exec)...)
+	if platform.Enabled(platform.CheckIDClockSkew) {
+		checks = append(checks, checkClockSkew(exec, ctx.Fqdn))
+	}
+	return checks
+}
+
+// checkMounts checks the filesystems of the node have room for the installer
+// in the work directory and for the Platform9 packages
+func checkMounts(exec cmdexec.Executor, dir string) []platform.Check {
+	reqs := append([]platform.MountRequirement{}, platform.DefaultMountRequirements...)
+	if dir, err := resolveWorkDir(exec, dir); err == nil {
+		reqs = append(reqs, platform.MountRequirement{Path: dir, MinFreeMB: MinWorkDirSpace, MinFreeInodes: minWorkDirInodes})
+	} else {
+		zap.S().Debugf("Unable to get the work directory, skipping its disk space check: %s", err)
+	}
+	return platform.CheckMounts(exec, reqs)
+}
+
+// checkHostname checks the hostname of the node resolves, adding it to
+// /etc/hosts when --fix-hostname is set
+func checkHostname(exec cmdexec.Executor) platform.Check {
+	return platform.CheckHostname(exec, util.FixHostname)
+}
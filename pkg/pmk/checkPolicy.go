@@ -0,0 +1,100 @@
+package pmk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	checkPolicyKind    = "CheckPolicy"
+	checkPolicyVersion = 1
+)
+
+// CheckPolicy changes the severity of the checks, like swap being optional
+// on the nodes of a lab. A failed check made optional no longer stops
+// prep-node, and a failed check made required does.
+type CheckPolicy struct {
+	Kind    string `yaml:"kind"`
+	Version int    `yaml:"version"`
+	// Severities are the severities of the checks by ID, required or
+	// optional
+	Severities map[string]string `yaml:"severities"`
+
+	// path is the file the policy was read from
+	path string
+}
+
+// ActiveCheckPolicy is the policy of --check-policy the checks are run with,
+// none when nil
+var ActiveCheckPolicy *CheckPolicy
+
+// ReadCheckPolicy reads a check policy from a YAML file
+func ReadCheckPolicy(path string) (*CheckPolicy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read check policy: %w", err)
+	}
+	policy := &CheckPolicy{path: path}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid check policy %s: %w", path, err)
+	}
+	if policy.Kind != checkPolicyKind || policy.Version != checkPolicyVersion {
+		return nil, fmt.Errorf("%s is not a version %d check policy, it should start with kind: %s and version: %d", path, checkPolicyVersion, checkPolicyKind, checkPolicyVersion)
+	}
+	for id, severity := range policy.Severities {
+		if _, found := platform.LookupCheckID(id); !found {
+			return nil, fmt.Errorf("invalid check policy %s: %w", path, platform.UnknownCheckError(id))
+		}
+		if severity != platform.SeverityRequired && severity != platform.SeverityOptional {
+			return nil, fmt.Errorf("invalid check policy %s: severity of check %s should be %s or %s", path, id, platform.SeverityRequired, platform.SeverityOptional)
+		}
+	}
+	return policy, nil
+}
+
+// Path is the file the policy was read from
+func (p *CheckPolicy) Path() string {
+	return p.path
+}
+
+// apply sets the severity of the checks the policy changes
+func (p *CheckPolicy) apply(checks []platform.Check) {
+	if p == nil {
+		return
+	}
+	for i, check := range checks {
+		info, found := platform.LookupCheck(check)
+		if severity, ok := p.Severities[info.ID]; found && ok {
+			checks[i].Mandatory = severity == platform.SeverityRequired
+		}
+	}
+}
+
+// Changes returns the checks whose severity the policy changes, like swap:
+// optional (default required), sorted by ID
+func (p *CheckPolicy) Changes() []string {
+	var changes []string
+	for id, severity := range p.Severities {
+		info, _ := platform.LookupCheckID(id)
+		change := fmt.Sprintf("%s: %s", id, severity)
+		if info.Severity != severity {
+			change += fmt.Sprintf(" (default %s)", info.Severity)
+		}
+		changes = append(changes, change)
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// String describes the policy as printed before the checks
+func (p *CheckPolicy) String() string {
+	if len(p.Severities) == 0 {
+		return fmt.Sprintf("Check policy %s: no severity changed", p.path)
+	}
+	return fmt.Sprintf("Check policy %s: %s", p.path, strings.Join(p.Changes(), ", "))
+}
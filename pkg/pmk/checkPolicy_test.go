@@ -0,0 +1,91 @@
+package pmk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+const labPolicy = `kind: CheckPolicy
+version: 1
+severities:
+  swap: optional
+  mount-space: optional
+  time-sync: required
+  cpu: optional
+`
+
+func TestReadCheckPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "policy.yaml")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(labPolicy), 0600))
+	policy, err := ReadCheckPolicy(file)
+	assert.Nil(t, err)
+	assert.Equal(t, file, policy.Path())
+	// The checks keeping their severity have no default shown
+	assert.Equal(t, []string{
+		"cpu: optional",
+		"mount-space: optional (default required)",
+		"swap: optional (default required)",
+		"time-sync: required (default optional)",
+	}, policy.Changes())
+	assert.Equal(t, "Check policy "+file+": cpu: optional, mount-space: optional (default required), swap: optional (default required), time-sync: required (default optional)", policy.String())
+
+	checks := []platform.Check{
+		{ID: platform.CheckIDSwap, Name: "Disabling swap and removing swap in fstab", Mandatory: true},
+		{ID: platform.CheckIDMountSpace, Name: "Disk space and inodes check of /var", Mandatory: true},
+		{ID: platform.CheckIDTimeSync, Name: "Check time synchronization"},
+		{ID: platform.CheckIDPorts, Name: "PortCheck", Mandatory: true},
+		{Name: "Hardening check", Mandatory: true},
+	}
+	policy.apply(checks)
+	var mandatory []bool
+	for _, check := range checks {
+		mandatory = append(mandatory, check.Mandatory)
+	}
+	assert.Equal(t, []bool{false, false, true, true, true}, mandatory)
+
+	// No policy leaves the checks alone
+	var none *CheckPolicy
+	none.apply(checks)
+	assert.False(t, checks[0].Mandatory)
+}
+
+func TestReadCheckPolicyInvalid(t *testing.T) {
+	cases := map[string]struct {
+		policy string
+		err    string
+	}{
+		"Kind": {
+			policy: "kind: Cluster\nversion: 1\n",
+			err:    "%s is not a version 1 check policy, it should start with kind: CheckPolicy and version: 1",
+		},
+		"UnknownCheck": {
+			policy: "kind: CheckPolicy\nversion: 1\nseverities:\n  swapoff: optional\n",
+			err:    `invalid check policy %s: unknown check "swapoff", see check-node --list-checks for the IDs of the checks`,
+		},
+		"Severity": {
+			policy: "kind: CheckPolicy\nversion: 1\nseverities:\n  swap: warning\n",
+			err:    "invalid check policy %s: severity of check swap should be required or optional",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "policy")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			file := filepath.Join(dir, "policy.yaml")
+			assert.Nil(t, ioutil.WriteFile(file, []byte(tc.policy), 0600))
+			_, err = ReadCheckPolicy(file)
+			assert.EqualError(t, err, fmt.Sprintf(tc.err, file))
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package pmk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// MaxClockSkew is the largest difference allowed between the clock of the
+// node and the one of the DU, TLS and the validation of the tokens fail
+// with a larger one
+var MaxClockSkew = time.Minute
+
+// NTPServer is the NTP server the clock of the node is compared against
+// instead of the DU when set
+var NTPServer string
+
+// ntpEpochOffset is the number of seconds between the NTP epoch, 1900, and
+// the Unix one
+const ntpEpochOffset = 2208988800
+
+// clockTimeout bounds the requests reading the reference time
+const clockTimeout = 5 * time.Second
+
+// checkClockSkew compares the clock of the node against the one of the DU,
+// or of NTPServer when set
+func checkClockSkew(exec cmdexec.Executor, fqdn string) platform.Check {
+	name := "Clock skew check"
+
+	reference, refOffset, err := clockReference(fqdn)
+	if err != nil {
+		// The skew can't be told, which isn't a failure of the node
+		zap.S().Debugf("Unable to read the time of the %s: %s", reference, err)
+		return platform.Check{ID: platform.CheckIDClockSkew, Name: name, Mandatory: true, Result: true}
+	}
+	nodeOffset, err := nodeClockOffset(exec)
+	if err != nil {
+		return platform.Check{ID: platform.CheckIDClockSkew, Name: name, Mandatory: true, Result: false, Err: err, UserErr: "unable to read the clock of the node"}
+	}
+
+	skew := nodeOffset - refOffset
+	zap.S().Debugf("Clock of the node is %.1f seconds off the %s", skew.Seconds(), reference)
+	if math.Abs(skew.Seconds()) > MaxClockSkew.Seconds() {
+		userErr := fmt.Sprintf("clock of the node is %.0f seconds off the %s, more than the %.0f allowed. "+
+			"Sync it with NTP (chrony or systemd-timesyncd), TLS and token validation fail with a large skew",
+			skew.Seconds(), reference, MaxClockSkew.Seconds())
+		return platform.Check{ID: platform.CheckIDClockSkew, Name: name, Mandatory: true, Result: false, Err: fmt.Errorf("clock skew of %s", skew), UserErr: userErr}
+	}
+	return platform.Check{ID: platform.CheckIDClockSkew, Name: name, Mandatory: true, Result: true}
+}
+
+// clockReference returns the name of the reference clock and its offset from
+// the local clock
+func clockReference(fqdn string) (string, time.Duration, error) {
+	if NTPServer != "" {
+		reference := "NTP server " + NTPServer
+		offset, err := ntpClockOffset(NTPServer, clockTimeout)
+		return reference, offset, err
+	}
+	offset, err := duClockOffset(fqdn)
+	return "DU", offset, err
+}
+
+// duClockOffset returns the offset of the clock of the DU, from the Date
+// header of its response, from the local clock
+func duClockOffset(fqdn string) (time.Duration, error) {
+	client := http.Client{Timeout: clockTimeout}
+	start := time.Now()
+	resp, err := client.Head(fqdn)
+	if err != nil {
+		return 0, err
+	}
+	end := time.Now()
+	resp.Body.Close()
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid Date header: %w", err)
+	}
+	// The Date header is truncated to the second
+	date = date.Add(500 * time.Millisecond)
+	return date.Sub(start.Add(end.Sub(start) / 2)), nil
+}
+
+// ntpClockOffset returns the offset of the clock of an NTP server from the
+// local clock, with a single SNTP request
+func ntpClockOffset(server string, timeout time.Duration) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	// Version 3, client mode
+	req := make([]byte, 48)
+	req[0] = 0x1b
+	sent := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	if n < 48 {
+		return 0, fmt.Errorf("short NTP response of %d bytes", n)
+	}
+
+	serverReceived := ntpTime(resp[32:40])
+	serverSent := ntpTime(resp[40:48])
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+func ntpTime(b []byte) time.Time {
+	secs := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	frac := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(secs, frac*1e9>>32)
+}
+
+// nodeClockOffset returns the offset of the clock of the node from the local
+// clock. The command latency, of SSH for remote nodes, is halved.
+func nodeClockOffset(exec cmdexec.Executor) (time.Duration, error) {
+	start := time.Now()
+	out, err := exec.RunArgs("date", "+%s.%N")
+	if err != nil {
+		return 0, fmt.Errorf("unable to read the time of the node: %w", err)
+	}
+	end := time.Now()
+
+	secs, err := strconv.ParseFloat(strings.TrimSpace(out), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q of the node: %w", strings.TrimSpace(out), err)
+	}
+	node := time.Unix(0, int64(secs*1e9))
+	return node.Sub(start.Add(end.Sub(start) / 2)), nil
+}
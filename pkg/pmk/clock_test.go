@@ -0,0 +1,89 @@
+package pmk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+// dateExecutor answers date with the local time shifted by skew
+func dateExecutor(skew time.Duration, err error) *cmdexec.MockExecutor {
+	return &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			if err != nil {
+				return "", err
+			}
+			now := time.Now().Add(skew)
+			return fmt.Sprintf("%d.%09d\n", now.Unix(), now.Nanosecond()), nil
+		},
+	}
+}
+
+func TestCheckClockSkew(t *testing.T) {
+	cases := map[string]struct {
+		duSkew   time.Duration
+		nodeSkew time.Duration
+		nodeErr  error
+		pass     bool
+		userErr  string
+	}{
+		"InSync":     {pass: true},
+		"SmallSkew":  {nodeSkew: 20 * time.Second, pass: true},
+		"NodeAhead":  {nodeSkew: 5 * time.Minute, userErr: "clock of the node is 300 seconds off the DU, more than the 60 allowed"},
+		"DUAhead":    {duSkew: 10 * time.Minute, userErr: "clock of the node is -600 seconds off the DU, more than the 60 allowed"},
+		"NodeFailed": {nodeErr: errors.New("exit status 1"), userErr: "unable to read the clock of the node"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Date", time.Now().Add(tc.duSkew).UTC().Format(http.TimeFormat))
+			}))
+			defer server.Close()
+
+			check := checkClockSkew(dateExecutor(tc.nodeSkew, tc.nodeErr), server.URL)
+			assert.Equal(t, tc.pass, check.Result)
+			assert.True(t, check.Mandatory)
+			assert.Contains(t, check.UserErr, tc.userErr)
+		})
+	}
+}
+
+func TestCheckClockSkewUnreachableDU(t *testing.T) {
+	check := checkClockSkew(dateExecutor(time.Hour, nil), "http://127.0.0.1:1")
+	assert.True(t, check.Result)
+}
+
+func TestNTPClockOffset(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	skew := 90 * time.Second
+	go func() {
+		req := make([]byte, 48)
+		_, addr, err := conn.ReadFrom(req)
+		if err != nil {
+			return
+		}
+		resp := make([]byte, 48)
+		now := time.Now().Add(skew)
+		binary.BigEndian.PutUint32(resp[32:], uint32(now.Unix()+ntpEpochOffset))
+		binary.BigEndian.PutUint32(resp[40:], uint32(now.Unix()+ntpEpochOffset))
+		binary.BigEndian.PutUint32(resp[44:], uint32(int64(now.Nanosecond())<<32/1e9))
+		conn.WriteTo(resp, addr)
+	}()
+
+	offset, err := ntpClockOffset(conn.LocalAddr().String(), time.Second)
+	assert.Nil(t, err)
+	// The receive timestamp is truncated to the second
+	assert.InDelta(t, skew.Seconds(), offset.Seconds(), 1)
+}
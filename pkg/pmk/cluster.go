@@ -5,7 +5,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/briandowns/spinner"
 	"github.com/platform9/pf9ctl/pkg/client"
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/color"
@@ -15,6 +14,7 @@ import (
 	"github.com/platform9/pf9ctl/pkg/platform/centos"
 	"github.com/platform9/pf9ctl/pkg/platform/debian"
 	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/ui"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"go.uber.org/zap"
 )
@@ -22,45 +22,32 @@ import (
 // Bootstrap simply onboards the local node and attaches it as master to a newly created cluster.
 func Bootstrap(ctx objects.Config, c client.Client, req qbert.ClusterCreateRequest, keystoneAuth keystone.KeystoneAuth, bootConfig objects.NodeConfig) error {
 
-	if err1 := c.Segment.SendEvent("Starting Cluster creation(Bootstrap)", keystoneAuth, checkPass, ""); err1 != nil {
-		zap.S().Debugf("Unable to send Segment event for bootstrap node. Error: %s", err1.Error())
-	}
+	trackEvent(c, keystoneAuth, client.Event{Name: segmentEventBootstrap, Phase: phaseStart, Status: checkPass})
 
 	token := keystoneAuth.Token
-	clustername := fmt.Sprintf(" Creating a cluster %s", req.Name)
+	clustername := fmt.Sprintf("Creating a cluster %s", req.Name)
 	zap.S().Debug(clustername)
-	s := spinner.New(spinner.CharSets[9], 100*time.Millisecond)
-	s.Color("red")
-	s.Start() // Start the spinner
-	defer s.Stop()
-	s.Suffix = clustername
+	phase := ui.StartPhase(clustername)
+	defer phase.Stop()
 
 	clusterID, err := c.Qbert.CreateCluster(
 		req,
 		keystoneAuth.ProjectID,
 		keystoneAuth.Token)
 
-	s.Stop()
-
 	if err != nil {
-		fmt.Println(color.Red("x")+" Unable to create cluster. Error:", err)
+		phase.Fail(fmt.Sprintf("Unable to create cluster. Error: %s", err))
 		zap.S().Debug("Unable to create cluster. Error:", err)
-		if err = c.Segment.SendEvent("Cluster creation(Bootstrap)", keystoneAuth, checkFail, ""); err != nil {
-			zap.S().Debugf("Unable to send Segment event for bootstrap node. Error: %s", err.Error())
-		}
+		trackEvent(c, keystoneAuth, client.Event{Name: segmentEventBootstrap, Phase: phaseCreateCluster, Status: checkFail, Err: err})
 		return fmt.Errorf("Unable to create cluster " + req.Name)
 	}
 
-	fmt.Println(color.Green("✓") + " Cluster creation completed")
+	phase.Succeed("Cluster creation completed")
 	zap.S().Debug("Cluster creation completed")
-	if err = c.Segment.SendEvent("Cluster creation(Bootstrap)", keystoneAuth, checkPass, ""); err != nil {
-		zap.S().Debugf("Unable to send Segment event for bootstrap node. Error: %s", err.Error())
-	}
+	trackEvent(c, keystoneAuth, client.Event{Name: segmentEventBootstrap, Phase: phaseCreateCluster, Status: checkPass})
 
-	s.Color("red")
-	s.Start() // Start the spinner
-	defer s.Stop()
-	s.Suffix = " Checking Host Status"
+	phase = ui.StartPhase("Checking Host Status")
+	defer phase.Stop()
 	zap.S().Debug("Checking Host Status")
 	cmd := `grep ^host_id /etc/pf9/host_id.conf | cut -d = -f2 | cut -d ' ' -f2`
 	output, err := c.Executor.RunWithStdout("bash", "-c", cmd)
@@ -85,31 +72,24 @@ func Bootstrap(ctx objects.Config, c client.Client, req qbert.ClusterCreateReque
 		util.HostDown = true
 	}
 
-	s.Stop()
-
 	if !util.HostDown {
 
 		zap.S().Debugf("Host is connected")
-		fmt.Println(color.Green("✓") + " Host is connected")
-		if err = c.Segment.SendEvent("Host Connected(Bootstrap)", keystoneAuth, checkPass, ""); err != nil {
-			zap.S().Debugf("Unable to send Segment event for bootstrap node. Error: %s", err.Error())
-		}
+		phase.Succeed("Host is connected")
+		trackEvent(c, keystoneAuth, client.Event{Name: segmentEventBootstrap, Phase: phaseHostConnected, Status: checkPass})
 	} else {
-		fmt.Println(color.Red("x") + " Host is disconnected. Unable to attach this node to the cluster " + req.Name + " Run prep-node/authorize-node and try again")
+		phase.Fail("Host is disconnected. Unable to attach this node to the cluster " + req.Name + " Run prep-node/authorize-node and try again")
 		zap.S().Debug("Host is disconnected. Unable to attach this node to the cluster " + req.Name + " Run prep-node/authorize-node and try again")
-		if err = c.Segment.SendEvent("Host Connected(Bootstrap)", keystoneAuth, checkFail, ""); err != nil {
-			zap.S().Debugf("Unable to send Segment event for bootstrap node. Error: %s", err.Error())
-		}
+		err = fmt.Errorf("Host is disconnected. Unable to attach this node to the cluster " + req.Name + " Run prep-node/authorize-node and try again")
+		trackEvent(c, keystoneAuth, client.Event{Name: segmentEventBootstrap, Phase: phaseHostConnected, Status: checkFail, Err: err})
 		//Deleting the cluster if the host is disconnected
 		DeleteClusterBootstrap(clusterID, c, keystoneAuth, token)
-		return fmt.Errorf("Host is disconnected. Unable to attach this node to the cluster " + req.Name + " Run prep-node/authorize-node and try again")
+		return err
 	}
 
-	attachname := fmt.Sprintf(" Attaching node to the cluster %s", req.Name)
-	s.Color("red")
-	s.Start() // Start the spinner
-	defer s.Stop()
-	s.Suffix = attachname
+	attachname := fmt.Sprintf("Attaching node to the cluster %s", req.Name)
+	phase = ui.StartPhase(attachname)
+	defer phase.Stop()
 	zap.S().Debug(attachname)
 	time.Sleep(30 * time.Second)
 	var nodeIDs []string
@@ -119,14 +99,10 @@ func Bootstrap(ctx objects.Config, c client.Client, req qbert.ClusterCreateReque
 		clusterID,
 		keystoneAuth.ProjectID, keystoneAuth.Token, nodeIDs, "master")
 
-	s.Stop() //Stop the Spinner
-
 	if err != nil {
-		fmt.Println(color.Red("x") + " Unable to attach-node to cluster " + req.Name + "Run bootstrap again")
+		phase.Fail("Unable to attach-node to cluster " + req.Name + "Run bootstrap again")
 		zap.S().Debug("Unable to attach-node to cluster. Error:", err)
-		if err = c.Segment.SendEvent("Attach-Node(Bootstrap)", keystoneAuth, checkFail, ""); err != nil {
-			zap.S().Debugf("Unable to send Segment event for bootstrap node. Error: %s", err.Error())
-		}
+		trackEvent(c, keystoneAuth, client.Event{Name: segmentEventBootstrap, Phase: phaseAttach, Status: checkFail, Err: err})
 
 		//Deleting the cluster if the node is not attached to the cluster
 		DeleteClusterBootstrap(clusterID, c, keystoneAuth, token)
@@ -134,15 +110,10 @@ func Bootstrap(ctx objects.Config, c client.Client, req qbert.ClusterCreateReque
 		return fmt.Errorf("Unable to attach node to cluster " + req.Name + "Run bootstrap again")
 	}
 
-	fmt.Println(color.Green("✓") + " Attached node to the cluster")
+	phase.Succeed("Attached node to the cluster")
 	zap.S().Debug("Attached node to the cluster")
-	if err = c.Segment.SendEvent("Attach-Node(Bootstrap)", keystoneAuth, checkPass, ""); err != nil {
-		zap.S().Debugf("Unable to send Segment event for bootstrap node. Error: %s", err.Error())
-	}
-
-	if err = c.Segment.SendEvent("Bootstrap Completed Successfully", keystoneAuth, checkPass, ""); err != nil {
-		zap.S().Debugf("Unable to send Segment event for bootstrap node. Error: %s", err.Error())
-	}
+	trackEvent(c, keystoneAuth, client.Event{Name: segmentEventBootstrap, Phase: phaseAttach, Status: checkPass})
+	trackEvent(c, keystoneAuth, client.Event{Name: segmentEventBootstrap, Phase: phaseComplete, Status: checkPass})
 	fmt.Println(color.Green("✓") + " Bootstrap successfully finished")
 	zap.S().Debug("Bootstrap successfully finished")
 	zap.S().Debug("Cluster creation started....This may take a few minutes....Check the latest status in UI")
@@ -155,7 +126,7 @@ func PreReqBootstrap(executor cmdexec.Executor) (bool, bool, error) {
 
 	os, err := ValidatePlatform(executor)
 	if err != nil {
-		zap.S().Fatalf("OS version is not supported")
+		return false, false, fmt.Errorf("OS version is not supported: %w", err)
 	}
 
 	var Instance platform.Platform
@@ -171,14 +142,12 @@ func PreReqBootstrap(executor cmdexec.Executor) (bool, bool, error) {
 
 	val, err := Instance.CheckExistingInstallation()
 	if err != nil {
-		//zap.S().Fatalf("Could not run command Installation")
-		zap.S().Fatalf("Error %s", err)
+		return false, false, fmt.Errorf("unable to check the existing installation: %w", err)
 	}
 
 	val1, err1 := Instance.CheckKubernetesCluster()
 	if err1 != nil {
-		//zap.S().Fatalf("Could not run command Cluster")
-		zap.S().Fatalf("Error %s", err1)
+		return false, false, fmt.Errorf("unable to check the existing Kubernetes cluster: %w", err1)
 	}
 	return val, val1, nil
 }
@@ -186,16 +155,11 @@ func PreReqBootstrap(executor cmdexec.Executor) (bool, bool, error) {
 //Deleting the cluster if the node is not attached to the cluster
 func DeleteClusterBootstrap(clusterID string, c client.Client, keystoneAuth keystone.KeystoneAuth, token string) {
 	err := c.Qbert.DeleteCluster(clusterID, keystoneAuth.ProjectID, token)
+	trackEvent(c, keystoneAuth, client.Event{Name: segmentEventBootstrap, Phase: phaseDeleteCluster, Status: eventStatus(err), Err: err})
 
 	if err != nil {
-		if err = c.Segment.SendEvent("Delete Cluster(Bootstrap)", keystoneAuth, checkFail, ""); err != nil {
-			zap.S().Debugf("Unable to send Segment event for bootstrap node. Error: %s", err.Error())
-		}
-		zap.S().Debugf("Unable to delete cluster")
+		zap.S().Debugf("Unable to delete cluster: %s", err)
 	} else {
-		if err = c.Segment.SendEvent("Delete Cluster(Bootstrap)", keystoneAuth, checkPass, ""); err != nil {
-			zap.S().Debugf("Unable to send Segment event for bootstrap node. Error: %s", err.Error())
-		}
 		zap.S().Debugf("Deleted the cluster successfully")
 	}
 }
@@ -0,0 +1,189 @@
+package pmk
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/fips"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/socks"
+)
+
+// ClusterDescription is the posture of a cluster: its version and the
+// upgrades available to it, its addons, the expiry of the certificate of its
+// API server and the state of its etcd backups
+type ClusterDescription struct {
+	Name             string `json:"name"`
+	Uuid             string `json:"uuid"`
+	Status           string `json:"status"`
+	TaskStatus       string `json:"taskStatus"`
+	PmkVersion       string `json:"pmkVersion"`
+	ContainerRuntime string `json:"containerRuntime"`
+	APIEndpoint      string `json:"apiEndpoint"`
+
+	Addons  []qbert.ClusterAddon `json:"addons"`
+	Upgrade ClusterUpgrade       `json:"upgrade"`
+
+	CertExpiry time.Time `json:"certExpiry"`
+	// CertError is why the certificate of the API server couldn't be read
+	CertError string `json:"certError,omitempty"`
+
+	EtcdBackup ClusterEtcdBackup `json:"etcdBackup"`
+}
+
+// ClusterUpgrade is the upgrade channel of a cluster
+type ClusterUpgrade struct {
+	Available    bool   `json:"available"`
+	PatchVersion string `json:"patchVersion,omitempty"`
+	MinorVersion string `json:"minorVersion,omitempty"`
+	UpgradingTo  string `json:"upgradingTo,omitempty"`
+	// Versions are the supported pmk versions newer than the one of the cluster
+	Versions []string `json:"versions"`
+}
+
+// ClusterEtcdBackup is the etcd backup configuration of a cluster and the
+// status of its last backup task
+type ClusterEtcdBackup struct {
+	Enabled     bool   `json:"enabled"`
+	StorageType string `json:"storageType,omitempty"`
+	LocalPath   string `json:"localPath,omitempty"`
+	Schedule    string `json:"schedule,omitempty"`
+	TaskStatus  string `json:"taskStatus,omitempty"`
+	TaskError   string `json:"taskError,omitempty"`
+}
+
+// specAddons are the addons enabled through the flags of the cluster, for the
+// DUs without the clusteraddons API
+var specAddons = []struct {
+	key  string
+	name string
+}{
+	{"enableMetallb", "metallb"},
+	{"deployKubevirt", "kubevirt"},
+	{"deployLuigiOperator", "luigi"},
+	{"enableProfileAgent", "profile-agent"},
+	{"enableCAS", "cluster-autoscaler"},
+}
+
+// DescribeCluster builds the description of a cluster from its qbert spec,
+// its addons and the pmk versions supported by the region
+func DescribeCluster(spec map[string]interface{}, addons []qbert.ClusterAddon, versions qbert.PMKVersions) ClusterDescription {
+	d := ClusterDescription{
+		Name:             specString(spec, "name"),
+		Uuid:             specString(spec, "uuid"),
+		Status:           specString(spec, "status"),
+		TaskStatus:       specString(spec, "taskStatus"),
+		PmkVersion:       specString(spec, "kubeRoleVersion"),
+		ContainerRuntime: specString(spec, "containerRuntime"),
+		APIEndpoint:      clusterAPIEndpoint(spec),
+		Addons:           addons,
+	}
+
+	if len(d.Addons) == 0 {
+		for _, addon := range specAddons {
+			if specBool(spec, addon.key) {
+				d.Addons = append(d.Addons, qbert.ClusterAddon{Type: addon.name, Phase: "enabled"})
+			}
+		}
+		if ClusterTemplateFromSpec(spec).Monitoring {
+			d.Addons = append(d.Addons, qbert.ClusterAddon{Type: "monitoring", Phase: "enabled"})
+		}
+	}
+
+	d.Upgrade = ClusterUpgrade{
+		Available:   specBool(spec, "canUpgrade") || specBool(spec, "canMinorUpgrade") || specBool(spec, "canPatchUpgrade"),
+		UpgradingTo: specString(spec, "upgradingTo"),
+	}
+	if specBool(spec, "canPatchUpgrade") {
+		d.Upgrade.PatchVersion = specString(spec, "patchUpgradeRoleVersion")
+	}
+	if specBool(spec, "canMinorUpgrade") {
+		d.Upgrade.MinorVersion = specString(spec, "minorUpgradeRoleVersion")
+	}
+	for _, role := range versions.Roles {
+		if compareVersions(role.RoleVersion, d.PmkVersion) > 0 {
+			d.Upgrade.Versions = append(d.Upgrade.Versions, role.RoleVersion)
+		}
+	}
+	sort.Slice(d.Upgrade.Versions, func(i, j int) bool {
+		return compareVersions(d.Upgrade.Versions[i], d.Upgrade.Versions[j]) < 0
+	})
+
+	if backup, ok := spec["etcdBackup"].(map[string]interface{}); ok {
+		d.EtcdBackup = ClusterEtcdBackup{
+			Enabled:     specBool(backup, "isEtcdBackupEnabled"),
+			StorageType: specString(backup, "storageType"),
+			TaskStatus:  specString(backup, "taskStatus"),
+			TaskError:   specString(backup, "taskErrorDetail"),
+		}
+		if props, ok := backup["storageProperties"].(map[string]interface{}); ok {
+			d.EtcdBackup.LocalPath = specString(props, "localPath")
+		}
+		var schedule []string
+		if daily := specString(backup, "dailyBackupTime"); daily != "" {
+			schedule = append(schedule, fmt.Sprintf("daily at %s, keeping %d", daily, specInt(backup, "maxTimestampBackupCount")))
+		}
+		if interval := specInt(backup, "intervalInMins"); interval > 0 {
+			schedule = append(schedule, fmt.Sprintf("every %d minutes, keeping %d", interval, specInt(backup, "maxIntervalBackupCount")))
+		}
+		d.EtcdBackup.Schedule = strings.Join(schedule, ", ")
+	}
+	return d
+}
+
+// clusterAPIEndpoint returns the host the API server of the cluster is
+// reached at
+func clusterAPIEndpoint(spec map[string]interface{}) string {
+	for _, key := range []string{"externalDnsName", "masterVipIpv4", "masterIp"} {
+		if host := specString(spec, key); host != "" {
+			return host
+		}
+	}
+	return ""
+}
+
+// APIServerCertExpiry returns when the serving certificate of the API server
+// at endpoint expires. The certificate is only read, so it isn't verified.
+func APIServerCertExpiry(endpoint string, timeout time.Duration) (time.Time, error) {
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		endpoint = net.JoinHostPort(endpoint, "443")
+	}
+	conn, err := socks.DialTLS(endpoint, timeout, fips.TLSConfig(&tls.Config{InsecureSkipVerify: true}))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to reach the API server at %s: %w", endpoint, err)
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return time.Time{}, fmt.Errorf("API server at %s sent no certificate", endpoint)
+	}
+	return certs[0].NotAfter, nil
+}
+
+// compareVersions compares pmk versions like 1.21.3-pmk.72 by their numbers
+func compareVersions(a, b string) int {
+	split := func(v string) []int {
+		var nums []int
+		for _, f := range strings.FieldsFunc(v, func(r rune) bool { return r < '0' || r > '9' }) {
+			n, _ := strconv.Atoi(f)
+			nums = append(nums, n)
+		}
+		return nums
+	}
+	na, nb := split(a), split(b)
+	for i := 0; i < len(na) && i < len(nb); i++ {
+		if na[i] != nb[i] {
+			if na[i] < nb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(na) - len(nb)
+}
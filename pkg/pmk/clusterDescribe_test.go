@@ -0,0 +1,91 @@
+package pmk
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeCluster(t *testing.T) {
+	versions := qbert.PMKVersions{}
+	for _, v := range []string{"1.21.3-pmk.72", "1.22.9-pmk.10", "1.21.10-pmk.5", "1.20.11-pmk.9"} {
+		versions.Roles = append(versions.Roles, struct {
+			RoleVersion string `json:"roleVersion"`
+		}{v})
+	}
+
+	cases := map[string]struct {
+		spec   map[string]interface{}
+		addons []qbert.ClusterAddon
+		want   ClusterDescription
+	}{
+		"Posture": {
+			spec: map[string]interface{}{
+				"name": "c1", "uuid": "u1", "status": "ok", "taskStatus": "success",
+				"kubeRoleVersion": "1.21.3-pmk.72", "containerRuntime": "containerd",
+				"masterVipIpv4": "10.0.0.10",
+				"canUpgrade":    true, "canPatchUpgrade": true, "patchUpgradeRoleVersion": "1.21.10-pmk.5",
+				"etcdBackup": map[string]interface{}{
+					"isEtcdBackupEnabled": float64(1), "storageType": "local",
+					"storageProperties": map[string]interface{}{"localPath": "/etc/pf9/etcd-backup"},
+					"dailyBackupTime":   "02:00", "maxTimestampBackupCount": float64(3),
+					"taskStatus": "failed", "taskErrorDetail": "disk full",
+				},
+			},
+			addons: []qbert.ClusterAddon{{Type: "coredns", Version: "1.8.0", Phase: "Installed"}},
+			want: ClusterDescription{
+				Name: "c1", Uuid: "u1", Status: "ok", TaskStatus: "success",
+				PmkVersion: "1.21.3-pmk.72", ContainerRuntime: "containerd", APIEndpoint: "10.0.0.10",
+				Addons: []qbert.ClusterAddon{{Type: "coredns", Version: "1.8.0", Phase: "Installed"}},
+				Upgrade: ClusterUpgrade{
+					Available: true, PatchVersion: "1.21.10-pmk.5",
+					Versions: []string{"1.21.10-pmk.5", "1.22.9-pmk.10"},
+				},
+				EtcdBackup: ClusterEtcdBackup{
+					Enabled: true, StorageType: "local", LocalPath: "/etc/pf9/etcd-backup",
+					Schedule: "daily at 02:00, keeping 3", TaskStatus: "failed", TaskError: "disk full",
+				},
+			},
+		},
+		"SpecAddons": {
+			spec: map[string]interface{}{
+				"kubeRoleVersion": "1.22.9-pmk.10", "externalDnsName": "api.example.com", "masterIp": "10.0.0.1",
+				"enableMetallb": true, "deployKubevirt": false, "tags": map[string]interface{}{"pf9-system:monitoring": "true"},
+			},
+			want: ClusterDescription{
+				PmkVersion: "1.22.9-pmk.10", APIEndpoint: "api.example.com",
+				Addons: []qbert.ClusterAddon{{Type: "metallb", Phase: "enabled"}, {Type: "monitoring", Phase: "enabled"}},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, DescribeCluster(tc.spec, tc.addons, versions))
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, 0, compareVersions("1.21.3-pmk.72", "1.21.3-pmk.72"))
+	assert.True(t, compareVersions("1.21.10-pmk.5", "1.21.3-pmk.72") > 0)
+	assert.True(t, compareVersions("1.21.3-pmk.9", "1.21.3-pmk.72") < 0)
+	assert.True(t, compareVersions("1.21.3", "") > 0)
+}
+
+func TestAPIServerCertExpiry(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	expiry, err := APIServerCertExpiry(strings.TrimPrefix(server.URL, "https://"), time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, server.Certificate().NotAfter, expiry)
+
+	_, err = APIServerCertExpiry("127.0.0.1:1", time.Second)
+	assert.NotNil(t, err)
+}
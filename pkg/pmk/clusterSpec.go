@@ -0,0 +1,213 @@
+package pmk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	clusterSpecKind    = "Cluster"
+	clusterSpecVersion = 1
+)
+
+// ClusterSpec is the declarative spec of a cluster: the settings it should
+// have, named like the ones of a cluster template, and the nodes which should
+// be attached to it. Only the settings given in the file are compared with
+// the cluster, so a spec can pin just the ones that matter.
+type ClusterSpec struct {
+	Kind     string          `yaml:"kind"`
+	Version  int             `yaml:"version"`
+	Name     string          `yaml:"name"`
+	Settings ClusterTemplate `yaml:"settings"`
+	Nodes    []NodeFileEntry `yaml:"nodes"`
+
+	// given are the settings of the file, in their order
+	given yaml.MapSlice
+}
+
+// SettingChange is a setting of the cluster which differs from the spec, Name
+// is its path like etcdBackup.intervalInMins
+type SettingChange struct {
+	Name string
+	Live interface{}
+	Want interface{}
+}
+
+// NodeChange is a node whose attachment differs from the spec. LiveRole is
+// empty for the nodes to attach and WantRole for the nodes to detach.
+type NodeChange struct {
+	IP       string
+	LiveRole string
+	WantRole string
+}
+
+// ClusterDiff is what differs between a cluster and its spec
+type ClusterDiff struct {
+	Name   string
+	Uuid   string
+	Exists bool
+
+	Settings []SettingChange
+	Nodes    []NodeChange
+}
+
+// Empty is true when the cluster matches its spec
+func (d ClusterDiff) Empty() bool {
+	return d.Exists && len(d.Settings) == 0 && len(d.Nodes) == 0
+}
+
+// ReadClusterSpec reads the spec of a cluster from a YAML file
+func ReadClusterSpec(path string) (ClusterSpec, error) {
+	var spec ClusterSpec
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return spec, fmt.Errorf("unable to read cluster spec: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+		return spec, fmt.Errorf("invalid cluster spec %s: %w", path, err)
+	}
+	if spec.Kind != clusterSpecKind || spec.Version != clusterSpecVersion {
+		return spec, fmt.Errorf("%s is not a version %d cluster spec, it should start with kind: %s and version: %d", path, clusterSpecVersion, clusterSpecKind, clusterSpecVersion)
+	}
+	if spec.Name == "" {
+		return spec, fmt.Errorf("invalid cluster spec %s: the name of the cluster is missing", path)
+	}
+	if err := validateNodes(spec.Nodes); err != nil {
+		return spec, fmt.Errorf("invalid cluster spec %s: %w", path, err)
+	}
+
+	var raw struct {
+		Settings yaml.MapSlice `yaml:"settings"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return spec, fmt.Errorf("invalid cluster spec %s: %w", path, err)
+	}
+	for _, item := range raw.Settings {
+		// The kind and version of a pasted template aren't settings
+		if item.Key != "kind" && item.Key != "version" {
+			spec.given = append(spec.given, item)
+		}
+	}
+	return spec, nil
+}
+
+// DiffClusterSpec compares the spec with the cluster, whose settings are live
+// as returned by qbert and whose nodes are among allNodes. live is nil when
+// the cluster doesn't exist.
+func DiffClusterSpec(spec ClusterSpec, live map[string]interface{}, allNodes []qbert.Node) (ClusterDiff, error) {
+	d := ClusterDiff{Name: spec.Name, Exists: live != nil}
+	want, err := templateValues(spec.Settings)
+	if err != nil {
+		return d, err
+	}
+	liveValues := map[interface{}]interface{}{}
+	liveRoles := map[string]string{}
+	if d.Exists {
+		d.Uuid = specString(live, "uuid")
+		if liveValues, err = templateValues(ClusterTemplateFromSpec(live)); err != nil {
+			return d, err
+		}
+		for _, node := range ClusterNodes(allNodes, d.Uuid) {
+			liveRoles[node.PrimaryIp] = "worker"
+			if node.IsMaster == 1 {
+				liveRoles[node.PrimaryIp] = "master"
+			}
+		}
+	}
+	d.Settings = diffSettings("", spec.given, want, liveValues)
+
+	wantRoles := map[string]string{}
+	for _, node := range spec.Nodes {
+		wantRoles[node.IP] = node.Role
+		if liveRoles[node.IP] != node.Role {
+			d.Nodes = append(d.Nodes, NodeChange{IP: node.IP, LiveRole: liveRoles[node.IP], WantRole: node.Role})
+		}
+	}
+	var extra []string
+	for ip := range liveRoles {
+		if _, ok := wantRoles[ip]; !ok {
+			extra = append(extra, ip)
+		}
+	}
+	sort.Strings(extra)
+	for _, ip := range extra {
+		d.Nodes = append(d.Nodes, NodeChange{IP: ip, LiveRole: liveRoles[ip]})
+	}
+	return d, nil
+}
+
+// templateValues returns the settings of a template by their YAML names, so
+// the spec and the cluster are compared with the same types
+func templateValues(t ClusterTemplate) (map[interface{}]interface{}, error) {
+	data, err := yaml.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	values := map[interface{}]interface{}{}
+	return values, yaml.Unmarshal(data, &values)
+}
+
+// diffSettings compares the given settings, the nested ones like etcdBackup
+// setting by setting
+func diffSettings(prefix string, given yaml.MapSlice, want, live map[interface{}]interface{}) []SettingChange {
+	var changes []SettingChange
+	for _, item := range given {
+		name := fmt.Sprintf("%s%v", prefix, item.Key)
+		if nested, ok := item.Value.(yaml.MapSlice); ok {
+			w, _ := want[item.Key].(map[interface{}]interface{})
+			l, _ := live[item.Key].(map[interface{}]interface{})
+			changes = append(changes, diffSettings(name+".", nested, w, l)...)
+			continue
+		}
+		if !sameSetting(want[item.Key], live[item.Key]) {
+			changes = append(changes, SettingChange{Name: name, Live: live[item.Key], Want: want[item.Key]})
+		}
+	}
+	return changes
+}
+
+// sameSetting compares two settings, the unset ones being the same as the
+// empty ones as the templates omit the empty settings
+func sameSetting(a, b interface{}) bool {
+	if isEmptySetting(a) && isEmptySetting(b) {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func isEmptySetting(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+// FormatSetting returns a setting as written in a spec
+func FormatSetting(v interface{}) string {
+	if isEmptySetting(v) {
+		switch v.(type) {
+		case bool, int:
+			return fmt.Sprint(v)
+		}
+		return `""`
+	}
+	if list, ok := v.([]interface{}); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,111 @@
+package pmk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/stretchr/testify/assert"
+)
+
+const edgeSpec = `kind: Cluster
+version: 1
+name: prod
+settings:
+  pmkVersion: 1.22.9-pmk.1
+  networkPlugin: calico
+  mtuSize: "1440"
+  masterVirtualIp: ""
+  apiServerFlags: ["--request-timeout=2m0s"]
+  etcdBackup:
+    enabled: true
+    intervalInMins: 60
+nodes:
+- ip: 10.0.0.1
+  role: master
+- ip: 10.0.0.2
+  role: master
+- ip: 10.0.0.4
+  role: worker
+`
+
+func TestDiffClusterSpec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cluster")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cluster.yaml")
+	assert.Nil(t, ioutil.WriteFile(file, []byte(edgeSpec), 0600))
+	spec, err := ReadClusterSpec(file)
+	assert.Nil(t, err)
+
+	var live map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(clusterSpec), &live))
+	nodes := []qbert.Node{
+		{PrimaryIp: "10.0.0.1", ClusterUuid: "0b0a9f2c", IsMaster: 1},
+		{PrimaryIp: "10.0.0.2", ClusterUuid: "0b0a9f2c"},
+		{PrimaryIp: "10.0.0.3", ClusterUuid: "0b0a9f2c"},
+		{PrimaryIp: "10.0.0.4", ClusterUuid: "other"},
+	}
+
+	d, err := DiffClusterSpec(spec, live, nodes)
+	assert.Nil(t, err)
+	assert.False(t, d.Empty())
+	assert.Equal(t, "0b0a9f2c", d.Uuid)
+	// Only the settings of the spec are compared
+	assert.Equal(t, []SettingChange{
+		{Name: "pmkVersion", Live: "1.21.3-pmk.72", Want: "1.22.9-pmk.1"},
+		{Name: "etcdBackup.intervalInMins", Live: 30, Want: 60},
+	}, d.Settings)
+	assert.Equal(t, []NodeChange{
+		{IP: "10.0.0.2", LiveRole: "worker", WantRole: "master"},
+		{IP: "10.0.0.4", WantRole: "worker"},
+		{IP: "10.0.0.3", LiveRole: "worker"},
+	}, d.Nodes)
+
+	// A missing cluster is created with the settings and the nodes of the spec
+	d, err = DiffClusterSpec(spec, nil, nodes)
+	assert.Nil(t, err)
+	assert.False(t, d.Exists)
+	assert.Equal(t, 6, len(d.Settings))
+	assert.Equal(t, 3, len(d.Nodes))
+
+	spec.given = nil
+	spec.Nodes = []NodeFileEntry{{IP: "10.0.0.1", Role: "master"}, {IP: "10.0.0.2", Role: "worker"}, {IP: "10.0.0.3", Role: "worker"}}
+	d, err = DiffClusterSpec(spec, live, nodes)
+	assert.Nil(t, err)
+	assert.True(t, d.Empty())
+}
+
+func TestReadClusterSpecErrors(t *testing.T) {
+	cases := map[string]struct {
+		content string
+		err     string
+	}{
+		"WrongKind":      {content: "kind: ClusterTemplate\nversion: 1\n", err: "<file> is not a version 1 cluster spec, it should start with kind: Cluster and version: 1"},
+		"NoName":         {content: "kind: Cluster\nversion: 1\n", err: "invalid cluster spec <file>: the name of the cluster is missing"},
+		"UnknownSetting": {content: "kind: Cluster\nversion: 1\nname: prod\nsettings:\n  masterVip: 10.0.0.1\n", err: "invalid cluster spec <file>: yaml: unmarshal errors:\n  line 5: field masterVip not found in type pmk.ClusterTemplate"},
+		"BadRole":        {content: "kind: Cluster\nversion: 1\nname: prod\nnodes:\n- ip: 10.0.0.1\n  role: etcd\n", err: "invalid cluster spec <file>: role of node 10.0.0.1 should be master or worker"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "cluster")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			file := filepath.Join(dir, "cluster.yaml")
+			assert.Nil(t, ioutil.WriteFile(file, []byte(tc.content), 0600))
+			_, err = ReadClusterSpec(file)
+			assert.EqualError(t, err, strings.Replace(tc.err, "<file>", file, 1))
+		})
+	}
+}
+
+func TestFormatSetting(t *testing.T) {
+	assert.Equal(t, "1.21.3-pmk.72", FormatSetting("1.21.3-pmk.72"))
+	assert.Equal(t, "[--a, --b]", FormatSetting([]interface{}{"--a", "--b"}))
+	assert.Equal(t, "false", FormatSetting(false))
+	assert.Equal(t, `""`, FormatSetting(nil))
+}
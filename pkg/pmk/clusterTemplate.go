@@ -0,0 +1,265 @@
+package pmk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	clusterTemplateKind    = "ClusterTemplate"
+	clusterTemplateVersion = 1
+)
+
+// EtcdBackupTemplate is the etcd backup configuration of a cluster template
+type EtcdBackupTemplate struct {
+	Enabled                bool   `yaml:"enabled"`
+	StorageType            string `yaml:"storageType,omitempty"`
+	LocalPath              string `yaml:"localPath,omitempty"`
+	IntervalInMins         int    `yaml:"intervalInMins,omitempty"`
+	MaxIntervalBackupCount int    `yaml:"maxIntervalBackupCount,omitempty"`
+}
+
+// ClusterTemplate is the configuration of a cluster without its identity, so
+// it can be used to create the same cluster in other regions or tenants. The
+// settings bound to the site, like the virtual IP, should be reviewed before
+// the template is used.
+type ClusterTemplate struct {
+	Kind    string `yaml:"kind"`
+	Version int    `yaml:"version"`
+
+	PmkVersion       string `yaml:"pmkVersion"`
+	ContainerRuntime string `yaml:"containerRuntime,omitempty"`
+	NetworkStack     int    `yaml:"networkStack"`
+
+	ContainersCIDR     string `yaml:"containersCidr"`
+	ServicesCIDR       string `yaml:"servicesCidr"`
+	NetworkPlugin      string `yaml:"networkPlugin"`
+	IPEncapsulation    string `yaml:"ipEncapsulation,omitempty"`
+	InterfaceDetection string `yaml:"interfaceDetectionMethod,omitempty"`
+	NatOutgoing        int    `yaml:"natOutgoing"`
+	MtuSize            string `yaml:"mtuSize,omitempty"`
+	BlockSize          string `yaml:"blockSize,omitempty"`
+	UseHostname        bool   `yaml:"useHostname"`
+
+	MasterVirtualIP      string `yaml:"masterVirtualIp,omitempty"`
+	MasterVirtualIPIface string `yaml:"masterVirtualInterface,omitempty"`
+	ExternalDNSName      string `yaml:"externalDnsName,omitempty"`
+	MetalLBIPRange       string `yaml:"metallbIpRange,omitempty"`
+	HttpProxy            string `yaml:"httpProxy,omitempty"`
+
+	Privileged             bool     `yaml:"privileged"`
+	AllowWorkloadsOnMaster bool     `yaml:"allowWorkloadsOnMaster"`
+	TopologyManagerPolicy  string   `yaml:"topologyManagerPolicy,omitempty"`
+	ReservedCPUs           string   `yaml:"reservedCpus,omitempty"`
+	APIServerFlags         []string `yaml:"apiServerFlags,omitempty"`
+	ControllerManagerFlags []string `yaml:"controllerManagerFlags,omitempty"`
+	SchedulerFlags         []string `yaml:"schedulerFlags,omitempty"`
+	RuntimeConfig          string   `yaml:"advancedApiConfiguration,omitempty"`
+
+	NetworkPluginOperator bool               `yaml:"networkPluginOperator"`
+	EnableKubeVirt        bool               `yaml:"enableKubeVirt"`
+	EnableProfileEngine   bool               `yaml:"enableProfileEngine"`
+	Monitoring            bool               `yaml:"monitoring"`
+	EtcdBackup            EtcdBackupTemplate `yaml:"etcdBackup"`
+}
+
+// ClusterTemplateFromSpec creates the template of a cluster from its settings
+// as returned by qbert.
+func ClusterTemplateFromSpec(spec map[string]interface{}) ClusterTemplate {
+	t := ClusterTemplate{
+		Kind:    clusterTemplateKind,
+		Version: clusterTemplateVersion,
+
+		PmkVersion:       specString(spec, "kubeRoleVersion"),
+		ContainerRuntime: specString(spec, "containerRuntime"),
+		NetworkStack:     specInt(spec, "ipv6"),
+
+		ContainersCIDR:     specString(spec, "containersCidr"),
+		ServicesCIDR:       specString(spec, "servicesCidr"),
+		NetworkPlugin:      specString(spec, "networkPlugin"),
+		IPEncapsulation:    specString(spec, "calicoIpIpMode"),
+		InterfaceDetection: specString(spec, "calicoIPv4DetectionMethod"),
+		NatOutgoing:        specInt(spec, "calicoNatOutgoing"),
+		MtuSize:            specString(spec, "mtuSize"),
+		BlockSize:          specString(spec, "calicoV4BlockSize"),
+		UseHostname:        specBool(spec, "useHostname"),
+
+		MasterVirtualIP:      specString(spec, "masterVipIpv4"),
+		MasterVirtualIPIface: specString(spec, "masterVipIface"),
+		ExternalDNSName:      specString(spec, "externalDnsName"),
+		MetalLBIPRange:       specString(spec, "metallbCidr"),
+		HttpProxy:            specString(spec, "httpProxy"),
+
+		Privileged:             specBool(spec, "privileged"),
+		AllowWorkloadsOnMaster: specBool(spec, "allowWorkloadsOnMaster"),
+		TopologyManagerPolicy:  specString(spec, "topologyManagerPolicy"),
+		ReservedCPUs:           specString(spec, "reservedCPUs"),
+		APIServerFlags:         specStrings(spec, "apiServerFlags"),
+		ControllerManagerFlags: specStrings(spec, "controllerManagerFlags"),
+		SchedulerFlags:         specStrings(spec, "schedulerFlags"),
+		RuntimeConfig:          specString(spec, "runtimeConfig"),
+
+		NetworkPluginOperator: specBool(spec, "deployLuigiOperator"),
+		EnableKubeVirt:        specBool(spec, "deployKubevirt"),
+		EnableProfileEngine:   specBool(spec, "enableProfileAgent"),
+	}
+
+	// Depending on the pmk version monitoring is either a field of its own
+	// or a system tag
+	if monitoring, ok := spec["monitoring"]; ok && monitoring != nil && monitoring != "" {
+		t.Monitoring = true
+	}
+	if tags, ok := spec["tags"].(map[string]interface{}); ok && specBool(tags, "pf9-system:monitoring") {
+		t.Monitoring = true
+	}
+
+	if backup, ok := spec["etcdBackup"].(map[string]interface{}); ok {
+		t.EtcdBackup = EtcdBackupTemplate{
+			Enabled:                specBool(backup, "isEtcdBackupEnabled"),
+			StorageType:            specString(backup, "storageType"),
+			IntervalInMins:         specInt(backup, "intervalInMins"),
+			MaxIntervalBackupCount: specInt(backup, "maxIntervalBackupCount"),
+		}
+		if props, ok := backup["storageProperties"].(map[string]interface{}); ok {
+			t.EtcdBackup.LocalPath = specString(props, "localPath")
+		}
+	}
+	return t
+}
+
+// CreateRequest returns the request creating a cluster named name from the
+// template.
+func (t ClusterTemplate) CreateRequest(name string) qbert.ClusterCreateRequest {
+	req := qbert.ClusterCreateRequest{
+		Name:                   name,
+		ContainerCIDR:          t.ContainersCIDR,
+		ServiceCIDR:            t.ServicesCIDR,
+		MasterVirtualIP:        t.MasterVirtualIP,
+		MasterVirtualIPIface:   t.MasterVirtualIPIface,
+		ExternalDNSName:        t.ExternalDNSName,
+		NetworkPlugin:          qbert.CNIBackend(t.NetworkPlugin),
+		MetalLBAddressPool:     t.MetalLBIPRange,
+		AllowWorkloadOnMaster:  t.AllowWorkloadsOnMaster,
+		Privileged:             t.Privileged,
+		NetworkPluginOperator:  t.NetworkPluginOperator,
+		EnableKubVirt:          t.EnableKubeVirt,
+		EnableProfileAgent:     t.EnableProfileEngine,
+		PmkVersion:             t.PmkVersion,
+		IPEncapsulation:        t.IPEncapsulation,
+		InterfaceDetection:     t.InterfaceDetection,
+		UseHostName:            t.UseHostname,
+		MtuSize:                t.MtuSize,
+		BlockSize:              t.BlockSize,
+		ContainerRuntime:       t.ContainerRuntime,
+		NetworkStack:           t.NetworkStack,
+		TopologyManagerPolicy:  t.TopologyManagerPolicy,
+		ReservedCPUs:           t.ReservedCPUs,
+		ApiServerFlags:         t.APIServerFlags,
+		ControllerManagerFlags: t.ControllerManagerFlags,
+		SchedulerFlags:         t.SchedulerFlags,
+		RuntimeConfig:          t.RuntimeConfig,
+		CalicoNatOutgoing:      t.NatOutgoing,
+		HttpProxy:              t.HttpProxy,
+	}
+	if t.EtcdBackup.Enabled {
+		req.EtcdBackup = qbert.EtcdBackup{
+			StorageType:            t.EtcdBackup.StorageType,
+			IsEtcdBackupEnabled:    1,
+			StorageProperties:      qbert.Storageproperties{LocalPath: t.EtcdBackup.LocalPath},
+			IntervalInMins:         t.EtcdBackup.IntervalInMins,
+			MaxIntervalBackupCount: t.EtcdBackup.MaxIntervalBackupCount,
+		}
+	}
+	return req
+}
+
+// MarshalClusterTemplate returns the template as YAML
+func MarshalClusterTemplate(t ClusterTemplate) ([]byte, error) {
+	return yaml.Marshal(t)
+}
+
+// ReadClusterTemplate reads a template written by 'pf9ctl cluster-template export'
+func ReadClusterTemplate(file string) (ClusterTemplate, error) {
+	var t ClusterTemplate
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return t, fmt.Errorf("unable to read cluster template: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, &t); err != nil {
+		return t, fmt.Errorf("unable to parse cluster template: %w", err)
+	}
+	if t.Kind != clusterTemplateKind || t.Version != clusterTemplateVersion {
+		return t, fmt.Errorf("%s is not a version %d cluster template", file, clusterTemplateVersion)
+	}
+	if t.PmkVersion == "" || t.ContainersCIDR == "" || t.ServicesCIDR == "" || t.NetworkPlugin == "" {
+		return t, fmt.Errorf("cluster template is missing pmkVersion, containersCidr, servicesCidr or networkPlugin")
+	}
+	return t, nil
+}
+
+// The settings returned by qbert don't always have the types of the create
+// request, booleans may come as numbers or strings and lists as comma separated
+// strings.
+
+func specString(spec map[string]interface{}, key string) string {
+	switch v := spec[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+func specInt(spec map[string]interface{}, key string) int {
+	switch v := spec[key].(type) {
+	case float64:
+		return int(v)
+	case bool:
+		if v {
+			return 1
+		}
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	}
+	return 0
+}
+
+func specBool(spec map[string]interface{}, key string) bool {
+	switch v := spec[key].(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+func specStrings(spec map[string]interface{}, key string) []string {
+	switch v := spec[key].(type) {
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	case string:
+		if v != "" {
+			return strings.Split(v, ",")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package pmk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/stretchr/testify/assert"
+)
+
+const clusterSpec = `{
+	"uuid": "0b0a9f2c",
+	"name": "prod",
+	"kubeRoleVersion": "1.21.3-pmk.72",
+	"containerRuntime": "containerd",
+	"ipv6": false,
+	"containersCidr": "10.20.0.0/16",
+	"servicesCidr": "10.21.0.0/16",
+	"networkPlugin": "calico",
+	"calicoIpIpMode": "Always",
+	"calicoNatOutgoing": true,
+	"mtuSize": 1440,
+	"calicoV4BlockSize": "26",
+	"privileged": 1,
+	"allowWorkloadsOnMaster": "true",
+	"apiServerFlags": ["--request-timeout=2m0s"],
+	"schedulerFlags": "--kube-api-burst=120,--log_file_max_size=3000",
+	"enableProfileAgent": true,
+	"tags": {"pf9-system:monitoring": "true"},
+	"etcdBackup": {
+		"storageType": "local",
+		"isEtcdBackupEnabled": 1,
+		"storageProperties": {"localPath": "/etc/pf9/etcd-backup"},
+		"intervalInMins": 30,
+		"maxIntervalBackupCount": 3
+	}
+}`
+
+func TestClusterTemplate(t *testing.T) {
+	var spec map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(clusterSpec), &spec))
+
+	template := ClusterTemplateFromSpec(spec)
+	data, err := MarshalClusterTemplate(template)
+	assert.Nil(t, err)
+
+	dir, err := ioutil.TempDir("", "template")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "tmpl.yaml")
+	assert.Nil(t, ioutil.WriteFile(file, data, 0644))
+
+	loaded, err := ReadClusterTemplate(file)
+	assert.Nil(t, err)
+	assert.Equal(t, template, loaded)
+	assert.True(t, loaded.Monitoring)
+
+	assert.Equal(t, qbert.ClusterCreateRequest{
+		Name:                  "staging",
+		ContainerCIDR:         "10.20.0.0/16",
+		ServiceCIDR:           "10.21.0.0/16",
+		NetworkPlugin:         "calico",
+		AllowWorkloadOnMaster: true,
+		Privileged:            true,
+		EtcdBackup: qbert.EtcdBackup{
+			StorageType:            "local",
+			IsEtcdBackupEnabled:    1,
+			StorageProperties:      qbert.Storageproperties{LocalPath: "/etc/pf9/etcd-backup"},
+			IntervalInMins:         30,
+			MaxIntervalBackupCount: 3,
+		},
+		EnableProfileAgent: true,
+		PmkVersion:         "1.21.3-pmk.72",
+		IPEncapsulation:    "Always",
+		MtuSize:            "1440",
+		BlockSize:          "26",
+		ContainerRuntime:   "containerd",
+		ApiServerFlags:     []string{"--request-timeout=2m0s"},
+		SchedulerFlags:     []string{"--kube-api-burst=120", "--log_file_max_size=3000"},
+		CalicoNatOutgoing:  1,
+	}, loaded.CreateRequest("staging"))
+}
+
+func TestReadClusterTemplateErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "template")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	cases := map[string]struct {
+		content string
+		err     string
+	}{
+		"WrongKind":    {content: "kind: Cluster\nversion: 1\n", err: "<file> is not a version 1 cluster template"},
+		"Incomplete":   {content: "kind: ClusterTemplate\nversion: 1\npmkVersion: 1.21.3-pmk.72\n", err: "cluster template is missing pmkVersion, containersCidr, servicesCidr or networkPlugin"},
+		"UnknownField": {content: "kind: ClusterTemplate\nversion: 1\nmasterVip: 10.0.0.1\n", err: "unable to parse cluster template: yaml: unmarshal errors:\n  line 3: field masterVip not found in type pmk.ClusterTemplate"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(dir, name+".yaml")
+			assert.Nil(t, ioutil.WriteFile(file, []byte(tc.content), 0644))
+			_, err := ReadClusterTemplate(file)
+			assert.EqualError(t, err, strings.Replace(tc.err, "<file>", file, 1))
+		})
+	}
+}
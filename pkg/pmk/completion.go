@@ -0,0 +1,166 @@
+package pmk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/platform9/pf9ctl/pkg/statefile"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// CompletionCacheTTL is how long the names fetched for shell completion are
+// reused, so that pressing TAB doesn't query the DU every time
+var CompletionCacheTTL = 5 * time.Minute
+
+// CompletionNames are the names of the clusters and nodes of a DU offered by
+// the shell completion
+type CompletionNames struct {
+	Clusters  []string         `json:"clusters"`
+	Hosts     []CompletionHost `json:"hosts"`
+	FetchedAt time.Time        `json:"fetchedAt"`
+}
+
+// CompletionHost is a host of the DU and the cluster it is part of, if any
+type CompletionHost struct {
+	IP       string `json:"ip"`
+	Hostname string `json:"hostname"`
+	Cluster  string `json:"cluster,omitempty"`
+}
+
+// ClusterNames returns the names of the clusters, with the prefix
+func (n CompletionNames) ClusterNames(prefix string) []string {
+	return withPrefix(n.Clusters, prefix)
+}
+
+// HostIPs returns the IPs of the hosts with the prefix, those which are
+// part of a cluster when attached is set and the others otherwise
+func (n CompletionNames) HostIPs(prefix string, attached bool) []string {
+	var ips []string
+	for _, host := range n.Hosts {
+		if (host.Cluster != "") == attached {
+			ips = append(ips, host.IP)
+		}
+	}
+	return withPrefix(ips, prefix)
+}
+
+// AllHostIPs returns the IPs of all the hosts with the prefix
+func (n CompletionNames) AllHostIPs(prefix string) []string {
+	return append(n.HostIPs(prefix, true), n.HostIPs(prefix, false)...)
+}
+
+// CachedCompletionNames returns the names cached for the tenant of the DU,
+// unless they are older than CompletionCacheTTL
+func CachedCompletionNames(fqdn, tenant string) (CompletionNames, bool) {
+	return loadCompletionCache(util.Pf9CompletionCacheLoc, completionCacheKey(fqdn, tenant), time.Now())
+}
+
+// FetchCompletionNames returns the names of the clusters and hosts of the
+// tenant of the DU and refreshes the cache.
+func FetchCompletionNames(c client.Client, auth keystone.KeystoneAuth, fqdn, tenant string) (CompletionNames, error) {
+	clusters, err := c.Qbert.ListClusters(auth.ProjectID, auth.Token)
+	if err != nil {
+		return CompletionNames{}, fmt.Errorf("Unable to list the clusters: %w", err)
+	}
+	hosts, err := c.Resmgr.GetHosts(auth.Token)
+	if err != nil {
+		return CompletionNames{}, fmt.Errorf("Unable to list the hosts: %w", err)
+	}
+	names := completionNames(clusters, hosts, c.Qbert.GetAllNodes(auth.Token, auth.ProjectID))
+	names.FetchedAt = time.Now()
+
+	if err := storeCompletionCache(util.Pf9CompletionCacheLoc, completionCacheKey(fqdn, tenant), names); err != nil {
+		zap.S().Debugf("Unable to store completion cache: %s", err)
+	}
+	return names, nil
+}
+
+func completionNames(clusters []qbert.Cluster, hosts []resmgr.HostInfo, nodes []qbert.Node) CompletionNames {
+	var names CompletionNames
+	for _, cluster := range clusters {
+		names.Clusters = append(names.Clusters, cluster.Name)
+	}
+	sort.Strings(names.Clusters)
+
+	qbertNodes := make(map[string]qbert.Node)
+	for _, node := range nodes {
+		qbertNodes[node.Uuid] = node
+	}
+	for _, host := range hosts {
+		node := qbertNodes[host.ID]
+		ip := node.PrimaryIp
+		if ip == "" && len(host.Extensions.IPAddress.Data) > 0 {
+			ip = host.Extensions.IPAddress.Data[0]
+		}
+		if ip == "" {
+			continue
+		}
+		names.Hosts = append(names.Hosts, CompletionHost{IP: ip, Hostname: host.Info.Hostname, Cluster: node.ClusterName})
+	}
+	sort.Slice(names.Hosts, func(i, j int) bool {
+		return names.Hosts[i].IP < names.Hosts[j].IP
+	})
+	return names
+}
+
+func completionCacheKey(fqdn, tenant string) string {
+	return fmt.Sprintf("%s|%s", fqdn, tenant)
+}
+
+func readCompletionCache(path string) map[string]CompletionNames {
+	cache := make(map[string]CompletionNames)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		zap.S().Debugf("Ignoring invalid completion cache: %s", err)
+		return make(map[string]CompletionNames)
+	}
+	return cache
+}
+
+func loadCompletionCache(path, key string, now time.Time) (CompletionNames, bool) {
+	names, found := readCompletionCache(path)[key]
+	if !found || now.Sub(names.FetchedAt) > CompletionCacheTTL {
+		return CompletionNames{}, false
+	}
+	return names, true
+}
+
+func storeCompletionCache(path, key string, names CompletionNames) error {
+	// The names stored by another pf9ctl at the same time are kept
+	lock, err := statefile.LockFile(path, time.Second)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	cache := readCompletionCache(path)
+	cache[key] = names
+
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return statefile.WriteFile(path, data, os.FileMode(0600))
+}
+
+func withPrefix(names []string, prefix string) []string {
+	var matching []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			matching = append(matching, name)
+		}
+	}
+	return matching
+}
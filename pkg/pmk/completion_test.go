@@ -0,0 +1,56 @@
+package pmk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompletionNames(t *testing.T) {
+	newHost := func(id, ip string) resmgr.HostInfo {
+		host := resmgr.HostInfo{ID: id}
+		host.Extensions.IPAddress.Data = []string{ip}
+		return host
+	}
+	clusters := []qbert.Cluster{{Name: "prod"}, {Name: "dev"}, {Name: "prod-eu"}}
+	hosts := []resmgr.HostInfo{newHost("host-3", "10.0.0.3"), newHost("host-1", "10.0.0.1"), newHost("host-2", "10.0.0.2"), {ID: "host-4"}}
+	nodes := []qbert.Node{
+		{Uuid: "host-1", PrimaryIp: "10.0.0.1", ClusterName: "prod"},
+		{Uuid: "host-3", PrimaryIp: "10.0.1.3"},
+	}
+
+	names := completionNames(clusters, hosts, nodes)
+	assert.Equal(t, []string{"dev", "prod", "prod-eu"}, names.Clusters)
+	assert.Equal(t, []string{"prod", "prod-eu"}, names.ClusterNames("pr"))
+	assert.Equal(t, []string{"10.0.0.1"}, names.HostIPs("", true))
+	assert.Equal(t, []string{"10.0.0.2", "10.0.1.3"}, names.HostIPs("", false))
+	assert.Equal(t, []string{"10.0.1.3"}, names.HostIPs("10.0.1", false))
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, names.AllHostIPs("10.0.0"))
+}
+
+func TestCompletionCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "completion")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "completion.json")
+
+	fetchedAt := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	names := CompletionNames{Clusters: []string{"prod"}, Hosts: []CompletionHost{{IP: "10.0.0.1"}}, FetchedAt: fetchedAt}
+	assert.NoError(t, storeCompletionCache(path, completionCacheKey("https://du", "service"), names))
+
+	cached, ok := loadCompletionCache(path, completionCacheKey("https://du", "service"), fetchedAt.Add(time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, names.Clusters, cached.Clusters)
+	assert.Equal(t, names.Hosts, cached.Hosts)
+
+	_, ok = loadCompletionCache(path, completionCacheKey("https://du", "service"), fetchedAt.Add(CompletionCacheTTL+time.Second))
+	assert.False(t, ok, "stale")
+	_, ok = loadCompletionCache(path, completionCacheKey("https://du", "other"), fetchedAt)
+	assert.False(t, ok, "other tenant")
+}
@@ -0,0 +1,171 @@
+package pmk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/platform9/pf9ctl/pkg/ui"
+	"go.uber.org/zap"
+)
+
+// ConvergeTimeout is how long --wait waits for the nodes to converge
+var ConvergeTimeout = 30 * time.Minute
+
+var convergePollInterval = 15 * time.Second
+
+// ConvergeTasks is the progress of the tasks the pf9-kube role runs on a
+// node to converge, like configuring the network or starting the kubelet
+type ConvergeTasks struct {
+	Tasks     []string
+	Completed int
+	// Failed is the task which failed last, if any
+	Failed string
+}
+
+// Current returns the task the node is running, the first one which isn't
+// completed yet
+func (t ConvergeTasks) Current() string {
+	if t.Completed < len(t.Tasks) {
+		return t.Tasks[t.Completed]
+	}
+	return ""
+}
+
+func (t ConvergeTasks) String() string {
+	if t.Failed != "" {
+		return fmt.Sprintf("failed task %q", t.Failed)
+	}
+	if current := t.Current(); current != "" {
+		return fmt.Sprintf("task %d/%d %q", t.Completed+1, len(t.Tasks), current)
+	}
+	return fmt.Sprintf("all %d tasks completed", len(t.Tasks))
+}
+
+// convergeTasks returns the tasks reported by the pf9-kube role of the host,
+// false when it reports none
+func convergeTasks(host resmgr.HostInfo) (ConvergeTasks, bool) {
+	var data struct {
+		AllTasks       []string    `json:"all_tasks"`
+		CompletedTasks []string    `json:"completed_tasks"`
+		LastFailedTask interface{} `json:"last_failed_task"`
+	}
+	raw := host.Extensions.KubeStatus.Data
+	if len(raw) == 0 {
+		return ConvergeTasks{}, false
+	}
+	if err := json.Unmarshal(raw, &data); err != nil || len(data.AllTasks) == 0 {
+		zap.S().Debugf("Ignoring the tasks of host %s: %v", host.ID, err)
+		return ConvergeTasks{}, false
+	}
+
+	tasks := ConvergeTasks{Tasks: data.AllTasks, Completed: len(data.CompletedTasks)}
+	if tasks.Completed > len(tasks.Tasks) {
+		tasks.Completed = len(tasks.Tasks)
+	}
+	// The failed task is reported by its name or its index
+	switch failed := data.LastFailedTask.(type) {
+	case string:
+		tasks.Failed = failed
+	case float64:
+		if i := int(failed); i >= 0 && i < len(tasks.Tasks) {
+			tasks.Failed = tasks.Tasks[i]
+		}
+	}
+	return tasks, true
+}
+
+// convergeDetail describes where the node is in its convergence, e.g.
+// `converging, task 4/12 "Start kubelet"`
+func convergeDetail(node qbert.Node, host resmgr.HostInfo) string {
+	status := node.Status
+	if status == "" {
+		status = host.Extensions.KubeStatus.Status
+	}
+	if status == "" {
+		status = "pending"
+	}
+	if tasks, ok := convergeTasks(host); ok {
+		return fmt.Sprintf("%s, %s", status, tasks)
+	}
+	return status
+}
+
+// nodeConvergeDetail fetches the converge tasks of the node, falling back to
+// its qbert status when resmgr can't be queried
+func nodeConvergeDetail(r resmgr.Resmgr, token string, node qbert.Node) string {
+	host, err := r.GetHostInfo(token, node.Uuid)
+	if err != nil {
+		nodeLog(node.PrimaryIp).Debugf("Unable to get the tasks of host %s: %s", node.Uuid, err.Error())
+	}
+	return convergeDetail(node, host)
+}
+
+// WaitForConvergence waits for the nodes, by IP and host ID, to converge,
+// showing the converge task every node is running. A node which fails or
+// doesn't converge in time is reported with the task it is stuck at.
+func WaitForConvergence(c client.Client, auth keystone.KeystoneAuth, ips, hostIDs []string, timeout time.Duration) error {
+	progress := ui.StartProgress(fmt.Sprintf("Waiting for %d node(s) to converge", len(ips)), ips)
+
+	var mu sync.Mutex
+	stuck := make(map[string]string)
+	var wg sync.WaitGroup
+	for i := range ips {
+		wg.Add(1)
+		go func(ip, hostID string) {
+			defer wg.Done()
+			detail, err := waitForConvergence(c, auth, hostID, timeout, convergePollInterval, func(detail string) {
+				progress.Set(ip, detail)
+			})
+			progress.Finish(ip, err)
+			if err != nil {
+				mu.Lock()
+				stuck[ip] = fmt.Sprintf("%s: %s", err, detail)
+				mu.Unlock()
+			}
+		}(ips[i], hostIDs[i])
+	}
+	wg.Wait()
+	progress.Stop()
+
+	if len(stuck) == 0 {
+		return nil
+	}
+	var lines []string
+	for _, ip := range ips {
+		if reason, found := stuck[ip]; found {
+			lines = append(lines, fmt.Sprintf("%s %s", ip, reason))
+		}
+	}
+	return fmt.Errorf("%d node(s) did not converge:\n  %s", len(stuck), strings.Join(lines, "\n  "))
+}
+
+// waitForConvergence polls the DU until the node with hostID is converged,
+// reporting its converge detail as it changes, and returns its last detail.
+func waitForConvergence(c client.Client, auth keystone.KeystoneAuth, hostID string, timeout, interval time.Duration, report func(string)) (string, error) {
+	deadline := time.Now().Add(timeout)
+	detail := ""
+	for {
+		node := c.Qbert.GetNodeInfo(auth.Token, auth.ProjectID, hostID)
+		node.Uuid = hostID
+		if nodeConverged(node) {
+			return detail, nil
+		}
+		detail = nodeConvergeDetail(c.Resmgr, auth.Token, node)
+		report(detail)
+		if node.Status == "failed" {
+			return detail, errors.New("failed")
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return detail, fmt.Errorf("not converged after %s", timeout)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,108 @@
+package pmk
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func kubeStatusHost(data string) resmgr.HostInfo {
+	host := resmgr.HostInfo{ID: "host-1"}
+	host.Extensions.KubeStatus.Data = json.RawMessage(data)
+	return host
+}
+
+func TestConvergeTasks(t *testing.T) {
+	cases := map[string]struct {
+		data string
+		want string
+		ok   bool
+	}{
+		"Running":      {data: `{"all_tasks":["Configure network","Start kubelet","Label node"],"completed_tasks":["Configure network"]}`, want: `task 2/3 "Start kubelet"`, ok: true},
+		"Done":         {data: `{"all_tasks":["Configure network"],"completed_tasks":["Configure network"]}`, want: "all 1 tasks completed", ok: true},
+		"FailedByName": {data: `{"all_tasks":["Configure network","Start kubelet"],"completed_tasks":[],"last_failed_task":"Configure network"}`, want: `failed task "Configure network"`, ok: true},
+		"FailedByIdx":  {data: `{"all_tasks":["Configure network","Start kubelet"],"completed_tasks":["Configure network"],"last_failed_task":1}`, want: `failed task "Start kubelet"`, ok: true},
+		"NoFailure":    {data: `{"all_tasks":["Configure network"],"completed_tasks":[],"last_failed_task":null}`, want: `task 1/1 "Configure network"`, ok: true},
+		"NoTasks":      {data: `{}`},
+		"Invalid":      {data: `[]`},
+		"Missing":      {},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tasks, ok := convergeTasks(kubeStatusHost(tc.data))
+			assert.Equal(t, tc.ok, ok)
+			if ok {
+				assert.Equal(t, tc.want, tasks.String())
+			}
+		})
+	}
+}
+
+func TestConvergeDetail(t *testing.T) {
+	host := kubeStatusHost(`{"all_tasks":["Configure network","Start kubelet"],"completed_tasks":["Configure network"]}`)
+	assert.Equal(t, `converging, task 2/2 "Start kubelet"`, convergeDetail(qbert.Node{Status: "converging"}, host))
+
+	host.Extensions.KubeStatus.Status = "retrying"
+	assert.Equal(t, `retrying, task 2/2 "Start kubelet"`, convergeDetail(qbert.Node{}, host))
+	assert.Equal(t, "pending", convergeDetail(qbert.Node{}, resmgr.HostInfo{}))
+}
+
+// convergingQbert answers GetNodeInfo with the next of its statuses
+type convergingQbert struct {
+	qbert.Qbert
+	statuses []string
+	calls    int
+}
+
+func (q *convergingQbert) GetNodeInfo(token, projectID, hostUUID string) qbert.Node {
+	i := q.calls
+	if i >= len(q.statuses) {
+		i = len(q.statuses) - 1
+	}
+	q.calls++
+	return qbert.Node{Uuid: hostUUID, Status: q.statuses[i]}
+}
+
+type kubeStatusResmgr struct {
+	resmgr.Resmgr
+	host resmgr.HostInfo
+}
+
+func (r kubeStatusResmgr) GetHostInfo(token, hostID string) (resmgr.HostInfo, error) {
+	return r.host, nil
+}
+
+func TestWaitForConvergence(t *testing.T) {
+	host := kubeStatusHost(`{"all_tasks":["Configure network","Start kubelet"],"completed_tasks":["Configure network"]}`)
+	cases := map[string]struct {
+		statuses []string
+		detail   string
+		err      string
+	}{
+		"Converged": {statuses: []string{"converging", "ok"}, detail: `converging, task 2/2 "Start kubelet"`},
+		"Failed":    {statuses: []string{"converging", "failed"}, detail: `failed, task 2/2 "Start kubelet"`, err: "failed"},
+		"TimedOut":  {statuses: []string{"converging"}, detail: `converging, task 2/2 "Start kubelet"`, err: "not converged after 10ms"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := client.Client{Qbert: &convergingQbert{statuses: tc.statuses}, Resmgr: kubeStatusResmgr{host: host}}
+			var reported []string
+			detail, err := waitForConvergence(c, keystone.KeystoneAuth{}, "host-1", 10*time.Millisecond, time.Millisecond, func(detail string) {
+				reported = append(reported, detail)
+			})
+			assert.Equal(t, tc.detail, detail)
+			assert.Contains(t, reported, tc.detail)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
@@ -1,38 +1,59 @@
 package pmk
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/platform9/pf9ctl/pkg/client"
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/keystone"
 	"github.com/platform9/pf9ctl/pkg/objects"
 	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/platform9/pf9ctl/pkg/tracing"
+	"github.com/platform9/pf9ctl/pkg/ui"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"go.uber.org/zap"
 )
 
-func removePf9Installation(c client.Client) {
-	fmt.Println("Removing /etc/pf9 logs")
+// DecommissionTimeout is how long decommission waits for resmgr to remove the host
+var DecommissionTimeout = 5 * time.Minute
+
+// decommissionPollInterval is how often resmgr is polled while waiting for the host removal
+var decommissionPollInterval = 10 * time.Second
+
+// pf9Services are the Platform9 services stopped when the hostagent is removed
+var pf9Services = []string{"pf9-hostagent", "pf9-nodeletd", "pf9-kubelet"}
+
+func removePf9Installation(c client.Client, phase *ui.Phase, span *tracing.Span) {
+	step := span.Child("remove-pf9-dirs")
+	defer step.End(nil)
+	phase.Update("Removing /etc/pf9 logs")
 	cmd := fmt.Sprintf("rm -rf %s", util.EtcDir)
 	c.Executor.RunCommandWait(cmd)
-	fmt.Println("Removing /var/opt/pf9 logs")
+	phase.Update("Removing /var/opt/pf9 logs")
 	cmd = fmt.Sprintf("rm -rf %s", util.OptDir)
 	c.Executor.RunCommandWait(cmd)
-	fmt.Println("Removing pf9 HOME dir")
+	phase.Update("Removing pf9 HOME dir")
 	cmd = fmt.Sprintf("rm -rf $HOME/pf9")
 	c.Executor.RunCommandWait(cmd)
+	phase.Step("Removed Platform9 directories")
 }
 
-func removeHostagent(c client.Client, hostOS string) {
+func removeHostagent(c client.Client, hostOS string, phase *ui.Phase, span *tracing.Span) {
+	step := span.Child("remove-hostagent")
+	defer step.End(nil)
 
-	fmt.Println("Removing pf9-hostagent (this might take a few minutes...)")
-	var services = []string{"pf9-hostagent", "pf9-nodeletd", "pf9-kubelet"}
+	phase.Update("Removing pf9-hostagent (this might take a few minutes...)")
+	// The watchdog would restart the services being removed
+	if err := RemoveWatchdog(c.Executor); err != nil {
+		zap.S().Debugf("Could not remove the watchdog %v", err)
+	}
 	//stop hostagent
-	for _, service := range services {
-		cmd := fmt.Sprintf("sudo systemctl stop %s", service)
-		_, err := c.Executor.RunWithStdout("bash", "-c", cmd)
+	for _, service := range pf9Services {
+		_, err := c.Executor.RunArgs("systemctl", "stop", service)
 		if err != nil {
 			zap.S().Debugf("Could not execute command %v", err)
 		}
@@ -40,23 +61,74 @@ func removeHostagent(c client.Client, hostOS string) {
 	//remove hostagent
 	var err error
 	if hostOS == "debian" {
-		_, err = c.Executor.RunWithStdout("bash", "-c", "sudo apt-get purge pf9-hostagent -y")
+		_, err = c.Executor.RunArgs("apt-get", "purge", "pf9-hostagent", "-y")
 	} else {
-		_, err = c.Executor.RunWithStdout("bash", "-c", "sudo yum remove pf9-hostagent -y")
+		_, err = c.Executor.RunArgs("yum", "remove", "pf9-hostagent", "-y")
 	}
 	if err != nil {
 		zap.S().Debugf("Could not execute command %v", err)
 	} else {
-		fmt.Println("Removed hostagent")
+		phase.Step("Removed hostagent")
 	}
-	fmt.Println("Removing logs...")
+	phase.Update("Removing logs...")
 	for _, file := range util.Files {
 		cmd := fmt.Sprintf("rm -rf %s", file)
 		c.Executor.RunCommandWait(cmd)
 	}
+	phase.Step("Removed logs")
 }
 
-func DecommissionNode(cfg *objects.Config, nc objects.NodeConfig, removePf9 bool) {
+// waitForHostRemoval polls resmgr until the host is gone or reported deleted.
+func waitForHostRemoval(r resmgr.Resmgr, token, hostID string, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		host, err := r.GetHostInfo(token, hostID)
+		if errors.Is(err, resmgr.ErrHostNotFound) || (err == nil && host.State == "deleted") {
+			return nil
+		}
+		if err != nil {
+			zap.S().Debugf("Unable to get host info of %s: %s", hostID, err)
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("node is still registered with the management plane after %s", timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// verifyHostagentRemoved checks that neither the hostagent package nor any of
+// the Platform9 services are left on the node.
+func verifyHostagentRemoved(exec cmdexec.Executor, hostOS string) error {
+	if hostagentInstalled(exec, hostOS) {
+		return fmt.Errorf("pf9-hostagent package is still installed")
+	}
+	var running []string
+	for _, service := range pf9Services {
+		// is-active exits with 0 only if the service is running
+		if _, err := exec.RunArgs("systemctl", "is-active", "--quiet", service); err == nil {
+			running = append(running, service)
+		}
+	}
+	if len(running) > 0 {
+		return fmt.Errorf("services still running: %s", strings.Join(running, ", "))
+	}
+	return nil
+}
+
+func hostagentInstalled(exec cmdexec.Executor, hostOS string) bool {
+	var err error
+	if hostOS == "debian" {
+		_, err = exec.RunArgs("dpkg", "-s", "pf9-hostagent")
+	} else {
+		_, err = exec.RunArgs("yum", "list", "installed", "pf9-hostagent")
+	}
+	return err == nil
+}
+
+// DecommissionNode removes the node from its cluster and from the management
+// plane and uninstalls the hostagent. It waits until the management plane has
+// dropped the node and the services are gone before reporting success.
+func DecommissionNode(cfg *objects.Config, nc objects.NodeConfig, removePf9 bool) error {
 	//Doc decommission steps
 	//detach-node from cluster
 	//deauthorize-node from controle plane
@@ -69,38 +141,46 @@ func DecommissionNode(cfg *objects.Config, nc objects.NodeConfig, removePf9 bool
 	var executor cmdexec.Executor
 	var err error
 	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, nc); err != nil {
-		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+		return fmt.Errorf("Unable to create executor: %s", err.Error())
 	}
+	span := tracing.Start("decommission-node", "host.name", cmdexec.Host(executor))
+	err = decommissionNode(cfg, executor, removePf9, span)
+	span.End(err)
+	return err
+}
+
+func decommissionNode(cfg *objects.Config, executor cmdexec.Executor, removePf9 bool, span *tracing.Span) error {
+	var err error
 	var c client.Client
 	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
-		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+		return fmt.Errorf("Unable to create client: %s", err.Error())
 	}
-	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	auth, err := keystone.Authenticate(c.Keystone, *cfg)
 	if err != nil {
 		zap.S().Debug("Failed to get keystone %s", err.Error())
 	}
+	if err := keystone.CheckOperationRole(auth, "decommission-node"); err != nil {
+		return err
+	}
 
-	ip, err := c.Executor.RunWithStdout("bash", "-c", "hostname -I")
+	ip, err := c.Executor.RunArgs("hostname", "-I")
 	//Handling case where host can have multiple IPs
 	ip = strings.Split(ip, " ")[0]
 	ip = strings.TrimSpace(ip)
 	if err != nil {
-		zap.S().Fatalf("ERROR : unable to get host ip")
+		return fmt.Errorf("unable to get host ip: %w", err)
 	}
 	hostOS, err := ValidatePlatform(c.Executor)
 	if err != nil {
-		zap.S().Fatalf("Error getting OS version")
+		return fmt.Errorf("Error getting OS version: %w", err)
 	}
 	var nodeIPs []string
 	nodeIPs = append(nodeIPs, ip)
 	hostID := c.Resmgr.GetHostId(auth.Token, nodeIPs)
 	//check if hostagent is installed on host
-	if hostOS == "debian" {
-		_, err = c.Executor.RunWithStdout("bash", "-c", "dpkg -s pf9-hostagent")
-	} else {
-		_, err = c.Executor.RunWithStdout("bash", "-c", "yum list installed pf9-hostagent")
-	}
-	if err == nil {
+	if hostagentInstalled(c.Executor, hostOS) {
+		phase := ui.StartPhase("Decommissioning node")
+		defer phase.Stop()
 		//check if node is connected to any cluster
 		var nodeInfo qbert.Node
 		var nodeConnectedToDU bool
@@ -110,54 +190,116 @@ func DecommissionNode(cfg *objects.Config, nc objects.NodeConfig, removePf9 bool
 		}
 
 		if nodeInfo.ClusterName == "" {
-			fmt.Println("Node is not connected to any cluster")
+			phase.Step("Node is not connected to any cluster")
 			if nodeConnectedToDU {
+				phase.Update("Deauthorizing node from UI...")
+				step := span.Child("deauthorize")
 				err = c.Qbert.DeauthoriseNode(hostID[0], auth.Token)
+				step.End(err)
 				if err != nil {
-					zap.S().Fatalf("Failed to deauthorize node")
+					phase.Fail("Failed to deauthorize node")
+					return fmt.Errorf("Failed to deauthorize node: %w", err)
 				} else {
-					fmt.Println("Deauthorized node from UI")
+					phase.Step("Deauthorized node from UI")
 				}
-				removeHostagent(c, hostOS)
+				removeHostagent(c, hostOS, phase, span)
 			} else {
 				//case where node is not connected to DU but hostagent is installed partially
-				removeHostagent(c, hostOS)
+				removeHostagent(c, hostOS, phase, span)
 			}
 			//remove pf9 dir
 			if removePf9 {
-				removePf9Installation(c)
+				removePf9Installation(c, phase, span)
 			}
-			fmt.Println("Node decommissioning started....This may take a few minutes....Check the latest status in UI")
-			time.Sleep(50 * time.Second)
 		} else {
 			//detach node from cluster
-			fmt.Printf("Node is connected to %s cluster\n", nodeInfo.ClusterName)
-			fmt.Println("Detaching node from cluster...")
+			phase.Step(fmt.Sprintf("Node is connected to %s cluster", nodeInfo.ClusterName))
+			phase.Update("Detaching node from cluster...")
+			step := span.Child("detach", "cluster.name", nodeInfo.ClusterName)
 			err = c.Qbert.DetachNode(nodeInfo.ClusterUuid, auth.ProjectID, auth.Token, hostID[0])
+			step.End(err)
 			if err != nil {
-				zap.S().Fatalf("Failed to detach host from cluster")
+				phase.Fail("Failed to detach host from cluster")
+				return fmt.Errorf("Failed to detach host from cluster: %w", err)
 			} else {
-				fmt.Println("Detached node from cluster")
+				phase.Step("Detached node from cluster")
 			}
 
 			//deauthorize host from UI
-			fmt.Println("Deauthorizing node from UI...")
+			phase.Update("Deauthorizing node from UI...")
+			step = span.Child("deauthorize")
 			err = c.Qbert.DeauthoriseNode(hostID[0], auth.Token)
+			step.End(err)
 			if err != nil {
-				zap.S().Fatalf("Failed to deauthorize node")
+				phase.Fail("Failed to deauthorize node")
+				return fmt.Errorf("Failed to deauthorize node: %w", err)
 			} else {
-				fmt.Println("Deauthorized node from UI")
+				phase.Step("Deauthorized node from UI")
 			}
 			//stop host agent and remove it
-			removeHostagent(c, hostOS)
+			removeHostagent(c, hostOS, phase, span)
 			//remove pf9 dir
 			if removePf9 {
-				removePf9Installation(c)
+				removePf9Installation(c, phase, span)
+			}
+		}
+
+		if nodeConnectedToDU {
+			phase.Update("Waiting for the node to be removed from the management plane...")
+			step := span.Child("wait-host-removal")
+			err := waitForHostRemoval(c.Resmgr, auth.Token, hostID[0], DecommissionTimeout, decommissionPollInterval)
+			step.End(err)
+			if err != nil {
+				phase.Fail(fmt.Sprintf("Node decommission timed out: %s", err))
+				return err
+			}
+			phase.Step("Node removed from the management plane")
+		}
+
+		phase.Update("Verifying Platform9 services are removed...")
+		step := span.Child("verify-removal")
+		err := verifyHostagentRemoved(c.Executor, hostOS)
+		step.End(err)
+		if err != nil {
+			phase.Fail(fmt.Sprintf("Node decommission incomplete: %s", err))
+			return err
+		}
+
+		if DecommissionCleanRuntime {
+			phase.Update("Removing the containers and images of the pods...")
+			step := span.Child("clean-runtime")
+			err := cleanRuntime(c.Executor)
+			step.End(err)
+			if err != nil {
+				phase.Warn(err.Error())
+			} else {
+				phase.Step("Removed the containers and images of the pods")
+			}
+		}
+
+		phase.Update("Checking for leftovers of Platform9...")
+		leftovers := findLeftovers(c.Executor, hostOS)
+		if DecommissionDeepClean && leftovers.Network() {
+			phase.Update("Removing CNI network artifacts...")
+			step := span.Child("deep-clean-network")
+			err := deepCleanNetwork(c.Executor, leftovers.Interfaces)
+			step.End(err)
+			if err != nil {
+				phase.Warn(err.Error())
+			} else {
+				phase.Step("Removed CNI network artifacts")
 			}
-			fmt.Println("Node decommissioning started....This may take a few minutes....Check the latest status in UI")
-			time.Sleep(50 * time.Second)
+			leftovers = findLeftovers(c.Executor, hostOS)
+		}
+		for _, leftover := range leftovers.List() {
+			phase.Warn(leftover)
+		}
+		if leftovers.Network() && !DecommissionDeepClean {
+			phase.Warn("Run decommission-node with --deep-clean to remove the CNI network artifacts")
 		}
+		phase.Succeed("Node decommissioned")
 	} else {
 		fmt.Println("Host is not connected to Platform9 Management Plane")
 	}
+	return nil
 }
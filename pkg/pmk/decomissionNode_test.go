@@ -0,0 +1,87 @@
+package pmk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeResmgr answers GetHostInfo with the next of its responses
+type fakeResmgr struct {
+	resmgr.Resmgr
+	responses []error
+	states    []string
+	calls     int
+}
+
+func (r *fakeResmgr) GetHostInfo(token, hostID string) (resmgr.HostInfo, error) {
+	i := r.calls
+	if i >= len(r.responses) {
+		i = len(r.responses) - 1
+	}
+	r.calls++
+	return resmgr.HostInfo{ID: hostID, State: r.states[i]}, r.responses[i]
+}
+
+func TestWaitForHostRemoval(t *testing.T) {
+	cases := map[string]struct {
+		responses []error
+		states    []string
+		err       bool
+	}{
+		"Removed":        {responses: []error{nil, nil, resmgr.ErrHostNotFound}, states: []string{"active", "active", ""}},
+		"ReportsDeleted": {responses: []error{nil, nil}, states: []string{"active", "deleted"}},
+		"APIError":       {responses: []error{errors.New("code: 503"), resmgr.ErrHostNotFound}, states: []string{"", ""}},
+		"TimedOut":       {responses: []error{nil}, states: []string{"active"}, err: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &fakeResmgr{responses: tc.responses, states: tc.states}
+			err := waitForHostRemoval(r, "token", "host-1", 50*time.Millisecond, time.Millisecond)
+			assert.Equal(t, tc.err, err != nil)
+		})
+	}
+}
+
+func TestVerifyHostagentRemoved(t *testing.T) {
+	cases := map[string]struct {
+		installed bool
+		running   []string
+		err       string
+	}{
+		"Removed":            {},
+		"PackageInstalled":   {installed: true, err: "pf9-hostagent package is still installed"},
+		"ServicesStillAlive": {running: []string{"pf9-nodeletd", "pf9-kubelet"}, err: "services still running: pf9-nodeletd, pf9-kubelet"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunArgs: func(name string, args ...string) (string, error) {
+					cmd := strings.Join(append([]string{name}, args...), " ")
+					if strings.HasPrefix(cmd, "dpkg -s") && tc.installed {
+						return "Status: install ok installed", nil
+					}
+					for _, service := range tc.running {
+						if strings.HasSuffix(cmd, " "+service) {
+							return "", nil
+						}
+					}
+					return "", errors.New("exit status 1")
+				},
+			}
+			err := verifyHostagentRemoved(exec, "debian")
+			if tc.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package pmk
+
+import (
+	"fmt"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/ui"
+	"go.uber.org/zap"
+)
+
+// ClusterNodes returns the nodes attached to the cluster, workers first and
+// masters last so that the control plane is the last thing to go away.
+func ClusterNodes(allNodes []qbert.Node, clusterUuid string) []qbert.Node {
+	var workers, masters []qbert.Node
+	for _, node := range allNodes {
+		if node.ClusterUuid != clusterUuid {
+			continue
+		}
+		if node.IsMaster == 1 {
+			masters = append(masters, node)
+		} else {
+			workers = append(workers, node)
+		}
+	}
+	return append(workers, masters...)
+}
+
+// DecommissionCluster drains and detaches the nodes of the cluster, as returned
+// by ClusterNodes, removes the hostagent from each of them over SSH then
+// deauthorizes it, and finally deletes the cluster. A node the hostagent can't
+// be removed from stays authorized, so it can still be managed.
+func DecommissionCluster(cfg *objects.Config, nc objects.NodeConfig, c client.Client, auth keystone.KeystoneAuth, clusterUuid string, nodes []qbert.Node) error {
+	zap.S().Debugf("Received a call to decommission cluster %s", clusterUuid)
+
+	fmt.Printf("Found %d node(s) attached to the cluster\n", len(nodes))
+
+	var failedNodes []string
+	for _, node := range nodes {
+		phase := ui.StartPhase(fmt.Sprintf("Decommissioning node %s", node.PrimaryIp))
+		if err := decommissionClusterNode(cfg, nc, c, auth, node, phase); err != nil {
+			phase.Fail(fmt.Sprintf("%s: %s", node.PrimaryIp, err))
+			zap.S().Debugf("Failed to decommission node %s: %s", node.PrimaryIp, err)
+			failedNodes = append(failedNodes, node.PrimaryIp)
+			continue
+		}
+		phase.Succeed(fmt.Sprintf("%s: node decommissioned", node.PrimaryIp))
+	}
+
+	if len(failedNodes) > 0 {
+		return fmt.Errorf("unable to decommission node(s) %v, cluster is not deleted", failedNodes)
+	}
+
+	phase := ui.StartPhase("Deleting the cluster")
+	defer phase.Stop()
+	if err := c.Qbert.DeleteCluster(clusterUuid, auth.ProjectID, auth.Token); err != nil {
+		phase.Fail("Unable to delete the cluster")
+		return fmt.Errorf("unable to delete cluster: %w", err)
+	}
+	phase.Succeed("Cluster deletion started....This may take a few minutes.")
+	return nil
+}
+
+func decommissionClusterNode(cfg *objects.Config, nc objects.NodeConfig, c client.Client, auth keystone.KeystoneAuth, node qbert.Node, phase *ui.Phase) error {
+	// The node is reached before anything changes, so an unreachable node is
+	// left as it was
+	nodeConfig := nc
+	nodeConfig.IPs = []string{node.PrimaryIp}
+	executor, err := cmdexec.GetExecutor(cfg.ProxyURL, nodeConfig)
+	if err != nil {
+		return fmt.Errorf("unable to connect to node: %w", err)
+	}
+	defer cmdexec.Close(executor)
+
+	hostOS, err := ValidatePlatform(executor)
+	if err != nil {
+		return fmt.Errorf("unable to determine OS of node: %w", err)
+	}
+
+	if err := cordonAndDrain(newKubeAPI(cfg.Fqdn, node.ClusterUuid, auth.Token), node.ClusterName, node.PrimaryIp, phase); err != nil {
+		return err
+	}
+	phase.Step("Drained node")
+
+	phase.Update(fmt.Sprintf("Detaching node %s from cluster", node.PrimaryIp))
+	if err := c.Qbert.DetachNode(node.ClusterUuid, auth.ProjectID, auth.Token, node.Uuid); err != nil {
+		return fmt.Errorf("unable to detach node from cluster: %w", err)
+	}
+	phase.Step("Detached node from cluster")
+
+	nodeClient := c
+	nodeClient.Executor = executor
+	removeHostagent(nodeClient, hostOS, phase, nil)
+	if hostagentInstalled(executor, hostOS) {
+		return fmt.Errorf("unable to remove the hostagent, the node is left authorized")
+	}
+
+	phase.Update(fmt.Sprintf("Deauthorizing node %s", node.PrimaryIp))
+	if err := c.Qbert.DeauthoriseNode(node.Uuid, auth.Token); err != nil {
+		return fmt.Errorf("unable to deauthorize node: %w", err)
+	}
+	phase.Step("Deauthorized node from UI")
+	return nil
+}
@@ -0,0 +1,47 @@
+package pmk
+
+import (
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterNodes(t *testing.T) {
+	allNodes := []qbert.Node{
+		{Uuid: "master-1", ClusterUuid: "cluster-a", IsMaster: 1},
+		{Uuid: "worker-1", ClusterUuid: "cluster-a"},
+		{Uuid: "worker-2", ClusterUuid: "cluster-b"},
+		{Uuid: "unattached"},
+		{Uuid: "worker-3", ClusterUuid: "cluster-a"},
+	}
+
+	cases := map[string]struct {
+		clusterUuid string
+		want        []string
+	}{
+		//Workers should come before masters, nodes of other clusters are skipped.
+		"WorkersFirst": {
+			clusterUuid: "cluster-a",
+			want:        []string{"worker-1", "worker-3", "master-1"},
+		},
+		"OtherCluster": {
+			clusterUuid: "cluster-b",
+			want:        []string{"worker-2"},
+		},
+		"NoNodes": {
+			clusterUuid: "cluster-c",
+			want:        nil,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, node := range ClusterNodes(allNodes, tc.clusterUuid) {
+				got = append(got, node.Uuid)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package pmk
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/util"
+)
+
+// DecommissionImpact is what decommissioning some of the nodes of a cluster
+// puts at risk
+type DecommissionImpact struct {
+	Cluster string
+	Nodes   []string
+	Risks   []string
+}
+
+// DecommissionRiskError is returned for the clusters which decommissioning
+// the nodes puts at risk
+type DecommissionRiskError struct {
+	Impacts []DecommissionImpact
+}
+
+func (e *DecommissionRiskError) Error() string {
+	var clusters []string
+	for _, impact := range e.Impacts {
+		clusters = append(clusters, fmt.Sprintf("%s (nodes %s): %s", impact.Cluster, strings.Join(impact.Nodes, ", "), strings.Join(impact.Risks, ", ")))
+	}
+	return fmt.Sprintf("decommissioning the node(s) puts %d cluster(s) at risk: %s", len(e.Impacts), strings.Join(clusters, "; "))
+}
+
+// CheckDecommission returns the clusters which decommissioning the nodes
+// with the IPs would drop below the etcd quorum of their masters or leave
+// without a schedulable node for the pods evicted from the nodes. It fails
+// when resmgr or qbert can't tell which clusters the nodes are in.
+func CheckDecommission(c client.Client, auth keystone.KeystoneAuth, fqdn string, ips []string) ([]DecommissionImpact, error) {
+	hosts, err := c.Resmgr.GetHosts(auth.Token)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the hosts of resmgr: %w", err)
+	}
+	removed := make(map[string]string)
+	for _, host := range hosts {
+		for _, ip := range ips {
+			if util.ContainsIP(host.Extensions.IPAddress.Data, ip) {
+				removed[host.ID] = ip
+			}
+		}
+	}
+	allNodes, err := c.Qbert.ListNodes(auth.Token, auth.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the nodes of qbert: %w", err)
+	}
+
+	byCluster := make(map[string][]qbert.Node)
+	for _, node := range allNodes {
+		if node.ClusterUuid != "" {
+			byCluster[node.ClusterUuid] = append(byCluster[node.ClusterUuid], node)
+		}
+	}
+
+	var impacts []DecommissionImpact
+	for clusterUuid, nodes := range byCluster {
+		var removedNodes []qbert.Node
+		for _, node := range nodes {
+			if _, found := removed[node.Uuid]; found {
+				removedNodes = append(removedNodes, node)
+			}
+		}
+		if len(removedNodes) == 0 {
+			continue
+		}
+
+		impact := DecommissionImpact{Cluster: nodes[0].ClusterName}
+		for _, node := range removedNodes {
+			impact.Nodes = append(impact.Nodes, removed[node.Uuid])
+		}
+		sort.Strings(impact.Nodes)
+		if risk := quorumRisk(nodes, removedNodes); risk != "" {
+			impact.Risks = append(impact.Risks, risk)
+		}
+		if risk := workloadRisk(newKubeAPI(fqdn, clusterUuid, auth.Token), impact.Nodes); risk != "" {
+			impact.Risks = append(impact.Risks, risk)
+		}
+		if len(impact.Risks) > 0 {
+			impacts = append(impacts, impact)
+		}
+	}
+
+	sort.Slice(impacts, func(i, j int) bool {
+		return impacts[i].Cluster < impacts[j].Cluster
+	})
+	return impacts, nil
+}
+
+// quorumRisk describes how removing the nodes breaks the etcd quorum of the
+// masters of the cluster, if it does
+func quorumRisk(clusterNodes, removedNodes []qbert.Node) string {
+	masters, removedMasters := 0, 0
+	for _, node := range clusterNodes {
+		if node.IsMaster == 1 {
+			masters++
+		}
+	}
+	for _, node := range removedNodes {
+		if node.IsMaster == 1 {
+			removedMasters++
+		}
+	}
+	if removedMasters == 0 {
+		return ""
+	}
+
+	left := masters - removedMasters
+	quorum := masters/2 + 1
+	if left == 0 {
+		return fmt.Sprintf("removes all the %d master(s), the cluster loses its control plane", masters)
+	}
+	if left < quorum {
+		return fmt.Sprintf("removes %d of the %d masters, the %d left are below the etcd quorum of %d", removedMasters, masters, left, quorum)
+	}
+	return ""
+}
+
+// workloadRisk describes the pods evicted from the nodes with the IPs which
+// no node left in the cluster can run, if any
+func workloadRisk(kube kubeAPI, ips []string) string {
+	nodes, err := kube.nodes()
+	if err != nil {
+		return fmt.Sprintf("unable to check the workloads of the nodes: %s", err)
+	}
+
+	evicted, schedulable := 0, 0
+	for _, node := range nodes {
+		removed := false
+		for _, ip := range ips {
+			if node.hasIP(ip) {
+				removed = true
+			}
+		}
+		if !removed {
+			if node.schedulable() {
+				schedulable++
+			}
+			continue
+		}
+		pods, err := kube.evictablePods(node.Metadata.Name)
+		if err != nil {
+			return fmt.Sprintf("unable to check the workloads of node %s: %s", node.Metadata.Name, err)
+		}
+		evicted += len(pods)
+	}
+
+	if evicted > 0 && schedulable == 0 {
+		return fmt.Sprintf("evicts %d pod(s) which no node left in the cluster can schedule", evicted)
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package pmk
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuorumRisk(t *testing.T) {
+	nodes := func(masters, workers int) []qbert.Node {
+		var list []qbert.Node
+		for i := 0; i < masters; i++ {
+			list = append(list, qbert.Node{IsMaster: 1})
+		}
+		for i := 0; i < workers; i++ {
+			list = append(list, qbert.Node{})
+		}
+		return list
+	}
+
+	cases := map[string]struct {
+		masters, removedMasters, removedWorkers int
+		want                                    string
+	}{
+		"Workers":        {masters: 3, removedWorkers: 2},
+		"OneOfThree":     {masters: 3, removedMasters: 1},
+		"TwoOfThree":     {masters: 3, removedMasters: 2, want: "removes 2 of the 3 masters, the 1 left are below the etcd quorum of 2"},
+		"TwoOfFive":      {masters: 5, removedMasters: 2},
+		"ThreeOfFive":    {masters: 5, removedMasters: 3, want: "removes 3 of the 5 masters, the 2 left are below the etcd quorum of 3"},
+		"OneOfTwo":       {masters: 2, removedMasters: 1, want: "removes 1 of the 2 masters, the 1 left are below the etcd quorum of 2"},
+		"AllMasters":     {masters: 3, removedMasters: 3, want: "removes all the 3 master(s), the cluster loses its control plane"},
+		"OnlyMaster":     {masters: 1, removedMasters: 1, want: "removes all the 1 master(s), the cluster loses its control plane"},
+		"MasterAndWorks": {masters: 3, removedMasters: 1, removedWorkers: 2},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, quorumRisk(nodes(tc.masters, 3), nodes(tc.removedMasters, tc.removedWorkers)))
+		})
+	}
+}
+
+func TestKubeNodeSchedulable(t *testing.T) {
+	ready := func() kubeNode {
+		var node kubeNode
+		node.Status.Conditions = append(node.Status.Conditions, struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		}{Type: "Ready", Status: "True"})
+		return node
+	}
+	assert.True(t, ready().schedulable())
+
+	cordoned := ready()
+	cordoned.Spec.Unschedulable = true
+	assert.False(t, cordoned.schedulable())
+
+	tainted := ready()
+	tainted.Spec.Taints = append(tainted.Spec.Taints, struct {
+		Key    string `json:"key"`
+		Effect string `json:"effect"`
+	}{Key: "node-role.kubernetes.io/master", Effect: "NoSchedule"})
+	assert.False(t, tainted.schedulable())
+
+	notReady := ready()
+	notReady.Status.Conditions[0].Status = "False"
+	assert.False(t, notReady.schedulable())
+	assert.False(t, kubeNode{}.schedulable())
+}
+
+func TestWorkloadRisk(t *testing.T) {
+	node := func(name, ip, extra string) string {
+		return `{"metadata": {"name": "` + name + `"}, "spec": {` + extra + `}, "status": {"addresses": [{"type": "InternalIP", "address": "` + ip + `"}], "conditions": [{"type": "Ready", "status": "True"}]}}`
+	}
+	nodes := `{"items": [` + strings.Join([]string{
+		node("master-1", "10.0.0.1", `"taints": [{"key": "node-role.kubernetes.io/master", "effect": "NoSchedule"}]`),
+		node("worker-1", "10.0.0.2", ""),
+		node("worker-2", "10.0.0.3", ""),
+		node("worker-3", "10.0.0.4", `"unschedulable": true`),
+	}, ",") + `]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/qbert/v1/clusters/c1/k8sapi/api/v1/nodes":
+			w.Write([]byte(nodes))
+		case "/qbert/v1/clusters/c1/k8sapi/api/v1/pods":
+			w.Write([]byte(kubePods))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	kube := newKubeAPI(server.URL, "c1", "token")
+
+	assert.Equal(t, "", workloadRisk(kube, []string{"10.0.0.2"}))
+	// worker-3 is cordoned and the master is tainted, the pods of the workers have nowhere to go
+	assert.Equal(t, "evicts 4 pod(s) which no node left in the cluster can schedule", workloadRisk(kube, []string{"10.0.0.2", "10.0.0.3"}))
+	assert.Contains(t, workloadRisk(newKubeAPI(server.URL, "c2", "token"), []string{"10.0.0.2"}), "unable to check the workloads of the nodes")
+}
+
+func TestDecommissionRiskError(t *testing.T) {
+	err := &DecommissionRiskError{Impacts: []DecommissionImpact{
+		{Cluster: "prod", Nodes: []string{"10.0.0.1"}, Risks: []string{"removes all the 1 master(s), the cluster loses its control plane"}},
+		{Cluster: "dev", Nodes: []string{"10.0.0.2", "10.0.0.3"}, Risks: []string{"risk a", "risk b"}},
+	}}
+	assert.EqualError(t, err, "decommissioning the node(s) puts 2 cluster(s) at risk: "+
+		"prod (nodes 10.0.0.1): removes all the 1 master(s), the cluster loses its control plane; "+
+		"dev (nodes 10.0.0.2, 10.0.0.3): risk a, risk b")
+}
@@ -0,0 +1,57 @@
+package pmk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// downloadLimitUnits are the multipliers of the units of --download-limit,
+// binary like the ones of curl --limit-rate
+var downloadLimitUnits = map[string]float64{
+	"":  1,
+	"K": 1024,
+	"M": 1024 * 1024,
+	"G": 1024 * 1024 * 1024,
+}
+
+// parseDownloadLimit returns the bytes per second of a limit like 10MB/s,
+// 512K or 1048576
+func parseDownloadLimit(limit string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(limit))
+	s = strings.TrimSuffix(s, "/S")
+	s = strings.TrimSuffix(s, "B")
+
+	unit := ""
+	if n := len(s); n > 0 {
+		if _, ok := downloadLimitUnits[s[n-1:]]; ok {
+			unit = s[n-1:]
+			s = s[:n-1]
+		}
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid download limit %q, use a rate like 10MB/s or 512KB/s", limit)
+	}
+	return int64(value * downloadLimitUnits[unit]), nil
+}
+
+// ValidateDownloadLimit checks the download limit given by the user
+func ValidateDownloadLimit(limit string) error {
+	_, err := parseDownloadLimit(limit)
+	return err
+}
+
+// curlLimitArgs are the arguments of curl limiting the rate of the download
+// of the installer, so preparing many nodes behind a slow link doesn't
+// saturate it
+func curlLimitArgs(limit string) []string {
+	if limit == "" {
+		return nil
+	}
+	rate, err := parseDownloadLimit(limit)
+	if err != nil {
+		return nil
+	}
+	return []string{"--limit-rate", strconv.FormatInt(rate, 10)}
+}
@@ -0,0 +1,37 @@
+package pmk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDownloadLimit(t *testing.T) {
+	cases := map[string]struct {
+		limit string
+		want  int64
+		err   bool
+	}{
+		"MBPerSecond": {limit: "10MB/s", want: 10 * 1024 * 1024},
+		"KB":          {limit: "512kb", want: 512 * 1024},
+		"Short":       {limit: "1.5M", want: 1572864},
+		"Gigabit":     {limit: "1G/s", want: 1024 * 1024 * 1024},
+		"Bytes":       {limit: "1048576", want: 1048576},
+		"Zero":        {limit: "0MB/s", err: true},
+		"Unit":        {limit: "10TB/s", err: true},
+		"Empty":       {limit: "", err: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseDownloadLimit(tc.limit)
+			assert.Equal(t, tc.err, err != nil)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCurlLimitArgs(t *testing.T) {
+	assert.Nil(t, curlLimitArgs(""))
+	assert.Equal(t, []string{"--limit-rate", "10485760"}, curlLimitArgs("10MB/s"))
+}
@@ -0,0 +1,358 @@
+package pmk
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/swapoff"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Areas of the state of a node checked for drift
+const (
+	DriftSysctl   = "sysctl"
+	DriftSwap     = "swap"
+	DriftService  = "service"
+	DriftFirewall = "firewall"
+	DriftProxy    = "proxy"
+	DriftPackage  = "package"
+)
+
+// driftServices are the services prep-node leaves running and enabled
+var driftServices = []string{"pf9-hostagent", "pf9-comms"}
+
+// driftPackages are the packages every onboarded node has installed
+var driftPackages = []string{"pf9-hostagent", "pf9-comms"}
+
+// Drift is a difference between the state of an onboarded node and the state
+// prep-node left it in
+type Drift struct {
+	Area    string
+	Message string
+	// fix repairs the drift, the drifts with the same fixID share a single
+	// run of it
+	fix   func(cmdexec.Executor) error
+	fixID string
+}
+
+// Fixable reports whether FixDrift can repair the drift
+func (d Drift) Fixable() bool {
+	return d.fix != nil
+}
+
+// DriftBaseline is the state expected of an onboarded node
+type DriftBaseline struct {
+	// Role is the role the node was prepared for, read from the kernel
+	// settings prep-node applied when empty
+	Role string
+	// Proxy is the proxy the pf9 services go through, nil when they connect
+	// to the DU directly
+	Proxy *NodeProxy
+	// Packages are the versions expected of pf9 packages by name, like the
+	// pf9-kube version of the cluster of the node
+	Packages map[string]string
+}
+
+// NewDriftBaseline returns the baseline of the node of exec for role and the
+// proxy of the config. When the node is attached to a cluster, its pf9-kube
+// version is expected to be the one of the cluster.
+func NewDriftBaseline(c client.Client, auth keystone.KeystoneAuth, exec cmdexec.Executor, role string, proxy *NodeProxy) DriftBaseline {
+	baseline := DriftBaseline{Role: role, Proxy: proxy, Packages: make(map[string]string)}
+	hostID := readHostID(exec)
+	if hostID == "" {
+		return baseline
+	}
+	node := c.Qbert.GetNodeInfo(auth.Token, auth.ProjectID, hostID)
+	if node.ClusterUuid == "" {
+		return baseline
+	}
+	cluster, err := c.Qbert.GetCluster(node.ClusterUuid, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Debugf("Unable to get cluster %s, skipping the pf9-kube version: %s", node.ClusterUuid, err.Error())
+		return baseline
+	}
+	if cluster.KubeRoleVersion != "" {
+		baseline.Packages["pf9-kube"] = cluster.KubeRoleVersion
+	}
+	return baseline
+}
+
+// DetectDrift compares the kernel settings, swap, services, firewall rules,
+// proxy and pf9 packages of the onboarded node of exec with the baseline
+func DetectDrift(exec cmdexec.Executor, baseline DriftBaseline) ([]Drift, error) {
+	if _, err := exec.RunArgs("test", "-d", "/opt/pf9/hostagent"); err != nil {
+		return nil, fmt.Errorf("hostagent is not installed, prepare the node with prep-node first")
+	}
+	hostOS, err := ValidatePlatform(exec)
+	if err != nil {
+		return nil, err
+	}
+	if hostOS == "" {
+		return nil, fmt.Errorf("unsupported OS")
+	}
+
+	role := baseline.Role
+	if role == "" {
+		role = preparedRole(exec)
+	}
+
+	var drifts []Drift
+	drifts = append(drifts, sysctlDrift(exec, role, baseline.Role != "")...)
+	drifts = append(drifts, swapDrift(exec)...)
+	drifts = append(drifts, serviceDrift(exec)...)
+	drifts = append(drifts, firewallDrift(exec, role)...)
+	drifts = append(drifts, proxyDrift(exec, baseline.Proxy)...)
+	drifts = append(drifts, packageDrift(exec, hostOS, baseline.Packages)...)
+	return drifts, nil
+}
+
+// FixDrift repairs the drifts which can be, running each fix once, and
+// returns the errors of the fixes which failed
+func FixDrift(exec cmdexec.Executor, drifts []Drift) []error {
+	var errs []error
+	done := make(map[string]bool)
+	for _, d := range drifts {
+		if !d.Fixable() || done[d.fixID] {
+			continue
+		}
+		done[d.fixID] = true
+		zap.S().Debugf("Fixing the %s drift: %s", d.Area, d.Message)
+		if err := d.fix(exec); err != nil {
+			errs = append(errs, fmt.Errorf("unable to fix %s: %w", d.Message, err))
+		}
+	}
+	return errs
+}
+
+var preparedRoleRegexp = regexp.MustCompile(`prep-node for the (\w+) role`)
+
+// preparedRole returns the role of the kernel settings prep-node applied,
+// empty when the node was prepared for any role
+func preparedRole(exec cmdexec.Executor) string {
+	out, err := exec.RunArgs("cat", sysctlFile)
+	if err != nil {
+		return ""
+	}
+	if match := preparedRoleRegexp.FindStringSubmatch(out); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// sysctlDrift compares the kernel settings with the ones of role. The file
+// of the settings is only expected when the role is given, otherwise the
+// role is read from it.
+func sysctlDrift(exec cmdexec.Executor, role string, expectFile bool) []Drift {
+	sysctls, ok := roleSysctls[role]
+	if !ok {
+		return nil
+	}
+	fix := func(exec cmdexec.Executor) error {
+		return applyRoleSysctls(exec, role)
+	}
+
+	var drifts []Drift
+	if expectFile {
+		if _, err := exec.RunArgs("test", "-f", sysctlFile); err != nil {
+			drifts = append(drifts, Drift{Area: DriftSysctl, fix: fix, fixID: DriftSysctl,
+				Message: fmt.Sprintf("%s is missing, the settings of the %s role are lost on reboot", sysctlFile, role)})
+		}
+	}
+
+	keys := make([]string, 0, len(sysctls))
+	for key := range sysctls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		out, err := exec.RunArgs("sysctl", "-n", key)
+		value := strings.Join(strings.Fields(out), " ")
+		switch {
+		case err != nil || value == "":
+			drifts = append(drifts, Drift{Area: DriftSysctl, fix: fix, fixID: DriftSysctl,
+				Message: fmt.Sprintf("%s is not set, expected %s", key, sysctls[key])})
+		case value != sysctls[key]:
+			drifts = append(drifts, Drift{Area: DriftSysctl, fix: fix, fixID: DriftSysctl,
+				Message: fmt.Sprintf("%s is %s, expected %s", key, value, sysctls[key])})
+		}
+	}
+	return drifts
+}
+
+func swapDrift(exec cmdexec.Executor) []Drift {
+	out, err := exec.RunArgs("swapon", "--noheadings", "--show")
+	if err != nil || strings.TrimSpace(out) == "" {
+		return nil
+	}
+	return []Drift{{Area: DriftSwap, fix: swapoff.SetupNode, fixID: DriftSwap,
+		Message: "swap is enabled, the kubelet needs it disabled"}}
+}
+
+// serviceDrift checks the pf9 services are running and start on boot. They
+// are expected down while the node is in maintenance.
+func serviceDrift(exec cmdexec.Executor) []Drift {
+	if _, err := exec.RunArgs("test", "-f", maintenanceMarker); err == nil {
+		zap.S().Debug("Node is in maintenance, skipping the services")
+		return nil
+	}
+
+	var drifts []Drift
+	for _, service := range driftServices {
+		service := service
+		fix := func(exec cmdexec.Executor) error {
+			_, err := exec.RunArgs("systemctl", "enable", "--now", service)
+			return err
+		}
+		if _, err := exec.RunArgs("systemctl", "is-active", "--quiet", service); err != nil {
+			drifts = append(drifts, Drift{Area: DriftService, fix: fix, fixID: DriftService + service,
+				Message: fmt.Sprintf("%s is not running", service)})
+		}
+		if _, err := exec.RunArgs("systemctl", "is-enabled", "--quiet", service); err != nil {
+			drifts = append(drifts, Drift{Area: DriftService, fix: fix, fixID: DriftService + service,
+				Message: fmt.Sprintf("%s is not enabled, it doesn't start on boot", service)})
+		}
+	}
+	return drifts
+}
+
+// firewallDrift checks the rules configure-firewall added for role are all
+// still there. Nodes without any of these rules, whose firewall isn't managed
+// with pf9ctl, and firewalld, whose rules can't be told apart, are skipped.
+func firewallDrift(exec cmdexec.Executor, role string) []Drift {
+	var listing string
+	var err error
+	firewall := DetectFirewall(exec)
+	switch firewall {
+	case FirewallUfw:
+		listing, err = exec.RunArgs("ufw", "status")
+	case FirewallIptables:
+		listing, err = exec.RunArgs("iptables", "-S", "INPUT")
+	default:
+		return nil
+	}
+	if err != nil || !strings.Contains(listing, firewallComment+" ") {
+		return nil
+	}
+
+	// The rules of the workers are the ones every node needs
+	if role == "" {
+		role = util.RoleWorker
+	}
+	rules, err := FirewallRules(role, FirewallCIDRs{})
+	if err != nil {
+		return nil
+	}
+	var drifts []Drift
+	for _, rule := range rules {
+		// The rules of ufw for the other protocols are in its before.rules,
+		// which ufw status doesn't list
+		if firewall == FirewallUfw && rule.Protocol == protocolIPIP {
+			continue
+		}
+		if !strings.Contains(listing, firewallComment+" "+rule.Description) {
+			drifts = append(drifts, Drift{Area: DriftFirewall,
+				Message: fmt.Sprintf("%s rule of %s %s is missing, add it with configure-firewall", firewall, rule.Description, orAny(rule.Ports, "all"))})
+		}
+	}
+	return drifts
+}
+
+// proxyDrift compares the proxy pf9-comms goes through with the expected one
+func proxyDrift(exec cmdexec.Executor, expected *NodeProxy) []Drift {
+	var current *NodeProxy
+	if out, err := exec.RunArgs("cat", commsProxyFile); err == nil {
+		current = parseCommsProxy(out)
+	}
+
+	var message string
+	switch {
+	case current == nil && expected == nil:
+		return nil
+	case expected == nil:
+		message = fmt.Sprintf("pf9-comms goes through proxy %s, expected no proxy", current.address())
+	case current == nil:
+		message = fmt.Sprintf("pf9-comms connects directly, expected proxy %s", expected.address())
+	case current.address() != expected.address():
+		message = fmt.Sprintf("pf9-comms goes through proxy %s, expected proxy %s", current.address(), expected.address())
+	default:
+		return nil
+	}
+	fix := func(exec cmdexec.Executor) error {
+		return ConfigureNodeProxy(exec, expected)
+	}
+	return []Drift{{Area: DriftProxy, Message: message, fix: fix, fixID: DriftProxy}}
+}
+
+// parseCommsProxy returns the proxy of the pf9-comms configuration, nil when
+// it sets none
+func parseCommsProxy(conf string) *NodeProxy {
+	var config struct {
+		HTTPProxy *struct {
+			Protocol string `json:"protocol"`
+			Host     string `json:"host"`
+			Port     int    `json:"port"`
+		} `json:"http_proxy"`
+	}
+	if err := json.Unmarshal([]byte(conf), &config); err != nil || config.HTTPProxy == nil || config.HTTPProxy.Host == "" {
+		return nil
+	}
+	return &NodeProxy{Protocol: config.HTTPProxy.Protocol, Host: config.HTTPProxy.Host, Port: config.HTTPProxy.Port}
+}
+
+// address returns the proxy URL without its credentials
+func (p NodeProxy) address() string {
+	p.User, p.Password = "", ""
+	return p.URL()
+}
+
+// packageDrift checks the pf9 packages are installed, with the expected
+// versions when known. The hostagent installs and upgrades the packages, so
+// they are left to prep-node and to the hostagent to fix.
+func packageDrift(exec cmdexec.Executor, hostOS string, expected map[string]string) []Drift {
+	packages := append([]string{}, driftPackages...)
+	var extra []string
+	for name := range expected {
+		if !hasString(packages, name) {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	packages = append(packages, extra...)
+
+	var drifts []Drift
+	for _, name := range packages {
+		version := installedVersion(exec, hostOS, name)
+		want := expected[name]
+		switch {
+		case version == "":
+			drifts = append(drifts, Drift{Area: DriftPackage,
+				Message: fmt.Sprintf("%s is not installed", name)})
+		case want != "" && compareVersions(version, want) != 0:
+			drifts = append(drifts, Drift{Area: DriftPackage,
+				Message: fmt.Sprintf("%s %s is installed, expected %s", name, version, want)})
+		}
+	}
+	return drifts
+}
+
+// installedVersion returns the version of the package, empty when it isn't installed
+func installedVersion(exec cmdexec.Executor, hostOS, name string) string {
+	var out string
+	var err error
+	if hostOS == "debian" {
+		out, err = exec.RunArgs("dpkg-query", "-W", "-f=${Version}", name)
+	} else {
+		out, err = exec.RunArgs("rpm", "-q", "--qf", "%{VERSION}-%{RELEASE}", name)
+	}
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
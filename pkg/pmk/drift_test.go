@@ -0,0 +1,181 @@
+package pmk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// driftMessages returns the messages of the drifts
+func driftMessages(drifts []Drift) []string {
+	var messages []string
+	for _, d := range drifts {
+		messages = append(messages, d.Message)
+	}
+	return messages
+}
+
+func TestSysctlDrift(t *testing.T) {
+	values := map[string]string{
+		"net.core.somaxconn":          "4096",
+		"fs.inotify.max_user_watches": "524288",
+	}
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			if name == "test" {
+				return "", errors.New("exit status 1")
+			}
+			if value, found := values[args[1]]; found {
+				return value + "\n", nil
+			}
+			return "", errors.New("exit status 255")
+		},
+	}
+
+	drifts := sysctlDrift(exec, util.RoleMaster, true)
+	assert.Equal(t, []string{
+		"/etc/sysctl.d/90-pf9-role.conf is missing, the settings of the master role are lost on reboot",
+		"net.core.somaxconn is 4096, expected 32768",
+		"vm.swappiness is not set, expected 0",
+	}, driftMessages(drifts))
+	for _, d := range drifts {
+		assert.True(t, d.Fixable())
+	}
+
+	assert.Empty(t, sysctlDrift(exec, "", false))
+}
+
+func TestPreparedRole(t *testing.T) {
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			return "# Set by pf9ctl prep-node for the worker role\nvm.max_map_count = 262144\n", nil
+		},
+	}
+	assert.Equal(t, util.RoleWorker, preparedRole(exec))
+}
+
+func TestProxyDrift(t *testing.T) {
+	conf := `{"http_proxy": {"protocol": "http", "host": "squid.example.com", "port": 3128}}`
+	cases := map[string]struct {
+		conf     string
+		expected *NodeProxy
+		want     string
+	}{
+		"Match": {
+			conf:     conf,
+			expected: &NodeProxy{Protocol: "http", Host: "squid.example.com", Port: 3128, User: "u", Password: "p"},
+		},
+		"NoProxy": {},
+		"Unexpected": {
+			conf: conf,
+			want: "pf9-comms goes through proxy http://squid.example.com:3128, expected no proxy",
+		},
+		"Missing": {
+			expected: &NodeProxy{Protocol: "http", Host: "squid.example.com", Port: 3128},
+			want:     "pf9-comms connects directly, expected proxy http://squid.example.com:3128",
+		},
+		"Other": {
+			conf:     conf,
+			expected: &NodeProxy{Protocol: "https", Host: "proxy.example.com", Port: 8443},
+			want:     "pf9-comms goes through proxy http://squid.example.com:3128, expected proxy https://proxy.example.com:8443",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunArgs: func(name string, args ...string) (string, error) {
+					if tc.conf == "" {
+						return "", errors.New("exit status 1")
+					}
+					return tc.conf, nil
+				},
+			}
+			drifts := proxyDrift(exec, tc.expected)
+			if tc.want == "" {
+				assert.Empty(t, drifts)
+				return
+			}
+			assert.Equal(t, []string{tc.want}, driftMessages(drifts))
+			assert.True(t, drifts[0].Fixable())
+		})
+	}
+}
+
+func TestFirewallDrift(t *testing.T) {
+	rules := []string{
+		`-A INPUT -p tcp -m tcp --dport 22 -m comment --comment "pf9 SSH" -j ACCEPT`,
+		`-A INPUT -p tcp -m tcp --dport 10250 -m comment --comment "pf9 kubelet API" -j ACCEPT`,
+		`-A INPUT -p tcp -m tcp --dport 179 -m comment --comment "pf9 Calico BGP" -j ACCEPT`,
+		`-A INPUT -p udp -m udp --dport 4789 -m comment --comment "pf9 Calico and Flannel VXLAN" -j ACCEPT`,
+		`-A INPUT -p udp -m udp --dport 8285 -m comment --comment "pf9 Flannel UDP backend" -j ACCEPT`,
+		`-A INPUT -p ipencap -m comment --comment "pf9 Calico IP-in-IP" -j ACCEPT`,
+	}
+	exec := func(listing string) cmdexec.Executor {
+		return &cmdexec.MockExecutor{
+			MockRunArgs: func(name string, args ...string) (string, error) {
+				switch name {
+				case "iptables":
+					return listing, nil
+				case "ufw":
+					return "Status: inactive\n", nil
+				}
+				return "", errors.New("exit status 3")
+			},
+		}
+	}
+
+	drifts := firewallDrift(exec(strings.Join(rules, "\n")), util.RoleWorker)
+	assert.Equal(t, []string{"iptables rule of NodePort services 30000:32767 is missing, add it with configure-firewall"}, driftMessages(drifts))
+	assert.False(t, drifts[0].Fixable())
+
+	// Firewalls not configured with pf9ctl are left alone
+	assert.Empty(t, firewallDrift(exec("-P INPUT ACCEPT\n"), util.RoleWorker))
+}
+
+func TestPackageDrift(t *testing.T) {
+	versions := map[string]string{
+		"pf9-hostagent": "5.4.0-1842",
+		"pf9-kube":      "1.20.11-pmk.2060",
+	}
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			if version, found := versions[args[len(args)-1]]; found {
+				return version, nil
+			}
+			return "", errors.New("exit status 1")
+		},
+	}
+
+	drifts := packageDrift(exec, "debian", map[string]string{"pf9-kube": "1.21.3-pmk.72"})
+	assert.Equal(t, []string{
+		"pf9-comms is not installed",
+		"pf9-kube 1.20.11-pmk.2060 is installed, expected 1.21.3-pmk.72",
+	}, driftMessages(drifts))
+}
+
+func TestFixDrift(t *testing.T) {
+	runs := 0
+	fix := func(cmdexec.Executor) error {
+		runs++
+		return nil
+	}
+	failing := func(cmdexec.Executor) error {
+		return errors.New("exit status 1")
+	}
+	drifts := []Drift{
+		{Area: DriftSysctl, Message: "a", fix: fix, fixID: DriftSysctl},
+		{Area: DriftSysctl, Message: "b", fix: fix, fixID: DriftSysctl},
+		{Area: DriftPackage, Message: "c"},
+		{Area: DriftSwap, Message: "swap is enabled", fix: failing, fixID: DriftSwap},
+	}
+
+	errs := FixDrift(&cmdexec.MockExecutor{}, drifts)
+	assert.Equal(t, 1, runs)
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "unable to fix swap is enabled: exit status 1")
+}
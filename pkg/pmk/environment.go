@@ -0,0 +1,104 @@
+package pmk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// Environments a node can run in
+const (
+	EnvAWS       = "aws"
+	EnvAzure     = "azure"
+	EnvGCP       = "gcp"
+	EnvOpenStack = "openstack"
+	EnvBareMetal = "baremetal"
+	// EnvVirtual is a VM outside of the known clouds, like on VMware
+	EnvVirtual = "virtual"
+	EnvUnknown = "unknown"
+)
+
+// environmentFile records the environment of the node, which the later runs
+// of pf9ctl read rather than probing the metadata endpoints again
+const environmentFile = "/etc/pf9/pf9ctl_environment"
+
+// metadataProbe is a request to the metadata endpoint of a cloud, codes are
+// the HTTP status codes it answers with
+type metadataProbe struct {
+	env    string
+	url    string
+	header string
+	codes  []string
+}
+
+// metadataProbes are tried in order. OpenStack serves the EC2 metadata too, so
+// it is probed before AWS. AWS answers 401 when IMDSv2 tokens are required.
+var metadataProbes = []metadataProbe{
+	{EnvAzure, "http://169.254.169.254/metadata/instance?api-version=2021-02-01", "Metadata: true", []string{"200"}},
+	{EnvGCP, "http://metadata.google.internal/computeMetadata/v1/", "Metadata-Flavor: Google", []string{"200"}},
+	{EnvOpenStack, "http://169.254.169.254/openstack/latest/meta_data.json", "", []string{"200"}},
+	{EnvAWS, "http://169.254.169.254/latest/meta-data/", "", []string{"200", "401"}},
+}
+
+// DetectEnvironment returns the environment recorded by a previous prep-node,
+// or else the cloud the node runs on from the metadata endpoints of the clouds,
+// telling bare metal from other VMs otherwise
+func DetectEnvironment(exec cmdexec.Executor) string {
+	if env := recordedEnvironment(exec); env != "" {
+		zap.S().Debugf("Node environment %s read from %s", env, environmentFile)
+		return env
+	}
+	for _, probe := range metadataProbes {
+		// The metadata endpoints are link local, they are never reached
+		// through the proxy
+		args := []string{"--silent", "--output", "/dev/null", "--write-out", "%{http_code}",
+			"--connect-timeout", "1", "--max-time", "2", "--noproxy", "*"}
+		if probe.header != "" {
+			args = append(args, "--header", probe.header)
+		}
+		code, _ := exec.RunArgs("curl", append(args, probe.url)...)
+		for _, c := range probe.codes {
+			if strings.TrimSpace(code) == c {
+				zap.S().Debugf("Metadata endpoint %s answered %s", probe.url, c)
+				return probe.env
+			}
+		}
+	}
+
+	// systemd-detect-virt prints none and exits with 1 on bare metal
+	virt, _ := exec.RunArgs("systemd-detect-virt")
+	switch virt = strings.TrimSpace(virt); virt {
+	case "":
+		return EnvUnknown
+	case "none":
+		return EnvBareMetal
+	default:
+		zap.S().Debugf("Node virtualized with %s", virt)
+		return EnvVirtual
+	}
+}
+
+// recordedEnvironment returns the environment of the node read from
+// environmentFile, empty when it isn't recorded or unknown
+func recordedEnvironment(exec cmdexec.Executor) string {
+	out, err := exec.RunArgs("cat", environmentFile)
+	if err != nil {
+		return ""
+	}
+	switch env := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(out), "environment=")); env {
+	case EnvAWS, EnvAzure, EnvGCP, EnvOpenStack, EnvBareMetal, EnvVirtual:
+		return env
+	}
+	return ""
+}
+
+// recordEnvironment writes the environment of the node to environmentFile
+func recordEnvironment(exec cmdexec.Executor, env string) error {
+	script := fmt.Sprintf("mkdir -p /etc/pf9 && echo environment=%s > %s", env, environmentFile)
+	if _, err := exec.RunWithStdout("bash", "-c", script); err != nil {
+		return fmt.Errorf("unable to record the environment of the node: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package pmk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectEnvironment(t *testing.T) {
+	cases := map[string]struct {
+		// codes are the status codes of the metadata endpoints by URL fragment
+		codes map[string]string
+		virt  string
+		// recorded is the content of the environment file
+		recorded string
+		want     string
+	}{
+		"AWS":          {codes: map[string]string{"latest/meta-data": "200"}, want: EnvAWS},
+		"AWSIMDSv2":    {codes: map[string]string{"latest/meta-data": "401"}, want: EnvAWS},
+		"Azure":        {codes: map[string]string{"metadata/instance": "200", "latest/meta-data": "404"}, want: EnvAzure},
+		"GCP":          {codes: map[string]string{"computeMetadata": "200"}, want: EnvGCP},
+		"OpenStack":    {codes: map[string]string{"openstack/latest": "200", "latest/meta-data": "200"}, want: EnvOpenStack},
+		"BareMetal":    {virt: "none\n", want: EnvBareMetal},
+		"VMware":       {virt: "vmware\n", want: EnvVirtual},
+		"AzureNoToken": {codes: map[string]string{"metadata/instance": "400"}, virt: "microsoft\n", want: EnvVirtual},
+		"Unknown":      {want: EnvUnknown},
+		"Recorded":     {recorded: "environment=gcp\n", want: EnvGCP},
+		// An unknown environment is detected again
+		"RecordedUnknown": {recorded: "environment=unknown\n", virt: "none\n", want: EnvBareMetal},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunArgs: func(name string, args ...string) (string, error) {
+					switch name {
+					case "cat":
+						if tc.recorded == "" {
+							return "", errors.New("exit status 1")
+						}
+						return tc.recorded, nil
+					case "curl":
+						assert.Contains(t, args, "--noproxy")
+						url := args[len(args)-1]
+						for fragment, code := range tc.codes {
+							if strings.Contains(url, fragment) {
+								return code, nil
+							}
+						}
+						return "000", errors.New("exit status 28")
+					case "systemd-detect-virt":
+						if tc.virt == "" {
+							return "", errors.New("command not found")
+						}
+						return tc.virt, nil
+					}
+					return "", nil
+				},
+			}
+			assert.Equal(t, tc.want, DetectEnvironment(exec))
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package pmk
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/tracing"
+	"github.com/platform9/pf9ctl/pkg/ui"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Phases of prep-node reported to segment
+const (
+	phaseStart         = "start"
+	phaseValidateOS    = "validate-os"
+	phaseHostID        = "host-id"
+	phaseExistingPkgs  = "existing-packages"
+	phaseRemoveRuntime = "remove-runtime"
+	phaseKernelTuning  = "kernel-tuning"
+	phaseReboot        = "reboot"
+	phaseInstallAgent  = "install-hostagent"
+	phaseInitialise    = "initialise-host"
+	phaseAuthorise     = "authorise-host"
+	phaseComplete      = "complete"
+)
+
+// Phases of the other commands reported to segment
+const (
+	phaseAttach        = "attach"
+	phaseDetach        = "detach"
+	phaseCreateCluster = "create-cluster"
+	phaseHostConnected = "host-connected"
+	phaseDeleteCluster = "delete-cluster"
+)
+
+// Names of the segment events of the commands tracked by phase
+const (
+	segmentEventPrep      = "Prep-node"
+	segmentEventCheck     = "CheckNode"
+	segmentEventAttach    = "Attach-node"
+	segmentEventDetach    = "Detach-node"
+	segmentEventAuthorize = "Authorize-node"
+	segmentEventBootstrap = "Bootstrap"
+)
+
+// trackEvent sends e to segment for the user of auth, the command goes on
+// when it can't be sent
+func trackEvent(c client.Client, auth keystone.KeystoneAuth, e client.Event) {
+	if err := c.Segment.Track(e, auth); err != nil {
+		zap.S().Debugf("Unable to send Segment event for %s. Error: %s", e.Name, err.Error())
+	}
+}
+
+// nodeLog returns the logger of the node host, whose lines are prefixed with
+// it as the nodes can be handled concurrently. The local node, or an unknown
+// one, logs through the global logger.
+func nodeLog(host string) *zap.SugaredLogger {
+	if host == "" || host == "localhost" {
+		return zap.S()
+	}
+	return log.ForHost(host)
+}
+
+// eventStatus is the status of the events of a phase which ended with err
+func eventStatus(err error) string {
+	if err != nil {
+		return checkFail
+	}
+	return checkPass
+}
+
+// phaseTracker sends the events of the phases of a command to segment, with
+// how long each phase took and the OS of the node, and records them as spans
+// of the trace of the operation
+type phaseTracker struct {
+	clients   client.Client
+	auth      keystone.KeystoneAuth
+	name      string
+	os        string
+	osVersion string
+	started   time.Time
+	// mu guards phase and passed, read when the command is interrupted
+	mu        sync.Mutex
+	phase     string
+	passed    []string
+	phaseAt   time.Time
+	span      *tracing.Span
+	phaseSpan *tracing.Span
+	operation string
+	// log is the logger of the node
+	log *zap.SugaredLogger
+	// budgets warns on ui when a phase is slow, when set
+	budgets *phaseBudgets
+	ui      *ui.Phase
+}
+
+// newPhaseTracker starts tracking the command name, whose span is operation,
+// reading the OS of the node of clients
+func newPhaseTracker(clients client.Client, auth keystone.KeystoneAuth, name, operation string) *phaseTracker {
+	now := time.Now()
+	host := cmdexec.Host(clients.Executor)
+	t := &phaseTracker{clients: clients, auth: auth, name: name, operation: operation, started: now, phaseAt: now, log: nodeLog(host)}
+	if release, err := clients.Executor.RunWithStdout("cat", "/etc/os-release"); err == nil {
+		t.os, t.osVersion = parseOSRelease(release)
+	}
+	t.span = tracing.Start(operation, "host.name", host, "os.type", t.os, "os.version", t.osVersion)
+	return t
+}
+
+// parseOSRelease returns the ID and VERSION_ID of an os-release file
+func parseOSRelease(release string) (string, string) {
+	var id, version string
+	for _, line := range strings.Split(release, "\n") {
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		value := strings.Trim(strings.TrimSpace(line[i+1:]), `"'`)
+		switch line[:i] {
+		case "ID":
+			id = value
+		case "VERSION_ID":
+			version = value
+		}
+	}
+	return id, version
+}
+
+// watchBudgets warns when a phase runs over multiple times its typical
+// duration, 0 never warns
+func (t *phaseTracker) watchBudgets(multiple float64) {
+	t.budgets = newPhaseBudgets(util.Pf9PhaseDurationsLoc, t.operation, multiple)
+}
+
+// show sets the phase shown to the user the warnings are printed on
+func (t *phaseTracker) show(p *ui.Phase) {
+	t.ui = p
+}
+
+// begin starts phase, the duration of the events is counted from there
+func (t *phaseTracker) begin(phase string) {
+	t.mu.Lock()
+	t.phase = phase
+	t.mu.Unlock()
+	t.phaseAt = time.Now()
+	t.phaseSpan = t.span.Child(phase)
+	if t.budgets != nil {
+		t.budgets.watch(phase, t.ui)
+	}
+}
+
+// stopBudget stops watching the duration of the current phase, recording it
+// when the phase succeeded
+func (t *phaseTracker) stopBudget(succeeded bool) {
+	if t.budgets == nil {
+		return
+	}
+	t.budgets.stop()
+	if succeeded && t.phase != phaseStart {
+		t.budgets.record(t.phase, time.Since(t.phaseAt))
+	}
+}
+
+// send sends the event of the current phase, whose duration is the one of
+// the whole command for the start and complete phases
+func (t *phaseTracker) send(phase, status string, err error, duration time.Duration) {
+	e := client.Event{
+		Name:      t.name,
+		Phase:     phase,
+		Status:    status,
+		Duration:  duration,
+		OS:        t.os,
+		OSVersion: t.osVersion,
+		Err:       err,
+	}
+	if sendErr := t.clients.Segment.Track(e, t.auth); sendErr != nil {
+		t.log.Debugf("Unable to send Segment event for %s. Error: %s", t.name, sendErr.Error())
+	}
+}
+
+// pass reports the current phase succeeded
+func (t *phaseTracker) pass() {
+	t.stopBudget(true)
+	t.phaseSpan.End(nil)
+	t.send(t.phase, checkPass, nil, time.Since(t.phaseAt))
+	t.mu.Lock()
+	t.passed = append(t.passed, t.phase)
+	t.mu.Unlock()
+}
+
+// progress returns the current phase and the phases which passed
+func (t *phaseTracker) progress() (string, []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.phase, append([]string(nil), t.passed...)
+}
+
+// fail reports the current phase and the command failed with err
+func (t *phaseTracker) fail(err error) {
+	t.stopBudget(false)
+	t.phaseSpan.End(err)
+	t.span.End(err)
+	t.send(t.phase, checkFail, err, time.Since(t.phaseAt))
+}
+
+// check reports the check name ran with its result, the checks aren't
+// timed one by one
+func (t *phaseTracker) check(name string, result bool, userErr string) {
+	if result {
+		t.send(name, checkPass, nil, 0)
+		return
+	}
+	t.send(name, checkFail, errors.New(userErr), 0)
+}
+
+// complete reports the whole command, with its total duration
+func (t *phaseTracker) complete() {
+	t.span.End(nil)
+	t.send(phaseComplete, checkPass, nil, time.Since(t.started))
+}
@@ -0,0 +1,21 @@
+package pmk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOSRelease(t *testing.T) {
+	id, version := parseOSRelease("NAME=\"Ubuntu\"\nVERSION_ID=\"20.04\"\nID=ubuntu\nID_LIKE=debian\n")
+	assert.Equal(t, "ubuntu", id)
+	assert.Equal(t, "20.04", version)
+
+	id, version = parseOSRelease("ID=\"centos\"\nVERSION_ID='7'\n")
+	assert.Equal(t, "centos", id)
+	assert.Equal(t, "7", version)
+
+	id, version = parseOSRelease("")
+	assert.Empty(t, id)
+	assert.Empty(t, version)
+}
@@ -0,0 +1,84 @@
+package pmk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/fips"
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// fipsState is the FIPS setup of a node
+type fipsState struct {
+	// Kernel is set when the kernel runs in FIPS mode
+	Kernel bool
+	// OpenSSL is the version of openssl, empty when it isn't installed
+	OpenSSL string
+	// Enforced is set when openssl refuses the algorithms FIPS doesn't
+	// approve, which it only does in FIPS mode
+	Enforced bool
+}
+
+// fipsScript prints whether the kernel runs in FIPS mode, the version of
+// openssl, and whether openssl refuses MD5, a line each
+const fipsScript = `cat /proc/sys/crypto/fips_enabled 2> /dev/null || echo 0
+openssl version 2> /dev/null || echo
+if echo | openssl md5 > /dev/null 2>&1; then echo allowed; else echo refused; fi`
+
+// parseFIPSState parses the output of fipsScript
+func parseFIPSState(out string) (fipsState, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		return fipsState{}, fmt.Errorf("unexpected output %q", out)
+	}
+	state := fipsState{
+		Kernel:  strings.TrimSpace(lines[0]) == "1",
+		OpenSSL: strings.TrimSpace(lines[1]),
+	}
+	state.Enforced = state.OpenSSL != "" && strings.TrimSpace(lines[2]) == "refused"
+	return state, nil
+}
+
+// fipsProblem returns why the node of state can't join a DU requiring FIPS,
+// empty when it can
+func fipsProblem(state fipsState) string {
+	switch {
+	case state.OpenSSL == "":
+		return "openssl isn't installed on the node"
+	case !state.Kernel:
+		return "the kernel of the node doesn't run in FIPS mode, enable it e.g: with 'fips-mode-setup --enable' and reboot the node"
+	case !state.Enforced:
+		return fmt.Sprintf("%s of the node isn't in FIPS mode, it accepts the algorithms FIPS doesn't approve", state.OpenSSL)
+	}
+	return ""
+}
+
+// checkFIPS checks the openssl of the node of exec is in FIPS mode, which the
+// DU requires in the FIPS mode of pf9ctl
+func checkFIPS(exec cmdexec.Executor) platform.Check {
+	name := "FIPS check"
+	out, err := exec.RunArgs("bash", "-c", fipsScript)
+	if err != nil {
+		return platform.Check{ID: platform.CheckIDFIPS, Name: name, Mandatory: true, Result: false, Err: err, UserErr: "unable to read the FIPS mode of the node"}
+	}
+	state, err := parseFIPSState(out)
+	if err != nil {
+		return platform.Check{ID: platform.CheckIDFIPS, Name: name, Mandatory: true, Result: false, Err: err, UserErr: "unable to read the FIPS mode of the node"}
+	}
+	zap.S().Debugf("Node FIPS kernel mode %t, openssl %q enforcing FIPS %t", state.Kernel, state.OpenSSL, state.Enforced)
+	if problem := fipsProblem(state); problem != "" {
+		return platform.Check{ID: platform.CheckIDFIPS, Name: name, Mandatory: true, Result: false, Err: fmt.Errorf("%s", problem), UserErr: problem}
+	}
+	return platform.Check{ID: platform.CheckIDFIPS, Name: name, Mandatory: true, Result: true}
+}
+
+// fipsChecks returns the FIPS check of the node of exec in the FIPS mode,
+// none otherwise
+func fipsChecks(exec cmdexec.Executor) []platform.Check {
+	if !fips.Enabled {
+		return nil
+	}
+	return []platform.Check{checkFIPS(exec)}
+}
@@ -0,0 +1,58 @@
+package pmk
+
+import (
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/fips"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFIPSState(t *testing.T) {
+	state, err := parseFIPSState("1\nOpenSSL 1.1.1k  FIPS 25 Mar 2021\nrefused\n")
+	assert.NoError(t, err)
+	assert.Equal(t, fipsState{Kernel: true, OpenSSL: "OpenSSL 1.1.1k  FIPS 25 Mar 2021", Enforced: true}, state)
+
+	state, err = parseFIPSState("0\n\nrefused\n")
+	assert.NoError(t, err)
+	assert.Equal(t, fipsState{}, state)
+
+	_, err = parseFIPSState("1\n")
+	assert.Error(t, err)
+}
+
+func TestFIPSProblem(t *testing.T) {
+	cases := map[string]struct {
+		state   fipsState
+		problem string
+	}{
+		"enforced":     {fipsState{Kernel: true, OpenSSL: "OpenSSL 3.0.7", Enforced: true}, ""},
+		"no openssl":   {fipsState{Kernel: true}, "openssl isn't installed on the node"},
+		"no kernel":    {fipsState{OpenSSL: "OpenSSL 3.0.7", Enforced: true}, "the kernel of the node doesn't run in FIPS mode, enable it e.g: with 'fips-mode-setup --enable' and reboot the node"},
+		"not enforced": {fipsState{Kernel: true, OpenSSL: "OpenSSL 3.0.7"}, "OpenSSL 3.0.7 of the node isn't in FIPS mode, it accepts the algorithms FIPS doesn't approve"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.problem, fipsProblem(tc.state))
+		})
+	}
+}
+
+func TestFIPSChecks(t *testing.T) {
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			return "1\nOpenSSL 3.0.7 1 Nov 2022\nallowed\n", nil
+		},
+	}
+	defer func() { fips.Enabled = fips.BuiltIn() }()
+
+	fips.Enabled = false
+	assert.Empty(t, fipsChecks(exec))
+
+	fips.Enabled = true
+	checks := fipsChecks(exec)
+	if assert.Len(t, checks, 1) {
+		assert.False(t, checks[0].Result)
+		assert.True(t, checks[0].Mandatory)
+	}
+}
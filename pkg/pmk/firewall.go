@@ -0,0 +1,248 @@
+package pmk
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Firewalls the rules can be applied with
+const (
+	FirewallUfw       = "ufw"
+	FirewallFirewalld = "firewalld"
+	FirewallIptables  = "iptables"
+)
+
+// Firewalls are the supported firewalls
+var Firewalls = []string{FirewallUfw, FirewallFirewalld, FirewallIptables}
+
+// FirewallRule allows incoming traffic to a node
+type FirewallRule struct {
+	// Protocol is tcp, udp or the number of another IP protocol, empty for
+	// any protocol
+	Protocol string
+	// Ports is a port or a first:last range, empty for any port
+	Ports string
+	// Source is the CIDR the traffic comes from, empty for anywhere
+	Source      string
+	Description string
+}
+
+// FirewallCIDRs are the networks of the cluster the rules are templated with
+type FirewallCIDRs struct {
+	// Nodes is the network of the nodes, empty allows the ports used between
+	// the nodes from anywhere
+	Nodes      string
+	Containers string
+	Services   string
+}
+
+// firewallComment tags the rules so they can be told apart from the others
+const firewallComment = "pf9"
+
+// protocolIPIP is the IP protocol of the IP-in-IP encapsulation of Calico
+const protocolIPIP = "4"
+
+// ufwBeforeRules holds the rules ufw has no command for, like the ones of
+// other protocols than tcp and udp
+const ufwBeforeRules = "/etc/ufw/before.rules"
+
+// FirewallRules returns the rules PMK needs on a node of role, the rules of
+// both roles when role is empty
+func FirewallRules(role string, cidrs FirewallCIDRs) ([]FirewallRule, error) {
+	if err := util.ValidateNodeRole(role); err != nil {
+		return nil, err
+	}
+	for name, cidr := range map[string]string{"node": cidrs.Nodes, "containers": cidrs.Containers, "services": cidrs.Services} {
+		if cidr == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("invalid %s CIDR %s", name, cidr)
+		}
+	}
+
+	rules := []FirewallRule{
+		{"tcp", "22", "", "SSH"},
+		{"tcp", "10250", cidrs.Nodes, "kubelet API"},
+		{"tcp", "179", cidrs.Nodes, "Calico BGP"},
+		{"udp", "4789", cidrs.Nodes, "Calico and Flannel VXLAN"},
+		{protocolIPIP, "", cidrs.Nodes, "Calico IP-in-IP"},
+		{"udp", "8285", cidrs.Nodes, "Flannel UDP backend"},
+		{"tcp", "30000:32767", "", "NodePort services"},
+	}
+	if role != util.RoleWorker {
+		rules = append(rules,
+			FirewallRule{"tcp", "443", "", "Kubernetes API server"},
+			FirewallRule{"tcp", "2379:2380", cidrs.Nodes, "etcd"},
+		)
+	}
+	if cidrs.Containers != "" {
+		rules = append(rules, FirewallRule{"", "", cidrs.Containers, "pod network"})
+	}
+	if cidrs.Services != "" {
+		rules = append(rules, FirewallRule{"", "", cidrs.Services, "service network"})
+	}
+	return rules, nil
+}
+
+// ValidateFirewall checks firewall is one of Firewalls, empty is detected on the node
+func ValidateFirewall(firewall string) error {
+	if firewall == "" {
+		return nil
+	}
+	for _, f := range Firewalls {
+		if f == firewall {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid firewall %s, it should be one of %s", firewall, strings.Join(Firewalls, ", "))
+}
+
+// DetectFirewall returns the firewall managing the rules of the node, the
+// running firewalld or ufw and iptables otherwise
+func DetectFirewall(exec cmdexec.Executor) string {
+	if _, err := exec.RunArgs("systemctl", "is-active", "--quiet", "firewalld"); err == nil {
+		return FirewallFirewalld
+	}
+	if out, err := exec.RunArgs("ufw", "status"); err == nil && strings.Contains(out, "Status: active") {
+		return FirewallUfw
+	}
+	return FirewallIptables
+}
+
+// ApplyFirewallRules adds the rules to the firewall of the node, firewall is
+// detected when empty. Existing rules are kept, adding a rule twice is a no-op.
+func ApplyFirewallRules(exec cmdexec.Executor, firewall string, rules []FirewallRule) (string, error) {
+	if firewall == "" {
+		firewall = DetectFirewall(exec)
+	}
+	script, err := FirewallScript(firewall, rules)
+	if err != nil {
+		return firewall, err
+	}
+	zap.S().Debugf("Applying %d firewall rules with %s", len(rules), firewall)
+	if _, err := exec.RunWithStdout("bash", "-c", script); err != nil {
+		return firewall, fmt.Errorf("unable to apply the firewall rules with %s: %w", firewall, err)
+	}
+	return firewall, nil
+}
+
+// FirewallScript returns the shell script adding the rules to firewall
+func FirewallScript(firewall string, rules []FirewallRule) (string, error) {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	switch firewall {
+	case FirewallUfw:
+		reload := false
+		for _, r := range rules {
+			if r.Protocol != "" && r.Protocol != "tcp" && r.Protocol != "udp" {
+				// The rule is added to the filter table of before.rules
+				rule := "-A ufw-before-input -p " + r.Protocol
+				if r.Source != "" {
+					rule += " -s " + r.Source
+				}
+				rule += " -j ACCEPT"
+				fmt.Fprintf(&b, "grep -qxF -- '%[1]s' %[2]s || sed -i '0,/^COMMIT/s|^COMMIT|%[1]s\\nCOMMIT|' %[2]s\n", rule, ufwBeforeRules)
+				reload = true
+				continue
+			}
+			b.WriteString(ufwRule(r) + "\n")
+		}
+		if reload {
+			b.WriteString("ufw reload\n")
+		}
+	case FirewallFirewalld:
+		for _, r := range rules {
+			b.WriteString(firewalldRule(r) + "\n")
+		}
+		b.WriteString("firewall-cmd --reload\n")
+	case FirewallIptables:
+		for _, r := range rules {
+			cmd := "iptables"
+			if ipv6CIDR(r.Source) {
+				cmd = "ip6tables"
+			}
+			fmt.Fprintf(&b, "%[1]s -C INPUT %[2]s 2> /dev/null || %[1]s -I INPUT %[2]s\n", cmd, iptablesRule(r))
+		}
+		// iptables rules are lost on reboot unless the distribution saves them,
+		// netfilter-persistent saves the IPv4 and IPv6 rules
+		b.WriteString("if command -v netfilter-persistent > /dev/null 2>&1; then netfilter-persistent save; " +
+			"elif [ -f /etc/sysconfig/iptables ]; then iptables-save > /etc/sysconfig/iptables; " +
+			"if command -v ip6tables-save > /dev/null 2>&1; then ip6tables-save > /etc/sysconfig/ip6tables; fi; fi\n")
+	default:
+		return "", ValidateFirewall(firewall)
+	}
+	return b.String(), nil
+}
+
+func ufwRule(r FirewallRule) string {
+	args := []string{"ufw", "allow"}
+	if r.Protocol != "" {
+		args = append(args, "proto", r.Protocol)
+	}
+	args = append(args, "from", orAny(r.Source, "any"))
+	if r.Ports != "" {
+		args = append(args, "to", "any", "port", r.Ports)
+	}
+	args = append(args, "comment", cmdexec.ShellQuote(firewallComment+" "+r.Description))
+	return strings.Join(args, " ")
+}
+
+func firewalldRule(r FirewallRule) string {
+	ports := strings.Replace(r.Ports, ":", "-", 1)
+	if r.Source == "" && r.Ports == "" {
+		return fmt.Sprintf("firewall-cmd --permanent --add-protocol=%s", r.Protocol)
+	}
+	if r.Source == "" {
+		return fmt.Sprintf("firewall-cmd --permanent --add-port=%s/%s", ports, r.Protocol)
+	}
+	family := "ipv4"
+	if ipv6CIDR(r.Source) {
+		family = "ipv6"
+	}
+	rule := fmt.Sprintf("rule family=%s source address=%s", family, r.Source)
+	if r.Ports != "" {
+		rule += fmt.Sprintf(" port port=%s protocol=%s", ports, r.Protocol)
+	} else if r.Protocol != "" {
+		rule += fmt.Sprintf(" protocol value=%s", r.Protocol)
+	}
+	return fmt.Sprintf("firewall-cmd --permanent --add-rich-rule=%s", cmdexec.ShellQuote(rule+" accept"))
+}
+
+func iptablesRule(r FirewallRule) string {
+	var args []string
+	if r.Protocol != "" {
+		args = append(args, "-p", r.Protocol)
+	}
+	if r.Source != "" {
+		args = append(args, "-s", r.Source)
+	}
+	if r.Ports != "" {
+		args = append(args, "--dport", r.Ports)
+	}
+	args = append(args, "-m", "comment", "--comment", cmdexec.ShellQuote(firewallComment+" "+r.Description), "-j", "ACCEPT")
+	return strings.Join(args, " ")
+}
+
+// String returns the rule as printed by --print-rules
+func (r FirewallRule) String() string {
+	return fmt.Sprintf("%-5s %-12s %-18s %s", orAny(r.Protocol, "all"), orAny(r.Ports, "all"), orAny(r.Source, "anywhere"), r.Description)
+}
+
+func orAny(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// ipv6CIDR reports whether cidr is an IPv6 network
+func ipv6CIDR(cidr string) bool {
+	ip, _, err := net.ParseCIDR(cidr)
+	return err == nil && ip.To4() == nil
+}
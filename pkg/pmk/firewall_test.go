@@ -0,0 +1,154 @@
+package pmk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirewallRules(t *testing.T) {
+	cidrs := FirewallCIDRs{Nodes: "10.0.0.0/24", Containers: "10.20.0.0/16", Services: "10.21.0.0/16"}
+	cases := map[string]struct {
+		role    string
+		cidrs   FirewallCIDRs
+		want    []FirewallRule
+		notWant []FirewallRule
+		err     string
+	}{
+		"Master": {
+			role:  util.RoleMaster,
+			cidrs: cidrs,
+			want: []FirewallRule{
+				{"tcp", "443", "", "Kubernetes API server"},
+				{"tcp", "2379:2380", "10.0.0.0/24", "etcd"},
+				{"", "", "10.20.0.0/16", "pod network"},
+			},
+		},
+		"Worker": {
+			role:    util.RoleWorker,
+			cidrs:   cidrs,
+			want:    []FirewallRule{{"tcp", "10250", "10.0.0.0/24", "kubelet API"}, {"4", "", "10.0.0.0/24", "Calico IP-in-IP"}},
+			notWant: []FirewallRule{{"tcp", "2379:2380", "10.0.0.0/24", "etcd"}},
+		},
+		"NoNodeCIDR": {
+			want: []FirewallRule{{"tcp", "10250", "", "kubelet API"}, {"tcp", "443", "", "Kubernetes API server"}},
+		},
+		"InvalidRole":  {role: "etcd", err: "invalid role etcd, it should be master or worker"},
+		"InvalidCIDRs": {cidrs: FirewallCIDRs{Nodes: "10.0.0.0"}, err: "invalid node CIDR 10.0.0.0"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rules, err := FirewallRules(tc.role, tc.cidrs)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			for _, rule := range tc.want {
+				assert.Contains(t, rules, rule)
+			}
+			for _, rule := range tc.notWant {
+				assert.NotContains(t, rules, rule)
+			}
+		})
+	}
+}
+
+func TestFirewallScript(t *testing.T) {
+	rules := []FirewallRule{
+		{"tcp", "30000:32767", "", "NodePort services"},
+		{"tcp", "10250", "10.0.0.0/24", "kubelet API"},
+		{"", "", "fd00::/64", "pod network"},
+		{"4", "", "10.0.0.0/24", "Calico IP-in-IP"},
+	}
+	cases := map[string]struct {
+		firewall string
+		want     []string
+		err      string
+	}{
+		"Ufw": {
+			firewall: FirewallUfw,
+			want: []string{
+				"ufw allow proto tcp from any to any port 30000:32767 comment 'pf9 NodePort services'",
+				"ufw allow proto tcp from 10.0.0.0/24 to any port 10250 comment 'pf9 kubelet API'",
+				"ufw allow from fd00::/64 comment 'pf9 pod network'",
+				"grep -qxF -- '-A ufw-before-input -p 4 -s 10.0.0.0/24 -j ACCEPT' /etc/ufw/before.rules || " +
+					"sed -i '0,/^COMMIT/s|^COMMIT|-A ufw-before-input -p 4 -s 10.0.0.0/24 -j ACCEPT\\nCOMMIT|' /etc/ufw/before.rules",
+				"ufw reload",
+			},
+		},
+		"Firewalld": {
+			firewall: FirewallFirewalld,
+			want: []string{
+				"firewall-cmd --permanent --add-port=30000-32767/tcp",
+				"--add-rich-rule='rule family=ipv4 source address=10.0.0.0/24 port port=10250 protocol=tcp accept'",
+				"--add-rich-rule='rule family=ipv6 source address=fd00::/64 accept'",
+				"--add-rich-rule='rule family=ipv4 source address=10.0.0.0/24 protocol value=4 accept'",
+				"firewall-cmd --reload",
+			},
+		},
+		"Iptables": {
+			firewall: FirewallIptables,
+			want: []string{
+				"iptables -C INPUT -p tcp --dport 30000:32767 -m comment --comment 'pf9 NodePort services' -j ACCEPT 2> /dev/null || iptables -I INPUT",
+				"iptables -I INPUT -p tcp -s 10.0.0.0/24 --dport 10250",
+				"ip6tables -I INPUT -s fd00::/64 -m comment --comment 'pf9 pod network' -j ACCEPT",
+				"iptables -I INPUT -p 4 -s 10.0.0.0/24 -m comment --comment 'pf9 Calico IP-in-IP' -j ACCEPT",
+				"netfilter-persistent save",
+				"ip6tables-save > /etc/sysconfig/ip6tables",
+			},
+		},
+		"Invalid": {firewall: "nftables", err: "invalid firewall nftables, it should be one of ufw, firewalld, iptables"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			script, err := FirewallScript(tc.firewall, rules)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			for _, s := range tc.want {
+				assert.Contains(t, script, s)
+			}
+			// The remote executor passes the script to bash in double quotes
+			assert.NotContains(t, script, "\"")
+			assert.NotContains(t, script, "$")
+		})
+	}
+}
+
+func TestDetectFirewall(t *testing.T) {
+	cases := map[string]struct {
+		firewalld bool
+		ufw       string
+		want      string
+	}{
+		"Firewalld": {firewalld: true, want: FirewallFirewalld},
+		"Ufw":       {ufw: "Status: active\n", want: FirewallUfw},
+		"UfwOff":    {ufw: "Status: inactive\n", want: FirewallIptables},
+		"None":      {want: FirewallIptables},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunArgs: func(name string, args ...string) (string, error) {
+					switch {
+					case name == "systemctl" && tc.firewalld:
+						return "", nil
+					case name == "ufw" && tc.ufw != "":
+						return tc.ufw, nil
+					}
+					return "", errors.New("exit status 1")
+				},
+			}
+			assert.Equal(t, tc.want, DetectFirewall(exec))
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package pmk
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// hardeningScript prints the settings of the node hardening benchmarks like
+// STIG and CIS change and PMK depends on, a section each
+const hardeningScript = `echo '## tmp'
+findmnt -no OPTIONS --target /tmp 2> /dev/null
+echo '## sudoers'
+cat /etc/sudoers 2> /dev/null
+for f in /etc/sudoers.d/*; do [ -f "$f" ] && cat "$f"; done
+echo '## ip_forward'
+sysctl -n net.ipv4.ip_forward 2> /dev/null
+echo '## ip_forward files'
+grep -lsE '^[[:space:]]*net[./]ipv4[./]ip_forward[[:space:]]*=[[:space:]]*0' /etc/sysctl.conf /etc/sysctl.d/*.conf /run/sysctl.d/*.conf /usr/lib/sysctl.d/*.conf
+echo '## auditd'
+systemctl is-active auditd 2> /dev/null
+echo '## auditd.conf'
+grep -iE '^[[:space:]]*(disk_full_action|disk_error_action|space_left_action|admin_space_left_action)[[:space:]]*=' /etc/audit/auditd.conf 2> /dev/null
+echo '## audit rules'
+auditctl -l 2> /dev/null
+true`
+
+// parseSections splits the output of hardeningScript by section
+func parseSections(out string) map[string][]string {
+	sections := make(map[string][]string)
+	section := ""
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			section = strings.TrimPrefix(line, "## ")
+			sections[section] = []string{}
+			continue
+		}
+		if line = strings.TrimSpace(line); line != "" && section != "" {
+			sections[section] = append(sections[section], line)
+		}
+	}
+	return sections
+}
+
+// hardeningRule is a setting of the hardening benchmarks which breaks PMK
+type hardeningRule struct {
+	id string
+	// name is the name of the check of the rule
+	name      string
+	mandatory bool
+	// conflicts returns the conflicts of the node with the rule, each naming
+	// the rule and the exception PMK needs
+	conflicts func(sections map[string][]string) []string
+}
+
+var hardeningRules = []hardeningRule{
+	{platform.CheckIDNoexecTmp, "Noexec /tmp check", true, tmpConflicts},
+	{platform.CheckIDSudoers, "Sudoers check", true, sudoersConflicts},
+	{platform.CheckIDIPForwarding, "IP forwarding check", true, ipForwardConflicts},
+	{platform.CheckIDAuditd, "Auditd check", false, auditdConflicts},
+}
+
+// enabledHardeningRules are the rules whose checks run
+func enabledHardeningRules() []hardeningRule {
+	var rules []hardeningRule
+	for _, rule := range hardeningRules {
+		if platform.Enabled(rule.id) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func tmpConflicts(sections map[string][]string) []string {
+	for _, options := range sections["tmp"] {
+		for _, option := range strings.Split(options, ",") {
+			if option == "noexec" {
+				return []string{`/tmp is mounted noexec (CIS "Ensure noexec option set on /tmp partition"), the installers of the pf9 packages run their scripts from it. ` +
+					`Except the node from the rule, or remount /tmp with 'mount -o remount,exec /tmp' while the node is prepared`}
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	sudoersIncludeDir = regexp.MustCompile(`^[#@]includedir\s+/etc/sudoers\.d/?$`)
+	// sudoersRequireTTY matches the Defaults requiring a tty for every user
+	// or for pf9, the user of the pf9 services
+	sudoersRequireTTY = regexp.MustCompile(`^Defaults(:pf9)?\s+(.*,\s*)?requiretty(\s*,.*)?$`)
+	sudoersNoTTY      = regexp.MustCompile(`^Defaults:pf9\s+(.*,\s*)?!requiretty(\s*,.*)?$`)
+)
+
+func sudoersConflicts(sections map[string][]string) []string {
+	lines := sections["sudoers"]
+	if len(lines) == 0 {
+		return nil
+	}
+	var includeDir, requireTTY, noTTY bool
+	for _, line := range lines {
+		includeDir = includeDir || sudoersIncludeDir.MatchString(line)
+		requireTTY = requireTTY || sudoersRequireTTY.MatchString(line)
+		noTTY = noTTY || sudoersNoTTY.MatchString(line)
+	}
+	var conflicts []string
+	if !includeDir {
+		conflicts = append(conflicts, "/etc/sudoers doesn't include /etc/sudoers.d, so the sudo rules the pf9 packages install for the pf9 user are ignored. "+
+			"Add '#includedir /etc/sudoers.d' to /etc/sudoers")
+	}
+	if requireTTY && !noTTY {
+		conflicts = append(conflicts, "sudo requires a tty with 'Defaults requiretty', the pf9 services run sudo without one. "+
+			"Except the pf9 user with 'Defaults:pf9 !requiretty' in /etc/sudoers.d")
+	}
+	return conflicts
+}
+
+func ipForwardConflicts(sections map[string][]string) []string {
+	// The files disabling it may be overridden by a later file, only the
+	// effective setting is checked
+	value := sections["ip_forward"]
+	if len(value) == 0 || value[0] != "0" {
+		return nil
+	}
+	files := sections["ip_forward files"]
+	if len(files) == 0 {
+		return []string{`IP forwarding is disabled (CIS "Ensure IP forwarding is disabled"), which stops the pods from reaching the other nodes. ` +
+			"Except the node from the rule and set net.ipv4.ip_forward = 1 in /etc/sysctl.d"}
+	}
+	return []string{fmt.Sprintf(`IP forwarding is disabled in %s (CIS "Ensure IP forwarding is disabled"), which stops the pods from reaching the other nodes. `+
+		"Except the node from the rule and set net.ipv4.ip_forward = 1 there", strings.Join(files, ", "))}
+}
+
+// auditExecve matches the audit rules recording the execve calls
+var auditExecve = regexp.MustCompile(`-S\s+(\S+,)?execve\b`)
+
+func auditdConflicts(sections map[string][]string) []string {
+	if active := sections["auditd"]; len(active) == 0 || active[0] != "active" {
+		return nil
+	}
+	var conflicts []string
+	for _, line := range sections["auditd.conf"] {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.EqualFold(strings.TrimSpace(parts[1]), "halt") {
+			conflicts = append(conflicts, fmt.Sprintf("auditd halts the node on %s (STIG audit storage rules), which the audit records of the containers make likely. "+
+				"Set %s to SUSPEND or SYSLOG on the node, or size the audit partition for the containers", strings.TrimSpace(parts[0]), strings.TrimSpace(parts[0])))
+		}
+	}
+	for _, rule := range sections["audit rules"] {
+		// The rules filtered on the login or effective user skip most of the
+		// processes of the containers
+		if auditExecve.MatchString(rule) && !strings.Contains(rule, "auid") && !strings.Contains(rule, "euid") && !strings.Contains(rule, "-C ") {
+			conflicts = append(conflicts, fmt.Sprintf("the audit rule '%s' records every process the containers start, which slows the node and floods the audit log. "+
+				"Filter it on the login user, e.g: with -F auid>=1000 -F auid!=unset", rule))
+		}
+	}
+	return conflicts
+}
+
+// hardeningChecks returns the check of each hardening rule on sections
+func hardeningChecks(sections map[string][]string) []platform.Check {
+	var checks []platform.Check
+	for _, rule := range enabledHardeningRules() {
+		check := platform.Check{ID: rule.id, Name: rule.name, Mandatory: rule.mandatory, Result: true}
+		if conflicts := rule.conflicts(sections); len(conflicts) > 0 {
+			check.Result = false
+			check.UserErr = strings.Join(conflicts, "; ")
+			check.Err = fmt.Errorf("%s", check.UserErr)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkHardening checks the node of exec for the settings of the hardening
+// benchmarks like STIG and CIS which break PMK, reporting the rule each
+// conflicting setting comes from and the exception PMK needs
+func checkHardening(exec cmdexec.Executor) []platform.Check {
+	if len(enabledHardeningRules()) == 0 {
+		return nil
+	}
+	out, err := exec.RunArgs("bash", "-c", hardeningScript)
+	if err != nil {
+		return []platform.Check{{Name: "Hardening check", Mandatory: false, Result: false, Err: err, UserErr: "unable to read the hardening settings of the node"}}
+	}
+	sections := parseSections(out)
+	zap.S().Debugf("Hardening settings of the node: %v", sections)
+	return hardeningChecks(sections)
+}
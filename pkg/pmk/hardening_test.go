@@ -0,0 +1,90 @@
+package pmk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+const hardenedNode = `## tmp
+rw,nosuid,nodev,noexec,relatime
+## sudoers
+Defaults    requiretty
+root    ALL=(ALL)       ALL
+## ip_forward
+0
+## ip_forward files
+/etc/sysctl.d/99-cis.conf
+## auditd
+active
+## auditd.conf
+disk_full_action = HALT
+space_left_action = email
+## audit rules
+-a always,exit -F arch=b64 -S execve -F key=exec
+-a always,exit -F arch=b64 -S execve -C uid!=euid -F euid=0 -F key=setuid
+`
+
+const defaultNode = `## tmp
+rw,relatime
+## sudoers
+Defaults    env_reset
+Defaults:pf9 !requiretty
+Defaults    requiretty
+#includedir /etc/sudoers.d
+## ip_forward
+1
+## ip_forward files
+/usr/lib/sysctl.d/50-default.conf
+## auditd
+inactive
+## auditd.conf
+disk_full_action = HALT
+## audit rules
+`
+
+func TestHardeningChecks(t *testing.T) {
+	checks := hardeningChecks(parseSections(hardenedNode))
+	if assert.Len(t, checks, 4) {
+		assert.Equal(t, "Noexec /tmp check", checks[0].Name)
+		assert.False(t, checks[0].Result)
+		assert.Contains(t, checks[0].UserErr, `CIS "Ensure noexec option set on /tmp partition"`)
+
+		assert.False(t, checks[1].Result)
+		assert.Contains(t, checks[1].UserErr, "doesn't include /etc/sudoers.d")
+		assert.Contains(t, checks[1].UserErr, "'Defaults:pf9 !requiretty'")
+
+		assert.False(t, checks[2].Result)
+		assert.Contains(t, checks[2].UserErr, "IP forwarding is disabled in /etc/sysctl.d/99-cis.conf")
+
+		assert.False(t, checks[3].Result)
+		assert.False(t, checks[3].Mandatory)
+		assert.Contains(t, checks[3].UserErr, "auditd halts the node on disk_full_action")
+		assert.Contains(t, checks[3].UserErr, "'-a always,exit -F arch=b64 -S execve -F key=exec'")
+		assert.NotContains(t, checks[3].UserErr, "key=setuid")
+	}
+
+	for _, check := range hardeningChecks(parseSections(defaultNode)) {
+		assert.True(t, check.Result, check.Name)
+	}
+
+	conflicts := ipForwardConflicts(parseSections("## ip_forward\n0\n## ip_forward files\n"))
+	if assert.Len(t, conflicts, 1) {
+		assert.Contains(t, conflicts[0], "set net.ipv4.ip_forward = 1 in /etc/sysctl.d")
+	}
+}
+
+func TestCheckHardeningUnreadable(t *testing.T) {
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			return "", errors.New("bash: not found")
+		},
+	}
+	checks := checkHardening(exec)
+	if assert.Len(t, checks, 1) {
+		assert.False(t, checks[0].Result)
+		assert.False(t, checks[0].Mandatory)
+	}
+}
@@ -0,0 +1,79 @@
+package pmk
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// hostagentVersionPattern matches the versions of the hostagent like 5.6.0 or
+// 5.6.0-2471
+var hostagentVersionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*(-[0-9A-Za-z.]+)?$`)
+
+// ValidateHostagentVersion checks the hostagent version the node is pinned to
+func ValidateHostagentVersion(version string) error {
+	if !hostagentVersionPattern.MatchString(version) {
+		return fmt.Errorf("invalid hostagent version %q, it is given as 5.6.0 or 5.6.0-2471", version)
+	}
+	return nil
+}
+
+// installerScriptURL returns the URL of the installer at path of the DU for
+// hostOS, asking for the hostagent version when it is pinned
+func installerScriptURL(regionURL, path, hostOS, version string) string {
+	u := fmt.Sprintf("https://%s/%s/platform9-install-%s.sh", regionURL, path, hostOS)
+	if version != "" {
+		u += "?version=" + url.QueryEscape(version)
+	}
+	return u
+}
+
+// hostagentVersionScript prints the version of the pf9-hostagent package
+const hostagentVersionScript = `if command -v dpkg-query > /dev/null; then
+    dpkg-query -W -f '${Version}' pf9-hostagent
+else
+    rpm -q --qf '%{VERSION}-%{RELEASE}' pf9-hostagent
+fi`
+
+// matchesHostagentVersion reports whether the installed version is the pinned
+// version, which may leave the build out
+func matchesHostagentVersion(installed, pinned string) bool {
+	return installed == pinned || strings.HasPrefix(installed, pinned+"-") || strings.HasPrefix(installed, pinned+".")
+}
+
+// checkInstallerVersion checks the installer at path on the node of exec,
+// downloaded from url, has the pinned hostagent version before it is run. The
+// installers embed the packages of the hostagent, which are named after its
+// version, and the DUs without the version install their latest hostagent.
+func checkInstallerVersion(exec cmdexec.Executor, url, path, pinned string) error {
+	if pinned == "" {
+		return nil
+	}
+	if _, err := exec.RunArgs("grep", "-q", "-F", "--", pinned, path); err != nil {
+		return fmt.Errorf("the installer downloaded from %s doesn't have the pinned hostagent %s, the DU may not support pinning the hostagent version or not have that version", url, pinned)
+	}
+	return nil
+}
+
+// checkHostagentVersion checks the installer of the DU installed the pinned
+// hostagent version on the node of exec. The DUs without version pinning
+// install their latest hostagent.
+func checkHostagentVersion(exec cmdexec.Executor, pinned string) error {
+	if pinned == "" {
+		return nil
+	}
+	out, err := exec.RunArgs("bash", "-c", hostagentVersionScript)
+	if err != nil {
+		return fmt.Errorf("unable to read the version of the installed hostagent: %w", err)
+	}
+	installed := strings.TrimSpace(out)
+	zap.S().Debugf("Hostagent %s installed, %s pinned", installed, pinned)
+	if !matchesHostagentVersion(installed, pinned) {
+		return fmt.Errorf("the DU installed hostagent %s instead of the pinned %s, it may not support pinning the hostagent version or not have that version", installed, pinned)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package pmk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// HostIDConf is the file holding the identity of the host in resmgr
+const HostIDConf = "/etc/pf9/host_id.conf"
+
+// readHostID returns the host ID stored on the node, empty if there is none.
+func readHostID(exec cmdexec.Executor) string {
+	cmd := fmt.Sprintf(`grep ^host_id %s | cut -d = -f2`, HostIDConf)
+	output, err := exec.RunWithStdout("bash", "-c", cmd)
+	if err != nil {
+		zap.S().Debugf("No host ID found on the node: %s", err)
+		return ""
+	}
+	return strings.TrimSpace(output)
+}
+
+// checkHostIDConflict fails if the host ID stored on the node is already
+// registered in resmgr for another host, which happens when the node is cloned
+// from a VM template that was onboarded before.
+func checkHostIDConflict(c client.Client, auth keystone.KeystoneAuth, hostID string) error {
+	host, err := c.Resmgr.GetHostInfo(auth.Token, hostID)
+	if err != nil {
+		zap.S().Debugf("Host ID %s is not registered: %s", hostID, err)
+		return nil
+	}
+
+	hostname, err := c.Executor.RunArgs("hostname")
+	if err != nil {
+		return fmt.Errorf("unable to get hostname: %w", err)
+	}
+	ips, err := c.Executor.RunArgs("hostname", "-I")
+	if err != nil {
+		return fmt.Errorf("unable to get host IPs: %w", err)
+	}
+
+	if isSameHost(host, strings.TrimSpace(hostname), strings.Fields(ips)) {
+		return nil
+	}
+	return fmt.Errorf("host ID %s is already registered to %s %v, the node was likely cloned from it. "+
+		"Use --regenerate-host-id to reset the identity of the node", hostID, host.Info.Hostname, host.Extensions.IPAddress.Data)
+}
+
+// isSameHost reports whether the host registered in resmgr is the node with
+// the given hostname and IPs. Clones usually keep the hostname of the template,
+// so the IPs are compared whenever resmgr knows them.
+func isSameHost(host resmgr.HostInfo, hostname string, ips []string) bool {
+	if len(host.Extensions.IPAddress.Data) > 0 {
+		for _, ip := range ips {
+			if util.ContainsIP(host.Extensions.IPAddress.Data, ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return host.Info.Hostname == hostname
+}
+
+// regenerateHostID removes the host and machine identity of the node so that
+// new ones are generated.
+func regenerateHostID(exec cmdexec.Executor) error {
+	if _, err := exec.RunArgs("rm", "-f", HostIDConf); err != nil {
+		return fmt.Errorf("unable to remove %s: %w", HostIDConf, err)
+	}
+	if _, err := exec.RunArgs("rm", "-f", "/etc/machine-id"); err != nil {
+		return fmt.Errorf("unable to remove machine-id: %w", err)
+	}
+	if _, err := exec.RunArgs("systemd-machine-id-setup"); err != nil {
+		return fmt.Errorf("unable to regenerate machine-id: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package pmk
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSameHost(t *testing.T) {
+	registered := func(hostname string, ips ...string) resmgr.HostInfo {
+		host := resmgr.HostInfo{}
+		host.Info.Hostname = hostname
+		host.Extensions.IPAddress.Data = ips
+		return host
+	}
+
+	cases := map[string]struct {
+		host     resmgr.HostInfo
+		hostname string
+		ips      []string
+		want     bool
+	}{
+		"SameHost":           {host: registered("node1", "10.0.0.1"), hostname: "node1", ips: []string{"10.0.0.1"}, want: true},
+		"AdditionalIP":       {host: registered("node1", "10.0.0.1"), hostname: "node1", ips: []string{"172.17.0.1", "10.0.0.1"}, want: true},
+		"ClonedSameHostname": {host: registered("template", "10.0.0.1"), hostname: "template", ips: []string{"10.0.0.2"}, want: false},
+		"NoIPsSameHostname":  {host: registered("node1"), hostname: "node1", ips: []string{"10.0.0.2"}, want: true},
+		"NoIPsOtherHostname": {host: registered("template"), hostname: "node1", ips: []string{"10.0.0.2"}, want: false},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, isSameHost(tc.host, tc.hostname, tc.ips))
+		})
+	}
+}
+
+func TestReadHostID(t *testing.T) {
+	cases := map[string]struct {
+		exec cmdexec.Executor
+		want string
+	}{
+		"HostIDPresent": {
+			exec: &cmdexec.MockExecutor{
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					return "9c3e8a52-1a8e-4b5d-8d6f-6f0ad2b1d7c1\n", nil
+				},
+			},
+			want: "9c3e8a52-1a8e-4b5d-8d6f-6f0ad2b1d7c1",
+		},
+		"NoHostIDConf": {
+			exec: &cmdexec.MockExecutor{
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					return "", fmt.Errorf("exit status 2")
+				},
+			},
+			want: "",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, readHostID(tc.exec))
+		})
+	}
+}
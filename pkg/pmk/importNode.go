@@ -0,0 +1,242 @@
+package pmk
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// ExistingInstall is the Kubernetes installation of a node not managed by
+// Platform9, like a node of a kubeadm cluster
+type ExistingInstall struct {
+	Kubelet        bool
+	KubeletVersion string
+	KubeletActive  bool
+	Kubeadm        bool
+	// ControlPlane is set when the node runs the static pods of a control plane
+	ControlPlane   bool
+	Runtime        string
+	RuntimeVersion string
+}
+
+// minRuntimeVersions are the oldest container runtimes kept when a node is
+// adopted, older ones have to be torn down
+var minRuntimeVersions = map[string][2]int{
+	"docker":     {19, 3},
+	"containerd": {1, 4},
+}
+
+// importBackupDir is where the configuration of the adopted installation is kept
+const importBackupDir = "/etc/pf9/import-backup"
+
+// kubePackages are the packages of an unmanaged installation removed on import
+var kubePackages = []string{"kubelet", "kubeadm", "kubectl"}
+
+// runtimePackages are the container runtime packages removed by a teardown
+var runtimePackages = []string{"docker-ce", "docker-ce-cli", "docker.io", "containerd.io", "containerd"}
+
+var versionRegexp = regexp.MustCompile(`v?([0-9]+)\.([0-9]+)\.([0-9]+)`)
+
+// Found reports whether the node has a Kubernetes installation
+func (i ExistingInstall) Found() bool {
+	return i.Kubelet || i.Kubeadm || i.ControlPlane
+}
+
+// DetectExistingInstall looks for an installed kubelet, kubeadm, the static
+// pods of a control plane and the container runtime of the node
+func DetectExistingInstall(exec cmdexec.Executor) (ExistingInstall, error) {
+	var inst ExistingInstall
+	run := func(cmd string) (string, error) {
+		out, err := exec.RunWithStdout("bash", "-c", cmd)
+		return strings.TrimSpace(out), err
+	}
+
+	out, err := run("command -v kubelet || true")
+	if err != nil {
+		return inst, fmt.Errorf("unable to look for the kubelet: %w", err)
+	}
+	if out != "" {
+		inst.Kubelet = true
+		version, _ := run("kubelet --version 2>/dev/null || true")
+		inst.KubeletVersion = parseVersion(version)
+		active, _ := run("systemctl is-active kubelet 2>/dev/null || true")
+		inst.KubeletActive = active == "active"
+	}
+
+	out, _ = run("command -v kubeadm || true; ls /etc/kubernetes/kubelet.conf /etc/kubernetes/admin.conf 2>/dev/null || true")
+	inst.Kubeadm = out != ""
+
+	out, _ = run("ls /etc/kubernetes/manifests 2>/dev/null || true")
+	for _, manifest := range strings.Fields(out) {
+		if manifest == "kube-apiserver.yaml" || manifest == "etcd.yaml" {
+			inst.ControlPlane = true
+		}
+	}
+
+	// docker runs its containers with containerd, so it is checked first
+	if out, _ = run("docker --version 2>/dev/null || true"); out != "" {
+		inst.Runtime, inst.RuntimeVersion = "docker", parseVersion(out)
+	} else if out, _ = run("containerd --version 2>/dev/null || true"); out != "" {
+		inst.Runtime, inst.RuntimeVersion = "containerd", parseVersion(out)
+	}
+	zap.S().Debugf("Existing installation: %+v", inst)
+	return inst, nil
+}
+
+// Validate checks the installation can be imported into PMK, supported are
+// the PMK role versions of the DU. Problems prevent the import, warnings are
+// to be confirmed. Adopting keeps the container runtime so it has to be
+// recent enough, and a control plane node can only be torn down.
+func (i ExistingInstall) Validate(supported []string, teardown bool) (problems, warnings []string) {
+	if i.ControlPlane && !teardown {
+		problems = append(problems, "the node runs a control plane, it can only be imported with --teardown once its workers are migrated, which destroys its cluster")
+	}
+	if !teardown && i.Runtime != "" {
+		oldest := minRuntimeVersions[i.Runtime]
+		if !versionAtLeast(i.RuntimeVersion, oldest[0], oldest[1]) {
+			problems = append(problems, fmt.Sprintf("%s %s is older than %d.%d, the runtime can't be kept, use --teardown to remove it",
+				i.Runtime, orUnknown(i.RuntimeVersion), oldest[0], oldest[1]))
+		}
+	}
+
+	if i.KubeletVersion != "" {
+		newest := ""
+		for _, v := range supported {
+			if newest == "" || compareMinor(v, newest) > 0 {
+				newest = v
+			}
+		}
+		if newest != "" && compareMinor(i.KubeletVersion, newest) > 0 {
+			warnings = append(warnings, fmt.Sprintf("kubelet %s is newer than the newest PMK version %s, workloads using newer APIs may not run once imported",
+				i.KubeletVersion, newest))
+		}
+	}
+	if i.KubeletActive {
+		warnings = append(warnings, "the kubelet is running, drain the node and delete it from its current cluster before importing it")
+	}
+	return problems, warnings
+}
+
+// AdoptExistingInstall retires the unmanaged Kubernetes installation so the
+// node can be prepared for PMK: the kubelet is stopped, the pods are removed,
+// the configuration is moved to importBackupDir and the Kubernetes packages
+// are removed. The container runtime and its images are kept.
+func AdoptExistingInstall(exec cmdexec.Executor, inst ExistingInstall) error {
+	if _, err := exec.RunWithStdout("bash", "-c", adoptScript(inst, time.Now())); err != nil {
+		return fmt.Errorf("unable to adopt the existing installation: %w", err)
+	}
+	return nil
+}
+
+// TeardownExistingInstall removes the unmanaged Kubernetes installation along
+// with its container runtime, its data and the etcd data of a control plane
+func TeardownExistingInstall(exec cmdexec.Executor, inst ExistingInstall) error {
+	if _, err := exec.RunWithStdout("bash", "-c", teardownScript(inst)); err != nil {
+		return fmt.Errorf("unable to tear down the existing installation: %w", err)
+	}
+	return nil
+}
+
+func adoptScript(inst ExistingInstall, now time.Time) string {
+	backup := fmt.Sprintf("%s/%s", importBackupDir, now.UTC().Format("20060102T150405Z"))
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	fmt.Fprintf(&b, "mkdir -p %s\n", backup)
+	b.WriteString(stopKubeletScript)
+	writeRemovePods(&b, inst.Runtime)
+	fmt.Fprintf(&b, "if [ -d /etc/kubernetes ]; then mv /etc/kubernetes %s/; fi\n", backup)
+	fmt.Fprintf(&b, "if [ -d /var/lib/kubelet ]; then mkdir -p %[1]s/kubelet; cp -a /var/lib/kubelet/config.yaml /var/lib/kubelet/kubeadm-flags.env %[1]s/kubelet/ 2>/dev/null || true; fi\n", backup)
+	b.WriteString("grep ' /var/lib/kubelet' /proc/mounts | cut -d ' ' -f 2 | xargs -r umount\n")
+	b.WriteString("rm -rf /var/lib/kubelet /etc/cni/net.d\n")
+	writeRemovePackages(&b, kubePackages)
+	return b.String()
+}
+
+func teardownScript(inst ExistingInstall) string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	b.WriteString("if command -v kubeadm > /dev/null 2>&1; then kubeadm reset -f; fi\n")
+	b.WriteString(stopKubeletScript)
+	writeRemovePods(&b, inst.Runtime)
+	b.WriteString("grep ' /var/lib/kubelet' /proc/mounts | cut -d ' ' -f 2 | xargs -r umount\n")
+	b.WriteString("rm -rf /etc/kubernetes /var/lib/kubelet /var/lib/etcd /etc/cni/net.d\n")
+	for _, svc := range []string{"docker", "containerd"} {
+		fmt.Fprintf(&b, "if systemctl cat %[1]s > /dev/null 2>&1; then systemctl stop %[1]s; systemctl disable %[1]s; fi\n", svc)
+	}
+	writeRemovePackages(&b, append(append([]string{}, kubePackages...), runtimePackages...))
+	b.WriteString("rm -rf /var/lib/docker /var/lib/containerd\n")
+	return b.String()
+}
+
+const stopKubeletScript = "if systemctl cat kubelet > /dev/null 2>&1; then systemctl stop kubelet; systemctl disable kubelet; fi\n"
+
+// writeRemovePods removes the containers of the pods left by the kubelet
+func writeRemovePods(b *strings.Builder, runtime string) {
+	switch runtime {
+	case "docker":
+		b.WriteString("docker ps -aq --filter name=k8s_ | xargs -r docker rm -f\n")
+	case "containerd":
+		b.WriteString("if command -v crictl > /dev/null 2>&1; then crictl rmp -fa || true; fi\n")
+	}
+}
+
+// writeRemovePackages removes the installed packages of pkgs, held packages
+// included as kubeadm installations hold the Kubernetes packages
+func writeRemovePackages(b *strings.Builder, pkgs []string) {
+	for _, pkg := range pkgs {
+		fmt.Fprintf(b, "if command -v dpkg > /dev/null 2>&1; then if dpkg -s %[1]s > /dev/null 2>&1; then apt-get purge -y --allow-change-held-packages %[1]s; fi; ", pkg)
+		fmt.Fprintf(b, "elif rpm -q %[1]s > /dev/null 2>&1; then yum remove -y %[1]s; fi\n", pkg)
+	}
+}
+
+// parseVersion returns the first x.y.z version of out
+func parseVersion(out string) string {
+	m := versionRegexp.FindStringSubmatch(out)
+	if m == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s.%s.%s", m[1], m[2], m[3])
+}
+
+// versionAtLeast reports whether version is major.minor or newer
+func versionAtLeast(version string, major, minor int) bool {
+	m := versionRegexp.FindStringSubmatch(version)
+	if m == nil {
+		return false
+	}
+	maj, _ := strconv.Atoi(m[1])
+	mnr, _ := strconv.Atoi(m[2])
+	return maj > major || (maj == major && mnr >= minor)
+}
+
+// compareMinor compares the major.minor of two versions like 1.21.3 or
+// 1.21.3-pmk.72
+func compareMinor(a, b string) int {
+	ma, mb := versionRegexp.FindStringSubmatch(a), versionRegexp.FindStringSubmatch(b)
+	if ma == nil || mb == nil {
+		return 0
+	}
+	for i := 1; i <= 2; i++ {
+		x, _ := strconv.Atoi(ma[i])
+		y, _ := strconv.Atoi(mb[i])
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
+
+func orUnknown(version string) string {
+	if version == "" {
+		return "of unknown version"
+	}
+	return version
+}
@@ -0,0 +1,131 @@
+package pmk
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectExistingInstall(t *testing.T) {
+	cases := map[string]struct {
+		outputs map[string]string
+		want    ExistingInstall
+	}{
+		"Clean": {
+			outputs: map[string]string{},
+			want:    ExistingInstall{},
+		},
+		"KubeadmWorker": {
+			outputs: map[string]string{
+				"command -v kubelet":      "/usr/bin/kubelet",
+				"kubelet --version":       "Kubernetes v1.21.2",
+				"systemctl is-active":     "active",
+				"command -v kubeadm":      "/usr/bin/kubeadm",
+				"ls /etc/kubernetes/mani": "",
+				"containerd --version":    "containerd containerd.io 1.4.6 d71fcd7d8303cbf684402823e425e9dd2e99285d",
+			},
+			want: ExistingInstall{Kubelet: true, KubeletVersion: "1.21.2", KubeletActive: true, Kubeadm: true,
+				Runtime: "containerd", RuntimeVersion: "1.4.6"},
+		},
+		"KubeadmControlPlane": {
+			outputs: map[string]string{
+				"command -v kubelet":      "/usr/bin/kubelet",
+				"kubelet --version":       "Kubernetes v1.20.4",
+				"systemctl is-active":     "inactive",
+				"command -v kubeadm":      "/etc/kubernetes/admin.conf",
+				"ls /etc/kubernetes/mani": "etcd.yaml\nkube-apiserver.yaml\nkube-scheduler.yaml",
+				"docker --version":        "Docker version 20.10.7, build f0df350",
+			},
+			want: ExistingInstall{Kubelet: true, KubeletVersion: "1.20.4", Kubeadm: true, ControlPlane: true,
+				Runtime: "docker", RuntimeVersion: "20.10.7"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					cmd := args[len(args)-1]
+					for prefix, out := range tc.outputs {
+						if strings.HasPrefix(cmd, prefix) {
+							return out + "\n", nil
+						}
+					}
+					return "", nil
+				},
+			}
+			inst, err := DetectExistingInstall(exec)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, inst)
+			assert.Equal(t, tc.want != ExistingInstall{}, inst.Found())
+		})
+	}
+}
+
+func TestValidateExistingInstall(t *testing.T) {
+	supported := []string{"1.20.11-pmk.1718", "1.21.3-pmk.72"}
+	cases := map[string]struct {
+		inst     ExistingInstall
+		teardown bool
+		problems int
+		warnings int
+	}{
+		"Worker": {
+			inst: ExistingInstall{Kubelet: true, KubeletVersion: "1.21.2", Runtime: "containerd", RuntimeVersion: "1.4.6"},
+		},
+		"RunningKubelet": {
+			inst:     ExistingInstall{Kubelet: true, KubeletVersion: "1.21.2", KubeletActive: true, Runtime: "docker", RuntimeVersion: "20.10.7"},
+			warnings: 1,
+		},
+		"NewerKubelet": {
+			inst:     ExistingInstall{Kubelet: true, KubeletVersion: "1.23.1", Runtime: "containerd", RuntimeVersion: "1.5.9"},
+			warnings: 1,
+		},
+		"OldRuntime": {
+			inst:     ExistingInstall{Kubelet: true, KubeletVersion: "1.20.4", Runtime: "docker", RuntimeVersion: "18.9.7"},
+			problems: 1,
+		},
+		"OldRuntimeTeardown": {
+			inst:     ExistingInstall{Kubelet: true, KubeletVersion: "1.20.4", Runtime: "docker", RuntimeVersion: "18.9.7"},
+			teardown: true,
+		},
+		"ControlPlane": {
+			inst:     ExistingInstall{Kubelet: true, KubeletVersion: "1.21.2", ControlPlane: true, Runtime: "containerd", RuntimeVersion: "1.4.6"},
+			problems: 1,
+		},
+		"ControlPlaneTeardown": {
+			inst:     ExistingInstall{Kubelet: true, KubeletVersion: "1.21.2", ControlPlane: true, Runtime: "containerd", RuntimeVersion: "1.4.6"},
+			teardown: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			problems, warnings := tc.inst.Validate(supported, tc.teardown)
+			assert.Len(t, problems, tc.problems)
+			assert.Len(t, warnings, tc.warnings)
+		})
+	}
+}
+
+func TestImportScripts(t *testing.T) {
+	inst := ExistingInstall{Kubelet: true, Kubeadm: true, Runtime: "docker", RuntimeVersion: "20.10.7"}
+
+	adopt := adoptScript(inst, time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC))
+	assert.Contains(t, adopt, "mv /etc/kubernetes /etc/pf9/import-backup/20210601T100000Z/")
+	assert.Contains(t, adopt, "docker ps -aq --filter name=k8s_")
+	assert.Contains(t, adopt, "apt-get purge -y --allow-change-held-packages kubeadm")
+	// The container runtime and its images are kept
+	assert.NotContains(t, adopt, "/var/lib/docker")
+	assert.NotContains(t, adopt, "docker-ce")
+
+	teardown := teardownScript(inst)
+	assert.Regexp(t, `(?s)kubeadm reset -f.*systemctl stop kubelet.*systemctl stop docker`, teardown)
+	assert.Contains(t, teardown, "yum remove -y containerd.io")
+	assert.Contains(t, teardown, "rm -rf /var/lib/docker /var/lib/containerd")
+
+	// The remote executor passes the scripts to bash in double quotes
+	assert.NotContains(t, adopt+teardown, "\"")
+	assert.NotContains(t, adopt+teardown, "$")
+}
@@ -0,0 +1,134 @@
+package pmk
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+)
+
+// minInstallerSize is the smallest believable installer script. The real
+// installers embed the packages and weigh hundreds of MB, while the pages of
+// proxies and captive portals are a few KB.
+const minInstallerSize = 64 * 1024
+
+// installerHeadSize is how much of the installer is read to check it
+const installerHeadSize = 512
+
+// proxyHeaders are set by intercepting proxies on the responses they generate
+var proxyHeaders = []string{"X-Squid-Error", "Proxy-Authenticate", "X-Bluecoat-Via", "X-Zscaler-Transaction-Id"}
+
+// managedInstallerArgs are the options of the installer pf9ctl sets itself
+var managedInstallerArgs = []string{"--controller", "--username", "--password", "--user-token", "--project-name", "--no-project", "--proxy", "--no-proxy", "--no-ntp", "--skip-os-check"}
+
+// ValidateInstallerArgs checks the extra args of the installer don't set the
+// options pf9ctl sets itself
+func ValidateInstallerArgs(args []string) error {
+	for _, arg := range args {
+		name := strings.SplitN(arg, "=", 2)[0]
+		for _, managed := range managedInstallerArgs {
+			if name == managed {
+				return fmt.Errorf("the installer option %s is set by pf9ctl, use its config or its flags instead of --installer-arg", managed)
+			}
+		}
+	}
+	return nil
+}
+
+// installerArgs returns the extra args of the installer quoted for its
+// command line, with a leading space
+func installerArgs(args []string) string {
+	var b strings.Builder
+	for _, arg := range args {
+		b.WriteString(" " + cmdexec.ShellQuote(arg))
+	}
+	return b.String()
+}
+
+// interceptedError is returned for a response which wasn't sent by the DU
+func interceptedError(url, reason string) error {
+	return fmt.Errorf("the response for %s looks intercepted by an HTTP proxy or a captive portal (%s), "+
+		"check the node can reach the DU or set the proxy with 'pf9ctl config set --proxy-url'", url, reason)
+}
+
+// checkInstallerResponse checks the response of the DU to the probe of the
+// installer before its status is trusted to choose the hostagent type. head
+// is the beginning of the body of a 200 response.
+func checkInstallerResponse(url string, resp *http.Response, head []byte) error {
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		return fmt.Errorf("the HTTP proxy requires authentication to reach %s, set the proxy credentials with 'pf9ctl config set --proxy-url'", url)
+	}
+	// The DU may redirect the download, which is only suspicious when the
+	// response isn't an installer
+	redirect := ""
+	if resp.Request != nil && resp.Request.URL != nil {
+		if requested, err := http.NewRequest("GET", url, nil); err == nil && resp.Request.URL.Host != requested.URL.Host {
+			redirect = resp.Request.URL.Host
+		}
+	}
+	for _, header := range proxyHeaders {
+		if resp.Header.Get(header) != "" {
+			return interceptedError(url, fmt.Sprintf("%s header set", header))
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		if redirect != "" {
+			return interceptedError(url, fmt.Sprintf("status %d after a redirect to %s", resp.StatusCode, redirect))
+		}
+		return nil
+	}
+
+	var err error
+	if contentType := resp.Header.Get("Content-Type"); strings.Contains(strings.ToLower(contentType), "html") {
+		err = interceptedError(url, fmt.Sprintf("content type %s", contentType))
+	} else {
+		err = checkInstallerContent(url, resp.ContentLength, head)
+	}
+	if err != nil && redirect != "" {
+		return interceptedError(url, fmt.Sprintf("redirected to %s", redirect))
+	}
+	return err
+}
+
+// checkInstallerContent checks an installer of size bytes starting with head
+// is a script, size is -1 when unknown
+func checkInstallerContent(url string, size int64, head []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(head), []byte("<")) {
+		return interceptedError(url, "HTML page instead of the installer script")
+	}
+	if !bytes.HasPrefix(head, []byte("#!")) {
+		return fmt.Errorf("the installer downloaded from %s is not a script, it may be corrupted by an HTTP proxy", url)
+	}
+	if size >= 0 && size < minInstallerSize {
+		return interceptedError(url, fmt.Sprintf("installer of only %d bytes", size))
+	}
+	return nil
+}
+
+// readInstallerHead reads the beginning of the body of the installer
+func readInstallerHead(body io.Reader) []byte {
+	head := make([]byte, installerHeadSize)
+	n, _ := io.ReadFull(body, head)
+	return head[:n]
+}
+
+// checkInstallerFile checks the installer downloaded to path on the node
+func checkInstallerFile(exec cmdexec.Executor, url, path string) error {
+	out, err := exec.RunArgs("stat", "-c", "%s", path)
+	if err != nil {
+		return fmt.Errorf("unable to find the installer downloaded from %s: %w", url, err)
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return fmt.Errorf("unable to get the size of the installer downloaded from %s: %w", url, err)
+	}
+	head, err := exec.RunArgs("head", "-c", strconv.Itoa(installerHeadSize), path)
+	if err != nil {
+		return fmt.Errorf("unable to read the installer downloaded from %s: %w", url, err)
+	}
+	return checkInstallerContent(url, size, []byte(head))
+}
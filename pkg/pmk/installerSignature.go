@@ -0,0 +1,118 @@
+package pmk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/signature"
+	"go.uber.org/zap"
+)
+
+// gpgvScript returns the script verifying the signature sig of file with the
+// keys of keyring with gpgv. It prints the output of gpgv followed by valid or
+// invalid, or missing when gpgv isn't installed.
+func gpgvScript(keyring, sig, file string) string {
+	return fmt.Sprintf(`gpgv=$(command -v gpgv || command -v gpgv2) || { echo missing; exit 0; }
+if out=$("$gpgv" --keyring %s %s %s 2>&1); then echo "$out"; echo valid; else echo "$out"; echo invalid; fi`,
+		cmdexec.ShellQuote(keyring), cmdexec.ShellQuote(sig), cmdexec.ShellQuote(file))
+}
+
+// parseGPGVOutput returns why the signature checked by gpgvScript is refused,
+// nil when it is valid
+func parseGPGVOutput(installerURL, out string) error {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	result := lines[len(lines)-1]
+	details := strings.TrimSpace(strings.Join(lines[:len(lines)-1], "\n"))
+	switch result {
+	case "valid":
+		zap.S().Debugf("Signature of %s: %s", installerURL, details)
+		return nil
+	case "missing":
+		return fmt.Errorf("gpgv isn't installed on the node to verify the signature of the installer, install gnupg or skip the verification with --skip-signature-verify")
+	case "invalid":
+		return fmt.Errorf("the signature of the installer %s doesn't match the signing keys of Platform9, it may have been tampered with by a mirror or a proxy: %s", installerURL, details)
+	}
+	return fmt.Errorf("unable to verify the signature of the installer %s: unexpected output %q", installerURL, out)
+}
+
+// downloadSignature downloads the detached signature of the installer at
+// installerURL on this machine
+func downloadSignature(installerURL string, insecure bool, headers map[string]string) ([]byte, error) {
+	sigURL := signature.SignatureURL(installerURL)
+	req, err := http.NewRequest("GET", sigURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create a http request: %w", err)
+	}
+	req.Header.Set(log.CorrelationIDHeader, log.CorrelationID)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := duClient(insecure).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download the signature of the installer from %s: %w", sigURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download the signature of the installer from %s, code: %d. "+
+			"The DU may not sign its installers, skip the verification with --skip-signature-verify", sigURL, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// copyToNode writes data to dest on the node of exec
+func copyToNode(exec cmdexec.Executor, data []byte, dest string) error {
+	local, err := ioutil.TempFile("", "pf9-signature-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(local.Name())
+	_, err = local.Write(data)
+	if closeErr := local.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return cmdexec.CopyFile(exec, local.Name(), dest, 0644)
+}
+
+// verifyInstallerSignature verifies the GPG signature of the installer file
+// of the node of exec, downloaded from installerURL, against the signing keys
+// of Platform9. The signature is downloaded by this machine with headers and
+// checked with gpgv on the node, so the installer isn't copied back.
+func verifyInstallerSignature(exec cmdexec.Executor, installerURL string, insecure bool, headers map[string]string, file string) error {
+	zap.S().Debugf("Verifying the signature of the installer %s", installerURL)
+	keys, err := signature.Keyring()
+	if err != nil {
+		return err
+	}
+	keyring, err := signature.SerializeKeyring(keys)
+	if err != nil {
+		return fmt.Errorf("unable to write the signing keys: %w", err)
+	}
+	sig, err := downloadSignature(installerURL, insecure, headers)
+	if err != nil {
+		return err
+	}
+
+	sigFile := file + ".asc"
+	keyringFile := path.Join(path.Dir(file), "pf9-signing-keys.gpg")
+	defer exec.RunArgs("rm", "-f", sigFile, keyringFile)
+	if err := copyToNode(exec, sig, sigFile); err != nil {
+		return fmt.Errorf("unable to copy the signature of the installer to the node: %w", err)
+	}
+	if err := copyToNode(exec, keyring, keyringFile); err != nil {
+		return fmt.Errorf("unable to copy the signing keys to the node: %w", err)
+	}
+	out, err := exec.RunArgs("bash", "-c", gpgvScript(keyringFile, sigFile, file))
+	if err != nil {
+		return fmt.Errorf("unable to verify the signature of the installer %s: %w", installerURL, err)
+	}
+	return parseGPGVOutput(installerURL, out)
+}
@@ -0,0 +1,97 @@
+package pmk
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/signature"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestParseGPGVOutput(t *testing.T) {
+	url := "https://du.platform9.net/clarity/platform9-install-debian.sh"
+	assert.NoError(t, parseGPGVOutput(url, "gpgv: Good signature from \"Platform9\"\nvalid\n"))
+	assert.EqualError(t, parseGPGVOutput(url, "gpgv: BAD signature from \"Platform9\"\ninvalid\n"),
+		"the signature of the installer "+url+" doesn't match the signing keys of Platform9, it may have been tampered with by a mirror or a proxy: gpgv: BAD signature from \"Platform9\"")
+	assert.EqualError(t, parseGPGVOutput(url, "missing\n"),
+		"gpgv isn't installed on the node to verify the signature of the installer, install gnupg or skip the verification with --skip-signature-verify")
+	assert.Error(t, parseGPGVOutput(url, ""))
+}
+
+func TestVerifyInstallerSignature(t *testing.T) {
+	key, err := openpgp.NewEntity("Platform9", "", "release@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var armored bytes.Buffer
+	w, _ := armor.Encode(&armored, openpgp.PublicKeyType, nil)
+	key.Serialize(w)
+	w.Close()
+	bundled := signature.BundledKeys
+	defer func() { signature.BundledKeys = bundled }()
+	signature.BundledKeys = base64.StdEncoding.EncodeToString(armored.Bytes())
+	file := signature.KeyringFile
+	defer func() { signature.KeyringFile = file }()
+	signature.KeyringFile = "/nonexistent/signing-keys.asc"
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/clarity/platform9-install-debian.sh.asc" || r.Header.Get("X-Auth-Token") != "token" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "-----BEGIN PGP SIGNATURE-----")
+	}))
+	defer server.Close()
+
+	tcs := map[string]struct {
+		path string
+		gpgv string
+		err  string
+	}{
+		"valid": {
+			path: "/clarity/platform9-install-debian.sh",
+			gpgv: "gpgv: Good signature\nvalid\n",
+		},
+		"tampered": {
+			path: "/clarity/platform9-install-debian.sh",
+			gpgv: "gpgv: BAD signature\ninvalid\n",
+			err:  "doesn't match the signing keys of Platform9",
+		},
+		"unsigned": {
+			path: "/clarity/platform9-install-redhat.sh",
+			err:  "The DU may not sign its installers, skip the verification with --skip-signature-verify",
+		},
+	}
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var ran []string
+			exec := &cmdexec.MockExecutor{
+				MockRunArgs: func(name string, args ...string) (string, error) {
+					ran = append(ran, name+" "+args[0])
+					if name == "bash" {
+						return tc.gpgv, nil
+					}
+					return "", nil
+				},
+			}
+			err := verifyInstallerSignature(exec, server.URL+tc.path, true, map[string]string{"X-Auth-Token": "token"}, "/root/pf9/installer.sh")
+			if tc.err != "" {
+				assert.Error(t, err)
+				assert.True(t, strings.Contains(err.Error(), tc.err), err.Error())
+				return
+			}
+			assert.NoError(t, err)
+			// The signature and the keys are copied to the node, checked
+			// there and removed
+			assert.Equal(t, []string{"install -m", "install -m", "bash -c", "rm -f"}, ran)
+		})
+	}
+}
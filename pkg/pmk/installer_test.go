@@ -0,0 +1,159 @@
+package pmk
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+const installerURL = "https://du.platform9.net/clarity/platform9-install-debian.sh"
+
+func TestCheckInstallerResponse(t *testing.T) {
+	script := []byte("#!/bin/bash\nset -e\n")
+	cases := map[string]struct {
+		status      int
+		header      http.Header
+		finalHost   string
+		length      int64
+		head        []byte
+		intercepted bool
+		wantErr     bool
+	}{
+		"Certless":      {status: 200, length: 300 << 20, head: script},
+		"UnknownLength": {status: 200, length: -1, head: script},
+		"Legacy":        {status: 404, header: http.Header{"Content-Type": {"text/html"}}},
+		"CDNRedirect":   {status: 200, length: 300 << 20, head: script, finalHost: "cdn.platform9.net"},
+		"CaptivePortal": {status: 200, length: 2048, header: http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+			head: []byte("<!DOCTYPE html><html>"), finalHost: "login.hotel.example", intercepted: true},
+		"HTMLWithoutType": {status: 200, length: -1, head: []byte("\n  <html><body>Access denied</body></html>"), intercepted: true},
+		"SquidError":      {status: 404, header: http.Header{"X-Squid-Error": {"ERR_ACCESS_DENIED 0"}}, intercepted: true},
+		"RedirectedError": {status: 404, finalHost: "proxy.corp.example", intercepted: true},
+		"ProxyAuth":       {status: 407, wantErr: true},
+		"TooSmall":        {status: 200, length: 120, head: script, intercepted: true},
+		"NotAScript":      {status: 200, length: 300 << 20, head: []byte("\x1f\x8b\x08\x00"), wantErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			host := tc.finalHost
+			if host == "" {
+				host = "du.platform9.net"
+			}
+			header := tc.header
+			if header == nil {
+				header = http.Header{"Content-Type": {"application/x-sh"}}
+			}
+			resp := &http.Response{
+				StatusCode:    tc.status,
+				Header:        header,
+				ContentLength: tc.length,
+				Request:       &http.Request{URL: &url.URL{Scheme: "https", Host: host, Path: "/installer.sh"}},
+			}
+			err := checkInstallerResponse(installerURL, resp, tc.head)
+			if tc.intercepted {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "intercepted by an HTTP proxy")
+			} else if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestReadInstallerHead(t *testing.T) {
+	assert.Equal(t, []byte("#!/bin/sh"), readInstallerHead(strings.NewReader("#!/bin/sh")))
+	assert.Len(t, readInstallerHead(bytes.NewReader(make([]byte, 4096))), installerHeadSize)
+}
+
+func TestCheckInstallerFile(t *testing.T) {
+	cases := map[string]struct {
+		size    string
+		head    string
+		wantErr string
+	}{
+		"Installer":    {size: "314572800\n", head: "#!/bin/bash\n"},
+		"PortalPage":   {size: "5120\n", head: "<html><head><title>Login</title>", wantErr: "intercepted by an HTTP proxy"},
+		"Truncated":    {size: "1024\n", head: "#!/bin/bash\n", wantErr: "installer of only 1024 bytes"},
+		"BinaryGarble": {size: "314572800\n", head: "PK\x03\x04", wantErr: "is not a script"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					if name == "stat" {
+						return tc.size, nil
+					}
+					return tc.head, nil
+				},
+			}
+			err := checkInstallerFile(exec, installerURL, "/root/pf9/installer.sh")
+			if tc.wantErr == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestInstallerArgs(t *testing.T) {
+	assert.NoError(t, ValidateInstallerArgs([]string{"--ntp", "--mirror=https://mirror.example.com"}))
+	assert.EqualError(t, ValidateInstallerArgs([]string{"--ntp", "--controller=du.example.com"}),
+		"the installer option --controller is set by pf9ctl, use its config or its flags instead of --installer-arg")
+	assert.Error(t, ValidateInstallerArgs([]string{"--no-ntp"}))
+	assert.Error(t, ValidateInstallerArgs([]string{"--skip-os-check"}))
+	assert.Error(t, ValidateInstallerArgs([]string{"--no-proxy"}))
+
+	assert.Equal(t, "", installerArgs(nil))
+	assert.Equal(t, ` '--ntp' '--label=team a'`, installerArgs([]string{"--ntp", "--label=team a"}))
+}
+
+func TestHostagentVersion(t *testing.T) {
+	assert.NoError(t, ValidateHostagentVersion("5.6.0"))
+	assert.NoError(t, ValidateHostagentVersion("5.6.0-2471"))
+	assert.EqualError(t, ValidateHostagentVersion("latest"), `invalid hostagent version "latest", it is given as 5.6.0 or 5.6.0-2471`)
+
+	assert.Equal(t, "https://du.platform9.net/clarity/platform9-install-debian.sh", installerScriptURL("du.platform9.net", "clarity", "debian", ""))
+	assert.Equal(t, "https://du.platform9.net/private/platform9-install-redhat.sh?version=5.6.0-2471", installerScriptURL("du.platform9.net", "private", "redhat", "5.6.0-2471"))
+
+	var grepped []string
+	inInstaller := true
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			grepped = append(grepped, name+" "+strings.Join(args, " "))
+			if !inInstaller {
+				return "", errors.New("exit status 1")
+			}
+			return "", nil
+		},
+	}
+	assert.NoError(t, checkInstallerVersion(exec, installerURL, "/root/pf9/installer.sh", ""))
+	assert.Empty(t, grepped)
+	assert.NoError(t, checkInstallerVersion(exec, installerURL, "/root/pf9/installer.sh", "5.6.0-2471"))
+	assert.Equal(t, []string{"grep -q -F -- 5.6.0-2471 /root/pf9/installer.sh"}, grepped)
+	inInstaller = false
+	err := checkInstallerVersion(exec, installerURL, "/root/pf9/installer.sh", "5.6.0-2471")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "doesn't have the pinned hostagent 5.6.0-2471")
+
+	installed := "5.6.0-2471"
+	exec = &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			return installed + "\n", nil
+		},
+	}
+	assert.NoError(t, checkHostagentVersion(exec, ""))
+	assert.NoError(t, checkHostagentVersion(exec, "5.6.0"))
+	assert.NoError(t, checkHostagentVersion(exec, "5.6.0-2471"))
+	assert.NoError(t, checkHostagentVersion(exec, "5.6"))
+	installed = "5.7.1-100"
+	assert.EqualError(t, checkHostagentVersion(exec, "5.6.0"), "the DU installed hostagent 5.7.1-100 instead of the pinned 5.6.0, it may not support pinning the hostagent version or not have that version")
+}
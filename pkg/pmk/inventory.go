@@ -0,0 +1,229 @@
+package pmk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/jobs"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+)
+
+// Formats of the inventory
+const (
+	InventoryAnsible = "ansible"
+	InventoryCSV     = "csv"
+	InventoryJSON    = "json"
+)
+
+// InventoryHost is a host of the DU in the inventory
+type InventoryHost struct {
+	Hostname    string `json:"hostname"`
+	IP          string `json:"ip"`
+	HostID      string `json:"hostId"`
+	Role        string `json:"role,omitempty"`
+	Cluster     string `json:"cluster,omitempty"`
+	ClusterUuid string `json:"clusterUuid,omitempty"`
+	OS          string `json:"os,omitempty"`
+	Responding  bool   `json:"responding"`
+	// LastJob and LastJobStatus are the last job of the local state store
+	// which processed the host and the status it recorded
+	LastJob       string `json:"lastJob,omitempty"`
+	LastJobStatus string `json:"lastJobStatus,omitempty"`
+	// SSHFingerprints are the fingerprints the SSH host key of the host is
+	// pinned to, added to the inventory by the operators
+	SSHFingerprints []string `json:"sshFingerprints,omitempty"`
+}
+
+// Inventory returns the hosts of the tenant of the DU at fqdn with their
+// roles and clusters, and the last local job which processed them
+func Inventory(c client.Client, auth keystone.KeystoneAuth, fqdn string) ([]InventoryHost, error) {
+	hosts, err := c.Resmgr.GetHosts(auth.Token)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the hosts: %w", err)
+	}
+	allJobs, err := jobs.List()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the jobs: %w", err)
+	}
+	// The jobs are matched to the hosts by their IDs, the legacy jobs can't
+	// be matched to the hosts of another DU
+	var ownJobs []*jobs.Job
+	for _, job := range allJobs {
+		if job.BelongsTo(fqdn, auth.ProjectID, true) {
+			ownJobs = append(ownJobs, job)
+		}
+	}
+	return buildInventory(hosts, c.Qbert.GetAllNodes(auth.Token, auth.ProjectID), ownJobs), nil
+}
+
+// ReadHostFingerprints reads the fingerprints the SSH host keys are pinned to
+// by IP and hostname from a JSON inventory
+func ReadHostFingerprints(r io.Reader) (map[string][]string, error) {
+	var hosts []InventoryHost
+	if err := json.NewDecoder(r).Decode(&hosts); err != nil {
+		return nil, fmt.Errorf("unable to parse the inventory: %w", err)
+	}
+	fingerprints := make(map[string][]string)
+	for _, h := range hosts {
+		if len(h.SSHFingerprints) == 0 {
+			continue
+		}
+		for _, name := range []string{h.IP, h.Hostname} {
+			if name != "" {
+				fingerprints[name] = append(fingerprints[name], h.SSHFingerprints...)
+			}
+		}
+	}
+	return fingerprints, nil
+}
+
+// buildInventory joins the hosts of resmgr with their qbert nodes and the
+// jobs, which are the most recent first
+func buildInventory(hosts []resmgr.HostInfo, nodes []qbert.Node, allJobs []*jobs.Job) []InventoryHost {
+	qbertNodes := make(map[string]qbert.Node)
+	for _, node := range nodes {
+		qbertNodes[node.Uuid] = node
+	}
+
+	var inventory []InventoryHost
+	for _, host := range hosts {
+		node := qbertNodes[host.ID]
+		entry := InventoryHost{
+			Hostname:    host.Info.Hostname,
+			IP:          node.PrimaryIp,
+			HostID:      host.ID,
+			Cluster:     node.ClusterName,
+			ClusterUuid: node.ClusterUuid,
+			OS:          host.Info.OSInfo,
+			Responding:  host.Info.Responding,
+		}
+		if entry.IP == "" && len(host.Extensions.IPAddress.Data) > 0 {
+			entry.IP = host.Extensions.IPAddress.Data[0]
+		}
+		if node.ClusterUuid != "" {
+			entry.Role = "worker"
+			if node.IsMaster == 1 {
+				entry.Role = "master"
+			}
+		}
+	lastJob:
+		for _, job := range allJobs {
+			for _, jobHost := range job.Hosts {
+				if jobHost.HostID == host.ID {
+					entry.LastJob = fmt.Sprintf("%s %s", job.ID, job.Operation)
+					entry.LastJobStatus = jobHost.Status
+					break lastJob
+				}
+			}
+		}
+		inventory = append(inventory, entry)
+	}
+	sort.Slice(inventory, func(i, j int) bool {
+		if inventory[i].Hostname != inventory[j].Hostname {
+			return inventory[i].Hostname < inventory[j].Hostname
+		}
+		return inventory[i].IP < inventory[j].IP
+	})
+	return inventory
+}
+
+// WriteInventory writes the hosts in format, ansible writes an INI
+// inventory with a group per role and per cluster
+func WriteInventory(w io.Writer, hosts []InventoryHost, format string) error {
+	switch format {
+	case InventoryJSON:
+		if hosts == nil {
+			hosts = []InventoryHost{}
+		}
+		data, err := json.MarshalIndent(hosts, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case InventoryCSV:
+		return writeInventoryCSV(w, hosts)
+	case InventoryAnsible:
+		return writeInventoryAnsible(w, hosts)
+	}
+	return fmt.Errorf("invalid format %q, use %s, %s or %s", format, InventoryAnsible, InventoryCSV, InventoryJSON)
+}
+
+func writeInventoryCSV(w io.Writer, hosts []InventoryHost) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"hostname", "ip", "host_id", "role", "cluster", "cluster_uuid", "os", "responding", "last_job", "last_job_status"})
+	for _, h := range hosts {
+		out.Write([]string{h.Hostname, h.IP, h.HostID, h.Role, h.Cluster, h.ClusterUuid, h.OS,
+			strconv.FormatBool(h.Responding), h.LastJob, h.LastJobStatus})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// ansibleGroupName replaces the characters Ansible doesn't allow in group
+// names
+var ansibleGroupName = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func writeInventoryAnsible(w io.Writer, hosts []InventoryHost) error {
+	groups := map[string][]string{}
+	var order []string
+	add := func(group, line string) {
+		if _, found := groups[group]; !found {
+			order = append(order, group)
+		}
+		groups[group] = append(groups[group], line)
+	}
+
+	for _, h := range hosts {
+		name := h.Hostname
+		if name == "" {
+			name = h.IP
+		}
+		vars := []string{"ansible_host=" + h.IP, "pf9_host_id=" + h.HostID}
+		if h.Cluster != "" {
+			vars = append(vars, "pf9_cluster="+strconv.Quote(h.Cluster), "pf9_role="+h.Role)
+		}
+		line := name + " " + strings.Join(vars, " ")
+		switch h.Role {
+		case "master":
+			add("pf9_masters", line)
+		case "worker":
+			add("pf9_workers", line)
+		default:
+			add("pf9_unattached", line)
+		}
+		if h.Cluster != "" {
+			add("pf9_cluster_"+ansibleGroupName.ReplaceAllString(h.Cluster, "_"), name)
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		// The role groups come first
+		iCluster := strings.HasPrefix(order[i], "pf9_cluster_")
+		jCluster := strings.HasPrefix(order[j], "pf9_cluster_")
+		if iCluster != jCluster {
+			return jCluster
+		}
+		return order[i] < order[j]
+	})
+	for i, group := range order {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "[%s]\n%s\n", group, strings.Join(groups[group], "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package pmk
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/jobs"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func inventoryHost(id, hostname, ip string) resmgr.HostInfo {
+	var host resmgr.HostInfo
+	host.ID = id
+	host.Info.Hostname = hostname
+	host.Info.Responding = true
+	host.Extensions.IPAddress.Data = []string{ip}
+	return host
+}
+
+func TestBuildInventory(t *testing.T) {
+	hosts := []resmgr.HostInfo{
+		inventoryHost("host-2", "worker1", "10.0.0.2"),
+		inventoryHost("host-1", "master1", "10.0.0.1"),
+		inventoryHost("host-3", "spare1", "10.0.0.3"),
+	}
+	nodes := []qbert.Node{
+		{Uuid: "host-1", ClusterUuid: "uuid-a", ClusterName: "prod a", PrimaryIp: "192.168.0.1", IsMaster: 1},
+		{Uuid: "host-2", ClusterUuid: "uuid-a", ClusterName: "prod a", IsMaster: 0},
+	}
+	allJobs := []*jobs.Job{
+		{ID: "bbbb", Operation: jobs.DetachNode, Hosts: []jobs.Host{{HostID: "host-3", Status: jobs.Done}}},
+		{ID: "aaaa", Operation: jobs.AttachNode, Hosts: []jobs.Host{{HostID: "host-3", Status: jobs.Done}, {HostID: "host-2", Status: jobs.Failed}}},
+	}
+
+	inventory := buildInventory(hosts, nodes, allJobs)
+	assert.Equal(t, []InventoryHost{
+		{Hostname: "master1", IP: "192.168.0.1", HostID: "host-1", Role: "master", Cluster: "prod a", ClusterUuid: "uuid-a", Responding: true},
+		{Hostname: "spare1", IP: "10.0.0.3", HostID: "host-3", Responding: true, LastJob: "bbbb detach-node", LastJobStatus: jobs.Done},
+		{Hostname: "worker1", IP: "10.0.0.2", HostID: "host-2", Role: "worker", Cluster: "prod a", ClusterUuid: "uuid-a", Responding: true, LastJob: "aaaa attach-node", LastJobStatus: jobs.Failed},
+	}, inventory)
+
+	var out bytes.Buffer
+	assert.Nil(t, WriteInventory(&out, inventory, InventoryAnsible))
+	assert.Equal(t, `[pf9_masters]
+master1 ansible_host=192.168.0.1 pf9_host_id=host-1 pf9_cluster="prod a" pf9_role=master
+
+[pf9_unattached]
+spare1 ansible_host=10.0.0.3 pf9_host_id=host-3
+
+[pf9_workers]
+worker1 ansible_host=10.0.0.2 pf9_host_id=host-2 pf9_cluster="prod a" pf9_role=worker
+
+[pf9_cluster_prod_a]
+master1
+worker1
+`, out.String())
+
+	out.Reset()
+	assert.Nil(t, WriteInventory(&out, inventory[:1], InventoryCSV))
+	assert.Equal(t, "hostname,ip,host_id,role,cluster,cluster_uuid,os,responding,last_job,last_job_status\n"+
+		"master1,192.168.0.1,host-1,master,prod a,uuid-a,,true,,\n", out.String())
+
+	out.Reset()
+	assert.Nil(t, WriteInventory(&out, nil, InventoryJSON))
+	assert.Equal(t, "[]\n", out.String())
+
+	assert.EqualError(t, WriteInventory(&out, nil, "yaml"), `invalid format "yaml", use ansible, csv or json`)
+}
+
+func TestReadHostFingerprints(t *testing.T) {
+	fingerprints, err := ReadHostFingerprints(strings.NewReader(`[
+  {"hostname": "worker1", "ip": "10.0.0.2", "hostId": "host-2", "sshFingerprints": ["SHA256:abc", "SHA256:def"]},
+  {"hostname": "spare1", "ip": "10.0.0.3", "hostId": "host-3"}
+]`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]string{
+		"10.0.0.2": {"SHA256:abc", "SHA256:def"},
+		"worker1":  {"SHA256:abc", "SHA256:def"},
+	}, fingerprints)
+
+	_, err = ReadHostFingerprints(strings.NewReader("hosts:"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package pmk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/util"
+)
+
+// IPStack is the IP families of the networks of a cluster
+type IPStack struct {
+	IPv4 bool
+	IPv6 bool
+}
+
+// DualStack is true when the cluster has both IPv4 and IPv6 networks
+func (s IPStack) DualStack() bool {
+	return s.IPv4 && s.IPv6
+}
+
+func (s IPStack) String() string {
+	switch {
+	case s.DualStack():
+		return "dual-stack"
+	case s.IPv6:
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+// ClusterIPStack returns the IP families of the cluster of spec, read from
+// its containers and services CIDRs, or from its ipv6 setting when they
+// can't be parsed
+func ClusterIPStack(spec map[string]interface{}) IPStack {
+	cidrs := append(specStrings(spec, "containersCidr"), specStrings(spec, "servicesCidr")...)
+	ipv4, ipv6 := util.IPFamilies(cidrs)
+	if specInt(spec, "ipv6") == 1 {
+		ipv6 = true
+	}
+	if !ipv4 && !ipv6 {
+		ipv4 = true
+	}
+	return IPStack{IPv4: ipv4, IPv6: ipv6}
+}
+
+// CheckHostIPStack checks the addresses resmgr reports for a host cover the
+// IP families of the cluster
+func CheckHostIPStack(hostIPs []string, stack IPStack) error {
+	ipv4, ipv6 := util.IPFamilies(hostIPs)
+	if stack.IPv6 && !ipv6 {
+		return fmt.Errorf("the cluster is %s but the host has no IPv6 address, its addresses are %s", stack, strings.Join(hostIPs, ", "))
+	}
+	if stack.IPv4 && !ipv4 {
+		return fmt.Errorf("the cluster is %s but the host has no IPv4 address, its addresses are %s", stack, strings.Join(hostIPs, ", "))
+	}
+	return nil
+}
+
+// ipStackChecks are the settings of a node an IPv6 or dual-stack cluster
+// needs, with the command failing when the node misses them. The IPv4 ones
+// are only checked for dual-stack clusters.
+var ipStackChecks = []struct {
+	missing string
+	ipv4    bool
+	command string
+}{
+	{"IPv6 is disabled (net.ipv6.conf.all.disable_ipv6)", false, "grep -qx 0 /proc/sys/net/ipv6/conf/all/disable_ipv6"},
+	{"IPv6 forwarding is disabled (net.ipv6.conf.all.forwarding)", false, "grep -qx 1 /proc/sys/net/ipv6/conf/all/forwarding"},
+	{"no global IPv6 address", false, "ip -6 addr show scope global | grep -q inet6"},
+	{"no IPv6 default route", false, "ip -6 route show default | grep -q default"},
+	{"no global IPv4 address", true, "ip -4 addr show scope global | grep -q inet"},
+	{"no IPv4 default route", true, "ip -4 route show default | grep -q default"},
+}
+
+// CheckNodeIPStack checks the node of exec has the settings of an IPv6 or
+// dual-stack cluster, returning those it is missing. An IPv4 cluster has no
+// requirement.
+func CheckNodeIPStack(exec cmdexec.Executor, stack IPStack) []string {
+	if !stack.IPv6 {
+		return nil
+	}
+	var missing []string
+	for _, check := range ipStackChecks {
+		if check.ipv4 && !stack.IPv4 {
+			continue
+		}
+		if _, err := exec.RunArgs("bash", "-c", check.command); err != nil {
+			missing = append(missing, check.missing)
+		}
+	}
+	return missing
+}
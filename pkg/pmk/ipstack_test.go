@@ -0,0 +1,55 @@
+package pmk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterIPStack(t *testing.T) {
+	cases := map[string]struct {
+		spec map[string]interface{}
+		want IPStack
+	}{
+		"IPv4":      {map[string]interface{}{"containersCidr": "10.20.0.0/16", "servicesCidr": "10.21.0.0/16"}, IPStack{IPv4: true}},
+		"IPv6":      {map[string]interface{}{"containersCidr": "fd00:20::/64", "ipv6": true}, IPStack{IPv6: true}},
+		"DualStack": {map[string]interface{}{"containersCidr": "10.20.0.0/16,fd00:20::/64"}, IPStack{IPv4: true, IPv6: true}},
+		"NoCIDRs":   {map[string]interface{}{"ipv6": float64(1)}, IPStack{IPv6: true}},
+		"Empty":     {map[string]interface{}{}, IPStack{IPv4: true}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ClusterIPStack(tc.spec))
+		})
+	}
+}
+
+func TestCheckHostIPStack(t *testing.T) {
+	dualStack := IPStack{IPv4: true, IPv6: true}
+	assert.NoError(t, CheckHostIPStack([]string{"10.0.0.1", "fd00::1"}, dualStack))
+	assert.EqualError(t, CheckHostIPStack([]string{"10.0.0.1"}, dualStack),
+		"the cluster is dual-stack but the host has no IPv6 address, its addresses are 10.0.0.1")
+	assert.EqualError(t, CheckHostIPStack([]string{"fd00::1"}, dualStack),
+		"the cluster is dual-stack but the host has no IPv4 address, its addresses are fd00::1")
+	assert.NoError(t, CheckHostIPStack([]string{"fd00::1"}, IPStack{IPv6: true}))
+}
+
+func TestCheckNodeIPStack(t *testing.T) {
+	// The node has IPv6 without forwarding, and no IPv4 default route
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			script := args[len(args)-1]
+			if strings.Contains(script, "ipv6/conf/all/forwarding") || strings.HasPrefix(script, "ip -4 route") {
+				return "", errors.New("exit status 1")
+			}
+			return "", nil
+		},
+	}
+	assert.Nil(t, CheckNodeIPStack(exec, IPStack{IPv4: true}))
+	assert.Equal(t, []string{"IPv6 forwarding is disabled (net.ipv6.conf.all.forwarding)"}, CheckNodeIPStack(exec, IPStack{IPv6: true}))
+	assert.Equal(t, []string{"IPv6 forwarding is disabled (net.ipv6.conf.all.forwarding)", "no IPv4 default route"},
+		CheckNodeIPStack(exec, IPStack{IPv4: true, IPv6: true}))
+}
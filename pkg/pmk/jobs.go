@@ -0,0 +1,215 @@
+package pmk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/jobs"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/tracing"
+	"github.com/platform9/pf9ctl/pkg/ui"
+	"go.uber.org/zap"
+)
+
+// RunJob runs the operation of the job on the hosts which are not done yet.
+// Hosts already processed by an interrupted run are marked done without
+// running the operation again.
+func RunJob(c client.Client, auth keystone.KeystoneAuth, job *jobs.Job) error {
+	span := tracing.Start(job.Operation, "cluster.name", job.ClusterName, "job.id", job.ID)
+	switch job.Operation {
+	case jobs.AttachNode:
+		runAttachJob(c, auth, job, span)
+	case jobs.DetachNode:
+		runDetachJob(c, auth, job, span)
+	default:
+		err := fmt.Errorf("unknown operation %s of job %s", job.Operation, job.ID)
+		span.End(err)
+		return err
+	}
+
+	if job.Status() != jobs.Done {
+		span.End(fmt.Errorf("failed on %d host(s)", len(job.Remaining())))
+		return fmt.Errorf("job %s failed on %d host(s), check 'pf9ctl jobs status %s' and resume it with 'pf9ctl jobs resume %s'",
+			job.ID, len(job.Remaining()), job.ID, job.ID)
+	}
+	span.End(nil)
+	return nil
+}
+
+// startHostSpans starts a span of name for every host of a batch under span
+func startHostSpans(span *tracing.Span, name string, hosts []jobs.Host) []*tracing.Span {
+	spans := make([]*tracing.Span, len(hosts))
+	for i, host := range hosts {
+		spans[i] = span.Child(name, "host.ip", host.IP, "host.id", host.HostID, "node.role", host.Role)
+	}
+	return spans
+}
+
+// endSpans ends the spans, failed with err when it isn't nil
+func endSpans(spans []*tracing.Span, err error) {
+	for _, span := range spans {
+		span.End(err)
+	}
+}
+
+func runAttachJob(c client.Client, auth keystone.KeystoneAuth, job *jobs.Job, span *tracing.Span) {
+	allNodes := c.Qbert.GetAllNodes(auth.Token, auth.ProjectID)
+
+	hostsByRole := make(map[string][]jobs.Host)
+	// Masters attached by an interrupted run may not be healthy yet
+	attachedMasters := make(map[string]bool)
+	for _, host := range job.Remaining() {
+		if node, found := findNode(allNodes, host.HostID); found && node.ClusterUuid == job.ClusterUuid {
+			log.ForHost(host.IP).Debugf("Node %s is already attached to cluster %s", host.IP, job.ClusterName)
+			if host.Role != "master" {
+				setHostStatus(job, []jobs.Host{host}, jobs.Done, nil)
+				continue
+			}
+			attachedMasters[host.HostID] = true
+		}
+		hostsByRole[host.Role] = append(hostsByRole[host.Role], host)
+	}
+
+	if len(hostsByRole["master"]) > 0 && len(attachedMasters) == 0 {
+		if healthy, err := mastersHealthy(ClusterNodes(allNodes, job.ClusterUuid), ""); err != nil || !healthy {
+			if err == nil {
+				err = fmt.Errorf("masters of the cluster are not healthy")
+			}
+			fmt.Printf("Unable to attach master node(s): %s\n", err)
+			abortMasterAttach(c, auth, job)
+			hostsByRole["master"] = nil
+		}
+	}
+
+	// Workers are attached first as they don't change the etcd membership,
+	// masters are attached one by one so etcd never loses quorum
+	batches := [][]jobs.Host{hostsByRole["worker"]}
+	for _, master := range hostsByRole["master"] {
+		batches = append(batches, []jobs.Host{master})
+	}
+	for _, hosts := range batches {
+		if len(hosts) == 0 {
+			continue
+		}
+		role := hosts[0].Role
+		ips, hostIDs := hostIPs(hosts), jobHostIDs(hosts)
+
+		setHostStatus(job, hosts, jobs.Running, nil)
+		hostSpans := startHostSpans(span, "attach", hosts)
+		phase := ui.StartPhase(fmt.Sprintf("Attaching %s node(s) %v to the cluster %s", role, ips, job.ClusterName))
+		var err error
+		if !attachedMasters[hostIDs[0]] {
+			err = c.Qbert.AttachNode(job.ClusterUuid, auth.ProjectID, auth.Token, hostIDs, role)
+		}
+		if err != nil {
+			setHostStatus(job, hosts, jobs.Failed, err)
+			endSpans(hostSpans, err)
+			phase.Fail(fmt.Sprintf("Unable to attach %s node(s) to the cluster", role))
+			trackEvent(c, auth, client.Event{Name: segmentEventAttach, Phase: phaseAttach + "-" + role, Status: checkFail, Err: err})
+			for _, ip := range ips {
+				log.ForHost(ip).Infof("Encountered an error while attaching %s node to a Kubernetes cluster : %s", role, err)
+			}
+			if role == "master" {
+				abortMasterAttach(c, auth, job)
+				return
+			}
+			continue
+		}
+
+		if role == "master" {
+			phase.Update(fmt.Sprintf("Waiting for master node %s and etcd to become healthy...", ips[0]))
+			healthSpan := hostSpans[0].Child("wait-master-health")
+			if err := waitForMasterHealth(c.Qbert, auth, job.ClusterUuid, hostIDs[0], MasterHealthTimeout, masterHealthPollInterval); err != nil {
+				node := c.Qbert.GetNodeInfo(auth.Token, auth.ProjectID, hostIDs[0])
+				node.Uuid = hostIDs[0]
+				err = fmt.Errorf("%w, the master is %s", err, nodeConvergeDetail(c.Resmgr, auth.Token, node))
+				healthSpan.End(err)
+				endSpans(hostSpans, err)
+				setHostStatus(job, hosts, jobs.Failed, err)
+				phase.Fail(fmt.Sprintf("Master node %s did not become healthy: %s", ips[0], err))
+				abortMasterAttach(c, auth, job)
+				return
+			}
+			healthSpan.End(nil)
+		}
+
+		setHostStatus(job, hosts, jobs.Done, nil)
+		endSpans(hostSpans, nil)
+		phase.Succeed(fmt.Sprintf("%s node(s) %v attached to cluster", strings.Title(role), ips))
+		trackEvent(c, auth, client.Event{Name: segmentEventAttach, Phase: phaseAttach + "-" + role, Status: checkPass})
+		for i, ip := range ips {
+			log.ForHost(ip).Debugf("%s node %s attached to cluster", role, hostIDs[i])
+		}
+	}
+}
+
+func runDetachJob(c client.Client, auth keystone.KeystoneAuth, job *jobs.Job, span *tracing.Span) {
+	allNodes := c.Qbert.GetAllNodes(auth.Token, auth.ProjectID)
+
+	for _, host := range job.Remaining() {
+		node, found := findNode(allNodes, host.HostID)
+		if found && node.ClusterUuid == "" {
+			log.ForHost(host.IP).Debugf("Node %s is already detached", host.IP)
+			setHostStatus(job, []jobs.Host{host}, jobs.Done, nil)
+			continue
+		}
+		if found && (node.IsMaster == 1 || len(ClusterNodes(allNodes, node.ClusterUuid)) == 1) {
+			fmt.Printf("Node %v is either the master node or the last node in the cluster\n", host.IP)
+		}
+
+		setHostStatus(job, []jobs.Host{host}, jobs.Running, nil)
+		hostSpan := span.Child("detach", "host.ip", host.IP, "host.id", host.HostID)
+		phase := ui.StartPhase(fmt.Sprintf("Detaching node %s", host.IP))
+		if err := c.Qbert.DetachNode(host.ClusterUuid, auth.ProjectID, auth.Token, host.HostID); err != nil {
+			setHostStatus(job, []jobs.Host{host}, jobs.Failed, err)
+			hostSpan.End(err)
+			phase.Fail(fmt.Sprintf("Unable to detach node %s", host.IP))
+			trackEvent(c, auth, client.Event{Name: segmentEventDetach, Phase: phaseDetach, Status: checkFail, Err: err})
+			log.ForHost(host.IP).Info("Encountered an error while detaching the ", host.IP, " node from a Kubernetes cluster : ", err)
+			continue
+		}
+
+		setHostStatus(job, []jobs.Host{host}, jobs.Done, nil)
+		hostSpan.End(nil)
+		phase.Succeed(fmt.Sprintf("Node %s detached from cluster", host.IP))
+		trackEvent(c, auth, client.Event{Name: segmentEventDetach, Phase: phaseDetach, Status: checkPass})
+	}
+}
+
+// setHostStatus records the status of the hosts. The operation keeps going if
+// the job can't be saved, it just can't be resumed from this point.
+func setHostStatus(job *jobs.Job, hosts []jobs.Host, status string, err error) {
+	for _, host := range hosts {
+		if saveErr := job.SetHostStatus(host.HostID, status, err); saveErr != nil {
+			zap.S().Warnf("Unable to save the state of job %s: %s", job.ID, saveErr)
+		}
+	}
+}
+
+func findNode(allNodes []qbert.Node, hostID string) (qbert.Node, bool) {
+	for _, node := range allNodes {
+		if node.Uuid == hostID {
+			return node, true
+		}
+	}
+	return qbert.Node{}, false
+}
+
+func hostIPs(hosts []jobs.Host) []string {
+	ips := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		ips = append(ips, host.IP)
+	}
+	return ips
+}
+
+func jobHostIDs(hosts []jobs.Host) []string {
+	hostIDs := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		hostIDs = append(hostIDs, host.HostID)
+	}
+	return hostIDs
+}
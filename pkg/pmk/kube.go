@@ -0,0 +1,256 @@
+package pmk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// kubeAPI reaches the API server of a cluster through the k8s API proxy of qbert
+type kubeAPI struct {
+	url   string
+	token string
+}
+
+type kubeNode struct {
+	Metadata struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		Unschedulable bool `json:"unschedulable"`
+		Taints        []struct {
+			Key    string `json:"key"`
+			Effect string `json:"effect"`
+		} `json:"taints"`
+	} `json:"spec"`
+	Status struct {
+		Addresses []struct {
+			Type    string `json:"type"`
+			Address string `json:"address"`
+		} `json:"addresses"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+		// Allocatable are the resources available to the pods, like cpu,
+		// memory and pods
+		Allocatable map[string]string `json:"allocatable"`
+	} `json:"status"`
+}
+
+// hasIP reports whether ip is the internal IP of the node
+func (n kubeNode) hasIP(ip string) bool {
+	for _, address := range n.Status.Addresses {
+		if address.Type == "InternalIP" && util.SameIP(address.Address, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// schedulable reports whether new pods can be scheduled on the node, that is
+// it is ready, not cordoned and not tainted against scheduling
+func (n kubeNode) schedulable() bool {
+	if n.Spec.Unschedulable {
+		return false
+	}
+	for _, taint := range n.Spec.Taints {
+		if taint.Effect == "NoSchedule" || taint.Effect == "NoExecute" {
+			return false
+		}
+	}
+	for _, condition := range n.Status.Conditions {
+		if condition.Type == "Ready" {
+			return condition.Status == "True"
+		}
+	}
+	return false
+}
+
+type kubePod struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace"`
+		Annotations     map[string]string `json:"annotations"`
+		OwnerReferences []struct {
+			Kind string `json:"kind"`
+		} `json:"ownerReferences"`
+	} `json:"metadata"`
+	Spec struct {
+		NodeName string `json:"nodeName"`
+	} `json:"spec"`
+	Status struct {
+		Phase string `json:"phase"`
+	} `json:"status"`
+}
+
+func newKubeAPI(fqdn, clusterUuid, token string) kubeAPI {
+	return kubeAPI{url: fmt.Sprintf("%s/qbert/v1/clusters/%s/k8sapi", fqdn, clusterUuid), token: token}
+}
+
+// request sends in as the JSON body of the request and decodes the response
+// into out when set. The status code is returned along with any error.
+func (k kubeAPI) request(method, path, contentType string, in, out interface{}) (int, error) {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return 0, err
+		}
+	}
+	req, err := http.NewRequest(method, k.url+path, &body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("X-Auth-Token", k.token)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("k8s API returned status %d for %s %s", resp.StatusCode, method, path)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("unable to decode the response of %s %s: %w", method, path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// nodeName returns the name of the k8s node with the IP, which is either the IP
+// itself or the host name depending on the cluster settings.
+func (k kubeAPI) nodeName(ip string) (string, error) {
+	nodes, err := k.nodes()
+	if err != nil {
+		return "", err
+	}
+	for _, node := range nodes {
+		if node.hasIP(ip) {
+			return node.Metadata.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no k8s node with IP %s", ip)
+}
+
+// nodes returns all the nodes of the cluster
+func (k kubeAPI) nodes() ([]kubeNode, error) {
+	var nodes struct {
+		Items []kubeNode `json:"items"`
+	}
+	if _, err := k.request("GET", "/api/v1/nodes", "application/json", nil, &nodes); err != nil {
+		return nil, err
+	}
+	return nodes.Items, nil
+}
+
+// setUnschedulable cordons or uncordons the node
+func (k kubeAPI) setUnschedulable(name string, unschedulable bool) error {
+	patch := map[string]interface{}{"spec": map[string]bool{"unschedulable": unschedulable}}
+	_, err := k.request("PATCH", "/api/v1/nodes/"+name, "application/strategic-merge-patch+json", patch, nil)
+	return err
+}
+
+// setLabels adds the labels to the node, replacing the ones with the same key
+func (k kubeAPI) setLabels(name string, labels map[string]string) error {
+	patch := map[string]interface{}{"metadata": map[string]interface{}{"labels": labels}}
+	_, err := k.request("PATCH", "/api/v1/nodes/"+name, "application/strategic-merge-patch+json", patch, nil)
+	return err
+}
+
+// evictablePods returns the pods running on the node which drain evicts, that
+// is all but the ones of daemon sets, the static pods and the finished ones.
+func (k kubeAPI) evictablePods(name string) ([]kubePod, error) {
+	var pods struct {
+		Items []kubePod `json:"items"`
+	}
+	query := url.Values{"fieldSelector": {"spec.nodeName=" + name}}
+	if _, err := k.request("GET", "/api/v1/pods?"+query.Encode(), "application/json", nil, &pods); err != nil {
+		return nil, err
+	}
+
+	var evictable []kubePod
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == "Succeeded" || pod.Status.Phase == "Failed" {
+			continue
+		}
+		if _, ok := pod.Metadata.Annotations["kubernetes.io/config.mirror"]; ok {
+			continue
+		}
+		daemon := false
+		for _, owner := range pod.Metadata.OwnerReferences {
+			if owner.Kind == "DaemonSet" {
+				daemon = true
+			}
+		}
+		if !daemon {
+			evictable = append(evictable, pod)
+		}
+	}
+	return evictable, nil
+}
+
+// evict asks the API server to evict the pod, which respects the pod
+// disruption budgets. It returns false when a budget blocks the eviction.
+func (k kubeAPI) evict(pod kubePod) (bool, error) {
+	eviction := map[string]interface{}{
+		"apiVersion": "policy/v1beta1",
+		"kind":       "Eviction",
+		"metadata":   map[string]string{"name": pod.Metadata.Name, "namespace": pod.Metadata.Namespace},
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s/eviction", pod.Metadata.Namespace, pod.Metadata.Name)
+	status, err := k.request("POST", path, "application/json", eviction, nil)
+	switch status {
+	case http.StatusTooManyRequests:
+		return false, nil
+	case http.StatusNotFound:
+		return true, nil
+	}
+	return err == nil, err
+}
+
+// drain evicts the pods of the node until none is left, retrying the ones
+// blocked by a disruption budget until timeout.
+func (k kubeAPI) drain(name string, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		pods, err := k.evictablePods(name)
+		if err != nil {
+			return err
+		}
+		if len(pods) == 0 {
+			return nil
+		}
+
+		var blocked []string
+		for _, pod := range pods {
+			podName := pod.Metadata.Namespace + "/" + pod.Metadata.Name
+			evicted, err := k.evict(pod)
+			if err != nil {
+				return fmt.Errorf("unable to evict pod %s: %w", podName, err)
+			}
+			if !evicted {
+				zap.S().Debugf("Eviction of pod %s is blocked by a disruption budget", podName)
+				blocked = append(blocked, podName)
+			}
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			if len(blocked) > 0 {
+				return fmt.Errorf("pods %s still running after %s, their disruption budgets block the eviction", strings.Join(blocked, ", "), timeout)
+			}
+			return fmt.Errorf("%d pod(s) still running after %s", len(pods), timeout)
+		}
+		time.Sleep(interval)
+	}
+}
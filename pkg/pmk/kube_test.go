@@ -0,0 +1,105 @@
+package pmk
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const kubePods = `{"items": [
+	{"metadata": {"name": "web-1", "namespace": "default"}, "status": {"phase": "Running"}},
+	{"metadata": {"name": "db-0", "namespace": "default"}, "status": {"phase": "Running"}},
+	{"metadata": {"name": "calico-node-x", "namespace": "kube-system", "ownerReferences": [{"kind": "DaemonSet"}]}, "status": {"phase": "Running"}},
+	{"metadata": {"name": "static", "namespace": "kube-system", "annotations": {"kubernetes.io/config.mirror": "abc"}}, "status": {"phase": "Running"}},
+	{"metadata": {"name": "job-1", "namespace": "default"}, "status": {"phase": "Succeeded"}}
+]}`
+
+func TestKubeAPI(t *testing.T) {
+	var mu sync.Mutex
+	var patches []string
+	evicted := map[string]bool{}
+	blocked := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		prefix := "/qbert/v1/clusters/c1/k8sapi"
+		switch {
+		case r.Header.Get("X-Auth-Token") != "token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == prefix+"/api/v1/nodes":
+			w.Write([]byte(`{"items": [{"metadata": {"name": "worker-1"}, "status": {"addresses": [{"type": "InternalIP", "address": "10.0.0.1"}]}}]}`))
+		case r.Method == "PATCH" && r.URL.Path == prefix+"/api/v1/nodes/worker-1":
+			assert.Equal(t, "application/strategic-merge-patch+json", r.Header.Get("Content-Type"))
+			var patch map[string]map[string]bool
+			json.NewDecoder(r.Body).Decode(&patch)
+			patches = append(patches, r.URL.Path)
+			assert.Contains(t, patch["spec"], "unschedulable")
+		case r.URL.Path == prefix+"/api/v1/pods":
+			assert.Equal(t, "spec.nodeName=worker-1", r.URL.Query().Get("fieldSelector"))
+			var pods struct {
+				Items []kubePod `json:"items"`
+			}
+			json.Unmarshal([]byte(kubePods), &pods)
+			var left []kubePod
+			for _, pod := range pods.Items {
+				if !evicted[pod.Metadata.Name] {
+					left = append(left, pod)
+				}
+			}
+			pods.Items = left
+			json.NewEncoder(w).Encode(pods)
+		case r.Method == "POST" && r.URL.Path == prefix+"/api/v1/namespaces/default/pods/db-0/eviction" && blocked > 0:
+			// The disruption budget of db-0 blocks its first eviction
+			blocked--
+			w.WriteHeader(http.StatusTooManyRequests)
+		case r.Method == "POST":
+			var eviction struct {
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+			}
+			json.NewDecoder(r.Body).Decode(&eviction)
+			evicted[eviction.Metadata.Name] = true
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	kube := newKubeAPI(server.URL, "c1", "token")
+
+	name, err := kube.nodeName("10.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, "worker-1", name)
+	_, err = kube.nodeName("10.0.0.2")
+	assert.EqualError(t, err, "no k8s node with IP 10.0.0.2")
+
+	assert.Nil(t, kube.setUnschedulable(name, true))
+	assert.Equal(t, []string{"/qbert/v1/clusters/c1/k8sapi/api/v1/nodes/worker-1"}, patches)
+
+	// Pods of daemon sets, static pods and finished pods are left on the node
+	assert.Nil(t, kube.drain(name, time.Second, time.Millisecond))
+	assert.Equal(t, map[string]bool{"web-1": true, "db-0": true}, evicted)
+
+	err = newKubeAPI(server.URL, "c1", "expired").setUnschedulable(name, false)
+	assert.EqualError(t, err, "k8s API returned status 401 for PATCH /api/v1/nodes/worker-1")
+}
+
+func TestDrainBlocked(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"items": [{"metadata": {"name": "db-0", "namespace": "default"}, "status": {"phase": "Running"}}]}`))
+	}))
+	defer server.Close()
+
+	err := newKubeAPI(server.URL, "c1", "token").drain("worker-1", 20*time.Millisecond, 5*time.Millisecond)
+	assert.EqualError(t, err, "pods default/db-0 still running after 20ms, their disruption budgets block the eviction")
+}
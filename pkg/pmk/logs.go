@@ -0,0 +1,43 @@
+package pmk
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// logUnits maps the components accepted by `pf9ctl logs node` to their systemd unit
+var logUnits = map[string]string{
+	"hostagent": "pf9-hostagent",
+	"nodelet":   "pf9-nodeletd",
+	"kubelet":   "pf9-kubelet",
+}
+
+// LogComponents returns the sorted names of the components whose logs can be fetched
+func LogComponents() []string {
+	components := make([]string, 0, len(logUnits))
+	for component := range logUnits {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	return components
+}
+
+// NodeLogCommand builds the journalctl command printing the logs of component
+// written in the last since, following new entries if follow is set.
+func NodeLogCommand(component string, since time.Duration, follow bool) (string, error) {
+	unit, found := logUnits[component]
+	if !found {
+		return "", fmt.Errorf("unknown component %s, must be one of %s", component, strings.Join(LogComponents(), ", "))
+	}
+	if since <= 0 {
+		return "", fmt.Errorf("invalid duration %s, must be greater than zero", since)
+	}
+
+	command := fmt.Sprintf("journalctl -u %s --no-pager --since '-%ds'", unit, int64(since.Seconds()))
+	if follow {
+		command += " -f"
+	}
+	return command, nil
+}
@@ -0,0 +1,32 @@
+package pmk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeLogCommand(t *testing.T) {
+	cases := map[string]struct {
+		component string
+		since     time.Duration
+		follow    bool
+		want      string
+		err       bool
+	}{
+		"Hostagent": {component: "hostagent", since: time.Hour, want: "journalctl -u pf9-hostagent --no-pager --since '-3600s'"},
+		"Follow":    {component: "kubelet", since: 10 * time.Minute, follow: true, want: "journalctl -u pf9-kubelet --no-pager --since '-600s' -f"},
+		"Nodelet":   {component: "nodelet", since: 90 * time.Second, want: "journalctl -u pf9-nodeletd --no-pager --since '-90s'"},
+		"Unknown":   {component: "etcd", since: time.Hour, err: true},
+		"NoSince":   {component: "hostagent", err: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			command, err := NodeLogCommand(tc.component, tc.since, tc.follow)
+			assert.Equal(t, tc.err, err != nil)
+			assert.Equal(t, tc.want, command)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package pmk
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/ui"
+)
+
+// MaintenanceTimeout is how long node maintenance waits for the node to be
+// drained and, at the end of the maintenance, to converge again
+var MaintenanceTimeout = 20 * time.Minute
+
+// maintenancePollInterval is how often the node is checked while waiting
+var maintenancePollInterval = 15 * time.Second
+
+// maintenanceMarker records when the maintenance of the node was started
+const maintenanceMarker = "/etc/pf9/maintenance"
+
+// maintenanceServices are disabled for the maintenance window. The hostagent
+// is stopped first so it doesn't restart the nodelet.
+var maintenanceServices = []string{"pf9-hostagent", "pf9-nodeletd"}
+
+// maintenanceNode is the node under maintenance as known to the DU
+type maintenanceNode struct {
+	ip     string
+	hostID string
+	node   qbert.Node
+}
+
+// StartMaintenance prepares the node for an OS patch window: it cordons and
+// drains the node when it is attached to a cluster, stops the k8s services
+// and disables the pf9 services so they stay down across reboots.
+func StartMaintenance(c client.Client, auth keystone.KeystoneAuth, fqdn, ip string) error {
+	n, err := findMaintenanceNode(c, auth, ip)
+	if err != nil {
+		return err
+	}
+
+	phase := ui.StartPhase(fmt.Sprintf("Starting the maintenance of node %s", n.ip))
+	defer phase.Stop()
+
+	if n.node.ClusterUuid != "" {
+		kube := newKubeAPI(fqdn, n.node.ClusterUuid, auth.Token)
+		name, err := kube.nodeName(n.ip)
+		if err != nil {
+			phase.Fail("Unable to find the node in its cluster")
+			return err
+		}
+		phase.Update("Cordoning node")
+		if err := kube.setUnschedulable(name, true); err != nil {
+			phase.Fail("Unable to cordon the node")
+			return err
+		}
+		phase.Step(fmt.Sprintf("Node %s of cluster %s cordoned", name, n.node.ClusterName))
+
+		phase.Update("Draining node")
+		if err := kube.drain(name, MaintenanceTimeout, maintenancePollInterval); err != nil {
+			phase.Fail("Unable to drain the node, it is still cordoned")
+			return err
+		}
+		phase.Step("Node drained")
+	} else {
+		phase.Step("Node is not attached to any cluster, skipping drain")
+	}
+
+	phase.Update("Stopping Platform9 services")
+	if _, err := c.Executor.RunWithStdout("bash", "-c", startMaintenanceScript(time.Now())); err != nil {
+		phase.Fail("Unable to stop the Platform9 services")
+		return fmt.Errorf("unable to stop the Platform9 services: %w", err)
+	}
+	phase.Step(fmt.Sprintf("Services %s stopped and disabled", strings.Join(maintenanceServices, ", ")))
+
+	phase.Succeed(fmt.Sprintf("Node %s is ready for maintenance, run 'pf9ctl node maintenance --end' once done", n.ip))
+	return nil
+}
+
+// EndMaintenance restores a node after its maintenance: it starts the pf9
+// services, waits for the node to converge and uncordons it.
+func EndMaintenance(c client.Client, auth keystone.KeystoneAuth, fqdn, ip string) error {
+	n, err := findMaintenanceNode(c, auth, ip)
+	if err != nil {
+		return err
+	}
+
+	phase := ui.StartPhase(fmt.Sprintf("Ending the maintenance of node %s", n.ip))
+	defer phase.Stop()
+
+	if _, err := c.Executor.RunArgs("test", "-f", maintenanceMarker); err != nil {
+		phase.Warn("Node was not put in maintenance by pf9ctl, restoring it anyway")
+	}
+
+	phase.Update("Starting Platform9 services")
+	if _, err := c.Executor.RunWithStdout("bash", "-c", endMaintenanceScript()); err != nil {
+		phase.Fail("Unable to start the Platform9 services")
+		return fmt.Errorf("unable to start the Platform9 services: %w", err)
+	}
+	phase.Step(fmt.Sprintf("Services %s enabled and started", strings.Join(maintenanceServices, ", ")))
+
+	phase.Update("Waiting for the node to converge")
+	if err := waitForNodeConvergence(c, auth, n, MaintenanceTimeout, maintenancePollInterval); err != nil {
+		phase.Fail("Node did not converge, it is left cordoned")
+		return err
+	}
+	phase.Step("Node converged")
+
+	if n.node.ClusterUuid != "" {
+		kube := newKubeAPI(fqdn, n.node.ClusterUuid, auth.Token)
+		name, err := kube.nodeName(n.ip)
+		if err == nil {
+			err = kube.setUnschedulable(name, false)
+		}
+		if err != nil {
+			phase.Fail("Unable to uncordon the node")
+			return err
+		}
+		phase.Step(fmt.Sprintf("Node %s uncordoned", name))
+	}
+
+	c.Executor.RunArgs("rm", "-f", maintenanceMarker)
+	phase.Succeed(fmt.Sprintf("Maintenance of node %s ended", n.ip))
+	return nil
+}
+
+// findMaintenanceNode looks the node up in the DU, ip defaults to the primary
+// IP of the host the executor runs on
+func findMaintenanceNode(c client.Client, auth keystone.KeystoneAuth, ip string) (maintenanceNode, error) {
+	if ip == "" {
+		out, err := c.Executor.RunArgs("hostname", "-I")
+		if err != nil {
+			return maintenanceNode{}, fmt.Errorf("unable to get the host IP: %w", err)
+		}
+		//Handling case where host can have multiple IPs
+		ip = strings.TrimSpace(strings.Split(strings.TrimSpace(out), " ")[0])
+	}
+
+	hostIDs := c.Resmgr.GetHostId(auth.Token, []string{ip})
+	if len(hostIDs) == 0 {
+		return maintenanceNode{}, fmt.Errorf("node %s is not onboarded", ip)
+	}
+	n := maintenanceNode{ip: ip, hostID: hostIDs[0]}
+	// A node whose cluster isn't known can't be drained, it is left running
+	node, err := c.Qbert.NodeInfo(auth.Token, auth.ProjectID, n.hostID)
+	if err != nil && !errors.Is(err, qbert.ErrNodeNotFound) {
+		return maintenanceNode{}, fmt.Errorf("unable to find the cluster of node %s: %w", ip, err)
+	}
+	n.node = node
+	return n, nil
+}
+
+// waitForNodeConvergence polls the DU until the hostagent of the node responds
+// and, when attached to a cluster, qbert reports the node as converged.
+func waitForNodeConvergence(c client.Client, auth keystone.KeystoneAuth, n maintenanceNode, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		var node qbert.Node
+		responding := c.Resmgr.HostSatus(auth.Token, n.hostID)
+		if responding {
+			if n.node.ClusterUuid == "" {
+				return nil
+			}
+			node = c.Qbert.GetNodeInfo(auth.Token, auth.ProjectID, n.hostID)
+			if nodeConverged(node) {
+				return nil
+			}
+		}
+		if time.Now().Add(interval).After(deadline) {
+			if !responding {
+				return fmt.Errorf("node %s not converged after %s, its hostagent is not responding", n.ip, timeout)
+			}
+			node.Uuid = n.hostID
+			return fmt.Errorf("node %s not converged after %s, it is %s", n.ip, timeout, nodeConvergeDetail(c.Resmgr, auth.Token, node))
+		}
+		time.Sleep(interval)
+	}
+}
+
+// nodeConverged reports whether qbert sees the node as healthy, masters
+// must also have their API server responding
+func nodeConverged(node qbert.Node) bool {
+	if node.Status != "ok" {
+		return false
+	}
+	return node.IsMaster != 1 || node.ApiResponding == 1
+}
+
+func startMaintenanceScript(now time.Time) string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	fmt.Fprintf(&b, "echo %s | sudo tee %s > /dev/null\n", cmdexec.ShellQuote(now.UTC().Format(time.RFC3339)), maintenanceMarker)
+	for _, svc := range maintenanceServices {
+		if svc == "pf9-nodeletd" {
+			// The nodelet stops the k8s services in order before being stopped
+			b.WriteString("if [ -x /opt/pf9/nodelet/nodeletd ]; then sudo /opt/pf9/nodelet/nodeletd phases stop; fi\n")
+		}
+		fmt.Fprintf(&b, "if systemctl cat %[1]s > /dev/null 2>&1; then sudo systemctl stop %[1]s; sudo systemctl disable %[1]s; fi\n", svc)
+	}
+	return b.String()
+}
+
+func endMaintenanceScript() string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	for _, svc := range maintenanceServices {
+		fmt.Fprintf(&b, "if systemctl cat %[1]s > /dev/null 2>&1; then sudo systemctl enable %[1]s; sudo systemctl start %[1]s; fi\n", svc)
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package pmk
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeConverged(t *testing.T) {
+	cases := map[string]struct {
+		node qbert.Node
+		want bool
+	}{
+		"Worker":            {node: qbert.Node{Status: "ok"}, want: true},
+		"Converging":        {node: qbert.Node{Status: "converging"}},
+		"Master":            {node: qbert.Node{Status: "ok", IsMaster: 1, ApiResponding: 1}, want: true},
+		"MasterAPIDown":     {node: qbert.Node{Status: "ok", IsMaster: 1}},
+		"FailedWithAPIDown": {node: qbert.Node{Status: "failed", IsMaster: 1}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, nodeConverged(tc.node))
+		})
+	}
+}
+
+func TestMaintenanceScripts(t *testing.T) {
+	start := startMaintenanceScript(time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC))
+	assert.Contains(t, start, "echo '2021-06-01T10:00:00Z' | sudo tee /etc/pf9/maintenance")
+	// The hostagent is stopped before the nodelet stops the k8s services
+	assert.Regexp(t, `(?s)systemctl stop pf9-hostagent.*nodeletd phases stop.*systemctl stop pf9-nodeletd`, start)
+	assert.Contains(t, start, "sudo systemctl disable pf9-nodeletd")
+
+	end := endMaintenanceScript()
+	assert.Regexp(t, `(?s)systemctl start pf9-hostagent.*systemctl start pf9-nodeletd`, end)
+	assert.Contains(t, end, "sudo systemctl enable pf9-hostagent")
+
+	// The remote executor passes the scripts to bash in double quotes
+	assert.NotContains(t, start+end, "\"")
+}
+
+func TestFindMaintenanceNode(t *testing.T) {
+	c := client.Client{
+		Resmgr: hostsResmgr{hosts: []resmgr.HostInfo{hostWithIP("host-1", "10.0.0.1"), hostWithIP("host-2", "10.0.0.2")}},
+		Qbert:  nodesQbert{nodes: map[string]qbert.Node{"host-1": {Uuid: "host-1", ClusterUuid: "uuid-prod"}}},
+	}
+	auth := keystone.KeystoneAuth{}
+
+	n, err := findMaintenanceNode(c, auth, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "uuid-prod", n.node.ClusterUuid)
+
+	// host-2 isn't a node of qbert, it has no cluster to be drained from
+	n, err = findMaintenanceNode(c, auth, "10.0.0.2")
+	assert.NoError(t, err)
+	assert.Equal(t, "host-2", n.hostID)
+	assert.Empty(t, n.node.ClusterUuid)
+
+	_, err = findMaintenanceNode(c, auth, "10.0.0.3")
+	assert.EqualError(t, err, "node 10.0.0.3 is not onboarded")
+
+	// The services of the node aren't stopped undrained when its cluster
+	// isn't known
+	c.Qbert = nodesQbert{err: errors.New("could not query the qbert endpoint: 503")}
+	_, err = findMaintenanceNode(c, auth, "10.0.0.1")
+	assert.EqualError(t, err, "unable to find the cluster of node 10.0.0.1: could not query the qbert endpoint: 503")
+}
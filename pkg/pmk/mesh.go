@@ -0,0 +1,400 @@
+package pmk
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/util"
+)
+
+// MeshPort is a port a node must reach on another node of the cluster
+type MeshPort struct {
+	Protocol    string
+	Port        int
+	Description string
+	// Plugin is the network plugin the port is needed for, empty for any
+	Plugin string
+	// FromMasters and ToMasters restrict the nodes the port is needed
+	// between
+	FromMasters bool
+	ToMasters   bool
+}
+
+func (p MeshPort) String() string {
+	if p.Protocol == meshIPIP {
+		return p.Protocol
+	}
+	return fmt.Sprintf("%d/%s", p.Port, p.Protocol)
+}
+
+// meshIPIP is the protocol of the IP-in-IP encapsulation, which has no port
+const meshIPIP = "ipip"
+
+// meshPorts are the ports between the nodes of a cluster
+var meshPorts = []MeshPort{
+	{"tcp", 2380, "etcd peer", "", true, true},
+	{"tcp", 2379, "etcd client", "", true, true},
+	{"tcp", 443, "Kubernetes API server", "", false, true},
+	{"tcp", 10250, "kubelet API", "", false, false},
+	{"tcp", 179, "Calico BGP", string(Calico), false, false},
+	{"udp", 4789, "Calico and Flannel VXLAN", "", false, false},
+	{meshIPIP, 0, "Calico IP-in-IP", string(Calico), false, false},
+}
+
+// MeshPorts returns the ports needed between the nodes of a cluster with the
+// network plugin
+func MeshPorts(plugin string) []MeshPort {
+	var ports []MeshPort
+	for _, p := range meshPorts {
+		if p.Plugin == "" || strings.EqualFold(p.Plugin, plugin) {
+			ports = append(ports, p)
+		}
+	}
+	return ports
+}
+
+// MeshNode is a candidate node of the mesh test
+type MeshNode struct {
+	IP   string
+	Role string
+	Exec cmdexec.Executor
+}
+
+// needs is true when from must reach to on the port
+func (p MeshPort) needs(from, to MeshNode) bool {
+	return (!p.FromMasters || from.Role == util.RoleMaster) && (!p.ToMasters || to.Role == util.RoleMaster)
+}
+
+// Statuses of a probe of the mesh test
+const (
+	// MeshOpen is a port the node connected to
+	MeshOpen = "open"
+	// MeshReceived is a datagram or packet received by the node
+	MeshReceived = "received"
+	// MeshFiltered is a port the traffic to is dropped
+	MeshFiltered = "filtered"
+	// MeshRejected is a port the connections to are refused, even while the
+	// node listens on it
+	MeshRejected = "rejected"
+	// MeshUnknown is a port which couldn't be probed
+	MeshUnknown = "unknown"
+)
+
+// MeshProbe is a port of a node probed from another node
+type MeshProbe struct {
+	From   string
+	To     string
+	Port   MeshPort
+	Status string
+	// Err is why the port couldn't be probed
+	Err error
+}
+
+// Reachable is true when the traffic to the port isn't blocked
+func (p MeshProbe) Reachable() bool {
+	return p.Status == MeshOpen || p.Status == MeshReceived
+}
+
+// Blocked is true when the traffic to the port is dropped or rejected
+func (p MeshProbe) Blocked() bool {
+	return p.Status == MeshFiltered || p.Status == MeshRejected
+}
+
+// meshTimeout is how long a probe waits for a port, in seconds
+const meshTimeout = 3
+
+// RunMesh probes the ports the network plugin needs between each pair of
+// nodes. The TCP ports are connected to, those refusing the connection are
+// connected to again while the node listens on them with python3, as a
+// firewall rejecting the traffic can't be told apart from a port nothing
+// listens on yet. The UDP ports and IP-in-IP are listened on with python3 for
+// the datagrams and packets sent by the other node.
+func RunMesh(nodes []MeshNode, plugin string) []MeshProbe {
+	ports := MeshPorts(plugin)
+	var probes []MeshProbe
+	for _, from := range nodes {
+		for _, to := range nodes {
+			if from.IP == to.IP {
+				continue
+			}
+			var tcp []MeshPort
+			for _, p := range ports {
+				if !p.needs(from, to) {
+					continue
+				}
+				if p.Protocol != "tcp" {
+					probes = append(probes, probeDatagram(from, to, p))
+				} else {
+					tcp = append(tcp, p)
+				}
+			}
+			if len(tcp) > 0 {
+				probes = append(probes, probeTCP(from, to, tcp)...)
+			}
+		}
+	}
+	return probes
+}
+
+// tcpProbeScript prints the status of each port of ip, connecting to them
+// with bash
+const tcpProbeScript = `for port in %s; do
+out=$(timeout %d bash -c "</dev/tcp/%s/$port" 2>&1); rc=$?
+if [ $rc -eq 0 ]; then s=open; elif [ $rc -eq 124 ]; then s=filtered; elif echo "$out" | grep -q refused; then s=refused; else s=rejected; fi
+echo "$port $s"
+done`
+
+// tcpRefused is the status of a port printed by tcpProbeScript when the
+// connection is refused
+const tcpRefused = "refused"
+
+// connectTCP connects from the node to the ports of ip, returning their status
+func connectTCP(from MeshNode, ip string, ports []MeshPort) (map[int]string, error) {
+	var numbers []string
+	for _, p := range ports {
+		numbers = append(numbers, strconv.Itoa(p.Port))
+	}
+	script := fmt.Sprintf(tcpProbeScript, strings.Join(numbers, " "), meshTimeout, ip)
+	out, err := from.Exec.RunArgs("bash", "-c", script)
+	return parseTCPProbe(out), err
+}
+
+// probeTCP connects from the node to the ports of to
+func probeTCP(from, to MeshNode, ports []MeshPort) []MeshProbe {
+	statuses, err := connectTCP(from, to.IP, ports)
+
+	var probes []MeshProbe
+	for _, p := range ports {
+		probe := MeshProbe{From: from.IP, To: to.IP, Port: p, Status: MeshUnknown}
+		if status, ok := statuses[p.Port]; ok {
+			if status == tcpRefused {
+				status = probeTCPListening(from, to, p)
+			}
+			probe.Status = status
+		} else if err != nil {
+			probe.Err = fmt.Errorf("unable to probe the port: %w", err)
+		} else {
+			probe.Err = fmt.Errorf("the probe didn't report the port")
+		}
+		probes = append(probes, probe)
+	}
+	return probes
+}
+
+// parseTCPProbe reads the status of the ports printed by tcpProbeScript
+func parseTCPProbe(out string) map[int]string {
+	statuses := make(map[int]string)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		port, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		statuses[port] = fields[1]
+	}
+	return statuses
+}
+
+// meshListenScript listens on a port of a protocol until the source sends
+// something to it, printing whether it did
+const meshListenScript = `command -v python3 > /dev/null || { echo nopython; exit; }
+python3 -c '
+import socket, sys
+protocol, port, source, timeout = sys.argv[1], int(sys.argv[2]), sys.argv[3], int(sys.argv[4])
+try:
+    if protocol == "tcp":
+        s = socket.socket(socket.AF_INET, socket.SOCK_STREAM)
+        s.setsockopt(socket.SOL_SOCKET, socket.SO_REUSEADDR, 1)
+    elif protocol == "udp":
+        s = socket.socket(socket.AF_INET, socket.SOCK_DGRAM)
+    else:
+        s = socket.socket(socket.AF_INET, socket.SOCK_RAW, 4)
+    s.bind(("", port))
+    if protocol == "tcp":
+        s.listen(1)
+except PermissionError:
+    print("denied")
+    sys.exit()
+except OSError:
+    print("inuse")
+    sys.exit()
+print("listening", flush=True)
+s.settimeout(timeout)
+try:
+    while True:
+        if protocol == "tcp":
+            conn, addr = s.accept()
+            conn.close()
+        else:
+            data, addr = s.recvfrom(2048)
+        if addr[0] == source:
+            print("received")
+            break
+except socket.timeout:
+    print("timeout")
+' %s %d %s %d`
+
+// udpSendScript sends a datagram to the port of ip every half second
+const udpSendScript = `for i in 1 2 3 4 5 6; do echo pf9ctl > /dev/udp/%s/%d; sleep 0.5; done`
+
+// ipipSendScript sends an IP-in-IP packet to ip every half second
+const ipipSendScript = `python3 -c '
+import socket, time
+s = socket.socket(socket.AF_INET, socket.SOCK_RAW, 4)
+for i in range(6):
+    s.sendto(b"pf9ctl", ("%s", 0))
+    time.sleep(0.5)
+'`
+
+// meshListenDelay is how long the listener is given to bind before the
+// traffic is sent
+var meshListenDelay = time.Second
+
+// listenWhile listens on the port of to while send runs, returning the last
+// line printed by meshListenScript
+func listenWhile(from, to MeshNode, p MeshPort, send func() error) (string, error) {
+	var (
+		wg     sync.WaitGroup
+		out    string
+		listen error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		out, listen = to.Exec.RunArgs("bash", "-c", fmt.Sprintf(meshListenScript, p.Protocol, p.Port, from.IP, 2*meshTimeout))
+	}()
+	time.Sleep(meshListenDelay)
+	if err := send(); err != nil {
+		log.ForHost(from.IP).Debugf("Unable to send to %s of %s: %s", p, to.IP, err)
+	}
+	wg.Wait()
+	return meshListenStatus(out), listen
+}
+
+// listenError is why the listener on the port of to printing status didn't
+// run
+func listenError(status string, to MeshNode, p MeshPort, listen error) error {
+	switch status {
+	case "nopython":
+		return fmt.Errorf("python3 is needed on %s to listen on %s", to.IP, p)
+	case "inuse":
+		return fmt.Errorf("%s is already in use on %s", p, to.IP)
+	case "denied":
+		return fmt.Errorf("root is needed on %s to listen on %s", to.IP, p)
+	}
+	return fmt.Errorf("unable to listen on %s of %s: %v", p, to.IP, listen)
+}
+
+// probeTCPListening connects from the node to the port of to refusing the
+// connection again while to listens on it. The port is rejected when the
+// connection is still refused or nothing could listen on it.
+func probeTCPListening(from, to MeshNode, p MeshPort) string {
+	status := tcpRefused
+	listened, listen := listenWhile(from, to, p, func() error {
+		statuses, err := connectTCP(from, to.IP, []MeshPort{p})
+		if s, ok := statuses[p.Port]; ok {
+			status = s
+		}
+		return err
+	})
+	if listened != "received" && listened != "timeout" {
+		log.ForHost(to.IP).Debugf("%s", listenError(listened, to, p, listen))
+	}
+	if status == tcpRefused {
+		return MeshRejected
+	}
+	return status
+}
+
+// probeDatagram sends datagrams or packets from the node to the port of to,
+// which listens for them
+func probeDatagram(from, to MeshNode, p MeshPort) MeshProbe {
+	probe := MeshProbe{From: from.IP, To: to.IP, Port: p, Status: MeshUnknown}
+	script := fmt.Sprintf(udpSendScript, to.IP, p.Port)
+	if p.Protocol == meshIPIP {
+		script = fmt.Sprintf(ipipSendScript, to.IP)
+	}
+	status, listen := listenWhile(from, to, p, func() error {
+		_, err := from.Exec.RunArgs("bash", "-c", script)
+		return err
+	})
+
+	switch status {
+	case "received":
+		probe.Status = MeshReceived
+	case "timeout":
+		probe.Status = MeshFiltered
+	default:
+		probe.Err = listenError(status, to, p, listen)
+	}
+	return probe
+}
+
+// meshListenStatus returns the last line printed by meshListenScript
+func meshListenStatus(out string) string {
+	lines := strings.Fields(out)
+	if len(lines) == 0 {
+		return ""
+	}
+	return lines[len(lines)-1]
+}
+
+// BlockedProbes returns the probes of the ports the traffic to is blocked
+func BlockedProbes(probes []MeshProbe) []MeshProbe {
+	var blocked []MeshProbe
+	for _, p := range probes {
+		if p.Blocked() {
+			blocked = append(blocked, p)
+		}
+	}
+	return blocked
+}
+
+// MeshMatrix is the reachability between the nodes, with a row by node the
+// traffic comes from and a column by node it goes to. A cell is ok, the
+// blocked ports or ? when a port couldn't be probed.
+func MeshMatrix(ips []string, probes []MeshProbe) [][]string {
+	type pair struct{ from, to string }
+	blocked := make(map[pair][]string)
+	unknown := make(map[pair]bool)
+	probed := make(map[pair]bool)
+	for _, p := range probes {
+		key := pair{p.From, p.To}
+		probed[key] = true
+		switch {
+		case p.Blocked():
+			blocked[key] = append(blocked[key], p.Port.String())
+		case !p.Reachable():
+			unknown[key] = true
+		}
+	}
+
+	matrix := make([][]string, len(ips))
+	for i, from := range ips {
+		matrix[i] = make([]string, len(ips))
+		for j, to := range ips {
+			key := pair{from, to}
+			switch {
+			case from == to, !probed[key]:
+				matrix[i][j] = "-"
+			case len(blocked[key]) > 0:
+				sort.Strings(blocked[key])
+				matrix[i][j] = "x " + strings.Join(blocked[key], ",")
+			case unknown[key]:
+				matrix[i][j] = "?"
+			default:
+				matrix[i][j] = "ok"
+			}
+		}
+	}
+	return matrix
+}
@@ -0,0 +1,131 @@
+package pmk
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeshPorts(t *testing.T) {
+	ports := func(plugin string) []string {
+		var names []string
+		for _, p := range MeshPorts(plugin) {
+			names = append(names, p.String())
+		}
+		return names
+	}
+	assert.Equal(t, []string{"2380/tcp", "2379/tcp", "443/tcp", "10250/tcp", "179/tcp", "4789/udp", "ipip"}, ports("calico"))
+	assert.Equal(t, []string{"2380/tcp", "2379/tcp", "443/tcp", "10250/tcp", "4789/udp"}, ports("flannel"))
+}
+
+func TestRunMesh(t *testing.T) {
+	meshListenDelay = 0
+	// The node answers the probes of the TCP ports of ip with statuses, the
+	// ports of listened once they are listened on, and receives the datagrams
+	// when received is set
+	node := func(ip string, statuses map[int]string, listened map[int]string, received bool) MeshNode {
+		return MeshNode{IP: ip, Exec: &cmdexec.MockExecutor{
+			MockRunArgs: func(name string, args ...string) (string, error) {
+				script := args[len(args)-1]
+				switch {
+				case strings.HasPrefix(script, "for port in"):
+					var out string
+					for port, status := range statuses {
+						if s, ok := listened[port]; ok && strings.HasPrefix(script, fmt.Sprintf("for port in %d;", port)) {
+							status = s
+						}
+						out += fmt.Sprintf("%d %s\n", port, status)
+					}
+					return out, nil
+				case strings.Contains(script, "listening") && received:
+					return "listening\nreceived\n", nil
+				case strings.Contains(script, "listening"):
+					return "listening\ntimeout\n", nil
+				}
+				return "", nil
+			},
+		}}
+	}
+	// The ports refused by 10.0.0.2 are only reached from 10.0.0.1 once they
+	// are listened on
+	masters := []MeshNode{
+		node("10.0.0.1", map[int]string{2380: "open", 2379: "open", 443: "refused", 10250: "filtered"}, map[int]string{443: "open"}, true),
+		node("10.0.0.2", map[int]string{2380: "refused", 2379: "refused", 443: "refused", 10250: "refused"}, map[int]string{2380: "open", 2379: "open", 443: "open", 10250: "open"}, false),
+	}
+	masters[0].Role, masters[1].Role = "master", "master"
+	worker := node("10.0.0.3", map[int]string{443: "rejected", 10250: "refused"}, nil, true)
+	worker.Role = "worker"
+
+	probes := RunMesh(append(masters, worker), "flannel")
+	// Masters reach 5 ports of each other, 2 ports of the worker, the worker
+	// 3 ports of each master
+	assert.Len(t, probes, 2*5+2*2+2*3)
+
+	var blocked []string
+	for _, p := range BlockedProbes(probes) {
+		blocked = append(blocked, fmt.Sprintf("%s>%s:%s", p.From, p.To, p.Port))
+	}
+	assert.ElementsMatch(t, []string{
+		"10.0.0.1>10.0.0.2:10250/tcp",
+		"10.0.0.1>10.0.0.3:10250/tcp",
+		"10.0.0.3>10.0.0.1:443/tcp",
+		"10.0.0.3>10.0.0.2:443/tcp",
+		// The connections of 10.0.0.3 are still refused while listening
+		"10.0.0.3>10.0.0.1:10250/tcp",
+		"10.0.0.3>10.0.0.2:10250/tcp",
+		// 10.0.0.2 never receives the datagrams
+		"10.0.0.1>10.0.0.2:4789/udp",
+		"10.0.0.3>10.0.0.2:4789/udp",
+	}, blocked)
+
+	matrix := MeshMatrix([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, probes)
+	assert.Equal(t, [][]string{
+		{"-", "x 10250/tcp,4789/udp", "x 10250/tcp"},
+		{"ok", "-", "ok"},
+		{"x 10250/tcp,443/tcp", "x 10250/tcp,443/tcp,4789/udp", "-"},
+	}, matrix)
+}
+
+func TestProbeTCPFailure(t *testing.T) {
+	from := MeshNode{IP: "10.0.0.1", Exec: &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			return "", errors.New("exit status 127")
+		},
+	}}
+	probes := probeTCP(from, MeshNode{IP: "10.0.0.2"}, MeshPorts("calico")[:1])
+	assert.Equal(t, MeshUnknown, probes[0].Status)
+	assert.EqualError(t, probes[0].Err, "unable to probe the port: exit status 127")
+	assert.Equal(t, [][]string{{"-", "?"}, {"-", "-"}}, MeshMatrix([]string{"10.0.0.1", "10.0.0.2"}, probes))
+}
+
+func TestProbeDatagram(t *testing.T) {
+	meshListenDelay = 0
+	var sent string
+	from := MeshNode{IP: "10.0.0.1", Exec: &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			sent = args[len(args)-1]
+			return "", nil
+		},
+	}}
+	to := func(out string) MeshNode {
+		return MeshNode{IP: "10.0.0.2", Exec: &cmdexec.MockExecutor{
+			MockRunArgs: func(name string, args ...string) (string, error) {
+				return out, nil
+			},
+		}}
+	}
+	ipip := MeshPorts("calico")[6]
+
+	probe := probeDatagram(from, to("listening\nreceived\n"), ipip)
+	assert.Equal(t, MeshReceived, probe.Status)
+	assert.Contains(t, sent, "socket.SOCK_RAW, 4")
+	assert.Contains(t, sent, `("10.0.0.2", 0)`)
+
+	probe = probeDatagram(from, to("denied\n"), ipip)
+	assert.Equal(t, MeshUnknown, probe.Status)
+	assert.EqualError(t, probe.Err, "root is needed on 10.0.0.2 to listen on ipip")
+}
@@ -0,0 +1,186 @@
+package pmk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/statefile"
+	"github.com/platform9/pf9ctl/pkg/util"
+)
+
+// Steps of migrate-node, in the order they run
+const (
+	MigrateDrain        = "drain"
+	MigrateDecommission = "decommission"
+	MigratePrep         = "prep"
+	MigrateAttach       = "attach"
+)
+
+// Migration is the move of a node from the DU of the config to the DU of a
+// profile. It is saved after every step, so an interrupted migration resumes
+// where it stopped.
+type Migration struct {
+	IP string `json:"ip"`
+	// From and To are the DUs the node moves between
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Profile string `json:"profile"`
+	HostID  string `json:"hostId,omitempty"`
+	// FromCluster is the cluster of the node on the former DU, drained
+	// before the node is decommissioned
+	FromCluster     string `json:"fromCluster,omitempty"`
+	FromClusterUuid string `json:"fromClusterUuid,omitempty"`
+	// ToCluster is the cluster the node is attached to with Role on the new DU
+	ToCluster string    `json:"toCluster,omitempty"`
+	Role      string    `json:"role,omitempty"`
+	Steps     []string  `json:"steps"`
+	Done      []string  `json:"done,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// PlanMigration plans the migration of the node with ip from the DU of c to
+// the DU at to, attaching it to toCluster with role when it is set. Masters
+// aren't migrated, their cluster would lose a member of its etcd.
+func PlanMigration(c client.Client, auth keystone.KeystoneAuth, ip, from, to, profile, toCluster, role string) (*Migration, error) {
+	if normalizeDU(from) == normalizeDU(to) {
+		return nil, fmt.Errorf("the profile %s points to the DU %s of the config", profile, DUHost(to))
+	}
+	m := &Migration{IP: ip, From: from, To: to, Profile: profile, ToCluster: toCluster, Role: role, CreatedAt: time.Now()}
+
+	hosts, err := c.Resmgr.GetHosts(auth.Token)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the hosts of %s: %w", DUHost(from), err)
+	}
+	for _, host := range hosts {
+		if util.ContainsIP(host.Extensions.IPAddress.Data, ip) {
+			m.HostID = host.ID
+			break
+		}
+	}
+	if m.HostID != "" {
+		// Without its cluster, a master would be migrated and a worker
+		// wouldn't be drained
+		node, err := c.Qbert.NodeInfo(auth.Token, auth.ProjectID, m.HostID)
+		if err != nil && !errors.Is(err, qbert.ErrNodeNotFound) {
+			return nil, fmt.Errorf("unable to find the cluster of node %s: %w", ip, err)
+		}
+		if node.ClusterUuid != "" {
+			if node.IsMaster == 1 {
+				return nil, fmt.Errorf("node %s is a master of cluster %s, replace it with replace-node before migrating it", ip, node.ClusterName)
+			}
+			m.FromCluster, m.FromClusterUuid = node.ClusterName, node.ClusterUuid
+			m.Steps = append(m.Steps, MigrateDrain)
+		}
+	}
+	m.Steps = append(m.Steps, MigrateDecommission, MigratePrep)
+	if toCluster != "" {
+		if m.Role == "" {
+			m.Role = "worker"
+		}
+		m.Steps = append(m.Steps, MigrateAttach)
+	}
+	return m, nil
+}
+
+// Describe describes step of the migration
+func (m *Migration) Describe(step string) string {
+	switch step {
+	case MigrateDrain:
+		return fmt.Sprintf("Drain node %s of cluster %s on %s", m.IP, m.FromCluster, DUHost(m.From))
+	case MigrateDecommission:
+		if m.HostID == "" {
+			return fmt.Sprintf("Remove the pf9 packages of node %s, it isn't known to %s", m.IP, DUHost(m.From))
+		}
+		return fmt.Sprintf("Decommission node %s (host %s) from %s", m.IP, m.HostID, DUHost(m.From))
+	case MigratePrep:
+		return fmt.Sprintf("Prepare node %s with %s, the DU of profile %s", m.IP, DUHost(m.To), m.Profile)
+	case MigrateAttach:
+		return fmt.Sprintf("Attach node %s to cluster %s on %s as %s", m.IP, m.ToCluster, DUHost(m.To), m.Role)
+	}
+	return step
+}
+
+// IsDone reports whether step of the migration is done
+func (m *Migration) IsDone(step string) bool {
+	for _, done := range m.Done {
+		if done == step {
+			return true
+		}
+	}
+	return false
+}
+
+// Complete records step is done and saves the migration
+func (m *Migration) Complete(step string) error {
+	if !m.IsDone(step) {
+		m.Done = append(m.Done, step)
+	}
+	return m.Save()
+}
+
+// Matches reports whether the saved migration moves its node to the same DU
+// and cluster, so it can be resumed
+func (m *Migration) Matches(to, toCluster string) bool {
+	return normalizeDU(m.To) == normalizeDU(to) && m.ToCluster == toCluster
+}
+
+func migrationFile(ip string) string {
+	return filepath.Join(util.Pf9MigrationsDir, strings.NewReplacer(":", "_", "/", "_").Replace(ip)+".json")
+}
+
+// Save writes the migration to the state store
+func (m *Migration) Save() error {
+	if err := os.MkdirAll(util.Pf9MigrationsDir, 0700); err != nil {
+		return fmt.Errorf("unable to create migrations dir: %w", err)
+	}
+	m.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statefile.WriteFile(migrationFile(m.IP), data, 0600)
+}
+
+// Delete removes the migration from the state store once it is done
+func (m *Migration) Delete() error {
+	if err := os.Remove(migrationFile(m.IP)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to delete the migration of node %s: %w", m.IP, err)
+	}
+	return nil
+}
+
+// LoadMigration reads the interrupted migration of the node with ip, nil when
+// there is none
+func LoadMigration(ip string) (*Migration, error) {
+	data, err := ioutil.ReadFile(migrationFile(ip))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the migration of node %s: %w", ip, err)
+	}
+	m := &Migration{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("unable to parse the migration of node %s: %w", ip, err)
+	}
+	return m, nil
+}
+
+// ProfileFile returns the file of the profile bundle profile, given by its
+// path or by its name in the profiles dir
+func ProfileFile(profile string) string {
+	if _, err := os.Stat(profile); err == nil || strings.ContainsRune(profile, filepath.Separator) {
+		return profile
+	}
+	return filepath.Join(util.Pf9ProfilesDir, profile+".json")
+}
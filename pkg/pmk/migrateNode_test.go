@@ -0,0 +1,159 @@
+package pmk
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+// nodesQbert answers NodeInfo with its nodes by host ID, or with err
+type nodesQbert struct {
+	qbert.Qbert
+	nodes map[string]qbert.Node
+	err   error
+}
+
+func (q nodesQbert) NodeInfo(token, projectID, hostUUID string) (qbert.Node, error) {
+	if q.err != nil {
+		return qbert.Node{}, q.err
+	}
+	node, found := q.nodes[hostUUID]
+	if !found {
+		return node, qbert.ErrNodeNotFound
+	}
+	return node, nil
+}
+
+func (q nodesQbert) ListNodes(token, projectID string) ([]qbert.Node, error) {
+	var nodes []qbert.Node
+	for _, node := range q.nodes {
+		nodes = append(nodes, node)
+	}
+	return nodes, q.err
+}
+
+// hostsResmgr answers GetHosts with its hosts, or with err
+type hostsResmgr struct {
+	resmgr.Resmgr
+	hosts []resmgr.HostInfo
+	err   error
+}
+
+func (r hostsResmgr) GetHosts(token string) ([]resmgr.HostInfo, error) {
+	return r.hosts, r.err
+}
+
+func (r hostsResmgr) GetHostId(token string, hostIPs []string) []string {
+	var ids []string
+	for _, host := range r.hosts {
+		for _, ip := range hostIPs {
+			if util.ContainsIP(host.Extensions.IPAddress.Data, ip) {
+				ids = append(ids, host.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// hostWithIP returns the host id with the IP ip
+func hostWithIP(id, ip string) resmgr.HostInfo {
+	host := resmgr.HostInfo{ID: id}
+	host.Extensions.IPAddress.Data = []string{ip}
+	return host
+}
+
+func TestPlanMigration(t *testing.T) {
+	c := client.Client{
+		Resmgr: hostsResmgr{hosts: []resmgr.HostInfo{hostWithIP("host-1", "10.0.0.1"), hostWithIP("host-2", "10.0.0.2"), hostWithIP("host-3", "10.0.0.3"), hostWithIP("host-4", "10.0.0.4")}},
+		Qbert: nodesQbert{nodes: map[string]qbert.Node{
+			"host-1": {Uuid: "host-1", ClusterName: "prod", ClusterUuid: "uuid-prod"},
+			"host-2": {Uuid: "host-2"},
+			"host-3": {Uuid: "host-3", ClusterName: "prod", ClusterUuid: "uuid-prod", IsMaster: 1},
+		}},
+	}
+	auth := keystone.KeystoneAuth{}
+	from, to := "https://old.example.com", "https://new.example.com"
+
+	m, err := PlanMigration(c, auth, "10.0.0.1", from, to, "newdu", "edge", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{MigrateDrain, MigrateDecommission, MigratePrep, MigrateAttach}, m.Steps)
+	assert.Equal(t, "uuid-prod", m.FromClusterUuid)
+	assert.Equal(t, "Drain node 10.0.0.1 of cluster prod on old.example.com", m.Describe(MigrateDrain))
+	assert.Equal(t, "Attach node 10.0.0.1 to cluster edge on new.example.com as worker", m.Describe(MigrateAttach))
+
+	m, err = PlanMigration(c, auth, "10.0.0.2", from, to, "newdu", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{MigrateDecommission, MigratePrep}, m.Steps)
+
+	// host-4 is known to resmgr only
+	m, err = PlanMigration(c, auth, "10.0.0.4", from, to, "newdu", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{MigrateDecommission, MigratePrep}, m.Steps)
+
+	m, err = PlanMigration(c, auth, "10.0.0.9", from, to, "newdu", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Remove the pf9 packages of node 10.0.0.9, it isn't known to old.example.com", m.Describe(MigrateDecommission))
+
+	_, err = PlanMigration(c, auth, "10.0.0.3", from, to, "newdu", "", "")
+	assert.EqualError(t, err, "node 10.0.0.3 is a master of cluster prod, replace it with replace-node before migrating it")
+	_, err = PlanMigration(c, auth, "10.0.0.2", from, "old.example.com", "olddu", "", "")
+	assert.EqualError(t, err, "the profile olddu points to the DU old.example.com of the config")
+
+	// The node isn't taken for a node without cluster when qbert can't be
+	// queried
+	q := c.Qbert
+	c.Qbert = nodesQbert{err: errors.New("could not query the qbert endpoint: 503")}
+	_, err = PlanMigration(c, auth, "10.0.0.3", from, to, "newdu", "", "")
+	assert.EqualError(t, err, "unable to find the cluster of node 10.0.0.3: could not query the qbert endpoint: 503")
+	c.Qbert = q
+
+	// The node isn't taken for unknown to the DU when resmgr can't be queried
+	c.Resmgr = hostsResmgr{err: errors.New("could not query the resmgr endpoint: 503")}
+	_, err = PlanMigration(c, auth, "10.0.0.1", from, to, "newdu", "", "")
+	assert.EqualError(t, err, "unable to list the hosts of old.example.com: could not query the resmgr endpoint: 503")
+}
+
+func TestMigrationCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer func(dir string) { util.Pf9MigrationsDir = dir }(util.Pf9MigrationsDir)
+	util.Pf9MigrationsDir = dir
+
+	m, err := LoadMigration("10.0.0.1")
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	m = &Migration{IP: "10.0.0.1", To: "https://new.example.com", ToCluster: "edge", Steps: []string{MigrateDecommission, MigratePrep, MigrateAttach}}
+	assert.NoError(t, m.Complete(MigrateDecommission))
+	assert.NoError(t, m.Complete(MigrateDecommission))
+
+	loaded, err := LoadMigration("10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{MigrateDecommission}, loaded.Done)
+	assert.True(t, loaded.IsDone(MigrateDecommission))
+	assert.False(t, loaded.IsDone(MigratePrep))
+	assert.True(t, loaded.Matches("new.example.com", "edge"))
+	assert.False(t, loaded.Matches("new.example.com", ""))
+
+	assert.NoError(t, loaded.Delete())
+	m, err = LoadMigration("10.0.0.1")
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestProfileFile(t *testing.T) {
+	defer func(dir string) { util.Pf9ProfilesDir = dir }(util.Pf9ProfilesDir)
+	util.Pf9ProfilesDir = "/home/user/pf9/db/profiles"
+	assert.Equal(t, filepath.Join(util.Pf9ProfilesDir, "newdu.json"), ProfileFile("newdu"))
+	assert.Equal(t, "./bundles/newdu.json", ProfileFile("./bundles/newdu.json"))
+}
@@ -0,0 +1,325 @@
+package pmk
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/statefile"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// mirrorOS is an OS the package repo of disconnected installs is built for
+type mirrorOS struct {
+	// image is the container image the packages are downloaded in
+	image string
+	// family is debian or redhat, like the OS types of ValidatePlatform
+	family string
+	// packages are the packages the checks of prep-node install and the
+	// ones pf9-kube depends on
+	packages []string
+}
+
+var (
+	debianMirrorPackages = []string{"curl", "uuid-runtime", "net-tools", "ntp", "systemd-timesyncd",
+		"socat", "conntrack", "ipset", "ebtables", "ethtool"}
+	centos7MirrorPackages = []string{"ntp", "curl", "policycoreutils", "policycoreutils-python", "selinux-policy", "selinux-policy-targeted", "libselinux-utils", "net-tools",
+		"socat", "conntrack-tools", "ipset", "ebtables", "ethtool"}
+	el8MirrorPackages = []string{"chrony", "curl", "policycoreutils", "policycoreutils-python-utils", "selinux-policy", "selinux-policy-targeted", "libselinux-utils", "net-tools",
+		"socat", "conntrack-tools", "ipset", "ebtables", "ethtool"}
+)
+
+var mirrorOSes = map[string]mirrorOS{
+	"ubuntu18": {"ubuntu:18.04", "debian", debianMirrorPackages},
+	"ubuntu20": {"ubuntu:20.04", "debian", debianMirrorPackages},
+	"ubuntu22": {"ubuntu:22.04", "debian", debianMirrorPackages},
+	"centos7":  {"centos:7", "redhat", centos7MirrorPackages},
+	"rhel8":    {"rockylinux:8", "redhat", el8MirrorPackages},
+}
+
+// MirrorOSNames returns the OSes a package repo can be built for
+func MirrorOSNames() []string {
+	var names []string
+	for name := range mirrorOSes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mirrorRepoDir is where the output directory is mounted in the container,
+// and mirrorKeyFile the signing key
+const (
+	mirrorRepoDir = "/repo"
+	mirrorKeyFile = "/pf9ctl-mirror-key.asc"
+)
+
+// MirrorKeyName is the name of the public key of a package repo in its
+// directory. The nodes are given it with prep-node --package-repo-key, they
+// don't trust the one the repo serves.
+const MirrorKeyName = "pf9ctl-mirror.asc"
+
+// mirrorScript returns the script the container of target runs. It downloads
+// each package with the dependencies the image lacks into mirrorRepoDir,
+// printing a "missing <package>" line for the ones it can't, indexes them for
+// apt or yum, signs the index with mirrorKeyFile and gives them to owner. The
+// rpm packages keep the signatures of the OS, whose keys are added to the
+// public key of the repo.
+func mirrorScript(target mirrorOS, owner string) string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	// The key is imported in a keyring of the container, the gpg of the
+	// images can't share one
+	b.WriteString("export GNUPGHOME=/tmp/gnupg\n")
+	b.WriteString("mkdir -m 700 -p $GNUPGHOME\n")
+	if target.family == "debian" {
+		b.WriteString("export DEBIAN_FRONTEND=noninteractive\n")
+		b.WriteString("apt-get update -q > /dev/null\n")
+		// The packages are downloaded one by one, as some conflict like
+		// ntp and systemd-timesyncd
+		fmt.Fprintf(&b, "for p in %s; do\n", strings.Join(target.packages, " "))
+		b.WriteString("    apt-get install -y -q --download-only --no-install-recommends \"$p\" > /dev/null 2>&1 || echo \"missing $p\"\n")
+		b.WriteString("done\n")
+		fmt.Fprintf(&b, "find /var/cache/apt/archives -maxdepth 1 -name '*.deb' -exec cp {} %s \\;\n", mirrorRepoDir)
+		b.WriteString("apt-get install -y -q --no-install-recommends dpkg-dev apt-utils gnupg > /dev/null\n")
+		fmt.Fprintf(&b, "gpg --batch -q --import %s\n", mirrorKeyFile)
+		fmt.Fprintf(&b, "cd %s && dpkg-scanpackages --multiversion . /dev/null > Packages 2> /dev/null && gzip -9kf Packages\n", mirrorRepoDir)
+		// apt checks the packages against the hashes of the signed Release
+		b.WriteString("apt-ftparchive release . > /tmp/Release && mv /tmp/Release Release\n")
+		b.WriteString("gpg --batch --yes --clearsign -o InRelease Release && gpg --batch --yes -abs -o Release.gpg Release\n")
+	} else {
+		b.WriteString("yum install -y -q yum-utils createrepo gnupg2 > /dev/null 2>&1 || yum install -y -q yum-utils createrepo_c gnupg2 > /dev/null\n")
+		fmt.Fprintf(&b, "gpg --batch -q --import %s\n", mirrorKeyFile)
+		fmt.Fprintf(&b, "for p in %s; do\n", strings.Join(target.packages, " "))
+		fmt.Fprintf(&b, "    yumdownloader -q --resolve --destdir %s \"$p\" > /dev/null 2>&1 || echo \"missing $p\"\n", mirrorRepoDir)
+		b.WriteString("done\n")
+		fmt.Fprintf(&b, "if command -v createrepo > /dev/null; then createrepo -q %[1]s; else createrepo_c -q %[1]s; fi\n", mirrorRepoDir)
+		fmt.Fprintf(&b, "gpg --batch --yes --armor --detach-sign %s/repodata/repomd.xml\n", mirrorRepoDir)
+		fmt.Fprintf(&b, "cat /etc/pki/rpm-gpg/RPM-GPG-KEY-* >> %s/%s\n", mirrorRepoDir, MirrorKeyName)
+	}
+	if owner != "" {
+		fmt.Fprintf(&b, "chown -R %s %s\n", owner, mirrorRepoDir)
+	}
+	return b.String()
+}
+
+// parseMissingPackages returns the packages of the "missing <package>" lines
+// of the output of mirrorScript
+func parseMissingPackages(out string) []string {
+	var missing []string
+	for _, line := range strings.Split(out, "\n") {
+		if p := strings.TrimPrefix(strings.TrimSpace(line), "missing "); p != strings.TrimSpace(line) {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// containerRuntime returns docker or podman, whichever exec finds first
+func containerRuntime(exec cmdexec.Executor) (string, error) {
+	out, err := exec.RunArgs("bash", "-c", "command -v docker || command -v podman")
+	if err != nil || strings.TrimSpace(out) == "" {
+		return "", fmt.Errorf("docker or podman is needed to download the packages in a container of the OS")
+	}
+	return strings.TrimSpace(strings.Split(strings.TrimSpace(out), "\n")[0]), nil
+}
+
+// LoadMirrorKey returns the armored public key of the key the package repos
+// are signed with, stored in keyFile. The key is generated the first time it
+// is needed.
+func LoadMirrorKey(keyFile string) ([]byte, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if os.IsNotExist(err) {
+		if data, err = generateMirrorKey(); err == nil {
+			err = os.MkdirAll(filepath.Dir(keyFile), 0700)
+		}
+		if err == nil {
+			err = statefile.WriteFile(keyFile, data, 0600)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate the signing key of the package repos: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read the signing key of the package repos: %w", err)
+	}
+
+	keys, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil || len(keys) != 1 || keys[0].PrivateKey == nil {
+		return nil, fmt.Errorf("invalid signing key of the package repos %s", keyFile)
+	}
+	var public bytes.Buffer
+	w, err := armor.Encode(&public, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := keys[0].Serialize(w); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	public.WriteString("\n")
+	return public.Bytes(), nil
+}
+
+// generateMirrorKey returns a new armored private key to sign the package
+// repos with. It isn't protected by a passphrase, so the container signs with
+// it unattended.
+func generateMirrorKey() ([]byte, error) {
+	entity, err := openpgp.NewEntity("pf9ctl mirror", "", "", &packet.Config{RSABits: 4096})
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	w, err := armor.Encode(&b, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// CreateMirror downloads the packages prep-node and the installer fetch on
+// the nodes of osName into the apt or yum repo of the directory dir, with a
+// container of the OS the runtime of exec runs. The repo is signed with the
+// key of keyFile, whose public key is written to MirrorKeyName in dir. The
+// directory is given to owner, like 1000:1000, when it is set. It returns the
+// packages which couldn't be downloaded.
+func CreateMirror(exec cmdexec.Executor, osName, dir, owner, keyFile string) ([]string, error) {
+	target, ok := mirrorOSes[osName]
+	if !ok {
+		return nil, fmt.Errorf("invalid OS %q, use one of %s", osName, strings.Join(MirrorOSNames(), ", "))
+	}
+	runtime, err := containerRuntime(exec)
+	if err != nil {
+		return nil, err
+	}
+	public, err := LoadMirrorKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, MirrorKeyName), public, 0644); err != nil {
+		return nil, fmt.Errorf("unable to write the public key of the package repo: %w", err)
+	}
+	zap.S().Debugf("Downloading the packages of %s with %s in %s", osName, runtime, target.image)
+	out, err := exec.RunArgs(runtime, "run", "--rm", "-v", dir+":"+mirrorRepoDir+":z", "-v", keyFile+":"+mirrorKeyFile+":ro,z",
+		target.image, "bash", "-c", mirrorScript(target, owner))
+	if err != nil {
+		return nil, fmt.Errorf("unable to build the package repo in %s: %w", target.image, err)
+	}
+	missing := parseMissingPackages(out)
+	if len(missing) == len(target.packages) {
+		return missing, fmt.Errorf("none of the packages could be downloaded in %s, check the container reaches the repos of the OS", target.image)
+	}
+	return missing, nil
+}
+
+// packageRepoName is the name of the repo of `pf9ctl mirror create` on the
+// nodes
+const packageRepoName = "pf9ctl-mirror"
+
+// ValidatePackageRepo checks the URL of the package repo the nodes are
+// pointed at
+func ValidatePackageRepo(repo string) error {
+	u, err := url.Parse(repo)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file") || (u.Path == "" && u.Host == "") {
+		return fmt.Errorf("invalid package repo %q, use an http, https or file URL like http://10.0.0.5/repo or file:///srv/repo", repo)
+	}
+	return nil
+}
+
+// ValidatePackageRepoKey checks keyFile is the public key of a package repo
+func ValidatePackageRepoKey(keyFile string) error {
+	f, err := os.Open(keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to read the key of the package repo: %w", err)
+	}
+	defer f.Close()
+	if _, err := openpgp.ReadArmoredKeyRing(f); err != nil {
+		return fmt.Errorf("invalid key of the package repo %s, use the %s of the repo built by 'pf9ctl mirror create': %w", keyFile, MirrorKeyName, err)
+	}
+	return nil
+}
+
+// The key of the repo on the nodes, for apt and for yum
+var (
+	aptRepoKey = "/etc/apt/keyrings/" + packageRepoName + ".asc"
+	yumRepoKey = "/etc/pki/rpm-gpg/RPM-GPG-KEY-" + packageRepoName
+)
+
+// packageRepoScript returns the script adding the repo at the URL repo to
+// apt, or to yum without apt, and refreshing its index. The index and the
+// packages are checked against the key of the repo.
+func packageRepoScript(repo string, apt bool) string {
+	if apt {
+		list := fmt.Sprintf("deb [signed-by=%s] %s ./", aptRepoKey, repo)
+		return fmt.Sprintf(`echo %[1]s > /etc/apt/sources.list.d/%[2]s.list
+apt-get update -q -o Dir::Etc::sourcelist=sources.list.d/%[2]s.list -o Dir::Etc::sourceparts=- -o APT::Get::List-Cleanup=0`,
+			cmdexec.ShellQuote(list), packageRepoName)
+	}
+	yumRepo := fmt.Sprintf("[%s]\nname=Packages of pf9ctl mirror create\nbaseurl=%s\nenabled=1\ngpgcheck=1\nrepo_gpgcheck=1\ngpgkey=file://%s\n",
+		packageRepoName, repo, yumRepoKey)
+	return fmt.Sprintf(`rpm --import %[3]s
+printf '%%s' %[1]s > /etc/yum.repos.d/%[2]s.repo
+yum makecache -q -y --disablerepo='*' --enablerepo=%[2]s`, cmdexec.ShellQuote(yumRepo), packageRepoName, yumRepoKey)
+}
+
+// usesApt reports whether the node of exec installs its packages with apt
+func usesApt(exec cmdexec.Executor) bool {
+	_, err := exec.RunArgs("bash", "-c", "command -v apt-get")
+	return err == nil
+}
+
+// ConfigurePackageRepo points the package manager of the node of exec at the
+// repo built by `pf9ctl mirror create` and served at the URL repo, so the
+// packages prep-node installs come from it. They are checked against the
+// public key of the repo in keyFile, on this machine.
+func ConfigurePackageRepo(exec cmdexec.Executor, repo, keyFile string) error {
+	apt := usesApt(exec)
+	key := yumRepoKey
+	if apt {
+		key = aptRepoKey
+		if _, err := exec.RunArgs("mkdir", "-p", path.Dir(aptRepoKey)); err != nil {
+			return fmt.Errorf("unable to add the key of the package repo to the node: %w", err)
+		}
+	}
+	if err := cmdexec.CopyFile(exec, keyFile, key, 0644); err != nil {
+		return fmt.Errorf("unable to add the key of the package repo to the node: %w", err)
+	}
+	if _, err := exec.RunArgs("bash", "-c", packageRepoScript(repo, apt)); err != nil {
+		return fmt.Errorf("unable to add the package repo %s to the node: %w", repo, err)
+	}
+	return nil
+}
+
+// removePackageRepoScript removes the repo and its key from apt and yum, and
+// the key from the rpm database
+var removePackageRepoScript = fmt.Sprintf(`rm -f /etc/apt/sources.list.d/%[1]s.list %[2]s /etc/yum.repos.d/%[1]s.repo
+if [ -f %[3]s ]; then
+    rpm -q gpg-pubkey --qf '%%{NAME}-%%{VERSION}-%%{RELEASE} %%{SUMMARY}\n' | grep 'pf9ctl mirror' | cut -d' ' -f1 | xargs -r rpm -e
+    rm -f %[3]s
+fi`, packageRepoName, aptRepoKey, yumRepoKey)
+
+// RemovePackageRepo removes the repo ConfigurePackageRepo added from the node
+// of exec, once it is prepared
+func RemovePackageRepo(exec cmdexec.Executor) error {
+	if _, err := exec.RunArgs("bash", "-c", removePackageRepoScript); err != nil {
+		return fmt.Errorf("unable to remove the package repo from the node: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package pmk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMirrorScript(t *testing.T) {
+	script := mirrorScript(mirrorOSes["ubuntu22"], "1000:1000")
+	assert.Contains(t, script, "for p in curl uuid-runtime net-tools ntp systemd-timesyncd socat conntrack ipset ebtables ethtool; do")
+	assert.Contains(t, script, "dpkg-scanpackages --multiversion .")
+	assert.Contains(t, script, "gpg --batch -q --import /pf9ctl-mirror-key.asc")
+	assert.Contains(t, script, "apt-ftparchive release . > /tmp/Release && mv /tmp/Release Release")
+	assert.Contains(t, script, "gpg --batch --yes --clearsign -o InRelease Release")
+	assert.Contains(t, script, "chown -R 1000:1000 /repo")
+
+	script = mirrorScript(mirrorOSes["rhel8"], "")
+	assert.Contains(t, script, "yumdownloader -q --resolve --destdir /repo \"$p\"")
+	assert.Contains(t, script, "createrepo_c -q /repo")
+	assert.Contains(t, script, "gpg --batch --yes --armor --detach-sign /repo/repodata/repomd.xml")
+	assert.Contains(t, script, "cat /etc/pki/rpm-gpg/RPM-GPG-KEY-* >> /repo/pf9ctl-mirror.asc")
+	assert.NotContains(t, script, "chown")
+}
+
+func TestCreateMirror(t *testing.T) {
+	type want struct {
+		missing []string
+		err     string
+	}
+	tcs := map[string]struct {
+		osName  string
+		runtime string
+		out     string
+		want    want
+	}{
+		"built": {
+			osName:  "ubuntu20",
+			runtime: "/usr/bin/docker\n",
+			out:     "missing systemd-timesyncd\n",
+			want:    want{missing: []string{"systemd-timesyncd"}},
+		},
+		"podman": {
+			osName:  "centos7",
+			runtime: "/usr/bin/podman\n",
+			want:    want{},
+		},
+		"invalid os": {
+			osName: "windows",
+			want:   want{err: `invalid OS "windows", use one of centos7, rhel8, ubuntu18, ubuntu20, ubuntu22`},
+		},
+		"no runtime": {
+			osName: "ubuntu22",
+			want:   want{err: "docker or podman is needed to download the packages in a container of the OS"},
+		},
+		"nothing downloaded": {
+			osName:  "rhel8",
+			runtime: "/usr/bin/docker\n",
+			out:     "missing " + strings.Join(el8MirrorPackages, "\nmissing ") + "\n",
+			want:    want{missing: el8MirrorPackages, err: "none of the packages could be downloaded in rockylinux:8, check the container reaches the repos of the OS"},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "key.asc")
+	repoDir := filepath.Join(dir, "repo")
+	if err := os.Mkdir(repoDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var ran []string
+			exec := &cmdexec.MockExecutor{
+				MockRunArgs: func(name string, args ...string) (string, error) {
+					if name == "bash" {
+						if tc.runtime == "" {
+							return "", fmt.Errorf("exit status 1")
+						}
+						return tc.runtime, nil
+					}
+					ran = append([]string{name}, args[:7]...)
+					return tc.out, nil
+				},
+			}
+			missing, err := CreateMirror(exec, tc.osName, repoDir, "", keyFile)
+			if tc.want.err != "" {
+				assert.EqualError(t, err, tc.want.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, []string{strings.TrimSpace(tc.runtime), "run", "--rm", "-v", repoDir + ":/repo:z",
+					"-v", keyFile + ":/pf9ctl-mirror-key.asc:ro,z", mirrorOSes[tc.osName].image}, ran)
+				assert.FileExists(t, filepath.Join(repoDir, MirrorKeyName))
+			}
+			assert.Equal(t, tc.want.missing, missing)
+		})
+	}
+}
+
+func TestPackageRepo(t *testing.T) {
+	assert.NoError(t, ValidatePackageRepo("http://10.0.0.5/repo"))
+	assert.NoError(t, ValidatePackageRepo("file:///srv/repo"))
+	assert.Error(t, ValidatePackageRepo("ftp://10.0.0.5/repo"))
+	assert.Error(t, ValidatePackageRepo("/srv/repo"))
+
+	script := packageRepoScript("http://10.0.0.5/repo", true)
+	assert.Contains(t, script, `echo 'deb [signed-by=/etc/apt/keyrings/pf9ctl-mirror.asc] http://10.0.0.5/repo ./' > /etc/apt/sources.list.d/pf9ctl-mirror.list`)
+	assert.NotContains(t, script, "trusted=yes")
+
+	script = packageRepoScript("http://10.0.0.5/repo", false)
+	assert.Contains(t, script, "rpm --import /etc/pki/rpm-gpg/RPM-GPG-KEY-pf9ctl-mirror")
+	assert.Contains(t, script, "baseurl=http://10.0.0.5/repo\n")
+	assert.Contains(t, script, "gpgcheck=1\nrepo_gpgcheck=1\ngpgkey=file:///etc/pki/rpm-gpg/RPM-GPG-KEY-pf9ctl-mirror\n")
+	assert.Contains(t, script, "yum makecache -q -y --disablerepo='*' --enablerepo=pf9ctl-mirror")
+}
+
+func TestMirrorKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "db", "key.asc")
+
+	public, err := LoadMirrorKey(keyFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(public), "BEGIN PGP PUBLIC KEY BLOCK")
+	assert.NotContains(t, string(public), "PRIVATE")
+
+	// The key is generated once
+	again, err := LoadMirrorKey(keyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, public, again)
+
+	publicFile := filepath.Join(dir, MirrorKeyName)
+	assert.NoError(t, ioutil.WriteFile(publicFile, public, 0644))
+	assert.NoError(t, ValidatePackageRepoKey(publicFile))
+	assert.Error(t, ValidatePackageRepoKey(filepath.Join(dir, "missing.asc")))
+
+	invalid := filepath.Join(dir, "invalid.asc")
+	assert.NoError(t, ioutil.WriteFile(invalid, []byte("not a key"), 0644))
+	assert.Error(t, ValidatePackageRepoKey(invalid))
+}
@@ -0,0 +1,169 @@
+package pmk
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Bounds of the path MTU probe: the minimum MTU of IPv4 and jumbo frames
+const (
+	minProbeMTU = 576
+	maxProbeMTU = 9000
+)
+
+// defaultRouteMTU reads the MTU of the interface of the default route
+const defaultRouteMTU = `cat /sys/class/net/$(ip route show default | awk '{for (i = 1; i < NF; i++) if ($i == "dev") {print $(i + 1); exit}}')/mtu`
+
+// Headers which ping adds to its payload, IPv4 and ICMP or IPv6 and ICMPv6
+const (
+	icmpOverhead   = 28
+	icmpv6Overhead = 48
+)
+
+// Overheads of the overlays the CNIs encapsulate pod traffic with
+const (
+	IPIPOverhead  = 20
+	VXLANOverhead = 50
+)
+
+// OverlayOverhead returns the bytes the overlay of plugin adds to the packets
+// between nodes and its name. Calico encapsulates in IP-in-IP unless
+// ipEncapsulation is Never, flannel in VXLAN.
+func OverlayOverhead(plugin, ipEncapsulation string) (int, string, error) {
+	switch strings.ToLower(plugin) {
+	case string(Calico):
+		switch strings.ToLower(ipEncapsulation) {
+		case "", "always", "crosssubnet":
+			return IPIPOverhead, "IP-in-IP", nil
+		case "never":
+			return 0, "no encapsulation", nil
+		}
+		return 0, "", fmt.Errorf("unknown IP encapsulation %q, it is Always, CrossSubnet or Never", ipEncapsulation)
+	case string(Flannel):
+		return VXLANOverhead, "VXLAN", nil
+	}
+	return 0, "", fmt.Errorf("unknown network plugin %q, it is calico or flannel", plugin)
+}
+
+// PathMTU is the effective MTU of the path from a node to a peer or the DU
+type PathMTU struct {
+	From string
+	To   string
+	// ToDU is set for the path to the DU, which pod traffic takes without
+	// the overlay
+	ToDU bool
+	MTU  int
+	// Err is why the MTU couldn't be measured
+	Err error
+}
+
+// InterfaceMTU returns the MTU of the interface of the default route of the
+// node of exec
+func InterfaceMTU(exec cmdexec.Executor) (int, error) {
+	out, err := exec.RunWithStdout("bash", "-c", defaultRouteMTU)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read the MTU of the interface: %w", err)
+	}
+	mtu, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return 0, fmt.Errorf("unable to read the MTU of the interface from %q", strings.TrimSpace(out))
+	}
+	return mtu, nil
+}
+
+// ProbePathMTU measures the effective MTU from the node of exec to target by
+// sending ping packets which must not be fragmented, up to max bytes
+func ProbePathMTU(exec cmdexec.Executor, target string, max int) (int, error) {
+	if max <= 0 || max > maxProbeMTU {
+		max = maxProbeMTU
+	}
+	if !pingFits(exec, target, minProbeMTU) {
+		return 0, fmt.Errorf("%s doesn't answer ping, ICMP may be blocked", target)
+	}
+	// The largest size which fits is searched between the sizes which fit
+	// and don't
+	fits, tooBig := minProbeMTU, max+1
+	for tooBig-fits > 1 {
+		size := (fits + tooBig) / 2
+		if pingFits(exec, target, size) {
+			fits = size
+		} else {
+			tooBig = size
+		}
+	}
+	return fits, nil
+}
+
+// pingFits is true when a packet of mtu bytes reaches target unfragmented
+func pingFits(exec cmdexec.Executor, target string, mtu int) bool {
+	overhead := icmpOverhead
+	if util.IsIPv6(target) {
+		overhead = icmpv6Overhead
+	}
+	_, err := exec.RunArgs("ping", "-c", "1", "-W", "2", "-M", "do", "-s", fmt.Sprint(mtu-overhead), target)
+	if err != nil {
+		zap.S().Debugf("Packet of %d bytes doesn't reach %s: %s", mtu, target, err)
+	}
+	return err == nil
+}
+
+// DUHost returns the host of the DU at fqdn
+func DUHost(fqdn string) string {
+	if u, err := url.Parse(fqdn); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return strings.TrimSuffix(fqdn, "/")
+}
+
+// MTUReport compares the path MTUs to the MTU the pods are given and the
+// overlay between nodes
+type MTUReport struct {
+	Paths    []PathMTU
+	PodMTU   int
+	Overhead int
+}
+
+// Required returns the MTU path needs: the pod MTU with the overlay between
+// nodes, the pod MTU alone to the DU
+func (r MTUReport) Required(path PathMTU) int {
+	if path.ToDU {
+		return r.PodMTU
+	}
+	return r.PodMTU + r.Overhead
+}
+
+// TooSmall returns the measured paths whose MTU is below what they need
+func (r MTUReport) TooSmall() []PathMTU {
+	var paths []PathMTU
+	for _, path := range r.Paths {
+		if path.Err == nil && path.MTU < r.Required(path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// SuggestedMTU returns the largest pod MTU all the measured paths carry, 0
+// when no path was measured
+func (r MTUReport) SuggestedMTU() int {
+	suggested := 0
+	for _, path := range r.Paths {
+		if path.Err != nil {
+			continue
+		}
+		mtu := path.MTU
+		if !path.ToDU {
+			mtu -= r.Overhead
+		}
+		if suggested == 0 || mtu < suggested {
+			suggested = mtu
+		}
+	}
+	return suggested
+}
@@ -0,0 +1,88 @@
+package pmk
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverlayOverhead(t *testing.T) {
+	cases := map[string]struct {
+		plugin, encapsulation string
+		want                  int
+		wantErr               bool
+	}{
+		"CalicoIPIP":        {"calico", "Always", IPIPOverhead, false},
+		"CalicoCrossSubnet": {"calico", "CrossSubnet", IPIPOverhead, false},
+		"CalicoNever":       {"calico", "Never", 0, false},
+		"Flannel":           {"flannel", "", VXLANOverhead, false},
+		"UnknownMode":       {"calico", "Sometimes", 0, true},
+		"UnknownPlugin":     {"weave", "", 0, true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			overhead, _, err := OverlayOverhead(tc.plugin, tc.encapsulation)
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.want, overhead)
+		})
+	}
+}
+
+// pathExecutor answers ping as a path carrying mtu bytes
+func pathExecutor(mtu int) *cmdexec.MockExecutor {
+	return &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			size, _ := strconv.Atoi(args[len(args)-2])
+			overhead := icmpOverhead
+			if util.IsIPv6(args[len(args)-1]) {
+				overhead = icmpv6Overhead
+			}
+			if size+overhead > mtu {
+				return "", errors.New("exit status 1")
+			}
+			return "", nil
+		},
+	}
+}
+
+func TestProbePathMTU(t *testing.T) {
+	for _, mtu := range []int{1500, 1450, 1280, 9000} {
+		got, err := ProbePathMTU(pathExecutor(mtu), "10.0.0.2", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, mtu, got)
+	}
+
+	got, err := ProbePathMTU(pathExecutor(9000), "10.0.0.2", 1500)
+	assert.NoError(t, err)
+	assert.Equal(t, 1500, got)
+
+	got, err = ProbePathMTU(pathExecutor(1500), "fd00::2", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1500, got)
+
+	_, err = ProbePathMTU(pathExecutor(0), "10.0.0.2", 0)
+	assert.EqualError(t, err, "10.0.0.2 doesn't answer ping, ICMP may be blocked")
+}
+
+func TestMTUReport(t *testing.T) {
+	r := MTUReport{
+		PodMTU:   1440,
+		Overhead: IPIPOverhead,
+		Paths: []PathMTU{
+			{From: "10.0.0.1", To: "10.0.0.2", MTU: 1500},
+			{From: "10.0.0.1", To: "10.0.0.3", MTU: 1450},
+			{From: "10.0.0.1", To: "du.example.com", ToDU: true, MTU: 1400},
+			{From: "10.0.0.2", To: "10.0.0.3", Err: errors.New("ICMP blocked")},
+		},
+	}
+	assert.Equal(t, []PathMTU{r.Paths[1], r.Paths[2]}, r.TooSmall())
+	assert.Equal(t, 1400, r.SuggestedMTU())
+
+	assert.Equal(t, 0, MTUReport{}.SuggestedMTU())
+	assert.Equal(t, "du.example.com", DUHost("https://du.example.com/"))
+	assert.Equal(t, "du.example.com", DUHost("du.example.com"))
+}
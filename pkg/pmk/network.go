@@ -1,17 +1,18 @@
 package pmk
 
 import (
-	"log"
+	"fmt"
 	"net"
 )
 
-func GetIp() net.IP {
+// GetIp returns the IP of this machine the default route goes through
+func GetIp() (net.IP, error) {
 	conn, err := net.Dial("udp", "8.8.8.8:80")
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("unable to find the IP of this machine: %w", err)
 	}
 	defer conn.Close()
 
 	localAddr := conn.LocalAddr().(*net.UDPAddr)
-	return localAddr.IP
+	return localAddr.IP, nil
 }
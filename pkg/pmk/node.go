@@ -2,20 +2,25 @@
 package pmk
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/briandowns/spinner"
 	"github.com/platform9/pf9ctl/pkg/client"
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
-	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/interrupt"
 	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/log"
 	"github.com/platform9/pf9ctl/pkg/objects"
 	"github.com/platform9/pf9ctl/pkg/platform"
 	"github.com/platform9/pf9ctl/pkg/platform/centos"
 	"github.com/platform9/pf9ctl/pkg/platform/debian"
+	"github.com/platform9/pf9ctl/pkg/ui"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"go.uber.org/zap"
 )
@@ -23,7 +28,16 @@ import (
 // This variable is assigned with StatusCode during hostagent installation
 var HostAgent int
 var IsRemoteExecutor bool
-var homeDir string
+
+// workDir is where the installer is downloaded and extracted, set by prepareWorkDir
+var workDir string
+
+// MinWorkDirSpace is the free space in MB needed in the work directory to
+// download and extract the installer
+const MinWorkDirSpace = 500
+
+// minWorkDirInodes is the number of free inodes needed to extract the installer
+const minWorkDirInodes = 10000
 
 const (
 	// Response Status Codes
@@ -31,48 +45,116 @@ const (
 	HostAgentLegacy   = 404
 )
 
-// Sends an event to segment based on the input string and uses auth as keystone UUID property.
-func sendSegmentEvent(allClients client.Client, eventStr string, auth keystone.KeystoneAuth, isError bool) {
-
-	var errorStr, suffixStr, status string
+// PrepNode sets up prerequisites for k8s stack
+func PrepNode(ctx objects.Config, allClients client.Client, auth keystone.KeystoneAuth) error {
+	return PrepNodeContext(context.Background(), ctx, allClients, auth)
+}
 
-	if isError {
-		status = "FAIL"
-		errorStr = eventStr
-		suffixStr = "Prep-node : ERROR"
+// PrepNodeContext is PrepNode stopping before its next phase once runCtx is
+// done, with the error of runCtx
+func PrepNodeContext(runCtx context.Context, ctx objects.Config, allClients client.Client, auth keystone.KeystoneAuth) error {
+	zap.S().Debug("Received a call to start preparing node(s).")
+	phase := ui.StartPhase("Starting prep-node")
+	defer phase.Stop()
+	events := newPhaseTracker(allClients, auth, segmentEventPrep, "prep-node")
+	if multiple, err := ParsePhaseBudget(ctx.PhaseBudget); err != nil {
+		zap.S().Debugf("%s, using the default phase budget", err)
+		events.watchBudgets(DefaultPhaseBudget)
 	} else {
-		status = "PASS"
-		errorStr = ""
-		suffixStr = "Prep-node : " + eventStr
+		events.watchBudgets(multiple)
+	}
+	events.show(phase)
+	events.begin(phaseStart)
+	events.pass()
+
+	// Once runCtx is done, the connection to the node is closed to stop the
+	// command running on it. An interrupted command waits for the cleanups
+	// below.
+	defer interrupt.Hold()()
+	stopped, closed := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(closed)
+		select {
+		case <-runCtx.Done():
+			cmdexec.Close(allClients.Executor)
+		case <-stopped:
+		}
+	}()
+	defer func() {
+		close(stopped)
+		<-closed
+	}()
+	// An interrupted prep-node reports the phase it was in and records the
+	// phases done, the next prep-node of the node resumes after them. An
+	// interrupted installer is stopped and removed. The node is unreachable
+	// while it reboots, and the reboot is resumed anyway.
+	interrupted := func() error {
+		<-closed
+		current, passed := events.progress()
+		events.fail(ErrInterrupted)
+		allClients.Segment.Close()
+		if current == phaseReboot {
+			return runCtx.Err()
+		}
+		if _, remote := allClients.Executor.(*cmdexec.RemoteExecutor); remote {
+			if err := cmdexec.Reconnect(allClients.Executor); err != nil {
+				zap.S().Debugf("Unable to reach the node to clean up: %s", err)
+				return runCtx.Err()
+			}
+		}
+		if current == phaseInstallAgent {
+			stopInstaller(allClients.Executor)
+			removeTempDirAndInstaller(allClients.Executor)
+		}
+		if err := recordInterruption(allClients.Executor, passed, current); err != nil {
+			zap.S().Debugf("%s", err.Error())
+		}
+		return runCtx.Err()
 	}
-
-	if err := allClients.Segment.SendEvent(suffixStr, auth, status, errorStr); err != nil {
-		zap.S().Debugf("Unable to send Segment event for Node prep. Error: %s", err.Error())
+	// fail reports the failure of the current phase, closing segment as the
+	// command exits with Fatalf
+	fail := func(err error) error {
+		if runCtx.Err() != nil {
+			return interrupted()
+		}
+		events.fail(err)
+		allClients.Segment.Close()
+		return err
 	}
-
-	// Close the segment for error path. Cmd level closure does not work due to FatalF.
-	if isError {
-		defer allClients.Segment.Close()
+	// begin starts the next phase unless runCtx is done
+	begin := func(phase string) error {
+		if err := runCtx.Err(); err != nil {
+			return err
+		}
+		events.begin(phase)
+		return nil
+	}
+	if err := begin(phaseValidateOS); err != nil {
+		return fail(err)
 	}
-}
-
-// PrepNode sets up prerequisites for k8s stack
-func PrepNode(ctx objects.Config, allClients client.Client, auth keystone.KeystoneAuth) error {
-	// Building our new spinner
-	s := spinner.New(spinner.CharSets[9], 100*time.Millisecond)
-	s.Color("red")
-
-	zap.S().Debug("Received a call to start preparing node(s).")
-	s.Start() // Start the spinner
-	defer s.Stop()
-	sendSegmentEvent(allClients, "Starting prep-node", auth, false)
-	s.Suffix = " Starting prep-node"
-
 	hostOS, err := ValidatePlatform(allClients.Executor)
 	if err != nil {
-		errStr := "Error: Invalid host OS. " + err.Error()
-		sendSegmentEvent(allClients, errStr, auth, true)
-		return fmt.Errorf(errStr)
+		return fail(fmt.Errorf("Error: Invalid host OS. %w", err))
+	}
+	events.pass()
+
+	// The node rebooted since prep-node stopped to reboot it, or prep-node
+	// was interrupted, the phases done before aren't done again
+	resumed := readCheckpoint(allClients.Executor)
+	if resumed != nil && resumed.Interrupted != "" {
+		clearReboot(allClients.Executor)
+		phase.Step(fmt.Sprintf("Resuming prep-node interrupted during %s", resumed.Interrupted))
+	} else if resumed != nil {
+		if err := begin(phaseReboot); err != nil {
+			return fail(err)
+		}
+		phase.Update("Verifying the reboot of the node")
+		if err := verifyReboot(allClients.Executor, resumed); err != nil {
+			return fail(fmt.Errorf("Error: The node rebooted but %w", err))
+		}
+		clearReboot(allClients.Executor)
+		phase.Step("Node rebooted, resuming prep-node")
+		events.pass()
 	}
 
 	if hostOS == "debian" {
@@ -98,84 +180,186 @@ func PrepNode(ctx objects.Config, allClients client.Client, auth keystone.Keysto
 		}
 	}
 
-	present := pf9PackagesPresent(hostOS, allClients.Executor)
-	if present {
+	// The environment is detected by the checks run before prep-node
+	if util.NodeEnvironment == "" {
+		util.NodeEnvironment = DetectEnvironment(allClients.Executor)
+	}
+	if err := recordEnvironment(allClients.Executor, util.NodeEnvironment); err != nil {
+		zap.S().Debugf("%s", err.Error())
+	}
+
+	if err := begin(phaseHostID); err != nil {
+		return fail(err)
+	}
+	if resumed.done(phaseHostID) {
+		zap.S().Debug("Host ID checked before the reboot")
+	} else if util.RegenerateHostID {
+		phase.Update("Regenerating host ID")
+		if err := regenerateHostID(allClients.Executor); err != nil {
+			return fail(fmt.Errorf("Error: Unable to regenerate host ID. %w", err))
+		}
+		phase.Step("Regenerated host ID")
+	} else if hostID := readHostID(allClients.Executor); hostID != "" {
+		if err := checkHostIDConflict(allClients, auth, hostID); err != nil {
+			events.fail(fmt.Errorf("Error: Host ID conflict. %w", err))
+			allClients.Segment.Close()
+			return err
+		}
+	}
+	events.pass()
+
+	if err := begin(phaseExistingPkgs); err != nil {
+		return fail(err)
+	}
+	// The packages found after an interruption are the ones it installed
+	if resumed.done(phaseExistingPkgs) {
+		zap.S().Debug("Existing packages checked before the interruption")
+	} else if pf9PackagesPresent(hostOS, allClients.Executor) {
 		errStr := "\n\nPlatform9 packages already present on the host." +
 			"\nPlease uninstall these packages if you want to prep the node again.\n" +
 			"Instructions to uninstall these are at:" +
 			"\nhttps://docs.platform9.com/kubernetes/pmk-cli-unistall-hostagent"
-		sendSegmentEvent(allClients, "Error: Platform9 packages already present.", auth, true)
+		events.fail(errors.New("Error: Platform9 packages already present."))
+		allClients.Segment.Close()
 		return fmt.Errorf(errStr)
 	}
+	events.pass()
 
-	sendSegmentEvent(allClients, "Installing hostagent - 2", auth, false)
-	s.Suffix = " Downloading the Hostagent (this might take a few minutes...)"
-	if err := installHostAgent(ctx, auth, hostOS, allClients.Executor); err != nil {
-		errStr := "Error: Unable to install hostagent. " + err.Error()
-		sendSegmentEvent(allClients, errStr, auth, true)
-		return fmt.Errorf(errStr)
+	done := []string{phaseValidateOS, phaseHostID, phaseExistingPkgs}
+	if RemoveExistingRuntime && !resumed.done(phaseRemoveRuntime) {
+		if err := begin(phaseRemoveRuntime); err != nil {
+			return fail(err)
+		}
+		runtimes, err := DetectRuntimes(allClients.Executor)
+		if err != nil {
+			return fail(fmt.Errorf("Error: Unable to remove the container runtimes. %w", err))
+		}
+		if len(runtimes) > 0 {
+			phase.Update("Removing " + runtimeNames(runtimes))
+			if err := RemoveRuntimes(allClients.Executor, runtimes); err != nil {
+				return fail(fmt.Errorf("Error: Unable to remove the container runtimes. %w", err))
+			}
+			phase.Step("Removed " + runtimeNames(runtimes))
+		}
+		events.pass()
+		done = append(done, phaseRemoveRuntime)
+	}
+	if util.NodeRole != "" && !resumed.done(phaseKernelTuning) {
+		if err := begin(phaseKernelTuning); err != nil {
+			return fail(err)
+		}
+		phase.Update(fmt.Sprintf("Tuning the kernel for the %s role", util.NodeRole))
+		if err := applyRoleSysctls(allClients.Executor, util.NodeRole); err != nil {
+			return fail(fmt.Errorf("Error: Unable to tune the kernel. %w", err))
+		}
+		phase.Step(fmt.Sprintf("Kernel tuned for the %s role", util.NodeRole))
+		events.pass()
+		done = append(done, phaseKernelTuning)
+	}
+
+	if resumed == nil || resumed.Interrupted != "" {
+		if reasons, err := RebootReasons(allClients.Executor); err != nil {
+			zap.S().Debugf("%s", err.Error())
+		} else if len(reasons) > 0 && !util.AllowReboot {
+			phase.Warn(rebootRequiredMessage(reasons))
+		} else if len(reasons) > 0 {
+			if err := begin(phaseReboot); err != nil {
+				return fail(err)
+			}
+			phase.Update("Rebooting the node (this might take a few minutes...)")
+			if err := rebootForPrep(allClients.Executor, done, reasons); err != nil {
+				return fail(fmt.Errorf("Error: Unable to reboot the node. %w", err))
+			}
+			clearReboot(allClients.Executor)
+			phase.Step("Node rebooted: " + strings.Join(reasons, ", "))
+			events.pass()
+		}
 	}
 
-	s.Suffix = " Platform9 packages installed successfully"
+	if err := begin(phaseInstallAgent); err != nil {
+		return fail(err)
+	}
+	if resumed.done(phaseInstallAgent) {
+		phase.Step("Hostagent installed before the interruption")
+	} else {
+		phase.Update("Downloading the Hostagent (this might take a few minutes...)")
+		// The installer is removed once it ran, an interrupted one is removed
+		// by interrupted
+		if err := installHostAgent(ctx, auth, hostOS, allClients.Executor); err != nil {
+			return fail(fmt.Errorf("Error: Unable to install hostagent. %w", err))
+		}
+	}
+	events.pass()
 
 	if HostAgent == HostAgentCertless {
-		s.Suffix = " Platform9 packages installed successfully"
-		s.Stop()
-		fmt.Println(color.Green("✓ ") + "Platform9 packages installed successfully")
+		phase.Succeed("Platform9 packages installed successfully")
 	} else if HostAgent == HostAgentLegacy {
-		s.Suffix = " Hostagent installed successfully"
-		s.Stop()
-		fmt.Println(color.Green("✓ ") + "Hostagent installed successfully")
+		phase.Succeed("Hostagent installed successfully")
+	} else {
+		phase.Stop()
 	}
-	s.Restart()
 
-	sendSegmentEvent(allClients, "Initialising host - 3", auth, false)
-	s.Suffix = " Initialising host"
+	phase = ui.StartPhase("Initialising host")
+	defer phase.Stop()
+	events.show(phase)
+	if err := begin(phaseInitialise); err != nil {
+		return fail(err)
+	}
 	zap.S().Debug("Initialising host")
 	zap.S().Debug("Identifying the hostID from conf")
 	cmd := `grep host_id /etc/pf9/host_id.conf | cut -d '=' -f2`
 	output, err := allClients.Executor.RunWithStdout("bash", "-c", cmd)
 	output = strings.TrimSpace(output)
 	if err != nil || output == "" {
-		errStr := "Error: Unable to fetch host ID. " + err.Error()
-		sendSegmentEvent(allClients, errStr, auth, true)
-		return fmt.Errorf(errStr)
+		if err == nil {
+			err = errors.New("empty host ID")
+		}
+		return fail(fmt.Errorf("Error: Unable to fetch host ID. %w", err))
 	}
 
-	s.Stop()
-	fmt.Println(color.Green("✓ ") + "Initialised host successfully")
+	phase.Succeed("Initialised host successfully")
 	zap.S().Debug("Initialised host successfully")
+	events.pass()
 	if util.SkipKube {
 		zap.S().Debug("Skip authorizing host as --skip-kube flag is true")
-		sendSegmentEvent(allClients, "Successful", auth, false)
+		events.complete()
 		return nil
 	}
 
-	s.Restart()
-	s.Suffix = " Authorising host"
+	phase = ui.StartPhase("Authorising host")
+	defer phase.Stop()
+	events.show(phase)
 	zap.S().Debug("Authorising host")
 	hostID := strings.TrimSuffix(output, "\n")
-	time.Sleep(ctx.WaitPeriod * time.Second)
+	if err := begin(phaseAuthorise); err != nil {
+		return fail(err)
+	}
+	select {
+	case <-time.After(ctx.WaitPeriod * time.Second):
+	case <-runCtx.Done():
+		return fail(runCtx.Err())
+	}
 
 	if err := allClients.Resmgr.AuthorizeHost(hostID, auth.Token); err != nil {
-		errStr := "Error: Unable to authorise host. " + err.Error()
-		sendSegmentEvent(allClients, errStr, auth, true)
-		return fmt.Errorf(errStr)
+		return fail(fmt.Errorf("Error: Unable to authorise host. %w", err))
+	}
+	if ReuseRuntime {
+		if err := ReuseNodeRuntime(ctx.Fqdn, auth, hostID, allClients.Executor); err != nil {
+			return fail(fmt.Errorf("Error: Unable to keep the container runtime of the node. %w", err))
+		}
 	}
 
 	zap.S().Debug("Host successfully attached to the Platform9 control-plane")
-	s.Suffix = " Host successfully attached to the Platform9 control-plane"
-	sendSegmentEvent(allClients, "Successful", auth, false)
-	s.Stop()
-
-	fmt.Println(color.Green("✓ ") + "Host successfully attached to the Platform9 control-plane")
+	events.pass()
+	events.complete()
+	phase.Succeed("Host successfully attached to the Platform9 control-plane")
 
 	return nil
 }
 
 func StatusUnattendedUpdates(allClients client.Client) bool {
 	zap.S().Debug("Checking Status of unattended-upgrades")
-	output, err := allClients.Executor.RunWithStdout("bash", "-c", "systemctl is-active unattended-upgrades")
+	output, err := allClients.Executor.RunArgs("systemctl", "is-active", "unattended-upgrades")
 	if err != nil {
 		zap.S().Debugf("Failed to check unattended-upgrades : %s", err)
 	}
@@ -185,7 +369,7 @@ func StatusUnattendedUpdates(allClients client.Client) bool {
 
 func StopUnattendedUpdates(allClients client.Client) {
 	zap.S().Debug("Stopping unattended-upgrades")
-	_, err := allClients.Executor.RunWithStdout("bash", "-c", "systemctl stop unattended-upgrades")
+	_, err := allClients.Executor.RunArgs("systemctl", "stop", "unattended-upgrades")
 	if err != nil {
 		zap.S().Debugf("Failed to stop unattended-upgrades : %s", err)
 	} else {
@@ -195,7 +379,7 @@ func StopUnattendedUpdates(allClients client.Client) {
 
 func StartUnattendedUpdates(allClients client.Client) {
 	zap.S().Debug("Start unattended-upgrades")
-	_, err := allClients.Executor.RunWithStdout("bash", "-c", "systemctl start unattended-upgrades")
+	_, err := allClients.Executor.RunArgs("systemctl", "start", "unattended-upgrades")
 	if err != nil {
 		zap.S().Debugf("Failed to start unattended-upgrades : %s", err)
 	} else {
@@ -205,7 +389,7 @@ func StartUnattendedUpdates(allClients client.Client) {
 
 func IsEnabledUnattendedUpdates(allClients client.Client) bool {
 	zap.S().Debug("Checking if unattended-upgrades is enabled")
-	output, err := allClients.Executor.RunWithStdout("bash", "-c", "systemctl is-enabled unattended-upgrades")
+	output, err := allClients.Executor.RunArgs("systemctl", "is-enabled", "unattended-upgrades")
 	if err != nil {
 		zap.S().Debugf("Failed to check unattended-upgrades is enabled : %s", err)
 	}
@@ -215,7 +399,7 @@ func IsEnabledUnattendedUpdates(allClients client.Client) bool {
 
 func DisableUnattendedUpdates(allClients client.Client) {
 	zap.S().Debug("Disabling unattended-upgrades")
-	_, err := allClients.Executor.RunWithStdout("bash", "-c", "systemctl disable unattended-upgrades")
+	_, err := allClients.Executor.RunArgs("systemctl", "disable", "unattended-upgrades")
 	if err != nil {
 		zap.S().Debugf("Failed to disable unattended-upgrades : %s", err)
 	} else {
@@ -225,7 +409,7 @@ func DisableUnattendedUpdates(allClients client.Client) {
 
 func EnableUnattendedUpdates(allClients client.Client) {
 	zap.S().Debug("Enabling unattended-upgrades")
-	_, err := allClients.Executor.RunWithStdout("bash", "-c", "systemctl enable unattended-upgrades")
+	_, err := allClients.Executor.RunArgs("systemctl", "enable", "unattended-upgrades")
 	if err != nil {
 		zap.S().Debugf("Failed to enable unattended-upgrades : %s", err)
 	} else {
@@ -241,7 +425,9 @@ func installHostAgent(ctx objects.Config, auth keystone.KeystoneAuth, hostOS str
 		return fmt.Errorf("Unable to fetch URL: %w", err)
 	}
 
-	url := fmt.Sprintf("https://%s/clarity/platform9-install-%s.sh", regionURL, hostOS)
+	// The type of the hostagent is probed on the latest installer, the DU
+	// may not have the pinned version
+	url := installerScriptURL(regionURL, "clarity", hostOS, "")
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return fmt.Errorf("Unable to create a http request: %w", err)
@@ -252,68 +438,97 @@ func installHostAgent(ctx objects.Config, auth keystone.KeystoneAuth, hostOS str
 	if err != nil {
 		return fmt.Errorf("Unable to send a request to clientL %w", err)
 	}
+	var head []byte
+	if resp.StatusCode == http.StatusOK {
+		head = readInstallerHead(resp.Body)
+	}
+	// Only the head of the installer is needed, the rest isn't downloaded
+	resp.Body.Close()
+	// Proxies and captive portals answering in place of the DU would
+	// otherwise be taken for one of the hostagent types
+	if err := checkInstallerResponse(url, resp, head); err != nil {
+		return err
+	}
 	HostAgent = resp.StatusCode
 	switch resp.StatusCode {
 	case 404:
-		return installHostAgentLegacy(ctx, regionURL, auth, hostOS, exec)
+		err = installHostAgentLegacy(ctx, regionURL, auth, hostOS, exec)
 	case 200:
-		return installHostAgentCertless(ctx, regionURL, auth, hostOS, exec)
+		err = installHostAgentCertless(ctx, regionURL, auth, hostOS, exec)
 	default:
 		return fmt.Errorf("Invalid status code when identifiying hostagent type: %d", resp.StatusCode)
 	}
+	if err != nil {
+		return err
+	}
+	return checkHostagentVersion(exec, ctx.HostagentVersion)
 }
 
 func installHostAgentCertless(ctx objects.Config, regionURL string, auth keystone.KeystoneAuth, hostOS string, exec cmdexec.Executor) error {
 	zap.S().Debug("Downloading the installer (this might take a few minutes...)")
 
-	url := fmt.Sprintf(
-		"https://%s/clarity/platform9-install-%s.sh",
-		regionURL, hostOS)
-	insecureDownload := ""
+	url := installerScriptURL(regionURL, "clarity", hostOS, ctx.HostagentVersion)
+	download := []string{"--silent", "--show-error", "-H", correlationHeader()}
 	if ctx.AllowInsecure {
-		insecureDownload = "-k"
+		download = append(download, "-k")
 	}
+	download = append(download, curlLimitArgs(ctx.DownloadLimit)...)
 
-	homeDir, err := createDirToDownloadInstaller(exec)
+	workDir, err := prepareWorkDir(exec, ctx.WorkDir)
 	if err != nil {
 		return err
 	}
 
-	cmd := fmt.Sprintf(`curl %s --silent --show-error  %s -o  %s/pf9/installer.sh`, insecureDownload, url, homeDir)
-	_, err = exec.RunWithStdout("bash", "-c", cmd)
+	if ctx.Relay {
+		err = relayInstaller(exec, url, ctx.AllowInsecure, nil, workDir+"/installer.sh")
+	} else {
+		_, err = exec.RunArgs("curl", append(download, url, "-o", workDir+"/installer.sh")...)
+	}
 	if err != nil {
 		return err
 	}
+	if err := checkInstallerFile(exec, url, workDir+"/installer.sh"); err != nil {
+		removeTempDirAndInstaller(exec)
+		return err
+	}
+	if err := checkInstallerVersion(exec, url, workDir+"/installer.sh", ctx.HostagentVersion); err != nil {
+		removeTempDirAndInstaller(exec)
+		return err
+	}
+	if !ctx.SkipSignatureVerify {
+		if err := verifyInstallerSignature(exec, url, ctx.AllowInsecure, nil, workDir+"/installer.sh"); err != nil {
+			removeTempDirAndInstaller(exec)
+			return err
+		}
+	}
 	zap.S().Debug("Hostagent download completed successfully")
 
 	var installOptions string
 
-	//Pass keystone token if MFA token is provided
-	if ctx.MfaToken != "" {
-		installOptions = fmt.Sprintf(`--no-project --controller=%s  --user-token='%s'`, regionURL, auth.Token)
+	//Pass keystone token if MFA token or an onboarding token is provided
+	if ctx.MfaToken != "" || ctx.ApplicationCredentialID != "" {
+		installOptions = fmt.Sprintf(`--no-project --controller=%s --user-token=%s`, regionURL, cmdexec.ShellQuote(auth.Token))
 	} else {
-		installOptions = fmt.Sprintf(`--no-project --controller=%s --username=%s --password='%s'`, regionURL, ctx.Username, ctx.Password)
+		installOptions = fmt.Sprintf(`--no-project --controller=%s --username=%s --password=%s`,
+			regionURL, cmdexec.ShellQuote(ctx.Username), cmdexec.ShellQuote(ctx.Password))
 	}
 
-	changePermission := fmt.Sprintf("chmod +x %s/pf9/installer.sh", homeDir)
-	_, err = exec.RunWithStdout("bash", "-c", changePermission)
+	_, err = exec.RunArgs("chmod", "+x", workDir+"/installer.sh")
 	if err != nil {
 		return err
 	}
 
+	var cmd string
 	if ctx.ProxyURL != "" {
-		cmd = fmt.Sprintf(`%s/pf9/installer.sh --proxy %s --skip-os-check --no-ntp`, homeDir, ctx.ProxyURL)
+		cmd = fmt.Sprintf(`%s/installer.sh --proxy %s --skip-os-check --no-ntp`, workDir, cmdexec.ShellQuote(ctx.ProxyURL))
 	} else {
-		cmd = fmt.Sprintf(`%s/pf9/installer.sh --no-proxy --skip-os-check --no-ntp`, homeDir)
+		cmd = fmt.Sprintf(`%s/installer.sh --no-proxy --skip-os-check --no-ntp`, workDir)
 	}
+	cmd += installerArgs(ctx.InstallerArgs)
 
-	if IsRemoteExecutor {
-		cmd = fmt.Sprintf(`bash %s %s`, cmd, installOptions)
-		_, err = exec.RunWithStdout(cmd)
-	} else {
-		cmd = fmt.Sprintf(`%s %s`, cmd, installOptions)
-		_, err = exec.RunWithStdout("bash", "-c", cmd)
-	}
+	// The credentials are passed through a script file so they aren't visible
+	// in the command line of sudo or of the SSH session
+	_, err = cmdexec.RunSecretScript(exec, fmt.Sprintf("%s bash %s %s\n", installerEnv(), cmd, installOptions))
 
 	removeTempDirAndInstaller(exec)
 
@@ -330,24 +545,42 @@ func installHostAgentCertless(ctx objects.Config, regionURL string, auth keyston
 	return nil
 }
 
+// installerEnv passes the correlation ID of the operation to the installer, so
+// that the logs it leaves on the node can be matched with the CLI and the DU
+func installerEnv() string {
+	return log.CorrelationIDEnv + "=" + cmdexec.ShellQuote(log.CorrelationID)
+}
+
+// correlationHeader is the header the downloads from the DU are requested with
+func correlationHeader() string {
+	return log.CorrelationIDHeader + ": " + log.CorrelationID
+}
+
+// stopInstaller kills the installer still running on the node of exec along
+// with the scripts it extracted
+func stopInstaller(exec cmdexec.Executor) {
+	for _, pattern := range []string{workDir + "/installer.sh", workDir + "/pf9-install-"} {
+		if _, err := exec.RunArgs("pkill", "-f", pattern); err != nil {
+			zap.S().Debugf("No process matching %s to stop", pattern)
+		}
+	}
+}
+
 func removeTempDirAndInstaller(exec cmdexec.Executor) {
 	zap.S().Debug("Removing temporary directory created to extract installer")
-	removeTmpDirCmd := fmt.Sprintf("rm -rf %s/pf9/pf9-install-*", homeDir)
-	_, err1 := exec.RunWithStdout("bash", "-c", removeTmpDirCmd)
+	_, err1 := exec.RunArgs("find", workDir, "-maxdepth", "1", "-name", "pf9-install-*", "-exec", "rm", "-rf", "{}", "+")
 	if err1 != nil {
 		zap.S().Debug("error removing temporary directory")
 	}
 
 	zap.S().Debug("Removing installer script")
-	removeInstallerCmd := fmt.Sprintf("rm -rf %s/pf9/installer.sh", homeDir)
-	_, err1 = exec.RunWithStdout("bash", "-c", removeInstallerCmd)
+	_, err1 = exec.RunArgs("rm", "-rf", workDir+"/installer.sh")
 	if err1 != nil {
 		zap.S().Debug("error removing installer script")
 	}
 
 	zap.S().Debug("Removing legacy installer script")
-	removeInstallerCmd = fmt.Sprintf("rm -rf %s/pf9/agent_install", homeDir)
-	_, err1 = exec.RunWithStdout("bash", "-c", removeInstallerCmd)
+	_, err1 = exec.RunArgs("rm", "-rf", workDir+"/agent_install")
 	if err1 != nil {
 		zap.S().Debug("error removing installer script")
 	}
@@ -419,39 +652,59 @@ func pf9PackagesPresent(hostOS string, exec cmdexec.Executor) bool {
 func installHostAgentLegacy(ctx objects.Config, regionURL string, auth keystone.KeystoneAuth, hostOS string, exec cmdexec.Executor) error {
 	zap.S().Debug("Downloading Hostagent Installer Legacy")
 
-	url := fmt.Sprintf("https://%s/private/platform9-install-%s.sh", regionURL, hostOS)
+	url := installerScriptURL(regionURL, "private", hostOS, ctx.HostagentVersion)
 
-	homeDir, err := createDirToDownloadInstaller(exec)
+	workDir, err := prepareWorkDir(exec, ctx.WorkDir)
 	if err != nil {
 		return err
 	}
 
-	installOptions := fmt.Sprintf("--insecure --project-name=%s 2>&1 | tee -a %s/pf9/agent_install", auth.ProjectID, homeDir)
+	installOptions := fmt.Sprintf("--insecure --project-name=%s 2>&1 | tee -a %s/agent_install", auth.ProjectID, workDir)
 	//use insecure by default
-	cmd := fmt.Sprintf(`curl --insecure --silent --show-error -H 'X-Auth-Token:%s' %s -o %s/pf9/installer.sh`, auth.Token, url, homeDir)
-	_, err = exec.RunWithStdout("bash", "-c", cmd)
+	cmd := fmt.Sprintf("curl --insecure --silent --show-error %s -H %s -H %s %s -o %s/installer.sh\n",
+		strings.Join(curlLimitArgs(ctx.DownloadLimit), " "), cmdexec.ShellQuote("X-Auth-Token:"+auth.Token),
+		cmdexec.ShellQuote(correlationHeader()), cmdexec.ShellQuote(url), workDir)
+	if ctx.Relay {
+		err = relayInstaller(exec, url, true, map[string]string{"X-Auth-Token": auth.Token}, workDir+"/installer.sh")
+	} else {
+		_, err = cmdexec.RunSecretScript(exec, cmd)
+	}
 	if err != nil {
 		return err
 	}
+	if err := checkInstallerFile(exec, url, workDir+"/installer.sh"); err != nil {
+		removeTempDirAndInstaller(exec)
+		return err
+	}
+	if err := checkInstallerVersion(exec, url, workDir+"/installer.sh", ctx.HostagentVersion); err != nil {
+		removeTempDirAndInstaller(exec)
+		return err
+	}
+	if !ctx.SkipSignatureVerify {
+		if err := verifyInstallerSignature(exec, url, true, map[string]string{"X-Auth-Token": auth.Token}, workDir+"/installer.sh"); err != nil {
+			removeTempDirAndInstaller(exec)
+			return err
+		}
+	}
 
 	zap.S().Debug("Hostagent download completed successfully")
-	changePermission := fmt.Sprintf("chmod +x %s/pf9/installer.sh", homeDir)
-	_, err = exec.RunWithStdout("bash", "-c", changePermission)
+	_, err = exec.RunArgs("chmod", "+x", workDir+"/installer.sh")
 	if err != nil {
 		return err
 	}
 
 	if ctx.ProxyURL != "" {
-		cmd = fmt.Sprintf(`%s/pf9/installer.sh --proxy %s --skip-os-check --no-ntp`, homeDir, ctx.ProxyURL)
+		cmd = fmt.Sprintf(`%s/installer.sh --proxy %s --skip-os-check --no-ntp`, workDir, cmdexec.ShellQuote(ctx.ProxyURL))
 	} else {
-		cmd = fmt.Sprintf(`%s/pf9/installer.sh --no-proxy --skip-os-check --no-ntp`, homeDir)
+		cmd = fmt.Sprintf(`%s/installer.sh --no-proxy --skip-os-check --no-ntp`, workDir)
 	}
+	cmd += installerArgs(ctx.InstallerArgs)
 
 	if IsRemoteExecutor {
-		cmd = fmt.Sprintf(`bash %s %s`, cmd, installOptions)
+		cmd = fmt.Sprintf(`%s bash %s %s`, installerEnv(), cmd, installOptions)
 		_, err = exec.RunWithStdout(cmd)
 	} else {
-		cmd = fmt.Sprintf(`%s %s`, cmd, installOptions)
+		cmd = fmt.Sprintf(`%s %s %s`, installerEnv(), cmd, installOptions)
 		_, err = exec.RunWithStdout("bash", "-c", cmd)
 	}
 
@@ -475,19 +728,70 @@ func CheckSudo(exec cmdexec.Executor) bool {
 	return err == nil
 }
 
-func createDirToDownloadInstaller(exec cmdexec.Executor) (string, error) {
-	var err error
-	homeDir, err = exec.RunWithStdout("bash", "-c", "echo $HOME")
-	homeDir = strings.TrimSpace(strings.Trim(homeDir, "\n\""))
+// prepareWorkDir creates the directory the installer is downloaded to, by
+// default $HOME/pf9, and checks it has enough free space and allows running
+// the installer from it.
+func prepareWorkDir(exec cmdexec.Executor, dir string) (string, error) {
+	dir, err := resolveWorkDir(exec, dir)
 	if err != nil {
 		return "", err
 	}
+	workDir = dir
+
 	//creating this dir because in remote case this dir will not be present for fresh vm
 	//for local case it will not cause any problem
-	cmd := fmt.Sprintf(`%s/pf9`, homeDir)
-	_, err = exec.RunWithStdout("mkdir", "-p", cmd)
+	if _, err = exec.RunArgs("mkdir", "-p", dir); err != nil {
+		return "", fmt.Errorf("unable to create work directory %s: %w", dir, err)
+	}
+
+	out, err := exec.RunArgs("df", "-Pm", dir)
+	if err != nil {
+		return "", fmt.Errorf("unable to get the free space of work directory %s: %w", dir, err)
+	}
+	// The last line of df is the filesystem of the directory
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return "", fmt.Errorf("unable to get the free space of work directory %s: unexpected df output %q", dir, out)
+	}
+	free, err := strconv.Atoi(fields[3])
 	if err != nil {
-		zap.S().Debugf("Directory exists")
+		return "", fmt.Errorf("unable to get the free space of work directory %s: unexpected df output %q", dir, out)
 	}
-	return homeDir, nil
+	if free < MinWorkDirSpace {
+		return "", fmt.Errorf("work directory %s has %d MB free, at least %d MB are needed, use --work-dir to change it", dir, free, MinWorkDirSpace)
+	}
+
+	// The installer is run from the work directory, which fails when its
+	// filesystem is mounted noexec
+	check := dir + "/.pf9ctl-exec-check"
+	_, err = exec.RunWithStdout("bash", "-c", fmt.Sprintf("printf '#!/bin/sh\\nexit 0\\n' > %[1]s && chmod +x %[1]s && %[1]s", check))
+	exec.RunArgs("rm", "-f", check)
+	if err != nil {
+		return "", fmt.Errorf("work directory %s doesn't allow running the installer, it may be on a noexec filesystem, use --work-dir to change it", dir)
+	}
+	return dir, nil
 }
+
+// resolveWorkDir returns dir, or $HOME/pf9 of the node when it is empty
+func resolveWorkDir(exec cmdexec.Executor, dir string) (string, error) {
+	if dir != "" {
+		return dir, ValidateWorkDir(dir)
+	}
+	home, err := exec.RunWithStdout("bash", "-c", "echo $HOME")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.Trim(home, "\n\"")) + "/pf9", nil
+}
+
+// ValidateWorkDir checks the work directory given by the user is an absolute
+// path which is safe to pass to the shell
+func ValidateWorkDir(dir string) error {
+	if !workDirRegexp.MatchString(dir) {
+		return fmt.Errorf("invalid work directory %q, it should be an absolute path made of letters, digits, '.', '_', '-' and '/'", dir)
+	}
+	return nil
+}
+
+var workDirRegexp = regexp.MustCompile(`^/[A-Za-z0-9._/-]*$`)
@@ -0,0 +1,229 @@
+package pmk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// DecommissionDeepClean also removes the network artifacts of the CNI plugins
+// and kube-proxy on decommission
+var DecommissionDeepClean bool
+
+// DecommissionCleanRuntime also removes the pods and images the container
+// runtime holds for PMK on decommission
+var DecommissionCleanRuntime bool
+
+// podNamespace is the containerd namespace of the containers and images of the
+// pods, the other users of containerd keep theirs in other namespaces
+const podNamespace = "k8s.io"
+
+// podMounts match the mounts of the volumes and the rootfs of the pods
+var podMounts = []string{"^/var/lib/kubelet/pods/", "^/run/containerd/io.containerd.runtime.v2.task/" + podNamespace + "/"}
+
+// cniInterfacePrefixes are the names of the network interfaces created by the
+// CNI plugins PMK deploys and by kube-proxy
+var cniInterfacePrefixes = []string{"cni0", "flannel.", "cali", "tunl0", "vxlan.calico", "kube-ipvs0", "kube-bridge", "nodelocaldns"}
+
+// cniChainPrefixes are the iptables chains of kube-proxy and the CNI plugins
+var cniChainPrefixes = []string{"KUBE-", "cali-", "CNI-", "FLANNEL"}
+
+// cniDirs hold the configuration and state of the CNI plugins
+var cniDirs = []string{"/etc/cni/net.d", "/var/lib/cni", "/var/lib/calico", "/run/flannel"}
+
+// NodeLeftovers is what remains of PMK on a node after decommission
+type NodeLeftovers struct {
+	Packages      []string
+	Services      []string
+	Interfaces    []string
+	IptablesRules int
+}
+
+// Empty reports whether nothing was left behind
+func (l NodeLeftovers) Empty() bool {
+	return len(l.Packages) == 0 && len(l.Services) == 0 && len(l.Interfaces) == 0 && l.IptablesRules == 0
+}
+
+// Network reports whether CNI network artifacts were left behind, which
+// --deep-clean removes
+func (l NodeLeftovers) Network() bool {
+	return len(l.Interfaces) > 0 || l.IptablesRules > 0
+}
+
+// List describes the leftovers, one line for each kind
+func (l NodeLeftovers) List() []string {
+	var list []string
+	if len(l.Packages) > 0 {
+		list = append(list, "Packages left installed: "+strings.Join(l.Packages, ", "))
+	}
+	if len(l.Services) > 0 {
+		list = append(list, "Services left: "+strings.Join(l.Services, ", "))
+	}
+	if len(l.Interfaces) > 0 {
+		list = append(list, "CNI network interfaces left: "+strings.Join(l.Interfaces, ", "))
+	}
+	if l.IptablesRules > 0 {
+		list = append(list, fmt.Sprintf("iptables rules of kube-proxy and CNI left: %d", l.IptablesRules))
+	}
+	return list
+}
+
+// findLeftovers looks for the packages, services, network interfaces and
+// iptables rules of PMK still on the node
+func findLeftovers(exec cmdexec.Executor, hostOS string) NodeLeftovers {
+	var l NodeLeftovers
+
+	// The package managers fail when no package matches, the output is
+	// parsed regardless
+	if hostOS == "debian" {
+		out, _ := exec.RunArgs("dpkg-query", "--show", "--showformat", `${Package} ${Status}\n`, "pf9-*")
+		for _, line := range strings.Split(out, "\n") {
+			// Removed packages are still listed with their config files
+			if fields := strings.Fields(line); len(fields) > 0 && strings.HasSuffix(line, " installed") {
+				l.Packages = append(l.Packages, fields[0])
+			}
+		}
+	} else {
+		out, _ := exec.RunArgs("rpm", "-qa", "pf9-*")
+		l.Packages = strings.Fields(out)
+	}
+
+	out, _ := exec.RunArgs("systemctl", "list-unit-files", "--no-legend", "pf9-*")
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			l.Services = append(l.Services, fields[0])
+		}
+	}
+
+	// Lines look like: 5: cali1234@if3: <BROADCAST,MULTICAST,UP> mtu 1440 ...
+	out, _ = exec.RunArgs("ip", "-o", "link", "show")
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		name := strings.SplitN(strings.TrimSuffix(fields[1], ":"), "@", 2)[0]
+		if hasAnyPrefix(name, cniInterfacePrefixes) {
+			l.Interfaces = append(l.Interfaces, name)
+		}
+	}
+
+	out, err := exec.RunArgs("iptables-save")
+	if err != nil {
+		zap.S().Debugf("Unable to list the iptables rules: %s", err)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.HasPrefix(line, "-A ") {
+			continue
+		}
+		for _, prefix := range cniChainPrefixes {
+			if strings.Contains(line, prefix) {
+				l.IptablesRules++
+				break
+			}
+		}
+	}
+	return l
+}
+
+// deepCleanNetwork removes the CNI network interfaces, the iptables rules of
+// kube-proxy and the CNI plugins and their configuration, so they don't
+// conflict with whatever runs on the node next
+func deepCleanNetwork(exec cmdexec.Executor, interfaces []string) error {
+	for _, name := range interfaces {
+		if _, err := exec.RunArgs("ip", "link", "delete", name); err != nil {
+			// Deleting an interface removes its peers and tunnels too
+			zap.S().Debugf("Unable to delete interface %s: %s", name, err)
+		}
+	}
+
+	var grep []string
+	for _, prefix := range cniChainPrefixes {
+		grep = append(grep, "-e", prefix)
+	}
+	script := fmt.Sprintf("iptables-save | grep -v %s | iptables-restore", strings.Join(grep, " "))
+	if _, err := exec.RunWithStdout("bash", "-c", script); err != nil {
+		return fmt.Errorf("unable to remove the iptables rules of kube-proxy and CNI: %w", err)
+	}
+
+	if _, err := exec.RunArgs("rm", append([]string{"-rf"}, cniDirs...)...); err != nil {
+		return fmt.Errorf("unable to remove the CNI configuration: %w", err)
+	}
+	return nil
+}
+
+// cleanRuntime removes the containers and images the container runtime keeps
+// for the pods of PMK, as the images of a decommissioned node easily add up to
+// gigabytes. The runtimes may have been installed for other uses, so their
+// other containers and images and their directories are left alone
+func cleanRuntime(exec cmdexec.Executor) error {
+	if _, err := exec.RunArgs("docker", "version"); err == nil {
+		// The images of the pods are the ones of their containers
+		out, err := exec.RunWithStdout("bash", "-c", "docker ps -aq --filter name=k8s_ | xargs -r docker inspect --format {{.Image}}")
+		if err != nil {
+			return fmt.Errorf("unable to list the containers of the pods: %w", err)
+		}
+		script := "docker ps -aq --filter name=k8s_ | xargs -r docker rm -f"
+		if _, err := exec.RunWithStdout("bash", "-c", script); err != nil {
+			return fmt.Errorf("unable to remove the containers of the pods: %w", err)
+		}
+		if images := uniqueFields(out); len(images) > 0 {
+			// The images still used by other containers are kept
+			if _, err := exec.RunArgs("docker", append([]string{"image", "rm"}, images...)...); err != nil {
+				zap.S().Debugf("Unable to remove some images of the pods: %s", err)
+			}
+		}
+	}
+
+	if _, err := exec.RunArgs("ctr", "version"); err == nil {
+		// The tasks of the pods are stopped before their containers and
+		// images are removed
+		ctr := "ctr -n " + podNamespace
+		scripts := []string{
+			fmt.Sprintf("%s tasks ls -q | xargs -r -n1 %s tasks rm -f", ctr, ctr),
+			fmt.Sprintf("%s containers ls -q | xargs -r %s containers rm", ctr, ctr),
+			fmt.Sprintf("%s images ls -q | xargs -r %s images rm", ctr, ctr),
+		}
+		for _, script := range scripts {
+			if _, err := exec.RunWithStdout("bash", "-c", script); err != nil {
+				return fmt.Errorf("unable to remove the containers and images of the pods from containerd: %w", err)
+			}
+		}
+	}
+
+	// The volumes and the rootfs of the pods stay mounted after their
+	// containers are removed, they are unmounted from the deepest
+	var grep []string
+	for _, mount := range podMounts {
+		grep = append(grep, "-e", mount)
+	}
+	script := fmt.Sprintf("findmnt -rn -o TARGET | grep %s | sort -r | xargs -r umount", strings.Join(grep, " "))
+	if _, err := exec.RunWithStdout("bash", "-c", script); err != nil {
+		zap.S().Debugf("Unable to unmount the volumes of the pods: %s", err)
+	}
+	return nil
+}
+
+// uniqueFields returns the fields of s, each once
+func uniqueFields(s string) []string {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Fields(s) {
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package pmk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+const ipLinks = `1: lo: <LOOPBACK,UP,LOWER_UP> mtu 65536 qdisc noqueue state UNKNOWN mode DEFAULT
+2: eth0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc mq state UP mode DEFAULT
+5: tunl0@NONE: <NOARP,UP,LOWER_UP> mtu 1440 qdisc noqueue state UNKNOWN mode DEFAULT
+7: cali5f1a2b3c4d@if3: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1440 qdisc noqueue state UP mode DEFAULT
+`
+
+const iptablesRules = `*nat
+:PREROUTING ACCEPT [0:0]
+:KUBE-SERVICES - [0:0]
+-A PREROUTING -m comment --comment "kubernetes service portals" -j KUBE-SERVICES
+-A POSTROUTING -j MASQUERADE
+COMMIT
+*filter
+:cali-FORWARD - [0:0]
+-A FORWARD -j cali-FORWARD
+-A INPUT -p tcp --dport 22 -j ACCEPT
+COMMIT
+`
+
+func TestFindLeftovers(t *testing.T) {
+	cases := map[string]struct {
+		outputs map[string]string
+		want    NodeLeftovers
+	}{
+		"Clean": {
+			outputs: map[string]string{
+				"dpkg-query": "pf9-hostagent deinstall ok config-files\n",
+				"ip":         "1: lo: <LOOPBACK,UP,LOWER_UP> mtu 65536\n",
+			},
+		},
+		"Leftovers": {
+			outputs: map[string]string{
+				"dpkg-query":    "pf9-hostagent deinstall ok config-files\npf9-comms install ok installed\n",
+				"systemctl":     "pf9-comms.service enabled enabled\n",
+				"ip":            ipLinks,
+				"iptables-save": iptablesRules,
+			},
+			want: NodeLeftovers{
+				Packages:      []string{"pf9-comms"},
+				Services:      []string{"pf9-comms.service"},
+				Interfaces:    []string{"tunl0", "cali5f1a2b3c4d"},
+				IptablesRules: 2,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunArgs: func(name string, args ...string) (string, error) {
+					if out, ok := tc.outputs[name]; ok {
+						return out, nil
+					}
+					return "", errors.New("exit status 1")
+				},
+			}
+			assert.Equal(t, tc.want, findLeftovers(exec, "debian"))
+		})
+	}
+}
+
+func TestNodeLeftoversList(t *testing.T) {
+	l := NodeLeftovers{Packages: []string{"pf9-comms"}, Interfaces: []string{"cni0"}, IptablesRules: 3}
+	assert.False(t, l.Empty())
+	assert.True(t, l.Network())
+	assert.Equal(t, []string{
+		"Packages left installed: pf9-comms",
+		"CNI network interfaces left: cni0",
+		"iptables rules of kube-proxy and CNI left: 3",
+	}, l.List())
+	assert.True(t, NodeLeftovers{}.Empty())
+}
+
+func TestDeepCleanNetwork(t *testing.T) {
+	var cmds []string
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			cmds = append(cmds, strings.Join(append([]string{name}, args...), " "))
+			return "", nil
+		},
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			script := args[len(args)-1]
+			assert.NotContains(t, script, `"`)
+			assert.NotContains(t, script, "$")
+			cmds = append(cmds, script)
+			return "", nil
+		},
+	}
+
+	assert.Nil(t, deepCleanNetwork(exec, []string{"cni0", "tunl0"}))
+	assert.Equal(t, []string{
+		"ip link delete cni0",
+		"ip link delete tunl0",
+		"iptables-save | grep -v -e KUBE- -e cali- -e CNI- -e FLANNEL | iptables-restore",
+		"rm -rf /etc/cni/net.d /var/lib/cni /var/lib/calico /run/flannel",
+	}, cmds)
+}
+
+func TestCleanRuntime(t *testing.T) {
+	umount := "findmnt -rn -o TARGET | grep -e ^/var/lib/kubelet/pods/ -e ^/run/containerd/io.containerd.runtime.v2.task/k8s.io/ | sort -r | xargs -r umount"
+	cases := map[string]struct {
+		docker bool
+		ctr    bool
+		want   []string
+	}{
+		"Containerd": {
+			ctr: true,
+			want: []string{
+				"ctr version",
+				"ctr -n k8s.io tasks ls -q | xargs -r -n1 ctr -n k8s.io tasks rm -f",
+				"ctr -n k8s.io containers ls -q | xargs -r ctr -n k8s.io containers rm",
+				"ctr -n k8s.io images ls -q | xargs -r ctr -n k8s.io images rm",
+				umount,
+			},
+		},
+		"Docker": {
+			docker: true,
+			want: []string{
+				"docker version",
+				"docker ps -aq --filter name=k8s_ | xargs -r docker inspect --format {{.Image}}",
+				"docker ps -aq --filter name=k8s_ | xargs -r docker rm -f",
+				"docker image rm sha256:pause sha256:calico",
+				umount,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var cmds []string
+			exec := &cmdexec.MockExecutor{
+				MockRunArgs: func(name string, args ...string) (string, error) {
+					if args[0] == "version" && ((name == "docker" && !tc.docker) || (name == "ctr" && !tc.ctr)) {
+						return "", errors.New("command not found")
+					}
+					cmds = append(cmds, strings.Join(append([]string{name}, args...), " "))
+					return "", nil
+				},
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					script := args[len(args)-1]
+					assert.NotContains(t, script, `"`)
+					assert.NotContains(t, script, "$")
+					// Nothing but the pods is removed
+					assert.NotContains(t, script, "rm -rf")
+					cmds = append(cmds, script)
+					if strings.Contains(script, "docker inspect") {
+						return "sha256:pause\nsha256:calico\nsha256:pause\n", nil
+					}
+					return "", nil
+				},
+			}
+			assert.Nil(t, cleanRuntime(exec))
+			assert.Equal(t, tc.want, cmds)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package pmk
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// hostagentConf is the configuration the installer writes for the hostagent,
+// it holds the DU the node was prepared with
+const hostagentConf = "/etc/pf9/hostagent.conf"
+
+// NodeDU returns the DU the hostagent of the node connects to, empty when the
+// configuration doesn't tell, like on nodes going through pf9-comms only
+func NodeDU(exec cmdexec.Executor) (string, error) {
+	out, err := exec.RunArgs("cat", hostagentConf)
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s, prepare the node with prep-node first: %w", hostagentConf, err)
+	}
+	return parseHostagentDU(out), nil
+}
+
+// parseHostagentDU returns the DU of the hostagent configuration, given by
+// du_fqdn or by the host of the amqp section. The amqp host is localhost when
+// the hostagent goes through pf9-comms, which doesn't tell the DU.
+func parseHostagentDU(conf string) string {
+	section, amqpHost := "", ""
+	scanner := bufio.NewScanner(strings.NewReader(conf))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.Trim(line, "[]")
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch {
+		case key == "du_fqdn" && value != "":
+			return normalizeDU(value)
+		case section == "amqp" && key == "host":
+			amqpHost = normalizeDU(value)
+		}
+	}
+	if ip := net.ParseIP(amqpHost); amqpHost == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return ""
+	}
+	return amqpHost
+}
+
+// normalizeDU returns the host of a DU given as an FQDN or a URL
+func normalizeDU(du string) string {
+	du = strings.TrimSpace(du)
+	if !strings.Contains(du, "://") {
+		du = "https://" + du
+	}
+	u, err := url.Parse(du)
+	if err != nil {
+		return strings.ToLower(du)
+	}
+	return strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+}
+
+// CheckNodeDU fails when the node was prepared with another DU or region than
+// du, the FQDN of the region the node is attached with. Such nodes don't
+// connect to the DU, so their attach would never complete.
+func CheckNodeDU(exec cmdexec.Executor, ip, du string) error {
+	nodeDU, err := NodeDU(exec)
+	if err != nil {
+		return fmt.Errorf("node %s: %w", ip, err)
+	}
+	if nodeDU == "" {
+		zap.S().Debugf("Unable to find the DU of node %s in %s, skipping the DU check", ip, hostagentConf)
+		return nil
+	}
+	if nodeDU != normalizeDU(du) {
+		return fmt.Errorf("node %s was prepared with the DU %s, not %s. Decommission it with 'pf9ctl decommission-node' "+
+			"and prepare it again with 'pf9ctl prep-node' using the current config", ip, nodeDU, normalizeDU(du))
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package pmk
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"go.uber.org/zap"
+)
+
+// Reconnection of an onboarded node to its DU once its configuration is
+// updated
+var (
+	// NodeDUTimeout is how long the pf9 services of the node have to
+	// reconnect before their previous configuration is restored
+	NodeDUTimeout = 5 * time.Minute
+	// nodeDUPollInterval is the delay between the checks of the reconnection
+	nodeDUPollInterval = 10 * time.Second
+)
+
+// nodeDUBackupDir is where the configuration of the pf9 services is kept
+// before it is updated, a directory for each update
+const nodeDUBackupDir = "/etc/pf9/du-backup"
+
+// NodeDUUpdate is the DU and the credentials the pf9 services of an onboarded
+// node are moved to
+type NodeDUUpdate struct {
+	// From is the DU the node connects to now
+	From string
+	// To is the DU the node connects to once updated
+	To string
+	// Via is the host pf9-comms connects to, the DU or the proxy it is
+	// reached through
+	Via string
+	// AMQPUser and AMQPPassword are the credentials of the hostagent, kept
+	// when empty
+	AMQPUser     string
+	AMQPPassword string
+}
+
+// Changes reports whether the update changes the DU or the credentials of the
+// node
+func (u NodeDUUpdate) Changes() bool {
+	return normalizeDU(u.From) != normalizeDU(u.To) || u.AMQPUser != "" || u.AMQPPassword != ""
+}
+
+// rewriteHostagentConf points the hostagent configuration conf to the DU of u
+// and sets its credentials. The amqp host stays when it is the loopback of
+// pf9-comms.
+func rewriteHostagentConf(conf string, u NodeDUUpdate) string {
+	var b strings.Builder
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(conf))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section = strings.Trim(trimmed, "[]")
+		} else if i := strings.Index(line, "="); i >= 0 && !strings.HasPrefix(trimmed, "#") {
+			key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+			set := func(v string) {
+				line = strings.TrimRight(line[:i], " ") + " = " + v
+			}
+			switch {
+			case key == "du_fqdn" && strings.Contains(value, "://"):
+				set("https://" + u.To)
+			case key == "du_fqdn":
+				set(u.To)
+			case section == "amqp" && key == "host":
+				if ip := net.ParseIP(value); value != "localhost" && (ip == nil || !ip.IsLoopback()) {
+					set(u.To)
+				}
+			case section == "amqp" && key == "username" && u.AMQPUser != "":
+				set(u.AMQPUser)
+			case section == "amqp" && key == "password" && u.AMQPPassword != "":
+				set(u.AMQPPassword)
+			}
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// nodeDUScript keeps the configuration of the pf9 services in backup, points
+// the files of /etc/pf9 mentioning the former DU to the new one, writes the
+// hostagent configuration conf and restarts the services
+func nodeDUScript(u NodeDUUpdate, conf, backup string) string {
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	fmt.Fprintf(&b, "BACKUP=%s\nmkdir -p \"$BACKUP\"\n", backup)
+	b.WriteString("files=\n")
+	if u.From != u.To {
+		fmt.Fprintf(&b, "files=$(grep -rliF --exclude-dir=%s --exclude-dir=import-backup -e %s /etc/pf9 || true)\n",
+			strings.TrimPrefix(nodeDUBackupDir, "/etc/pf9/"), cmdexec.ShellQuote(u.From))
+	}
+	fmt.Fprintf(&b, "for f in %s $files; do mkdir -p \"$BACKUP$(dirname \"$f\")\"; cp -a \"$f\" \"$BACKUP$f\"; done\n", hostagentConf)
+	fmt.Fprintf(&b, "for f in $files; do [ \"$f\" = %s ] || sed -i 's/%s/%s/gI' \"$f\"; done\n",
+		hostagentConf, strings.ReplaceAll(u.From, ".", `\.`), u.To)
+	fmt.Fprintf(&b, "cat > %s <<'PF9_EOF'\n%sPF9_EOF\n", hostagentConf, conf)
+	b.WriteString("systemctl restart pf9-comms pf9-hostagent\n")
+	return b.String()
+}
+
+// restoreNodeDUScript restores the configuration kept in backup
+func restoreNodeDUScript(backup string) string {
+	return fmt.Sprintf("set -e\ncp -a %s/. /\nsystemctl restart pf9-comms pf9-hostagent\n", backup)
+}
+
+// commsConnectedScript succeeds when pf9-comms has a connection to the host,
+// as checked by the watchdog
+const commsConnectedScript = `for ip in $(getent ahosts %s | awk '{print $1}' | sort -u); do
+    ss -tn state established dst "$ip" | tail -n +2 | grep -q . && exit 0
+done
+exit 1`
+
+// waitForNodeDU waits for pf9-comms to connect to u.Via and for the host to
+// respond to the DU, until NodeDUTimeout
+func waitForNodeDU(c client.Client, auth keystone.KeystoneAuth, exec cmdexec.Executor, hostID string, u NodeDUUpdate) error {
+	deadline := time.Now().Add(NodeDUTimeout)
+	var lastErr error
+	for {
+		if _, err := exec.RunArgs("bash", "-c", fmt.Sprintf(commsConnectedScript, cmdexec.ShellQuote(u.Via))); err != nil {
+			lastErr = fmt.Errorf("pf9-comms has no connection to %s", u.Via)
+		} else if host, err := c.Resmgr.GetHostInfo(auth.Token, hostID); err != nil {
+			lastErr = err
+		} else if !host.Info.Responding {
+			lastErr = fmt.Errorf("host %s isn't responding to the DU %s", hostID, u.To)
+		} else {
+			return nil
+		}
+		zap.S().Debugf("The node hasn't reconnected yet: %s", lastErr)
+		if !time.Now().Add(nodeDUPollInterval).Before(deadline) {
+			return fmt.Errorf("the node didn't reconnect within %s: %w", NodeDUTimeout, lastErr)
+		}
+		time.Sleep(nodeDUPollInterval)
+	}
+}
+
+// UpdateNodeDU points the pf9 services of the onboarded node of exec to the DU
+// of u, without reinstalling them, and waits for them to reconnect to it. The
+// previous configuration is restored when they don't, so the node stays
+// connected to its former DU. c is the client of the new DU.
+func UpdateNodeDU(c client.Client, auth keystone.KeystoneAuth, exec cmdexec.Executor, u NodeDUUpdate) error {
+	if u.AMQPPassword != "" {
+		log.RegisterSecret(u.AMQPPassword)
+	}
+	if u.From == "" {
+		return fmt.Errorf("unable to tell the DU the node connects to from %s, give it with --from", hostagentConf)
+	}
+	u.From, u.To = normalizeDU(u.From), normalizeDU(u.To)
+	if _, err := exec.RunArgs("test", "-d", "/opt/pf9/hostagent"); err != nil {
+		return fmt.Errorf("hostagent is not installed, prepare the node with prep-node first")
+	}
+	hostID := readHostID(exec)
+	if hostID == "" {
+		return errors.New("the node has no host ID, prepare it with prep-node first")
+	}
+	conf, err := exec.RunArgs("cat", hostagentConf)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", hostagentConf, err)
+	}
+
+	backup := fmt.Sprintf("%s/%s", nodeDUBackupDir, time.Now().UTC().Format("20060102T150405Z"))
+	zap.S().Debugf("Moving host %s from the DU %s to %s, its configuration is kept in %s", hostID, u.From, u.To, backup)
+	// The credentials are kept out of the command line
+	if _, err := cmdexec.RunSecretScript(exec, nodeDUScript(u, rewriteHostagentConf(conf, u), backup)); err != nil {
+		return fmt.Errorf("unable to update the configuration of the pf9 services: %w", err)
+	}
+
+	waitErr := waitForNodeDU(c, auth, exec, hostID, u)
+	if waitErr == nil {
+		return nil
+	}
+	if _, err := exec.RunArgs("bash", "-c", restoreNodeDUScript(backup)); err != nil {
+		return fmt.Errorf("%s, and restoring the configuration from %s failed: %w", waitErr, backup, err)
+	}
+	return fmt.Errorf("%s, the previous configuration is restored", waitErr)
+}
@@ -0,0 +1,93 @@
+package pmk
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRewriteHostagentConf(t *testing.T) {
+	u := NodeDUUpdate{From: "old.example.com", To: "new.example.com", AMQPPassword: "s3cret"}
+	conf := "[hostagent]\ndu_fqdn = https://old.example.com/\n# host = old.example.com\n\n[amqp]\nhost=old.example.com\nusername = hostagent\npassword = old\n"
+	assert.Equal(t, "[hostagent]\ndu_fqdn = https://new.example.com\n# host = old.example.com\n\n[amqp]\nhost = new.example.com\nusername = hostagent\npassword = s3cret\n",
+		rewriteHostagentConf(conf, u))
+
+	// The hostagent going through pf9-comms keeps connecting to it
+	assert.Equal(t, "[amqp]\nhost = localhost\n", rewriteHostagentConf("[amqp]\nhost = localhost\n", u))
+}
+
+func TestNodeDUUpdateChanges(t *testing.T) {
+	assert.False(t, NodeDUUpdate{From: "du.example.com", To: "https://DU.example.com/"}.Changes())
+	assert.True(t, NodeDUUpdate{From: "du.example.com", To: "du.example.com", AMQPUser: "hostagent"}.Changes())
+	assert.True(t, NodeDUUpdate{From: "old.example.com", To: "new.example.com"}.Changes())
+}
+
+// respondingResmgr reports the host responding once it is asked calls times
+type respondingResmgr struct {
+	resmgr.Resmgr
+	after int
+	calls *int
+}
+
+func (r respondingResmgr) GetHostInfo(token, hostID string) (resmgr.HostInfo, error) {
+	*r.calls++
+	host := resmgr.HostInfo{ID: hostID}
+	host.Info.Responding = r.after >= 0 && *r.calls > r.after
+	return host, nil
+}
+
+func TestUpdateNodeDU(t *testing.T) {
+	NodeDUTimeout, nodeDUPollInterval = 50*time.Millisecond, time.Millisecond
+	defer func() { NodeDUTimeout, nodeDUPollInterval = 5*time.Minute, 10*time.Second }()
+
+	u := NodeDUUpdate{From: "old.example.com", To: "https://new.example.com", Via: "new.example.com"}
+	for name, tc := range map[string]struct {
+		after   int
+		restore bool
+	}{
+		"Reconnects":     {after: 2},
+		"NoReconnection": {after: -1, restore: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var scripts []string
+			exec := &cmdexec.MockExecutor{
+				MockRunArgs: func(name string, args ...string) (string, error) {
+					switch name {
+					case "cat":
+						return "[hostagent]\ndu_fqdn = old.example.com\n", nil
+					case "bash":
+						scripts = append(scripts, args[1])
+					}
+					return "", nil
+				},
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					if strings.Contains(args[1], "host_id") {
+						return "host-1\n", nil
+					}
+					scripts = append(scripts, args[1])
+					return "", nil
+				},
+			}
+			calls := 0
+			c := client.Client{Resmgr: respondingResmgr{after: tc.after, calls: &calls}}
+			err := UpdateNodeDU(c, keystone.KeystoneAuth{}, exec, u)
+			if assert.NotEmpty(t, scripts) {
+				assert.Contains(t, scripts[0], "du_fqdn = new.example.com\n")
+				assert.Contains(t, scripts[0], `sed -i 's/old\.example\.com/new.example.com/gI'`)
+			}
+			if !tc.restore {
+				assert.NoError(t, err)
+				assert.Equal(t, 3, calls)
+				return
+			}
+			assert.EqualError(t, err, "the node didn't reconnect within 50ms: host host-1 isn't responding to the DU new.example.com, the previous configuration is restored")
+			assert.Contains(t, scripts[len(scripts)-1], "cp -a /etc/pf9/du-backup/")
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package pmk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHostagentDU(t *testing.T) {
+	cases := map[string]struct {
+		conf string
+		want string
+	}{
+		"DUFQDN": {
+			conf: "[hostagent]\ndu_fqdn = https://Example.platform9.io/\n",
+			want: "example.platform9.io",
+		},
+		"AMQPHost": {
+			conf: "[DEFAULT]\nhost = node-1\n\n[amqp]\nhost = example-region2.platform9.io\nport = 5671\n",
+			want: "example-region2.platform9.io",
+		},
+		"Comms": {
+			conf: "[amqp]\nhost = localhost\n",
+		},
+		"Loopback": {
+			conf: "[amqp]\nhost = 127.0.0.1\n",
+		},
+		"Empty": {},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, parseHostagentDU(tc.conf))
+		})
+	}
+}
+
+func TestCheckNodeDU(t *testing.T) {
+	cases := map[string]struct {
+		conf string
+		err  string
+	}{
+		"Match":   {conf: "[amqp]\nhost = example.platform9.io\n"},
+		"Unknown": {conf: "[amqp]\nhost = localhost\n"},
+		"OtherDU": {
+			conf: "[amqp]\nhost = other.platform9.io\n",
+			err: "node 10.0.0.1 was prepared with the DU other.platform9.io, not example.platform9.io. " +
+				"Decommission it with 'pf9ctl decommission-node' and prepare it again with 'pf9ctl prep-node' using the current config",
+		},
+		"NotPrepared": {err: "node 10.0.0.1: unable to read /etc/pf9/hostagent.conf, prepare the node with prep-node first: exit status 1"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunArgs: func(name string, args ...string) (string, error) {
+					if tc.conf == "" {
+						return "", errors.New("exit status 1")
+					}
+					return tc.conf, nil
+				},
+			}
+			err := CheckNodeDU(exec, "10.0.0.1", "https://example.platform9.io")
+			if tc.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
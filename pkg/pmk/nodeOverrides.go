@@ -0,0 +1,224 @@
+package pmk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"gopkg.in/yaml.v2"
+)
+
+// NodeOverrides are kubelet settings of a single node, applied when it is
+// attached to a cluster. NodeIP is needed on multi-NIC hosts which otherwise
+// register with the IP of their default route.
+type NodeOverrides struct {
+	NodeIP         string `yaml:"nodeIP,omitempty"`
+	MaxPods        int    `yaml:"maxPods,omitempty"`
+	KubeReserved   string `yaml:"kubeReserved,omitempty"`
+	SystemReserved string `yaml:"systemReserved,omitempty"`
+}
+
+// NodeFileEntry is a node to attach along with its overrides. Region is the
+// region of the DU the node is onboarded with, the one of the config when
+// empty.
+type NodeFileEntry struct {
+	IP            string `yaml:"ip"`
+	Role          string `yaml:"role"`
+	Region        string `yaml:"region,omitempty"`
+	NodeOverrides `yaml:",inline"`
+}
+
+// NodeFile lists the nodes to attach, as read by attach-node --node-file
+type NodeFile struct {
+	Nodes []NodeFileEntry `yaml:"nodes"`
+}
+
+// RegionNodes are the nodes of a node file in a single region
+type RegionNodes struct {
+	Region    string
+	MasterIPs []string
+	WorkerIPs []string
+}
+
+// ByRegion groups the nodes by region, in the order the regions first appear
+// in the file. The nodes without a region are in defaultRegion.
+func (f NodeFile) ByRegion(defaultRegion string) []RegionNodes {
+	var groups []RegionNodes
+	index := make(map[string]int)
+	for _, node := range f.Nodes {
+		region := node.Region
+		if region == "" {
+			region = defaultRegion
+		}
+		i, ok := index[region]
+		if !ok {
+			i = len(groups)
+			index[region] = i
+			groups = append(groups, RegionNodes{Region: region})
+		}
+		if node.Role == "master" {
+			groups[i].MasterIPs = append(groups[i].MasterIPs, node.IP)
+		} else {
+			groups[i].WorkerIPs = append(groups[i].WorkerIPs, node.IP)
+		}
+	}
+	return groups
+}
+
+var (
+	reservedResources = map[string]bool{"cpu": true, "memory": true, "ephemeral-storage": true, "pid": true}
+	quantityRegexp    = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(m|k|Ki|M|Mi|G|Gi|T|Ti)?$`)
+)
+
+// Empty is true when no setting is overridden
+func (o NodeOverrides) Empty() bool {
+	return o == NodeOverrides{}
+}
+
+// Merge returns o with the settings it doesn't set taken from defaults
+func (o NodeOverrides) Merge(defaults NodeOverrides) NodeOverrides {
+	if o.NodeIP == "" {
+		o.NodeIP = defaults.NodeIP
+	}
+	if o.MaxPods == 0 {
+		o.MaxPods = defaults.MaxPods
+	}
+	if o.KubeReserved == "" {
+		o.KubeReserved = defaults.KubeReserved
+	}
+	if o.SystemReserved == "" {
+		o.SystemReserved = defaults.SystemReserved
+	}
+	return o
+}
+
+// Validate checks the overrides, the node IP must be one of hostIPs, the IPs
+// resmgr reports for the host.
+func (o NodeOverrides) Validate(hostIPs []string) error {
+	if o.NodeIP != "" {
+		if net.ParseIP(o.NodeIP) == nil {
+			return fmt.Errorf("invalid node IP %s", o.NodeIP)
+		}
+		if !util.ContainsIP(hostIPs, o.NodeIP) {
+			return fmt.Errorf("node IP %s is not an address of the host, its addresses are %s", o.NodeIP, strings.Join(hostIPs, ", "))
+		}
+	}
+	if o.MaxPods < 0 {
+		return fmt.Errorf("invalid max pods %d", o.MaxPods)
+	}
+	if err := validateReserved(o.KubeReserved); err != nil {
+		return fmt.Errorf("invalid kube reserved resources: %w", err)
+	}
+	if err := validateReserved(o.SystemReserved); err != nil {
+		return fmt.Errorf("invalid system reserved resources: %w", err)
+	}
+	return nil
+}
+
+// validateReserved checks reserved resources given like cpu=500m,memory=1Gi
+func validateReserved(reserved string) error {
+	if reserved == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(reserved, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("%s should be <resource>=<quantity>", pair)
+		}
+		if !reservedResources[kv[0]] {
+			return fmt.Errorf("unknown resource %s, it should be cpu, memory, ephemeral-storage or pid", kv[0])
+		}
+		if !quantityRegexp.MatchString(kv[1]) {
+			return fmt.Errorf("invalid quantity %s for %s", kv[1], kv[0])
+		}
+	}
+	return nil
+}
+
+// extraCfg returns the overrides as the extra configuration of the sunpike
+// host, which nodelet passes to the kubelet of the node
+func (o NodeOverrides) extraCfg() map[string]string {
+	cfg := make(map[string]string)
+	if o.NodeIP != "" {
+		cfg["KUBELET_NODE_IP"] = o.NodeIP
+	}
+	if o.MaxPods != 0 {
+		cfg["KUBELET_MAX_PODS"] = strconv.Itoa(o.MaxPods)
+	}
+	if o.KubeReserved != "" {
+		cfg["KUBELET_KUBE_RESERVED"] = o.KubeReserved
+	}
+	if o.SystemReserved != "" {
+		cfg["KUBELET_SYSTEM_RESERVED"] = o.SystemReserved
+	}
+	return cfg
+}
+
+// ApplyNodeOverrides writes the overrides to the sunpike host of the node
+// before it is attached, so nodelet configures the kubelet with them
+func ApplyNodeOverrides(fqdn string, auth keystone.KeystoneAuth, hostID string, o NodeOverrides) error {
+	return patchExtraCfg(fqdn, auth, hostID, o.extraCfg())
+}
+
+// patchExtraCfg merges cfg into the extra configuration of the sunpike host,
+// which nodelet reads when it configures the node
+func patchExtraCfg(fqdn string, auth keystone.KeystoneAuth, hostID string, cfg map[string]string) error {
+	patch := map[string]interface{}{"spec": map[string]interface{}{"extraCfg": cfg}}
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/sunpike/apis/sunpike.platform9.com/v1alpha2/namespaces/default/hosts/%s", fqdn, hostID)
+	req, err := http.NewRequest("PATCH", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", auth.Token)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to send request to sunpike: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("sunpike returned status %d for host %s", resp.StatusCode, hostID)
+	}
+	return nil
+}
+
+// ReadNodeFile reads the nodes to attach from a YAML file
+func ReadNodeFile(path string) (NodeFile, error) {
+	var file NodeFile
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return file, err
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return file, fmt.Errorf("invalid node file %s: %w", path, err)
+	}
+	if err := validateNodes(file.Nodes); err != nil {
+		return file, fmt.Errorf("invalid node file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// validateNodes checks the IP and the role of the nodes
+func validateNodes(nodes []NodeFileEntry) error {
+	for _, node := range nodes {
+		if net.ParseIP(node.IP) == nil {
+			return fmt.Errorf("invalid IP %q", node.IP)
+		}
+		if node.Role != "master" && node.Role != "worker" {
+			return fmt.Errorf("role of node %s should be master or worker", node.IP)
+		}
+	}
+	return nil
+}
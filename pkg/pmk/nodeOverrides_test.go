@@ -0,0 +1,130 @@
+package pmk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeOverridesValidate(t *testing.T) {
+	hostIPs := []string{"10.0.0.1", "192.168.10.1"}
+	cases := map[string]struct {
+		overrides NodeOverrides
+		err       string
+	}{
+		"Empty":    {},
+		"SecondIP": {overrides: NodeOverrides{NodeIP: "192.168.10.1", MaxPods: 200, KubeReserved: "cpu=500m,memory=1Gi", SystemReserved: "pid=1000"}},
+		"ForeignIP": {
+			overrides: NodeOverrides{NodeIP: "172.16.0.1"},
+			err:       "node IP 172.16.0.1 is not an address of the host, its addresses are 10.0.0.1, 192.168.10.1",
+		},
+		"UnknownResource": {
+			overrides: NodeOverrides{KubeReserved: "gpu=1"},
+			err:       "invalid kube reserved resources: unknown resource gpu, it should be cpu, memory, ephemeral-storage or pid",
+		},
+		"BadQuantity": {
+			overrides: NodeOverrides{SystemReserved: "memory=1GB"},
+			err:       "invalid system reserved resources: invalid quantity 1GB for memory",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.overrides.Validate(hostIPs)
+			if tc.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestNodeOverridesMerge(t *testing.T) {
+	defaults := NodeOverrides{MaxPods: 110, KubeReserved: "cpu=500m"}
+	merged := NodeOverrides{NodeIP: "10.0.0.1", MaxPods: 200}.Merge(defaults)
+	assert.Equal(t, NodeOverrides{NodeIP: "10.0.0.1", MaxPods: 200, KubeReserved: "cpu=500m"}, merged)
+	assert.True(t, NodeOverrides{}.Merge(NodeOverrides{}).Empty())
+}
+
+func TestApplyNodeOverrides(t *testing.T) {
+	var patch map[string]map[string]map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PATCH", r.Method)
+		assert.Equal(t, "/sunpike/apis/sunpike.platform9.com/v1alpha2/namespaces/default/hosts/host-1", r.URL.Path)
+		assert.Equal(t, "application/merge-patch+json", r.Header.Get("Content-Type"))
+		json.NewDecoder(r.Body).Decode(&patch)
+	}))
+	defer server.Close()
+
+	overrides := NodeOverrides{NodeIP: "192.168.10.1", MaxPods: 200}
+	assert.Nil(t, ApplyNodeOverrides(server.URL, keystone.KeystoneAuth{Token: "token"}, "host-1", overrides))
+	assert.Equal(t, map[string]string{"KUBELET_NODE_IP": "192.168.10.1", "KUBELET_MAX_PODS": "200"}, patch["spec"]["extraCfg"])
+}
+
+func TestReadNodeFile(t *testing.T) {
+	cases := map[string]struct {
+		content string
+		want    NodeFile
+		err     string
+	}{
+		"Valid": {
+			content: "nodes:\n- ip: 10.0.0.1\n  role: master\n- ip: 10.0.0.2\n  role: worker\n  nodeIP: 192.168.10.2\n  maxPods: 200\n",
+			want: NodeFile{Nodes: []NodeFileEntry{
+				{IP: "10.0.0.1", Role: "master"},
+				{IP: "10.0.0.2", Role: "worker", NodeOverrides: NodeOverrides{NodeIP: "192.168.10.2", MaxPods: 200}},
+			}},
+		},
+		"Region": {
+			content: "nodes:\n- ip: 10.0.0.1\n  role: master\n  region: us-west\n",
+			want:    NodeFile{Nodes: []NodeFileEntry{{IP: "10.0.0.1", Role: "master", Region: "us-west"}}},
+		},
+		"BadRole": {
+			content: "nodes:\n- ip: 10.0.0.1\n  role: etcd\n",
+			err:     "invalid node file <file>: role of node 10.0.0.1 should be master or worker",
+		},
+		"UnknownField": {
+			content: "nodes:\n- ip: 10.0.0.1\n  role: worker\n  maxpods: 10\n",
+			err:     "invalid node file <file>: yaml: unmarshal errors:\n  line 4: field maxpods not found in type pmk.NodeFileEntry",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "nodes")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "nodes.yaml")
+			assert.Nil(t, ioutil.WriteFile(path, []byte(tc.content), 0600))
+			file, err := ReadNodeFile(path)
+			if tc.err != "" {
+				assert.EqualError(t, err, strings.Replace(tc.err, "<file>", path, 1))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, file)
+		})
+	}
+}
+
+func TestNodeFileByRegion(t *testing.T) {
+	file := NodeFile{Nodes: []NodeFileEntry{
+		{IP: "10.0.0.1", Role: "master"},
+		{IP: "10.1.0.1", Role: "master", Region: "eu-central"},
+		{IP: "10.0.0.2", Role: "worker", Region: "RegionOne"},
+		{IP: "10.1.0.2", Role: "worker", Region: "eu-central"},
+		{IP: "10.2.0.1", Role: "worker", Region: "ap-south"},
+	}}
+	assert.Equal(t, []RegionNodes{
+		{Region: "RegionOne", MasterIPs: []string{"10.0.0.1"}, WorkerIPs: []string{"10.0.0.2"}},
+		{Region: "eu-central", MasterIPs: []string{"10.1.0.1"}, WorkerIPs: []string{"10.1.0.2"}},
+		{Region: "ap-south", WorkerIPs: []string{"10.2.0.1"}},
+	}, file.ByRegion("RegionOne"))
+	assert.Nil(t, NodeFile{}.ByRegion("RegionOne"))
+}
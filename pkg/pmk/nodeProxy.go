@@ -0,0 +1,140 @@
+package pmk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"go.uber.org/zap"
+)
+
+const (
+	// commsProxyFile is read by pf9-comms to reach the DU through a proxy
+	commsProxyFile = "/etc/pf9/comms_proxy_cfg.json"
+	// hostagentProxyFile sets the proxy environment of the hostagent and of
+	// the pf9 services it starts
+	hostagentProxyFile = "/etc/systemd/system/pf9-hostagent.service.d/proxy.conf"
+)
+
+// NodeProxy is the proxy the pf9 services of an onboarded node go through
+type NodeProxy struct {
+	Protocol string
+	Host     string
+	Port     int
+	User     string
+	Password string
+	// NoProxy is the comma separated list of hosts reached directly
+	NoProxy string
+}
+
+// ParseNodeProxy parses a proxy URL given as [<protocol>://][<username>:<password>@]<host>:<port>
+func ParseNodeProxy(proxyURL, noProxy string) (NodeProxy, error) {
+	if !strings.Contains(proxyURL, "://") {
+		proxyURL = "http://" + proxyURL
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return NodeProxy{}, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return NodeProxy{}, fmt.Errorf("invalid proxy URL, protocol should be http or https")
+	}
+	host, portStr, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return NodeProxy{}, fmt.Errorf("invalid proxy URL, it should be [<protocol>://][<username>:<password>@]<host>:<port>")
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return NodeProxy{}, fmt.Errorf("invalid proxy port %s", portStr)
+	}
+
+	proxy := NodeProxy{Protocol: u.Scheme, Host: host, Port: port, NoProxy: noProxy}
+	if u.User != nil {
+		proxy.User = u.User.Username()
+		proxy.Password, _ = u.User.Password()
+		log.RegisterSecret(proxy.Password)
+	}
+	return proxy, nil
+}
+
+// URL returns the proxy URL as set in the environment of the services
+func (p NodeProxy) URL() string {
+	u := url.URL{Scheme: p.Protocol, Host: net.JoinHostPort(p.Host, strconv.Itoa(p.Port))}
+	if p.User != "" {
+		u.User = url.UserPassword(p.User, p.Password)
+	}
+	return u.String()
+}
+
+func (p NodeProxy) commsConfig() (string, error) {
+	proxy := map[string]interface{}{
+		"protocol": p.Protocol,
+		"host":     p.Host,
+		"port":     p.Port,
+	}
+	if p.User != "" {
+		proxy["user"] = p.User
+		proxy["pass"] = p.Password
+	}
+	data, err := json.MarshalIndent(map[string]interface{}{"http_proxy": proxy}, "", "  ")
+	return string(data), err
+}
+
+func (p NodeProxy) hostagentConfig() string {
+	// systemd expands % specifiers, which shows up in URL encoded passwords
+	proxyURL := strings.ReplaceAll(p.URL(), "%", "%%")
+	config := "[Service]\n"
+	for _, name := range []string{"http_proxy", "https_proxy", "HTTP_PROXY", "HTTPS_PROXY"} {
+		config += fmt.Sprintf("Environment=\"%s=%s\"\n", name, proxyURL)
+	}
+	if p.NoProxy != "" {
+		config += fmt.Sprintf("Environment=\"no_proxy=%s\"\n", p.NoProxy)
+		config += fmt.Sprintf("Environment=\"NO_PROXY=%s\"\n", p.NoProxy)
+	}
+	return config
+}
+
+// nodeProxyScript writes or, when proxy is nil, removes the proxy settings of
+// the pf9 services and restarts them so they are picked up.
+func nodeProxyScript(proxy *NodeProxy) (string, error) {
+	script := "set -e\n"
+	if proxy == nil {
+		script += fmt.Sprintf("rm -f %s %s\n", commsProxyFile, hostagentProxyFile)
+	} else {
+		comms, err := proxy.commsConfig()
+		if err != nil {
+			return "", err
+		}
+		script += fmt.Sprintf("mkdir -p /etc/pf9 %s\n", filepath.Dir(hostagentProxyFile))
+		script += fmt.Sprintf("umask 077\ncat > %s <<'PF9_EOF'\n%s\nPF9_EOF\n", commsProxyFile, comms)
+		script += fmt.Sprintf("cat > %s <<'PF9_EOF'\n%sPF9_EOF\n", hostagentProxyFile, proxy.hostagentConfig())
+	}
+	script += "systemctl daemon-reload\nsystemctl restart pf9-comms pf9-hostagent\n"
+	return script, nil
+}
+
+// ConfigureNodeProxy updates the proxy of the pf9 services of an onboarded
+// node, a nil proxy removes it so the services connect to the DU directly.
+func ConfigureNodeProxy(exec cmdexec.Executor, proxy *NodeProxy) error {
+	zap.S().Debug("Configuring the proxy of the pf9 services")
+
+	if _, err := exec.RunArgs("test", "-d", "/opt/pf9/hostagent"); err != nil {
+		return fmt.Errorf("hostagent is not installed, prepare the node with prep-node first")
+	}
+
+	script, err := nodeProxyScript(proxy)
+	if err != nil {
+		return fmt.Errorf("unable to generate the proxy config: %w", err)
+	}
+	// The proxy credentials are kept out of the command line
+	if _, err := cmdexec.RunSecretScript(exec, script); err != nil {
+		return fmt.Errorf("unable to update the proxy config: %w", err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package pmk
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
@@ -61,3 +62,57 @@ func TestOpenOSReleaseFile(t *testing.T) {
 		})
 	}
 }
+
+func TestPrepareWorkDir(t *testing.T) {
+	cases := map[string]struct {
+		dir     string
+		free    string
+		noexec  bool
+		wantDir string
+		err     string
+	}{
+		"DefaultsToHome": {free: "/dev/sda1 40000 1000 39000 3% /", wantDir: "/home/ubuntu/pf9"},
+		"WorkDir":        {dir: "/data/pf9", free: "/dev/sdb1 40000 1000 39000 3% /data", wantDir: "/data/pf9"},
+		"InvalidDir":     {dir: "data/pf9 x", err: `invalid work directory "data/pf9 x", it should be an absolute path made of letters, digits, '.', '_', '-' and '/'`},
+		"NoSpace": {
+			free: "/dev/sda1 4000 3900 100 97% /",
+			err:  "work directory /home/ubuntu/pf9 has 100 MB free, at least 500 MB are needed, use --work-dir to change it",
+		},
+		"NoExec": {
+			dir: "/tmp/pf9", free: "tmpfs 2000 0 2000 0% /tmp", noexec: true,
+			err: "work directory /tmp/pf9 doesn't allow running the installer, it may be on a noexec filesystem, use --work-dir to change it",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunArgs: func(name string, args ...string) (string, error) {
+					if name == "df" {
+						return "Filesystem 1048576-blocks Used Available Capacity Mounted on\n" + tc.free + "\n", nil
+					}
+					return "", nil
+				},
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					if name != "bash" {
+						return "", nil
+					}
+					switch {
+					case args[1] == "echo $HOME":
+						return "/home/ubuntu\n", nil
+					case strings.Contains(args[1], ".pf9ctl-exec-check") && tc.noexec:
+						return "", fmt.Errorf("permission denied")
+					}
+					return "", nil
+				},
+			}
+			dir, err := prepareWorkDir(exec, tc.dir)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.wantDir, dir)
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package pmk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// nodeFacts are the facts the nodes of a batch are compared on, with the
+// command reading them
+var nodeFacts = []struct {
+	name    string
+	command string
+}{
+	{"Kernel", "uname -r"},
+	{"OS", `. /etc/os-release && echo "$PRETTY_NAME"`},
+	{"CPUs", "nproc"},
+	{"Memory", `awk '/^MemTotal/ {printf "%.0f GiB", $2 / 1048576}' /proc/meminfo`},
+	{"MTU", defaultRouteMTU},
+	{"Container runtime", `for r in containerd dockerd crio; do command -v $r > /dev/null && echo $r && exit; done; echo none`},
+}
+
+// Values of the checks in the preflight matrix
+const (
+	matrixPass    = "ok"
+	matrixFail    = "failed"
+	matrixUnknown = "-"
+)
+
+// NodePreflight is the preflight of a node of a batch
+type NodePreflight struct {
+	Host   string
+	Facts  map[string]string
+	Checks []platform.Check
+	// Err is why the checks couldn't run on the node
+	Err error
+}
+
+// PreflightNode runs the pre-requisite checks on the node of exec and reads
+// the facts the nodes of a batch are compared on. The node is left as is, the
+// checks which fix the node, like installing the missing packages, are
+// skipped: they run when the node is prepared.
+func PreflightNode(ctx objects.Config, exec cmdexec.Executor, host string) NodePreflight {
+	node := NodePreflight{Host: host, Facts: make(map[string]string)}
+	for _, fact := range nodeFacts {
+		out, err := exec.RunWithStdout("bash", "-c", fact.command)
+		if err != nil {
+			zap.S().Debugf("Unable to read the %s of node %s: %s", fact.name, host, err)
+			continue
+		}
+		node.Facts[fact.name] = strings.TrimSpace(out)
+	}
+	p, err := nodePlatform(exec)
+	if err != nil {
+		node.Err = err
+		return node
+	}
+	restore := platform.SkipRemediableChecks()
+	defer restore()
+	node.Checks = runChecks(ctx, exec, p)
+	return node
+}
+
+// MatrixRow is a fact or a check of the nodes of a batch, Outliers tells the
+// nodes it differs on from most nodes
+type MatrixRow struct {
+	Name     string
+	Values   []string
+	Outliers []bool
+}
+
+// PreflightMatrix compares the preflight of the nodes of a batch, with a
+// column by node
+type PreflightMatrix struct {
+	Hosts []string
+	Rows  []MatrixRow
+	// Errors are why the checks couldn't run on nodes, by node
+	Errors map[string]error
+}
+
+// NewPreflightMatrix compares the preflight of the nodes. The facts come
+// first, then the checks in the order they ran.
+func NewPreflightMatrix(nodes []NodePreflight) PreflightMatrix {
+	m := PreflightMatrix{Errors: make(map[string]error)}
+	for _, node := range nodes {
+		m.Hosts = append(m.Hosts, node.Host)
+		if node.Err != nil {
+			m.Errors[node.Host] = node.Err
+		}
+	}
+	for _, fact := range nodeFacts {
+		row := MatrixRow{Name: fact.name}
+		for _, node := range nodes {
+			value, ok := node.Facts[fact.name]
+			if !ok || value == "" {
+				value = matrixUnknown
+			}
+			row.Values = append(row.Values, value)
+		}
+		m.Rows = append(m.Rows, row)
+	}
+
+	var checks []string
+	results := make([]map[string]string, len(nodes))
+	for i, node := range nodes {
+		results[i] = make(map[string]string)
+		for _, check := range node.Checks {
+			if !contains(checks, check.Name) {
+				checks = append(checks, check.Name)
+			}
+			results[i][check.Name] = matrixFail
+			if check.Result {
+				results[i][check.Name] = matrixPass
+			}
+		}
+	}
+	for _, check := range checks {
+		row := MatrixRow{Name: check}
+		for i := range nodes {
+			value, ok := results[i][check]
+			if !ok {
+				value = matrixUnknown
+			}
+			row.Values = append(row.Values, value)
+		}
+		m.Rows = append(m.Rows, row)
+	}
+
+	for i := range m.Rows {
+		m.Rows[i].Outliers = outliers(m.Rows[i].Values)
+	}
+	return m
+}
+
+// Outliers describes the values of the nodes which differ from most nodes
+func (m PreflightMatrix) Outliers() []string {
+	var descriptions []string
+	for _, row := range m.Rows {
+		common := mostCommon(row.Values)
+		for i, outlier := range row.Outliers {
+			if outlier {
+				descriptions = append(descriptions, fmt.Sprintf("%s of %s is %s while most nodes have %s", row.Name, m.Hosts[i], row.Values[i], common))
+			}
+		}
+	}
+	return descriptions
+}
+
+// outliers flags the values which differ from the most common one, unknown
+// values aren't compared
+func outliers(values []string) []bool {
+	common := mostCommon(values)
+	flags := make([]bool, len(values))
+	for i, value := range values {
+		flags[i] = value != matrixUnknown && value != common
+	}
+	return flags
+}
+
+// mostCommon returns the most common of the known values, the first one on
+// ties
+func mostCommon(values []string) string {
+	counts := make(map[string]int)
+	common := matrixUnknown
+	for _, value := range values {
+		if value == matrixUnknown {
+			continue
+		}
+		counts[value]++
+		if common == matrixUnknown || counts[value] > counts[common] {
+			common = value
+		}
+	}
+	return common
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package pmk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflightMatrix(t *testing.T) {
+	facts := func(kernel, mtu string) map[string]string {
+		return map[string]string{"Kernel": kernel, "OS": "Ubuntu 20.04.4 LTS", "CPUs": "4", "Memory": "16 GiB", "MTU": mtu}
+	}
+	nodes := []NodePreflight{
+		{Host: "10.0.0.1", Facts: facts("5.4.0-100", "1500"), Checks: []platform.Check{{Name: "Swap Check", Result: true}, {Name: "Disk Check", Result: true}}},
+		{Host: "10.0.0.2", Facts: facts("5.4.0-100", "9000"), Checks: []platform.Check{{Name: "Swap Check", Result: true}, {Name: "Disk Check", Result: false}}},
+		{Host: "10.0.0.3", Facts: facts("5.15.0-43", "1500"), Checks: []platform.Check{{Name: "Swap Check", Result: true}, {Name: "Disk Check", Result: true}}},
+		{Host: "10.0.0.4", Facts: map[string]string{}, Err: errors.New("This OS is not supported")},
+	}
+
+	m := NewPreflightMatrix(nodes)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}, m.Hosts)
+	assert.EqualError(t, m.Errors["10.0.0.4"], "This OS is not supported")
+
+	rows := make(map[string]MatrixRow)
+	var names []string
+	for _, row := range m.Rows {
+		rows[row.Name] = row
+		names = append(names, row.Name)
+	}
+	assert.Equal(t, []string{"Kernel", "OS", "CPUs", "Memory", "MTU", "Container runtime", "Swap Check", "Disk Check"}, names)
+	assert.Equal(t, []string{"5.4.0-100", "5.4.0-100", "5.15.0-43", "-"}, rows["Kernel"].Values)
+	assert.Equal(t, []bool{false, false, true, false}, rows["Kernel"].Outliers)
+	assert.Equal(t, []bool{false, false, false, false}, rows["Container runtime"].Outliers)
+	assert.Equal(t, []string{"ok", "failed", "ok", "-"}, rows["Disk Check"].Values)
+
+	assert.Equal(t, []string{
+		"Kernel of 10.0.0.3 is 5.15.0-43 while most nodes have 5.4.0-100",
+		"MTU of 10.0.0.2 is 9000 while most nodes have 1500",
+		"Disk Check of 10.0.0.2 is failed while most nodes have ok",
+	}, m.Outliers())
+}
@@ -0,0 +1,130 @@
+package pmk
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// PrepArtifact is a file or directory a failed or interrupted prep-node left
+// on a node
+type PrepArtifact struct {
+	Path string
+	Kind string
+	// SizeKB is the disk space the artifact uses
+	SizeKB int64
+}
+
+// relayLeftoverAge is how many minutes the files uploaded by pf9ctl to the
+// home of the node are kept, so the ones of a running command aren't removed
+const relayLeftoverAge = 60
+
+// prepArtifactsScript lists the artifacts with their size, in the work
+// directory, in /tmp and in the home of the user. The installer and its
+// extracted directories are removed by prep-node once it ran, they are only
+// left when it was interrupted.
+const prepArtifactsScript = `{
+find %[1]s -maxdepth 1 \( -name 'pf9-install-*' -o -name installer.sh -o -name agent_install -o -name .pf9ctl-exec-check \)
+find /tmp -maxdepth 1 \( -name 'pf9-install-*' -o -name 'agent_install*' \)
+find %[2]s -maxdepth 1 -name '.pf9-*' -mmin +%[3]d
+} 2> /dev/null | while read -r f; do du -sk "$f" 2> /dev/null; done
+`
+
+// userHomeScript prints the home of the user running pf9ctl commands on the
+// node, which $HOME isn't under sudo
+const userHomeScript = `getent passwd "${SUDO_USER:-$(id -un)}" | cut -d: -f6`
+
+// userHome returns the home of the user of the node of exec, where the files
+// uploaded over SFTP are
+func userHome(exec cmdexec.Executor) (string, error) {
+	out, err := exec.RunWithStdout("bash", "-c", userHomeScript)
+	if err != nil {
+		return "", fmt.Errorf("unable to find the home of the user: %w", err)
+	}
+	home := strings.TrimSpace(out)
+	if !path.IsAbs(home) {
+		return "", fmt.Errorf("unable to find the home of the user, got %q", home)
+	}
+	return home, nil
+}
+
+// prepArtifactKind describes the artifact at p
+func prepArtifactKind(p string) string {
+	name := path.Base(p)
+	switch {
+	case strings.HasPrefix(name, "pf9-install-"):
+		return "extracted installer"
+	case name == "installer.sh":
+		return "installer"
+	case strings.HasPrefix(name, "agent_install"):
+		return "installer log"
+	case strings.HasPrefix(name, ".pf9-"):
+		return "file uploaded by pf9ctl"
+	}
+	return "work directory check"
+}
+
+// parsePrepArtifacts parses the output of du -sk, a size and a path per line
+func parsePrepArtifacts(out string) []PrepArtifact {
+	var artifacts []PrepArtifact
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		artifacts = append(artifacts, PrepArtifact{Path: fields[1], Kind: prepArtifactKind(fields[1]), SizeKB: size})
+	}
+	return artifacts
+}
+
+// FindPrepArtifacts lists the leftovers of previous preps of the node of exec
+// in workDir, $HOME/pf9 when empty, in /tmp and in the home of the user. The
+// installed packages aren't part of them.
+func FindPrepArtifacts(exec cmdexec.Executor, workDir string) ([]PrepArtifact, error) {
+	dir, err := resolveWorkDir(exec, workDir)
+	if err != nil {
+		return nil, err
+	}
+	home, err := userHome(exec)
+	if err != nil {
+		return nil, err
+	}
+	out, err := exec.RunWithStdout("bash", "-c", fmt.Sprintf(prepArtifactsScript, cmdexec.ShellQuote(dir), cmdexec.ShellQuote(home), relayLeftoverAge))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the prep artifacts: %w", err)
+	}
+	return parsePrepArtifacts(out), nil
+}
+
+// PrepRunning reports whether the installer is running on the node of exec,
+// whose files mustn't be removed
+func PrepRunning(exec cmdexec.Executor) bool {
+	// The brackets keep pgrep from matching the command lines of sudo and of
+	// the shell running it
+	_, err := exec.RunArgs("pgrep", "-f", `[/]installer\.sh|[p]f9-install-`)
+	return err == nil
+}
+
+// RemovePrepArtifacts removes the artifacts from the node of exec
+func RemovePrepArtifacts(exec cmdexec.Executor, artifacts []PrepArtifact) error {
+	if len(artifacts) == 0 {
+		return nil
+	}
+	args := []string{"-rf", "--"}
+	for _, artifact := range artifacts {
+		args = append(args, artifact.Path)
+	}
+	zap.S().Debugf("Removing the prep artifacts %s", strings.Join(args[2:], ", "))
+	if _, err := exec.RunArgs("rm", args...); err != nil {
+		return fmt.Errorf("unable to remove the prep artifacts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package pmk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindPrepArtifacts(t *testing.T) {
+	var script string
+	exec := &cmdexec.MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			switch args[1] {
+			case "echo $HOME":
+				return "/root\n", nil
+			case userHomeScript:
+				return "/home/ubuntu\n", nil
+			}
+			script = args[1]
+			return "4\t/root/pf9/installer.sh\n" +
+				"20480\t/root/pf9/pf9-install-aB3d\n" +
+				"12\t/root/pf9/agent_install\n" +
+				"8\t/tmp/pf9-install-x1\n" +
+				"1\t/home/ubuntu/.pf9-0f3a.sh\n" +
+				"du: cannot access '/tmp/gone'\n", nil
+		},
+	}
+	artifacts, err := FindPrepArtifacts(exec, "")
+	assert.NoError(t, err)
+	// The work dir is in the home of root under sudo, the uploaded files in
+	// the one of the user
+	assert.Contains(t, script, "find '/root/pf9' -maxdepth 1")
+	assert.Contains(t, script, "find '/home/ubuntu' -maxdepth 1 -name '.pf9-*'")
+	assert.NotContains(t, script, "$HOME")
+	assert.Equal(t, []PrepArtifact{
+		{"/root/pf9/installer.sh", "installer", 4},
+		{"/root/pf9/pf9-install-aB3d", "extracted installer", 20480},
+		{"/root/pf9/agent_install", "installer log", 12},
+		{"/tmp/pf9-install-x1", "extracted installer", 8},
+		{"/home/ubuntu/.pf9-0f3a.sh", "file uploaded by pf9ctl", 1},
+	}, artifacts)
+
+	_, err = FindPrepArtifacts(exec, "relative/dir")
+	assert.Error(t, err)
+}
+
+func TestRemovePrepArtifacts(t *testing.T) {
+	var removed string
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			removed = name + " " + strings.Join(args, " ")
+			return "", nil
+		},
+	}
+	assert.NoError(t, RemovePrepArtifacts(exec, nil))
+	assert.Empty(t, removed)
+
+	assert.NoError(t, RemovePrepArtifacts(exec, []PrepArtifact{{Path: "/tmp/pf9-install-x1"}, {Path: "/root/pf9/agent_install"}}))
+	assert.Equal(t, "rm -rf -- /tmp/pf9-install-x1 /root/pf9/agent_install", removed)
+
+	exec.MockRunArgs = func(name string, args ...string) (string, error) {
+		return "", errors.New("exit status 1")
+	}
+	assert.False(t, PrepRunning(exec))
+}
@@ -0,0 +1,183 @@
+package pmk
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"go.uber.org/zap"
+)
+
+// DefaultProtectedMarker is the marker file protecting a host from prep-node
+// and decommission-node, along with those of the protected_markers setting
+const DefaultProtectedMarker = "/etc/pf9/protected"
+
+// lookupIP resolves the host of the DU, replaced in tests
+var lookupIP = net.LookupIP
+
+// splitList splits a comma separated setting, dropping the empty entries
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// ValidateProtectedHosts validates the protected_hosts setting, a comma
+// separated list of IPs, CIDRs and hostnames which may have * wildcards
+func ValidateProtectedHosts(value string) error {
+	for _, entry := range splitList(value) {
+		if strings.Contains(entry, "/") {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return fmt.Errorf("invalid protected host %q: %w", entry, err)
+			}
+			continue
+		}
+		if _, err := path.Match(entry, ""); err != nil {
+			return fmt.Errorf("invalid protected host %q: %w", entry, err)
+		}
+	}
+	return nil
+}
+
+// ValidateProtectedMarkers validates the protected_markers setting, a comma
+// separated list of absolute paths
+func ValidateProtectedMarkers(value string) error {
+	for _, entry := range splitList(value) {
+		if !path.IsAbs(entry) {
+			return fmt.Errorf("invalid protected marker %q, it is an absolute path", entry)
+		}
+	}
+	return nil
+}
+
+// nodeIdentity is what a node is matched against the protected hosts on
+type nodeIdentity struct {
+	names []string
+	ips   []net.IP
+}
+
+// readNodeIdentity reads the hostnames and the addresses of the node of exec,
+// along with the address it is reached at
+func readNodeIdentity(exec cmdexec.Executor) nodeIdentity {
+	var id nodeIdentity
+	for _, args := range [][]string{{}, {"-f"}} {
+		if name, err := exec.RunArgs("hostname", args...); err == nil && strings.TrimSpace(name) != "" {
+			id.names = append(id.names, strings.ToLower(strings.TrimSpace(name)))
+		}
+	}
+	addrs := []string{cmdexec.Host(exec)}
+	if out, err := exec.RunArgs("hostname", "-I"); err == nil {
+		addrs = append(addrs, strings.Fields(out)...)
+	}
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil {
+			id.ips = append(id.ips, ip)
+		}
+	}
+	return id
+}
+
+// matches returns the address or name of id matching the protected entry
+func (id nodeIdentity) matches(entry string) (string, bool) {
+	if _, cidr, err := net.ParseCIDR(entry); err == nil {
+		for _, ip := range id.ips {
+			if cidr.Contains(ip) {
+				return ip.String(), true
+			}
+		}
+		return "", false
+	}
+	if protected := net.ParseIP(entry); protected != nil {
+		for _, ip := range id.ips {
+			if ip.Equal(protected) {
+				return ip.String(), true
+			}
+		}
+		return "", false
+	}
+	for _, name := range id.names {
+		if ok, _ := path.Match(strings.ToLower(entry), name); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// runsDU returns the address of id the DU at fqdn resolves to, when the node
+// is the machine running the DU
+func (id nodeIdentity) runsDU(fqdn string) (string, bool) {
+	host := strings.ToLower(DUHost(fqdn))
+	if host == "" {
+		return "", false
+	}
+	for _, name := range id.names {
+		if name == host {
+			return name, true
+		}
+	}
+	duIPs := []net.IP{net.ParseIP(host)}
+	if duIPs[0] == nil {
+		var err error
+		if duIPs, err = lookupIP(host); err != nil {
+			zap.S().Debugf("Unable to resolve the DU %s to check it doesn't run on the node: %s", host, err)
+			return "", false
+		}
+	}
+	for _, duIP := range duIPs {
+		for _, ip := range id.ips {
+			if ip.Equal(duIP) && !ip.IsLoopback() {
+				return ip.String(), true
+			}
+		}
+	}
+	return "", false
+}
+
+// existingMarkers returns the marker files which exist on the node of exec
+func existingMarkers(exec cmdexec.Executor, markers []string) []string {
+	var quoted []string
+	for _, marker := range markers {
+		quoted = append(quoted, cmdexec.ShellQuote(marker))
+	}
+	script := fmt.Sprintf(`for f in %s; do [ -e "$f" ] && echo "$f"; done; true`, strings.Join(quoted, " "))
+	out, err := exec.RunArgs("bash", "-c", script)
+	if err != nil {
+		zap.S().Debugf("Unable to look for the protected markers: %s", err)
+		return nil
+	}
+	var found []string
+	for _, line := range strings.Split(out, "\n") {
+		if line != "" {
+			found = append(found, line)
+		}
+	}
+	return found
+}
+
+// ProtectedReason returns why the node of exec is protected from prep-node
+// and decommission-node, empty when it isn't. A node is protected when it
+// runs the DU of cfg, matches the protected_hosts setting, or has one of the
+// marker files of protected_markers or DefaultProtectedMarker.
+func ProtectedReason(exec cmdexec.Executor, cfg *objects.Config) string {
+	id := readNodeIdentity(exec)
+	if addr, ok := id.runsDU(cfg.Fqdn); ok {
+		return fmt.Sprintf("it runs the DU %s (%s)", DUHost(cfg.Fqdn), addr)
+	}
+	for _, entry := range splitList(cfg.ProtectedHosts) {
+		if addr, ok := id.matches(entry); ok {
+			return fmt.Sprintf("%s matches %s of the protected_hosts setting", addr, entry)
+		}
+	}
+	markers := append([]string{DefaultProtectedMarker}, splitList(cfg.ProtectedMarkers)...)
+	if found := existingMarkers(exec, markers); len(found) > 0 {
+		return fmt.Sprintf("it has the marker file %s", found[0])
+	}
+	return ""
+}
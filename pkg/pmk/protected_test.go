@@ -0,0 +1,76 @@
+package pmk
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/stretchr/testify/assert"
+)
+
+// protectedExecutor answers as the node node1.infra.example.com at 10.0.0.5
+// with the marker files markers
+func protectedExecutor(markers ...string) *cmdexec.MockExecutor {
+	return &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			switch {
+			case name == "hostname" && len(args) == 0:
+				return "node1\n", nil
+			case name == "hostname" && args[0] == "-f":
+				return "node1.infra.example.com\n", nil
+			case name == "hostname" && args[0] == "-I":
+				return "10.0.0.5 fd00::5 \n", nil
+			case name == "bash":
+				var found []string
+				for _, marker := range markers {
+					if strings.Contains(args[1], marker) {
+						found = append(found, marker)
+					}
+				}
+				return strings.Join(found, "\n"), nil
+			}
+			return "", errors.New("unexpected command")
+		},
+	}
+}
+
+func TestProtectedReason(t *testing.T) {
+	lookupIP = func(host string) ([]net.IP, error) {
+		if host == "du.example.com" {
+			return []net.IP{net.ParseIP("10.0.0.5")}, nil
+		}
+		return []net.IP{net.ParseIP("192.0.2.10")}, nil
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	cases := map[string]struct {
+		cfg     objects.Config
+		markers []string
+		want    string
+	}{
+		"NotProtected":  {objects.Config{Fqdn: "https://saas.platform9.net", ProtectedHosts: "10.1.0.0/16,db-*"}, nil, ""},
+		"RunsDU":        {objects.Config{Fqdn: "https://du.example.com/"}, nil, "it runs the DU du.example.com (10.0.0.5)"},
+		"DUHostname":    {objects.Config{Fqdn: "https://NODE1.infra.example.com"}, nil, "it runs the DU NODE1.infra.example.com (node1.infra.example.com)"},
+		"CIDR":          {objects.Config{ProtectedHosts: "192.168.0.0/16, 10.0.0.0/24"}, nil, "10.0.0.5 matches 10.0.0.0/24 of the protected_hosts setting"},
+		"IPv6":          {objects.Config{ProtectedHosts: "fd00::5"}, nil, "fd00::5 matches fd00::5 of the protected_hosts setting"},
+		"Wildcard":      {objects.Config{ProtectedHosts: "*.infra.example.com"}, nil, "node1.infra.example.com matches *.infra.example.com of the protected_hosts setting"},
+		"DefaultMarker": {objects.Config{}, []string{DefaultProtectedMarker}, "it has the marker file /etc/pf9/protected"},
+		"Marker":        {objects.Config{ProtectedMarkers: "/etc/kubernetes/admin.conf"}, []string{"/etc/kubernetes/admin.conf"}, "it has the marker file /etc/kubernetes/admin.conf"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ProtectedReason(protectedExecutor(tc.markers...), &tc.cfg))
+		})
+	}
+}
+
+func TestValidateProtectedSettings(t *testing.T) {
+	assert.NoError(t, ValidateProtectedHosts("10.0.0.0/24, infra-*,du.example.com,fd00::1"))
+	assert.Error(t, ValidateProtectedHosts("10.0.0.0/33"))
+	assert.Error(t, ValidateProtectedHosts("infra-[a"))
+	assert.NoError(t, ValidateProtectedMarkers("/etc/pf9/du,/opt/infra marker"))
+	assert.EqualError(t, ValidateProtectedMarkers("etc/pf9/du"), `invalid protected marker "etc/pf9/du", it is an absolute path`)
+}
@@ -0,0 +1,137 @@
+package pmk
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// Connectivity models of the nodes to the DU
+const (
+	ReachDirect = "direct"
+	ReachProxy  = "proxy"
+	ReachTunnel = "tunnel"
+)
+
+// reachTimeout is how long the node has to reach the DU, in seconds
+const reachTimeout = "15"
+
+// NodeReachability is how a node reaches the DU
+type NodeReachability struct {
+	Model string
+	// Via is the proxy the DU is reached through, empty for direct
+	Via string
+	// Status is the HTTP status answered by the DU
+	Status string
+	Err    error
+}
+
+// OK is true when the DU answered the node
+func (r NodeReachability) OK() bool {
+	return r.Err == nil
+}
+
+// ProbeNodeReachability checks how the node of the executor reaches the
+// keystone of the DU at fqdn: directly, through proxyURL when set and through
+// a tunnel opened on tunnelPort for the probe when it isn't 0. The executor
+// must not add a proxy to the commands.
+func ProbeNodeReachability(exec cmdexec.Executor, fqdn, proxyURL string, tunnelPort int) []NodeReachability {
+	url := DUEndpoints(fqdn, "")["keystone"]
+	results := []NodeReachability{probeFromNode(exec, ReachDirect, url, "")}
+	if proxyURL != "" {
+		results = append(results, probeFromNode(exec, ReachProxy, url, proxyURL))
+	}
+	if tunnelPort != 0 {
+		tunnelURL := TunnelProxyURL(tunnelPort)
+		tunnel, err := OpenDUTunnel(exec, tunnelPort, fqdn)
+		if err != nil {
+			results = append(results, NodeReachability{Model: ReachTunnel, Via: tunnelURL, Err: err})
+		} else {
+			results = append(results, probeFromNode(exec, ReachTunnel, url, tunnelURL))
+			tunnel.Close()
+		}
+	}
+	return results
+}
+
+// WorkingModels returns the models the DU answered the node with
+func WorkingModels(results []NodeReachability) []string {
+	var models []string
+	for _, r := range results {
+		if r.OK() {
+			models = append(models, r.Model)
+		}
+	}
+	return models
+}
+
+// probeFromNode requests url with curl on the node, through proxy when set
+func probeFromNode(exec cmdexec.Executor, model, url, proxy string) NodeReachability {
+	result := NodeReachability{Model: model, Via: proxy}
+	args := []string{"--silent", "--output", "/dev/null", "--write-out", "%{http_code}", "--max-time", reachTimeout}
+	if proxy != "" {
+		args = append(args, "--proxy", proxy)
+	} else {
+		args = append(args, "--noproxy", "*")
+	}
+	// The DU is only being reached, its certificate is checked by check-du
+	args = append(args, "--insecure", url)
+	out, err := exec.RunArgs("curl", args...)
+	result.Status = strings.TrimSpace(out)
+	if err != nil {
+		zap.S().Debugf("Node is unable to reach %s (%s): %s", url, model, err)
+		result.Err = curlError(err, proxy)
+		return result
+	}
+	if code, _ := strconv.Atoi(result.Status); code == 0 || code >= 500 {
+		result.Err = fmt.Errorf("the DU answered with status %s", result.Status)
+	}
+	return result
+}
+
+var exitStatusPattern = regexp.MustCompile(`exit(?:ed with)? status (\d+)`)
+
+// curlExitCode returns the exit code of the failed curl command
+func curlExitCode(err error) int {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	if m := exitStatusPattern.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return code
+	}
+	return -1
+}
+
+// curlError explains why curl failed to reach the DU
+func curlError(err error, proxy string) error {
+	switch code := curlExitCode(err); code {
+	case 5:
+		return fmt.Errorf("the node can't resolve the proxy %s", proxy)
+	case 6:
+		return fmt.Errorf("the node can't resolve the DU, check its DNS")
+	case 7:
+		if proxy != "" {
+			return fmt.Errorf("the node can't connect to the proxy %s", proxy)
+		}
+		return fmt.Errorf("the node can't connect to the DU, the connection is refused or there is no route")
+	case 28:
+		return fmt.Errorf("the connection timed out, egress from the node is likely blocked by a firewall")
+	case 35, 60:
+		return fmt.Errorf("the TLS handshake with the DU failed, the traffic may be intercepted")
+	case 56:
+		if proxy != "" {
+			return fmt.Errorf("the proxy %s refused to connect to the DU", proxy)
+		}
+		return fmt.Errorf("the connection to the DU was reset")
+	case 127:
+		return fmt.Errorf("curl is not installed on the node")
+	default:
+		return fmt.Errorf("curl failed with exit code %d", code)
+	}
+}
@@ -0,0 +1,44 @@
+package pmk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurlExitCode(t *testing.T) {
+	assert.Equal(t, 28, curlExitCode(errors.New("command sudo curl failed: Process exited with status 28")))
+	assert.Equal(t, 7, curlExitCode(errors.New("exit status 7")))
+	assert.Equal(t, -1, curlExitCode(errors.New("unable to create session")))
+}
+
+func TestProbeNodeReachability(t *testing.T) {
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			for i, arg := range args {
+				if arg == "--proxy" && args[i+1] == "http://squid:3128" {
+					return "401", nil
+				}
+			}
+			return "000", errors.New("Process exited with status 28")
+		},
+	}
+
+	results := ProbeNodeReachability(exec, "https://du.platform9.net", "http://squid:3128", DefaultTunnelPort)
+	assert.Len(t, results, 3)
+
+	assert.Equal(t, ReachDirect, results[0].Model)
+	assert.EqualError(t, results[0].Err, "the connection timed out, egress from the node is likely blocked by a firewall")
+
+	assert.Equal(t, ReachProxy, results[1].Model)
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, "401", results[1].Status)
+
+	// The mock can't forward ports
+	assert.Equal(t, ReachTunnel, results[2].Model)
+	assert.Error(t, results[2].Err)
+
+	assert.Equal(t, []string{ReachProxy}, WorkingModels(results))
+}
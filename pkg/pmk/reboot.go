@@ -0,0 +1,309 @@
+package pmk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// Reboot of the node by prep-node, when a remediation only applies once the
+// node rebooted
+var (
+	// RebootTimeout is how long the node has to come back after a reboot
+	RebootTimeout = 10 * time.Minute
+	// rebootPollInterval is the delay between the attempts to reconnect to
+	// the rebooting node
+	rebootPollInterval = 5 * time.Second
+)
+
+// Files of the node recording the reboot
+const (
+	// rebootMarker lists the remediations waiting for a reboot, a line each
+	// with the check they applied after a tab. The remediations needing a
+	// reboot add to it with RequireReboot. It is in /run so any reboot of the
+	// node clears it.
+	rebootMarker = "/run/pf9ctl-reboot-required"
+	// prepCheckpoint is where prep-node records its progress before the
+	// reboot, so it resumes once the node is back
+	prepCheckpoint = "/etc/pf9/pf9ctl-prep-checkpoint.json"
+)
+
+// ErrRebootRequired is returned by prep-node when the node needs a reboot it
+// can't do itself
+var ErrRebootRequired = errors.New("the node needs a reboot")
+
+// ErrInterrupted is reported when prep-node is interrupted by a signal
+var ErrInterrupted = errors.New("prep-node was interrupted")
+
+// rebootReasonsScript prints why the node needs a reboot, a reason per line.
+// Swap still in use once it was removed from fstab is only gone after a
+// reboot, as are the updates of the OS needing one.
+const rebootReasonsScript = `[ -f %[1]s ] && cat %[1]s
+if [ "$(swapon --noheadings --show 2> /dev/null | wc -l)" -gt 0 ] && ! grep -Eq '^[[:space:]]*[^#[:space:]]+[[:space:]]+[^[:space:]]+[[:space:]]+swap[[:space:]]' /etc/fstab; then
+    echo "swap is still in use once removed from /etc/fstab"
+fi
+if [ -f /var/run/reboot-required ]; then
+    echo "the updates of the OS need a reboot"
+elif command -v needs-restarting > /dev/null && ! needs-restarting -r > /dev/null 2>&1; then
+    echo "the updates of the OS need a reboot"
+fi
+true
+`
+
+// RebootReasons returns why the node of exec needs a reboot, none when it
+// doesn't
+func RebootReasons(exec cmdexec.Executor) ([]string, error) {
+	out, err := exec.RunArgs("bash", "-c", fmt.Sprintf(rebootReasonsScript, rebootMarker))
+	if err != nil {
+		return nil, fmt.Errorf("unable to check whether the node needs a reboot: %w", err)
+	}
+	var reasons []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		reason, _ := splitRebootLine(line)
+		if reason != "" && !seen[reason] {
+			seen[reason] = true
+			reasons = append(reasons, reason)
+		}
+	}
+	return reasons, nil
+}
+
+// splitRebootLine splits a line of the reboot marker in its reason and the
+// check of its remediation
+func splitRebootLine(line string) (string, string) {
+	i := strings.Index(line, "\t")
+	if i < 0 {
+		return strings.TrimSpace(line), ""
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+}
+
+// RequireReboot records that the remediation reason only applies once the
+// node of exec rebooted, prep-node reboots it with --allow-reboot. check is
+// the shell command succeeding once the remediation applied, run after the
+// reboot, none when empty.
+func RequireReboot(exec cmdexec.Executor, reason, check string) error {
+	line := reason
+	if check != "" {
+		line += "\t" + check
+	}
+	script := fmt.Sprintf("grep -qxF %[1]s %[2]s 2> /dev/null || printf '%%s\\n' %[1]s >> %[2]s", cmdexec.ShellQuote(line), rebootMarker)
+	if _, err := exec.RunArgs("bash", "-c", script); err != nil {
+		return fmt.Errorf("unable to record that the node needs a reboot: %w", err)
+	}
+	return nil
+}
+
+// bootID returns the ID of the current boot of the node of exec, which changes
+// with each reboot
+func bootID(exec cmdexec.Executor) (string, error) {
+	out, err := exec.RunArgs("cat", "/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", fmt.Errorf("unable to read the boot ID of the node: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// checkpoint is the progress of prep-node recorded before the reboot, or
+// when it was interrupted
+type checkpoint struct {
+	// BootID is the boot before the reboot
+	BootID string `json:"bootId"`
+	// Phases are the phases done before the reboot
+	Phases []string `json:"phases"`
+	// Interrupted is the phase prep-node was interrupted in, empty when it
+	// stopped to reboot the node
+	Interrupted string `json:"interrupted,omitempty"`
+	// Reasons are why the node was rebooted
+	Reasons []string `json:"reasons"`
+	// Checks are the checks of the remediations waiting for the reboot, by
+	// reason
+	Checks    map[string]string `json:"checks,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// done is true when phase was done before the reboot
+func (c *checkpoint) done(phase string) bool {
+	if c == nil {
+		return false
+	}
+	for _, p := range c.Phases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+func writeCheckpoint(exec cmdexec.Executor, c checkpoint) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	script := fmt.Sprintf("mkdir -p /etc/pf9 && echo %s > %s", cmdexec.ShellQuote(string(data)), prepCheckpoint)
+	if _, err := exec.RunArgs("bash", "-c", script); err != nil {
+		return fmt.Errorf("unable to record the progress of prep-node on the node: %w", err)
+	}
+	return nil
+}
+
+// readCheckpoint returns the progress of prep-node recorded before the node
+// rebooted or before prep-node was interrupted, nil when there is none or the
+// node hasn't rebooted since
+func readCheckpoint(exec cmdexec.Executor) *checkpoint {
+	out, err := exec.RunArgs("bash", "-c", fmt.Sprintf("[ ! -f %[1]s ] || cat %[1]s", prepCheckpoint))
+	if err != nil || strings.TrimSpace(out) == "" {
+		return nil
+	}
+	var c checkpoint
+	if err := json.Unmarshal([]byte(out), &c); err != nil {
+		zap.S().Debugf("Ignoring the checkpoint of prep-node %s: %s", prepCheckpoint, err)
+		return nil
+	}
+	current, err := bootID(exec)
+	if err != nil || (current == c.BootID && c.Interrupted == "") {
+		return nil
+	}
+	return &c
+}
+
+// recordInterruption records the phases done before prep-node was
+// interrupted in phase, the next prep-node of the node resumes after them
+func recordInterruption(exec cmdexec.Executor, done []string, phase string) error {
+	id, err := bootID(exec)
+	if err != nil {
+		return err
+	}
+	return writeCheckpoint(exec, checkpoint{BootID: id, Phases: done, Interrupted: phase, CreatedAt: time.Now().UTC()})
+}
+
+// clearReboot removes the checkpoint once the node rebooted
+func clearReboot(exec cmdexec.Executor) {
+	if _, err := exec.RunArgs("rm", "-f", prepCheckpoint); err != nil {
+		zap.S().Debugf("Unable to remove the checkpoint of prep-node: %s", err)
+	}
+}
+
+// verifyReboot checks the reasons of the reboot are gone: the checks of the
+// remediations waiting for the reboot pass and the node doesn't need another
+// reboot
+func verifyReboot(exec cmdexec.Executor, c *checkpoint) error {
+	var remaining []string
+	for _, reason := range c.Reasons {
+		check, ok := c.Checks[reason]
+		if !ok {
+			continue
+		}
+		if _, err := exec.RunArgs("bash", "-c", check); err != nil {
+			zap.S().Debugf("The check %q of %q fails after the reboot: %s", check, reason, err)
+			remaining = append(remaining, reason)
+		}
+	}
+	reasons, err := RebootReasons(exec)
+	if err != nil {
+		return err
+	}
+	for _, reason := range reasons {
+		for _, before := range c.Reasons {
+			if reason == before {
+				remaining = append(remaining, reason)
+			}
+		}
+	}
+	if len(remaining) > 0 {
+		return fmt.Errorf("the reboot didn't apply: %s", strings.Join(remaining, ", "))
+	}
+	return nil
+}
+
+// rebootCommand reboots the node in the background, so the command returns
+// before the SSH connection drops
+const rebootCommand = "setsid nohup sh -c 'sleep 3; systemctl reboot || reboot' > /dev/null 2>&1 &"
+
+// RebootNode reboots the node of exec and waits for it to come back, until
+// RebootTimeout. exec is connected to the node again once it returns.
+func RebootNode(exec cmdexec.Executor) error {
+	before, err := bootID(exec)
+	if err != nil {
+		return err
+	}
+	zap.S().Debugf("Rebooting the node, boot %s", before)
+	if _, err := exec.RunArgs("bash", "-c", rebootCommand); err != nil {
+		return fmt.Errorf("unable to reboot the node: %w", err)
+	}
+
+	deadline := time.Now().Add(RebootTimeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		time.Sleep(rebootPollInterval)
+		if lastErr = cmdexec.Reconnect(exec); lastErr != nil {
+			zap.S().Debugf("The node isn't back yet: %s", lastErr)
+			continue
+		}
+		var after string
+		if after, lastErr = bootID(exec); lastErr != nil {
+			continue
+		}
+		if after != before {
+			zap.S().Debugf("The node is back, boot %s", after)
+			return nil
+		}
+		lastErr = errors.New("the node hasn't rebooted yet")
+	}
+	return fmt.Errorf("the node didn't come back within %s of the reboot: %v", RebootTimeout, lastErr)
+}
+
+// rebootForPrep records the progress of prep-node, reboots the node and
+// verifies the reasons of the reboot are gone. The node of this machine isn't
+// rebooted, prep-node resumes from its checkpoint once it is rebooted and
+// prep-node run again.
+func rebootForPrep(exec cmdexec.Executor, done []string, reasons []string) error {
+	id, err := bootID(exec)
+	if err != nil {
+		return err
+	}
+	c := checkpoint{BootID: id, Phases: done, Reasons: reasons, Checks: rebootChecks(exec), CreatedAt: time.Now().UTC()}
+	if err := writeCheckpoint(exec, c); err != nil {
+		return err
+	}
+	if cmdexec.Host(exec) == "localhost" {
+		return fmt.Errorf("%w, which pf9ctl can't do on the machine it runs on: reboot it and run prep-node again, it resumes where it stopped", ErrRebootRequired)
+	}
+	if err := RebootNode(exec); err != nil {
+		// The next prep-node runs every phase again rather than resuming
+		// from a reboot which may not have happened
+		clearReboot(exec)
+		return err
+	}
+	return verifyReboot(exec, &c)
+}
+
+// rebootChecks returns the checks of the remediations waiting for the reboot,
+// by reason
+func rebootChecks(exec cmdexec.Executor) map[string]string {
+	checks := make(map[string]string)
+	out, err := exec.RunArgs("bash", "-c", fmt.Sprintf("[ ! -f %[1]s ] || cat %[1]s", rebootMarker))
+	if err != nil {
+		zap.S().Debugf("Unable to read the remediations waiting for the reboot: %s", err)
+		return checks
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if reason, check := splitRebootLine(line); check != "" {
+			checks[reason] = check
+		}
+	}
+	return checks
+}
+
+// rebootRequiredMessage is the warning of prep-node when the node needs a
+// reboot it isn't allowed to do
+func rebootRequiredMessage(reasons []string) string {
+	return fmt.Sprintf("The node needs a reboot: %s. Run prep-node with --allow-reboot to reboot it and resume, or reboot it before creating the cluster",
+		strings.Join(reasons, ", "))
+}
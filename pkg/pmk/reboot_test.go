@@ -0,0 +1,122 @@
+package pmk
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+// rebootNode is a node whose files are in memory, boot is its boot ID and
+// failing the checks which fail
+type rebootNode struct {
+	files   map[string]string
+	boot    string
+	swap    bool
+	failing map[string]bool
+}
+
+func (n *rebootNode) executor() *cmdexec.MockExecutor {
+	return &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			switch name {
+			case "cat":
+				return n.boot + "\n", nil
+			case "rm":
+				for _, path := range args[1:] {
+					delete(n.files, path)
+				}
+				return "", nil
+			case "bash":
+				script := args[1]
+				switch {
+				case strings.HasPrefix(script, "[ -f "+rebootMarker):
+					out := n.files[rebootMarker]
+					if n.swap {
+						out += "swap is still in use once removed from /etc/fstab\n"
+					}
+					return out, nil
+				case strings.Contains(script, "cat "+rebootMarker):
+					return n.files[rebootMarker], nil
+				case strings.Contains(script, "cat "+prepCheckpoint):
+					return n.files[prepCheckpoint], nil
+				case n.failing[script]:
+					return "", errors.New("exit status 1")
+				}
+				return "", nil
+			}
+			return "", errors.New("unexpected command")
+		},
+	}
+}
+
+func TestRebootReasons(t *testing.T) {
+	n := &rebootNode{
+		files: map[string]string{rebootMarker: "cgroup v2 is enabled on the kernel command line\tgrep -q cgroup2 /proc/mounts\n" +
+			"cgroup v2 is enabled on the kernel command line\n"},
+		swap: true,
+	}
+	reasons, err := RebootReasons(n.executor())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cgroup v2 is enabled on the kernel command line", "swap is still in use once removed from /etc/fstab"}, reasons)
+
+	reason, check := splitRebootLine("swap\tswapon --show")
+	assert.Equal(t, "swap", reason)
+	assert.Equal(t, "swapon --show", check)
+}
+
+func TestReadCheckpoint(t *testing.T) {
+	data, _ := json.Marshal(checkpoint{BootID: "boot-1", Phases: []string{phaseValidateOS, phaseHostID}})
+	n := &rebootNode{files: map[string]string{prepCheckpoint: string(data)}, boot: "boot-1"}
+
+	// The node hasn't rebooted yet
+	assert.Nil(t, readCheckpoint(n.executor()))
+
+	n.boot = "boot-2"
+	c := readCheckpoint(n.executor())
+	if assert.NotNil(t, c) {
+		assert.True(t, c.done(phaseHostID))
+		assert.False(t, c.done(phaseKernelTuning))
+	}
+	var none *checkpoint
+	assert.False(t, none.done(phaseHostID))
+
+	n.files[prepCheckpoint] = "{"
+	assert.Nil(t, readCheckpoint(n.executor()))
+
+	// An interrupted prep-node resumes without a reboot
+	data, _ = json.Marshal(checkpoint{BootID: "boot-2", Phases: []string{phaseExistingPkgs}, Interrupted: phaseInstallAgent})
+	n.files[prepCheckpoint] = string(data)
+	c = readCheckpoint(n.executor())
+	if assert.NotNil(t, c) {
+		assert.Equal(t, phaseInstallAgent, c.Interrupted)
+		assert.True(t, c.done(phaseExistingPkgs))
+	}
+}
+
+func TestVerifyReboot(t *testing.T) {
+	cgroup := "cgroup v2 is enabled on the kernel command line"
+	before := &rebootNode{files: map[string]string{rebootMarker: cgroup + "\tgrep -q cgroup2 /proc/mounts\n"}, boot: "boot-1"}
+	c := &checkpoint{
+		BootID:  "boot-1",
+		Reasons: []string{cgroup, "swap is still in use once removed from /etc/fstab"},
+		Checks:  rebootChecks(before.executor()),
+	}
+	assert.Equal(t, map[string]string{cgroup: "grep -q cgroup2 /proc/mounts"}, c.Checks)
+
+	// The reboot cleared the marker
+	n := &rebootNode{files: map[string]string{}, boot: "boot-2"}
+	assert.NoError(t, verifyReboot(n.executor(), c))
+
+	n = &rebootNode{
+		files:   map[string]string{},
+		boot:    "boot-2",
+		swap:    true,
+		failing: map[string]bool{"grep -q cgroup2 /proc/mounts": true},
+	}
+	assert.EqualError(t, verifyReboot(n.executor(), c),
+		"the reboot didn't apply: cgroup v2 is enabled on the kernel command line, swap is still in use once removed from /etc/fstab")
+}
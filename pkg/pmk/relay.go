@@ -0,0 +1,197 @@
+package pmk
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/fips"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/socks"
+	"go.uber.org/zap"
+)
+
+// DefaultTunnelPort is the port of the loopback of the nodes their DU traffic
+// is sent to when it is tunneled through the machine running pf9ctl
+const DefaultTunnelPort = 3129
+
+// tunnelDialTimeout is how long connecting to the DU for a node can take
+const tunnelDialTimeout = 30 * time.Second
+
+// relayInstaller downloads the installer at url on this machine and copies it
+// to dest on the node, for nodes which can't reach the DU themselves. The
+// download limit of the nodes doesn't apply.
+func relayInstaller(exec cmdexec.Executor, url string, insecure bool, headers map[string]string, dest string) error {
+	zap.S().Debugf("Relaying the installer %s to the node", url)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("unable to create a http request: %w", err)
+	}
+	req.Header.Set(log.CorrelationIDHeader, log.CorrelationID)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := duClient(insecure).Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to download the installer from %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to download the installer from %s, code: %d", url, resp.StatusCode)
+	}
+
+	local, err := ioutil.TempFile("", "pf9-installer-")
+	if err != nil {
+		return fmt.Errorf("unable to create the installer file: %w", err)
+	}
+	defer os.Remove(local.Name())
+	size, err := io.Copy(local, resp.Body)
+	if closeErr := local.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to download the installer from %s: %w", url, err)
+	}
+	head, err := readFileHead(local.Name())
+	if err != nil {
+		return err
+	}
+	if err := checkInstallerContent(url, size, head); err != nil {
+		return err
+	}
+	return cmdexec.CopyFile(exec, local.Name(), dest, 0755)
+}
+
+// duClient returns the client of the downloads from the DU, which skips the
+// verification of its certificate when insecure is set
+func duClient(insecure bool) *http.Client {
+	if !insecure {
+		return http.DefaultClient
+	}
+	return &http.Client{Transport: &http.Transport{
+		Proxy:           socks.Proxy,
+		TLSClientConfig: fips.TLSConfig(&tls.Config{InsecureSkipVerify: true}),
+	}}
+}
+
+func readFileHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readInstallerHead(f), nil
+}
+
+// TunnelProxyURL is the proxy of the nodes whose DU traffic is tunneled
+// through the machine running pf9ctl from port
+func TunnelProxyURL(port int) string {
+	return fmt.Sprintf("http://127.0.0.1:%d", port)
+}
+
+// OpenDUTunnel tunnels the DU traffic of the node of the executor through this
+// machine. The node is given an HTTP proxy on port of its loopback, forwarded
+// over SSH, which only connects to the HTTPS port of duURLs. The tunnel lasts
+// until it is closed or pf9ctl exits.
+func OpenDUTunnel(exec cmdexec.Executor, port int, duURLs ...string) (io.Closer, error) {
+	listener, err := cmdexec.ListenRemote(exec, fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("unable to listen on port %d of the node, check sshd allows TCP forwarding and the port is free: %w", port, err)
+	}
+	dial := func(network, addr string) (net.Conn, error) {
+		return socks.DialTimeout(network, addr, tunnelDialTimeout)
+	}
+	go serveTunnel(listener, tunnelTargets(duURLs), dial)
+	return listener, nil
+}
+
+// tunnelTargets returns the host:port the tunnels connect to for the URLs or
+// FQDNs of the DU
+func tunnelTargets(duURLs []string) map[string]bool {
+	targets := make(map[string]bool)
+	for _, duURL := range duURLs {
+		if duURL == "" {
+			continue
+		}
+		if !strings.Contains(duURL, "://") {
+			duURL = "https://" + duURL
+		}
+		u, err := url.Parse(duURL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			port = "443"
+		}
+		targets[net.JoinHostPort(strings.ToLower(u.Hostname()), port)] = true
+	}
+	return targets
+}
+
+// serveTunnel serves the connections accepted by listener until it is closed
+func serveTunnel(listener net.Listener, targets map[string]bool, dial func(network, addr string) (net.Conn, error)) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			zap.S().Debugf("Tunnel closed: %s", err)
+			return
+		}
+		go handleTunnelConn(conn, targets, dial)
+	}
+}
+
+// handleTunnelConn answers the CONNECT request of the node and copies the
+// traffic between the node and the DU
+func handleTunnelConn(conn net.Conn, targets map[string]bool, dial func(network, addr string) (net.Conn, error)) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		zap.S().Debugf("Invalid tunnel request: %s", err)
+		return
+	}
+	if req.Method != http.MethodConnect {
+		fmt.Fprint(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+		return
+	}
+	target := strings.ToLower(req.Host)
+	if !targets[target] {
+		zap.S().Debugf("Refusing to tunnel the connection to %s, only the DU is reachable", req.Host)
+		fmt.Fprint(conn, "HTTP/1.1 403 Forbidden\r\n\r\n")
+		return
+	}
+	upstream, err := dial("tcp", target)
+	if err != nil {
+		zap.S().Debugf("Unable to connect to %s for the tunnel: %s", target, err)
+		fmt.Fprint(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	defer upstream.Close()
+	zap.S().Debugf("Tunneling a connection to %s", target)
+	fmt.Fprint(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+
+	// Either side closing ends the connection
+	var once sync.Once
+	done := make(chan struct{})
+	stop := func() { once.Do(func() { close(done) }) }
+	go func() {
+		io.Copy(upstream, reader)
+		stop()
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		stop()
+	}()
+	<-done
+}
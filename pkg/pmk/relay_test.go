@@ -0,0 +1,101 @@
+package pmk
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTunnelTargets(t *testing.T) {
+	targets := tunnelTargets([]string{"https://du.platform9.net", "region1.platform9.net", "https://Other.example.com:8443/path", ""})
+	assert.Equal(t, map[string]bool{
+		"du.platform9.net:443":      true,
+		"region1.platform9.net:443": true,
+		"other.example.com:8443":    true,
+	}, targets)
+}
+
+func TestTunnel(t *testing.T) {
+	// The DU answers with what it receives
+	du, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer du.Close()
+	go func() {
+		for {
+			conn, err := du.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				fmt.Fprintf(conn, "du: %s", line)
+			}()
+		}
+	}()
+	dial := func(network, addr string) (net.Conn, error) {
+		return net.Dial(network, du.Addr().String())
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go serveTunnel(listener, tunnelTargets([]string{"du.platform9.net"}), dial)
+
+	connect := func(request string) string {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		assert.NoError(t, err)
+		defer conn.Close()
+		fmt.Fprint(conn, request)
+		reply, _ := ioutil.ReadAll(conn)
+		return string(reply)
+	}
+
+	reply := connect("CONNECT du.platform9.net:443 HTTP/1.1\r\nHost: du.platform9.net:443\r\n\r\nhello\n")
+	assert.Equal(t, "HTTP/1.1 200 Connection established\r\n\r\ndu: hello\n", reply)
+
+	reply = connect("CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n")
+	assert.True(t, strings.HasPrefix(reply, "HTTP/1.1 403"), reply)
+
+	reply = connect("GET http://du.platform9.net/ HTTP/1.1\r\nHost: du.platform9.net\r\n\r\n")
+	assert.True(t, strings.HasPrefix(reply, "HTTP/1.1 405"), reply)
+}
+
+func TestRelayInstaller(t *testing.T) {
+	installer := "#!/bin/bash\n" + strings.Repeat("#", minInstallerSize)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, installer)
+	}))
+	defer server.Close()
+
+	var copied string
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			assert.Equal(t, "install", name)
+			assert.Equal(t, []string{"-m", "755"}, args[:2])
+			assert.Equal(t, "/root/pf9/installer.sh", args[3])
+			content, err := ioutil.ReadFile(args[2])
+			copied = string(content)
+			return "", err
+		},
+	}
+
+	err := relayInstaller(exec, server.URL, false, map[string]string{"X-Auth-Token": "token"}, "/root/pf9/installer.sh")
+	assert.NoError(t, err)
+	assert.Equal(t, installer, copied)
+
+	err = relayInstaller(exec, server.URL, false, nil, "/root/pf9/installer.sh")
+	assert.EqualError(t, err, fmt.Sprintf("unable to download the installer from %s, code: 401", server.URL))
+}
@@ -0,0 +1,163 @@
+package pmk
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/ui"
+)
+
+// NodeReplacement is a node of a cluster replaced by a new node
+type NodeReplacement struct {
+	ClusterName string
+	ClusterUuid string
+	OldIP       string
+	OldHostID   string
+	NewIP       string
+	// Role is the role of the old node, master or worker, the new node is
+	// attached with
+	Role string
+	// Labels are the labels of the old node which are copied to the new one
+	Labels map[string]string
+}
+
+// PlanReplacement checks the node with oldIP is part of the cluster and the
+// node with newIP isn't part of any, and returns the role and the labels of
+// the old node the new one takes over.
+func PlanReplacement(c client.Client, auth keystone.KeystoneAuth, fqdn, clusterName, oldIP, newIP string) (NodeReplacement, error) {
+	if oldIP == newIP {
+		return NodeReplacement{}, fmt.Errorf("the old and the new node are the same node %s", oldIP)
+	}
+	_, clusterUuid, _, err := c.Qbert.CheckClusterExists(clusterName, auth.ProjectID, auth.Token)
+	if err != nil {
+		return NodeReplacement{}, fmt.Errorf("Unable to check cluster %s: %w", clusterName, err)
+	}
+	if clusterUuid == "" {
+		return NodeReplacement{}, fmt.Errorf("cluster %s does not exist", clusterName)
+	}
+
+	hostIDs := c.Resmgr.GetHostId(auth.Token, []string{oldIP})
+	if len(hostIDs) == 0 {
+		return NodeReplacement{}, fmt.Errorf("node %s is not known to the DU", oldIP)
+	}
+	old := c.Qbert.GetNodeInfo(auth.Token, auth.ProjectID, hostIDs[0])
+	if old.ClusterUuid != clusterUuid {
+		return NodeReplacement{}, fmt.Errorf("node %s is not part of cluster %s", oldIP, clusterName)
+	}
+	if newIDs := c.Resmgr.GetHostId(auth.Token, []string{newIP}); len(newIDs) > 0 {
+		if node := c.Qbert.GetNodeInfo(auth.Token, auth.ProjectID, newIDs[0]); node.ClusterUuid != "" {
+			return NodeReplacement{}, fmt.Errorf("node %s is already part of cluster %s", newIP, node.ClusterName)
+		}
+	}
+
+	r := NodeReplacement{
+		ClusterName: clusterName,
+		ClusterUuid: clusterUuid,
+		OldIP:       oldIP,
+		OldHostID:   hostIDs[0],
+		NewIP:       newIP,
+		Role:        "worker",
+	}
+	if old.IsMaster == 1 {
+		r.Role = "master"
+	}
+
+	nodes, err := newKubeAPI(fqdn, clusterUuid, auth.Token).nodes()
+	if err != nil {
+		return NodeReplacement{}, fmt.Errorf("Unable to read the labels of node %s: %w", oldIP, err)
+	}
+	for _, node := range nodes {
+		if node.hasIP(oldIP) {
+			r.Labels = userLabels(node.Metadata.Labels)
+		}
+	}
+	return r, nil
+}
+
+// LabelList lists the labels copied to the new node, e.g. "disk=ssd, zone=a"
+func (r NodeReplacement) LabelList() string {
+	var labels []string
+	for key, value := range r.Labels {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, ", ")
+}
+
+// userLabels returns the labels set by the users, leaving out the ones k8s
+// and the pf9-kube role set on every node like kubernetes.io/hostname or
+// node-role.kubernetes.io/master
+func userLabels(labels map[string]string) map[string]string {
+	user := make(map[string]string)
+	for key, value := range labels {
+		domain := ""
+		if i := strings.Index(key, "/"); i >= 0 {
+			domain = key[:i]
+		}
+		if domain == "kubernetes.io" || strings.HasSuffix(domain, ".kubernetes.io") ||
+			domain == "k8s.io" || strings.HasSuffix(domain, ".k8s.io") {
+			continue
+		}
+		user[key] = value
+	}
+	return user
+}
+
+// CopyNodeLabels sets the labels of the old node on the new one, once it is
+// attached to the cluster
+func CopyNodeLabels(auth keystone.KeystoneAuth, fqdn string, r NodeReplacement) error {
+	if len(r.Labels) == 0 {
+		return nil
+	}
+	kube := newKubeAPI(fqdn, r.ClusterUuid, auth.Token)
+	name, err := kube.nodeName(r.NewIP)
+	if err != nil {
+		return err
+	}
+	if err := kube.setLabels(name, r.Labels); err != nil {
+		return fmt.Errorf("Unable to label node %s: %w", r.NewIP, err)
+	}
+	return nil
+}
+
+// DrainReplacedNode cordons the old node and evicts its pods, which the new
+// node can now run, before it is decommissioned
+func DrainReplacedNode(auth keystone.KeystoneAuth, fqdn string, r NodeReplacement) error {
+	return DrainNode(auth, fqdn, r.ClusterName, r.ClusterUuid, r.OldIP)
+}
+
+// DrainNode cordons the node with ip of the cluster and evicts its pods
+func DrainNode(auth keystone.KeystoneAuth, fqdn, clusterName, clusterUuid, ip string) error {
+	phase := ui.StartPhase(fmt.Sprintf("Draining node %s", ip))
+	defer phase.Stop()
+
+	if err := cordonAndDrain(newKubeAPI(fqdn, clusterUuid, auth.Token), clusterName, ip, phase); err != nil {
+		phase.Fail(err.Error())
+		return err
+	}
+	phase.Succeed(fmt.Sprintf("Node %s drained", ip))
+	return nil
+}
+
+// cordonAndDrain cordons the node with ip of the cluster of kube and evicts
+// its pods, reporting the steps on phase
+func cordonAndDrain(kube kubeAPI, clusterName, ip string, phase *ui.Phase) error {
+	name, err := kube.nodeName(ip)
+	if err != nil {
+		return fmt.Errorf("unable to find the node in its cluster: %w", err)
+	}
+	phase.Update("Cordoning node")
+	if err := kube.setUnschedulable(name, true); err != nil {
+		return fmt.Errorf("unable to cordon the node: %w", err)
+	}
+	phase.Step(fmt.Sprintf("Node %s of cluster %s cordoned", name, clusterName))
+
+	phase.Update("Draining node")
+	if err := kube.drain(name, MaintenanceTimeout, maintenancePollInterval); err != nil {
+		return fmt.Errorf("unable to drain the node, it is still cordoned: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package pmk
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserLabels(t *testing.T) {
+	type args struct {
+		labels map[string]string
+	}
+	tests := map[string]struct {
+		args
+		want map[string]string
+	}{
+		"k8s labels left out": {
+			args: args{map[string]string{
+				"kubernetes.io/hostname":                 "node-1",
+				"node-role.kubernetes.io/master":         "",
+				"beta.kubernetes.io/arch":                "amd64",
+				"topology.k8s.io/zone":                   "a",
+				"disktype":                               "ssd",
+				"example.com/rack":                       "r12",
+				"kubernetes.io.example.com/not-k8s-only": "kept",
+			}},
+			want: map[string]string{
+				"disktype":                               "ssd",
+				"example.com/rack":                       "r12",
+				"kubernetes.io.example.com/not-k8s-only": "kept",
+			},
+		},
+		"no labels": {
+			args: args{nil},
+			want: map[string]string{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, userLabels(tc.labels))
+		})
+	}
+}
+
+func TestCopyNodeLabels(t *testing.T) {
+	nodes := `{"items": [
+		{"metadata": {"name": "old"}, "status": {"addresses": [{"type": "InternalIP", "address": "10.0.0.1"}]}},
+		{"metadata": {"name": "new"}, "status": {"addresses": [{"type": "InternalIP", "address": "10.0.0.2"}]}}]}`
+	var patched map[string]map[string]map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/qbert/v1/clusters/c1/k8sapi/api/v1/nodes":
+			w.Write([]byte(nodes))
+		case r.Method == "PATCH" && r.URL.Path == "/qbert/v1/clusters/c1/k8sapi/api/v1/nodes/new":
+			json.NewDecoder(r.Body).Decode(&patched)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	auth := keystone.KeystoneAuth{Token: "token"}
+	r := NodeReplacement{ClusterUuid: "c1", OldIP: "10.0.0.1", NewIP: "10.0.0.2", Labels: map[string]string{"disktype": "ssd", "rack": "r12"}}
+	assert.NoError(t, CopyNodeLabels(auth, server.URL, r))
+	assert.Equal(t, r.Labels, patched["metadata"]["labels"])
+	assert.Equal(t, "disktype=ssd, rack=r12", r.LabelList())
+
+	r.NewIP = "10.0.0.3"
+	assert.Error(t, CopyNodeLabels(auth, server.URL, r))
+}
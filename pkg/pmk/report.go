@@ -0,0 +1,269 @@
+package pmk
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"github.com/platform9/pf9ctl/pkg/statefile"
+	"github.com/platform9/pf9ctl/pkg/util"
+)
+
+// ReportHost identifies the node a preflight report was created for
+type ReportHost struct {
+	Hostname  string `json:"hostname"`
+	MachineID string `json:"machineId"`
+	OS        string `json:"os"`
+	// Environment is the cloud or bare metal the node runs on
+	Environment string `json:"environment,omitempty"`
+}
+
+// ReportCheck is the result of a single preflight check
+type ReportCheck struct {
+	// ID is the ID of the check in check-node --list-checks
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Mandatory bool   `json:"mandatory"`
+	Passed    bool   `json:"passed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ReportPolicy is the check policy the checks of a report ran with
+type ReportPolicy struct {
+	File string `json:"file"`
+	// Severities are the severities of the checks the policy changes, by
+	// ID
+	Severities map[string]string `json:"severities"`
+}
+
+// PreflightReport records the preflight checks of a node so they can be
+// approved before the node is prepared. The approver signs it with the
+// signing key of their pf9ctl installation, whose public key prep-node is
+// configured to accept. Policy is the check policy the checks ran with, none
+// when nil.
+type PreflightReport struct {
+	Host      ReportHost      `json:"host"`
+	Result    CheckNodeResult `json:"result"`
+	Checks    []ReportCheck   `json:"checks"`
+	Policy    *ReportPolicy   `json:"policy,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	PublicKey string          `json:"publicKey,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+}
+
+// NewPreflightReport creates the report of the checks run on the node of exec
+func NewPreflightReport(exec cmdexec.Executor, result CheckNodeResult, checks []platform.Check) (*PreflightReport, error) {
+	host, err := reportHost(exec)
+	if err != nil {
+		return nil, err
+	}
+	host.Environment = util.NodeEnvironment
+	report := &PreflightReport{Host: host, Result: result, CreatedAt: time.Now().UTC()}
+	if p := ActiveCheckPolicy; p != nil {
+		report.Policy = &ReportPolicy{File: p.Path(), Severities: p.Severities}
+	}
+	for _, check := range checks {
+		info, _ := platform.LookupCheck(check)
+		report.Checks = append(report.Checks, ReportCheck{
+			ID:        info.ID,
+			Name:      check.Name,
+			Mandatory: check.Mandatory,
+			Passed:    check.Result,
+			Error:     check.UserErr,
+		})
+	}
+	return report, nil
+}
+
+func reportHost(exec cmdexec.Executor) (ReportHost, error) {
+	var host ReportHost
+	var err error
+	if host.Hostname, err = exec.RunArgs("hostname"); err != nil {
+		return host, fmt.Errorf("unable to read hostname: %w", err)
+	}
+	if host.MachineID, err = exec.RunArgs("cat", "/etc/machine-id"); err != nil {
+		return host, fmt.Errorf("unable to read machine-id: %w", err)
+	}
+	if host.OS, err = exec.RunWithStdout("bash", "-c", `. /etc/os-release && echo "$PRETTY_NAME"`); err != nil {
+		return host, fmt.Errorf("unable to read OS release: %w", err)
+	}
+	host.Hostname = strings.TrimSpace(host.Hostname)
+	host.MachineID = strings.TrimSpace(host.MachineID)
+	host.OS = strings.TrimSpace(host.OS)
+	return host, nil
+}
+
+// payload is the content of the report covered by the signature
+func (r PreflightReport) payload() ([]byte, error) {
+	r.Signature = ""
+	return json.Marshal(r)
+}
+
+// Sign signs the report with key
+func (r *PreflightReport) Sign(key ed25519.PrivateKey) error {
+	r.PublicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	payload, err := r.payload()
+	if err != nil {
+		return err
+	}
+	r.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	return nil
+}
+
+// Verify checks that the report was signed with the private key of publicKey
+// and wasn't modified since.
+func (r PreflightReport) Verify(publicKey ed25519.PublicKey) error {
+	if r.Signature == "" {
+		return fmt.Errorf("report was not approved")
+	}
+	if r.PublicKey != base64.StdEncoding.EncodeToString(publicKey) {
+		return fmt.Errorf("report was not approved with the approver key %s", Fingerprint(publicKey))
+	}
+	signature, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid report signature: %w", err)
+	}
+	payload, err := r.payload()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, payload, signature) {
+		return fmt.Errorf("report signature does not match, the report was modified")
+	}
+	return nil
+}
+
+// EncodeApproverKey encodes publicKey for the report_approver_key setting
+func EncodeApproverKey(publicKey ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(publicKey)
+}
+
+// ParseApproverKey parses the report_approver_key setting
+func ParseApproverKey(value string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid report approver key %q, it is the key printed by 'pf9ctl approve-report'", value)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Fingerprint identifies the signing key in the reports
+func Fingerprint(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Compare returns the differences between the node state of the approved
+// report and of the current one. The checks are compared by their outcome
+// only, as their error messages may include varying details.
+func (r PreflightReport) Compare(current *PreflightReport) []string {
+	var diffs []string
+	if r.Host.Hostname != current.Host.Hostname {
+		diffs = append(diffs, fmt.Sprintf("hostname changed from %s to %s", r.Host.Hostname, current.Host.Hostname))
+	}
+	if r.Host.MachineID != current.Host.MachineID {
+		diffs = append(diffs, fmt.Sprintf("machine-id changed from %s to %s", r.Host.MachineID, current.Host.MachineID))
+	}
+	if r.Host.OS != current.Host.OS {
+		diffs = append(diffs, fmt.Sprintf("OS changed from %s to %s", r.Host.OS, current.Host.OS))
+	}
+
+	approved := make(map[string]ReportCheck)
+	for _, check := range r.Checks {
+		approved[check.Name] = check
+	}
+	for _, check := range current.Checks {
+		old, found := approved[check.Name]
+		delete(approved, check.Name)
+		switch {
+		case !found:
+			diffs = append(diffs, fmt.Sprintf("check %q was not approved", check.Name))
+		case old.Passed != check.Passed:
+			diffs = append(diffs, fmt.Sprintf("check %q %s, approved as %s", check.Name, outcome(check.Passed), outcome(old.Passed)))
+		// The check policy the report was approved with changed
+		case old.Mandatory != check.Mandatory:
+			diffs = append(diffs, fmt.Sprintf("check %q is %s, approved as %s", check.Name, severity(check.Mandatory), severity(old.Mandatory)))
+		}
+	}
+	for _, check := range r.Checks {
+		if _, missing := approved[check.Name]; missing {
+			diffs = append(diffs, fmt.Sprintf("check %q was not run", check.Name))
+		}
+	}
+	return diffs
+}
+
+func severity(mandatory bool) string {
+	if mandatory {
+		return platform.SeverityRequired
+	}
+	return platform.SeverityOptional
+}
+
+func outcome(passed bool) string {
+	if passed {
+		return "passed"
+	}
+	return "failed"
+}
+
+// WriteReport writes the report to file
+func WriteReport(report *PreflightReport, file string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return statefile.WriteFile(file, data, 0644)
+}
+
+// ReadReport reads a report written by WriteReport
+func ReadReport(file string) (*PreflightReport, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read report: %w", err)
+	}
+	report := &PreflightReport{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("unable to parse report: %w", err)
+	}
+	return report, nil
+}
+
+// LoadReportKey returns the key this installation approves reports with, it
+// is generated the first time it is needed.
+func LoadReportKey() (ed25519.PrivateKey, error) {
+	data, err := ioutil.ReadFile(util.Pf9ReportKeyLoc)
+	if err == nil {
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid report signing key %s", util.Pf9ReportKeyLoc)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read report signing key: %w", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate report signing key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(util.Pf9ReportKeyLoc), 0700); err != nil {
+		return nil, err
+	}
+	if err := statefile.WriteFile(util.Pf9ReportKeyLoc, []byte(base64.StdEncoding.EncodeToString(key.Seed())), 0600); err != nil {
+		return nil, fmt.Errorf("unable to save report signing key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,125 @@
+package pmk
+
+import (
+	"crypto/ed25519"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func reportExecutor(hostname string) cmdexec.Executor {
+	return &cmdexec.MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			cmd := strings.Join(append([]string{name}, args...), " ")
+			switch {
+			case strings.Contains(cmd, "hostname"):
+				return hostname + "\n", nil
+			case strings.Contains(cmd, "machine-id"):
+				return "0123456789abcdef\n", nil
+			default:
+				return "Ubuntu 20.04.3 LTS\n", nil
+			}
+		},
+	}
+}
+
+func TestPreflightReportSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "report")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	util.Pf9ReportKeyLoc = filepath.Join(dir, "key")
+
+	report, err := NewPreflightReport(reportExecutor("node-1"), PASS, []platform.Check{{Name: "Disk Check", Result: true}})
+	assert.Nil(t, err)
+	assert.Equal(t, ReportHost{Hostname: "node-1", MachineID: "0123456789abcdef", OS: "Ubuntu 20.04.3 LTS"}, report.Host)
+
+	key, err := LoadReportKey()
+	assert.Nil(t, err)
+	assert.Nil(t, report.Sign(key))
+
+	file := filepath.Join(dir, "report.json")
+	assert.Nil(t, WriteReport(report, file))
+	loaded, err := ReadReport(file)
+	assert.Nil(t, err)
+
+	// The key is stored and reused
+	key, err = LoadReportKey()
+	assert.Nil(t, err)
+	assert.Nil(t, loaded.Verify(key.Public().(ed25519.PublicKey)))
+
+	loaded.Checks[0].Passed = false
+	assert.EqualError(t, loaded.Verify(key.Public().(ed25519.PublicKey)), "report signature does not match, the report was modified")
+
+	other, _, err := ed25519.GenerateKey(nil)
+	assert.Nil(t, err)
+	assert.EqualError(t, loaded.Verify(other), "report was not approved with the approver key "+Fingerprint(other))
+
+	loaded.Signature = ""
+	assert.EqualError(t, loaded.Verify(key.Public().(ed25519.PublicKey)), "report was not approved")
+}
+
+func TestParseApproverKey(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(nil)
+	assert.Nil(t, err)
+	parsed, err := ParseApproverKey(EncodeApproverKey(public))
+	assert.Nil(t, err)
+	assert.Equal(t, public, parsed)
+
+	_, err = ParseApproverKey("c2hvcnQ=")
+	assert.Error(t, err)
+}
+
+func TestPreflightReportCompare(t *testing.T) {
+	approved := &PreflightReport{
+		Host: ReportHost{Hostname: "node-1", MachineID: "id", OS: "Ubuntu 20.04.3 LTS"},
+		Checks: []ReportCheck{
+			{Name: "Disk Check", Passed: true},
+			{Name: "Swap Check", Passed: false, Error: "swap enabled"},
+			{Name: "Port Check", Passed: true},
+		},
+	}
+
+	cases := map[string]struct {
+		update func(r *PreflightReport)
+		want   []string
+	}{
+		"Unchanged": {
+			update: func(r *PreflightReport) { r.Checks[1].Error = "swap enabled on /dev/sdb" },
+		},
+		"HostChanged": {
+			update: func(r *PreflightReport) { r.Host.Hostname = "node-2" },
+			want:   []string{"hostname changed from node-1 to node-2"},
+		},
+		"ChecksChanged": {
+			update: func(r *PreflightReport) {
+				r.Checks[0].Passed = false
+				r.Checks = append(r.Checks[:2], ReportCheck{Name: "CPU Check", Passed: true})
+			},
+			want: []string{
+				`check "Disk Check" failed, approved as passed`,
+				`check "CPU Check" was not approved`,
+				`check "Port Check" was not run`,
+			},
+		},
+		"SeverityChanged": {
+			update: func(r *PreflightReport) { r.Checks[1].Mandatory = true },
+			want:   []string{`check "Swap Check" is required, approved as optional`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			current := *approved
+			current.Checks = append([]ReportCheck{}, approved.Checks...)
+			tc.update(&current)
+			assert.Equal(t, tc.want, approved.Compare(&current))
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package pmk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// Handling of the container runtimes installed on the node before prep-node,
+// which conflict with the containerd installed with pf9-kube
+var (
+	// RemoveExistingRuntime removes the container runtimes of the node
+	RemoveExistingRuntime bool
+	// ReuseRuntime keeps the docker or containerd of the node for PMK
+	ReuseRuntime bool
+)
+
+// InstalledRuntime is a container runtime installed on the node
+type InstalledRuntime struct {
+	Name    string
+	Version string
+	Active  bool
+}
+
+func (r InstalledRuntime) String() string {
+	s := r.Name
+	if r.Version != "" {
+		s += " " + r.Version
+	}
+	if r.Active {
+		s += " (running)"
+	}
+	return s
+}
+
+// runtimesScript prints the container runtimes of the node a line each, with
+// whether their service is active and their version after tabs. The
+// containerd of pf9-kube isn't in the PATH and is skipped anyway.
+const runtimesScript = `for r in docker containerd crio; do
+    bin=$(command -v $r) || continue
+    case $bin in /opt/pf9/*) continue;; esac
+    printf '%s\t%s\t%s\n' $r "$(systemctl is-active $r 2> /dev/null)" "$($r --version 2> /dev/null | head -1)"
+done
+true`
+
+// parseRuntimes parses the output of runtimesScript
+func parseRuntimes(out string) []InstalledRuntime {
+	var runtimes []InstalledRuntime
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 3 || fields[0] == "" {
+			continue
+		}
+		runtimes = append(runtimes, InstalledRuntime{
+			Name:    fields[0],
+			Active:  strings.TrimSpace(fields[1]) == "active",
+			Version: parseVersion(fields[2]),
+		})
+	}
+	return runtimes
+}
+
+// DetectRuntimes returns the container runtimes installed on the node of exec
+func DetectRuntimes(exec cmdexec.Executor) ([]InstalledRuntime, error) {
+	out, err := exec.RunArgs("bash", "-c", runtimesScript)
+	if err != nil {
+		return nil, fmt.Errorf("unable to look for the container runtimes of the node: %w", err)
+	}
+	runtimes := parseRuntimes(out)
+	zap.S().Debugf("Container runtimes of the node: %v", runtimes)
+	return runtimes, nil
+}
+
+// runtimeReuseProblem returns why the runtimes can't be kept for PMK, empty
+// when they can. PMK runs on containerd, which docker runs its containers
+// with, both have to be recent enough. CRI-O is always removed.
+func runtimeReuseProblem(runtimes []InstalledRuntime) string {
+	var problems []string
+	for _, r := range runtimes {
+		oldest, ok := minRuntimeVersions[r.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("PMK doesn't run on %s", r.Name))
+			continue
+		}
+		if !versionAtLeast(r.Version, oldest[0], oldest[1]) {
+			problems = append(problems, fmt.Sprintf("%s %s is older than %d.%d", r.Name, orUnknown(r.Version), oldest[0], oldest[1]))
+		}
+	}
+	return strings.Join(problems, ", ")
+}
+
+func runtimeNames(runtimes []InstalledRuntime) string {
+	names := make([]string, 0, len(runtimes))
+	for _, r := range runtimes {
+		names = append(names, r.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// removeRuntimesScript stops and removes the runtimes along with the state of
+// containerd, which pf9-kube uses the paths of. The images of docker in
+// /var/lib/docker and of CRI-O are kept.
+func removeRuntimesScript(runtimes []InstalledRuntime) string {
+	var services, pkgs []string
+	for _, r := range runtimes {
+		switch r.Name {
+		case "docker":
+			services = append(services, "docker.socket", "docker")
+			pkgs = append(pkgs, "docker-ce", "docker-ce-cli", "docker.io")
+		case "containerd":
+			services = append(services, "containerd")
+			pkgs = append(pkgs, "containerd.io", "containerd")
+		case "crio":
+			services = append(services, "crio")
+			pkgs = append(pkgs, "cri-o", "cri-o-runc")
+		}
+	}
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	for _, svc := range services {
+		fmt.Fprintf(&b, "if systemctl cat %[1]s > /dev/null 2>&1; then systemctl stop %[1]s; systemctl disable %[1]s; fi\n", svc)
+	}
+	writeRemovePackages(&b, pkgs)
+	b.WriteString("grep ' /run/containerd' /proc/mounts | cut -d ' ' -f 2 | sort -r | xargs -r umount\n")
+	b.WriteString("rm -rf /var/lib/containerd /run/containerd /etc/containerd\n")
+	return b.String()
+}
+
+// RemoveRuntimes stops and removes the container runtimes of the node of exec
+func RemoveRuntimes(exec cmdexec.Executor, runtimes []InstalledRuntime) error {
+	if _, err := exec.RunArgs("bash", "-c", removeRuntimesScript(runtimes)); err != nil {
+		return fmt.Errorf("unable to remove %s: %w", runtimeNames(runtimes), err)
+	}
+	return nil
+}
+
+// activeRuntimes returns the runtimes whose service is running, the stopped
+// ones don't conflict with the containerd of pf9-kube
+func activeRuntimes(runtimes []InstalledRuntime) []InstalledRuntime {
+	var active []InstalledRuntime
+	for _, r := range runtimes {
+		if r.Active {
+			active = append(active, r)
+		}
+	}
+	return active
+}
+
+// reusedRuntimeCfg is the extra configuration of the sunpike host making
+// nodelet run the pods on the runtime of the node instead of starting the
+// containerd of pf9-kube. Docker is used when it runs, its containerd
+// otherwise.
+func reusedRuntimeCfg(runtimes []InstalledRuntime) map[string]string {
+	runtime := "containerd"
+	for _, r := range runtimes {
+		if r.Name == "docker" {
+			runtime = "docker"
+		}
+	}
+	return map[string]string{"CONTAINER_RUNTIME": runtime, "USE_EXISTING_RUNTIME": "true"}
+}
+
+// ReuseNodeRuntime tells nodelet to keep the runtime running on the node of
+// exec, authorized as hostID
+func ReuseNodeRuntime(fqdn string, auth keystone.KeystoneAuth, hostID string, exec cmdexec.Executor) error {
+	runtimes, err := DetectRuntimes(exec)
+	if err != nil {
+		return err
+	}
+	active := activeRuntimes(runtimes)
+	if len(active) == 0 {
+		zap.S().Debug("No container runtime running on the node, using the containerd of PMK")
+		return nil
+	}
+	if err := patchExtraCfg(fqdn, auth, hostID, reusedRuntimeCfg(active)); err != nil {
+		return fmt.Errorf("unable to keep %s for PMK: %w", runtimeNames(active), err)
+	}
+	zap.S().Debugf("Keeping %s for PMK", runtimeNames(active))
+	return nil
+}
+
+// checkRuntimes warns about the container runtimes running on the node, which
+// duel with the containerd of pf9-kube. Prep-node removes them with
+// RemoveExistingRuntime, or keeps them with ReuseRuntime when PMK runs on
+// them, the check only fails then when they can't be reused.
+func checkRuntimes(exec cmdexec.Executor) platform.Check {
+	name := "Container runtime check"
+	runtimes, err := DetectRuntimes(exec)
+	if err != nil {
+		return platform.Check{ID: platform.CheckIDContainerRuntime, Name: name, Mandatory: false, Result: false, Err: err, UserErr: "unable to look for the container runtimes of the node"}
+	}
+	active := activeRuntimes(runtimes)
+	if len(active) == 0 {
+		return platform.Check{ID: platform.CheckIDContainerRuntime, Name: name, Mandatory: false, Result: true}
+	}
+
+	found := runtimeNames(active)
+	switch {
+	case RemoveExistingRuntime:
+		zap.S().Debugf("%s is removed by prep-node", found)
+		return platform.Check{ID: platform.CheckIDContainerRuntime, Name: name, Mandatory: false, Result: true}
+	case ReuseRuntime:
+		if problem := runtimeReuseProblem(active); problem != "" {
+			return platform.Check{ID: platform.CheckIDContainerRuntime, Name: name, Mandatory: true, Result: false, Err: fmt.Errorf("found %s", found),
+				UserErr: fmt.Sprintf("%s can't be reused: %s. Remove it with --remove-existing-runtime", found, problem)}
+		}
+		return platform.Check{ID: platform.CheckIDContainerRuntime, Name: name, Mandatory: false, Result: true}
+	}
+	return platform.Check{ID: platform.CheckIDContainerRuntime, Name: name, Mandatory: false, Result: false, Err: fmt.Errorf("found %s", found),
+		UserErr: fmt.Sprintf("%s is running, which conflicts with the containerd of PMK. Remove it with --remove-existing-runtime or keep it for PMK with --reuse-runtime", found)}
+}
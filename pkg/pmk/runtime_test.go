@@ -0,0 +1,86 @@
+package pmk
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRuntimes(t *testing.T) {
+	out := "docker\tactive\tDocker version 24.0.5, build ced0996\n" +
+		"containerd\tactive\tcontainerd containerd.io 1.6.22 8165feabfdfe38c65b599c4993d227328c231fca\n" +
+		"crio\tinactive\t\n"
+	assert.Equal(t, []InstalledRuntime{
+		{Name: "docker", Version: "24.0.5", Active: true},
+		{Name: "containerd", Version: "1.6.22", Active: true},
+		{Name: "crio"},
+	}, parseRuntimes(out))
+	assert.Empty(t, parseRuntimes("\n"))
+}
+
+func TestRuntimeReuseProblem(t *testing.T) {
+	cases := map[string]struct {
+		runtimes []InstalledRuntime
+		want     string
+	}{
+		"Docker":         {[]InstalledRuntime{{Name: "docker", Version: "24.0.5"}, {Name: "containerd", Version: "1.6.22"}}, ""},
+		"OldDocker":      {[]InstalledRuntime{{Name: "docker", Version: "18.09.1"}, {Name: "containerd", Version: "1.2.6"}}, "docker 18.09.1 is older than 19.3, containerd 1.2.6 is older than 1.4"},
+		"Containerd":     {[]InstalledRuntime{{Name: "containerd", Version: "1.4.0"}}, ""},
+		"UnknownVersion": {[]InstalledRuntime{{Name: "containerd"}}, "containerd of unknown version is older than 1.4"},
+		"CRIO":           {[]InstalledRuntime{{Name: "crio", Version: "1.24.1"}}, "PMK doesn't run on crio"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, runtimeReuseProblem(tc.runtimes))
+		})
+	}
+}
+
+func TestCheckRuntimes(t *testing.T) {
+	out := "crio\tactive\tcrio version 1.24.1\ndocker\tinactive\tDocker version 24.0.5\n"
+	var scripts []string
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			scripts = append(scripts, args[1])
+			return out, nil
+		},
+	}
+	defer func() { RemoveExistingRuntime, ReuseRuntime = false, false }()
+
+	check := checkRuntimes(exec)
+	assert.False(t, check.Result)
+	assert.False(t, check.Mandatory)
+	assert.Equal(t, "crio 1.24.1 (running) is running, which conflicts with the containerd of PMK. Remove it with --remove-existing-runtime or keep it for PMK with --reuse-runtime", check.UserErr)
+
+	ReuseRuntime = true
+	check = checkRuntimes(exec)
+	assert.False(t, check.Result)
+	assert.True(t, check.Mandatory)
+	assert.Equal(t, "crio 1.24.1 (running) can't be reused: PMK doesn't run on crio. Remove it with --remove-existing-runtime", check.UserErr)
+
+	// The runtimes are removed by prep-node, not by the check
+	ReuseRuntime, RemoveExistingRuntime = false, true
+	scripts = nil
+	assert.True(t, checkRuntimes(exec).Result)
+	assert.Equal(t, []string{runtimesScript}, scripts)
+
+	// Stopped runtimes don't conflict
+	RemoveExistingRuntime = false
+	out = "docker\tinactive\tDocker version 24.0.5\n"
+	assert.True(t, checkRuntimes(exec).Result)
+}
+
+func TestReusedRuntimeCfg(t *testing.T) {
+	assert.Equal(t, map[string]string{"CONTAINER_RUNTIME": "docker", "USE_EXISTING_RUNTIME": "true"},
+		reusedRuntimeCfg([]InstalledRuntime{{Name: "containerd"}, {Name: "docker"}}))
+	assert.Equal(t, "containerd", reusedRuntimeCfg([]InstalledRuntime{{Name: "containerd"}})["CONTAINER_RUNTIME"])
+}
+
+func TestRemoveRuntimesScript(t *testing.T) {
+	script := removeRuntimesScript([]InstalledRuntime{{Name: "crio"}})
+	assert.Contains(t, script, "systemctl stop crio")
+	assert.Contains(t, script, "yum remove -y cri-o")
+	assert.False(t, strings.Contains(script, "docker"))
+}
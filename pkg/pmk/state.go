@@ -0,0 +1,108 @@
+package pmk
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/jobs"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/statefile"
+	"go.uber.org/zap"
+)
+
+// PrunedJob is a job of the state store with hosts which no longer exist in
+// resmgr. The job is deleted when none of its hosts is left.
+type PrunedJob struct {
+	ID        string
+	Operation string
+	Hosts     []jobs.Host
+	Deleted   bool
+}
+
+// PruneState removes from the jobs of the state store the hosts of the
+// tenant of the DU at fqdn which resmgr no longer knows, and refreshes the
+// hosts cached for shell completion. The jobs created before their DU was
+// recorded are only pruned with includeLegacy. Nothing is changed with dryRun,
+// the jobs which would be pruned are returned.
+func PruneState(c client.Client, auth keystone.KeystoneAuth, fqdn, tenant string, includeLegacy, dryRun bool) ([]PrunedJob, error) {
+	hosts, err := c.Resmgr.GetHosts(auth.Token)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the hosts: %w", err)
+	}
+	known := make(map[string]bool)
+	for _, host := range hosts {
+		known[host.ID] = true
+	}
+
+	allJobs, err := jobs.List()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the jobs: %w", err)
+	}
+	pruned, err := pruneJobs(allJobs, fqdn, auth.ProjectID, includeLegacy, func(hostID string) bool { return known[hostID] }, dryRun)
+	if err != nil {
+		return pruned, err
+	}
+
+	if !dryRun {
+		if _, err := FetchCompletionNames(c, auth, fqdn, tenant); err != nil {
+			zap.S().Debugf("Unable to refresh the completion cache: %s", err)
+		}
+	}
+	return pruned, nil
+}
+
+// pruneJobs removes the hosts for which exists is false from the jobs of the
+// tenant projectID of the DU at fqdn, deleting the jobs left without hosts.
+// The jobs in use by another pf9ctl are skipped.
+func pruneJobs(allJobs []*jobs.Job, fqdn, projectID string, includeLegacy bool, exists func(hostID string) bool, dryRun bool) ([]PrunedJob, error) {
+	var pruned []PrunedJob
+	for _, job := range allJobs {
+		if !job.BelongsTo(fqdn, projectID, includeLegacy) {
+			continue
+		}
+		p, err := pruneJob(job.ID, exists, dryRun)
+		if err != nil {
+			return pruned, err
+		}
+		if p != nil {
+			pruned = append(pruned, *p)
+		}
+	}
+	return pruned, nil
+}
+
+// pruneJob prunes the job with id under its lock. It returns nil when the job
+// has no host to prune or is in use.
+func pruneJob(id string, exists func(hostID string) bool, dryRun bool) (*PrunedJob, error) {
+	lock, err := jobs.Lock(id)
+	if err != nil {
+		var locked *statefile.LockedError
+		if errors.As(err, &locked) {
+			zap.S().Infof("Skipping job %s: %s", id, err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer lock.Release()
+
+	// The job is read again as it may have changed since it was listed
+	job, err := jobs.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	hosts := job.PruneHosts(exists)
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+	p := &PrunedJob{ID: job.ID, Operation: job.Operation, Hosts: hosts, Deleted: len(job.Hosts) == 0}
+	if dryRun {
+		return p, nil
+	}
+	if p.Deleted {
+		err = job.Delete()
+	} else {
+		err = job.Save()
+	}
+	return p, err
+}
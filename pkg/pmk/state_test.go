@@ -0,0 +1,65 @@
+package pmk
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/jobs"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneJobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer func(jobsDir string) { util.Pf9JobsDir = jobsDir }(util.Pf9JobsDir)
+	util.Pf9JobsDir = dir
+
+	partial, err := jobs.New(jobs.AttachNode, "du.example.com", "project-a", "cluster-a", "uuid-a", []jobs.Host{
+		{IP: "10.0.0.1", HostID: "host-1"},
+		{IP: "10.0.0.2", HostID: "host-2"},
+	})
+	assert.Nil(t, err)
+	stale, err := jobs.New(jobs.DetachNode, "", "", "", "", []jobs.Host{{IP: "10.0.0.3", HostID: "host-3"}})
+	assert.Nil(t, err)
+	otherDU, err := jobs.New(jobs.DetachNode, "other.example.com", "project-a", "", "", []jobs.Host{{IP: "10.0.0.4", HostID: "host-4"}})
+	assert.Nil(t, err)
+
+	exists := func(hostID string) bool { return hostID == "host-1" }
+	all, err := jobs.List()
+	assert.Nil(t, err)
+
+	pruned, err := pruneJobs(all, "du.example.com", "project-a", false, exists, true)
+	assert.Nil(t, err)
+	assert.Len(t, pruned, 1)
+	all, err = jobs.List()
+	assert.Nil(t, err)
+	pruned, err = pruneJobs(all, "du.example.com", "project-a", true, exists, true)
+	assert.Nil(t, err)
+	assert.Len(t, pruned, 2)
+	all, err = jobs.List()
+	assert.Nil(t, err)
+	assert.Len(t, all, 3)
+
+	pruned, err = pruneJobs(all, "du.example.com", "project-a", true, exists, false)
+	assert.Nil(t, err)
+	byID := make(map[string]PrunedJob)
+	for _, p := range pruned {
+		byID[p.ID] = p
+	}
+	assert.Len(t, byID[partial.ID].Hosts, 1)
+	assert.Equal(t, "host-2", byID[partial.ID].Hosts[0].HostID)
+	assert.False(t, byID[partial.ID].Deleted)
+	assert.True(t, byID[stale.ID].Deleted)
+	assert.NotContains(t, byID, otherDU.ID)
+
+	loaded, err := jobs.Load(partial.ID)
+	assert.Nil(t, err)
+	assert.Len(t, loaded.Hosts, 1)
+	_, err = jobs.Load(stale.ID)
+	assert.EqualError(t, err, "job "+stale.ID+" not found")
+	_, err = jobs.Load(otherDU.ID)
+	assert.Nil(t, err)
+}
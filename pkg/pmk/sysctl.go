@@ -0,0 +1,67 @@
+package pmk
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// sysctlFile holds the kernel settings prep-node applies for the role of the node
+const sysctlFile = "/etc/sysctl.d/90-pf9-role.conf"
+
+// roleSysctls are the kernel settings tuned for each role. Masters get a
+// larger listen backlog for the API server and more inotify watches for etcd
+// and the controllers, workers more conntrack entries, inotify instances and
+// memory maps for the pods they run.
+var roleSysctls = map[string]map[string]string{
+	util.RoleMaster: {
+		"net.core.somaxconn":          "32768",
+		"fs.inotify.max_user_watches": "524288",
+		"vm.swappiness":               "0",
+	},
+	util.RoleWorker: {
+		"net.netfilter.nf_conntrack_max": "1048576",
+		"fs.inotify.max_user_instances":  "8192",
+		"fs.inotify.max_user_watches":    "524288",
+		"vm.max_map_count":               "262144",
+	},
+}
+
+// applyRoleSysctls writes the kernel settings of role to sysctlFile and loads
+// them, nothing is changed when the role isn't known
+func applyRoleSysctls(exec cmdexec.Executor, role string) error {
+	sysctls, ok := roleSysctls[role]
+	if !ok {
+		return nil
+	}
+	zap.S().Debugf("Applying the sysctls of the %s role", role)
+	if _, err := exec.RunWithStdout("bash", "-c", sysctlScript(role, sysctls)); err != nil {
+		return fmt.Errorf("unable to apply the sysctls of the %s role: %w", role, err)
+	}
+	return nil
+}
+
+func sysctlScript(role string, sysctls map[string]string) string {
+	keys := make([]string, 0, len(sysctls))
+	for key := range sysctls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := []string{cmdexec.ShellQuote(fmt.Sprintf("# Set by pf9ctl prep-node for the %s role", role))}
+	for _, key := range keys {
+		lines = append(lines, cmdexec.ShellQuote(fmt.Sprintf("%s = %s", key, sysctls[key])))
+	}
+
+	var b strings.Builder
+	b.WriteString("set -e\n")
+	fmt.Fprintf(&b, "printf '%%s\\n' %s | tee %s > /dev/null\n", strings.Join(lines, " "), sysctlFile)
+	// nf_conntrack_max only exists once the conntrack module is loaded
+	b.WriteString("modprobe nf_conntrack > /dev/null 2>&1 || true\n")
+	fmt.Fprintf(&b, "sysctl -p %s > /dev/null\n", sysctlFile)
+	return b.String()
+}
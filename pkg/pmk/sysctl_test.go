@@ -0,0 +1,55 @@
+package pmk
+
+import (
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyRoleSysctls(t *testing.T) {
+	cases := map[string]struct {
+		role     string
+		want     []string
+		notWant  []string
+		noScript bool
+	}{
+		"Master": {
+			role:    util.RoleMaster,
+			want:    []string{"'net.core.somaxconn = 32768'", "'vm.swappiness = 0'", "sysctl -p /etc/sysctl.d/90-pf9-role.conf"},
+			notWant: []string{"nf_conntrack_max"},
+		},
+		"Worker": {
+			role:    util.RoleWorker,
+			want:    []string{"'net.netfilter.nf_conntrack_max = 1048576'", "'vm.max_map_count = 262144'", "modprobe nf_conntrack"},
+			notWant: []string{"somaxconn"},
+		},
+		"NoRole": {noScript: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var script string
+			exec := &cmdexec.MockExecutor{
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					script = args[len(args)-1]
+					return "", nil
+				},
+			}
+			assert.Nil(t, applyRoleSysctls(exec, tc.role))
+			if tc.noScript {
+				assert.Empty(t, script)
+				return
+			}
+			for _, s := range tc.want {
+				assert.Contains(t, script, s)
+			}
+			for _, s := range tc.notWant {
+				assert.NotContains(t, script, s)
+			}
+			// The remote executor passes the script to bash in double quotes
+			assert.NotContains(t, script, "\"")
+			assert.NotContains(t, script, "$")
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package pmk
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/jobs"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Sources of the events of a timeline
+const (
+	TimelineLocal  = "local"
+	TimelineResmgr = "resmgr"
+	TimelineQbert  = "qbert"
+)
+
+// TimelineEvent is a single event of the timeline of a host
+type TimelineEvent struct {
+	Time    time.Time
+	Source  string
+	Message string
+}
+
+// timestampLayouts are the layouts of the timestamps reported by the DU
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.9999Z",
+	"2006-01-02T15:04:05.999999",
+	"2006-01-02 15:04:05.999999",
+	"2006-01-02 15:04:05",
+}
+
+// HostTimeline merges what pf9ctl did locally on the host with IP, its
+// jobs and log entries, with what resmgr and qbert report about it into a
+// single chronological list of the events since since. The current state of
+// the host, which carries no timestamp, is reported at the time of the query.
+func HostTimeline(c client.Client, auth keystone.KeystoneAuth, ip string, since time.Time) ([]TimelineEvent, error) {
+	now := time.Now()
+
+	hostIDs := c.Resmgr.GetHostId(auth.Token, []string{ip})
+	hostID := ""
+	if len(hostIDs) > 0 {
+		hostID = hostIDs[0]
+	}
+
+	events, err := localEvents(ip, hostID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if hostID == "" {
+		events = append(events, TimelineEvent{Time: now, Source: TimelineResmgr,
+			Message: "host is not registered with the DU, the hostagent never reported or prep-node didn't run"})
+		return timelineSince(events, since), nil
+	}
+
+	host, err := c.Resmgr.GetHostInfo(auth.Token, hostID)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get host info of %s: %w", ip, err)
+	}
+	events = append(events, resmgrEvents(host, now)...)
+
+	node := c.Qbert.GetNodeInfo(auth.Token, auth.ProjectID, hostID)
+	var cluster *qbert.Cluster
+	if node.ClusterUuid != "" {
+		found, err := c.Qbert.GetCluster(node.ClusterUuid, auth.ProjectID, auth.Token)
+		if err != nil {
+			zap.S().Debugf("Unable to get cluster %s: %s", node.ClusterUuid, err.Error())
+		} else {
+			cluster = &found
+		}
+	}
+	events = append(events, qbertEvents(node, cluster, now)...)
+
+	return timelineSince(events, since), nil
+}
+
+// localEvents returns the events of the jobs and of the log of pf9ctl about
+// the host with ip or hostID, up to until to leave out the entries logged
+// while building the timeline
+func localEvents(ip, hostID string, until time.Time) ([]TimelineEvent, error) {
+	allJobs, err := jobs.List()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the jobs: %w", err)
+	}
+	events := jobEvents(ip, allJobs)
+
+	// The log is rotated daily, see log.GetLogLocation
+	ext := filepath.Ext(util.Pf9Log)
+	logFiles, err := filepath.Glob(strings.TrimSuffix(util.Pf9Log, ext) + "-*" + ext)
+	if err != nil {
+		return nil, err
+	}
+	match := mentions(ip, hostID)
+	for _, logFile := range logFiles {
+		f, err := os.Open(logFile)
+		if err != nil {
+			zap.S().Debugf("Unable to read log %s: %s", logFile, err.Error())
+			continue
+		}
+		for _, event := range logEvents(f, match) {
+			if event.Time.Before(until) {
+				events = append(events, event)
+			}
+		}
+		f.Close()
+	}
+	return events, nil
+}
+
+// jobEvents returns the start of the jobs which processed the host and the
+// last status they recorded for it
+func jobEvents(ip string, allJobs []*jobs.Job) []TimelineEvent {
+	var events []TimelineEvent
+	for _, job := range allJobs {
+		for _, host := range job.Hosts {
+			if host.IP != ip {
+				continue
+			}
+			target := job.ClusterName
+			if target == "" {
+				target = job.ClusterUuid
+			}
+			events = append(events, TimelineEvent{Time: job.CreatedAt, Source: TimelineLocal,
+				Message: fmt.Sprintf("job %s started %s on cluster %s", job.ID, job.Operation, target)})
+			message := fmt.Sprintf("job %s: node %s", job.ID, host.Status)
+			if host.Error != "" {
+				message += ": " + host.Error
+			}
+			events = append(events, TimelineEvent{Time: host.UpdatedAt, Source: TimelineLocal, Message: message})
+		}
+	}
+	return events
+}
+
+// logEvents returns the entries of the JSON log of pf9ctl matching
+func logEvents(r io.Reader, match func(string) bool) []TimelineEvent {
+	var events []TimelineEvent
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry struct {
+			Level string `json:"level"`
+			TS    string `json:"ts"`
+			Msg   string `json:"msg"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || !match(entry.Msg) {
+			continue
+		}
+		ts, ok := parseTimestamp(entry.TS)
+		if !ok {
+			continue
+		}
+		events = append(events, TimelineEvent{Time: ts, Source: TimelineLocal,
+			Message: fmt.Sprintf("[%s] %s", entry.Level, strings.TrimSpace(entry.Msg))})
+	}
+	return events
+}
+
+// mentions returns a matcher of the text mentioning the ip, not as the
+// prefix of a longer IP, or the hostID
+func mentions(ip, hostID string) func(string) bool {
+	ipRe := regexp.MustCompile(`(^|[^0-9.])` + regexp.QuoteMeta(ip) + `($|[^0-9.]|\.([^0-9]|$))`)
+	return func(text string) bool {
+		return ipRe.MatchString(text) || (hostID != "" && strings.Contains(text, hostID))
+	}
+}
+
+// resmgrEvents returns the last report of the hostagent of the host and its
+// current state
+func resmgrEvents(host resmgr.HostInfo, now time.Time) []TimelineEvent {
+	var events []TimelineEvent
+	if ts, ok := parseTimestamp(host.Info.LastResponseTime); ok {
+		events = append(events, TimelineEvent{Time: ts, Source: TimelineResmgr,
+			Message: fmt.Sprintf("last report of the hostagent of %s", host.Info.Hostname)})
+	}
+
+	state := fmt.Sprintf("host %s is not responding", host.ID)
+	if host.Info.Responding {
+		state = fmt.Sprintf("host %s is responding", host.ID)
+	}
+	if len(host.Roles) == 0 {
+		state += ", no role is authorized"
+	} else {
+		state += fmt.Sprintf(", roles %s", strings.Join(host.Roles, ", "))
+		if host.RoleStatus != "" {
+			state += fmt.Sprintf(" are %s", host.RoleStatus)
+		}
+	}
+	return append(events, TimelineEvent{Time: now, Source: TimelineResmgr, Message: state})
+}
+
+// qbertEvents returns the operations of the cluster of the node and the
+// current state of its convergence
+func qbertEvents(node qbert.Node, cluster *qbert.Cluster, now time.Time) []TimelineEvent {
+	if node.Uuid == "" {
+		return []TimelineEvent{{Time: now, Source: TimelineQbert,
+			Message: "node is not known to qbert, the pf9-kube role isn't applied"}}
+	}
+	if node.ClusterUuid == "" {
+		return []TimelineEvent{{Time: now, Source: TimelineQbert,
+			Message: fmt.Sprintf("node %s is %s and not attached to any cluster", node.Name, node.Status)}}
+	}
+
+	var events []TimelineEvent
+	if cluster != nil {
+		if ts, ok := parseTimestamp(cluster.LastOp); ok {
+			events = append(events, TimelineEvent{Time: ts, Source: TimelineQbert,
+				Message: fmt.Sprintf("last operation started on cluster %s", cluster.Name)})
+		}
+		if ts, ok := parseTimestamp(cluster.LastOk); ok {
+			events = append(events, TimelineEvent{Time: ts, Source: TimelineQbert,
+				Message: fmt.Sprintf("cluster %s last converged", cluster.Name)})
+		}
+		if cluster.TaskStatus != "" && cluster.TaskStatus != "success" {
+			message := fmt.Sprintf("cluster %s task is %s", cluster.Name, cluster.TaskStatus)
+			if cluster.TaskError != "" {
+				message += ": " + cluster.TaskError
+			}
+			events = append(events, TimelineEvent{Time: now, Source: TimelineQbert, Message: message})
+		}
+	}
+
+	role := "worker"
+	if node.IsMaster == 1 {
+		role = "master"
+	}
+	message := fmt.Sprintf("node %s is %s as %s of cluster %s", node.Name, node.Status, role, node.ClusterName)
+	if node.ApiResponding != 1 && node.IsMaster == 1 {
+		message += ", its API server isn't responding"
+	}
+	return append(events, TimelineEvent{Time: now, Source: TimelineQbert, Message: message})
+}
+
+// timelineSince sorts the events since since chronologically, keeping the
+// order of the events at the same time
+func timelineSince(events []TimelineEvent, since time.Time) []TimelineEvent {
+	var timeline []TimelineEvent
+	for _, event := range events {
+		if !event.Time.Before(since) {
+			timeline = append(timeline, event)
+		}
+	}
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Time.Before(timeline[j].Time)
+	})
+	return timeline
+}
+
+func parseTimestamp(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range timestampLayouts {
+		if ts, err := time.Parse(layout, value); err == nil {
+			return ts, true
+		}
+	}
+	zap.S().Debugf("Ignoring unknown timestamp %q", value)
+	return time.Time{}, false
+}
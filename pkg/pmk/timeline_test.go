@@ -0,0 +1,111 @@
+package pmk
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/jobs"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMentions(t *testing.T) {
+	match := mentions("10.0.0.1", "host-1")
+
+	cases := map[string]bool{
+		"Attaching node 10.0.0.1":         true,
+		"10.0.0.1 is ready":               true,
+		"node (10.0.0.1), retrying":       true,
+		"Prepared 10.0.0.1.":              true,
+		"Host host-1 authorized":          true,
+		"Attaching node 10.0.0.12":        false,
+		"Attaching node 110.0.0.1":        false,
+		"Attaching node 10.0.0.1.5":       false,
+		"Loaded Config Successfully":      false,
+		"Authorizing the host: host-2 ok": false,
+	}
+	for text, want := range cases {
+		assert.Equal(t, want, match(text), text)
+	}
+}
+
+func TestLogEvents(t *testing.T) {
+	log := strings.Join([]string{
+		`{"level":"info","ts":"2021-03-01T10:00:00.5Z","caller":"pmk/node.go:1","msg":"Preparing node 10.0.0.1"}`,
+		`{"level":"debug","ts":"2021-03-01T10:00:01Z","caller":"pmk/node.go:2","msg":"Preparing node 10.0.0.2"}`,
+		`not json`,
+		`{"level":"error","ts":"2021-03-01T10:05:00Z","caller":"pmk/node.go:3","msg":"Host host-1 failed "}`,
+	}, "\n")
+
+	events := logEvents(strings.NewReader(log), mentions("10.0.0.1", "host-1"))
+	assert.Equal(t, []TimelineEvent{
+		{Time: time.Date(2021, 3, 1, 10, 0, 0, 500000000, time.UTC), Source: TimelineLocal, Message: "[info] Preparing node 10.0.0.1"},
+		{Time: time.Date(2021, 3, 1, 10, 5, 0, 0, time.UTC), Source: TimelineLocal, Message: "[error] Host host-1 failed"},
+	}, events)
+}
+
+func TestJobEvents(t *testing.T) {
+	created := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	updated := created.Add(5 * time.Minute)
+	allJobs := []*jobs.Job{
+		{ID: "a1", Operation: jobs.AttachNode, ClusterName: "prod", CreatedAt: created, Hosts: []jobs.Host{
+			{IP: "10.0.0.1", Status: jobs.Failed, Error: "timed out", UpdatedAt: updated},
+			{IP: "10.0.0.2", Status: jobs.Done, UpdatedAt: updated},
+		}},
+		{ID: "b2", Operation: jobs.DetachNode, ClusterUuid: "uuid-1", CreatedAt: created, Hosts: []jobs.Host{
+			{IP: "10.0.0.2", Status: jobs.Done, UpdatedAt: updated},
+		}},
+	}
+
+	assert.Equal(t, []TimelineEvent{
+		{Time: created, Source: TimelineLocal, Message: "job a1 started attach-node on cluster prod"},
+		{Time: updated, Source: TimelineLocal, Message: "job a1: node failed: timed out"},
+	}, jobEvents("10.0.0.1", allJobs))
+	assert.Len(t, jobEvents("10.0.0.2", allJobs), 4)
+}
+
+func TestResmgrEvents(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	host := resmgr.HostInfo{ID: "host-1", Roles: []string{"pf9-kube"}, RoleStatus: "converging"}
+	host.Info.Hostname = "node-a"
+	host.Info.LastResponseTime = "2021-03-01 11:59:30.123456"
+
+	assert.Equal(t, []TimelineEvent{
+		{Time: time.Date(2021, 3, 1, 11, 59, 30, 123456000, time.UTC), Source: TimelineResmgr, Message: "last report of the hostagent of node-a"},
+		{Time: now, Source: TimelineResmgr, Message: "host host-1 is not responding, roles pf9-kube are converging"},
+	}, resmgrEvents(host, now))
+}
+
+func TestQbertEvents(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	events := qbertEvents(qbert.Node{}, nil, now)
+	assert.Equal(t, "node is not known to qbert, the pf9-kube role isn't applied", events[0].Message)
+
+	node := qbert.Node{Uuid: "host-1", Name: "node-a", ClusterUuid: "uuid-1", ClusterName: "prod", Status: "converging", IsMaster: 1}
+	cluster := &qbert.Cluster{Name: "prod", LastOp: "2021-03-01T11:00:00Z", LastOk: "bad", TaskStatus: "error", TaskError: "etcd unhealthy"}
+	assert.Equal(t, []TimelineEvent{
+		{Time: time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC), Source: TimelineQbert, Message: "last operation started on cluster prod"},
+		{Time: now, Source: TimelineQbert, Message: "cluster prod task is error: etcd unhealthy"},
+		{Time: now, Source: TimelineQbert, Message: "node node-a is converging as master of cluster prod, its API server isn't responding"},
+	}, qbertEvents(node, cluster, now))
+}
+
+func TestTimelineSince(t *testing.T) {
+	base := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	events := []TimelineEvent{
+		{Time: base.Add(2 * time.Hour), Message: "c"},
+		{Time: base, Message: "old"},
+		{Time: base.Add(time.Hour), Message: "a"},
+		{Time: base.Add(time.Hour), Message: "b"},
+	}
+
+	timeline := timelineSince(events, base.Add(time.Minute))
+	var messages []string
+	for _, event := range timeline {
+		messages = append(messages, event.Message)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, messages)
+}
@@ -0,0 +1,175 @@
+package pmk
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NodeTop is the usage of the resources of a node of a cluster. The CPU is in
+// millicores and the memory in bytes.
+type NodeTop struct {
+	Name  string
+	Role  string
+	Ready bool
+	// HasMetrics is false when the metrics API has no usage for the node
+	HasMetrics        bool
+	CPUUsage          int64
+	CPUAllocatable    int64
+	MemoryUsage       int64
+	MemoryAllocatable int64
+	Pods              int
+	PodCapacity       int
+}
+
+// CPUPercent returns the CPU usage in percent of the allocatable CPU
+func (n NodeTop) CPUPercent() int {
+	return percent(n.CPUUsage, n.CPUAllocatable)
+}
+
+// MemoryPercent returns the memory usage in percent of the allocatable memory
+func (n NodeTop) MemoryPercent() int {
+	return percent(n.MemoryUsage, n.MemoryAllocatable)
+}
+
+func percent(used, total int64) int {
+	if total == 0 {
+		return 0
+	}
+	return int(math.Round(float64(used) * 100 / float64(total)))
+}
+
+// kubeNodeMetrics is the usage of a node reported by the metrics API
+type kubeNodeMetrics struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Usage map[string]string `json:"usage"`
+}
+
+// ErrNoMetrics is returned when the metrics API of the cluster isn't served,
+// metrics-server isn't running
+var ErrNoMetrics = errors.New("the metrics API isn't available, metrics-server isn't running on the cluster")
+
+// ClusterTop returns the usage of the nodes of the cluster of clusterUuid,
+// from the metrics API and the pods scheduled on them. The nodes are returned
+// without their usage along with ErrNoMetrics when the metrics API isn't
+// available.
+func ClusterTop(fqdn, clusterUuid, token string) ([]NodeTop, error) {
+	kube := newKubeAPI(fqdn, clusterUuid, token)
+	nodes, err := kube.nodes()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the nodes: %w", err)
+	}
+	var pods struct {
+		Items []kubePod `json:"items"`
+	}
+	query := url.Values{"fieldSelector": {"status.phase!=Succeeded,status.phase!=Failed"}}
+	if _, err := kube.request("GET", "/api/v1/pods?"+query.Encode(), "application/json", nil, &pods); err != nil {
+		return nil, fmt.Errorf("unable to list the pods: %w", err)
+	}
+
+	var metrics struct {
+		Items []kubeNodeMetrics `json:"items"`
+	}
+	status, metricsErr := kube.request("GET", "/apis/metrics.k8s.io/v1beta1/nodes", "application/json", nil, &metrics)
+	switch {
+	case metricsErr == nil:
+	case status == http.StatusNotFound || status == http.StatusServiceUnavailable:
+		metricsErr = ErrNoMetrics
+	default:
+		metricsErr = fmt.Errorf("unable to get the metrics of the nodes: %w", metricsErr)
+	}
+	return nodeTops(nodes, metrics.Items, pods.Items), metricsErr
+}
+
+// nodeTops joins the nodes with their metrics and the pods scheduled on them
+func nodeTops(nodes []kubeNode, metrics []kubeNodeMetrics, pods []kubePod) []NodeTop {
+	usage := make(map[string]map[string]string)
+	for _, m := range metrics {
+		usage[m.Metadata.Name] = m.Usage
+	}
+	podCount := make(map[string]int)
+	for _, pod := range pods {
+		if pod.Status.Phase != "Succeeded" && pod.Status.Phase != "Failed" {
+			podCount[pod.Spec.NodeName]++
+		}
+	}
+
+	var tops []NodeTop
+	for _, node := range nodes {
+		top := NodeTop{
+			Name:              node.Metadata.Name,
+			Role:              "worker",
+			CPUAllocatable:    parseCPU(node.Status.Allocatable["cpu"]),
+			MemoryAllocatable: parseMemory(node.Status.Allocatable["memory"]),
+			Pods:              podCount[node.Metadata.Name],
+		}
+		top.PodCapacity, _ = strconv.Atoi(node.Status.Allocatable["pods"])
+		for _, label := range []string{"node-role.kubernetes.io/master", "node-role.kubernetes.io/control-plane"} {
+			if _, ok := node.Metadata.Labels[label]; ok {
+				top.Role = "master"
+			}
+		}
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == "Ready" {
+				top.Ready = condition.Status == "True"
+			}
+		}
+		if u, ok := usage[node.Metadata.Name]; ok {
+			top.HasMetrics = true
+			top.CPUUsage = parseCPU(u["cpu"])
+			top.MemoryUsage = parseMemory(u["memory"])
+		}
+		tops = append(tops, top)
+	}
+	sort.Slice(tops, func(i, j int) bool {
+		if tops[i].Role != tops[j].Role {
+			return tops[i].Role == "master"
+		}
+		return tops[i].Name < tops[j].Name
+	})
+	return tops
+}
+
+// quantitySuffixes are the multipliers of the suffixes of the k8s quantities
+var quantitySuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50}, {"Ei", 1 << 60},
+	{"n", 1e-9}, {"u", 1e-6}, {"m", 1e-3},
+	{"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15}, {"E", 1e18},
+}
+
+// parseQuantity parses a k8s quantity like 250m or 16Gi, 0 when it is invalid
+func parseQuantity(quantity string) float64 {
+	multiplier := 1.0
+	for _, s := range quantitySuffixes {
+		if strings.HasSuffix(quantity, s.suffix) {
+			quantity = strings.TrimSuffix(quantity, s.suffix)
+			multiplier = s.multiplier
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(quantity, 64)
+	if err != nil {
+		return 0
+	}
+	return value * multiplier
+}
+
+// parseCPU returns a CPU quantity in millicores
+func parseCPU(quantity string) int64 {
+	return int64(math.Round(parseQuantity(quantity) * 1000))
+}
+
+// parseMemory returns a memory quantity in bytes
+func parseMemory(quantity string) int64 {
+	return int64(math.Round(parseQuantity(quantity)))
+}
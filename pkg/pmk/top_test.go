@@ -0,0 +1,83 @@
+package pmk
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuantity(t *testing.T) {
+	cases := map[string]struct {
+		quantity    string
+		cpu, memory int64
+	}{
+		"Cores":      {"2", 2000, 2},
+		"Millicores": {"250m", 250, 0},
+		"Nanocores":  {"123456789n", 123, 0},
+		"Kibibytes":  {"16384Ki", 16777216000, 16 << 20},
+		"Gibibytes":  {"2Gi", 2147483648000, 2 << 30},
+		"Megabytes":  {"500M", 500000000000, 500000000},
+		"Invalid":    {"abc", 0, 0},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.cpu, parseCPU(tc.quantity))
+			assert.Equal(t, tc.memory, parseMemory(tc.quantity))
+		})
+	}
+}
+
+const topNodes = `{"items": [
+	{"metadata": {"name": "worker-1"}, "status": {"allocatable": {"cpu": "4", "memory": "8Gi", "pods": "110"},
+		"conditions": [{"type": "Ready", "status": "True"}]}},
+	{"metadata": {"name": "master-1", "labels": {"node-role.kubernetes.io/master": ""}},
+		"status": {"allocatable": {"cpu": "2", "memory": "4Gi", "pods": "110"}, "conditions": [{"type": "Ready", "status": "False"}]}}
+]}`
+
+const topPods = `{"items": [
+	{"metadata": {"name": "web-1"}, "spec": {"nodeName": "worker-1"}, "status": {"phase": "Running"}},
+	{"metadata": {"name": "web-2"}, "spec": {"nodeName": "worker-1"}, "status": {"phase": "Pending"}},
+	{"metadata": {"name": "etcd"}, "spec": {"nodeName": "master-1"}, "status": {"phase": "Running"}}
+]}`
+
+func TestClusterTop(t *testing.T) {
+	metrics := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/qbert/v1/clusters/c1/k8sapi"
+		switch r.URL.Path {
+		case prefix + "/api/v1/nodes":
+			w.Write([]byte(topNodes))
+		case prefix + "/api/v1/pods":
+			assert.Equal(t, "status.phase!=Succeeded,status.phase!=Failed", r.URL.Query().Get("fieldSelector"))
+			w.Write([]byte(topPods))
+		case prefix + "/apis/metrics.k8s.io/v1beta1/nodes":
+			if !metrics {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"items": [{"metadata": {"name": "worker-1"}, "usage": {"cpu": "1000000000n", "memory": "2Gi"}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	nodes, err := ClusterTop(server.URL, "c1", "token")
+	assert.Nil(t, err)
+	assert.Equal(t, []NodeTop{
+		{Name: "master-1", Role: "master", CPUAllocatable: 2000, MemoryAllocatable: 4 << 30, Pods: 1, PodCapacity: 110},
+		{Name: "worker-1", Role: "worker", Ready: true, HasMetrics: true, CPUUsage: 1000, CPUAllocatable: 4000,
+			MemoryUsage: 2 << 30, MemoryAllocatable: 8 << 30, Pods: 2, PodCapacity: 110},
+	}, nodes)
+	assert.Equal(t, 25, nodes[1].CPUPercent())
+	assert.Equal(t, 25, nodes[1].MemoryPercent())
+	assert.Equal(t, 0, nodes[0].CPUPercent())
+
+	metrics = false
+	nodes, err = ClusterTop(server.URL, "c1", "token")
+	assert.Equal(t, ErrNoMetrics, err)
+	assert.Len(t, nodes, 2)
+	assert.False(t, nodes[1].HasMetrics)
+}
@@ -0,0 +1,256 @@
+package pmk
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// Files of the watchdog of the pf9 services, installed with prep-node
+// --install-watchdog
+const (
+	watchdogScript  = "/etc/pf9/pf9ctl-watchdog.sh"
+	watchdogService = "/etc/systemd/system/pf9ctl-watchdog.service"
+	watchdogTimer   = "/etc/systemd/system/pf9ctl-watchdog.timer"
+	watchdogState   = "/var/lib/pf9ctl-watchdog/state"
+)
+
+// Delays between the restarts of the watchdog, doubled after each restart
+// which didn't bring the services back
+var (
+	WatchdogBaseDelay = time.Minute
+	WatchdogMaxDelay  = time.Hour
+)
+
+// watchdogScriptTemplate checks pf9-hostagent and pf9-comms run and pf9-comms
+// is connected to the host the DU is reached through, restarting them when
+// they aren't. Only the connections of the processes of pf9-comms count. The
+// delay before the next restart doubles each time they stay unhealthy, and is
+// reset once they are healthy. It leaves the services alone while the node is
+// in maintenance. The state is read as key=value lines rather than sourced, so
+// nothing in it is run.
+const watchdogScriptTemplate = `#!/bin/bash
+# Installed by pf9ctl prep-node --install-watchdog
+STATE=%s
+MAINTENANCE=%s
+DU_HOST=%s
+BASE_DELAY=%d
+MAX_DELAY=%d
+
+mkdir -p "$(dirname "$STATE")"
+failures=0
+last_restart=0
+next_restart=0
+if [ -f "$STATE" ]; then
+    while IFS='=' read -r key value; do
+        case "$value" in
+        '' | *[!0-9]*) continue ;;
+        esac
+        case "$key" in
+        failures) failures=$value ;;
+        last_restart) last_restart=$value ;;
+        next_restart) next_restart=$value ;;
+        esac
+    done < "$STATE"
+fi
+now=$(date +%%s)
+
+if [ -f "$MAINTENANCE" ]; then
+    sed -i "s/^status=.*/status=maintenance/; s/^last_check=.*/last_check=$now/" "$STATE" 2> /dev/null
+    exit 0
+fi
+
+reason=
+for svc in pf9-hostagent pf9-comms; do
+    systemctl is-active --quiet "$svc" || reason="$svc is not running"
+done
+if [ -z "$reason" ]; then
+    # systemd 219 of CentOS 7 doesn't have systemctl show --value
+    main=$(systemctl show -p MainPID pf9-comms | cut -d= -f2)
+    pids=$(echo "$main" $(pgrep -P "$main") | tr ' ' '|')
+    connected=
+    for ip in $(getent ahosts "$DU_HOST" | awk '{print $1}' | sort -u); do
+        ss -tnp state established dst "$ip" | grep -qE "pid=($pids)," && connected=1
+    done
+    [ -n "$connected" ] || reason="pf9-comms has no connection to $DU_HOST"
+fi
+
+if [ -z "$reason" ]; then
+    status=healthy
+    failures=0
+    next_restart=0
+elif [ "$now" -ge "$next_restart" ]; then
+    status=unhealthy
+    systemctl restart pf9-comms pf9-hostagent
+    delay=$((BASE_DELAY << (failures < 10 ? failures : 10)))
+    [ "$delay" -gt "$MAX_DELAY" ] && delay=$MAX_DELAY
+    failures=$((failures + 1))
+    last_restart=$now
+    next_restart=$((now + delay))
+    logger -t pf9ctl-watchdog "Restarted pf9-comms and pf9-hostagent: $reason, next restart in ${delay}s at the earliest"
+else
+    status=unhealthy
+fi
+
+cat > "$STATE" <<EOF
+status=$status
+reason=$reason
+failures=$failures
+last_check=$now
+last_restart=$last_restart
+next_restart=$next_restart
+EOF
+`
+
+const watchdogServiceUnit = `[Unit]
+Description=pf9ctl watchdog of pf9-hostagent and pf9-comms
+After=network-online.target
+
+[Service]
+Type=oneshot
+ExecStart=/bin/bash ` + watchdogScript + `
+`
+
+const watchdogTimerUnit = `[Unit]
+Description=Run the pf9ctl watchdog of pf9-hostagent and pf9-comms every minute
+
+[Timer]
+OnBootSec=5min
+OnUnitActiveSec=1min
+
+[Install]
+WantedBy=timers.target
+`
+
+// watchdogInstallScript writes the watchdog, checking the connection of
+// pf9-comms to duHost, and starts its timer
+func watchdogInstallScript(duHost string) string {
+	script := fmt.Sprintf(watchdogScriptTemplate, watchdogState, maintenanceMarker, cmdexec.ShellQuote(duHost),
+		int(WatchdogBaseDelay.Seconds()), int(WatchdogMaxDelay.Seconds()))
+	var b strings.Builder
+	b.WriteString("set -e\nmkdir -p /etc/pf9\n")
+	for _, file := range []struct{ path, content string }{
+		{watchdogScript, script},
+		{watchdogService, watchdogServiceUnit},
+		{watchdogTimer, watchdogTimerUnit},
+	} {
+		fmt.Fprintf(&b, "cat > %s <<'PF9_EOF'\n%sPF9_EOF\n", file.path, file.content)
+	}
+	b.WriteString("systemctl daemon-reload\nsystemctl enable --now pf9ctl-watchdog.timer\n")
+	return b.String()
+}
+
+// WatchdogHost returns the host pf9-comms connects to, the proxy the DU is
+// reached through when proxyURL is set, the DU at fqdn otherwise
+func WatchdogHost(fqdn, proxyURL string) string {
+	if proxyURL != "" {
+		if u, err := url.Parse(proxyURL); err == nil && u.Hostname() != "" {
+			return u.Hostname()
+		}
+	}
+	return DUHost(fqdn)
+}
+
+// InstallWatchdog installs the systemd timer restarting pf9-hostagent and
+// pf9-comms when they stop or pf9-comms loses its connection to duHost, the
+// host of the DU or of the proxy it is reached through
+func InstallWatchdog(exec cmdexec.Executor, duHost string) error {
+	zap.S().Debugf("Installing the watchdog of the pf9 services, checking the connection to %s", duHost)
+	if _, err := exec.RunArgs("bash", "-c", watchdogInstallScript(duHost)); err != nil {
+		return fmt.Errorf("unable to install the watchdog: %w", err)
+	}
+	return nil
+}
+
+// RemoveWatchdog stops and removes the watchdog, which is a no-op when it
+// isn't installed
+func RemoveWatchdog(exec cmdexec.Executor) error {
+	script := fmt.Sprintf("systemctl disable --now pf9ctl-watchdog.timer 2> /dev/null || true\nrm -rf %s %s %s %s\nsystemctl daemon-reload\n",
+		watchdogTimer, watchdogService, watchdogScript, strings.TrimSuffix(watchdogState, "/state"))
+	if _, err := exec.RunArgs("bash", "-c", script); err != nil {
+		return fmt.Errorf("unable to remove the watchdog: %w", err)
+	}
+	return nil
+}
+
+// WatchdogHealth is the health of the pf9 services as last checked by the
+// watchdog
+type WatchdogHealth struct {
+	Installed bool
+	// Active is set when the timer of the watchdog runs
+	Active      bool
+	Status      string
+	Reason      string
+	Failures    int
+	LastCheck   time.Time
+	LastRestart time.Time
+	NextRestart time.Time
+}
+
+// ReadWatchdogHealth reads the health of the pf9 services the watchdog of the
+// node of exec last recorded
+func ReadWatchdogHealth(exec cmdexec.Executor) WatchdogHealth {
+	var health WatchdogHealth
+	if _, err := exec.RunArgs("test", "-f", watchdogTimer); err != nil {
+		return health
+	}
+	health.Installed = true
+	_, err := exec.RunArgs("systemctl", "is-active", "--quiet", "pf9ctl-watchdog.timer")
+	health.Active = err == nil
+	if state, err := exec.RunArgs("cat", watchdogState); err == nil {
+		parseWatchdogState(state, &health)
+	}
+	return health
+}
+
+// parseWatchdogState reads the key=value state written by the watchdog
+func parseWatchdogState(state string, health *WatchdogHealth) {
+	epoch := func(value string) time.Time {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seconds == 0 {
+			return time.Time{}
+		}
+		return time.Unix(seconds, 0)
+	}
+	for _, line := range strings.Split(state, "\n") {
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		key, value := line[:i], strings.Trim(line[i+1:], `"`)
+		switch key {
+		case "status":
+			health.Status = value
+		case "reason":
+			health.Reason = value
+		case "failures":
+			health.Failures, _ = strconv.Atoi(value)
+		case "last_check":
+			health.LastCheck = epoch(value)
+		case "last_restart":
+			health.LastRestart = epoch(value)
+		case "next_restart":
+			health.NextRestart = epoch(value)
+		}
+	}
+}
+
+// watchedServices are the services the watchdog restarts
+var watchedServices = []string{"pf9-hostagent", "pf9-comms"}
+
+// InactiveServices returns the services watched by the watchdog which don't
+// run on the node of exec
+func InactiveServices(exec cmdexec.Executor) []string {
+	var inactive []string
+	for _, service := range watchedServices {
+		if _, err := exec.RunArgs("systemctl", "is-active", "--quiet", service); err != nil {
+			inactive = append(inactive, service)
+		}
+	}
+	return inactive
+}
@@ -0,0 +1,82 @@
+package pmk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWatchdogState(t *testing.T) {
+	var health WatchdogHealth
+	parseWatchdogState(`status=unhealthy
+reason="pf9-comms has no connection to du.example.com"
+failures=3
+last_check=1700000060
+last_restart=1700000000
+next_restart=1700000480
+`, &health)
+	assert.Equal(t, WatchdogHealth{
+		Status:      "unhealthy",
+		Reason:      "pf9-comms has no connection to du.example.com",
+		Failures:    3,
+		LastCheck:   time.Unix(1700000060, 0),
+		LastRestart: time.Unix(1700000000, 0),
+		NextRestart: time.Unix(1700000480, 0),
+	}, health)
+
+	health = WatchdogHealth{}
+	parseWatchdogState("status=healthy\nreason=\"\"\nlast_restart=0\nnext_restart=0\n", &health)
+	assert.Equal(t, "healthy", health.Status)
+	assert.True(t, health.LastRestart.IsZero())
+	assert.True(t, health.NextRestart.IsZero())
+}
+
+func TestWatchdogInstallScript(t *testing.T) {
+	script := watchdogInstallScript("du.example.com")
+	assert.Contains(t, script, "DU_HOST='du.example.com'")
+	assert.Contains(t, script, "BASE_DELAY=60\nMAX_DELAY=3600\n")
+	assert.Contains(t, script, "MAINTENANCE="+maintenanceMarker)
+	assert.Contains(t, script, "cat > "+watchdogTimer)
+	assert.True(t, strings.HasSuffix(script, "systemctl enable --now pf9ctl-watchdog.timer\n"))
+	assert.NotContains(t, script, "%!")
+	// The state is parsed, never sourced
+	assert.NotContains(t, script, `. "$STATE"`)
+	assert.Contains(t, script, `done < "$STATE"`)
+	assert.Contains(t, script, `grep -qE "pid=($pids),"`)
+}
+
+func TestWatchdogHost(t *testing.T) {
+	assert.Equal(t, "du.example.com", WatchdogHost("https://du.example.com", ""))
+	assert.Equal(t, "proxy.example.com", WatchdogHost("https://du.example.com", "http://proxy.example.com:3128"))
+	assert.Equal(t, "du.example.com", WatchdogHost("https://du.example.com", "://"))
+}
+
+func TestReadWatchdogHealth(t *testing.T) {
+	exec := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			switch name {
+			case "cat":
+				return "status=healthy\nfailures=0\n", nil
+			case "systemctl":
+				return "", errors.New("exit status 3")
+			}
+			return "", nil
+		},
+	}
+	health := ReadWatchdogHealth(exec)
+	assert.True(t, health.Installed)
+	assert.False(t, health.Active)
+	assert.Equal(t, "healthy", health.Status)
+
+	missing := &cmdexec.MockExecutor{
+		MockRunArgs: func(name string, args ...string) (string, error) {
+			return "", errors.New("exit status 1")
+		},
+	}
+	assert.Equal(t, WatchdogHealth{}, ReadWatchdogHealth(missing))
+	assert.Equal(t, watchedServices, InactiveServices(missing))
+}
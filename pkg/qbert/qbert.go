@@ -44,8 +44,17 @@ type Qbert interface {
 	CheckClusterExists(Name, projectID, token string) (bool, string, string, error)
 	CheckClusterExistsWithUuid(uuid, projectID, token string) (string, error)
 	GetNodeInfo(token, projectID, hostUUID string) Node
+	NodeInfo(token, projectID, hostUUID string) (Node, error)
 	GetAllNodes(token, projectID string) []Node
-	GetPMKVersions(token, projectID string) PMKVersions
+	ListNodes(token, projectID string) ([]Node, error)
+	GetPMKVersions(token, projectID string) (PMKVersions, error)
+	GetCluster(clusterID, projectID, token string) (Cluster, error)
+	ListClusters(projectID, token string) ([]Cluster, error)
+	GetClusterSpec(clusterID, projectID, token string) (map[string]interface{}, error)
+	GetClusterAddons(clusterID, projectID, token string) ([]ClusterAddon, error)
+	GetKubeconfig(clusterID, projectID, token string) ([]byte, error)
+	RotateClusterCerts(clusterID, projectID, token string) error
+	APIVersion(projectID, token string) string
 }
 
 func NewQbert(fqdn string) Qbert {
@@ -62,6 +71,10 @@ type PMKVersions struct {
 	} `json:"roles"`
 }
 
+// ErrNodeNotFound is returned when qbert doesn't know the node, e.g. a host
+// authorized without the kube role
+var ErrNodeNotFound = errors.New("node not found")
+
 var (
 	IStag                bool
 	IsPMKversionDefined  bool
@@ -73,11 +86,34 @@ var (
 )
 
 type Node struct {
-	Uuid        string `json:"uuid"`
-	ClusterUuid string `json:"clusterUuid"`
-	PrimaryIp   string `json:"primaryIp"`
-	IsMaster    int    `json:"isMaster"`
-	ClusterName string `json:"clusterName"`
+	Uuid          string `json:"uuid"`
+	Name          string `json:"name"`
+	ClusterUuid   string `json:"clusterUuid"`
+	PrimaryIp     string `json:"primaryIp"`
+	IsMaster      int    `json:"isMaster"`
+	ClusterName   string `json:"clusterName"`
+	Status        string `json:"status"`
+	ApiResponding int    `json:"api_responding"`
+}
+
+// Cluster holds the state of a cluster as reported by qbert
+type Cluster struct {
+	Uuid            string `json:"uuid"`
+	Name            string `json:"name"`
+	Status          string `json:"status"`
+	TaskStatus      string `json:"taskStatus"`
+	TaskError       string `json:"taskError"`
+	LastOp          string `json:"lastOp"`
+	LastOk          string `json:"lastOk"`
+	KubeRoleVersion string `json:"kubeRoleVersion"`
+}
+
+// ClusterAddon is an addon deployed on a cluster through sunpike
+type ClusterAddon struct {
+	Type    string `json:"type"`
+	Version string `json:"version"`
+	Phase   string `json:"phase"`
+	Message string `json:"message,omitempty"`
 }
 
 type ClusterCreateRequest struct {
@@ -202,9 +238,8 @@ func (c QbertImpl) AttachNode(clusterID, projectID, token string, nodeIDs []stri
 		return fmt.Errorf("Unable to marshal payload: %s", err.Error())
 	}
 
-	attachEndpoint := fmt.Sprintf(
-		"%s/qbert/v3/%s/clusters/%s/attach",
-		c.fqdn, projectID, clusterID)
+	version := c.APIVersion(projectID, token)
+	attachEndpoint := c.clusterActionURL(version, projectID, clusterID, "attach")
 
 	resp, err := Attach_Status(attachEndpoint, token, byt)
 	if err != nil {
@@ -213,7 +248,7 @@ func (c QbertImpl) AttachNode(clusterID, projectID, token string, nodeIDs []stri
 
 	LoopVariable := 1
 	for LoopVariable <= util.MaxRetryValue {
-		if resp.StatusCode != 200 {
+		if !actionSucceeded(version, resp.StatusCode) {
 			time.Sleep(30 * time.Second)
 			zap.S().Debug("Trying to attach-node to cluster")
 			resp, err = Attach_Status(attachEndpoint, token, byt)
@@ -225,7 +260,7 @@ func (c QbertImpl) AttachNode(clusterID, projectID, token string, nodeIDs []stri
 			break
 		}
 	}
-	if resp.StatusCode != 200 {
+	if !actionSucceeded(version, resp.StatusCode) {
 		respString, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
 			zap.S().Errorf("Error occurred while converting response body to string")
@@ -250,9 +285,8 @@ func (c QbertImpl) DetachNode(clusterID, projectID, token string, nodeID string)
 		return fmt.Errorf("Unable to marshal payload: %s", err.Error())
 	}
 
-	detachEndpoint := fmt.Sprintf(
-		"%s/qbert/v3/%s/clusters/%s/detach",
-		c.fqdn, projectID, clusterID)
+	version := c.APIVersion(projectID, token)
+	detachEndpoint := c.clusterActionURL(version, projectID, clusterID, "detach")
 
 	client := http.Client{}
 
@@ -270,7 +304,7 @@ func (c QbertImpl) DetachNode(clusterID, projectID, token string, nodeID string)
 
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
+	if !actionSucceeded(version, resp.StatusCode) {
 		respString, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
 			zap.S().Info("Error occurred while converting response body to string")
@@ -566,80 +600,286 @@ func getTagDetails() string {
 }
 
 func (c QbertImpl) GetNodeInfo(token, projectID, hostUUID string) Node {
+	node, err := c.NodeInfo(token, projectID, hostUUID)
+	if err != nil {
+		zap.S().Infof("%s", err)
+	}
+	return node
+}
+
+// NodeInfo returns the node with hostUUID, failing when qbert can't return it
+func (c QbertImpl) NodeInfo(token, projectID, hostUUID string) (Node, error) {
 	url := fmt.Sprintf("%s/qbert/v3/%s/nodes/%s", c.fqdn, projectID, hostUUID)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		zap.S().Infof("Unable to create request to check if node is connected to any cluster: %w", err)
+		return Node{}, fmt.Errorf("Unable to create request to check if node is connected to any cluster: %w", err)
 	}
 	req.Header.Set("X-Auth-Token", token)
 	req.Header.Set("Content-Type", "application/json")
 	client := http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		zap.S().Infof("Unable to send request to qbert: %w", err)
+		return Node{}, fmt.Errorf("Unable to send request to qbert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Node{}, ErrNodeNotFound
+	}
+	if resp.StatusCode != 200 {
+		return Node{}, fmt.Errorf("could not query the qbert endpoint: %d", resp.StatusCode)
 	}
 
 	node := Node{}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		zap.S().Infof("Unable to read resp body of node info: %w", err)
+	if err := json.NewDecoder(resp.Body).Decode(&node); err != nil {
+		return Node{}, fmt.Errorf("Unable to decode node info: %w", err)
 	}
-	err = json.Unmarshal(body, &node)
+	return node, nil
+}
+
+func (c QbertImpl) GetAllNodes(token, projectID string) []Node {
+	nodes, err := c.ListNodes(token, projectID)
 	if err != nil {
-		zap.S().Infof("Unable to unmarshal node info: %w", err)
+		zap.S().Infof("%s", err)
 	}
-	return node
+	return nodes
 }
 
-func (c QbertImpl) GetAllNodes(token, projectID string) []Node {
+// ListNodes returns the nodes of the project, failing when qbert can't list
+// them
+func (c QbertImpl) ListNodes(token, projectID string) ([]Node, error) {
 	url := fmt.Sprintf("%s/qbert/v3/%s/nodes", c.fqdn, projectID)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		zap.S().Infof("Unable to create request to check if node is connected to any cluster: %w", err)
+		return nil, fmt.Errorf("Unable to create request to list nodes: %w", err)
 	}
 	req.Header.Set("X-Auth-Token", token)
 	req.Header.Set("Content-Type", "application/json")
 	client := http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		zap.S().Infof("Unable to send request to qbert: %w", err)
+		return nil, fmt.Errorf("Unable to send request to qbert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("could not query the qbert endpoint: %d", resp.StatusCode)
 	}
 
 	var nodes []Node
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		return nil, fmt.Errorf("Unable to decode nodes: %w", err)
+	}
+	return nodes, nil
+}
+
+func (c QbertImpl) GetPMKVersions(token, projectID string) (PMKVersions, error) {
+	pmkVersions := PMKVersions{}
+	url := fmt.Sprintf("%s/qbert/v4/%s/clusters/supportedRoleVersions", c.fqdn, projectID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return pmkVersions, fmt.Errorf("Unable to create request to get pmk versions: %w", err)
+	}
+	req.Header.Set("X-Auth-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return pmkVersions, fmt.Errorf("Unable to send request to qbert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return pmkVersions, fmt.Errorf("could not query the qbert endpoint: %d", resp.StatusCode)
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		zap.S().Infof("Unable to read resp body of node info: %w", err)
+		return pmkVersions, fmt.Errorf("Unable to read resp body: %w", err)
 	}
-	err = json.Unmarshal(body, &nodes)
+	if err := json.Unmarshal(body, &pmkVersions); err != nil {
+		return pmkVersions, fmt.Errorf("Unable to unmarshal resp body: %w", err)
+	}
+	return pmkVersions, nil
+}
+
+func (c QbertImpl) GetCluster(clusterID, projectID, token string) (Cluster, error) {
+	cluster := Cluster{}
+	url := fmt.Sprintf("%s/qbert/v3/%s/clusters/%s", c.fqdn, projectID, clusterID)
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		zap.S().Infof("Unable to unmarshal node info: %w", err)
+		return cluster, fmt.Errorf("Unable to create request to get cluster: %w", err)
 	}
-	return nodes
+	req.Header.Set("X-Auth-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return cluster, fmt.Errorf("Unable to send request to qbert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return cluster, fmt.Errorf("could not query the qbert endpoint: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&cluster); err != nil {
+		return cluster, fmt.Errorf("Unable to decode cluster: %w", err)
+	}
+	return cluster, nil
 }
 
-func (c QbertImpl) GetPMKVersions(token, projectID string) PMKVersions {
-	url := fmt.Sprintf("%s/qbert/v4/%s/clusters/supportedRoleVersions", c.fqdn, projectID)
+// ListClusters returns all the clusters of the project
+func (c QbertImpl) ListClusters(projectID, token string) ([]Cluster, error) {
+	url := fmt.Sprintf("%s/qbert/v3/%s/clusters", c.fqdn, projectID)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		zap.S().Infof("Unable to create request to get pmk versions: %w", err)
+		return nil, fmt.Errorf("Unable to create request to list clusters: %w", err)
 	}
 	req.Header.Set("X-Auth-Token", token)
 	req.Header.Set("Content-Type", "application/json")
 	client := http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		zap.S().Infof("Unable to send request to qbert: %w", err)
+		return nil, fmt.Errorf("Unable to send request to qbert: %w", err)
 	}
+	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("could not query the qbert endpoint: %d", resp.StatusCode)
+	}
+
+	var clusters []Cluster
+	if err := json.NewDecoder(resp.Body).Decode(&clusters); err != nil {
+		return nil, fmt.Errorf("Unable to decode clusters: %w", err)
+	}
+	return clusters, nil
+}
+
+// GetClusterSpec returns all the settings of the cluster as reported by qbert
+func (c QbertImpl) GetClusterSpec(clusterID, projectID, token string) (map[string]interface{}, error) {
+	url := fmt.Sprintf("%s/qbert/v4/%s/clusters/%s", c.fqdn, projectID, clusterID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create request to get cluster: %w", err)
+	}
+	req.Header.Set("X-Auth-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+	client := http.Client{}
+	resp, err := client.Do(req)
 	if err != nil {
-		zap.S().Infof("Unable to read resp body: %w", err)
+		return nil, fmt.Errorf("Unable to send request to qbert: %w", err)
 	}
+	defer resp.Body.Close()
 
-	pmkVersions := PMKVersions{}
-	err = json.Unmarshal(body, &pmkVersions)
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("could not query the qbert endpoint: %d", resp.StatusCode)
+	}
+
+	var spec map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		return nil, fmt.Errorf("Unable to decode cluster: %w", err)
+	}
+	return spec, nil
+}
+
+// GetClusterAddons returns the addons of the cluster. DUs without the
+// clusteraddons API of sunpike return an empty list.
+func (c QbertImpl) GetClusterAddons(clusterID, projectID, token string) ([]ClusterAddon, error) {
+	url := fmt.Sprintf("%s/qbert/v4/%s/sunpike/apis/sunpike.platform9.com/v1alpha2/namespaces/default/clusteraddons?labelSelector=sunpike.pf9.io/cluster=%s",
+		c.fqdn, projectID, clusterID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create request to get cluster addons: %w", err)
+	}
+	req.Header.Set("X-Auth-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+	client := http.Client{}
+	resp, err := client.Do(req)
 	if err != nil {
-		zap.S().Infof("Unable to unmarshal resp body: %w", err)
+		return nil, fmt.Errorf("Unable to send request to qbert: %w", err)
 	}
-	return pmkVersions
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("could not query the clusteraddons endpoint: %d", resp.StatusCode)
+	}
+
+	var list struct {
+		Items []struct {
+			Spec struct {
+				Type    string `json:"type"`
+				Version string `json:"version"`
+			} `json:"spec"`
+			Status struct {
+				Phase   string `json:"phase"`
+				Message string `json:"message"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("Unable to decode cluster addons: %w", err)
+	}
+	var addons []ClusterAddon
+	for _, item := range list.Items {
+		addons = append(addons, ClusterAddon{
+			Type:    item.Spec.Type,
+			Version: item.Spec.Version,
+			Phase:   item.Status.Phase,
+			Message: item.Status.Message,
+		})
+	}
+	return addons, nil
+}
+
+// GetKubeconfig returns the kubeconfig of the cluster, which holds the CA of
+// the cluster
+func (c QbertImpl) GetKubeconfig(clusterID, projectID, token string) ([]byte, error) {
+	url := fmt.Sprintf("%s/qbert/v1/projects/%s/kubeconfig/%s", c.fqdn, projectID, clusterID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create request to get kubeconfig: %w", err)
+	}
+	req.Header.Set("X-Auth-Token", token)
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to send request to qbert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("could not query the qbert endpoint: %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// RotateClusterCerts triggers the rotation of the certificates of the control
+// plane of the cluster. The nodes of the cluster converge to the new
+// certificates afterwards.
+func (c QbertImpl) RotateClusterCerts(clusterID, projectID, token string) error {
+	url := fmt.Sprintf("%s/qbert/v4/%s/clusters/%s/certs/rotate", c.fqdn, projectID, clusterID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return fmt.Errorf("Unable to create request to rotate certificates: %w", err)
+	}
+	req.Header.Set("X-Auth-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Unable to send request to qbert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed:
+		return fmt.Errorf("the DU doesn't support rotating the certificates of clusters: %d", resp.StatusCode)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("could not rotate the certificates: %d", resp.StatusCode)
+	}
+	return nil
 }
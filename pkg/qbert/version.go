@@ -0,0 +1,86 @@
+package qbert
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// API versions of qbert the CLI speaks. v4 is served by the recent management
+// planes, the older ones only serve v3.
+const (
+	APIv3 = "v3"
+	APIv4 = "v4"
+)
+
+// discoveryTimeout bounds the discovery of the API version of a DU
+const discoveryTimeout = 30 * time.Second
+
+// apiVersions caches the API version of qbert of each DU for the command
+var apiVersions = struct {
+	sync.Mutex
+	byDU map[string]string
+}{byDU: make(map[string]string)}
+
+// APIVersion returns the newest API version of qbert the DU serves, discovered
+// once per DU. It is v3 when the DU can't tell, as every DU serves it.
+func (c QbertImpl) APIVersion(projectID, token string) string {
+	apiVersions.Lock()
+	defer apiVersions.Unlock()
+	if version, ok := apiVersions.byDU[c.fqdn]; ok {
+		return version
+	}
+	version, known := discoverAPIVersion(c.fqdn, projectID, token)
+	if known {
+		apiVersions.byDU[c.fqdn] = version
+	}
+	zap.S().Debugf("Using the %s API of qbert at %s", version, c.fqdn)
+	return version
+}
+
+// discoverAPIVersion asks the DU at fqdn for the role versions of v4, which the
+// DUs serving only v3 don't know. known is false when the answer doesn't tell
+// the version, as when the token is refused.
+func discoverAPIVersion(fqdn, projectID, token string) (version string, known bool) {
+	url := fmt.Sprintf("%s/qbert/v4/%s/clusters/supportedRoleVersions", fqdn, projectID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return APIv3, false
+	}
+	req.Header.Set("X-Auth-Token", token)
+	client := http.Client{Timeout: discoveryTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		zap.S().Debugf("Unable to discover the API version of qbert: %s", err)
+		return APIv3, false
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return APIv4, true
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusMethodNotAllowed, resp.StatusCode == http.StatusNotImplemented:
+		return APIv3, true
+	}
+	zap.S().Debugf("Unable to discover the API version of qbert: %s answered %s", url, resp.Status)
+	return APIv3, false
+}
+
+// clusterActionURL returns the URL of action, as attach, on the cluster in
+// version of the API
+func (c QbertImpl) clusterActionURL(version, projectID, clusterID, action string) string {
+	return fmt.Sprintf("%s/qbert/%s/%s/clusters/%s/%s", c.fqdn, version, projectID, clusterID, action)
+}
+
+// actionSucceeded tells whether status answers a successful action on a
+// cluster. v4 accepts the actions it runs asynchronously with 202, v3 always
+// answers 200.
+func actionSucceeded(version string, status int) bool {
+	if version == APIv4 {
+		return status >= 200 && status < 300
+	}
+	return status == http.StatusOK
+}
@@ -0,0 +1,121 @@
+package qbert
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDU serves the v4 API when v4 is set, recording the paths of the
+// requests
+func fakeDU(v4 bool, status int, paths *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*paths = append(*paths, r.Method+" "+r.URL.Path)
+		if strings.HasPrefix(r.URL.Path, "/qbert/v4/") && !v4 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/supportedRoleVersions") {
+			w.WriteHeader(status)
+			return
+		}
+		ioutil.ReadAll(r.Body)
+		if strings.HasPrefix(r.URL.Path, "/qbert/v4/") {
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+}
+
+func TestAPIVersion(t *testing.T) {
+	cases := map[string]struct {
+		v4         bool
+		status     int
+		want       string
+		wantProbes int
+	}{
+		"V4":           {true, http.StatusOK, APIv4, 1},
+		"V3":           {false, http.StatusOK, APIv3, 1},
+		"Unauthorized": {true, http.StatusUnauthorized, APIv3, 2},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var paths []string
+			du := fakeDU(tc.v4, tc.status, &paths)
+			defer du.Close()
+
+			q := QbertImpl{du.URL}
+			assert.Equal(t, tc.want, q.APIVersion("p1", "token"))
+			// The version is discovered once, unless the DU couldn't tell
+			assert.Equal(t, tc.want, q.APIVersion("p1", "token"))
+			assert.Len(t, paths, tc.wantProbes)
+		})
+	}
+}
+
+func TestAttachDetachVersion(t *testing.T) {
+	for _, v4 := range []bool{true, false} {
+		var paths []string
+		du := fakeDU(v4, http.StatusOK, &paths)
+		q := QbertImpl{du.URL}
+
+		assert.NoError(t, q.AttachNode("c1", "p1", "token", []string{"n1"}, "worker"))
+		assert.NoError(t, q.DetachNode("c1", "p1", "token", "n1"))
+		version := APIv3
+		if v4 {
+			version = APIv4
+		}
+		assert.Equal(t, []string{
+			"GET /qbert/v4/p1/clusters/supportedRoleVersions",
+			"POST /qbert/" + version + "/p1/clusters/c1/attach",
+			"POST /qbert/" + version + "/p1/clusters/c1/detach",
+		}, paths)
+		du.Close()
+	}
+}
+
+func TestActionSucceeded(t *testing.T) {
+	assert.True(t, actionSucceeded(APIv4, http.StatusAccepted))
+	assert.True(t, actionSucceeded(APIv3, http.StatusOK))
+	assert.False(t, actionSucceeded(APIv3, http.StatusAccepted))
+	assert.False(t, actionSucceeded(APIv4, http.StatusConflict))
+}
+
+func TestGetPMKVersions(t *testing.T) {
+	var paths []string
+	du := fakeDU(true, http.StatusInternalServerError, &paths)
+	_, err := QbertImpl{du.URL}.GetPMKVersions("token", "p1")
+	assert.EqualError(t, err, "could not query the qbert endpoint: 500")
+
+	// The DU can't be reached once closed
+	du.Close()
+	_, err = QbertImpl{du.URL}.GetPMKVersions("token", "p1")
+	assert.Error(t, err)
+}
+
+func TestNodeInfo(t *testing.T) {
+	du := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/qbert/v3/p1/nodes/host-1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"uuid":"host-1","clusterUuid":"uuid-prod","isMaster":1}`))
+	}))
+	node, err := QbertImpl{du.URL}.NodeInfo("token", "p1", "host-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "uuid-prod", node.ClusterUuid)
+	assert.Equal(t, 1, node.IsMaster)
+
+	_, err = QbertImpl{du.URL}.NodeInfo("token", "p1", "host-2")
+	assert.Equal(t, ErrNodeNotFound, err)
+
+	// GetNodeInfo returns an empty node rather than panicking once the DU
+	// can't be reached
+	du.Close()
+	_, err = QbertImpl{du.URL}.NodeInfo("token", "p1", "host-1")
+	assert.Error(t, err)
+	assert.Equal(t, Node{}, QbertImpl{du.URL}.GetNodeInfo("token", "p1", "host-1"))
+}
@@ -3,10 +3,10 @@ package resmgr
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 
-	"crypto/tls"
 	"net/http"
 	"time"
 
@@ -19,8 +19,13 @@ type Resmgr interface {
 	AuthorizeHost(hostID, token string) error
 	GetHostId(token string, hostIP []string) []string
 	HostSatus(token string, hostID string) bool
+	GetHostInfo(token string, hostID string) (HostInfo, error)
+	GetHosts(token string) ([]HostInfo, error)
 }
 
+// ErrHostNotFound is returned when resmgr doesn't know the host
+var ErrHostNotFound = errors.New("host not found")
+
 type ResmgrImpl struct {
 	fqdn          string
 	minWait       time.Duration
@@ -38,6 +43,33 @@ type hostInfo []struct {
 	ID string `json:"id,omitempty"`
 }
 
+// HostInfo is the subset of the resmgr host details used by pf9ctl
+type HostInfo struct {
+	ID         string   `json:"id"`
+	State      string   `json:"state"`
+	Roles      []string `json:"roles"`
+	RoleStatus string   `json:"role_status,omitempty"`
+	Extensions struct {
+		IPAddress struct {
+			Data []string `json:"data"`
+		} `json:"ip_address,omitempty"`
+		// KubeStatus is reported by the pf9-kube role as it converges, its
+		// data holds the tasks it runs
+		KubeStatus struct {
+			Status string          `json:"status"`
+			Data   json.RawMessage `json:"data"`
+		} `json:"pf9_kube_status,omitempty"`
+	} `json:"extensions,omitempty"`
+	Info struct {
+		Hostname   string `json:"hostname"`
+		OSFamily   string `json:"os_family"`
+		OSInfo     string `json:"os_info"`
+		Responding bool   `json:"responding"`
+		// LastResponseTime is when the hostagent of the host last reported to resmgr
+		LastResponseTime string `json:"last_response_time,omitempty"`
+	} `json:"info"`
+}
+
 func NewResmgr(fqdn string, maxHttpRetry int, minWait, maxWait time.Duration, allowInsecure bool) Resmgr {
 
 	return &ResmgrImpl{fqdn, minWait, maxWait, maxHttpRetry, allowInsecure}
@@ -48,7 +80,9 @@ func (c *ResmgrImpl) AuthorizeHost(hostID string, token string) error {
 	zap.S().Debugf("Authorizing the host: %s with DU: %s", hostID, c.fqdn)
 
 	client := rhttp.NewClient()
-	client.HTTPClient.Transport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	// Its retries are sent through the default transport, rate limited with
+	// the other requests to the DU
+	client.HTTPClient.Transport = http.DefaultTransport
 
 	client.RetryWaitMin = c.minWait
 	client.RetryWaitMax = c.maxWait
@@ -107,7 +141,7 @@ func (c *ResmgrImpl) GetHostId(token string, hostIPs []string) []string {
 		hostNotFound := true
 		for _, node := range nodeData {
 			for _, ip := range node.Extensions.IPAddress.Data {
-				if ip == hostip {
+				if util.SameIP(ip, hostip) {
 					hostUUIDs = append(hostUUIDs, node.ID)
 					hostNotFound = false
 				}
@@ -151,3 +185,58 @@ func (c *ResmgrImpl) HostSatus(token string, hostID string) bool {
 	}
 	return host.Info.Responding
 }
+
+// GetHostInfo returns the details resmgr has about the host with hostID.
+func (c *ResmgrImpl) GetHostInfo(token string, hostID string) (HostInfo, error) {
+	host := HostInfo{}
+	url := fmt.Sprintf("%s/resmgr/v1/hosts/%s", c.fqdn, hostID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return host, fmt.Errorf("Unable to create a new request: %w", err)
+	}
+	req.Header.Set("X-Auth-Token", token)
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return host, fmt.Errorf("Client is unable to send the request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return host, ErrHostNotFound
+	}
+	if resp.StatusCode != 200 {
+		return host, fmt.Errorf("Unable to get host info, code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&host); err != nil {
+		return host, fmt.Errorf("Unable to decode host info: %w", err)
+	}
+	return host, nil
+}
+
+// GetHosts returns all the hosts registered with resmgr
+func (c *ResmgrImpl) GetHosts(token string) ([]HostInfo, error) {
+	url := fmt.Sprintf("%s/resmgr/v1/hosts", c.fqdn)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create a new request: %w", err)
+	}
+	req.Header.Set("X-Auth-Token", token)
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Client is unable to send the request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Unable to get hosts, code: %d", resp.StatusCode)
+	}
+
+	var hosts []HostInfo
+	if err := json.NewDecoder(resp.Body).Decode(&hosts); err != nil {
+		return nil, fmt.Errorf("Unable to decode hosts: %w", err)
+	}
+	return hosts, nil
+}
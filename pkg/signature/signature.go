@@ -0,0 +1,190 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+
+// Package signature verifies the GPG signatures of the installers downloaded
+// from the DU against the signing keys Platform9 publishes. The keys are
+// bundled at build time and can be refreshed from where they are published.
+package signature
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/statefile"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"golang.org/x/crypto/openpgp"
+)
+
+// BundledKeys are the signing keys of Platform9, armored then base64 encoded
+// to be set at build time like the segment key
+var BundledKeys string
+
+// KeysURL is where Platform9 publishes its signing keys, set at build time.
+// A detached signature of the keys is published next to them with the .asc
+// extension.
+var KeysURL string
+
+// KeyringFile holds the signing keys refreshed with 'pf9ctl signing-keys
+// refresh', which are used instead of the bundled ones
+var KeyringFile = filepath.Join(util.Pf9DBDir, "signing-keys.asc")
+
+// ErrNoKeys is returned when neither keys are bundled nor refreshed
+var ErrNoKeys = fmt.Errorf("no signing keys of Platform9 are bundled with this build, run 'pf9ctl signing-keys refresh' or skip the verification with --skip-signature-verify")
+
+// ReadKeyring reads the armored signing keys of r
+func ReadKeyring(r io.Reader) (openpgp.EntityList, error) {
+	keys, err := openpgp.ReadArmoredKeyRing(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("invalid signing keys: no key found")
+	}
+	return keys, nil
+}
+
+// bundledKeyring returns the keys bundled at build time
+func bundledKeyring() (openpgp.EntityList, error) {
+	if BundledKeys == "" {
+		return nil, ErrNoKeys
+	}
+	armored, err := base64.StdEncoding.DecodeString(BundledKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundled signing keys: %w", err)
+	}
+	return ReadKeyring(bytes.NewReader(armored))
+}
+
+// Keyring returns the refreshed signing keys, or the bundled ones when they
+// were never refreshed
+func Keyring() (openpgp.EntityList, error) {
+	f, err := os.Open(KeyringFile)
+	if os.IsNotExist(err) {
+		return bundledKeyring()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the signing keys: %w", err)
+	}
+	defer f.Close()
+	return ReadKeyring(f)
+}
+
+// Verify checks sig is a signature of signed by one of keys, armored or not,
+// and returns the key which made it
+func Verify(keys openpgp.EntityList, signed, sig []byte) (*openpgp.Entity, error) {
+	var signer *openpgp.Entity
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN")) {
+		signer, err = openpgp.CheckArmoredDetachedSignature(keys, bytes.NewReader(signed), bytes.NewReader(sig))
+	} else {
+		signer, err = openpgp.CheckDetachedSignature(keys, bytes.NewReader(signed), bytes.NewReader(sig))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	return signer, nil
+}
+
+// SerializeKeyring returns keys as a binary keyring, the format of the
+// --keyring of gpgv
+func SerializeKeyring(keys openpgp.EntityList) ([]byte, error) {
+	var b bytes.Buffer
+	for _, key := range keys {
+		if err := key.Serialize(&b); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// SignatureURL returns the URL of the detached signature of the file at
+// fileURL, which has the .asc extension
+func SignatureURL(fileURL string) string {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return fileURL + ".asc"
+	}
+	u.Path += ".asc"
+	return u.String()
+}
+
+// Fingerprint returns the fingerprint of key as GPG prints it
+func Fingerprint(key *openpgp.Entity) string {
+	return strings.ToUpper(fmt.Sprintf("%x", key.PrimaryKey.Fingerprint))
+}
+
+// Identity returns the name of one of the identities of key
+func Identity(key *openpgp.Entity) string {
+	for name := range key.Identities {
+		return name
+	}
+	return ""
+}
+
+func download(client *http.Client, u string) ([]byte, error) {
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download %s, code: %d", u, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// ErrUntrustedKeys is returned when keys are refreshed while none are bundled
+// or refreshed to verify them with, and trustOnFirstUse isn't set
+var ErrUntrustedKeys = fmt.Errorf("no signing keys are known to verify the downloaded ones with, check where they come from and pass --trust-on-first-use to trust them")
+
+// Refresh downloads the signing keys published at keysURL and stores them in
+// KeyringFile. When keys are already bundled or refreshed, the new keys must
+// be signed by one of them, which is returned, so a rotation can't be forged.
+// Without keys, the downloaded ones are only trusted with trustOnFirstUse.
+func Refresh(client *http.Client, keysURL string, trustOnFirstUse bool) (openpgp.EntityList, *openpgp.Entity, error) {
+	if !strings.HasPrefix(keysURL, "https://") {
+		return nil, nil, fmt.Errorf("the signing keys are only refreshed over https, not from %s", keysURL)
+	}
+	armored, err := download(client, keysURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := ReadKeyring(bytes.NewReader(armored))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var signer *openpgp.Entity
+	current, err := Keyring()
+	switch {
+	case err == ErrNoKeys:
+		if !trustOnFirstUse {
+			return nil, nil, ErrUntrustedKeys
+		}
+	case err != nil:
+		return nil, nil, err
+	default:
+		sig, err := download(client, SignatureURL(keysURL))
+		if err != nil {
+			return nil, nil, err
+		}
+		if signer, err = Verify(current, armored, sig); err != nil {
+			return nil, nil, fmt.Errorf("the signing keys of %s aren't signed by the current keys: %w", keysURL, err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(KeyringFile), 0700); err != nil {
+		return nil, nil, fmt.Errorf("unable to store the signing keys: %w", err)
+	}
+	if err := statefile.WriteFile(KeyringFile, armored, 0644); err != nil {
+		return nil, nil, fmt.Errorf("unable to store the signing keys: %w", err)
+	}
+	return keys, signer, nil
+}
@@ -0,0 +1,140 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func newKey(t *testing.T, name string) *openpgp.Entity {
+	key, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func armoredKeys(t *testing.T, keys ...*openpgp.Entity) []byte {
+	var b bytes.Buffer
+	w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if err := key.Serialize(w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	return b.Bytes()
+}
+
+func sign(t *testing.T, key *openpgp.Entity, data []byte) []byte {
+	var b bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&b, key, bytes.NewReader(data), nil); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// useKeys bundles keys and points KeyringFile to a temporary directory for
+// the test
+func useKeys(t *testing.T, keys ...*openpgp.Entity) {
+	bundled, file := BundledKeys, KeyringFile
+	dir, err := ioutil.TempDir("", "signature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		BundledKeys, KeyringFile = bundled, file
+		os.RemoveAll(dir)
+	})
+	BundledKeys = ""
+	if len(keys) > 0 {
+		BundledKeys = base64.StdEncoding.EncodeToString(armoredKeys(t, keys...))
+	}
+	KeyringFile = filepath.Join(dir, "signing-keys.asc")
+}
+
+func TestVerify(t *testing.T) {
+	key, other := newKey(t, "release"), newKey(t, "other")
+	useKeys(t, key)
+
+	keys, err := Keyring()
+	assert.NoError(t, err)
+	installer := []byte("#!/bin/bash\necho installing\n")
+
+	signer, err := Verify(keys, installer, sign(t, key, installer))
+	assert.NoError(t, err)
+	assert.Equal(t, Fingerprint(key), Fingerprint(signer))
+
+	_, err = Verify(keys, []byte("#!/bin/bash\necho tampered\n"), sign(t, key, installer))
+	assert.Error(t, err)
+	_, err = Verify(keys, installer, sign(t, other, installer))
+	assert.Error(t, err)
+
+	useKeys(t)
+	_, err = Keyring()
+	assert.Equal(t, ErrNoKeys, err)
+}
+
+func TestSignatureURL(t *testing.T) {
+	assert.Equal(t, "https://du.platform9.net/clarity/platform9-install-debian.sh.asc", SignatureURL("https://du.platform9.net/clarity/platform9-install-debian.sh"))
+	assert.Equal(t, "https://du.platform9.net/clarity/platform9-install-debian.sh.asc?version=5.6.0", SignatureURL("https://du.platform9.net/clarity/platform9-install-debian.sh?version=5.6.0"))
+}
+
+func TestRefresh(t *testing.T) {
+	current, rotated, forged := newKey(t, "current"), newKey(t, "rotated"), newKey(t, "forged")
+	published := armoredKeys(t, rotated)
+	sig := sign(t, current, published)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/keys.asc":
+			w.Write(published)
+		case "/keys.asc.asc":
+			w.Write(sig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	useKeys(t, current)
+	keys, signer, err := Refresh(server.Client(), server.URL+"/keys.asc", false)
+	assert.NoError(t, err)
+	assert.Equal(t, Fingerprint(current), Fingerprint(signer))
+	assert.Equal(t, Fingerprint(rotated), Fingerprint(keys[0]))
+	keys, err = Keyring()
+	assert.NoError(t, err)
+	assert.Equal(t, Fingerprint(rotated), Fingerprint(keys[0]))
+
+	// Keys signed by an unknown key are refused
+	useKeys(t, current)
+	sig = sign(t, forged, published)
+	_, _, err = Refresh(server.Client(), server.URL+"/keys.asc", true)
+	assert.Error(t, err)
+	_, err = os.Stat(KeyringFile)
+	assert.True(t, os.IsNotExist(err))
+
+	// Without keys, the first ones are only trusted when asked
+	useKeys(t)
+	_, _, err = Refresh(server.Client(), server.URL+"/keys.asc", false)
+	assert.Equal(t, ErrUntrustedKeys, err)
+	_, err = os.Stat(KeyringFile)
+	assert.True(t, os.IsNotExist(err))
+	_, signer, err = Refresh(server.Client(), server.URL+"/keys.asc", true)
+	assert.NoError(t, err)
+	assert.Nil(t, signer)
+
+	_, _, err = Refresh(server.Client(), "http://example.com/keys.asc", true)
+	assert.EqualError(t, err, "the signing keys are only refreshed over https, not from http://example.com/keys.asc")
+}
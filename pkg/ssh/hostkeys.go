@@ -0,0 +1,157 @@
+// Copyright 2020 Platform9 Systems Inc.
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Policies verifying the host keys of the nodes
+const (
+	// HostKeyStrict only accepts the host keys of the known_hosts file or
+	// pinned for the host
+	HostKeyStrict = "strict"
+	// HostKeyTOFU trusts the host key of a node seen for the first time once
+	// confirmed, and adds it to the known_hosts file
+	HostKeyTOFU = "tofu"
+	// HostKeyInsecure accepts any host key
+	HostKeyInsecure = "insecure"
+)
+
+var (
+	// HostKeyPolicy is how the host keys of the nodes are verified
+	HostKeyPolicy = HostKeyTOFU
+	// KnownHostsFile is the known_hosts file the host keys are verified
+	// against and added to, in the format of OpenSSH
+	KnownHostsFile string
+	// HostFingerprints are the SHA256 or MD5 fingerprints the host key of a
+	// host, by IP or hostname, is pinned to instead of the known_hosts file
+	HostFingerprints map[string][]string
+	// ConfirmHostKey asks whether to trust the unknown host key of host with
+	// the tofu policy, the key is trusted without asking when it is nil
+	ConfirmHostKey func(host, fingerprint string) bool
+)
+
+// knownHostsMu serializes the updates of the known_hosts file and the
+// confirmations, as the executors of the nodes may dial concurrently
+var knownHostsMu sync.Mutex
+
+// ValidateHostKeyPolicy validates the host_key_policy setting
+func ValidateHostKeyPolicy(policy string) error {
+	switch policy {
+	case HostKeyStrict, HostKeyTOFU, HostKeyInsecure:
+		return nil
+	}
+	return fmt.Errorf("invalid host key policy %q, it is either %s, %s or %s", policy, HostKeyStrict, HostKeyTOFU, HostKeyInsecure)
+}
+
+// hostKeyCallback verifies the host key of host with HostKeyPolicy
+func hostKeyCallback(host string) ssh.HostKeyCallback {
+	if HostKeyPolicy == HostKeyInsecure {
+		return ssh.InsecureIgnoreHostKey()
+	}
+	return func(address string, remote net.Addr, key ssh.PublicKey) error {
+		return verifyHostKey(host, address, remote, key)
+	}
+}
+
+func verifyHostKey(host, address string, remote net.Addr, key ssh.PublicKey) error {
+	fingerprint := ssh.FingerprintSHA256(key)
+	if pinned, ok := HostFingerprints[host]; ok {
+		for _, fp := range pinned {
+			if fp == fingerprint || strings.EqualFold(fp, ssh.FingerprintLegacyMD5(key)) {
+				return nil
+			}
+		}
+		return fmt.Errorf("the %s host key of %s has the fingerprint %s, which isn't one of the fingerprints pinned for it", key.Type(), host, fingerprint)
+	}
+
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+	err := checkKnownHosts(address, remote, key)
+	var keyErr *knownhosts.KeyError
+	if err == nil || !errors.As(err, &keyErr) {
+		return err
+	}
+	if len(keyErr.Want) > 0 {
+		want := keyErr.Want[0]
+		return fmt.Errorf("the host key of %s has changed, its fingerprint %s doesn't match the key of %s:%d. The node may be impersonated, remove the line once the change is verified",
+			host, fingerprint, want.Filename, want.Line)
+	}
+	if HostKeyPolicy == HostKeyStrict {
+		return fmt.Errorf("the %s host key of %s with fingerprint %s isn't in %s, add it e.g: with ssh-keyscan or pin its fingerprint",
+			key.Type(), host, fingerprint, KnownHostsFile)
+	}
+	if ConfirmHostKey == nil {
+		zap.S().Warnf("Trusting the %s host key of %s with fingerprint %s, seen for the first time", key.Type(), host, fingerprint)
+	} else if !ConfirmHostKey(host, fingerprint) {
+		return fmt.Errorf("the host key of %s isn't trusted", host)
+	}
+	zap.S().Infof("Adding the %s host key of %s with fingerprint %s to %s", key.Type(), host, fingerprint, KnownHostsFile)
+	return addKnownHost(address, key)
+}
+
+// checkKnownHosts checks key against KnownHostsFile, a missing file knows no
+// host
+func checkKnownHosts(address string, remote net.Addr, key ssh.PublicKey) error {
+	if _, err := os.Stat(KnownHostsFile); os.IsNotExist(err) {
+		return &knownhosts.KeyError{}
+	}
+	callback, err := knownhosts.New(KnownHostsFile)
+	if err != nil {
+		return fmt.Errorf("unable to read the known hosts: %w", err)
+	}
+	return callback(address, remote, key)
+}
+
+// addKnownHost appends the key of address to KnownHostsFile
+func addKnownHost(address string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(KnownHostsFile), 0700); err != nil {
+		return fmt.Errorf("unable to create the directory of the known hosts: %w", err)
+	}
+	f, err := os.OpenFile(KnownHostsFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open the known hosts: %w", err)
+	}
+	defer f.Close()
+	line := knownhosts.Line([]string{knownhosts.Normalize(address)}, key)
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		return fmt.Errorf("unable to add the host key to the known hosts: %w", err)
+	}
+	return nil
+}
+
+// probeKey is matched against the known hosts to list the keys known for an
+// address, it matches none of them
+type probeKey struct{}
+
+func (probeKey) Type() string                        { return "pf9ctl-probe" }
+func (probeKey) Marshal() []byte                     { return []byte("pf9ctl-probe") }
+func (probeKey) Verify([]byte, *ssh.Signature) error { return errors.New("probe key") }
+
+// hostKeyAlgorithms returns the algorithms of the keys of KnownHostsFile for
+// address, so the node presents a key of the same type, nil when it has none
+func hostKeyAlgorithms(host, address string) []string {
+	if HostKeyPolicy == HostKeyInsecure || HostFingerprints[host] != nil {
+		return nil
+	}
+	// The known hosts match address rather than the remote address
+	var keyErr *knownhosts.KeyError
+	if !errors.As(checkKnownHosts(address, &net.TCPAddr{}, probeKey{}), &keyErr) {
+		return nil
+	}
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		algorithms = append(algorithms, known.Key.Type())
+	}
+	return algorithms
+}
@@ -0,0 +1,63 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func hostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	assert.Nil(t, err)
+	return key
+}
+
+func TestVerifyHostKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "known_hosts")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer func() {
+		HostKeyPolicy, KnownHostsFile, HostFingerprints, ConfirmHostKey = HostKeyTOFU, "", nil, nil
+	}()
+	KnownHostsFile = filepath.Join(dir, ".ssh", "known_hosts")
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+	key, other := hostKey(t), hostKey(t)
+
+	HostKeyPolicy = HostKeyStrict
+	assert.Contains(t, verifyHostKey("10.0.0.1", "10.0.0.1:22", remote, key).Error(), "isn't in "+KnownHostsFile)
+	assert.Nil(t, hostKeyAlgorithms("10.0.0.1", "10.0.0.1:22"))
+
+	HostKeyPolicy = HostKeyTOFU
+	ConfirmHostKey = func(host, fingerprint string) bool { return false }
+	assert.EqualError(t, verifyHostKey("10.0.0.1", "10.0.0.1:22", remote, key), "the host key of 10.0.0.1 isn't trusted")
+	ConfirmHostKey = func(host, fingerprint string) bool { return fingerprint == ssh.FingerprintSHA256(key) }
+	assert.Nil(t, verifyHostKey("10.0.0.1", "10.0.0.1:22", remote, key))
+
+	HostKeyPolicy = HostKeyStrict
+	assert.Nil(t, verifyHostKey("10.0.0.1", "10.0.0.1:22", remote, key))
+	assert.Equal(t, []string{ssh.KeyAlgoED25519}, hostKeyAlgorithms("10.0.0.1", "10.0.0.1:22"))
+	assert.Contains(t, verifyHostKey("10.0.0.1", "10.0.0.1:22", remote, other).Error(), "the host key of 10.0.0.1 has changed")
+
+	HostFingerprints = map[string][]string{"10.0.0.1": {ssh.FingerprintSHA256(other)}}
+	assert.Nil(t, verifyHostKey("10.0.0.1", "10.0.0.1:22", remote, other))
+	assert.Nil(t, hostKeyAlgorithms("10.0.0.1", "10.0.0.1:22"))
+	HostFingerprints = map[string][]string{"10.0.0.1": {ssh.FingerprintLegacyMD5(key)}}
+	assert.Nil(t, verifyHostKey("10.0.0.1", "10.0.0.1:22", remote, key))
+	assert.Contains(t, verifyHostKey("10.0.0.1", "10.0.0.1:22", remote, other).Error(), "isn't one of the fingerprints pinned for it")
+}
+
+func TestValidateHostKeyPolicy(t *testing.T) {
+	assert.Nil(t, ValidateHostKeyPolicy(HostKeyStrict))
+	assert.Nil(t, ValidateHostKeyPolicy(HostKeyTOFU))
+	assert.Nil(t, ValidateHostKeyPolicy(HostKeyInsecure))
+	assert.EqualError(t, ValidateHostKeyPolicy("yes"), `invalid host key policy "yes", it is either strict, tofu or insecure`)
+}
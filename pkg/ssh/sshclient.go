@@ -7,12 +7,19 @@ package ssh
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
+	"strconv"
 
 	"github.com/pkg/sftp"
+	"github.com/platform9/pf9ctl/pkg/fips"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/socks"
+	"github.com/platform9/pf9ctl/pkg/util"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/ssh"
 )
@@ -21,10 +28,16 @@ import (
 type Client interface {
 	// RunCommand executes the remote command returning the stdout, stderr and any error associated with it
 	RunCommand(cmd string) ([]byte, []byte, error)
+	// RunCommandStream executes the remote command copying its stdout to w as it is produced
+	RunCommandStream(cmd string, w io.Writer) error
 	// Uploadfile uploads the srcFile to remoteDestFilePath and changes the mode to the filemode
 	UploadFile(srcFilePath, remoteDstFilePath string, mode os.FileMode, cb func(read int64, total int64)) error
 	// Downloadfile downloads the remoteFile to localFile and changes the mode to the filemode
 	DownloadFile(remoteFile, localPath string, mode os.FileMode, cb func(read int64, total int64)) error
+	// ListenRemote listens on addr of the remote host, the connections made to it are forwarded over SSH
+	ListenRemote(addr string) (net.Listener, error)
+	// Close closes the connection to the remote host
+	Close() error
 }
 
 type client struct {
@@ -52,21 +65,38 @@ func NewClient(host string, port int, username string, privateKey []byte, passwo
 		if err != nil {
 			return nil, fmt.Errorf("error parsing private key: %s", err)
 		}
+		if err := fips.CheckSSHKey(signer); err != nil {
+			return nil, err
+		}
 		authMethods[0] = ssh.PublicKeys(signer)
 	} else {
+		log.RegisterSecret(password)
 		authMethods[0] = ssh.Password(password)
 	}
+	// IPv6 addresses are put in brackets
+	address := net.JoinHostPort(util.NormalizeIP(host), strconv.Itoa(port))
 	sshConfig := &ssh.ClientConfig{
 		User: string(username),
 		Auth: authMethods,
-		// by default ignore host key checks
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		// the host key is verified with HostKeyPolicy
+		HostKeyCallback:   hostKeyCallback(host),
+		HostKeyAlgorithms: hostKeyAlgorithms(host, address),
+	}
+	if err := fips.SSHConfig(sshConfig); err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %w", host, err)
 	}
 
-	sshClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host, port), sshConfig)
+	// The connection goes through the SOCKS proxy when one is set
+	conn, err := socks.DialTimeout("tcp", address, sshConfig.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial %s: %s", address, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, address, sshConfig)
 	if err != nil {
-		return nil, fmt.Errorf("unable to dial %s:%d: %s", host, port, err)
+		conn.Close()
+		return nil, fmt.Errorf("unable to dial %s: %s", address, err)
 	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
 	sftpClient, err := sftp.NewClient(sshClient)
 	return &client{
 		sshClient:  sshClient,
@@ -87,29 +117,18 @@ func (c *client) RunCommand(cmd string) ([]byte, []byte, error) {
 	if err != nil {
 		return nil, nil, fmt.Errorf("unable to pipe stdout: %s", err)
 	}
-	stdErrPipe, err := session.StderrPipe()
-	if err != nil {
-		return nil, nil, fmt.Errorf("unable to pipe stderr: %s", err)
-	}
-	// Prepend sudo if runAsSudo set to true
-	if runAsSudo {
-		// Prepend Sudo and add if Password is required to access Sudo
-		if SudoPassword != "" {
-			cmd = fmt.Sprintf("echo %s | sudo -S su ; sudo %s", SudoPassword, cmd)
-		} else {
-			cmd = fmt.Sprintf("sudo %s", cmd)
-		}
-	}
-	if c.proxyURL != "" {
-		cmd = fmt.Sprintf("https_proxy=%s %s", c.proxyURL, cmd)
+	var stdErrBuf bytes.Buffer
+	if session.Stderr, err = sudoStderr(session, &stdErrBuf); err != nil {
+		return nil, nil, fmt.Errorf("unable to pipe stdin: %s", err)
 	}
+	cmd = c.wrapCommand(cmd)
 	err = session.Start(cmd)
 	if err != nil {
 		return nil, nil, fmt.Errorf("unable to run command: %s", err)
 	}
 	stdOut, err := ioutil.ReadAll(stdOutPipe)
-	stdErr, err := ioutil.ReadAll(stdErrPipe)
 	err = session.Wait()
+	stdErr := stdErrBuf.Bytes()
 	if err != nil {
 		retError := err
 		switch err.(type) {
@@ -128,6 +147,53 @@ func (c *client) RunCommand(cmd string) ([]byte, []byte, error) {
 	return stdOut, stdErr, nil
 }
 
+// RunCommandStream runs a command on the machine copying its stdout to w
+// until the command exits, stderr is discarded
+func (c *client) RunCommandStream(cmd string, w io.Writer) error {
+	session, err := c.sshClient.NewSession()
+	if err != nil {
+		return fmt.Errorf("unable to create session: %s", err)
+	}
+	defer session.Close()
+	session.Stdout = w
+	if session.Stderr, err = sudoStderr(session, nil); err != nil {
+		return fmt.Errorf("unable to pipe stdin: %s", err)
+	}
+
+	cmd = c.wrapCommand(cmd)
+	if err := session.Run(cmd); err != nil {
+		return fmt.Errorf("command %s failed: %s", cmd, err)
+	}
+	return nil
+}
+
+// wrapCommand prepends sudo and the proxy settings to the command
+func (c *client) wrapCommand(cmd string) string {
+	// Prepend sudo if runAsSudo set to true
+	if runAsSudo {
+		// Prepend Sudo and add if Password is required to access Sudo
+		// The password is written to stdin by sudoStderr when sudo prompts for
+		// it, so it never shows up in the command line
+		if SudoPassword != "" {
+			cmd = fmt.Sprintf("%ssudo %s", sudoPrefix, cmd)
+		} else {
+			cmd = fmt.Sprintf("sudo %s", cmd)
+		}
+	}
+	if c.proxyURL != "" {
+		cmd = fmt.Sprintf("https_proxy=%s %s", c.proxyURL, cmd)
+	}
+	return cmd
+}
+
+// Close closes the SFTP session and the SSH connection
+func (c *client) Close() error {
+	if c.sftpClient != nil {
+		c.sftpClient.Close()
+	}
+	return c.sshClient.Close()
+}
+
 // Upload writes a file to the machine
 func (c *client) UploadFile(localFile string, remoteFilePath string, mode os.FileMode, cb func(read int64, total int64)) error {
 	// first check if the local file exists or not
@@ -150,6 +216,12 @@ func (c *client) UploadFile(localFile string, remoteFilePath string, mode os.Fil
 		return fmt.Errorf("unable to create file: %s", err)
 	}
 	defer remoteFile.Close()
+	// Change the mode before writing so files only readable by the owner never
+	// have their content exposed
+	err = remoteFile.Chmod(mode)
+	if err != nil {
+		return fmt.Errorf("chmod failed: %s", err)
+	}
 	// IMHO this function is misnomer, it actually writes to the remoteFile
 	_, err = remoteFile.ReadFrom(progressReader)
 	if err != nil {
@@ -158,10 +230,6 @@ func (c *client) UploadFile(localFile string, remoteFilePath string, mode os.Fil
 		c.sftpClient.Remove(remoteFilePath)
 		return fmt.Errorf("write failed: %s, ", err)
 	}
-	err = remoteFile.Chmod(mode)
-	if err != nil {
-		return fmt.Errorf("chmod failed: %s", err)
-	}
 	return nil
 }
 
@@ -200,6 +268,12 @@ func (c *client) DownloadFile(remoteFile string, localFilePath string, mode os.F
 	return nil
 }
 
+// ListenRemote asks the SSH server to listen on addr and forward the
+// connections it accepts, which needs AllowTcpForwarding in sshd
+func (c *client) ListenRemote(addr string) (net.Listener, error) {
+	return c.sshClient.Listen("tcp", addr)
+}
+
 func newProgressCBReader(totalSize int64, orig io.Reader, cb func(read int64, total int64)) io.Reader {
 	progReader := &ProgressCBReader{
 		TotalSize:  totalSize,
@@ -0,0 +1,83 @@
+package ssh
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"github.com/platform9/pf9ctl/pkg/log"
+	"golang.org/x/crypto/ssh"
+)
+
+// Markers printed on stderr by the commands run with a sudo password, the
+// prompt of sudo -S and the end of the validation of the password
+const (
+	sudoPromptMarker = "[pf9ctl-sudo-prompt]"
+	sudoDoneMarker   = "[pf9ctl-sudo-done]"
+)
+
+// sudoPrefix validates the sudo password before the command, prompting for
+// it with sudoPromptMarker only when sudo needs it
+const sudoPrefix = "sudo -S -p '" + sudoPromptMarker + "' -v ; echo '" + sudoDoneMarker + "' >&2 ; "
+
+// sudoPrompt is the stderr of a command validating the sudo password, which
+// writes the password to stdin when sudo prompts for it and closes stdin once
+// the password is validated. On the hosts where sudo needs no password it is
+// never written, so the command can't read it. The markers are removed from
+// what is written to out.
+type sudoPrompt struct {
+	stdin    io.WriteCloser
+	password string
+	out      io.Writer
+	buf      []byte
+	done     bool
+}
+
+func (p *sudoPrompt) Write(b []byte) (int, error) {
+	if p.done {
+		return p.out.Write(b)
+	}
+	p.buf = append(p.buf, b...)
+	for {
+		prompt := bytes.Index(p.buf, []byte(sudoPromptMarker))
+		done := bytes.Index(p.buf, []byte(sudoDoneMarker+"\n"))
+		switch {
+		case prompt >= 0 && (done < 0 || prompt < done):
+			p.out.Write(p.buf[:prompt])
+			p.buf = p.buf[prompt+len(sudoPromptMarker):]
+			io.WriteString(p.stdin, p.password+"\n")
+		case done >= 0:
+			p.out.Write(p.buf[:done])
+			rest := p.buf[done+len(sudoDoneMarker)+1:]
+			p.buf, p.done = nil, true
+			p.stdin.Close()
+			p.out.Write(rest)
+			return len(b), nil
+		default:
+			// The end of the output may be the start of a marker, the prompt
+			// marker is the longest
+			if keep := len(sudoPromptMarker); len(p.buf) > keep {
+				p.out.Write(p.buf[:len(p.buf)-keep])
+				p.buf = append([]byte(nil), p.buf[len(p.buf)-keep:]...)
+			}
+			return len(b), nil
+		}
+	}
+}
+
+// sudoStderr returns the stderr of session, which answers the sudo prompt of
+// the command with the password when there is one
+func sudoStderr(session *ssh.Session, stderr io.Writer) (io.Writer, error) {
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	if !runAsSudo || SudoPassword == "" {
+		return stderr, nil
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	log.RegisterSecret(SudoPassword)
+	return &sudoPrompt{stdin: stdin, password: SudoPassword, out: stderr}, nil
+}
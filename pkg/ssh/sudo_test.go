@@ -0,0 +1,65 @@
+package ssh
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingStdin struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (s *recordingStdin) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestSudoPrompt(t *testing.T) {
+	cases := map[string]struct {
+		stderr  []string
+		stdin   string
+		wantOut string
+		closed  bool
+	}{
+		"NoPasswordNeeded": {
+			stderr:  []string{sudoDoneMarker + "\n", "warning\n"},
+			wantOut: "warning\n",
+			closed:  true,
+		},
+		"Prompted": {
+			stderr:  []string{sudoPromptMarker, sudoDoneMarker + "\n"},
+			stdin:   "secret\n",
+			wantOut: "",
+			closed:  true,
+		},
+		"WrongPassword": {
+			stderr:  []string{sudoPromptMarker, "Sorry, try again.\n" + sudoPromptMarker},
+			stdin:   "secret\nsecret\n",
+			wantOut: "Sorry, try again.\n",
+		},
+		"SplitMarkers": {
+			stderr:  []string{"[pf9ctl-sudo-", "prompt][pf9ctl", "-sudo-done]", "\nerror\n"},
+			stdin:   "secret\n",
+			wantOut: "error\n",
+			closed:  true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var stdin recordingStdin
+			var out bytes.Buffer
+			p := &sudoPrompt{stdin: &stdin, password: "secret", out: &out}
+			for _, s := range tc.stderr {
+				n, err := p.Write([]byte(s))
+				assert.NoError(t, err)
+				assert.Equal(t, len(s), n)
+			}
+			assert.Equal(t, tc.stdin, stdin.String())
+			assert.Equal(t, tc.wantOut, out.String())
+			assert.Equal(t, tc.closed, stdin.closed)
+		})
+	}
+}
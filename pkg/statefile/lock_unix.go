@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package statefile
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLock takes the lock of f without waiting, false when another process
+// holds it
+func tryLock(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlock(f *os.File) {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
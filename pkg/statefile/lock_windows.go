@@ -0,0 +1,30 @@
+//go:build windows
+// +build windows
+
+package statefile
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockedRange is the byte of the lock file which is locked, far from the
+// PID so the other processes can read it
+var lockedRange = windows.Overlapped{OffsetHigh: 1}
+
+// tryLock takes the lock of f without waiting, false when another process
+// holds it
+func tryLock(f *os.File) (bool, error) {
+	r := lockedRange
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &r)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlock(f *os.File) {
+	r := lockedRange
+	windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &r)
+}
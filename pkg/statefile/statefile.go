@@ -0,0 +1,158 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+
+// Package statefile updates the config and the state files of pf9ctl safely
+// when several pf9ctl run at once, like the parallel jobs of a CI. The files
+// are written aside and renamed in place, so they are never read half
+// written, and the commands reading a file then writing it back hold an
+// advisory lock on it.
+package statefile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pollInterval is the delay between the attempts to take a lock held by
+// another pf9ctl
+var pollInterval = 50 * time.Millisecond
+
+// LockedError is returned when the lock of a file is held by another pf9ctl
+type LockedError struct {
+	Path string
+	// PID is the process holding the lock, 0 when unknown
+	PID int
+}
+
+func (e *LockedError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("another pf9ctl is running (pid %d) and is using %s, try again once it is done", e.PID, e.Path)
+	}
+	return fmt.Sprintf("another pf9ctl is running and is using %s, try again once it is done", e.Path)
+}
+
+// held are the locks held by this process by path. A process takes a lock
+// it already holds again, so a command holding the lock of the config can
+// store it.
+var held = struct {
+	sync.Mutex
+	locks map[string]*heldLock
+}{locks: map[string]*heldLock{}}
+
+type heldLock struct {
+	f     *os.File
+	count int
+}
+
+// Lock is the advisory lock of a file, in the file with the .lock extension
+// next to it
+type Lock struct {
+	path string
+	once sync.Once
+}
+
+// LockFile takes the lock of path, waiting for the other pf9ctl holding it
+// for up to wait. It fails with a *LockedError when they still hold it.
+func LockFile(path string, wait time.Duration) (*Lock, error) {
+	held.Lock()
+	defer held.Unlock()
+	if h, ok := held.locks[path]; ok {
+		h.count++
+		return &Lock{path: path}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock %s: %w", path, err)
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("unable to lock %s: %w", path, err)
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, &LockedError{Path: path, PID: lockOwner(path)}
+		}
+		time.Sleep(pollInterval)
+	}
+
+	// The PID in the lock file tells the other pf9ctl who holds it
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	held.locks[path] = &heldLock{f: f, count: 1}
+	return &Lock{path: path}, nil
+}
+
+// lockOwner returns the PID of the process holding the lock of path, 0 when
+// unknown
+func lockOwner(path string) int {
+	data, err := ioutil.ReadFile(path + ".lock")
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// Release releases the lock, once the process released it as many times as
+// it took it. It is safe to call it more than once, so it can be deferred.
+func (l *Lock) Release() {
+	l.once.Do(func() {
+		held.Lock()
+		defer held.Unlock()
+		h, ok := held.locks[l.path]
+		if !ok {
+			return
+		}
+		if h.count--; h.count > 0 {
+			return
+		}
+		delete(held.locks, l.path)
+		// The lock file is kept, removing it would let another pf9ctl lock
+		// a file which is about to be gone
+		h.f.Truncate(0)
+		unlock(h.f)
+		h.f.Close()
+	})
+}
+
+// WriteFile writes data to path like ioutil.WriteFile, in a temporary file
+// renamed in place so path is never left half written
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
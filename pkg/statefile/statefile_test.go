@@ -0,0 +1,90 @@
+package statefile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statefile")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+
+	// The process takes the locks it holds again
+	lock, err := LockFile(path, 0)
+	assert.NoError(t, err)
+	again, err := LockFile(path, 0)
+	assert.NoError(t, err)
+	again.Release()
+	again.Release()
+	assert.True(t, lockedByOther(t, path))
+	lock.Release()
+	assert.False(t, lockedByOther(t, path))
+}
+
+func TestLockFileHeld(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statefile")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	// Another pf9ctl holds the lock
+	other, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	assert.NoError(t, err)
+	defer other.Close()
+	locked, err := tryLock(other)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+	other.WriteString("4242\n")
+
+	_, err = LockFile(path, 2*pollInterval)
+	var lockedErr *LockedError
+	if assert.True(t, errors.As(err, &lockedErr)) {
+		assert.Equal(t, 4242, lockedErr.PID)
+	}
+	assert.EqualError(t, err, "another pf9ctl is running (pid 4242) and is using "+path+", try again once it is done")
+
+	unlock(other)
+	lock, err := LockFile(path, 0)
+	assert.NoError(t, err)
+	lock.Release()
+}
+
+// lockedByOther is true when another process can't take the lock of path
+func lockedByOther(t *testing.T, path string) bool {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR, 0600)
+	assert.NoError(t, err)
+	defer f.Close()
+	locked, err := tryLock(f)
+	assert.NoError(t, err)
+	if locked {
+		unlock(f)
+	}
+	return !locked
+}
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statefile")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "job.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("old"), 0644))
+
+	assert.NoError(t, WriteFile(path, []byte("new"), 0600))
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The temporary file is gone
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(files))
+}
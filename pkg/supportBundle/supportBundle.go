@@ -1,6 +1,7 @@
 package supportBundle
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -43,12 +44,12 @@ var (
 	Timestamp = time.Now()
 )
 
-func HostOS(exec cmdexec.Executor) {
+func HostOS(exec cmdexec.Executor) error {
 	hostOS, err = pmk.ValidatePlatform(exec)
 	if err != nil {
-		zap.S().Fatalf("OS version is not supported")
+		return fmt.Errorf("OS version is not supported: %w", err)
 	}
-
+	return nil
 }
 
 // To get the Host IP address
@@ -59,16 +60,21 @@ func HostIP(exec cmdexec.Executor) (string, error) {
 		zap.S().Error("Host IP Not found", err)
 		return host, ErrHostIP
 	}
-	// If the host has multiple IPs
-	host = strings.Split(host, " ")[0]
-	return host, nil
+	// If the host has multiple IPs, IPv4 or IPv6
+	ips := strings.Fields(host)
+	if len(ips) == 0 {
+		return "", ErrHostIP
+	}
+	return ips[0], nil
 }
 
 // To upload pf9ctl log bundle to S3 bucket
 func SupportBundleUpload(ctx objects.Config, allClients client.Client, isRemote bool) error {
 
 	zap.S().Debugf("Received a call to upload pf9ctl supportBundle to %s bucket.\n", S3_BUCKET_NAME)
-	HostOS(allClients.Executor)
+	if err := HostOS(allClients.Executor); err != nil {
+		return err
+	}
 	fileloc, err = GenSupportBundle(allClients.Executor, Timestamp, isRemote)
 	if err != nil && err != ErrPartialBundle {
 		if isRemote {
@@ -89,12 +95,7 @@ func SupportBundleUpload(ctx objects.Config, allClients client.Client, isRemote
 
 	// Fetch the keystone token.
 	// This is used as a reference to the segment event.
-	auth, err := allClients.Keystone.GetAuth(
-		ctx.Username,
-		ctx.Password,
-		ctx.Tenant,
-		ctx.MfaToken,
-	)
+	auth, err := keystone.Authenticate(allClients.Keystone, ctx)
 	if err != nil {
 		zap.S().Debug("Unable to locate keystone credentials: %s\n", err.Error())
 		return fmt.Errorf("Unable to locate keystone credentials: %s\n", err.Error())
@@ -102,6 +103,10 @@ func SupportBundleUpload(ctx objects.Config, allClients client.Client, isRemote
 
 	// To Fetch FQDN
 	FQDN, err := keystone.FetchRegionFQDN(ctx.Fqdn, ctx.Region, auth)
+	var regionNotFound *keystone.RegionNotFoundError
+	if errors.As(err, &regionNotFound) {
+		FQDN, err = "", nil
+	}
 	if err != nil {
 		zap.S().Debug("unable to fetch fqdn: %w")
 		return fmt.Errorf("unable to fetch fqdn: %w", err)
@@ -120,14 +125,16 @@ func SupportBundleUpload(ctx objects.Config, allClients client.Client, isRemote
 	if errUpload != nil {
 		zap.S().Debugf("Failed to upload pf9ctl supportBundle to %s bucket!! ", S3_BUCKET_NAME, errUpload)
 
-		if err := allClients.Segment.SendEvent("supportBundle upload Failed", auth, "Failed", ""); err != nil {
+		e := client.Event{Name: "Support bundle", Phase: "upload", Status: util.CheckFail, Err: errUpload}
+		if err := allClients.Segment.Track(e, auth); err != nil {
 			zap.S().Debugf("Unable to send Segment event for supportBundle. Error: %s", err.Error())
 		}
 
 	} else {
 		zap.S().Debugf("Succesfully uploaded pf9ctl supportBundle to %s bucket at %s location \n",
 			S3_BUCKET_NAME, S3_Location)
-		if err := allClients.Segment.SendEvent("supportBundle upload Success", auth, "Success", ""); err != nil {
+		e := client.Event{Name: "Support bundle", Phase: "upload", Status: util.CheckPass}
+		if err := allClients.Segment.Track(e, auth); err != nil {
 			zap.S().Debugf("Unable to send Segment event for supportBundle. Error: %s", err.Error())
 		}
 	}
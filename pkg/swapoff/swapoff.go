@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/util"
 	"go.uber.org/zap"
 )
 
@@ -11,12 +12,17 @@ import (
 func SetupNode(exec cmdexec.Executor) (err error) {
 	zap.S().Debug("Received a call to setup the node")
 
-	if err := swapOff(exec); err != nil {
-		return err
+	swapErr := swapOff(exec)
+	if swapErr != nil && !util.AllowReboot {
+		return swapErr
 	}
 	if err := swapOffFstab(exec, "/etc/fstab"); err != nil {
 		return fmt.Errorf("Unable to edit file /etc/fstab")
 	}
+	if swapErr != nil {
+		// The swap still in use is gone once prep-node reboots the node
+		zap.S().Debugf("Unable to disable swap, it is disabled by the reboot: %s", swapErr)
+	}
 	return nil
 }
 
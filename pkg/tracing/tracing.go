@@ -0,0 +1,324 @@
+// Package tracing records the phases of the operations on the nodes as
+// OpenTelemetry spans, exported in the JSON encoding of OTLP to a collector
+// or a local file once the command is done.
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/util"
+)
+
+// Where the spans are exported, tracing is off when neither is set nor the
+// endpoint of the OpenTelemetry environment variables
+var (
+	// Endpoint is the OTLP/HTTP endpoint of a collector, as
+	// http://localhost:4318, the spans are posted to its /v1/traces
+	Endpoint string
+	// File is the file the spans are appended to, a line of OTLP JSON per
+	// command
+	File string
+)
+
+// Environment variables of the OpenTelemetry SDKs setting the endpoint, the
+// traces one is used as it is
+const (
+	EndpointEnv       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	TracesEndpointEnv = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+)
+
+// exportTimeout bounds the post of the spans to the collector
+const exportTimeout = 10 * time.Second
+
+// Status codes of the spans in OTLP
+const (
+	statusOK    = 1
+	statusError = 2
+)
+
+// Span is a timed phase of an operation. The methods of a nil Span do
+// nothing, which is what Start returns when tracing is off.
+type Span struct {
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    map[string]string
+	err      error
+}
+
+var (
+	mu sync.Mutex
+	// spans are the spans started by the command, in order
+	spans   []*Span
+	traceID string
+)
+
+// Enabled is true when the spans are exported
+func Enabled() bool {
+	return File != "" || endpointURL() != ""
+}
+
+// endpointURL returns the URL the spans are posted to
+func endpointURL() string {
+	if Endpoint != "" {
+		return strings.TrimSuffix(Endpoint, "/") + "/v1/traces"
+	}
+	if endpoint := os.Getenv(TracesEndpointEnv); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv(EndpointEnv); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// randomID returns n random bytes in hex
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// commandTraceID is the trace of the command, the correlation ID when it is
+// a UUID so the trace is found from the logs
+func commandTraceID() string {
+	if traceID == "" {
+		traceID = strings.ReplaceAll(log.CorrelationID, "-", "")
+		if _, err := hex.DecodeString(traceID); err != nil || len(traceID) != 32 {
+			traceID = randomID(16)
+		}
+	}
+	return traceID
+}
+
+// Start starts a span of the command with attrs, given as key and value
+// pairs. It returns nil when tracing is off.
+func Start(name string, attrs ...string) *Span {
+	return start(name, "", attrs)
+}
+
+func start(name, parentID string, attrs []string) *Span {
+	if !Enabled() {
+		return nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s := &Span{
+		traceID:  commandTraceID(),
+		spanID:   randomID(8),
+		parentID: parentID,
+		name:     name,
+		start:    time.Now(),
+		attrs:    make(map[string]string),
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.attrs[attrs[i]] = attrs[i+1]
+	}
+	spans = append(spans, s)
+	return s
+}
+
+// Child starts a span of a phase of s
+func (s *Span) Child(name string, attrs ...string) *Span {
+	if s == nil {
+		return nil
+	}
+	return start(name, s.spanID, attrs)
+}
+
+// SetAttr sets the attribute key of s
+func (s *Span) SetAttr(key, value string) {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	s.attrs[key] = value
+}
+
+// End ends s, failed with err when it isn't nil. Only the first end counts.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !s.end.IsZero() {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+}
+
+// Flush exports the spans started by the command, ending those still running
+// as failed with reason when it is set. The spans are dropped once exported.
+func Flush(reason string) error {
+	mu.Lock()
+	pending := spans
+	spans = nil
+	mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for _, s := range pending {
+		if s.end.IsZero() {
+			s.end = now
+			if reason != "" {
+				s.err = fmt.Errorf("%s", reason)
+			}
+		}
+	}
+	body, err := json.Marshal(export(pending))
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	if File != "" {
+		if err := appendFile(File, body); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if url := endpointURL(); url != "" {
+		if err := post(url, body); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to export the traces: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func appendFile(path string, body []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(body, '\n'))
+	return err
+}
+
+func post(url string, body []byte) error {
+	client := http.Client{Timeout: exportTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return nil
+}
+
+// The types of the JSON encoding of OTLP, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []keyValue `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []spanJSON `json:"spans"`
+	}
+	scope struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	spanJSON struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Status            status     `json:"status"`
+	}
+	keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+	anyValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// spanKindInternal is the kind of the spans, they aren't requests
+const spanKindInternal = 1
+
+func keyValues(attrs map[string]string) []keyValue {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var kvs []keyValue
+	for _, key := range keys {
+		kvs = append(kvs, keyValue{key, anyValue{attrs[key]}})
+	}
+	return kvs
+}
+
+// export returns the OTLP request of spans
+func export(spans []*Span) exportRequest {
+	var out []spanJSON
+	for _, s := range spans {
+		st := status{Code: statusOK}
+		if s.err != nil {
+			st = status{Code: statusError, Message: s.err.Error()}
+		}
+		out = append(out, spanJSON{
+			TraceID:           s.traceID,
+			SpanID:            s.spanID,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        keyValues(s.attrs),
+			Status:            st,
+		})
+	}
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource: resource{Attributes: keyValues(map[string]string{
+			"service.name":    "pf9ctl",
+			"service.version": util.CLIVersion,
+		})},
+		ScopeSpans: []scopeSpans{{
+			Scope: scope{Name: "github.com/platform9/pf9ctl", Version: util.CLIVersion},
+			Spans: out,
+		}},
+	}}}
+}
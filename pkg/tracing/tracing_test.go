@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisabled(t *testing.T) {
+	os.Unsetenv(EndpointEnv)
+	os.Unsetenv(TracesEndpointEnv)
+	span := Start("prep-node")
+	assert.Nil(t, span)
+	// The methods of the nil span do nothing
+	span.Child("install-hostagent").End(nil)
+	span.SetAttr("host.name", "10.0.0.1")
+	span.End(nil)
+	assert.NoError(t, Flush(""))
+}
+
+func TestFlushFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	File = filepath.Join(dir, "traces.json")
+	defer func() { File = "" }()
+
+	span := Start("prep-node", "host.name", "10.0.0.1")
+	span.Child("validate-os").End(nil)
+	span.Child("install-hostagent").End(errors.New("exit status 1"))
+	running := span.Child("authorise-host")
+	span.End(errors.New("Error: Unable to install hostagent"))
+	assert.NoError(t, Flush("interrupted"))
+
+	data, err := ioutil.ReadFile(File)
+	assert.NoError(t, err)
+	var req exportRequest
+	assert.NoError(t, json.Unmarshal(data, &req))
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Len(t, spans, 4)
+
+	assert.Equal(t, "prep-node", spans[0].Name)
+	assert.Empty(t, spans[0].ParentSpanID)
+	assert.Equal(t, []keyValue{{"host.name", anyValue{"10.0.0.1"}}}, spans[0].Attributes)
+	assert.Equal(t, status{statusError, "Error: Unable to install hostagent"}, spans[0].Status)
+	for _, s := range spans[1:] {
+		assert.Equal(t, spans[0].SpanID, s.ParentSpanID)
+		assert.Equal(t, spans[0].TraceID, s.TraceID)
+		assert.Len(t, s.TraceID, 32)
+		assert.Len(t, s.SpanID, 16)
+	}
+	assert.Equal(t, status{Code: statusOK}, spans[1].Status)
+	assert.Equal(t, status{statusError, "exit status 1"}, spans[2].Status)
+	// The span still running is ended with the reason of the flush
+	assert.Equal(t, status{statusError, "interrupted"}, spans[3].Status)
+
+	running.End(nil)
+	assert.NoError(t, Flush(""))
+	data2, _ := ioutil.ReadFile(File)
+	assert.Equal(t, data, data2, "the spans are exported once")
+}
+
+func TestFlushEndpoint(t *testing.T) {
+	var received exportRequest
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	os.Setenv(EndpointEnv, server.URL+"/")
+	defer os.Unsetenv(EndpointEnv)
+	assert.True(t, Enabled())
+
+	Start("attach-node", "cluster.name", "c1").End(nil)
+	assert.NoError(t, Flush(""))
+	assert.Equal(t, "/v1/traces", path)
+	assert.Equal(t, "attach-node", received.ResourceSpans[0].ScopeSpans[0].Spans[0].Name)
+	assert.Contains(t, received.ResourceSpans[0].Resource.Attributes, keyValue{"service.name", anyValue{"pf9ctl"}})
+
+	Endpoint = server.URL
+	defer func() { Endpoint = "" }()
+	Start("detach-node").End(nil)
+	assert.NoError(t, Flush(""))
+	assert.Equal(t, "/v1/traces", path)
+}
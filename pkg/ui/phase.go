@@ -0,0 +1,186 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+
+// Package ui reports the progress of long running commands in a consistent way.
+// A command is split into phases, each shown with a spinner while it runs and
+// finished with a success or failure line. Phases can report nested sub-steps.
+package ui
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/fatih/color"
+	pf9color "github.com/platform9/pf9ctl/pkg/color"
+)
+
+// Plain disables the spinner animation, phases are printed as plain lines
+// which is better suited for logs and non interactive terminals.
+var Plain bool
+
+// Output is where the progress is written to
+var Output io.Writer = color.Output
+
+// hostPrefix prefixes the lines of the phases started, see ScopeHost
+var hostPrefix string
+
+// ScopeHost prefixes the lines of the phases started until restore is called
+// with host, so the phases of the nodes of a batch can be told apart
+func ScopeHost(host string) (restore func()) {
+	previous := hostPrefix
+	hostPrefix = fmt.Sprintf("[%s] ", host)
+	return func() { hostPrefix = previous }
+}
+
+// running are the phases not finished yet, stopped by StopAll
+var running = struct {
+	sync.Mutex
+	phases map[*Phase]bool
+}{phases: map[*Phase]bool{}}
+
+// Phase is a single step of a command shown to the user. Its lines can be
+// printed from other goroutines, as the warnings of the slow phases are.
+type Phase struct {
+	mu      sync.Mutex
+	spinner *spinner.Spinner
+	indent  string
+	message string
+	done    bool
+	// prefix is the host the phase is for, prefixing its lines
+	prefix string
+}
+
+// StartPhase starts a new top level phase showing message while it runs.
+func StartPhase(message string) *Phase {
+	return startPhase("", message)
+}
+
+// StartSubPhase starts a phase nested under p. The spinner of p is paused
+// until the sub phase is finished.
+func (p *Phase) StartSubPhase(message string) *Phase {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pause()
+	return startPhase(p.indent+"  ", message)
+}
+
+func startPhase(indent, message string) *Phase {
+	p := &Phase{indent: indent, message: message, prefix: hostPrefix}
+	running.Lock()
+	running.phases[p] = true
+	running.Unlock()
+	if Plain {
+		fmt.Fprintf(Output, "%s%s%s...\n", p.prefix, indent, message)
+		return p
+	}
+	p.spinner = spinner.New(spinner.CharSets[9], 100*time.Millisecond, spinner.WithWriter(Output))
+	p.spinner.Color("red")
+	p.spinner.Prefix = p.prefix + indent
+	p.spinner.Suffix = " " + message
+	p.spinner.Start()
+	return p
+}
+
+// Update changes the message shown while the phase is running.
+func (p *Phase) Update(message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.message = message
+	if Plain {
+		fmt.Fprintf(Output, "%s%s%s...\n", p.prefix, p.indent, message)
+		return
+	}
+	p.spinner.Lock()
+	p.spinner.Suffix = " " + message
+	p.spinner.Unlock()
+}
+
+// Step reports a finished sub-step of the phase, the phase keeps running.
+func (p *Phase) Step(message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pause()
+	fmt.Fprintf(Output, "%s%s  %s%s\n", p.prefix, p.indent, pf9color.Green("✓ "), message)
+	p.resume()
+}
+
+// Warn reports a problem that does not stop the phase.
+func (p *Phase) Warn(message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pause()
+	fmt.Fprintf(Output, "%s%s  %s%s\n", p.prefix, p.indent, pf9color.Yellow("! "), message)
+	p.resume()
+}
+
+// Succeed finishes the phase printing message as successful.
+func (p *Phase) Succeed(message string) {
+	p.finish(pf9color.Green("✓ "), message)
+}
+
+// Fail finishes the phase printing message as failed.
+func (p *Phase) Fail(message string) {
+	p.finish(pf9color.Red("x "), message)
+}
+
+// Stop finishes the phase without printing anything. It is safe to call it
+// on an already finished phase, so it can be deferred.
+func (p *Phase) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+}
+
+func (p *Phase) stop() {
+	p.pause()
+	p.done = true
+	running.Lock()
+	delete(running.phases, p)
+	running.Unlock()
+}
+
+// StopAll stops the spinners of the running phases, so the terminal is
+// clean when the command is interrupted.
+func StopAll() {
+	running.Lock()
+	phases := make([]*Phase, 0, len(running.phases))
+	for p := range running.phases {
+		phases = append(phases, p)
+	}
+	running.Unlock()
+	for _, p := range phases {
+		p.Stop()
+	}
+}
+
+// Resume restarts the spinner of a phase paused by a sub phase.
+func (p *Phase) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resume()
+}
+
+func (p *Phase) resume() {
+	if p.done || Plain || p.spinner.Active() {
+		return
+	}
+	p.spinner.Start()
+}
+
+func (p *Phase) pause() {
+	if p.spinner != nil && p.spinner.Active() {
+		p.spinner.Stop()
+	}
+}
+
+func (p *Phase) finish(mark, message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.done {
+		return
+	}
+	p.stop()
+	fmt.Fprintf(Output, "%s%s%s%s\n", p.prefix, p.indent, mark, message)
+}
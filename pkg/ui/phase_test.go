@@ -0,0 +1,81 @@
+package ui
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlainPhase(t *testing.T) {
+	color.NoColor = true
+	Plain = true
+	defer func() { Plain = false }()
+
+	cases := map[string]struct {
+		run  func()
+		want string
+	}{
+		"Succeed": {
+			run: func() {
+				p := StartPhase("Installing hostagent")
+				p.Update("Downloading installer")
+				p.Step("Downloaded installer")
+				p.Succeed("Hostagent installed")
+			},
+			want: "Installing hostagent...\nDownloading installer...\n  ✓ Downloaded installer\n✓ Hostagent installed\n",
+		},
+		//A phase is finished only once, a deferred Stop or a second Fail is ignored.
+		"FinishedOnce": {
+			run: func() {
+				p := StartPhase("Authorising host")
+				defer p.Stop()
+				p.Fail("Unable to authorise host")
+				p.Fail("Unable to authorise host")
+			},
+			want: "Authorising host...\nx Unable to authorise host\n",
+		},
+		"SubPhase": {
+			run: func() {
+				p := StartPhase("Decommissioning cluster")
+				sub := p.StartSubPhase("Decommissioning node")
+				sub.Warn("Node is not responding")
+				sub.Succeed("Node decommissioned")
+				p.Resume()
+				p.Succeed("Cluster decommissioned")
+			},
+			want: "Decommissioning cluster...\n  Decommissioning node...\n    ! Node is not responding\n  ✓ Node decommissioned\n✓ Cluster decommissioned\n",
+		},
+		//The phases stopped when the command is interrupted don't print anymore.
+		"StopAll": {
+			run: func() {
+				p := StartPhase("Installing hostagent")
+				StopAll()
+				p.Succeed("Hostagent installed")
+			},
+			want: "Installing hostagent...\n",
+		},
+		//The phases of a node of a batch are prefixed with it.
+		"ScopeHost": {
+			run: func() {
+				restore := ScopeHost("10.0.0.1")
+				p := StartPhase("Installing hostagent")
+				restore()
+				p.Step("Downloaded installer")
+				p.Succeed("Hostagent installed")
+				StartPhase("Installing hostagent").Stop()
+			},
+			want: "[10.0.0.1] Installing hostagent...\n[10.0.0.1]   ✓ Downloaded installer\n[10.0.0.1] ✓ Hostagent installed\nInstalling hostagent...\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			Output = &out
+			tc.run()
+			assert.Equal(t, tc.want, out.String())
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/briandowns/spinner"
+	pf9color "github.com/platform9/pf9ctl/pkg/color"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// SummaryInterval is how often the progress of many hosts is summarized when
+// the output can't be redrawn
+var SummaryInterval = 10 * time.Second
+
+// refreshInterval is how often the per host lines are redrawn on a terminal
+var refreshInterval = 100 * time.Millisecond
+
+// Interactive reports whether Output is a terminal the progress can be
+// redrawn on
+var Interactive = func() bool {
+	f, ok := Output.(*os.File)
+	return ok && terminal.IsTerminal(int(f.Fd()))
+}
+
+// Progress shows the phase of every host of a command operating on many
+// hosts concurrently. On a terminal each host gets its own line which is
+// redrawn as its phase changes, otherwise a summary is printed periodically
+// along with a line for every host as it finishes. Errors are left to the
+// caller to print, as they can be long or hold sensitive output.
+type Progress struct {
+	mu       sync.Mutex
+	title    string
+	hosts    []string
+	states   map[string]*hostState
+	redraw   bool
+	lines    int
+	frame    int
+	stopping chan struct{}
+	stopped  chan struct{}
+}
+
+type hostState struct {
+	phase    string
+	finished bool
+	err      error
+}
+
+// StartProgress starts showing the progress of hosts under title, every host
+// is waiting until its phase is set.
+func StartProgress(title string, hosts []string) *Progress {
+	p := &Progress{
+		title:    title,
+		hosts:    hosts,
+		states:   make(map[string]*hostState),
+		redraw:   !Plain && Interactive(),
+		stopping: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	for _, host := range hosts {
+		p.states[host] = &hostState{phase: "waiting"}
+	}
+
+	fmt.Fprintf(Output, "%s...\n", title)
+	interval := SummaryInterval
+	if p.redraw {
+		interval = refreshInterval
+	}
+	go p.run(interval)
+	return p
+}
+
+// Set changes the phase shown for host.
+func (p *Progress) Set(host, phase string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if state, ok := p.states[host]; ok && !state.finished {
+		state.phase = phase
+	}
+}
+
+// Finish marks host as done, failed when err is set.
+func (p *Progress) Finish(host string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state, ok := p.states[host]
+	if !ok || state.finished {
+		return
+	}
+	state.finished = true
+	state.err = err
+	if !p.redraw {
+		fmt.Fprintf(Output, "  %s\n", p.hostLine(host))
+	}
+}
+
+// Stop stops showing the progress and prints its final state. It is safe to
+// call it more than once.
+func (p *Progress) Stop() {
+	select {
+	case <-p.stopping:
+		return
+	default:
+		close(p.stopping)
+	}
+	<-p.stopped
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.redraw {
+		p.render()
+		return
+	}
+	fmt.Fprintf(Output, "  %s\n", p.summary())
+}
+
+func (p *Progress) run(interval time.Duration) {
+	defer close(p.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stopping:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			if p.redraw {
+				p.frame++
+				p.render()
+			} else {
+				fmt.Fprintf(Output, "  %s\n", p.summary())
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
+// render redraws the line of every host over the previous ones
+func (p *Progress) render() {
+	var b strings.Builder
+	if p.lines > 0 {
+		fmt.Fprintf(&b, "\033[%dA", p.lines)
+	}
+	for _, host := range p.hosts {
+		fmt.Fprintf(&b, "\r\033[2K  %s\n", p.hostLine(host))
+	}
+	p.lines = len(p.hosts)
+	fmt.Fprint(Output, b.String())
+}
+
+func (p *Progress) hostLine(host string) string {
+	state := p.states[host]
+	switch {
+	case state.finished && state.err != nil:
+		return fmt.Sprintf("%s%s: failed", pf9color.Red("x "), host)
+	case state.finished:
+		return fmt.Sprintf("%s%s", pf9color.Green("✓ "), host)
+	}
+	frames := spinner.CharSets[9]
+	return fmt.Sprintf("%s %s: %s", frames[p.frame%len(frames)], host, state.phase)
+}
+
+// summary counts the hosts by phase, e.g. "1/4 done, 1 failed, 2 running"
+func (p *Progress) summary() string {
+	finished, failed := 0, 0
+	var phases []string
+	counts := make(map[string]int)
+	for _, host := range p.hosts {
+		state := p.states[host]
+		if state.finished {
+			finished++
+			if state.err != nil {
+				failed++
+			}
+			continue
+		}
+		if counts[state.phase] == 0 {
+			phases = append(phases, state.phase)
+		}
+		counts[state.phase]++
+	}
+
+	parts := []string{fmt.Sprintf("%d/%d done", finished, len(p.hosts))}
+	if failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", failed))
+	}
+	for _, phase := range phases {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[phase], phase))
+	}
+	return strings.Join(parts, ", ")
+}
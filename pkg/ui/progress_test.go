@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	color.NoColor = true
+	interactive := Interactive
+	SummaryInterval = time.Hour
+	refreshInterval = time.Hour
+	defer func() {
+		SummaryInterval = 10 * time.Second
+		refreshInterval = 100 * time.Millisecond
+		Interactive = interactive
+	}()
+
+	run := func() {
+		p := StartProgress("Running command on 3 node(s)", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"})
+		p.Set("10.0.0.1", "running")
+		p.Set("10.0.0.2", "running")
+		p.Finish("10.0.0.1", nil)
+		p.Finish("10.0.0.2", errors.New("exit status 1"))
+		p.Set("10.0.0.2", "running")
+		p.Set("10.0.0.3", "connecting")
+		p.Stop()
+		p.Stop()
+	}
+
+	cases := map[string]struct {
+		interactive bool
+		want        string
+	}{
+		// Hosts are printed as they finish followed by a summary
+		"Plain": {
+			want: "Running command on 3 node(s)...\n  ✓ 10.0.0.1\n  x 10.0.0.2: failed\n  2/3 done, 1 failed, 1 connecting\n",
+		},
+		// Every host gets a line redrawn in place
+		"Terminal": {
+			interactive: true,
+			want:        "Running command on 3 node(s)...\n\r\033[2K  ✓ 10.0.0.1\n\r\033[2K  x 10.0.0.2: failed\n\r\033[2K  | 10.0.0.3: connecting\n",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			Output = &out
+			Interactive = func() bool { return tc.interactive }
+			run()
+			assert.Equal(t, tc.want, out.String())
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quiet replaces the output of a command by a single summary line printed when
+// it finishes, which suits cron jobs and CI
+var Quiet bool
+
+// Formats of the summary line
+const (
+	SummaryText = "text"
+	SummaryJSON = "json"
+)
+
+// SummaryFormat is the format of the summary line
+var SummaryFormat = SummaryText
+
+// Summary is the outcome of a command run in quiet mode
+type Summary struct {
+	Command  string  `json:"command"`
+	Status   string  `json:"status"`
+	ExitCode int     `json:"exitCode"`
+	Duration float64 `json:"durationSeconds"`
+	Warnings int     `json:"warnings"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// String is the summary as a single line of text
+func (s Summary) String() string {
+	status := s.Status
+	if s.ExitCode != 0 {
+		status += fmt.Sprintf(" (exit %d)", s.ExitCode)
+	}
+	duration := time.Duration(s.Duration * float64(time.Second)).Round(time.Millisecond)
+	line := fmt.Sprintf("%s: %s in %s, %d warning(s)", s.Command, status, duration, s.Warnings)
+	if s.Error != "" {
+		line += ": " + s.Error
+	}
+	return line
+}
+
+// quietRun is the command run in quiet mode
+type quietRun struct {
+	mu       sync.Mutex
+	out      io.Writer
+	command  string
+	start    time.Time
+	warnings int
+	done     bool
+}
+
+var quiet *quietRun
+
+// BeginQuiet silences the output of command, which is then only summarized by
+// EndQuiet
+func BeginQuiet(command string) error {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("unable to silence the output: %w", err)
+	}
+	beginQuiet(command, os.Stdout)
+	os.Stdout = devNull
+	return nil
+}
+
+func beginQuiet(command string, out io.Writer) {
+	quiet = &quietRun{out: out, command: command, start: time.Now()}
+	Output = ioutil.Discard
+	Plain = true
+}
+
+// CountWarning counts a warning logged by the command in its summary
+func CountWarning() {
+	if quiet == nil {
+		return
+	}
+	quiet.mu.Lock()
+	quiet.warnings++
+	quiet.mu.Unlock()
+}
+
+// EndQuiet prints the summary of the command when it runs in quiet mode. The
+// command failed when exitCode isn't 0, with errMsg when it is known. Only the
+// first call prints the summary.
+func EndQuiet(exitCode int, errMsg string) {
+	if quiet == nil {
+		return
+	}
+	quiet.mu.Lock()
+	defer quiet.mu.Unlock()
+	if quiet.done {
+		return
+	}
+	quiet.done = true
+
+	s := Summary{
+		Command:  quiet.command,
+		Status:   "OK",
+		ExitCode: exitCode,
+		Duration: time.Since(quiet.start).Seconds(),
+		Warnings: quiet.warnings,
+		// The summary is kept on a single line
+		Error: strings.Join(strings.Fields(errMsg), " "),
+	}
+	if exitCode != 0 {
+		s.Status = "FAILED"
+	}
+	if SummaryFormat == SummaryJSON {
+		out, _ := json.Marshal(s)
+		fmt.Fprintln(quiet.out, string(out))
+		return
+	}
+	fmt.Fprintln(quiet.out, s.String())
+}
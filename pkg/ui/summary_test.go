@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndQuiet(t *testing.T) {
+	defer func() { quiet, Plain, Output, SummaryFormat = nil, false, color.Output, SummaryText }()
+
+	cases := map[string]struct {
+		format   string
+		exitCode int
+		errMsg   string
+		want     string
+	}{
+		"OK":     {SummaryText, 0, "", `^check-node: OK in \d+m?s, 1 warning\(s\)\n$`},
+		"Failed": {SummaryText, 1, "Unable to load\nthe context", `^check-node: FAILED \(exit 1\) in \d+m?s, 1 warning\(s\): Unable to load the context\n$`},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			SummaryFormat = tc.format
+			beginQuiet("check-node", &out)
+			CountWarning()
+			EndQuiet(tc.exitCode, tc.errMsg)
+			// Only the first outcome is summarized
+			EndQuiet(0, "")
+			assert.Regexp(t, regexp.MustCompile(tc.want), out.String())
+		})
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		SummaryFormat = SummaryJSON
+		beginQuiet("attach-node", &out)
+		EndQuiet(3, "")
+		var s Summary
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &s))
+		assert.Equal(t, "attach-node", s.Command)
+		assert.Equal(t, "FAILED", s.Status)
+		assert.Equal(t, 3, s.ExitCode)
+		assert.Empty(t, s.Error)
+	})
+}
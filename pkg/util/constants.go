@@ -16,6 +16,20 @@ var SkipPrepNode bool
 
 // SkipKube skips authorizing kube role during prep-node. Not applicable to bootstrap command
 var SkipKube bool
+
+// RegenerateHostID resets the host identity of the node during prep-node
+var RegenerateHostID bool
+
+// AllowReboot lets prep-node reboot the node when a remediation only applies
+// once the node rebooted
+var AllowReboot bool
+
+// FixHostname adds the hostname of the node to /etc/hosts when it is missing
+var FixHostname bool
+
+// NodeEnvironment is the cloud or bare metal the node being checked runs on,
+// sent with the Segment events
+var NodeEnvironment string
 var HostDown bool
 var EBSPermissions []string
 var Route53Permissions []string
@@ -64,9 +78,6 @@ var (
 	ExisitngInstallationErr = "Platform9 packages already exist. These must be uninstalled."
 	SudoErr                 = "User running pf9ctl must have privilege (sudo) mode enabled."
 	OSPackagesErr           = "Some OS packages needed for the CLI not found"
-	CPUErr                  = "At least 2 CPUs are needed on host."
-	DiskErr                 = "At least 30 GB of total disk space and 15 GB of free space is needed on host."
-	MemErr                  = "At least 12 GB of memory is needed on host."
 )
 
 var (
@@ -88,6 +99,22 @@ var (
 	Pf9DBDir = filepath.Join(Pf9Dir, "db")
 	// Pf9DBLoc represents location of the config file.
 	Pf9DBLoc = filepath.Join(Pf9DBDir, "config.json")
+	// Pf9RegionCacheLoc represents location of the cached region endpoints.
+	Pf9RegionCacheLoc = filepath.Join(Pf9DBDir, "regions.json")
+	// Pf9CompletionCacheLoc represents location of the names cached for shell completion.
+	Pf9CompletionCacheLoc = filepath.Join(Pf9DBDir, "completion.json")
+	// Pf9JobsDir is the dir where the state of batch jobs is stored.
+	Pf9JobsDir = filepath.Join(Pf9DBDir, "jobs")
+	// Pf9MigrationsDir is the dir where the checkpoints of migrate-node are stored.
+	Pf9MigrationsDir = filepath.Join(Pf9DBDir, "migrations")
+	// Pf9ProfilesDir is the dir of the profile bundles migrate-node finds by name.
+	Pf9ProfilesDir = filepath.Join(Pf9DBDir, "profiles")
+	// Pf9PhaseDurationsLoc represents location of the typical durations of the phases of prep-node.
+	Pf9PhaseDurationsLoc = filepath.Join(Pf9DBDir, "phase_durations.json")
+	// Pf9ReportKeyLoc is the key the preflight reports are signed with.
+	Pf9ReportKeyLoc = filepath.Join(Pf9DBDir, "report_signing_key")
+	// Pf9MirrorKeyLoc is the key the package repos of mirror create are signed with.
+	Pf9MirrorKeyLoc = filepath.Join(Pf9DBDir, "mirror_signing_key.asc")
 	// Pf9Log represents location of the log.
 	Pf9Log = filepath.Join(Pf9LogDir, "pf9ctl.log")
 	// WaitPeriod is the sleep period for the cli
@@ -300,7 +327,9 @@ func init() {
 
 //These are the constants needed for everything version related
 const (
-	Version         string = "pf9ctl version: v1.16"
+	// CLIVersion is the bare version of pf9ctl
+	CLIVersion      string = "v1.16"
+	Version         string = "pf9ctl version: " + CLIVersion
 	AWSBucketName   string = "pmkft-assets"
 	AWSBucketKey    string = "pf9ctl"
 	AWSBucketRegion string = "us-west-1"
@@ -0,0 +1,53 @@
+package util
+
+import "strings"
+
+// ClosestMatch returns the candidate closest to value, ignoring case, as long as
+// it is close enough to be a likely typo. An empty string is returned otherwise.
+func ClosestMatch(value string, candidates []string) string {
+	value = strings.ToLower(value)
+	// Allow roughly one typo for every three characters
+	maxDistance := len(value)/3 + 1
+
+	match := ""
+	best := maxDistance + 1
+	for _, candidate := range candidates {
+		if d := levenshtein(value, strings.ToLower(candidate)); d < best {
+			match, best = candidate, d
+		}
+	}
+	return match
+}
+
+// levenshtein returns the number of single character edits needed to turn a into b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
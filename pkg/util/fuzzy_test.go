@@ -0,0 +1,34 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClosestMatch(t *testing.T) {
+	candidates := []string{"RegionOne", "us-west-2", "us-east-1", "eu-central"}
+
+	cases := map[string]string{
+		"RegionOne":  "RegionOne",
+		"regionone":  "RegionOne",
+		"RegoinOne":  "RegionOne",
+		"us-west-1":  "us-west-2",
+		"us-esat-1":  "us-east-1",
+		"eu-centrl":  "eu-central",
+		"ap-south-1": "",
+		"":           "",
+		"completely": "",
+	}
+
+	for value, want := range cases {
+		assert.Equal(t, want, ClosestMatch(value, candidates), value)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("region", "region"))
+	assert.Equal(t, 1, levenshtein("region", "regions"))
+	assert.Equal(t, 2, levenshtein("region", "rgeion"))
+	assert.Equal(t, 6, levenshtein("", "region"))
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"net"
+	"strings"
+)
+
+// NormalizeIP returns the canonical form of the IP address ip, which may be an
+// IPv6 address in brackets. Values which aren't IP addresses, like hostnames,
+// are returned as they are.
+func NormalizeIP(ip string) string {
+	trimmed := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(ip), "["), "]")
+	if parsed := net.ParseIP(trimmed); parsed != nil {
+		return parsed.String()
+	}
+	return ip
+}
+
+// NormalizeIPs returns the canonical form of the IP addresses
+func NormalizeIPs(ips []string) []string {
+	normalized := make([]string, len(ips))
+	for i, ip := range ips {
+		normalized[i] = NormalizeIP(ip)
+	}
+	return normalized
+}
+
+// SameIP reports whether a and b are the same address, whichever way each of
+// them is written
+func SameIP(a, b string) bool {
+	return NormalizeIP(a) == NormalizeIP(b)
+}
+
+// ContainsIP reports whether ip is one of ips
+func ContainsIP(ips []string, ip string) bool {
+	for _, i := range ips {
+		if SameIP(i, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIPv6 reports whether ip is an IPv6 address
+func IsIPv6(ip string) bool {
+	parsed := net.ParseIP(NormalizeIP(ip))
+	return parsed != nil && parsed.To4() == nil
+}
+
+// IPFamilies reports which of IPv4 and IPv6 the addresses or CIDRs are of
+func IPFamilies(addresses []string) (ipv4, ipv6 bool) {
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if i := strings.Index(address, "/"); i >= 0 {
+			address = address[:i]
+		}
+		parsed := net.ParseIP(NormalizeIP(address))
+		switch {
+		case parsed == nil:
+		case parsed.To4() != nil:
+			ipv4 = true
+		default:
+			ipv6 = true
+		}
+	}
+	return ipv4, ipv6
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeIP(t *testing.T) {
+	cases := map[string]struct {
+		input string
+		want  string
+	}{
+		"IPv4":        {"10.0.0.1", "10.0.0.1"},
+		"IPv6":        {"fd00:0:0:0:0:0:0:1", "fd00::1"},
+		"Bracketed":   {"[fd00::1]", "fd00::1"},
+		"UpperCase":   {"FD00::A", "fd00::a"},
+		"MappedIPv4":  {"::ffff:10.0.0.1", "10.0.0.1"},
+		"Hostname":    {"node1.example.com", "node1.example.com"},
+		"NotAnIP":     {"[node1]", "[node1]"},
+		"Whitespaces": {" 10.0.0.1 ", "10.0.0.1"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, NormalizeIP(tc.input))
+		})
+	}
+}
+
+func TestSameIP(t *testing.T) {
+	assert.True(t, SameIP("fd00::1", "[fd00:0::1]"))
+	assert.True(t, SameIP("10.0.0.1", "10.0.0.1"))
+	assert.False(t, SameIP("fd00::1", "fd00::2"))
+	assert.True(t, ContainsIP([]string{"10.0.0.1", "FD00::1"}, "fd00::1"))
+	assert.False(t, ContainsIP([]string{"10.0.0.1"}, "fd00::1"))
+	assert.True(t, IsIPv6("[fd00::1]"))
+	assert.False(t, IsIPv6("10.0.0.1"))
+	assert.False(t, IsIPv6("node1"))
+}
+
+func TestIPFamilies(t *testing.T) {
+	cases := map[string]struct {
+		addresses  []string
+		ipv4, ipv6 bool
+	}{
+		"IPv4":      {[]string{"10.20.0.0/16"}, true, false},
+		"IPv6":      {[]string{"fd00:20::/64"}, false, true},
+		"DualStack": {[]string{"10.20.0.0/16", " fd00:20::/64"}, true, true},
+		"Addresses": {[]string{"10.0.0.1", "fd00::1"}, true, true},
+		"Empty":     {nil, false, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ipv4, ipv6 := IPFamilies(tc.addresses)
+			assert.Equal(t, tc.ipv4, ipv4)
+			assert.Equal(t, tc.ipv6, ipv6)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package util
+
+import "fmt"
+
+// Roles a node can be prepared for with prep-node --role
+const (
+	RoleMaster = "master"
+	RoleWorker = "worker"
+)
+
+// NodeRole is the role the node is prepared for, empty when it isn't known in
+// which case the node is checked to be able to take any role
+var NodeRole string
+
+// NodeRequirements are the resources a node needs for its role
+type NodeRequirements struct {
+	CPUs int
+	// MemGB is the RAM in GiBs
+	MemGB int
+	// DiskGB is the size of the root disk in GiBs
+	DiskGB int
+	// AvailDiskGB is the free space of the root disk in GiBs
+	AvailDiskGB int
+}
+
+// roleRequirements are the resources needed by each role. Masters run etcd
+// and the control plane on top of workloads, workers only need room for the
+// kubelet and the pods.
+var roleRequirements = map[string]NodeRequirements{
+	"":         {CPUs: MinCPUs, MemGB: MinMem, DiskGB: MinDisk, AvailDiskGB: MinAvailDisk},
+	RoleMaster: {CPUs: MinCPUs, MemGB: MinMem, DiskGB: MinDisk, AvailDiskGB: MinAvailDisk},
+	RoleWorker: {CPUs: MinCPUs, MemGB: 8, DiskGB: 20, AvailDiskGB: 10},
+}
+
+// ValidateNodeRole checks role is empty, master or worker
+func ValidateNodeRole(role string) error {
+	if _, ok := roleRequirements[role]; !ok {
+		return fmt.Errorf("invalid role %s, it should be %s or %s", role, RoleMaster, RoleWorker)
+	}
+	return nil
+}
+
+// Requirements returns the resources needed by a node of NodeRole
+func Requirements() NodeRequirements {
+	return roleRequirements[NodeRole]
+}
+
+// CPUErr is the check failure message of a node with too few CPUs
+func (r NodeRequirements) CPUErr() string {
+	return fmt.Sprintf("At least %d CPUs are needed on %s.", r.CPUs, roleHost())
+}
+
+// DiskErr is the check failure message of a node with too small a disk
+func (r NodeRequirements) DiskErr() string {
+	return fmt.Sprintf("At least %d GB of total disk space and %d GB of free space is needed on %s.", r.DiskGB, r.AvailDiskGB, roleHost())
+}
+
+// MemErr is the check failure message of a node with too little memory
+func (r NodeRequirements) MemErr() string {
+	return fmt.Sprintf("At least %d GB of memory is needed on %s.", r.MemGB, roleHost())
+}
+
+func roleHost() string {
+	if NodeRole == "" {
+		return "host"
+	}
+	return NodeRole + " host"
+}
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseSelection parses the choices of a numbered menu of n items, given as
+// comma separated numbers and ranges like "1,3,5-7", into the 0-based indexes
+// of the items in the order of the menu. An empty input selects nothing.
+func ParseSelection(input string, n int) ([]int, error) {
+	selected := make(map[int]bool)
+	for _, field := range strings.Split(input, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		from, to := field, field
+		if i := strings.Index(field, "-"); i > 0 {
+			from, to = strings.TrimSpace(field[:i]), strings.TrimSpace(field[i+1:])
+		}
+		first, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid choice %q, expected a number or a range like 2-4", field)
+		}
+		last, err := strconv.Atoi(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid choice %q, expected a number or a range like 2-4", field)
+		}
+		if first > last {
+			return nil, fmt.Errorf("invalid range %q, its start is after its end", field)
+		}
+		if first < 1 || last > n {
+			return nil, fmt.Errorf("choice %q is out of the range 1-%d", field, n)
+		}
+		for i := first; i <= last; i++ {
+			selected[i-1] = true
+		}
+	}
+
+	var indexes []int
+	for i := 0; i < n; i++ {
+		if selected[i] {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSelection(t *testing.T) {
+	cases := map[string]struct {
+		input string
+		want  []int
+		err   bool
+	}{
+		"Empty":      {input: ""},
+		"Blank":      {input: "  "},
+		"Single":     {input: "2", want: []int{1}},
+		"List":       {input: "3, 1", want: []int{0, 2}},
+		"Range":      {input: "2-4", want: []int{1, 2, 3}},
+		"Mixed":      {input: "1,3-4", want: []int{0, 2, 3}},
+		"Duplicates": {input: "2,1-2", want: []int{0, 1}},
+		"Zero":       {input: "0", err: true},
+		"OutOfRange": {input: "6", err: true},
+		"Reversed":   {input: "4-2", err: true},
+		"NotNumber":  {input: "a", err: true},
+		"Negative":   {input: "-1", err: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseSelection(tc.input, 5)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}